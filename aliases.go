@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// aliasNamePattern matches the names git accepts for aliases
+var aliasNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// parseGitAlias parses NAME=COMMAND for the alias field
+func parseGitAlias(value string) (string, string, error) {
+	name, command, ok := strings.Cut(value, "=")
+	name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+	if !ok || !aliasNamePattern.MatchString(name) || command == "" {
+		return "", "", fmt.Errorf("alias must be NAME=COMMAND (e.g. ci=commit -s, or sync=!./scripts/sync.sh)")
+	}
+	return name, command, nil
+}
+
+// aliasKeys returns the alias.NAME values of a profile's aliases
+func aliasKeys(profile Profile) []ManagedKey {
+	var keys []ManagedKey
+	for _, name := range sortedKeys(profile.Aliases) {
+		keys = append(keys, ManagedKey{Key: "alias." + name, Value: profile.Aliases[name]})
+	}
+	return keys
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseGitAlias tests parsing NAME=COMMAND
+func TestParseGitAlias(t *testing.T) {
+	name, command, err := parseGitAlias(" lg = log --format=%h ")
+	if err != nil || name != "lg" || command != "log --format=%h" {
+		t.Errorf("parseGitAlias() = %q, %q, %v", name, command, err)
+	}
+	for _, value := range []string{"lg", "lg=", "=log", "two words=log", "a.b=log"} {
+		if _, _, err := parseGitAlias(value); err == nil {
+			t.Errorf("parseGitAlias(%q) accepted an invalid alias", value)
+		}
+	}
+}
+
+// TestAliasKeys tests that aliases are applied in name order
+func TestAliasKeys(t *testing.T) {
+	keys := aliasKeys(Profile{Aliases: map[string]string{"st": "status -sb", "ci": "commit -s"}})
+	expected := []ManagedKey{
+		{Key: "alias.ci", Value: "commit -s"},
+		{Key: "alias.st", Value: "status -sb"},
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("aliasKeys() = %v, expected %v", keys, expected)
+	}
+}
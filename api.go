@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The serve API speaks newline-delimited JSON requests and responses. A
+// client starts with a "hello" handshake listing the protocol versions it
+// understands; the server answers with the highest version both support
+// and the capabilities available in it. Clients that skip the handshake get
+// version 1, so prompt snippets written against it keep working.
+//
+// Adding a method to an existing version only adds a capability. Changing
+// the shape of a request or response requires a new version, and old
+// versions stay in apiCapabilities until they are dropped on purpose.
+
+// apiCapabilities lists the methods of every protocol version
+var apiCapabilities = map[int][]string{
+	1: {"audit", "check", "current", "list", "profiles", "switch"},
+}
+
+// apiRequest is a single request line
+type apiRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// apiError is the error of a failed request. Code is a stable identifier
+// clients can branch on
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiResponse is a single response line
+type apiResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  *apiError       `json:"error,omitempty"`
+}
+
+// helloParams are the parameters of the handshake
+type helloParams struct {
+	Versions     []int    `json:"versions"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// helloResult is the negotiated protocol of a session
+type helloResult struct {
+	Version      int      `json:"version"`
+	Capabilities []string `json:"capabilities"`
+	Server       string   `json:"server"`
+}
+
+// apiSession is the state of one client connection
+type apiSession struct {
+	version      int
+	capabilities map[string]bool
+}
+
+// apiVersions returns the protocol versions the server supports, newest first
+func apiVersions() []int {
+	versions := make([]int, 0, len(apiCapabilities))
+	for version := range apiCapabilities {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	return versions
+}
+
+// newAPISession returns a session speaking version 1, as used by clients
+// that don't shake hands
+func newAPISession() *apiSession {
+	session := &apiSession{}
+	session.use(1, nil)
+	return session
+}
+
+// use switches the session to version, limited to the requested
+// capabilities when any are given
+func (s *apiSession) use(version int, requested []string) []string {
+	wanted := map[string]bool{}
+	for _, capability := range requested {
+		wanted[capability] = true
+	}
+
+	s.version = version
+	s.capabilities = map[string]bool{}
+	var granted []string
+	for _, capability := range apiCapabilities[version] {
+		if len(requested) == 0 || wanted[capability] {
+			s.capabilities[capability] = true
+			granted = append(granted, capability)
+		}
+	}
+	return granted
+}
+
+// negotiate picks the highest version supported by both sides
+func negotiate(clientVersions []int) (int, bool) {
+	supported := map[int]bool{}
+	for _, version := range clientVersions {
+		supported[version] = true
+	}
+	for _, version := range apiVersions() {
+		if supported[version] {
+			return version, true
+		}
+	}
+	return 0, false
+}
+
+// handle answers a single request
+func (s *apiSession) handle(req apiRequest) apiResponse {
+	resp := apiResponse{ID: req.ID}
+	fail := func(code, format string, args ...interface{}) apiResponse {
+		resp.Error = &apiError{Code: code, Message: fmt.Sprintf(format, args...)}
+		return resp
+	}
+
+	if req.Method == "hello" {
+		var params helloParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return fail("invalid_params", "%v", err)
+			}
+		}
+		negotiated, ok := negotiate(params.Versions)
+		if !ok {
+			return fail("unsupported_version", "server supports versions %v", apiVersions())
+		}
+		resp.Result = helloResult{
+			Version:      negotiated,
+			Capabilities: s.use(negotiated, params.Capabilities),
+			Server:       "git-usr " + version,
+		}
+		return resp
+	}
+
+	if !s.capabilities[req.Method] {
+		return fail("unsupported_method", "method %q is not available in version %d", req.Method, s.version)
+	}
+
+	var params apiParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return fail("invalid_params", "%v", err)
+		}
+	}
+	if req.Method == "switch" && params.Profile == "" {
+		return fail("invalid_params", "switch requires a profile")
+	}
+	if params.Scope != "" && params.Scope != "local" && params.Scope != "global" {
+		return fail("invalid_params", "scope must be local or global")
+	}
+
+	result, err := apiMethod(req.Method, params)
+	if errors.Is(err, errConfigLocked) {
+		return fail("locked", "%v", err)
+	}
+	if err != nil {
+		return fail("failed", "%v", err)
+	}
+	resp.Result = result
+	return resp
+}
+
+// apiParams are the parameters of the methods. Dir is the directory
+// repositories are resolved relative to; Profile and Scope are for switch
+type apiParams struct {
+	Dir     string `json:"dir"`
+	Profile string `json:"profile"`
+	Scope   string `json:"scope"`
+}
+
+// apiListEntry is a profile as listed by the list method
+type apiListEntry struct {
+	Profile     string   `json:"profile"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Default     bool     `json:"default,omitempty"`
+}
+
+// apiSwitch switches dir or the global config to a profile by running
+// git-usr, so its output doesn't end up in the API stream. It returns the
+// output, or an error carrying it
+func apiSwitch(params apiParams) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{"--plain"}, configFlagArgs()...)
+	args = append(args, "-C", params.Dir, "switch", params.Profile, "--exact")
+	if params.Scope != "" {
+		args = append(args, "--"+params.Scope)
+	}
+	out, err := exec.Command(executable, args...).CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output == "" {
+			return "", err
+		}
+		return "", errors.New(output)
+	}
+	return output, nil
+}
+
+// apiMethod runs a method
+func apiMethod(method string, params apiParams) (interface{}, error) {
+	dir := params.Dir
+	switch method {
+	case "profiles":
+		profiles, err := loadProfiles()
+		if err != nil {
+			return nil, err
+		}
+		// Only identities: environment variables may hold tokens
+		identities := map[string]map[string]string{}
+		for name, profile := range profiles {
+			identities[name] = map[string]string{"name": profile.Name, "email": profile.Email}
+		}
+		return identities, nil
+
+	case "current":
+		name, email := getIdentityIn(dir)
+		profileName := ""
+		if name != "" && email != "" {
+			profiles, err := loadProfiles()
+			if err != nil {
+				return nil, err
+			}
+			profileName, _ = findProfileByIdentity(profiles, name, email)
+		}
+		return map[string]string{"name": name, "email": email, "profile": profileName}, nil
+
+	case "check":
+		state, err := promptCheckStateIn(dir)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int{"state": state}, nil
+
+	case "list":
+		config, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		// Like profiles, without environment variables and credentials
+		entries := []apiListEntry{}
+		for _, name := range sortedProfileNames(config.Profiles) {
+			profile := config.Profiles[name]
+			entries = append(entries, apiListEntry{
+				Profile:     name,
+				Name:        profile.Name,
+				Email:       profile.Email,
+				Description: profile.Description,
+				Tags:        profile.Tags,
+				Default:     name == config.Settings.DefaultProfile,
+			})
+		}
+		return entries, nil
+
+	case "switch":
+		profiles, err := loadProfiles()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := profiles[params.Profile]; !exists {
+			return nil, fmt.Errorf("profile %q not found", params.Profile)
+		}
+		output, err := apiSwitch(params)
+		if err != nil {
+			return nil, err
+		}
+		name, email := getIdentityIn(dir)
+		return map[string]string{"name": name, "email": email, "profile": params.Profile, "output": output}, nil
+
+	case "audit":
+		config, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		state, err := loadState()
+		if err != nil {
+			return nil, err
+		}
+		report := buildReport(config, state, time.Now(), inspectRepo)
+		result := map[string]interface{}{"repos": report.Repos, "violations": report.Violations}
+		if report.Repos == nil {
+			result["repos"] = []reportRepo{}
+		}
+		if report.Violations == nil {
+			result["violations"] = []reportViolation{}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown method %q", method)
+}
+
+// apiMutex serializes requests across sessions: loading the config sets
+// package state and may pull a git store's clone
+var apiMutex sync.Mutex
+
+// serveSession answers requests from r on w until r is closed
+func serveSession(r io.Reader, w io.Writer) error {
+	session := newAPISession()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	encoder := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var req apiRequest
+		var resp apiResponse
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			resp.Error = &apiError{Code: "invalid_request", Message: err.Error()}
+		} else {
+			apiMutex.Lock()
+			resp = session.handle(req)
+			apiMutex.Unlock()
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// getAPISocketPath returns the socket serve --unix-socket listens on when
+// no path is given
+func getAPISocketPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "api.sock"), nil
+}
+
+// listenUnixSocket listens on the socket at path, only for the current
+// user. A socket left behind by a server that is gone is replaced; one a
+// server still answers on is an error
+func listenUnixSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another server is listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Anyone who can connect can switch the identity
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveUnixSocket answers API sessions on the socket at path, one per
+// connection, until interrupted
+func serveUnixSocket(path string) error {
+	// Nobody answers a prompt on the terminal of a daemon
+	promptsDisabled = true
+
+	listener, err := listenUnixSocket(path)
+	if err != nil {
+		fmt.Printf("❌ Could not listen on %s: %v\n", path, err)
+		return err
+	}
+	// Unix sockets are removed on Close
+	defer listener.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	fmt.Printf("🔌 Serving the JSON API on %s\n", path)
+	fmt.Println("   Press Ctrl-C to stop")
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			serveSession(conn, conn)
+		}()
+	}
+}
+
+// runServe handles the serve command
+func runServe(args []string) error {
+	usage := "Usage: git usr serve --stdio | --unix-socket [<path>]"
+	switch {
+	case len(args) == 1 && args[0] == "--stdio":
+		// stdin carries the requests and stdout the responses: diagnostics
+		// go to stderr and a locked config fails instead of prompting
+		promptsDisabled = true
+		stream := os.Stdout
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stream }()
+		return serveSession(os.Stdin, stream)
+	case len(args) == 1 && args[0] == "--unix-socket":
+		path, err := getAPISocketPath()
+		if err != nil {
+			return err
+		}
+		return serveUnixSocket(path)
+	case len(args) == 2 && args[0] == "--unix-socket":
+		path, err := expandHome(args[1])
+		if err != nil {
+			return err
+		}
+		return serveUnixSocket(path)
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("serve requires --stdio or --unix-socket")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNegotiate tests picking the highest common protocol version
+func TestNegotiate(t *testing.T) {
+	if version, ok := negotiate([]int{1, 99}); !ok || version != 1 {
+		t.Errorf("negotiate([1 99]) = %d, %v", version, ok)
+	}
+	if _, ok := negotiate([]int{99}); ok {
+		t.Error("Expected no common version")
+	}
+	if _, ok := negotiate(nil); ok {
+		t.Error("Expected no common version without client versions")
+	}
+}
+
+// TestServeSessionHandshake tests the handshake and capability checks
+func TestServeSessionHandshake(t *testing.T) {
+	input := strings.Join([]string{
+		`{"id":1,"method":"hello","params":{"versions":[99]}}`,
+		`{"id":2,"method":"hello","params":{"versions":[1],"capabilities":["check","future"]}}`,
+		`{"id":3,"method":"profiles"}`,
+		`not json`,
+		``,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := serveSession(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("serveSession failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 responses, got %d:\n%s", len(lines), out.String())
+	}
+
+	type response struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *apiError       `json:"error"`
+	}
+	var responses []response
+	for _, line := range lines {
+		var resp response
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("Invalid response %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+
+	if responses[0].Error == nil || responses[0].Error.Code != "unsupported_version" {
+		t.Errorf("Expected unsupported_version, got %s", lines[0])
+	}
+
+	var hello helloResult
+	if err := json.Unmarshal(responses[1].Result, &hello); err != nil {
+		t.Fatalf("Invalid hello result %s: %v", lines[1], err)
+	}
+	if hello.Version != 1 || len(hello.Capabilities) != 1 || hello.Capabilities[0] != "check" {
+		t.Errorf("Unexpected hello result %+v", hello)
+	}
+
+	// profiles was not among the negotiated capabilities
+	if responses[2].ID != 3 || responses[2].Error == nil || responses[2].Error.Code != "unsupported_method" {
+		t.Errorf("Expected unsupported_method, got %s", lines[2])
+	}
+	if responses[3].Error == nil || responses[3].Error.Code != "invalid_request" {
+		t.Errorf("Expected invalid_request, got %s", lines[3])
+	}
+}
+
+// TestAPISessionDefaults tests that clients skipping the handshake get
+// version 1 with all of its capabilities
+func TestAPISessionDefaults(t *testing.T) {
+	session := newAPISession()
+	if session.version != 1 {
+		t.Errorf("Expected version 1, got %d", session.version)
+	}
+	for _, capability := range apiCapabilities[1] {
+		if !session.capabilities[capability] {
+			t.Errorf("Capability %s missing", capability)
+		}
+	}
+}
+
+// TestAPIList tests listing profiles without their environment
+func TestAPIList(t *testing.T) {
+	setupConfigHome(t)
+	config := &Config{
+		Profiles: map[string]Profile{
+			"work": {Name: "Jane Doe", Email: "jane@acme.io", Tags: []string{"job"}, Env: map[string]string{"TOKEN": "secret"}},
+			"oss":  {Name: "Jane Doe", Email: "jane@example.org"},
+		},
+		Settings: Settings{DefaultProfile: "work"},
+	}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := serveSession(strings.NewReader(`{"id":1,"method":"list"}`+"\n"), &out); err != nil {
+		t.Fatalf("serveSession failed: %v", err)
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Errorf("list leaked the environment: %s", out.String())
+	}
+	var resp struct {
+		Result []apiListEntry `json:"result"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response %s: %v", out.String(), err)
+	}
+	if len(resp.Result) != 2 || resp.Result[0].Profile != "oss" || resp.Result[1].Profile != "work" || !resp.Result[1].Default || resp.Result[0].Default {
+		t.Errorf("Unexpected list %+v", resp.Result)
+	}
+}
+
+// TestAPILockedConfig tests that a locked config fails with the locked
+// code instead of reading the next request as its passphrase
+func TestAPILockedConfig(t *testing.T) {
+	setupConfigHome(t)
+	t.Setenv("GIT_USR_PASSPHRASE", "")
+	configPassphrase, configLocked = "hunter2", true
+	if err := saveConfig(defaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	configPassphrase, configLocked, promptsDisabled = "", false, true
+	t.Cleanup(func() { configPassphrase, configLocked, promptsDisabled = "", false, false })
+
+	var out bytes.Buffer
+	requests := `{"id":1,"method":"list"}` + "\n" + `{"id":2,"method":"profiles"}` + "\n"
+	if err := serveSession(strings.NewReader(requests), &out); err != nil {
+		t.Fatalf("serveSession failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a response to each request, got %q", out.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"code":"locked"`) {
+			t.Errorf("Expected the locked code, got %s", line)
+		}
+	}
+}
+
+// TestAPISwitchParams tests rejecting switches without a profile or with
+// an unknown scope
+func TestAPISwitchParams(t *testing.T) {
+	input := strings.Join([]string{
+		`{"id":1,"method":"switch"}`,
+		`{"id":2,"method":"switch","params":{"profile":"work","scope":"system"}}`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := serveSession(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("serveSession failed: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.Contains(line, `"code":"invalid_params"`) {
+			t.Errorf("Expected invalid_params, got %s", line)
+		}
+	}
+}
+
+// TestListenUnixSocket tests replacing sockets left behind and refusing
+// to take over one in use
+func TestListenUnixSocket(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, too short for some
+	// temporary directories
+	dir, err := os.MkdirTemp("", "gu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "api.sock")
+
+	listener, err := listenUnixSocket(path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a socket only the user can use, got %v, %v", info.Mode(), err)
+	}
+	if _, err := listenUnixSocket(path); err == nil {
+		t.Error("Expected an error for a socket in use")
+	}
+
+	// The check for a socket in use connected once already
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			serveSession(conn, conn)
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(conn, `{"id":7,"method":"hello","params":{"versions":[1]}}`)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	conn.Close()
+	if err != nil || !strings.Contains(line, `"id":7`) || !strings.Contains(line, `"switch"`) {
+		t.Errorf("Unexpected handshake over the socket: %q, %v", line, err)
+	}
+	listener.Close()
+
+	// A socket whose server is gone is replaced
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnixSocket(filepath.Join(dir, "file")); err == nil {
+		t.Error("Expected an error for a file that isn't a socket")
+	}
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	listener, err = listenUnixSocket(path)
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced: %v", err)
+	}
+	listener.Close()
+}
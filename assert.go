@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// gitIdent is the identity git records as author or committer
+type gitIdent struct {
+	Role  string
+	Name  string
+	Email string
+}
+
+// parseGitIdent parses `git var GIT_AUTHOR_IDENT` output, which looks like
+// "Jane Doe <jane@example.com> 1700000000 +0100"
+func parseGitIdent(out string) (name, email string, ok bool) {
+	out = strings.TrimSpace(out)
+	start := strings.LastIndex(out, "<")
+	end := strings.LastIndex(out, ">")
+	if start < 0 || end < start {
+		return "", "", false
+	}
+	return strings.TrimSpace(out[:start]), out[start+1 : end], true
+}
+
+// effectiveIdents asks git for the author and committer identity of the
+// next commit or tag, so GIT_AUTHOR_*/GIT_COMMITTER_* variables and
+// committer.* config count just like they will for the real thing
+func effectiveIdents() ([]gitIdent, error) {
+	var idents []gitIdent
+	for _, role := range []string{"author", "committer"} {
+		out, err := runGit("", "var", "GIT_"+strings.ToUpper(role)+"_IDENT")
+		if isUnsafeRepository(err) {
+			return nil, err
+		}
+		name, email, ok := parseGitIdent(out)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("git has no %s identity", role)
+		}
+		idents = append(idents, gitIdent{Role: role, Name: name, Email: email})
+	}
+	return idents, nil
+}
+
+// emailInDomain reports whether email is at domain or one of its subdomains
+func emailInDomain(email, domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "@"))
+	at := strings.LastIndex(email, "@")
+	if at < 0 || domain == "" {
+		return false
+	}
+	host := strings.ToLower(email[at+1:])
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// identProfile returns the profile an identity belongs to in its role
+func identProfile(profiles map[string]Profile, ident gitIdent, candidates []string) (string, bool) {
+	for _, name := range candidates {
+		profile := profiles[name]
+		if ident.Role == "committer" {
+			committerName, committerEmail := committerIdentity(profile)
+			profile = Profile{Name: committerName, Email: committerEmail}
+		}
+		if identityMatches(profile, ident.Name, ident.Email) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// assertProblems checks the identities against the wanted profiles and
+// domains, any of which will do. With neither, the identities only need
+// to belong to some profile
+func assertProblems(idents []gitIdent, profiles map[string]Profile, wantProfiles, wantDomains []string) []string {
+	var problems []string
+	for _, ident := range idents {
+		// Only report the committer when it differs from the author
+		if ident.Role == "committer" && len(idents) > 1 && ident.Name == idents[0].Name && ident.Email == idents[0].Email {
+			continue
+		}
+		who := fmt.Sprintf("%s %s", ident.Role, formatAddress(ident.Name, ident.Email))
+
+		if len(wantProfiles) > 0 {
+			if _, ok := identProfile(profiles, ident, wantProfiles); !ok {
+				problems = append(problems, fmt.Sprintf("%s is not profile '%s'", who, strings.Join(wantProfiles, "' or '")))
+			}
+		}
+		if len(wantDomains) > 0 {
+			inDomain := false
+			for _, domain := range wantDomains {
+				inDomain = inDomain || emailInDomain(ident.Email, domain)
+			}
+			if !inDomain {
+				problems = append(problems, fmt.Sprintf("%s is not at %s", who, strings.Join(wantDomains, " or ")))
+			}
+		}
+		if len(wantProfiles) == 0 && len(wantDomains) == 0 {
+			if _, ok := identProfile(profiles, ident, sortedProfileNames(profiles)); !ok {
+				problems = append(problems, fmt.Sprintf("%s does not belong to any profile", who))
+			}
+		}
+	}
+	return problems
+}
+
+// runAssert handles the assert command, meant as the first step of build
+// and release targets: it fails unless the next commit or tag would be
+// made under the expected identity
+func runAssert(args []string) error {
+	usage := "Usage: git usr assert [--profile <profile>]... [--domain <domain>]..."
+
+	var wantProfiles, wantDomains []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var value string
+		switch {
+		case arg == "--profile" || arg == "--domain":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--profile="), strings.HasPrefix(arg, "--domain="):
+			arg, value, _ = strings.Cut(arg, "=")
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+		if arg == "--profile" {
+			wantProfiles = append(wantProfiles, value)
+		} else {
+			wantDomains = append(wantDomains, value)
+		}
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range wantProfiles {
+		if _, exists := profiles[name]; !exists {
+			fmt.Fprintf(os.Stderr, "❌ git-usr assert: profile '%s' not found\n", name)
+			return errProfileNotFound
+		}
+	}
+
+	idents, err := effectiveIdents()
+	if err != nil {
+		if !isUnsafeRepository(err) {
+			fmt.Fprintf(os.Stderr, "❌ git-usr assert: %v\n", err)
+		}
+		return err
+	}
+
+	problems := assertProblems(idents, profiles, wantProfiles, wantDomains)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "❌ git-usr assert: %s\n", problem)
+		}
+		if len(wantProfiles) > 0 {
+			fmt.Fprintf(os.Stderr, "   Run 'git usr %s' first\n", wantProfiles[0])
+		}
+		return fmt.Errorf("identity assertion failed")
+	}
+
+	author := idents[0]
+	if name, ok := identProfile(profiles, author, sortedProfileNames(profiles)); ok {
+		fmt.Printf("✅ Committing as %s (%s)\n", formatAddress(author.Name, author.Email), name)
+	} else {
+		fmt.Printf("✅ Committing as %s\n", formatAddress(author.Name, author.Email))
+	}
+	return nil
+}
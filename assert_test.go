@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseGitIdent tests reading `git var` identities
+func TestParseGitIdent(t *testing.T) {
+	name, email, ok := parseGitIdent("Jane Q. Doe <jane@acme.io> 1700000000 +0100\n")
+	if !ok || name != "Jane Q. Doe" || email != "jane@acme.io" {
+		t.Errorf("parseGitIdent = %q, %q, %v", name, email, ok)
+	}
+	if _, _, ok := parseGitIdent("fatal: no email was given"); ok {
+		t.Error("parseGitIdent accepted an error message")
+	}
+}
+
+// TestEmailInDomain tests matching emails against a domain
+func TestEmailInDomain(t *testing.T) {
+	tests := map[string]bool{
+		"jane@acme.com":      true,
+		"jane@ACME.com":      true,
+		"jane@eng.acme.com":  true,
+		"jane@notacme.com":   false,
+		"jane@acme.com.evil": false,
+		"acme.com":           false,
+	}
+	for email, expected := range tests {
+		if got := emailInDomain(email, "@acme.com"); got != expected {
+			t.Errorf("emailInDomain(%q) = %v, expected %v", email, got, expected)
+		}
+	}
+}
+
+// TestAssertProblems tests checking identities against expectations
+func TestAssertProblems(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {Name: "Jane Doe", Email: "jane@acme.com", CommitterEmail: "release@acme.com"},
+		"personal": {Name: "Jane Doe", Email: "jane@home.dev"},
+	}
+	work := []gitIdent{
+		{Role: "author", Name: "Jane Doe", Email: "jane@acme.com"},
+		{Role: "committer", Name: "Jane Doe", Email: "release@acme.com"},
+	}
+	personal := []gitIdent{
+		{Role: "author", Name: "Jane Doe", Email: "jane@home.dev"},
+		{Role: "committer", Name: "Jane Doe", Email: "jane@home.dev"},
+	}
+
+	if problems := assertProblems(work, profiles, []string{"work"}, nil); len(problems) != 0 {
+		t.Errorf("work as work: %q", problems)
+	}
+	if problems := assertProblems(work, profiles, nil, []string{"acme.com"}); len(problems) != 0 {
+		t.Errorf("work in acme.com: %q", problems)
+	}
+	if problems := assertProblems(personal, profiles, nil, nil); len(problems) != 0 {
+		t.Errorf("personal as any profile: %q", problems)
+	}
+
+	// The committer is the same as the author, so it is reported once
+	problems := assertProblems(personal, profiles, []string{"work"}, nil)
+	if len(problems) != 1 || !strings.Contains(problems[0], "author Jane Doe <jane@home.dev> is not profile 'work'") {
+		t.Errorf("personal as work: %q", problems)
+	}
+
+	// A committer override from the environment is caught too
+	mixed := []gitIdent{work[0], {Role: "committer", Name: "Jane Doe", Email: "jane@home.dev"}}
+	if problems := assertProblems(mixed, profiles, []string{"work"}, nil); len(problems) != 1 || !strings.HasPrefix(problems[0], "committer") {
+		t.Errorf("mixed as work: %q", problems)
+	}
+}
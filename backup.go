@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultBackups is how many backups are kept unless the backups setting
+// says otherwise
+const defaultBackups = 10
+
+// backupIDFormat is the layout of backup IDs, the time the backup was taken
+const backupIDFormat = "20060102-150405.000"
+
+// configBackup is a timestamped copy of the config file
+type configBackup struct {
+	ID   string
+	Path string
+	Time time.Time
+}
+
+// getBackupDir returns the directory backups of the config are kept in
+func getBackupDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "backups")
+}
+
+// backupName returns the file name of a backup, prefixed with the config's
+// own name so configs sharing a directory keep separate backups
+func backupName(configPath, id string) string {
+	base := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	return base + "-" + id + filepath.Ext(configPath)
+}
+
+// listBackups returns the backups of the config, oldest first
+func listBackups(configPath string) ([]configBackup, error) {
+	entries, err := os.ReadDir(getBackupDir(configPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, suffix, _ := strings.Cut(backupName(configPath, "\x00"), "\x00")
+	var backups []configBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		taken, err := time.ParseInLocation(backupIDFormat, id, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, configBackup{ID: id, Path: filepath.Join(getBackupDir(configPath), name), Time: taken})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ID < backups[j].ID })
+	return backups, nil
+}
+
+// latestBackup returns the most recent backup of the config
+func latestBackup(configPath string) (configBackup, bool) {
+	backups, err := listBackups(configPath)
+	if err != nil || len(backups) == 0 {
+		return configBackup{}, false
+	}
+	return backups[len(backups)-1], true
+}
+
+// findBackup returns the backup with an ID, or the only one starting with
+// it, so `20240501` is enough when there was one backup that day
+func findBackup(configPath, id string) (configBackup, error) {
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return configBackup{}, err
+	}
+	var matches []configBackup
+	for _, backup := range backups {
+		if backup.ID == id {
+			return backup, nil
+		}
+		if strings.HasPrefix(backup.ID, id) {
+			matches = append(matches, backup)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return configBackup{}, fmt.Errorf("no backup '%s'", id)
+	case 1:
+		return matches[0], nil
+	}
+	return configBackup{}, fmt.Errorf("'%s' matches %d backups", id, len(matches))
+}
+
+// backupConfig copies the config file on disk to a new timestamped backup
+// before it is replaced, keeping the newest keep backups. Backups are
+// owner-only since they may hold a decrypted config. The plaintext file a
+// config being locked replaces is not kept, as that would undo the lock
+func backupConfig(configPath string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, encrypted := parseEncryptedConfig(data); configLocked && !encrypted {
+		return nil
+	}
+
+	dir := getBackupDir(configPath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Saves within the same millisecond get the next free one
+	taken := time.Now()
+	path := filepath.Join(dir, backupName(configPath, taken.Format(backupIDFormat)))
+	for {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		taken = taken.Add(time.Millisecond)
+		path = filepath.Join(dir, backupName(configPath, taken.Format(backupIDFormat)))
+	}
+	if err := writeFileAtomic(path, data, 0600, true); err != nil {
+		return err
+	}
+
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0].Path); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// encryptBackups encrypts every plaintext backup of the config with
+// passphrase, so locking the config leaves no readable copy behind
+func encryptBackups(configPath, passphrase string) error {
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			return err
+		}
+		if _, encrypted := parseEncryptedConfig(data); encrypted {
+			continue
+		}
+		if data, err = encryptConfig(data, passphrase); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(backup.Path, data, 0600, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeBackup returns the profiles in a backup, without asking for the
+// passphrase of an encrypted one
+func describeBackup(backup configBackup) string {
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return err.Error()
+	}
+	if _, encrypted := parseEncryptedConfig(data); encrypted {
+		return "(encrypted)"
+	}
+	config, err := parseConfig(data)
+	if err != nil {
+		return "(invalid)"
+	}
+	return strings.Join(sortedProfileNames(config.Profiles), ", ")
+}
+
+// showBackups lists the backups of the config, newest first
+func showBackups() error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("No backups of %s yet. One is kept every time it changes\n", configPath)
+		return nil
+	}
+
+	rows := [][]string{{"ID", "TAKEN", "PROFILES"}}
+	for i := len(backups) - 1; i >= 0; i-- {
+		rows = append(rows, []string{backups[i].ID, backups[i].Time.Format("2006-01-02 15:04:05"), describeBackup(backups[i])})
+	}
+	fmt.Printf("\n🗄️  Backups of %s:\n", configPath)
+	for _, line := range formatTable(rows) {
+		fmt.Println("   " + line)
+	}
+	return nil
+}
+
+// restoreConfigBackup replaces the config with a backup. The config being
+// replaced is backed up too, so a restore can itself be undone
+func restoreConfigBackup(id string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	backup, err := findBackup(configPath, id)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		fmt.Println("\nSee 'git usr backup list' for the backups")
+		return err
+	}
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return err
+	}
+	config, _, err := readConfigFile(backup.Path)
+	if err != nil {
+		fmt.Printf("❌ Backup %s is not a valid config: %v\n", backup.ID, err)
+		return err
+	}
+
+	if err := backupConfig(configPath, backupsToKeep(config)); err != nil {
+		return err
+	}
+	_, encrypted := parseEncryptedConfig(data)
+	if err := writeFileAtomic(configPath, data, 0600, encrypted); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Restored backup %s (%d profile(s))\n", backup.ID, len(config.Profiles))
+	return nil
+}
+
+// backupsToKeep returns how many backups a config asks to keep
+func backupsToKeep(config *Config) int {
+	keep, _ := strconv.Atoi(settingKeys["backups"].get(&config.Settings))
+	return keep
+}
+
+// runBackup handles the backup command
+func runBackup(args []string) error {
+	usage := "Usage: git usr backup list | restore <id>"
+
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "list":
+		return showBackups()
+	case len(args) == 2 && args[0] == "restore":
+		return restoreConfigBackup(args[1])
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("invalid backup command")
+}
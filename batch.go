@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// batchEntry is a profile definition read by `git usr add --batch`
+type batchEntry struct {
+	// Position is the 1-based position of the entry in the input
+	Position    int
+	ProfileName string
+	Fields      map[string]any
+}
+
+// batchMapFields are the map keys of profiles.json and the profile field
+// their entries are set with
+var batchMapFields = map[string]string{
+	"hostAliases":         "hostAlias",
+	"urlRewrites":         "urlRewrite",
+	"gitsignOptions":      "gitsignOption",
+	"credentialUsernames": "credentialUsername",
+	"credentialHelpers":   "credentialHelper",
+	"forgeAccounts":       "forgeAccount",
+}
+
+// parseBatch reads profile definitions as JSON, or YAML when the input
+// doesn't start like JSON
+func parseBatch(data []byte) ([]batchEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("no profiles given")
+	}
+
+	var doc any
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		var err error
+		if doc, err = parseYAML(string(data)); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	}
+	return batchEntries(doc)
+}
+
+// batchEntries returns the profiles of a parsed document: a map of profile
+// names to fields, a list of fields with a "profile" key, or either under
+// "profiles" so that a profiles.json can be fed back
+func batchEntries(doc any) ([]batchEntry, error) {
+	if top, ok := doc.(map[string]any); ok {
+		if profiles, ok := top["profiles"]; ok {
+			doc = profiles
+		}
+	}
+
+	var entries []batchEntry
+	switch doc := doc.(type) {
+	case map[string]any:
+		for i, name := range sortedKeys(doc) {
+			fields, ok := doc[name].(map[string]any)
+			if !ok && doc[name] != nil {
+				return nil, fmt.Errorf("profile '%s' must be a mapping of fields", name)
+			}
+			entries = append(entries, batchEntry{Position: i + 1, ProfileName: name, Fields: fields})
+		}
+	case []any:
+		for i, item := range doc {
+			fields, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("entry %d must be a mapping of fields", i+1)
+			}
+			name, _ := fields["profile"].(string)
+			rest := map[string]any{}
+			for key, value := range fields {
+				if key != "profile" {
+					rest[key] = value
+				}
+			}
+			entries = append(entries, batchEntry{Position: i + 1, ProfileName: strings.TrimSpace(name), Fields: rest})
+		}
+	default:
+		return nil, fmt.Errorf("expected a mapping of profiles or a list of profiles")
+	}
+	return entries, nil
+}
+
+// batchString returns a scalar field value
+func batchString(key string, value any) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", key)
+	}
+	return s, nil
+}
+
+// batchStringMap returns a map field value
+func batchStringMap(key string, value any) (map[string]string, error) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping", key)
+	}
+	values := map[string]string{}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a string", key, k)
+		}
+		values[k] = s
+	}
+	return values, nil
+}
+
+// applyBatchFields sets the fields of an entry on profile. Tags and maps
+// are replaced as a whole and null removes a field; fields the entry
+// doesn't mention are kept
+func applyBatchFields(profile *Profile, fields map[string]any) error {
+	for _, key := range sortedKeys(fields) {
+		value := fields[key]
+		switch field, isMap := batchMapFields[key]; {
+		case key == "tags":
+			profile.Tags = nil
+			if value == nil {
+				continue
+			}
+			tags, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("tags must be a list")
+			}
+			for _, tag := range tags {
+				s, err := batchString("tags", tag)
+				if err != nil {
+					return err
+				}
+				if err := profileFields["tag"].set(profile, s); err != nil {
+					return err
+				}
+			}
+		case key == "env":
+			profile.Env = nil
+			if value == nil {
+				continue
+			}
+			env, err := batchStringMap(key, value)
+			if err != nil {
+				return err
+			}
+			for name := range env {
+				if !envKeyPattern.MatchString(name) {
+					return fmt.Errorf("env: invalid variable name '%s'", name)
+				}
+			}
+			if len(env) > 0 {
+				profile.Env = env
+			}
+		case isMap:
+			profileFields[field].unset(profile, "")
+			if value == nil {
+				continue
+			}
+			entries, err := batchStringMap(key, value)
+			if err != nil {
+				return err
+			}
+			for _, k := range sortedKeys(entries) {
+				if err := profileFields[field].set(profile, k+"="+entries[k]); err != nil {
+					return err
+				}
+			}
+		case key != "tag" && profileFields[key].set != nil:
+			if value == nil {
+				profileFields[key].unset(profile, "")
+				continue
+			}
+			s, err := batchString(key, value)
+			if err != nil {
+				return err
+			}
+			if err := profileFields[key].set(profile, s); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown field '%s'", key)
+		}
+	}
+	return nil
+}
+
+// addBatch creates and updates the profiles defined in a JSON or YAML file
+// ("-" for stdin). Entries that leave a profile as it is are skipped, so
+// running the same file twice changes nothing
+func addBatch(path string, dryRun bool) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("❌ Failed to read %s: %v\n", path, err)
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Printf("❌ Failed to read %s: %v\n", path, err)
+		return err
+	}
+
+	entries, err := parseBatch(data)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return err
+	}
+	return applyBatch(entries, batchOptions{DryRun: dryRun, Strict: true})
+}
+
+// batchOptions control how applyBatch treats its entries
+type batchOptions struct {
+	DryRun bool
+	// Strict fails when any entry is invalid, rather than only when all are
+	Strict bool
+	// DefaultName is the user name of new profiles that don't set one
+	DefaultName string
+}
+
+// applyBatch creates and updates the profiles of entries, reporting each.
+// Invalid entries are skipped
+func applyBatch(entries []batchEntry, opts batchOptions) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles := config.Profiles
+
+	added, updated, skipped, invalid := 0, 0, 0, 0
+	seen := map[string]int{}
+	for _, entry := range entries {
+		fail := func(err error) {
+			label := fmt.Sprintf("entry %d", entry.Position)
+			if entry.ProfileName != "" {
+				label = entry.ProfileName
+			}
+			fmt.Printf("   ✗ %s: %v\n", label, err)
+			invalid++
+		}
+		if err := validateImportName(entry.ProfileName); err != nil {
+			fail(err)
+			continue
+		}
+		if first, ok := seen[entry.ProfileName]; ok {
+			fail(fmt.Errorf("already defined by entry %d", first))
+			continue
+		}
+		seen[entry.ProfileName] = entry.Position
+
+		existing, exists := profiles[entry.ProfileName]
+		profile := existing
+		if err := applyBatchFields(&profile, entry.Fields); err != nil {
+			fail(err)
+			continue
+		}
+		if !exists && profile.Name == "" {
+			profile.Name = opts.DefaultName
+		}
+		if err := validateName("name", profile.Name); err != nil {
+			fail(err)
+			continue
+		}
+		if err := validateEmail("email", profile.Email); err != nil {
+			fail(err)
+			continue
+		}
+
+		switch {
+		case exists && reflect.DeepEqual(existing, profile):
+			fmt.Printf("   = %s (unchanged)\n", entry.ProfileName)
+			skipped++
+		case exists:
+			fmt.Printf("   ~ %s (%s)\n", entry.ProfileName, formatAddress(profile.Name, profile.Email))
+			updated++
+		default:
+			fmt.Printf("   + %s (%s)\n", entry.ProfileName, formatAddress(profile.Name, profile.Email))
+			added++
+		}
+		profiles[entry.ProfileName] = profile
+	}
+
+	summary := fmt.Sprintf("%d added, %d updated, %d skipped, %d invalid", added, updated, skipped, invalid)
+	if opts.DryRun {
+		fmt.Printf("\nDry run: %s\n", summary)
+	} else {
+		if added+updated > 0 {
+			if err := saveConfig(config); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("✅ Batch finished: %s\n", summary)
+	}
+
+	switch {
+	case invalid > 0 && invalid == len(entries):
+		return fmt.Errorf("all entries were invalid")
+	case invalid > 0 && opts.Strict:
+		return fmt.Errorf("some entries were invalid")
+	}
+	return nil
+}
+
+// yamlLine is a line of YAML without its indentation and comment
+type yamlLine struct {
+	Number int
+	Indent int
+	Text   string
+}
+
+// yamlParser parses the block-style subset of YAML profile definitions are
+// written in: mappings, sequences and single-line scalars. Anchors, tags,
+// multi-line scalars and flow mappings are rejected rather than misread
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses a YAML document into maps, slices and strings, the
+// values encoding/json would produce
+func parseYAML(text string) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		content := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", i+1)
+		}
+		content = strings.TrimRight(stripYAMLComment(content), " \t")
+		switch {
+		case content == "":
+			continue
+		case content == "---" || content == "...":
+			if len(p.lines) > 0 {
+				return nil, fmt.Errorf("line %d: only a single document is supported", i+1)
+			}
+			continue
+		case strings.HasPrefix(content, "%"):
+			return nil, fmt.Errorf("line %d: directives are not supported", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{Number: i + 1, Indent: len(raw) - len(strings.TrimLeft(raw, " ")), Text: content})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	if p.lines[0].Indent != 0 {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[0].Number)
+	}
+
+	value, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].Number)
+	}
+	return value, nil
+}
+
+// stripYAMLComment removes a # comment, which starts a line or follows a
+// space outside of quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		case (c == '"' || c == '\'') && (i == 0 || strings.ContainsRune(" :-[,", rune(line[i-1]))):
+			quote = c
+		}
+	}
+	return line
+}
+
+// isYAMLSequenceItem reports whether a line starts a sequence item
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the mapping or sequence starting at the current line
+func (p *yamlParser) parseBlock() (any, error) {
+	line := p.lines[p.pos]
+	if isYAMLSequenceItem(line.Text) {
+		return p.parseSequence(line.Indent)
+	}
+	return p.parseMapping(line.Indent)
+}
+
+// parseMapping parses the "key: value" lines at indent
+func (p *yamlParser) parseMapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.Indent < indent || line.Indent == indent && isYAMLSequenceItem(line.Text) {
+			break
+		}
+		if line.Indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.Number)
+		}
+		key, rest, ok, err := splitYAMLKey(line.Text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.Number, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key: value'", line.Number)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", line.Number, key)
+		}
+		p.pos++
+
+		value, err := p.parseValue(rest, line, true)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// parseSequence parses the "- item" lines at indent
+func (p *yamlParser) parseSequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.Indent < indent || line.Indent == indent && !isYAMLSequenceItem(line.Text) {
+			break
+		}
+		if line.Indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.Number)
+		}
+		after := line.Text[1:]
+		rest := strings.TrimLeft(after, " ")
+
+		// "- key: value" and "- - item" start a block indented to the item
+		_, _, isKey, _ := splitYAMLKey(rest)
+		if isKey || isYAMLSequenceItem(rest) {
+			p.lines[p.pos] = yamlLine{Number: line.Number, Indent: line.Indent + 1 + len(after) - len(rest), Text: rest}
+			value, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+		p.pos++
+		value, err := p.parseValue(rest, line, false)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// parseValue parses what follows a key or "-": a scalar on the same line,
+// or else a nested block on the following lines. A mapping's sequence may
+// be at the key's own indentation, as YAML allows
+func (p *yamlParser) parseValue(rest string, line yamlLine, inMapping bool) (any, error) {
+	if rest != "" {
+		value, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.Number, err)
+		}
+		return value, nil
+	}
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		if next.Indent > line.Indent || inMapping && next.Indent == line.Indent && isYAMLSequenceItem(next.Text) {
+			return p.parseBlock()
+		}
+	}
+	return nil, nil
+}
+
+// splitYAMLKey splits "key: value" into the key and the rest of the line.
+// ok is false when the line is a scalar rather than a key
+func splitYAMLKey(text string) (string, string, bool, error) {
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := quotedYAMLEnd(text)
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated quoted string")
+		}
+		after := text[end:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		key, err := parseYAMLScalar(text[:end])
+		if err != nil {
+			return "", "", false, err
+		}
+		return key.(string), strings.TrimSpace(after[1:]), true, nil
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false, nil
+	}
+
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false, nil
+		}
+		i = len(text) - 1
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+}
+
+// quotedYAMLEnd returns the index after the quoted string text starts with
+func quotedYAMLEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseYAMLScalar parses a value on a single line: a quoted or plain
+// string, null, or a flow sequence of those like [a, b]
+func parseYAMLScalar(text string) (any, error) {
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("missing value")
+	case text == "~" || text == "null" || text == "Null" || text == "NULL":
+		return nil, nil
+	case text[0] == '"':
+		if quotedYAMLEnd(text) != len(text) {
+			return nil, fmt.Errorf("unexpected text after quoted string: %s", text)
+		}
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", text)
+		}
+		return s, nil
+	case text[0] == '\'':
+		if quotedYAMLEnd(text) != len(text) {
+			return nil, fmt.Errorf("unexpected text after quoted string: %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text[0] == '[':
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("flow sequences must be on a single line")
+		}
+		return parseYAMLFlowSequence(strings.TrimSpace(text[1 : len(text)-1]))
+	case text == "{}":
+		return map[string]any{}, nil
+	case strings.ContainsRune("{&*!|>@`", rune(text[0])):
+		return nil, fmt.Errorf("unsupported YAML: %s", text)
+	}
+	return text, nil
+}
+
+// parseYAMLFlowSequence parses the items between the brackets of [a, b]
+func parseYAMLFlowSequence(text string) (any, error) {
+	items := []any{}
+	for text != "" {
+		item := text
+		if text[0] == '"' || text[0] == '\'' {
+			end := quotedYAMLEnd(text)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string")
+			}
+			item = text[:end]
+		} else if comma := strings.Index(text, ","); comma >= 0 {
+			item = text[:comma]
+		}
+		text = strings.TrimSpace(text[len(item):])
+		item = strings.TrimSpace(item)
+		if strings.HasPrefix(item, "[") {
+			return nil, fmt.Errorf("nested flow sequences are not supported")
+		}
+		value, err := parseYAMLScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+		if text != "" {
+			if text[0] != ',' {
+				return nil, fmt.Errorf("expected ',' in flow sequence")
+			}
+			text = strings.TrimSpace(text[1:])
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseYAML tests the YAML subset batch definitions are written in
+func TestParseYAML(t *testing.T) {
+	input := `---
+# provisioning
+profiles:
+  work:
+    name: "Jane Doe"   # quoted
+    email: jane@work.com
+    description: Work: main # a colon in a value
+    tags: [client, 'back end']
+    hostAliases:
+      github.com: github.com-work
+  list:
+  - profile: oss
+    name: 'Jane O''Doe'
+    tags:
+    - oss
+  - ~
+`
+	got, err := parseYAML(input)
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+	want := map[string]any{
+		"profiles": map[string]any{
+			"work": map[string]any{
+				"name":        "Jane Doe",
+				"email":       "jane@work.com",
+				"description": "Work: main",
+				"tags":        []any{"client", "back end"},
+				"hostAliases": map[string]any{"github.com": "github.com-work"},
+			},
+			"list": []any{
+				map[string]any{"profile": "oss", "name": "Jane O'Doe", "tags": []any{"oss"}},
+				nil,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML = %#v\nwant %#v", got, want)
+	}
+
+	for _, invalid := range []string{
+		"a: 1\n\tb: 2\n",
+		"a: 1\n   b: 2\n",
+		"a: 1\na: 2\n",
+		"a: &anchor x\n",
+		"a: |\n  text\n",
+		"a: \"open\n",
+		"a: [x, y\n",
+		"a: 1\n---\nb: 2\n",
+	} {
+		if _, err := parseYAML(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+// TestParseBatch tests the JSON and YAML layouts profiles can be given in
+func TestParseBatch(t *testing.T) {
+	inputs := []string{
+		`{"profiles": {"work": {"name": "Jane", "email": "jane@work.com"}}, "settings": {}}`,
+		`{"work": {"name": "Jane", "email": "jane@work.com"}}`,
+		`[{"profile": "work", "name": "Jane", "email": "jane@work.com"}]`,
+		"- profile: work\n  name: Jane\n  email: jane@work.com\n",
+		"\ufeffwork:\n  name: Jane\n  email: jane@work.com\n",
+	}
+	want := []batchEntry{{Position: 1, ProfileName: "work", Fields: map[string]any{"name": "Jane", "email": "jane@work.com"}}}
+	for _, input := range inputs {
+		entries, err := parseBatch([]byte(input))
+		if err != nil || !reflect.DeepEqual(entries, want) {
+			t.Errorf("parseBatch(%q) = %v (%v), want %v", input, entries, err, want)
+		}
+	}
+
+	for _, invalid := range []string{"", "{", `"work"`, `["work"]`, `{"work": "Jane"}`} {
+		if _, err := parseBatch([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+// TestApplyBatchFields tests that fields are validated, lists and maps are
+// replaced and unmentioned fields are kept
+func TestApplyBatchFields(t *testing.T) {
+	existing := Profile{
+		Name:        "Jane",
+		Email:       "jane@work.com",
+		PushRemote:  "fork",
+		Tags:        []string{"old"},
+		HostAliases: map[string]string{"gitlab.com": "gitlab.com-work"},
+	}
+	profile := existing
+	err := applyBatchFields(&profile, map[string]any{
+		"email":       "jane@acme.com",
+		"tags":        []any{"client", "client"},
+		"hostAliases": map[string]any{"github.com": "github.com-work"},
+		"env":         map[string]any{"GOPRIVATE": "github.com/acme/*"},
+		"scope":       nil,
+	})
+	if err != nil {
+		t.Fatalf("applyBatchFields failed: %v", err)
+	}
+	want := Profile{
+		Name:        "Jane",
+		Email:       "jane@acme.com",
+		PushRemote:  "fork",
+		Tags:        []string{"client"},
+		HostAliases: map[string]string{"github.com": "github.com-work"},
+		Env:         map[string]string{"GOPRIVATE": "github.com/acme/*"},
+	}
+	if !reflect.DeepEqual(profile, want) {
+		t.Errorf("Got %+v\nwant %+v", profile, want)
+	}
+	if existing.Tags[0] != "old" || existing.HostAliases["gitlab.com"] == "" {
+		t.Errorf("The existing profile was changed: %+v", existing)
+	}
+
+	for field, value := range map[string]any{
+		"scope":       "everywhere",
+		"email":       "nope",
+		"name":        []any{"Jane"},
+		"tags":        "client",
+		"env":         map[string]any{"NOT VALID": "x"},
+		"hostAliases": map[string]any{"github.com": ""},
+		"tag":         "client",
+		"unknown":     "x",
+	} {
+		profile := Profile{}
+		if err := applyBatchFields(&profile, map[string]any{field: value}); err == nil {
+			t.Errorf("Expected an error for %s = %v", field, value)
+		} else if field == "unknown" && !strings.Contains(err.Error(), "unknown field") {
+			t.Errorf("Unexpected error %v", err)
+		}
+	}
+}
+
+// TestAddProfilePairs tests adding profiles from profile=email pairs, where
+// only all of them failing, or any with Strict, is an error
+func TestAddProfilePairs(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	t.Setenv("GIT_USR_NO_SEED", "1")
+
+	if err := addProfilePairs([]string{"acme=jane@acme.io", "oss=not-an-email"}, "Jane Doe", batchOptions{}); err != nil {
+		t.Errorf("addProfilePairs() error = %v, expected acme to be added", err)
+	}
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := profiles["acme"]; got.Name != "Jane Doe" || got.Email != "jane@acme.io" || len(profiles) != 1 {
+		t.Errorf("profiles = %v, expected only acme", profiles)
+	}
+
+	// An existing profile keeps its name
+	if err := addProfilePairs([]string{"acme=jane@acme.com", "oss=x"}, "", batchOptions{Strict: true}); err == nil {
+		t.Error("addProfilePairs() succeeded with an invalid entry and Strict")
+	}
+	profiles, _ = loadProfiles()
+	if got := profiles["acme"]; got.Name != "Jane Doe" || got.Email != "jane@acme.com" {
+		t.Errorf("acme = %+v, expected the new email under the old name", got)
+	}
+
+	if err := addProfilePairs([]string{"oss=x", "list=jane@acme.io"}, "Jane Doe", batchOptions{}); err == nil {
+		t.Error("addProfilePairs() succeeded with only invalid entries")
+	}
+	if err := addProfilePairs([]string{"oss"}, "Jane Doe", batchOptions{}); err == nil {
+		t.Error("addProfilePairs() accepted a pair without =")
+	}
+}
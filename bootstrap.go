@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The variables bootstrap takes the identity from, preferred first. The
+// GIT_AUTHOR_* fallbacks are what `git usr env` exports
+var (
+	bootstrapNameVars    = []string{"GIT_USR_NAME", "GIT_AUTHOR_NAME"}
+	bootstrapEmailVars   = []string{"GIT_USR_EMAIL", "GIT_AUTHOR_EMAIL"}
+	bootstrapProfileVars = []string{"GIT_USR_PROFILE"}
+)
+
+// bootstrapFileVar names a secrets file to read the variables from, and
+// bootstrapSecretsFile is read without it, where Docker mounts a secret
+// named git-usr
+const (
+	bootstrapFileVar     = "GIT_USR_IDENTITY_FILE"
+	bootstrapSecretsFile = "/run/secrets/git-usr"
+)
+
+// bootstrapDefaultProfile is the profile bootstrap saves the identity as
+// when none is named
+const bootstrapDefaultProfile = "container"
+
+// devcontainerSnippet is the devcontainer.json part that runs bootstrap
+// with the identity of the host
+const devcontainerSnippet = `// In .devcontainer/devcontainer.json, with git-usr installed in the image:
+"containerEnv": {
+  "GIT_USR_NAME": "${localEnv:GIT_USR_NAME}",
+  "GIT_USR_EMAIL": "${localEnv:GIT_USR_EMAIL}"
+},
+"postCreateCommand": "git usr bootstrap --from-env"
+`
+
+// parseEnvFile parses KEY=VALUE lines as written for docker --env-file or
+// a shell, skipping comments and blank lines. An export prefix and quotes
+// around the value are dropped
+func parseEnvFile(data string) map[string]string {
+	vars := map[string]string{}
+	for _, line := range strings.Split(normalizeLF(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars
+}
+
+// lookupBootstrapVar returns the first of names set in the environment or,
+// failing that, in the secrets file
+func lookupBootstrapVar(getenv func(string) string, file map[string]string, names []string) string {
+	for _, name := range names {
+		if value := getenv(name); value != "" {
+			return value
+		}
+	}
+	for _, name := range names {
+		if value := file[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// readBootstrapFile reads the secrets file given by --file, by
+// GIT_USR_IDENTITY_FILE or mounted as a Docker secret, in that order.
+// Only a missing Docker secret isn't an error
+func readBootstrapFile(path string) (map[string]string, error) {
+	if path == "" {
+		path = os.Getenv(bootstrapFileVar)
+	}
+	if path == "" {
+		data, err := os.ReadFile(bootstrapSecretsFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseEnvFile(string(data)), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseEnvFile(string(data)), nil
+}
+
+// saveBootstrapProfile saves the identity as a profile, keeping the other
+// settings of an existing one
+func saveBootstrapProfile(profileName, name, email string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profile := config.Profiles[profileName]
+	profile.Name, profile.Email = name, email
+	config.Profiles[profileName] = profile
+	return saveConfig(config)
+}
+
+// bootstrapFromEnv configures git with the identity from the environment
+// or secrets file, saving it as a profile and switching to it globally
+func bootstrapFromEnv(file, profileName string) error {
+	// A fresh container has no config yet, and placeholders are no use there
+	noSeedFlag = true
+
+	vars, err := readBootstrapFile(file)
+	if err != nil {
+		fmt.Printf("❌ Could not read the identity file: %v\n", err)
+		return err
+	}
+	name := cleanIdentityText(lookupBootstrapVar(os.Getenv, vars, bootstrapNameVars))
+	email := cleanIdentityText(lookupBootstrapVar(os.Getenv, vars, bootstrapEmailVars))
+	if name == "" || email == "" {
+		fmt.Println("❌ No identity found in the environment")
+		fmt.Printf("   Set %s and %s, or mount a file setting them at %s (or pass --file)\n",
+			bootstrapNameVars[0], bootstrapEmailVars[0], bootstrapSecretsFile)
+		return fmt.Errorf("no identity in the environment")
+	}
+	for _, err := range []error{validateName("name", name), validateEmail("email", email)} {
+		if err != nil {
+			fmt.Println("❌ " + err.Error())
+			return err
+		}
+	}
+
+	if profileName == "" {
+		profileName = lookupBootstrapVar(os.Getenv, vars, bootstrapProfileVars)
+	}
+	if profileName == "" {
+		profileName = bootstrapDefaultProfile
+	}
+	if reason := reservedProfileName(profileName); reason != "" {
+		fmt.Printf("❌ Can't name a profile '%s': %s\n", profileName, reason)
+		return fmt.Errorf("reserved profile name: %s", profileName)
+	}
+
+	if err := saveBootstrapProfile(profileName, name, email); err != nil {
+		return err
+	}
+	return switchProfile(profileName, "global")
+}
+
+// runBootstrap handles the bootstrap command
+func runBootstrap(args []string) error {
+	usage := "Usage: git usr bootstrap --from-env [--file <path>] [--profile <name>] | --devcontainer"
+
+	fromEnv, devcontainer := false, false
+	file, profileName := "", ""
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--from-env":
+			fromEnv = true
+		case "--devcontainer":
+			devcontainer = true
+		case "--file", "--profile":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			if arg == "--file" {
+				file = args[i+1]
+			} else {
+				profileName = args[i+1]
+			}
+			i++
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+
+	switch {
+	case devcontainer && !fromEnv && file == "" && profileName == "":
+		fmt.Print(devcontainerSnippet)
+		return nil
+	case fromEnv && !devcontainer:
+		return bootstrapFromEnv(file, profileName)
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("expected --from-env or --devcontainer")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestParseEnvFile tests reading docker --env-file and shell style files
+func TestParseEnvFile(t *testing.T) {
+	data := "# identity\r\nexport GIT_USR_NAME=\"Jane Doe\"\r\n\r\nGIT_USR_EMAIL='jane@acme.io'\nGIT_USR_PROFILE = work\nnot a variable\n"
+	expected := map[string]string{
+		"GIT_USR_NAME":    "Jane Doe",
+		"GIT_USR_EMAIL":   "jane@acme.io",
+		"GIT_USR_PROFILE": "work",
+	}
+	if got := parseEnvFile(data); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseEnvFile() = %v, expected %v", got, expected)
+	}
+}
+
+// TestLookupBootstrapVar tests that the environment wins over the file and
+// each wins in the order of the names
+func TestLookupBootstrapVar(t *testing.T) {
+	env := map[string]string{"GIT_AUTHOR_NAME": "Env Author"}
+	getenv := func(name string) string { return env[name] }
+	file := map[string]string{"GIT_USR_NAME": "File User"}
+
+	if got := lookupBootstrapVar(getenv, file, bootstrapNameVars); got != "Env Author" {
+		t.Errorf("lookupBootstrapVar() = %q, expected the environment's", got)
+	}
+	env["GIT_USR_NAME"] = "Env User"
+	if got := lookupBootstrapVar(getenv, file, bootstrapNameVars); got != "Env User" {
+		t.Errorf("lookupBootstrapVar() = %q, expected GIT_USR_NAME", got)
+	}
+	if got := lookupBootstrapVar(getenv, file, bootstrapEmailVars); got != "" {
+		t.Errorf("lookupBootstrapVar() = %q, expected nothing", got)
+	}
+}
+
+// TestBootstrapFromEnv tests saving and switching to the identity of a
+// secrets file in a home without any config
+func TestBootstrapFromEnv(t *testing.T) {
+	home := setupConfigHome(t)
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(home, "git-usr", "profiles.json"))
+	for _, name := range append(append(append([]string{}, bootstrapNameVars...), bootstrapEmailVars...), bootstrapProfileVars...) {
+		t.Setenv(name, "")
+	}
+	t.Cleanup(func() { noSeedFlag = false })
+
+	file := filepath.Join(home, "identity.env")
+	if err := os.WriteFile(file, []byte("GIT_USR_NAME=Jane Doe\nGIT_USR_EMAIL=jane@acme.io\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := bootstrapFromEnv(file, ""); err != nil {
+		t.Fatalf("bootstrapFromEnv() error = %v", err)
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[bootstrapDefaultProfile].Email != "jane@acme.io" {
+		t.Errorf("profiles = %v, expected only %s", profiles, bootstrapDefaultProfile)
+	}
+	if email := gitConfigValue("", "global", "user.email"); email != "jane@acme.io" {
+		t.Errorf("global user.email = %q", email)
+	}
+
+	if err := bootstrapFromEnv(filepath.Join(home, "missing.env"), ""); err == nil {
+		t.Error("bootstrapFromEnv() accepted a missing file")
+	}
+}
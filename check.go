@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// httpsForm returns a remote URL as https://host/path, so SSH and HTTPS
+// remotes of the same repository compare equal
+func httpsForm(remote string) string {
+	remote = strings.TrimSuffix(remote, ".git")
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return remote
+		}
+		return "https://" + strings.ToLower(u.Hostname()) + u.Path
+	}
+	// scp-like git@host:path
+	if _, rest, ok := strings.Cut(remote, "@"); ok {
+		remote = rest
+	}
+	host, path, _ := strings.Cut(remote, ":")
+	return "https://" + strings.ToLower(host) + "/" + strings.TrimPrefix(path, "/")
+}
+
+// remoteMatchesProfile reports whether a remote URL belongs to a profile
+// through its host aliases, URL rewrites or credentials for a path. Host-
+// only credentials are shared by too many repositories to tell
+func remoteMatchesProfile(profile Profile, remote string) bool {
+	for _, alias := range profile.HostAliases {
+		if hostOf(remote) == strings.ToLower(alias) {
+			return true
+		}
+	}
+	for prefix, base := range profile.URLRewrites {
+		if strings.HasPrefix(remote, prefix) || strings.HasPrefix(remote, base) {
+			return true
+		}
+	}
+
+	https := httpsForm(remote)
+	for _, credentials := range []map[string]string{profile.CredentialUsernames, profile.CredentialHelpers} {
+		for address := range credentials {
+			u, err := url.Parse(address)
+			if err != nil || strings.Trim(u.Path, "/") == "" {
+				continue
+			}
+			if strings.HasPrefix(https+"/", httpsForm(address)+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expectedProfiles returns the profiles the remotes of a repository belong
+// to, in alphabetical order
+func expectedProfiles(profiles map[string]Profile, remotes []string) []string {
+	var expected []string
+	for _, name := range sortedProfileNames(profiles) {
+		for _, remote := range remotes {
+			if remoteMatchesProfile(profiles[name], remote) {
+				expected = append(expected, name)
+				break
+			}
+		}
+	}
+	return expected
+}
+
+// getRemoteURLs returns the URLs of all remotes of the repository in dir
+// (the current directory when empty)
+func getRemoteURLs(dir string) []string {
+	config, err := gitConfig.List(dir, "")
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for _, key := range sortedKeys(config) {
+		if strings.HasPrefix(key, "remote.") && strings.HasSuffix(key, ".url") {
+			urls = append(urls, config[key]...)
+		}
+	}
+	return urls
+}
+
+// identityWarning returns a one-line warning about the identity of a
+// repository, or "" when it is the expected one
+func identityWarning(info promptInfo) string {
+	// The repository's own policy comes before anything else
+	policy := projectPolicy{EmailDomains: info.Domains}
+	if (info.State == promptCheckOK || info.State == promptCheckMismatch) && !policy.allowsEmail(info.Email) {
+		fix := "switch to a profile at " + formatDomains(info.Domains)
+		if info.Suggested != "" {
+			fix = "run 'git usr apply " + info.Suggested + "'"
+		}
+		return fmt.Sprintf("⚠️  git-usr: %s is not at %s as %s requires (%s)", info.Email, formatDomains(info.Domains), info.PolicyFile, fix)
+	}
+	if info.BrokenRule != "" && (info.State == promptCheckOK || info.State == promptCheckMismatch) {
+		fix := "no profile satisfies it"
+		if info.RuleProfile != "" {
+			fix = "run 'git usr switch --auto'"
+		}
+		return fmt.Sprintf("⚠️  git-usr: %s breaks %s (%s)", info.Email, info.BrokenRule, fix)
+	}
+
+	// A pin says which profile is expected better than the remotes
+	if info.Pinned != "" && (info.State == promptCheckOK || info.State == promptCheckMismatch || info.State == promptCheckNoIdentity) {
+		switch {
+		case info.PinMissing:
+			return fmt.Sprintf("⚠️  git-usr: this repository is pinned to '%s', which is not one of your profiles (run 'git usr add %s')", info.Pinned, info.Pinned)
+		case info.State == promptCheckNoIdentity:
+			return fmt.Sprintf("⚠️  git-usr: no identity configured; this repository is pinned to '%s' (run 'git usr apply %s')", info.Pinned, info.Pinned)
+		case info.State == promptCheckMismatch:
+			return fmt.Sprintf("⚠️  git-usr: this repository is pinned to '%s' but commits as %s (run 'git usr apply %s')", info.Pinned, formatAddress(info.Name, info.Email), info.Pinned)
+		}
+		return ""
+	}
+
+	switch info.State {
+	case promptCheckNoIdentity:
+		return "⚠️  git-usr: no identity configured (run 'git usr <profile>')"
+	case promptCheckMismatch:
+		if len(info.Expected) > 0 {
+			return fmt.Sprintf("⚠️  git-usr: %s does not belong to any profile; the remote belongs to '%s' (run 'git usr %s')", info.Email, strings.Join(info.Expected, "' or '"), info.Expected[0])
+		}
+		return fmt.Sprintf("⚠️  git-usr: %s does not belong to any profile (run 'git usr <profile>')", info.Email)
+	case promptCheckOK:
+		if len(info.Expected) == 0 {
+			return ""
+		}
+		for _, name := range info.Expected {
+			if name == info.Profile {
+				return ""
+			}
+		}
+		return fmt.Sprintf("⚠️  git-usr: committing as '%s' but the remote belongs to '%s' (run 'git usr %s')", info.Profile, strings.Join(info.Expected, "' or '"), info.Expected[0])
+	}
+	return ""
+}
+
+// runCheck handles the check command, meant for chpwd and precmd hooks:
+// it prints a warning on stderr when the identity is not the expected one
+// and always succeeds, so it never disturbs the prompt. With --pre-commit
+// it is the guard instead, for the pre-commit framework
+func runCheck(args []string) error {
+	// Run from cd and pre-commit hooks, which must never wait for input
+	promptsDisabled = true
+	// The framework may pass the staged files too, which don't matter
+	if len(args) > 0 && args[0] == "--pre-commit" {
+		return guardCommit()
+	}
+
+	useCache := true
+	for _, arg := range args {
+		switch arg {
+		case "--no-cache":
+			useCache = false
+		default:
+			fmt.Println("Usage: git usr check [--no-cache] | --pre-commit")
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+
+	info, err := getPromptInfo(useCache)
+	if err != nil {
+		return nil
+	}
+	if warning := identityWarning(info); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	return nil
+}
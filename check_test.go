@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestHTTPSForm tests normalizing remote URLs
+func TestHTTPSForm(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:acme/app.git":       "https://github.com/acme/app",
+		"ssh://git@GitHub.com/acme/app.git": "https://github.com/acme/app",
+		"https://github.com/acme/app":       "https://github.com/acme/app",
+		"github.com:acme/app":               "https://github.com/acme/app",
+	}
+	for remote, expected := range tests {
+		if got := httpsForm(remote); got != expected {
+			t.Errorf("httpsForm(%q) = %q, expected %q", remote, got, expected)
+		}
+	}
+}
+
+// TestExpectedProfiles tests matching remotes to profiles
+func TestExpectedProfiles(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {HostAliases: map[string]string{"github.com": "github.com-work"}},
+		"client":   {URLRewrites: map[string]string{"https://github.com/client/": "git@github.com-client:client/"}},
+		"gitlab":   {CredentialUsernames: map[string]string{"https://gitlab.company.com/team": "jdoe"}},
+		"personal": {CredentialUsernames: map[string]string{"https://github.com": "me"}},
+	}
+
+	tests := map[string]string{
+		"git@github.com-work:acme/app.git":          "work",
+		"https://github.com/client/app.git":         "client",
+		"git@github.com-client:client/app.git":      "client",
+		"git@gitlab.company.com:team/app.git":       "gitlab",
+		"https://gitlab.company.com/teamwork/x":     "",
+		"git@github.com:someone/app.git":            "",
+		"https://gitlab.company.com/team/sub/x.git": "gitlab",
+	}
+	for remote, expected := range tests {
+		if got := strings.Join(expectedProfiles(profiles, []string{remote}), ","); got != expected {
+			t.Errorf("expectedProfiles(%q) = %q, expected %q", remote, got, expected)
+		}
+	}
+}
+
+// TestIdentityWarning tests when check warns
+func TestIdentityWarning(t *testing.T) {
+	tests := []struct {
+		info    promptInfo
+		warning string
+	}{
+		{promptInfo{State: promptCheckOK, Profile: "work"}, ""},
+		{promptInfo{State: promptCheckOK, Profile: "work", Expected: []string{"client", "work"}}, ""},
+		{promptInfo{State: promptCheckOK, Profile: "personal", Expected: []string{"work"}}, "committing as 'personal' but the remote belongs to 'work'"},
+		{promptInfo{State: promptCheckMismatch, Email: "z@z"}, "z@z does not belong to any profile"},
+		{promptInfo{State: promptCheckNoIdentity}, "no identity configured"},
+		{promptInfo{State: promptCheckNotRepo}, ""},
+		{promptInfo{State: promptCheckOK, Profile: "work", Pinned: "work", Expected: []string{"client"}}, ""},
+		{promptInfo{State: promptCheckMismatch, Profile: "home", Name: "J", Email: "j@home.dev", Pinned: "work"}, "pinned to 'work' but commits as J <j@home.dev>"},
+		{promptInfo{State: promptCheckNoIdentity, Pinned: "work"}, "pinned to 'work' (run 'git usr apply work')"},
+		{promptInfo{State: promptCheckOK, Profile: "home", Pinned: "work", PinMissing: true}, "which is not one of your profiles"},
+		{promptInfo{State: promptCheckNotRepo, Pinned: "work"}, ""},
+		{promptInfo{State: promptCheckMismatch, Profile: "home", Email: "j@home.dev", Pinned: "work", Domains: []string{"corp.com"}, PolicyFile: ".gitusr", Suggested: "work"}, "j@home.dev is not at @corp.com as .gitusr requires (run 'git usr apply work')"},
+		{promptInfo{State: promptCheckOK, Profile: "home", Email: "j@home.dev", Domains: []string{"corp.com", "corp.io"}, PolicyFile: ".git-usr.yaml"}, "(switch to a profile at @corp.com or @corp.io)"},
+		{promptInfo{State: promptCheckOK, Profile: "work", Email: "j@eu.corp.com", Domains: []string{"corp.com"}, PolicyFile: ".gitusr"}, ""},
+	}
+	for _, test := range tests {
+		got := identityWarning(test.info)
+		if test.warning == "" && got != "" || !strings.Contains(got, test.warning) {
+			t.Errorf("identityWarning(%+v) = %q, expected %q", test.info, got, test.warning)
+		}
+	}
+}
+
+// TestPreCommitHooksFile tests that the hooks offered to the pre-commit
+// framework run the check entry point
+func TestPreCommitHooksFile(t *testing.T) {
+	data, err := os.ReadFile(".pre-commit-hooks.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+	hooks, ok := doc.([]any)
+	if !ok || len(hooks) == 0 {
+		t.Fatalf("Expected a list of hooks, got %v", doc)
+	}
+	for _, item := range hooks {
+		hook, _ := item.(map[string]any)
+		if hook["entry"] != "git-usr check --pre-commit" || hook["pass_filenames"] != "false" {
+			t.Errorf("Hook %v doesn't run 'git-usr check --pre-commit' once per commit", hook["id"])
+		}
+	}
+}
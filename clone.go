@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// scpURLPattern matches scp-like SSH remotes such as git@github.com:org/repo.git
+var scpURLPattern = regexp.MustCompile(`^([^@/:]+@)?([^/:]+):(.*)$`)
+
+// rewriteRemoteHost replaces the host of an SSH remote URL with the
+// profile's alias for it, so the matching ~/.ssh/config key is used.
+// HTTPS and local URLs are returned unchanged.
+func rewriteRemoteHost(url string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return url
+	}
+
+	if strings.HasPrefix(url, "ssh://") {
+		rest := strings.TrimPrefix(url, "ssh://")
+		userHost, path, _ := strings.Cut(rest, "/")
+		user, host, hasUser := strings.Cut(userHost, "@")
+		if !hasUser {
+			host, user = user, ""
+		}
+		hostname, port, hasPort := strings.Cut(host, ":")
+		alias, ok := aliases[hostname]
+		if !ok {
+			return url
+		}
+		if hasPort {
+			alias += ":" + port
+		}
+		if hasUser {
+			alias = user + "@" + alias
+		}
+		return "ssh://" + alias + "/" + path
+	}
+
+	if strings.Contains(url, "://") {
+		return url
+	}
+
+	m := scpURLPattern.FindStringSubmatch(url)
+	if m == nil {
+		return url
+	}
+	alias, ok := aliases[m[2]]
+	if !ok {
+		return url
+	}
+	return m[1] + alias + ":" + m[3]
+}
+
+// cloneTargetDir returns the directory git clone picks for url when none
+// is given: the last path component without a trailing ".git"
+func cloneTargetDir(url string) string {
+	name := strings.TrimRight(url, "/")
+	name = strings.TrimSuffix(name, "/.git")
+	name = strings.TrimSuffix(name, ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// cloneWithProfile clones a repository with the profile's identity already
+// in its local config, so not even the first commit uses the wrong one
+func cloneWithProfile(url, dir, profileName string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if profileName == "" {
+		profileName = config.Settings.DefaultProfile
+	}
+	if profileName == "" {
+		fmt.Println("❌ No profile given and no default profile set!")
+		fmt.Println("Usage: git usr clone <url> [dir] --profile <profile>")
+		return fmt.Errorf("profile required")
+	}
+
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		fmt.Println("\nAvailable profiles:", getProfileNames(config.Profiles))
+		return errProfileNotFound
+	}
+
+	cloneURL := rewriteRemoteHost(url, profile.HostAliases)
+	if cloneURL != url {
+		fmt.Printf("🔑 Using SSH host alias: %s\n", cloneURL)
+	}
+
+	args := []string{"clone",
+		"-c", "user.name=" + profile.Name,
+		"-c", "user.email=" + profile.Email,
+		cloneURL}
+	if dir != "" {
+		args = append(args, dir)
+	}
+
+	cmd := exec.Command("git", args...)
+	attachTerminal(cmd)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &ExitError{Code: exitErr.ExitCode(), Err: err}
+		}
+		return err
+	}
+
+	if dir == "" {
+		dir = cloneTargetDir(url)
+	}
+	if err := recordManagedKeys(dir, "local", profileName, identityKeys(profile)); err != nil {
+		fmt.Printf("⚠️  Managed state not updated: %v\n", err)
+	}
+
+	fmt.Printf("✅ Cloned with '%s' profile\n", profileName)
+	fmt.Printf("   Name:  %s\n", profile.Name)
+	fmt.Printf("   Email: %s\n", profile.Email)
+	return nil
+}
+
+// runClone handles the clone command
+func runClone(args []string) error {
+	url, dir, profileName := "", "", ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--profile requires a value")
+			}
+			profileName = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			profileName = strings.TrimPrefix(args[i], "--profile=")
+		case url == "":
+			url = args[i]
+		case dir == "":
+			dir = args[i]
+		default:
+			fmt.Println("Usage: git usr clone <url> [dir] [--profile <profile>]")
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+
+	if url == "" {
+		fmt.Println("❌ Repository URL required!")
+		fmt.Println("Usage: git usr clone <url> [dir] [--profile <profile>]")
+		return fmt.Errorf("url required")
+	}
+
+	return cloneWithProfile(url, dir, profileName)
+}
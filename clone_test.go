@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+// TestRewriteRemoteHost tests SSH host alias rewriting of remote URLs
+func TestRewriteRemoteHost(t *testing.T) {
+	aliases := map[string]string{"github.com": "github.com-work"}
+
+	cases := map[string]string{
+		"git@github.com:acme/app.git":          "git@github.com-work:acme/app.git",
+		"ssh://git@github.com/acme/app.git":    "ssh://git@github.com-work/acme/app.git",
+		"ssh://git@github.com:22/acme/app.git": "ssh://git@github.com-work:22/acme/app.git",
+		"git@gitlab.com:acme/app.git":          "git@gitlab.com:acme/app.git",
+		"https://github.com/acme/app.git":      "https://github.com/acme/app.git",
+	}
+	for url, expected := range cases {
+		if got := rewriteRemoteHost(url, aliases); got != expected {
+			t.Errorf("rewriteRemoteHost(%s) = %s, expected %s", url, got, expected)
+		}
+	}
+}
+
+// TestCloneTargetDir tests the directory name derived from clone URLs
+func TestCloneTargetDir(t *testing.T) {
+	cases := map[string]string{
+		"git@github.com:acme/app.git":          "app",
+		"https://github.com/acme/app":          "app",
+		"https://github.com/acme/app.git/":     "app",
+		"/srv/git/app/.git":                    "app",
+		"ssh://git@github.com:22/acme/app.git": "app",
+	}
+	for url, expected := range cases {
+		if got := cloneTargetDir(url); got != expected {
+			t.Errorf("cloneTargetDir(%s) = %s, expected %s", url, got, expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import "fmt"
+
+// Coauthor is a teammate who can be paired with but is never switched to
+type Coauthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// pairRoster returns everyone who can be paired with: the profiles and
+// the co-authors. A profile wins over a co-author of the same name
+func pairRoster(config *Config) map[string]Profile {
+	roster := map[string]Profile{}
+	for alias, coauthor := range config.Coauthors {
+		roster[alias] = Profile{Name: coauthor.Name, Email: coauthor.Email}
+	}
+	for name, profile := range config.Profiles {
+		roster[name] = profile
+	}
+	return roster
+}
+
+// loadPairRoster loads the config and returns its pair roster
+func loadPairRoster() (map[string]Profile, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return pairRoster(config), nil
+}
+
+// addCoauthor adds or updates a co-author
+func addCoauthor(alias, name, email string) error {
+	name, email = cleanIdentityText(name), cleanIdentityText(email)
+	if name == "" || email == "" {
+		fmt.Println("❌ Name and email are required!")
+		return fmt.Errorf("name and email required")
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Profiles[alias]; exists {
+		fmt.Printf("❌ '%s' is already a profile; pick another name for the co-author\n", alias)
+		return fmt.Errorf("name taken by a profile")
+	}
+
+	if config.Coauthors == nil {
+		config.Coauthors = map[string]Coauthor{}
+	}
+	config.Coauthors[alias] = Coauthor{Name: name, Email: email}
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Co-author '%s' saved!\n", alias)
+	fmt.Printf("   %s\n", formatAddress(name, email))
+	fmt.Printf("\nUse: git usr pair %s\n", alias)
+	return nil
+}
+
+// listCoauthors prints the co-authors
+func listCoauthors() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(config.Coauthors) == 0 {
+		fmt.Println("No co-authors yet")
+		fmt.Println("\nUse: git usr coauthor add <alias> <name> <email>")
+		return nil
+	}
+
+	fmt.Println("\n👥 Co-authors:")
+	fmt.Println("--------------------------------------------------")
+	for _, alias := range sortedKeys(config.Coauthors) {
+		coauthor := config.Coauthors[alias]
+		fmt.Printf("   %-12s %s\n", alias, formatAddress(coauthor.Name, coauthor.Email))
+	}
+	fmt.Println()
+	return nil
+}
+
+// removeCoauthor removes a co-author
+func removeCoauthor(alias string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Coauthors[alias]; !exists {
+		fmt.Printf("❌ Co-author '%s' not found!\n", alias)
+		return fmt.Errorf("co-author not found: %s", alias)
+	}
+
+	delete(config.Coauthors, alias)
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Co-author '%s' removed!\n", alias)
+	return nil
+}
+
+// runCoauthor handles the coauthor command
+func runCoauthor(args []string) error {
+	usage := "Usage: git usr coauthor add <alias> <name> <email> | list | remove <alias>"
+
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "list":
+		return listCoauthors()
+	case len(args) == 4 && args[0] == "add":
+		return addCoauthor(args[1], args[2], args[3])
+	case len(args) == 2 && args[0] == "remove":
+		return removeCoauthor(args[1])
+	}
+
+	fmt.Println(usage)
+	return fmt.Errorf("invalid coauthor command")
+}
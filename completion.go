@@ -0,0 +1,649 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Markers around the lines git-usr adds to shell rc files, so installing
+// again replaces them instead of adding another copy
+const (
+	rcBlockBegin = "# BEGIN git-usr completion (added by 'git usr completion install')"
+	rcBlockEnd   = "# END git-usr completion"
+)
+
+// completionShells are the shells completion scripts are generated for
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionScript returns the completion script for shell, printing the
+// supported shells when it has none
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return getBashCompletion(), nil
+	case "zsh":
+		return getZshCompletion(), nil
+	case "fish":
+		return getFishCompletion(), nil
+	case "powershell":
+		return getPowershellCompletion(), nil
+	}
+	fmt.Printf("❌ Unsupported shell: %s. Supported: %s\n", shell, strings.Join(completionShells, ", "))
+	return "", fmt.Errorf("unsupported shell: %s", shell)
+}
+
+// completionItem is a completion candidate and what it does
+type completionItem struct {
+	Value       string
+	Description string
+}
+
+// completionCommands are the commands offered for the first word
+var completionCommands = []completionItem{
+	{"list", "List all profiles"},
+	{"find", "Search profiles"},
+	{"dedupe", "Merge profiles that share an email"},
+	{"current", "Show current git config"},
+	{"diff", "Compare the global and local identity"},
+	{"setup", "Set up your profiles step by step"},
+	{"bootstrap", "Set the identity from the environment"},
+	{"add", "Add or update a profile"},
+	{"remove", "Remove a profile"},
+	{"profile", "Show or change profile fields"},
+	{"clone", "Clone with a profile applied"},
+	{"import", "Create profiles from a CSV file"},
+	{"lint", "Check profiles for common problems"},
+	{"prune", "Archive or delete unused profiles"},
+	{"stats", "Show how often and where profiles are used"},
+	{"doctor", "Check signing certificates and tools"},
+	{"report", "Write an HTML identity report"},
+	{"config", "Show or change settings"},
+	{"interop", "Keep the WSL and Windows identities in step"},
+	{"default", "Show or set the default profile"},
+	{"apply", "Apply a profile to this repository and its submodules"},
+	{"pin", "Pin this repository to a profile"},
+	{"rules", "Manage identity rules"},
+	{"switch", "Switch to a profile, or pick it by rules with --auto"},
+	{"init", "Apply the default profile to this repository"},
+	{"hooks", "Install, remove or inspect git-usr hooks"},
+	{"plugins", "List the plugins run on add, remove and switch"},
+	{"env", "Print environment for a profile"},
+	{"exec", "Run a command with a profile environment"},
+	{"direnv", "Export a profile identity from .envrc"},
+	{"managed", "Show git config values written by git-usr"},
+	{"verify", "Check a profile email against a forge account"},
+	{"keys", "Set up signing keys for a profile"},
+	{"signers", "Sync allowed_signers from profiles"},
+	{"mailmap", "Generate a .mailmap from profiles"},
+	{"pair", "Add Co-authored-by trailers for teammates"},
+	{"coauthor", "Manage co-authors to pair with"},
+	{"watch", "Apply profiles to new clones automatically"},
+	{"push-to", "Push to the current profile push remote"},
+	{"team", "Import signed team profiles"},
+	{"serve", "Answer JSON API requests"},
+	{"menubar", "Print an xbar/SwiftBar menu of profiles"},
+	{"prompt", "Shell prompt integration"},
+	{"check", "Warn about an unexpected identity"},
+	{"session", "Summarize identity switches on shell exit"},
+	{"assert", "Fail unless the identity is the expected one"},
+	{"tag", "Create a signed tag as a profile"},
+	{"repair", "Salvage a config file that fails to parse"},
+	{"backup", "List or restore config backups"},
+	{"lock", "Encrypt the config file"},
+	{"unlock", "Decrypt the config file"},
+	{"version", "Show version information"},
+	{"help", "Show help"},
+	{"completion", "Generate or install completion scripts"},
+}
+
+// completionValues turns values into candidates with one description
+func completionValues(description string, values ...string) []completionItem {
+	items := make([]completionItem, 0, len(values))
+	for _, value := range values {
+		items = append(items, completionItem{value, description})
+	}
+	return items
+}
+
+// completionCandidates returns the candidates for the last of words, the
+// arguments typed after git-usr. Filtering by what was typed is left to
+// the caller
+func completionCandidates(words []string, config *Config) []completionItem {
+	profiles := sortedProfileNames(config.Profiles)
+	profileItems := completionValues("profile", profiles...)
+
+	if len(words) <= 1 {
+		items := append([]completionItem{}, completionCommands...)
+		for _, name := range profiles {
+			items = append(items, completionItem{name, fmt.Sprintf("Switch to %s profile", name)})
+		}
+		return append(items, completionItem{"--global", "Apply globally"})
+	}
+
+	command, args := words[0], words[1:len(words)-1]
+	previous := ""
+	if len(args) > 0 {
+		previous = args[len(args)-1]
+	}
+	if previous == "--profile" {
+		return profileItems
+	}
+
+	switch command {
+	case "completion":
+		if len(args) == 0 {
+			return append(completionValues("shell", completionShells...), completionItem{"install", "Install completion for your shell"})
+		}
+		if len(args) == 1 && args[0] == "install" {
+			return completionValues("shell", completionShells...)
+		}
+	case "remove":
+		if len(args) == 0 {
+			return append(profileItems, completionItem{"--all", "Remove every profile"})
+		}
+		items := completionValues("option", "--force", "--strict", "--skip-unsafe")
+		for _, item := range profileItems {
+			if !containsString(args, item.Value) {
+				items = append(items, item)
+			}
+		}
+		return items
+	case "env":
+		switch {
+		case len(args) == 0:
+			return profileItems
+		case previous == "--shell":
+			return completionValues("shell", "sh", "fish", "powershell")
+		case previous == "--format":
+			return completionValues("format", "shell", "dch")
+		case previous == "--set" || previous == "--unset":
+			return nil
+		}
+		return completionValues("option", "--shell", "--format", "--set", "--unset")
+	case "interop":
+		if len(args) == 0 {
+			return completionValues("action", "status", "sync")
+		}
+		if args[0] == "sync" && len(args) == 1 {
+			return completionValues("option", "--from-windows")
+		}
+	case "bootstrap":
+		switch {
+		case previous == "--file":
+			return nil
+		case previous == "--profile":
+			return profileItems
+		case len(args) == 0:
+			return completionValues("option", "--from-env", "--devcontainer")
+		case containsString(args, "--from-env"):
+			return completionValues("option", "--file", "--profile")
+		}
+	case "direnv":
+		switch {
+		case len(args) == 0:
+			return append(profileItems, completionItem{"--remove", "Remove the identity from .envrc"})
+		case previous == "--ssh-key":
+			return nil
+		case !containsString(args, "--remove") && !containsString(args, "--ssh-key"):
+			return completionValues("option", "--ssh-key")
+		}
+	case "exec", "verify", "tag", "stats":
+		if len(args) == 0 {
+			return profileItems
+		}
+	case "default":
+		if len(args) == 0 {
+			return append(profileItems, completionItem{"--unset", "Clear the default profile"})
+		}
+	case "doctor":
+		return profileItems
+	case "pair":
+		return append(append(profileItems, completionValues("co-author", sortedKeys(config.Coauthors)...)...), completionItem{"--stop", "Stop pairing"})
+	case "coauthor":
+		if len(args) == 0 {
+			return completionValues("action", "add", "list", "remove")
+		}
+		if len(args) == 1 && args[0] == "remove" {
+			return completionValues("co-author", sortedKeys(config.Coauthors)...)
+		}
+	case "profile":
+		switch {
+		case len(args) == 0:
+			return completionValues("action", "show", "get", "set", "unset")
+		case len(args) == 1:
+			return profileItems
+		case len(args) == 2 && args[0] != "show":
+			return completionValues("field", sortedKeys(profileFields)...)
+		}
+	case "config":
+		if len(args) == 0 {
+			return completionValues("action", "list", "get", "set")
+		}
+		if len(args) == 1 && (args[0] == "get" || args[0] == "set") {
+			return completionValues("setting", sortedKeys(settingKeys)...)
+		}
+	case "keys":
+		switch {
+		case len(args) == 0:
+			return completionValues("action", "setup")
+		case len(args) == 1:
+			return profileItems
+		default:
+			return completionValues("option", "--gpg", "--ssh-signing", "--x509", "--gitsign", "--key", "--generate", "--no-passphrase", "--program", "--connector")
+		}
+	case "menubar":
+		if len(args) == 0 {
+			return completionValues("option", "--dir")
+		}
+	case "serve":
+		if len(args) == 0 {
+			return completionValues("option", "--stdio", "--unix-socket")
+		}
+	case "watch":
+		if len(args) == 0 {
+			return completionValues("action", "run", "start", "stop", "status", "add", "remove", "list")
+		}
+		if len(args) == 2 && args[0] == "add" {
+			return profileItems
+		}
+	case "prompt":
+		if previous == "--format" {
+			return completionValues("style", sortedKeys(promptStyles)...)
+		}
+		return completionValues("option", "--format", "--no-cache", "--check")
+	case "init":
+		return completionValues("option", "--force", "--quiet", "--install-template", "--install-hooks", "--uninstall-hooks")
+	case "clone":
+		return completionValues("option", "--profile")
+	case "apply":
+		if len(args) == 0 {
+			return profileItems
+		}
+		return completionValues("option", "--submodules")
+	case "pin":
+		if len(args) == 0 {
+			return append(profileItems, completionValues("option", "--unset", "--tracked")...)
+		}
+		return completionValues("option", "--tracked")
+	case "hooks":
+		if len(args) == 0 {
+			return completionValues("action", "install", "uninstall", "status", "on-switch")
+		}
+		if args[0] == "on-switch" {
+			if len(args) == 1 {
+				return completionValues("option", "--remove")
+			}
+			return nil
+		}
+		if args[0] != "status" {
+			return completionValues("option", "--global")
+		}
+	case "plugins":
+		if len(args) == 0 {
+			return completionValues("action", "list")
+		}
+	case "rules":
+		switch {
+		case len(args) == 0:
+			return completionValues("action", "list", "add", "remove", "test")
+		case args[0] == "add":
+			return completionValues("option", "--remote", "--dir", "--branch", "--profile", "--domain")
+		case args[0] == "test":
+			return completionValues("option", "--remote", "--dir", "--branch", "--email")
+		}
+	case "switch":
+		if len(args) == 0 {
+			return append(profileItems, completionItem{"--auto", "Pick the profile by rules"})
+		}
+		return completionValues("option", "--global", "--local", "--exact")
+	case "list", "find":
+		switch previous {
+		case "--sort":
+			return completionValues("order", listSortKeys...)
+		case "--tag":
+			tags := map[string]bool{}
+			for _, profile := range config.Profiles {
+				for _, tag := range profile.Tags {
+					tags[tag] = true
+				}
+			}
+			return completionValues("tag", sortedKeys(tags)...)
+		}
+		if command == "find" {
+			return completionValues("option", "--sort", "--tag", "--quiet")
+		}
+		return completionValues("option", "--sort", "--filter", "--tag", "--quiet")
+	case "dedupe":
+		if previous == "--keep" {
+			return profileItems
+		}
+		return completionValues("option", "--keep", "--dry-run")
+	case "current":
+		return completionValues("option", "--name-only", "--email-only", "--profile-only")
+	case "session":
+		if len(args) == 0 {
+			return completionValues("action", "hook", "report")
+		}
+		if len(args) == 1 && args[0] == "hook" {
+			return completionValues("shell", sortedKeys(sessionHooks)...)
+		}
+		if args[0] == "report" {
+			return completionValues("option", "--since")
+		}
+	case "check":
+		return completionValues("option", "--no-cache", "--pre-commit")
+	case "assert":
+		return completionValues("option", "--profile", "--domain")
+	case "add":
+		switch previous {
+		case "--batch", "--profile", "--name":
+			return nil
+		}
+		if len(args) > 0 {
+			return completionValues("option", "--from-current", "--global", "--verify-domain", "--force", "--batch", "--dry-run", "--profile", "--name", "--strict")
+		}
+	case "report":
+		return completionValues("option", "--html")
+	case "lint":
+		return completionValues("option", "--unused-months")
+	case "prune":
+		if previous == "--restore" {
+			return completionValues("archived", sortedProfileNames(config.Archived)...)
+		}
+		switch previous {
+		case "--to":
+			return profileItems
+		case "--scan", "--unused-months":
+			return nil
+		}
+		if containsString(args, "--scan") {
+			return completionValues("option", "--to")
+		}
+		return completionValues("option", "--unused-months", "--archived", "--restore", "--scan")
+	case "signers":
+		if len(args) == 0 {
+			return completionValues("action", "sync")
+		}
+	case "mailmap":
+		if len(args) == 0 {
+			return completionValues("action", "generate")
+		}
+		switch previous {
+		case "--canonical":
+			return profileItems
+		case "--output", "-o":
+			return nil
+		}
+		return completionValues("option", "--canonical", "--output", "--append", "--dry-run")
+	case "managed":
+		return completionValues("action", "list")
+	case "team":
+		return completionValues("action", "pull")
+	case "backup":
+		if len(args) == 0 {
+			return completionValues("action", "list", "restore")
+		}
+		if args[0] == "restore" && len(args) == 1 {
+			var items []completionItem
+			if configPath, err := getConfigPath(); err == nil {
+				backups, _ := listBackups(configPath)
+				for i := len(backups) - 1; i >= 0; i-- {
+					items = append(items, completionItem{backups[i].ID, "backup taken " + backups[i].Time.Format("2006-01-02 15:04:05")})
+				}
+			}
+			return items
+		}
+	case "repair":
+		return completionValues("option", "--from-backup", "--dry-run")
+	case "import":
+		return completionValues("option", "--csv", "--update", "--dry-run")
+	default:
+		if _, exists := config.Profiles[command]; exists {
+			return completionValues("option", "--global", "--local", "--exact")
+		}
+	}
+	return nil
+}
+
+// completionConfig loads the config for completion. A locked config is
+// only read when GIT_USR_PASSPHRASE is set, so pressing tab never prompts
+func completionConfig() *Config {
+	empty := &Config{Profiles: map[string]Profile{}}
+	configPath, err := getConfigPath()
+	if err != nil {
+		return empty
+	}
+	if data, err := os.ReadFile(configPath); err == nil && os.Getenv("GIT_USR_PASSPHRASE") == "" {
+		if _, locked := parseEncryptedConfig(data); locked {
+			return empty
+		}
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return empty
+	}
+	return config
+}
+
+// runComplete handles the hidden __complete command: it prints the
+// candidates for the last argument as "value<TAB>description" lines
+func runComplete(words []string) error {
+	current := ""
+	if len(words) > 0 {
+		// The PowerShell script passes a space for an empty word
+		current = strings.TrimSpace(words[len(words)-1])
+	}
+
+	for _, item := range completionCandidates(words, completionConfig()) {
+		if strings.HasPrefix(item.Value, current) {
+			fmt.Printf("%s\t%s\n", item.Value, item.Description)
+		}
+	}
+	return nil
+}
+
+// detectShell returns the completion shell of the user's login shell
+func detectShell(getenv func(string) string) string {
+	switch name := strings.TrimSuffix(filepath.Base(getenv("SHELL")), ".exe"); name {
+	case "bash", "zsh", "fish":
+		return name
+	case "pwsh", "powershell":
+		return "powershell"
+	}
+	if runtime.GOOS == "windows" || getenv("PSModulePath") != "" {
+		return "powershell"
+	}
+	return ""
+}
+
+// completionTarget is where the completion script of a shell goes, and
+// the rc file lines that load it when the shell doesn't on its own
+type completionTarget struct {
+	Script  string
+	RCFile  string
+	RCLines []string
+}
+
+// completionTargetFor returns the install locations for shell below home
+func completionTargetFor(shell, home string, getenv func(string) string) completionTarget {
+	dataHome := getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(dataHome) {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	configHome := getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	switch shell {
+	case "bash":
+		// bash-completion loads this directory on demand; sourcing it from
+		// .bashrc also covers systems without bash-completion
+		script := filepath.Join(dataHome, "bash-completion", "completions", "git-usr")
+		return completionTarget{
+			Script:  script,
+			RCFile:  filepath.Join(home, ".bashrc"),
+			RCLines: []string{fmt.Sprintf("[ -f %q ] && . %q", script, script)},
+		}
+	case "zsh":
+		zdotdir := getenv("ZDOTDIR")
+		if zdotdir == "" {
+			zdotdir = home
+		}
+		dir := filepath.Join(home, ".zsh", "completions")
+		return completionTarget{
+			Script: filepath.Join(dir, "_git-usr"),
+			RCFile: filepath.Join(zdotdir, ".zshrc"),
+			RCLines: []string{
+				fmt.Sprintf("fpath=(%q $fpath)", dir),
+				// Lists usr among git's commands for "git <tab>"
+				"zstyle ':completion:*:*:git:*' user-commands usr:'switch git identities'",
+				"autoload -U compinit && compinit",
+			},
+		}
+	case "fish":
+		// fish autoloads its completions directory
+		return completionTarget{Script: filepath.Join(configHome, "fish", "completions", "git-usr.fish")}
+	case "powershell":
+		script := filepath.Join(configHome, "git-usr", "completion.ps1")
+		if runtime.GOOS == "windows" {
+			script = filepath.Join(home, "Documents", "PowerShell", "git-usr-completion.ps1")
+		}
+		return completionTarget{
+			Script:  script,
+			RCFile:  powershellProfilePath(home, configHome),
+			RCLines: []string{fmt.Sprintf(". '%s'", strings.ReplaceAll(script, "'", "''"))},
+		}
+	}
+	return completionTarget{}
+}
+
+// powershellProfilePath returns the current user's PowerShell profile,
+// asking PowerShell itself when it is installed
+func powershellProfilePath(home, configHome string) string {
+	for _, program := range []string{"pwsh", "powershell"} {
+		out, err := exec.Command(program, "-NoProfile", "-NonInteractive", "-Command", "$PROFILE").Output()
+		if err == nil && strings.TrimSpace(string(out)) != "" {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(configHome, "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+// renderRCBlock replaces the git-usr block of an rc file with lines, or
+// appends it when there is none. Everything else is kept as it is
+func renderRCBlock(existing string, lines []string) string {
+	block := strings.Join(append(append([]string{rcBlockBegin}, lines...), rcBlockEnd), "\n")
+
+	start := strings.Index(existing, rcBlockBegin)
+	if start >= 0 {
+		if end := strings.Index(existing[start:], rcBlockEnd); end >= 0 {
+			return existing[:start] + block + existing[start+end+len(rcBlockEnd):]
+		}
+	}
+
+	if existing != "" && !strings.HasSuffix(existing, "\n") {
+		existing += "\n"
+	}
+	if existing != "" {
+		existing += "\n"
+	}
+	return existing + block + "\n"
+}
+
+// updateRCFile writes the git-usr block into an rc file, following
+// symlinks so dotfile managers keep their link. It reports whether the
+// file changed
+func updateRCFile(path string, lines []string) (bool, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	updated := renderRCBlock(string(data), lines)
+	if updated == string(data) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	return true, writeFileAtomic(path, []byte(updated), 0644, false)
+}
+
+// installCompletion writes the completion script for shell (detected when
+// empty) and makes the shell load it
+func installCompletion(shell string) error {
+	if shell == "" {
+		if shell = detectShell(os.Getenv); shell == "" {
+			fmt.Println("❌ Could not detect your shell")
+			fmt.Printf("Usage: git usr completion install [%s]\n", strings.Join(completionShells, "|"))
+			return fmt.Errorf("unknown shell")
+		}
+	}
+
+	script, err := completionScript(shell)
+	if err != nil {
+		return err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	target := completionTargetFor(shell, home, os.Getenv)
+
+	if err := os.MkdirAll(filepath.Dir(target.Script), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(target.Script, []byte(script+"\n"), 0644, false); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Installed %s completion in %s\n", shell, target.Script)
+
+	if target.RCFile != "" {
+		changed, err := updateRCFile(target.RCFile, target.RCLines)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("   Updated %s to load it\n", target.RCFile)
+		} else {
+			fmt.Printf("   %s already loads it\n", target.RCFile)
+		}
+	}
+
+	fmt.Println("   Restart your shell to use it")
+	return nil
+}
+
+// runCompletion handles the completion command
+func runCompletion(args []string) error {
+	usage := fmt.Sprintf("Usage: git usr completion [%s] | install [<shell>]", strings.Join(completionShells, "|"))
+
+	switch {
+	case len(args) == 1 && args[0] != "install":
+		script, err := completionScript(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(script)
+		return nil
+	case len(args) == 1:
+		return installCompletion("")
+	case len(args) == 2 && args[0] == "install":
+		return installCompletion(args[1])
+	}
+
+	fmt.Println("❌ Shell type required!")
+	fmt.Println(usage)
+	return fmt.Errorf("shell required")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDetectShell tests picking the completion shell from $SHELL
+func TestDetectShell(t *testing.T) {
+	tests := map[string]string{
+		"/bin/bash":              "bash",
+		"/usr/local/bin/zsh":     "zsh",
+		"/opt/homebrew/bin/fish": "fish",
+		"/usr/bin/pwsh":          "powershell",
+	}
+	for shell, expected := range tests {
+		getenv := func(key string) string {
+			if key == "SHELL" {
+				return shell
+			}
+			return ""
+		}
+		if got := detectShell(getenv); got != expected {
+			t.Errorf("detectShell(%s) = %q, expected %q", shell, got, expected)
+		}
+	}
+}
+
+// TestCompletionTargetFor tests where completion scripts are installed
+func TestCompletionTargetFor(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	bash := completionTargetFor("bash", "/home/me", getenv)
+	if bash.Script != filepath.Join("/home/me", ".local", "share", "bash-completion", "completions", "git-usr") || bash.RCFile != filepath.Join("/home/me", ".bashrc") {
+		t.Errorf("bash target = %+v", bash)
+	}
+
+	env["ZDOTDIR"] = "/home/me/.config/zsh"
+	zsh := completionTargetFor("zsh", "/home/me", getenv)
+	if zsh.RCFile != filepath.Join("/home/me/.config/zsh", ".zshrc") || !strings.Contains(zsh.RCLines[0], "fpath=") {
+		t.Errorf("zsh target = %+v", zsh)
+	}
+
+	env["XDG_CONFIG_HOME"] = "/xdg"
+	fish := completionTargetFor("fish", "/home/me", getenv)
+	if fish.Script != filepath.Join("/xdg", "fish", "completions", "git-usr.fish") || fish.RCFile != "" {
+		t.Errorf("fish target = %+v", fish)
+	}
+}
+
+// TestRenderRCBlock tests that installing twice keeps a single block
+func TestRenderRCBlock(t *testing.T) {
+	original := "export PATH=$PATH:~/bin"
+	once := renderRCBlock(original, []string{"source a"})
+	expected := original + "\n\n" + rcBlockBegin + "\nsource a\n" + rcBlockEnd + "\n"
+	if once != expected {
+		t.Fatalf("renderRCBlock = %q, expected %q", once, expected)
+	}
+
+	if twice := renderRCBlock(once, []string{"source a"}); twice != once {
+		t.Errorf("second install changed the file: %q", twice)
+	}
+
+	updated := renderRCBlock(once+"alias g=git\n", []string{"source b"})
+	if strings.Count(updated, rcBlockBegin) != 1 || !strings.Contains(updated, "source b") || strings.Contains(updated, "source a") || !strings.HasSuffix(updated, "alias g=git\n") {
+		t.Errorf("renderRCBlock replace = %q", updated)
+	}
+
+	if got := renderRCBlock("", []string{"source a"}); got != rcBlockBegin+"\nsource a\n"+rcBlockEnd+"\n" {
+		t.Errorf("renderRCBlock on empty file = %q", got)
+	}
+}
+
+// TestUpdateRCFileFollowsSymlinks tests that a symlinked rc file stays a
+// symlink
+func TestUpdateRCFileFollowsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles-bashrc")
+	link := filepath.Join(dir, ".bashrc")
+	os.WriteFile(target, []byte("# mine\n"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	if changed, err := updateRCFile(link, []string{"source a"}); err != nil || !changed {
+		t.Fatalf("updateRCFile = %v, %v", changed, err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("rc file symlink was replaced")
+	}
+	data, _ := os.ReadFile(target)
+	if !strings.Contains(string(data), "source a") {
+		t.Errorf("symlink target not updated: %q", data)
+	}
+	if changed, _ := updateRCFile(link, []string{"source a"}); changed {
+		t.Error("second update reported a change")
+	}
+}
+
+// completionValuesOf returns the values of candidates
+func completionValuesOf(items []completionItem) []string {
+	var values []string
+	for _, item := range items {
+		values = append(values, item.Value)
+	}
+	return values
+}
+
+// TestCompletionCandidates tests what is offered at each position
+func TestCompletionCandidates(t *testing.T) {
+	config := &Config{
+		Profiles:  map[string]Profile{"work": {}, "personal": {}},
+		Coauthors: map[string]Coauthor{"alice": {}},
+	}
+	tests := []struct {
+		words    []string
+		expected string
+	}{
+		{[]string{"remove", ""}, "personal work --all"},
+		{[]string{"remove", "work", ""}, "--force --strict --skip-unsafe personal"},
+		{[]string{"pair", ""}, "personal work alice --stop"},
+		{[]string{"completion", "install", ""}, "bash zsh fish powershell"},
+		{[]string{"clone", "--profile", ""}, "personal work"},
+		{[]string{"work", ""}, "--global --local --exact"},
+		{[]string{"watch", "add", "~/src", ""}, "personal work"},
+	}
+	for _, test := range tests {
+		got := strings.Join(completionValuesOf(completionCandidates(test.words, config)), " ")
+		if got != test.expected {
+			t.Errorf("completionCandidates(%q) = %q, expected %q", test.words, got, test.expected)
+		}
+	}
+
+	first := completionValuesOf(completionCandidates([]string{""}, config))
+	if !containsString(first, "list") || !containsString(first, "work") || !containsString(first, "--global") {
+		t.Errorf("first word candidates = %q", first)
+	}
+}
+
+// TestCompletionUnsupportedShell tests that an unknown shell is reported
+// along with the supported ones
+func TestCompletionUnsupportedShell(t *testing.T) {
+	var err error
+	out := captureStdout(t, func() { _, err = completionScript("tcsh") })
+	if err == nil || strings.HasPrefix(err.Error(), "❌") {
+		t.Errorf("Expected a plain error, got %v", err)
+	}
+	if !strings.Contains(out, "❌ Unsupported shell: tcsh. Supported: bash, zsh, fish, powershell") {
+		t.Errorf("Expected the supported shells to be printed, got %q", out)
+	}
+}
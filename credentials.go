@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// normalizeCredentialURL turns a host into the https URL git matches
+// credential.<url>.* against, keeping URLs with a scheme as they are
+func normalizeCredentialURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSpace(url), "/")
+	if !strings.Contains(url, "://") {
+		url = "https://" + url
+	}
+	return url
+}
+
+// parseCredentialEntry parses URL=VALUE, as used by the credentialUsername
+// and credentialHelper fields. The value may itself contain '='.
+func parseCredentialEntry(field, entry string) (string, string, error) {
+	url, value, ok := strings.Cut(entry, "=")
+	if !ok || strings.TrimSpace(url) == "" || strings.TrimSpace(value) == "" {
+		return "", "", fmt.Errorf("%s must be URL=VALUE", field)
+	}
+	if strings.ContainsAny(strings.TrimSpace(url), " \t!") {
+		return "", "", fmt.Errorf("%s must be URL=VALUE, '%s' is not a URL", field, url)
+	}
+	return normalizeCredentialURL(url), strings.TrimSpace(value), nil
+}
+
+// credentialField builds a URL=VALUE profile field for credential config,
+// normalizing the URL on set and unset
+func credentialField(name, description, noun string, field func(p *Profile) *map[string]string) profileField {
+	f := mapField(description, noun, field, func(value string) (string, string, error) {
+		return parseCredentialEntry(name, value)
+	})
+	unset := f.unset
+	f.unset = func(p *Profile, value string) error {
+		if value != "" {
+			value = normalizeCredentialURL(value)
+		}
+		return unset(p, value)
+	}
+	return f
+}
+
+// credentialKeys returns the credential.<url>.* values of a profile. A
+// helper is preceded by an empty value, which makes git drop the helpers
+// inherited from other config files so only the profile's one is asked.
+func credentialKeys(profile Profile) []ManagedKey {
+	var keys []ManagedKey
+	for _, url := range sortedKeys(profile.CredentialUsernames) {
+		keys = append(keys, ManagedKey{Key: "credential." + url + ".username", Value: profile.CredentialUsernames[url]})
+	}
+	for _, url := range sortedKeys(profile.CredentialHelpers) {
+		keys = append(keys,
+			ManagedKey{Key: "credential." + url + ".helper", Value: ""},
+			ManagedKey{Key: "credential." + url + ".helper", Value: profile.CredentialHelpers[url]})
+	}
+	return keys
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+// TestParseCredentialEntry tests parsing URL=VALUE credential entries
+func TestParseCredentialEntry(t *testing.T) {
+	tests := []struct {
+		entry string
+		url   string
+		value string
+		ok    bool
+	}{
+		{"github.com=jdoe", "https://github.com", "jdoe", true},
+		{"https://gitlab.example.com/=jdoe", "https://gitlab.example.com", "jdoe", true},
+		{"github.com=store --file=~/.git-credentials-work", "https://github.com", "store --file=~/.git-credentials-work", true},
+		{"github.com", "", "", false},
+		{"=jdoe", "", "", false},
+		{"!f() { echo password=x; }; f", "", "", false},
+	}
+
+	for _, tt := range tests {
+		url, value, err := parseCredentialEntry("credentialHelper", tt.entry)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseCredentialEntry(%q) error = %v", tt.entry, err)
+			continue
+		}
+		if url != tt.url || value != tt.value {
+			t.Errorf("parseCredentialEntry(%q) = %q, %q", tt.entry, url, value)
+		}
+	}
+}
+
+// TestCredentialKeys tests that helpers reset inherited helpers first
+func TestCredentialKeys(t *testing.T) {
+	keys := credentialKeys(Profile{
+		CredentialUsernames: map[string]string{"https://github.com": "jdoe"},
+		CredentialHelpers:   map[string]string{"https://github.com": "!gh auth git-credential"},
+	})
+
+	expected := []ManagedKey{
+		{Key: "credential.https://github.com.username", Value: "jdoe"},
+		{Key: "credential.https://github.com.helper", Value: ""},
+		{Key: "credential.https://github.com.helper", Value: "!gh auth git-credential"},
+	}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %v", len(expected), keys)
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("Key %d = %v, expected %v", i, keys[i], expected[i])
+		}
+	}
+}
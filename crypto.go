@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	encryptionKDF        = "pbkdf2-sha256"
+	encryptionCipher     = "aes-256-gcm"
+	encryptionIterations = 600000
+)
+
+// EncryptedConfig is the on-disk envelope of a locked config file
+type EncryptedConfig struct {
+	Encrypted  int    `json:"encrypted"`
+	KDF        string `json:"kdf"`
+	Cipher     string `json:"cipher"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// configPassphrase caches the passphrase of a locked config for the
+// lifetime of the process, so saves re-encrypt without prompting again
+var configPassphrase string
+
+// configLocked is set when the config file was read from an encrypted envelope
+var configLocked bool
+
+// pbkdf2 derives a key from a password using PBKDF2 with HMAC-SHA256
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
+
+// newGCM builds an AES-GCM cipher from a passphrase and salt
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key := pbkdf2([]byte(passphrase), salt, iterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptConfig encrypts config data with a passphrase into an envelope
+func encryptConfig(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, salt, encryptionIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	envelope := EncryptedConfig{
+		Encrypted:  1,
+		KDF:        encryptionKDF,
+		Cipher:     encryptionCipher,
+		Iterations: encryptionIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, data, nil)),
+	}
+
+	return json.MarshalIndent(envelope, "", "  ")
+}
+
+// decryptConfig decrypts an envelope produced by encryptConfig
+func decryptConfig(envelope EncryptedConfig, passphrase string) ([]byte, error) {
+	if envelope.KDF != encryptionKDF || envelope.Cipher != encryptionCipher {
+		return nil, fmt.Errorf("unsupported encryption: %s/%s", envelope.KDF, envelope.Cipher)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(envelope.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	gcm, err := newGCM(passphrase, salt, envelope.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length")
+	}
+
+	data, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted config")
+	}
+
+	return data, nil
+}
+
+// parseEncryptedConfig reports whether data is an encrypted envelope
+func parseEncryptedConfig(data []byte) (EncryptedConfig, bool) {
+	var envelope EncryptedConfig
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return envelope, false
+	}
+	return envelope, envelope.Encrypted > 0 && envelope.Ciphertext != ""
+}
+
+// readPassphrase reads a passphrase from GIT_USR_PASSPHRASE or the terminal
+func readPassphrase(prompt string) (string, error) {
+	if passphrase := os.Getenv("GIT_USR_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if promptsDisabled {
+		return "", fmt.Errorf("the config is locked; set GIT_USR_PASSPHRASE")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+
+	// Hide input on unix terminals; Windows consoles echo regardless
+	if runtime.GOOS != "windows" {
+		stty := exec.Command("stty", "-echo")
+		stty.Stdin = os.Stdin
+		if stty.Run() == nil {
+			defer func() {
+				restore := exec.Command("stty", "echo")
+				restore.Stdin = os.Stdin
+				restore.Run()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// decodeConfigData returns plaintext config data, decrypting it if locked
+func decodeConfigData(data []byte) ([]byte, error) {
+	envelope, ok := parseEncryptedConfig(data)
+	if !ok {
+		configLocked = false
+		return data, nil
+	}
+
+	if configPassphrase == "" {
+		passphrase, err := readPassphrase("Passphrase: ")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errConfigLocked, err)
+		}
+		configPassphrase = passphrase
+	}
+
+	plain, err := decryptConfig(envelope, configPassphrase)
+	if err != nil {
+		configPassphrase = ""
+		fmt.Fprintf(os.Stderr, "❌ Could not unlock config: %v\n", err)
+		return nil, fmt.Errorf("%w: %w", errConfigLocked, err)
+	}
+
+	configLocked = true
+	return plain, nil
+}
+
+// encodeConfigData returns config data as it should be written to disk,
+// re-encrypting it when the config is locked
+func encodeConfigData(data []byte) ([]byte, error) {
+	if !configLocked {
+		return data, nil
+	}
+	return encryptConfig(data, configPassphrase)
+}
+
+// lockConfig encrypts the config file with a passphrase
+func lockConfig() error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if configLocked {
+		fmt.Println("🔒 Config is already locked")
+		return nil
+	}
+
+	passphrase, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		fmt.Println("❌ Passphrase cannot be empty!")
+		return fmt.Errorf("empty passphrase")
+	}
+	if os.Getenv("GIT_USR_PASSPHRASE") == "" {
+		confirm, err := readPassphrase("Confirm passphrase: ")
+		if err != nil {
+			return err
+		}
+		if confirm != passphrase {
+			fmt.Println("❌ Passphrases do not match!")
+			return fmt.Errorf("passphrase mismatch")
+		}
+	}
+
+	configPassphrase = passphrase
+	configLocked = true
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := encryptBackups(configPath, passphrase); err != nil {
+		return fmt.Errorf("config locked, but encrypting its backups failed: %w", err)
+	}
+
+	fmt.Println("🔒 Config locked")
+	fmt.Println("   Set GIT_USR_PASSPHRASE to avoid being prompted")
+	return nil
+}
+
+// unlockConfig decrypts the config file back to plain JSON
+func unlockConfig() error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if !configLocked {
+		fmt.Println("🔓 Config is not locked")
+		return nil
+	}
+
+	configLocked = false
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Println("🔓 Config unlocked")
+	return nil
+}
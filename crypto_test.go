@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestPBKDF2 tests key derivation against a known PBKDF2-HMAC-SHA256 vector
+func TestPBKDF2(t *testing.T) {
+	key := pbkdf2([]byte("password"), []byte("salt"), 1, 32)
+	expected := "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"
+
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("Unexpected key: %x", key)
+	}
+}
+
+// TestEncryptDecryptConfig tests the encrypted config round trip
+func TestEncryptDecryptConfig(t *testing.T) {
+	plain := []byte(`{"work":{"name":"John","email":"john@work.com"}}`)
+
+	data, err := encryptConfig(plain, "secret")
+	if err != nil {
+		t.Fatalf("encryptConfig failed: %v", err)
+	}
+
+	envelope, ok := parseEncryptedConfig(data)
+	if !ok {
+		t.Fatal("Encrypted data not recognized as envelope")
+	}
+
+	decrypted, err := decryptConfig(envelope, "secret")
+	if err != nil {
+		t.Fatalf("decryptConfig failed: %v", err)
+	}
+	if string(decrypted) != string(plain) {
+		t.Error("Config data mismatch after decryption")
+	}
+
+	if _, err := decryptConfig(envelope, "wrong"); err == nil {
+		t.Error("Expected error for wrong passphrase")
+	}
+}
+
+// TestParseEncryptedConfigPlain tests that plain profiles are not treated as encrypted
+func TestParseEncryptedConfigPlain(t *testing.T) {
+	if _, ok := parseEncryptedConfig([]byte(`{"work":{"name":"John","email":"john@work.com"}}`)); ok {
+		t.Error("Plain config detected as encrypted")
+	}
+}
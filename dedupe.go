@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// duplicateGroup is a set of profiles with the same email
+type duplicateGroup struct {
+	Email    string
+	Profiles []string
+}
+
+// duplicateGroups returns the profiles sharing an email, ignoring case,
+// ordered by email and then profile name
+func duplicateGroups(profiles map[string]Profile) []duplicateGroup {
+	byEmail := map[string][]string{}
+	for _, name := range sortedProfileNames(profiles) {
+		email := strings.ToLower(cleanIdentityText(profiles[name].Email))
+		if email != "" {
+			byEmail[email] = append(byEmail[email], name)
+		}
+	}
+	var groups []duplicateGroup
+	for _, email := range sortedKeys(byEmail) {
+		if names := byEmail[email]; len(names) > 1 {
+			groups = append(groups, duplicateGroup{Email: email, Profiles: names})
+		}
+	}
+	return groups
+}
+
+// suggestedKeeper returns the profile of a group to keep: the one switched
+// to most recently, or the first by name
+func suggestedKeeper(group duplicateGroup, state *ManagedState) string {
+	names := append([]string{}, group.Profiles...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return state.LastUsed[names[i]].After(state.LastUsed[names[j]])
+	})
+	return names[0]
+}
+
+// mergeProfile fills in what keep leaves unset from other: single values,
+// the signing key and committer as a whole, and missing tags and map keys
+func mergeProfile(keep, other Profile) Profile {
+	for _, field := range []struct{ keep, other *string }{
+		{&keep.Description, &other.Description},
+		{&keep.Scope, &other.Scope},
+		{&keep.PushRemote, &other.PushRemote},
+		{&keep.CommitTemplate, &other.CommitTemplate},
+	} {
+		if *field.keep == "" {
+			*field.keep = *field.other
+		}
+	}
+	if keep.SigningKey == "" {
+		keep.SigningKey, keep.SigningFormat, keep.X509Program = other.SigningKey, other.SigningFormat, other.X509Program
+	}
+	if !hasCommitter(keep) {
+		keep.CommitterName, keep.CommitterEmail = other.CommitterName, other.CommitterEmail
+	}
+	for _, tag := range other.Tags {
+		if !hasTag(keep, tag) {
+			keep.Tags = append(keep.Tags, tag)
+		}
+	}
+	for _, trailer := range other.Trailers {
+		if !containsString(keep.Trailers, trailer) {
+			keep.Trailers = append(keep.Trailers, trailer)
+		}
+	}
+	keep.Env = mergeStringMap(keep.Env, other.Env)
+	keep.HostAliases = mergeStringMap(keep.HostAliases, other.HostAliases)
+	keep.URLRewrites = mergeStringMap(keep.URLRewrites, other.URLRewrites)
+	keep.GitsignOptions = mergeStringMap(keep.GitsignOptions, other.GitsignOptions)
+	keep.CredentialUsernames = mergeStringMap(keep.CredentialUsernames, other.CredentialUsernames)
+	keep.CredentialHelpers = mergeStringMap(keep.CredentialHelpers, other.CredentialHelpers)
+	keep.ForgeAccounts = mergeStringMap(keep.ForgeAccounts, other.ForgeAccounts)
+	keep.Aliases = mergeStringMap(keep.Aliases, other.Aliases)
+	return keep
+}
+
+// mergeStringMap adds the keys of other that keep doesn't have
+func mergeStringMap(keep, other map[string]string) map[string]string {
+	for key, value := range other {
+		if _, exists := keep[key]; exists {
+			continue
+		}
+		if keep == nil {
+			keep = map[string]string{}
+		}
+		keep[key] = value
+	}
+	return keep
+}
+
+// renameProfileReferences points the config's references to a profile at
+// another: the default profile, watched directories and rules
+func renameProfileReferences(config *Config, from, to string) []string {
+	var notes []string
+	if config.Settings.DefaultProfile == from {
+		config.Settings.DefaultProfile = to
+		notes = append(notes, fmt.Sprintf("default profile is now '%s'", to))
+	}
+	for _, dir := range sortedKeys(config.Watch) {
+		if config.Watch[dir] == from {
+			config.Watch[dir] = to
+			notes = append(notes, fmt.Sprintf("%s now uses '%s'", dir, to))
+		}
+	}
+	for i := range config.Rules {
+		if config.Rules[i].Profile == from {
+			config.Rules[i].Profile = to
+			notes = append(notes, fmt.Sprintf("rule %d now uses '%s'", i+1, to))
+		}
+	}
+	return notes
+}
+
+// renameStateProfile moves the managed keys, usage and history of a
+// profile to another
+func renameStateProfile(state *ManagedState, from, to string) {
+	for i := range state.Keys {
+		if state.Keys[i].Profile == from {
+			state.Keys[i].Profile = to
+		}
+	}
+	for i := range state.History {
+		if state.History[i].Profile == from {
+			state.History[i].Profile = to
+		}
+	}
+	if used, ok := state.LastUsed[from]; ok {
+		if used.After(state.LastUsed[to]) {
+			state.LastUsed[to] = used
+		}
+		delete(state.LastUsed, from)
+	}
+	if usage, ok := state.Usage[from]; ok {
+		merged := state.Usage[to]
+		merged.Switches += usage.Switches
+		merged.Global += usage.Global
+		for repo, count := range usage.Repos {
+			if merged.Repos == nil {
+				merged.Repos = map[string]int{}
+			}
+			merged.Repos[repo] += count
+		}
+		state.Usage[to] = merged
+		delete(state.Usage, from)
+	}
+}
+
+// staleNameRepos returns the repositories a profile set user.name in to a
+// value other than name
+func staleNameRepos(state *ManagedState, profileName, name string) []string {
+	var repos []string
+	for _, key := range state.Keys {
+		if key.Profile == profileName && key.Key == "user.name" && key.Scope == "local" && key.Value != name {
+			repos = append(repos, key.Repo)
+		}
+	}
+	return repos
+}
+
+// askKeeper asks which profile of a group to keep; ok is false to stop,
+// and keep is "" to skip the group
+func askKeeper(reader *bufio.Reader, group duplicateGroup, suggested string) (keep string, ok bool) {
+	for {
+		fmt.Printf("   Keep which profile? [%s] (s to skip, q to quit) ", suggested)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", false
+		}
+		switch answer := strings.TrimSpace(line); answer {
+		case "":
+			return suggested, true
+		case "s":
+			return "", true
+		case "q":
+			return "", false
+		default:
+			if containsString(group.Profiles, answer) {
+				return answer, true
+			}
+			fmt.Printf("   '%s' isn't one of %s\n", answer, strings.Join(group.Profiles, ", "))
+		}
+	}
+}
+
+// dedupeProfiles merges the profiles sharing an email into one of them,
+// the one in keep for its group or, on a terminal, the one picked. A dry
+// run shows the merges, into the suggested profile for groups without one
+// in keep
+func dedupeProfiles(keep []string, dryRun bool) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	for _, name := range keep {
+		if _, exists := config.Profiles[name]; !exists {
+			fmt.Printf("❌ Profile '%s' not found!\n", name)
+			return errProfileNotFound
+		}
+	}
+
+	groups := duplicateGroups(config.Profiles)
+	if len(groups) == 0 {
+		fmt.Println("✅ No two profiles share an email")
+		return nil
+	}
+
+	interactive := len(keep) == 0 && !dryRun && isInteractive()
+	reader := bufio.NewReader(os.Stdin)
+	merges := map[string]string{}
+	for _, group := range groups {
+		fmt.Printf("\n🔁 %d profiles use %s:\n", len(group.Profiles), group.Email)
+		for _, name := range group.Profiles {
+			profile := config.Profiles[name]
+			fmt.Printf("   %s: %s\n", name, formatAddress(profile.Name, profile.Email))
+		}
+
+		keeper := ""
+		for _, name := range keep {
+			if containsString(group.Profiles, name) {
+				keeper = name
+			}
+		}
+		if keeper == "" && dryRun {
+			keeper = suggestedKeeper(group, state)
+		}
+		if keeper == "" && interactive {
+			var ok bool
+			if keeper, ok = askKeeper(reader, group, suggestedKeeper(group, state)); !ok {
+				break
+			}
+		}
+		if keeper == "" {
+			continue
+		}
+		for _, name := range group.Profiles {
+			if name != keeper {
+				merges[name] = keeper
+			}
+		}
+	}
+
+	if dryRun || len(keep) == 0 && !interactive {
+		fmt.Println()
+		for _, from := range sortedKeys(merges) {
+			fmt.Printf("Would merge '%s' into '%s'\n", from, merges[from])
+		}
+		if len(merges) == 0 {
+			fmt.Printf("%d group(s) of duplicates. Run 'git usr dedupe' from a terminal, or add --keep <profile> for each group, to merge them.\n", len(groups))
+		}
+		return nil
+	}
+	if len(merges) == 0 {
+		fmt.Println(tr("Nothing changed"))
+		return nil
+	}
+
+	var notes []string
+	for _, from := range sortedKeys(merges) {
+		to := merges[from]
+		config.Profiles[to] = mergeProfile(config.Profiles[to], config.Profiles[from])
+		delete(config.Profiles, from)
+		notes = append(notes, fmt.Sprintf("✅ Merged '%s' into '%s'", from, to))
+		for _, note := range renameProfileReferences(config, from, to) {
+			notes = append(notes, "   "+note)
+		}
+		for _, repo := range staleNameRepos(state, from, config.Profiles[to].Name) {
+			notes = append(notes, fmt.Sprintf("   %s keeps the name of '%s'; run 'git usr -C %s %s' to update it", repo, from, repo, to))
+		}
+	}
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+	if err := updateState(func(state *ManagedState) error {
+		for from, to := range merges {
+			renameStateProfile(state, from, to)
+		}
+		return nil
+	}); err != nil {
+		fmt.Printf("⚠️  Managed state not updated: %v\n", err)
+	}
+
+	fmt.Println()
+	for _, note := range notes {
+		fmt.Println(note)
+	}
+	return nil
+}
+
+// runDedupe handles the dedupe command
+func runDedupe(args []string) error {
+	usage := "Usage: git usr dedupe [--keep <profile>]... [--dry-run]"
+	var keep []string
+	dryRun := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--dry-run":
+			dryRun = true
+		case arg == "--keep":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--keep requires a profile")
+			}
+			keep = append(keep, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--keep="):
+			keep = append(keep, strings.TrimPrefix(arg, "--keep="))
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+	return dedupeProfiles(keep, dryRun)
+}
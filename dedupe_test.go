@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDuplicateGroups tests grouping profiles by email, ignoring case
+func TestDuplicateGroups(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":   {Name: "Jane Doe", Email: "jane@acme.io"},
+		"acme":   {Name: "Jane D.", Email: "Jane@Acme.io"},
+		"home":   {Name: "Jane Doe", Email: "jane@home.dev"},
+		"client": {Name: "Jane Doe", Email: "jane@acme.io"},
+	}
+	expected := []duplicateGroup{{Email: "jane@acme.io", Profiles: []string{"acme", "client", "work"}}}
+	if got := duplicateGroups(profiles); !reflect.DeepEqual(got, expected) {
+		t.Errorf("duplicateGroups() = %v, expected %v", got, expected)
+	}
+
+	state := &ManagedState{LastUsed: map[string]time.Time{"work": time.Now()}}
+	if got := suggestedKeeper(expected[0], state); got != "work" {
+		t.Errorf("suggestedKeeper() = %q, expected the profile used last", got)
+	}
+	if got := suggestedKeeper(expected[0], &ManagedState{}); got != "acme" {
+		t.Errorf("suggestedKeeper() = %q, expected the first by name", got)
+	}
+}
+
+// TestMergeProfile tests filling in what the kept profile leaves unset
+func TestMergeProfile(t *testing.T) {
+	keep := Profile{Name: "Jane Doe", Email: "jane@acme.io", Tags: []string{"client"}, Env: map[string]string{"A": "1"}}
+	other := Profile{
+		Name: "Jane D.", Email: "jane@acme.io", Description: "Acme",
+		Tags: []string{"Client", "oss"}, Env: map[string]string{"A": "2", "B": "3"},
+		SigningKey: "~/.ssh/id.pub", SigningFormat: "ssh",
+	}
+	expected := Profile{
+		Name: "Jane Doe", Email: "jane@acme.io", Description: "Acme",
+		Tags: []string{"client", "oss"}, Env: map[string]string{"A": "1", "B": "3"},
+		SigningKey: "~/.ssh/id.pub", SigningFormat: "ssh",
+	}
+	if got := mergeProfile(keep, other); !reflect.DeepEqual(got, expected) {
+		t.Errorf("mergeProfile() = %+v, expected %+v", got, expected)
+	}
+}
+
+// TestRenameProfileReferences tests pointing the default profile, watched
+// directories, rules and state at the kept profile
+func TestRenameProfileReferences(t *testing.T) {
+	config := &Config{
+		Settings: Settings{DefaultProfile: "acme"},
+		Watch:    map[string]string{"~/src/acme": "acme", "~/src": ""},
+		Rules:    []Rule{{Remote: "github.com/acme/*", Profile: "acme"}, {Dir: "~/oss/", Profile: "oss"}},
+	}
+	if notes := renameProfileReferences(config, "acme", "work"); len(notes) != 3 {
+		t.Errorf("renameProfileReferences() notes = %q, expected 3", notes)
+	}
+	if config.Settings.DefaultProfile != "work" || config.Watch["~/src/acme"] != "work" || config.Rules[0].Profile != "work" || config.Rules[1].Profile != "oss" {
+		t.Errorf("config after renaming = %+v", config)
+	}
+
+	now := time.Now()
+	state := &ManagedState{
+		Keys:     []ManagedKey{{Scope: "local", Repo: "/r", Key: "user.name", Value: "Jane D.", Profile: "acme"}},
+		LastUsed: map[string]time.Time{"acme": now, "work": now.Add(-time.Hour)},
+		Usage:    map[string]ProfileUsage{"acme": {Switches: 2, Repos: map[string]int{"/r": 2}}, "work": {Switches: 1}},
+		History:  []SwitchRecord{{Profile: "acme"}},
+	}
+	if repos := staleNameRepos(state, "acme", "Jane Doe"); !reflect.DeepEqual(repos, []string{"/r"}) {
+		t.Errorf("staleNameRepos() = %v, expected /r", repos)
+	}
+	renameStateProfile(state, "acme", "work")
+	if state.Keys[0].Profile != "work" || state.History[0].Profile != "work" || !state.LastUsed["work"].Equal(now) || state.Usage["work"].Switches != 3 || state.Usage["work"].Repos["/r"] != 2 {
+		t.Errorf("state after renaming = %+v", state)
+	}
+	if _, ok := state.Usage["acme"]; ok {
+		t.Error("usage of the merged profile is left behind")
+	}
+}
+
+// TestDedupeProfilesKeep tests merging without a terminal through --keep
+func TestDedupeProfilesKeep(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	config := &Config{Profiles: map[string]Profile{
+		"work": {Name: "Jane Doe", Email: "jane@acme.io"},
+		"acme": {Name: "Jane Doe", Email: "jane@acme.io", Description: "Acme"},
+		"home": {Name: "Jane Doe", Email: "jane@home.dev"},
+	}}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dedupeProfiles(nil, false); err != nil {
+		t.Fatalf("dedupeProfiles() error = %v", err)
+	}
+	if profiles, _ := loadProfiles(); len(profiles) != 3 {
+		t.Errorf("profiles merged without a terminal or --keep: %v", sortedProfileNames(profiles))
+	}
+
+	if err := dedupeProfiles([]string{"work"}, false); err != nil {
+		t.Fatalf("dedupeProfiles() error = %v", err)
+	}
+	profiles, _ := loadProfiles()
+	if _, exists := profiles["acme"]; exists || profiles["work"].Description != "Acme" || len(profiles) != 2 {
+		t.Errorf("profiles after merging = %v", profiles)
+	}
+
+	if err := dedupeProfiles([]string{"nope"}, false); err == nil {
+		t.Error("dedupeProfiles() accepted a profile that doesn't exist")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// scopeConfig is the git config of one scope, by canonical key
+type scopeConfig map[string][]string
+
+// canonicalConfigKey lowercases the section and name of a config key as
+// git does, leaving the subsection (e.g. a URL) alone
+func canonicalConfigKey(key string) string {
+	first, last := strings.Index(key, "."), strings.LastIndex(key, ".")
+	if first < 0 {
+		return strings.ToLower(key)
+	}
+	return strings.ToLower(key[:first]) + key[first:last] + strings.ToLower(key[last:])
+}
+
+// parseConfigList parses the output of `git config --list --null`
+func parseConfigList(out string) scopeConfig {
+	config := scopeConfig{}
+	for _, entry := range strings.Split(out, "\x00") {
+		if entry == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, "\n")
+		key = canonicalConfigKey(key)
+		config[key] = append(config[key], value)
+	}
+	return config
+}
+
+// readScopeConfig reads the global or local git config; ok is false when
+// there is none, e.g. outside a repository
+func readScopeConfig(scope string) (scopeConfig, bool, error) {
+	config, err := gitConfig.List("", scope)
+	if isUnsafeRepository(err) {
+		return nil, false, err
+	}
+	if err != nil {
+		return scopeConfig{}, false, nil
+	}
+	return config, true, nil
+}
+
+// scopeProfile returns the profile whose identity a scope's config has, or
+// failing that whose email it has, with whether both name and email match
+func scopeProfile(profiles map[string]Profile, config scopeConfig) (string, bool) {
+	name, email := lastValue(config["user.name"]), lastValue(config["user.email"])
+	if email == "" {
+		return "", false
+	}
+	if profileName, ok := findProfileByIdentity(profiles, name, email); ok {
+		return profileName, true
+	}
+	profileName, _ := profileForEmail(profiles, email)
+	return profileName, false
+}
+
+// lastValue returns the value of a single-valued key, the last one set
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// profileExpectations returns the values a switch to a profile in scope
+// writes, by canonical key
+func profileExpectations(profile Profile, scope string) scopeConfig {
+	expected := scopeConfig{}
+	for _, key := range profileManagedKeys(profile, scope) {
+		canonical := canonicalConfigKey(key.Key)
+		expected[canonical] = append(expected[canonical], key.Value)
+	}
+	return expected
+}
+
+// configDiff is a row of `git usr diff`: a key's values in both scopes and
+// what is worth noting about them
+type configDiff struct {
+	Key    string
+	Global []string
+	Local  []string
+	Notes  []string
+}
+
+// sameValues reports whether two keys hold the same values, in any order
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// identityDiff compares the global and local config: the identity keys,
+// the keys the profile of either scope writes, and where the local config
+// shadows the global one or a scope differs from its profile
+func identityDiff(profiles map[string]Profile, global, local scopeConfig, globalProfile, localProfile string) []configDiff {
+	expectations := map[string]scopeConfig{}
+	keys := map[string]bool{"user.name": true, "user.email": true}
+	for scope, profileName := range map[string]string{"global": globalProfile, "local": localProfile} {
+		if profileName == "" {
+			continue
+		}
+		expectations[scope] = profileExpectations(profiles[profileName], scope)
+		for key := range expectations[scope] {
+			keys[key] = true
+		}
+	}
+	// Signing and committer settings matter even without a profile
+	for _, key := range []string{"user.signingkey", "gpg.format", "commit.gpgsign", "committer.name", "committer.email"} {
+		if len(global[key]) > 0 || len(local[key]) > 0 {
+			keys[key] = true
+		}
+	}
+
+	var diffs []configDiff
+	for _, key := range sortedKeys(keys) {
+		diff := configDiff{Key: key, Global: global[key], Local: local[key]}
+		if len(diff.Global) > 0 && len(diff.Local) > 0 && !sameValues(diff.Global, diff.Local) {
+			diff.Notes = append(diff.Notes, "local shadows global")
+		}
+		for _, scope := range []string{"global", "local"} {
+			expected, ok := expectations[scope][key]
+			actual := diff.Global
+			profileName := globalProfile
+			if scope == "local" {
+				actual, profileName = diff.Local, localProfile
+			}
+			if ok && !sameValues(expected, actual) {
+				diff.Notes = append(diff.Notes, fmt.Sprintf("%s differs from '%s'", scope, profileName))
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// describeScopeProfile returns the PROFILE cell of a scope
+func describeScopeProfile(config scopeConfig, profileName string, exact bool) string {
+	switch {
+	case len(config["user.email"]) == 0:
+		return "-"
+	case profileName == "":
+		return "(none)"
+	case !exact:
+		return profileName + " (email only)"
+	}
+	return profileName
+}
+
+// showIdentityDiff prints the global and local git config side by side
+// with the profiles they belong to
+func showIdentityDiff() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	global, _, err := readScopeConfig("global")
+	if err != nil {
+		return err
+	}
+	local, inRepo, err := readScopeConfig("local")
+	if err != nil {
+		return err
+	}
+
+	globalProfile, globalExact := scopeProfile(config.Profiles, global)
+	localProfile, localExact := scopeProfile(config.Profiles, local)
+	diffs := identityDiff(config.Profiles, global, local, globalProfile, localProfile)
+
+	rows := [][]string{{"KEY", "GLOBAL", "LOCAL", ""}}
+	shadowed := 0
+	for _, diff := range diffs {
+		localCell := strings.Join(diff.Local, ", ")
+		if !inRepo {
+			localCell = "-"
+		}
+		rows = append(rows, []string{diff.Key, strings.Join(diff.Global, ", "), localCell, strings.Join(diff.Notes, "; ")})
+		if containsString(diff.Notes, "local shadows global") {
+			shadowed++
+		}
+	}
+	localCell := describeScopeProfile(local, localProfile, localExact)
+	if !inRepo {
+		localCell = "-"
+	}
+	rows = append(rows, []string{"PROFILE", describeScopeProfile(global, globalProfile, globalExact), localCell, ""})
+
+	if inRepo {
+		repo, _ := managedRepo("", "local")
+		fmt.Printf("\n🔍 Global and local git config of %s:\n", repo)
+	} else {
+		fmt.Println("\n🔍 Global git config (not in a git repository):")
+	}
+	color := useColor(config.Settings)
+	lines := formatTable(rows)
+	fmt.Println("   " + colorize(color, ansiBold, lines[0]))
+	for i, line := range lines[1:] {
+		if i < len(diffs) && len(diffs[i].Notes) > 0 {
+			fmt.Println("⚠️ " + line)
+		} else {
+			fmt.Println("   " + line)
+		}
+	}
+
+	if inRepo {
+		if expected := expectedProfiles(config.Profiles, getRemoteURLs("")); len(expected) > 0 && !containsString(expected, localProfile) {
+			fmt.Printf("\nThe remote belongs to '%s'; switch with 'git usr %s'\n", strings.Join(expected, "' or '"), expected[0])
+		}
+	}
+	if shadowed > 0 {
+		fmt.Printf("\n%d global value(s) are shadowed by this repository's config; 'git usr managed list' shows what git-usr wrote\n", shadowed)
+	}
+	return nil
+}
+
+// runDiff handles the diff command
+func runDiff(args []string) error {
+	if len(args) > 0 {
+		fmt.Println("Usage: git usr diff")
+		return fmt.Errorf("unexpected argument: %s", args[0])
+	}
+	return showIdentityDiff()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseConfigList tests parsing `git config --list --null`, keeping
+// the case of subsections and every value of multi-valued keys
+func TestParseConfigList(t *testing.T) {
+	out := "user.name\nJane Doe\x00User.Email\njane@acme.io\x00credential.https://GitHub.com.helper\n\x00credential.https://GitHub.com.helper\nstore\x00remote.pushDefault\nfork\x00"
+	config := parseConfigList(out)
+	if lastValue(config["user.email"]) != "jane@acme.io" || lastValue(config["user.name"]) != "Jane Doe" {
+		t.Errorf("identity: got %v", config)
+	}
+	if helpers := config["credential.https://GitHub.com.helper"]; len(helpers) != 2 || helpers[1] != "store" {
+		t.Errorf("helpers: got %q", helpers)
+	}
+	if canonicalConfigKey("remote.pushDefault") != "remote.pushdefault" || lastValue(config["remote.pushdefault"]) != "fork" {
+		t.Errorf("pushDefault: got %v", config)
+	}
+}
+
+// TestIdentityDiff tests flagging local values shadowing global ones and
+// scopes that drifted from their profile
+func TestIdentityDiff(t *testing.T) {
+	profiles := map[string]Profile{
+		"personal": {Name: "Jane Doe", Email: "jane@home.dev"},
+		"work":     {Name: "Jane Doe", Email: "jane@acme.io", SigningKey: "ABC"},
+	}
+	global := scopeConfig{"user.name": {"Jane Doe"}, "user.email": {"jane@home.dev"}}
+	local := scopeConfig{"user.name": {"Jane Doe"}, "user.email": {"jane@acme.io"}, "user.signingkey": {"XYZ"}}
+
+	if name, exact := scopeProfile(profiles, local); name != "work" || !exact {
+		t.Errorf("scopeProfile(local) = %s, %v", name, exact)
+	}
+	notes := map[string]string{}
+	for _, diff := range identityDiff(profiles, global, local, "personal", "work") {
+		notes[diff.Key] = strings.Join(diff.Notes, "; ")
+	}
+	expected := map[string]string{
+		"user.name":       "",
+		"user.email":      "local shadows global",
+		"user.signingkey": "local differs from 'work'",
+		"commit.gpgsign":  "local differs from 'work'",
+	}
+	for key, note := range expected {
+		if got, listed := notes[key]; !listed || got != note {
+			t.Errorf("%s: got %q (listed %v), expected %q", key, got, listed, note)
+		}
+	}
+}
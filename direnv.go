@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Markers around the part of an .envrc owned by git-usr; the rest of the
+// file is left alone
+const (
+	envrcBlockBegin = "# BEGIN git-usr (generated by 'git usr direnv')"
+	envrcBlockEnd   = "# END git-usr"
+)
+
+// envrcLines returns the .envrc lines exporting a profile's environment
+// and, for sshKey, a GIT_SSH_COMMAND that authenticates with that key only
+func envrcLines(profileName string, profile Profile, sshKey string) []string {
+	export := envShells["sh"]
+	lines := []string{fmt.Sprintf("# Identity of the git-usr profile '%s'", profileName)}
+	for _, v := range profileEnv(profile) {
+		lines = append(lines, export(v))
+	}
+	if sshKey != "" {
+		lines = append(lines, export(EnvVar{"GIT_SSH_COMMAND", "ssh -i " + shellQuote(sshKey) + " -o IdentitiesOnly=yes"}))
+	}
+	return lines
+}
+
+// writeEnvrc replaces the git-usr block of the .envrc in dir with lines,
+// removing the file when nothing else is left in it. It returns the path
+// and whether there was a block to remove
+func writeEnvrc(dir string, lines []string) (string, bool, error) {
+	path := filepath.Join(dir, ".envrc")
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	content := replaceMarkedBlock(string(existing), envrcBlockBegin, envrcBlockEnd, lines)
+	if content == string(existing) {
+		return path, false, nil
+	}
+	if content == "" {
+		return path, true, os.Remove(path)
+	}
+	return path, true, writeFileAtomic(path, []byte(content), 0644, false)
+}
+
+// runDirenv handles the direnv command
+func runDirenv(args []string) error {
+	usage := "Usage: git usr direnv <profile> [--ssh-key <path>] | --remove"
+
+	profileName, sshKey := "", ""
+	remove := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--remove":
+			remove = true
+		case "--ssh-key":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--ssh-key requires a path")
+			}
+			sshKey = args[i+1]
+			i++
+		default:
+			if profileName != "" || strings.HasPrefix(arg, "-") {
+				fmt.Println(usage)
+				return fmt.Errorf("unexpected argument: %s", arg)
+			}
+			profileName = arg
+		}
+	}
+	if remove == (profileName != "") || remove && sshKey != "" {
+		fmt.Println(usage)
+		return fmt.Errorf("expected a profile or --remove")
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if remove {
+		path, changed, err := writeEnvrc(dir, nil)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			fmt.Printf("No git-usr identity in %s\n", path)
+			return nil
+		}
+		fmt.Printf("✅ Removed the git-usr identity from %s\n", path)
+		return nil
+	}
+
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+	if sshKey != "" {
+		if sshKey, err = expandHome(sshKey); err != nil {
+			return err
+		}
+		if sshKey, err = filepath.Abs(sshKey); err != nil {
+			return err
+		}
+		if _, err := os.Stat(sshKey); err != nil {
+			fmt.Printf("⚠️  SSH key not found: %s\n", sshKey)
+		}
+	}
+
+	path, _, err := writeEnvrc(dir, envrcLines(profileName, profile, sshKey))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s now exports the identity of '%s'\n", path, profileName)
+	fmt.Println("   Entering the directory applies it to git and to anything started from the shell")
+	if _, err := exec.LookPath("direnv"); err != nil {
+		fmt.Println("⚠️  direnv isn't installed; see https://direnv.net")
+	} else {
+		fmt.Println("   Run 'direnv allow' to let direnv load the changed file")
+	}
+	return nil
+}
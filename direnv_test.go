@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestEnvrcLines tests the exports written for a profile
+func TestEnvrcLines(t *testing.T) {
+	profile := Profile{Name: "Jane Doe", Email: "jane@acme.io"}
+	expected := []string{
+		"# Identity of the git-usr profile 'work'",
+		"export GIT_AUTHOR_NAME='Jane Doe'",
+		"export GIT_AUTHOR_EMAIL='jane@acme.io'",
+		"export GIT_COMMITTER_NAME='Jane Doe'",
+		"export GIT_COMMITTER_EMAIL='jane@acme.io'",
+		`export GIT_SSH_COMMAND='ssh -i '\''/keys/id work'\'' -o IdentitiesOnly=yes'`,
+	}
+	if got := envrcLines("work", profile, "/keys/id work"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("envrcLines() =\n%q\nexpected\n%q", got, expected)
+	}
+}
+
+// TestWriteEnvrc tests updating the git-usr block while keeping the rest
+// of the file, and removing the file once only the block was in it
+func TestWriteEnvrc(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".envrc")
+	if err := os.WriteFile(path, []byte("layout go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, changed, err := writeEnvrc(dir, []string{"export A=1"}); err != nil || !changed {
+		t.Fatalf("writeEnvrc() = %v, %v", changed, err)
+	}
+	if _, _, err := writeEnvrc(dir, []string{"export A=2"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if expected := "layout go\n\n" + envrcBlockBegin + "\nexport A=2\n" + envrcBlockEnd + "\n"; string(data) != expected {
+		t.Errorf(".envrc =\n%s\nexpected\n%s", data, expected)
+	}
+
+	if _, changed, err := writeEnvrc(dir, nil); err != nil || !changed {
+		t.Fatalf("writeEnvrc() removing = %v, %v", changed, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "layout go\n" {
+		t.Errorf(".envrc after removing = %q", data)
+	}
+	if _, changed, _ := writeEnvrc(dir, nil); changed {
+		t.Error("writeEnvrc() reported a change without a block")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	writeEnvrc(dir, []string{"export A=1"})
+	writeEnvrc(dir, nil)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf(".envrc with only the git-usr block left behind: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// doctorResult is the outcome of a doctor check for one profile
+type doctorResult struct {
+	Profile string
+	OK      bool
+	Message string
+	Fix     string
+}
+
+// doctorCheck is a check of the environment a profile needs. Unlike lint,
+// doctor checks run the tools the profile relies on
+type doctorCheck struct {
+	name  string
+	check func(profileName string, profile Profile) []doctorResult
+}
+
+// doctorChecks are run by `git usr doctor` in order
+var doctorChecks = []doctorCheck{
+	{"x509", doctorX509},
+	{"gitsign", doctorGitsign},
+	{"token", doctorToken},
+}
+
+// x509ExpiryWarning is how early doctor warns about expiring certificates
+const x509ExpiryWarning = 30 * 24 * time.Hour
+
+// doctorX509 checks that an X.509 signing certificate chains to a trusted
+// root and isn't about to expire
+func doctorX509(profileName string, profile Profile) []doctorResult {
+	if profile.SigningFormat != "x509" || profile.SigningKey == "" || usesGitsign(profile) {
+		return nil
+	}
+
+	expires, err := checkX509Signing(profile)
+	if err != nil {
+		return []doctorResult{{
+			Profile: profileName,
+			Message: fmt.Sprintf("X.509 certificate %s: %v", profile.SigningKey, err),
+			Fix:     fmt.Sprintf("import the issuing CA certificates and trust the root for %s", x509Program(profile)),
+		}}
+	}
+
+	if !expires.IsZero() && time.Until(expires) < x509ExpiryWarning {
+		return []doctorResult{{
+			Profile: profileName,
+			Message: fmt.Sprintf("X.509 certificate %s expires on %s", profile.SigningKey, expires.Format("2006-01-02")),
+			Fix:     fmt.Sprintf("renew the certificate, then run 'git usr keys setup %s --x509'", profileName),
+		}}
+	}
+
+	message := fmt.Sprintf("X.509 certificate %s chains to a trusted root", profile.SigningKey)
+	if !expires.IsZero() {
+		message += fmt.Sprintf(" (expires %s)", expires.Format("2006-01-02"))
+	}
+	return []doctorResult{{Profile: profileName, OK: true, Message: message}}
+}
+
+// runDoctor handles the doctor command
+func runDoctor(args []string) error {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = sortedProfileNames(profiles)
+	}
+	for _, name := range names {
+		if _, exists := profiles[name]; !exists {
+			fmt.Printf("❌ Profile '%s' not found!\n", name)
+			fmt.Println("\nAvailable profiles:", getProfileNames(profiles))
+			return errProfileNotFound
+		}
+	}
+
+	checked, problems := 0, 0
+	for _, check := range doctorChecks {
+		for _, name := range names {
+			for _, result := range check.check(name, profiles[name]) {
+				checked++
+				if result.OK {
+					fmt.Printf("✅ %s: %s [%s]\n", result.Profile, result.Message, check.name)
+					continue
+				}
+				problems++
+				fmt.Printf("❌ %s: %s [%s]\n", result.Profile, result.Message, check.name)
+				fmt.Printf("   Fix: %s\n", result.Fix)
+			}
+		}
+	}
+
+	switch {
+	case problems > 0:
+		fmt.Printf("\n%d problem(s) found\n", problems)
+		return fmt.Errorf("doctor found %d problem(s)", problems)
+	case checked == 0:
+		fmt.Println("✅ Nothing to check")
+	}
+	return nil
+}
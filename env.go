@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envKeyPattern matches valid environment variable names
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVar is a single environment variable assignment
+type EnvVar struct {
+	Key   string
+	Value string
+}
+
+// profileEnv returns the environment a profile exports: git's author and
+// committer identity followed by the profile's own variables
+func profileEnv(profile Profile) []EnvVar {
+	committerName, committerEmail := committerIdentity(profile)
+	vars := []EnvVar{
+		{"GIT_AUTHOR_NAME", profile.Name},
+		{"GIT_AUTHOR_EMAIL", profile.Email},
+		{"GIT_COMMITTER_NAME", committerName},
+		{"GIT_COMMITTER_EMAIL", committerEmail},
+	}
+
+	keys := make([]string, 0, len(profile.Env))
+	for key := range profile.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		vars = append(vars, EnvVar{key, profile.Env[key]})
+	}
+
+	return vars
+}
+
+// debianEnv returns the identity variables read by dch/debchange and other
+// Debian packaging tools, honoring overrides from the profile's variables
+func debianEnv(profile Profile) []EnvVar {
+	vars := []EnvVar{
+		{"DEBFULLNAME", profile.Name},
+		{"DEBEMAIL", profile.Email},
+	}
+	for i, v := range vars {
+		if override, ok := profile.Env[v.Key]; ok {
+			vars[i].Value = override
+		}
+	}
+	return vars
+}
+
+// shellQuote quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes a value for fish, which only escapes \ and ' in single
+// quotes
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// powershellQuote quotes a value for PowerShell, which doubles ' in
+// single quotes, and the typographic single quotes it takes for ' too
+func powershellQuote(value string) string {
+	return "'" + strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b").Replace(value) + "'"
+}
+
+// envShells format the statement exporting a variable, per shell
+var envShells = map[string]func(v EnvVar) string{
+	"sh":         func(v EnvVar) string { return "export " + v.Key + "=" + shellQuote(v.Value) },
+	"fish":       func(v EnvVar) string { return "set -gx " + v.Key + " " + fishQuote(v.Value) },
+	"powershell": func(v EnvVar) string { return "$env:" + v.Key + " = " + powershellQuote(v.Value) },
+}
+
+// envShellNames maps the names accepted by --shell to envShells
+var envShellNames = map[string]string{
+	"":           "sh",
+	"sh":         "sh",
+	"bash":       "sh",
+	"zsh":        "sh",
+	"fish":       "fish",
+	"powershell": "powershell",
+	"pwsh":       "powershell",
+}
+
+// getProfile loads a single profile by name, reporting when it is missing
+func getProfile(profileName string) (Profile, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile, exists := profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		fmt.Println("\nAvailable profiles:", getProfileNames(profiles))
+		return Profile{}, errProfileNotFound
+	}
+
+	return profile, nil
+}
+
+// printProfileEnv prints the statements exporting a profile's variables in
+// the given format, for shell
+func printProfileEnv(profileName, format, shell string) error {
+	var vars func(Profile) []EnvVar
+	switch format {
+	case "", "shell":
+		vars = profileEnv
+	case "dch":
+		vars = debianEnv
+	default:
+		fmt.Printf("❌ Unsupported format: %s. Supported: shell, dch\n", format)
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	export, ok := envShells[envShellNames[shell]]
+	if !ok {
+		fmt.Printf("❌ Unsupported shell: %s. Supported: sh, fish, powershell\n", shell)
+		return fmt.Errorf("unsupported shell: %s", shell)
+	}
+
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range vars(profile) {
+		fmt.Println(export(v))
+	}
+
+	return nil
+}
+
+// updateProfileEnv sets and unsets environment variables of a profile
+func updateProfileEnv(profileName string, set []string, unset []string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles := config.Profiles
+
+	profile, exists := profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		return errProfileNotFound
+	}
+
+	for _, assignment := range set {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok || !envKeyPattern.MatchString(key) {
+			fmt.Printf("❌ Invalid variable '%s', expected KEY=VALUE\n", assignment)
+			return fmt.Errorf("invalid variable: %s", assignment)
+		}
+		if profile.Env == nil {
+			profile.Env = map[string]string{}
+		}
+		profile.Env[key] = value
+		fmt.Printf("✅ Set %s for '%s'\n", key, profileName)
+	}
+
+	for _, key := range unset {
+		if _, ok := profile.Env[key]; !ok {
+			fmt.Printf("⚠️  %s is not set for '%s'\n", key, profileName)
+			continue
+		}
+		delete(profile.Env, key)
+		fmt.Printf("✅ Unset %s for '%s'\n", key, profileName)
+	}
+	if len(profile.Env) == 0 {
+		profile.Env = nil
+	}
+
+	profiles[profileName] = profile
+	return saveConfig(config)
+}
+
+// runEnv handles the env command
+func runEnv(args []string) error {
+	usage := "Usage: git usr env <profile> [--format shell|dch] [--shell sh|fish|powershell] [--set KEY=VALUE]... [--unset KEY]..."
+
+	profileName, format, shell := "", "", ""
+	var set, unset []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--set", "--unset", "--format", "--shell":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			switch args[i] {
+			case "--set":
+				set = append(set, args[i+1])
+			case "--unset":
+				unset = append(unset, args[i+1])
+			case "--shell":
+				shell = args[i+1]
+			default:
+				format = args[i+1]
+			}
+			i++
+		default:
+			if profileName != "" {
+				fmt.Println(usage)
+				return fmt.Errorf("unexpected argument: %s", args[i])
+			}
+			profileName = args[i]
+		}
+	}
+
+	if profileName == "" {
+		fmt.Println("❌ Profile name required!")
+		fmt.Println(usage)
+		return fmt.Errorf("profile name required")
+	}
+
+	if len(set) > 0 || len(unset) > 0 {
+		return updateProfileEnv(profileName, set, unset)
+	}
+	return printProfileEnv(profileName, format, shell)
+}
+
+// runExec runs a command with a profile's environment
+func runExec(args []string) error {
+	if len(args) > 1 && args[1] == "--" {
+		args = append(args[:1], args[2:]...)
+	}
+	if len(args) < 2 {
+		fmt.Println("Usage: git usr exec <profile> -- <command> [args...]")
+		return fmt.Errorf("command required")
+	}
+
+	profile, err := getProfile(args[0])
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+	for _, v := range profileEnv(profile) {
+		env = append(env, v.Key+"="+v.Value)
+	}
+
+	cmd := exec.Command(args[1], args[2:]...)
+	cmd.Env = env
+	attachTerminal(cmd)
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &ExitError{Code: exitErr.ExitCode(), Err: err}
+		}
+		fmt.Printf("❌ Failed to run %s: %v\n", args[1], err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+// TestProfileEnv tests the variables exported for a profile
+func TestProfileEnv(t *testing.T) {
+	profile := Profile{
+		Name:  "John Doe",
+		Email: "john@work.com",
+		Env:   map[string]string{"NPM_EMAIL": "john@work.com", "DEBFULLNAME": "John Doe"},
+	}
+
+	vars := profileEnv(profile)
+	if len(vars) != 6 {
+		t.Fatalf("Expected 6 variables, got: %d", len(vars))
+	}
+	if vars[0].Key != "GIT_AUTHOR_NAME" || vars[0].Value != "John Doe" {
+		t.Errorf("Unexpected first variable: %v", vars[0])
+	}
+	if vars[4].Key != "DEBFULLNAME" || vars[5].Key != "NPM_EMAIL" {
+		t.Errorf("Profile variables not sorted: %v", vars[4:])
+	}
+}
+
+// TestProfileEnvCommitter tests that a committer override only changes the
+// committer variables
+func TestProfileEnvCommitter(t *testing.T) {
+	profile := Profile{Name: "Release Bot", Email: "bot@example.com", CommitterEmail: "john@example.com"}
+
+	expected := []EnvVar{
+		{"GIT_AUTHOR_NAME", "Release Bot"},
+		{"GIT_AUTHOR_EMAIL", "bot@example.com"},
+		{"GIT_COMMITTER_NAME", "Release Bot"},
+		{"GIT_COMMITTER_EMAIL", "john@example.com"},
+	}
+	vars := profileEnv(profile)
+	for i, v := range expected {
+		if vars[i] != v {
+			t.Errorf("Variable %d = %v, expected %v", i, vars[i], v)
+		}
+	}
+}
+
+// TestShellQuote tests POSIX quoting of values
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("O'Brien"); got != `'O'\''Brien'` {
+		t.Errorf("Unexpected quoting: %s", got)
+	}
+}
+
+// TestEnvShells tests the export statements of each shell
+func TestEnvShells(t *testing.T) {
+	v := EnvVar{"GIT_AUTHOR_NAME", `Jane O'Brien \ O’Hara`}
+	expected := map[string]string{
+		"sh":         `export GIT_AUTHOR_NAME='Jane O'\''Brien \ O’Hara'`,
+		"fish":       `set -gx GIT_AUTHOR_NAME 'Jane O\'Brien \\ O’Hara'`,
+		"powershell": `$env:GIT_AUTHOR_NAME = 'Jane O''Brien \ O’’Hara'`,
+	}
+	for shell, want := range expected {
+		if got := envShells[shell](v); got != want {
+			t.Errorf("%s: got %s, expected %s", shell, got, want)
+		}
+	}
+	for _, name := range []string{"", "bash", "zsh", "pwsh"} {
+		if _, ok := envShells[envShellNames[name]]; !ok {
+			t.Errorf("--shell %q isn't accepted", name)
+		}
+	}
+}
+
+// TestDebianEnv tests the dch identity variables and their overrides
+func TestDebianEnv(t *testing.T) {
+	profile := Profile{Name: "John Doe", Email: "john@work.com"}
+
+	vars := debianEnv(profile)
+	if vars[0] != (EnvVar{"DEBFULLNAME", "John Doe"}) || vars[1] != (EnvVar{"DEBEMAIL", "john@work.com"}) {
+		t.Errorf("Unexpected variables: %v", vars)
+	}
+
+	profile.Env = map[string]string{"DEBEMAIL": "john@debian.org"}
+	if vars := debianEnv(profile); vars[1].Value != "john@debian.org" {
+		t.Errorf("DEBEMAIL override not honored: %v", vars)
+	}
+}
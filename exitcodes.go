@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Exit codes, so scripts can tell failures apart. Commands that run
+// another program (exec, clone, push-to, tag) exit with its status.
+// 3 to 5 are the states of prompt --check (see prompt.go), which predate
+// these codes
+const (
+	exitFailure          = 1
+	exitProfileNotFound  = 2
+	exitUnsafeRepository = 6
+	exitMixedIdentity    = 7
+	exitGitMissing       = 8
+	exitConfigCorrupt    = 9
+	exitConfigLocked     = 10
+)
+
+var (
+	errProfileNotFound = errors.New("profile not found")
+	errConfigCorrupt   = errors.New("invalid config file")
+	errConfigLocked    = errors.New("config could not be unlocked")
+	errMixedIdentity   = errors.New("identity only partly switched")
+)
+
+// isGitMissing reports whether err is from running git when it isn't
+// installed
+func isGitMissing(err error) bool {
+	var execErr *exec.Error
+	return errors.As(err, &execErr) && execErr.Name == "git"
+}
+
+// exitCode returns the exit code for the error a command failed with
+func exitCode(err error) int {
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.Is(err, errProfileNotFound):
+		return exitProfileNotFound
+	case isGitMissing(err):
+		return exitGitMissing
+	case errors.Is(err, errConfigCorrupt):
+		return exitConfigCorrupt
+	case errors.Is(err, errConfigLocked):
+		return exitConfigLocked
+	case isUnsafeRepository(err):
+		return exitUnsafeRepository
+	case errors.Is(err, errMixedIdentity):
+		return exitMixedIdentity
+	}
+	return exitFailure
+}
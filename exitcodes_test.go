@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"other", fmt.Errorf("something failed"), exitFailure},
+		{"profile not found", errProfileNotFound, exitProfileNotFound},
+		{"wrapped profile not found", fmt.Errorf("%w: work", errProfileNotFound), exitProfileNotFound},
+		{"git missing", &exec.Error{Name: "git", Err: exec.ErrNotFound}, exitGitMissing},
+		{"other program missing", &exec.Error{Name: "gpg", Err: exec.ErrNotFound}, exitFailure},
+		{"config corrupt", fmt.Errorf("%w: %w", errConfigCorrupt, fmt.Errorf("unexpected EOF")), exitConfigCorrupt},
+		{"config locked", fmt.Errorf("%w: wrong passphrase", errConfigLocked), exitConfigLocked},
+		{"unsafe repository", &UnsafeRepositoryError{Path: "/srv/repo"}, exitUnsafeRepository},
+		{"explicit code", &ExitError{Code: 42}, 42},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
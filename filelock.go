@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// configLockTimeout is how long to wait for another invocation to finish
+	configLockTimeout = 10 * time.Second
+	// staleLockAge is the age after which a lock file whose holder can't be
+	// checked, such as one on another host, is assumed abandoned
+	staleLockAge = 30 * time.Second
+)
+
+// lockHolder is what a lock file records about its holder: the pid, the
+// host and a token telling this holder's lock from a later one
+type lockHolder struct {
+	PID   int
+	Host  string
+	Token string
+}
+
+// String returns the lock file content
+func (h lockHolder) String() string {
+	return fmt.Sprintf("%d %s %s\n", h.PID, h.Host, h.Token)
+}
+
+// lockHost returns the host name lock files record
+func lockHost() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "-"
+}
+
+// parseLockHolder parses a lock file. Files holding just a pid are from
+// this host
+func parseLockHolder(data []byte) lockHolder {
+	fields := strings.Fields(string(data))
+	var holder lockHolder
+	if len(fields) > 0 {
+		holder.PID, _ = strconv.Atoi(fields[0])
+	}
+	if len(fields) > 1 {
+		holder.Host = fields[1]
+	} else {
+		holder.Host = lockHost()
+	}
+	return holder
+}
+
+// processAlive reports whether a process with pid is running
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// On Windows FindProcess opens the process, which fails once it's gone
+	if runtime.GOOS == "windows" {
+		process.Release()
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// isStaleLock reports whether the lock file at path was left behind by a
+// holder that is gone. A holder on this host is checked by its pid, so a
+// live one sitting at a prompt keeps its lock however long it takes
+func isStaleLock(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	holder := parseLockHolder(data)
+	if holder.Host == lockHost() && holder.PID > 0 {
+		return !processAlive(holder.PID)
+	}
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > staleLockAge
+}
+
+// breakStaleLock removes the lock file at path if its holder is gone,
+// reporting whether it did. Breakers first create path+".break"
+// exclusively and check the holder again while holding it: nothing else can
+// remove the lock file then, and nothing can create one while it exists, so
+// the file removed is the one found stale, never a lock another waiter took
+// after breaking it
+func breakStaleLock(path string) bool {
+	guard := path + ".break"
+	f, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		// Left behind by a breaker that crashed, as it is only held briefly
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(guard)
+		}
+		return false
+	}
+	f.Close()
+	defer os.Remove(guard)
+
+	if !isStaleLock(path) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// acquireFileLock creates path exclusively, waiting up to timeout for a
+// concurrent holder to release it. The returned func releases the lock,
+// unless it was broken and taken by someone else in the meantime
+func acquireFileLock(path string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+
+	token := make([]byte, 8)
+	rand.Read(token)
+	mine := []byte(lockHolder{PID: os.Getpid(), Host: lockHost(), Token: hex.EncodeToString(token)}.String())
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Write(mine)
+			f.Close()
+			return func() {
+				if data, err := os.ReadFile(path); err == nil && bytes.Equal(data, mine) {
+					os.Remove(path)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		// Break locks left behind by crashed invocations
+		if isStaleLock(path) && breakStaleLock(path) {
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("timed out waiting for lock %s (held by pid %d)", path, parseLockHolder(holder).PID)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// configLockHeld is set while this process holds the config lock, so code
+// that may run with or without it, like the first-run save, can tell
+var configLockHeld bool
+
+// acquireConfigLock locks the config file against concurrent modification
+func acquireConfigLock() (func(), error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := acquireFileLock(configPath+".lock", configLockTimeout)
+	if err != nil {
+		fmt.Printf("❌ Config is busy: %v\n", err)
+		return nil, err
+	}
+	configLockHeld = true
+	return func() {
+		configLockHeld = false
+		unlock()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temp file next to path, fsyncs it and
+// renames it into place, so readers never observe a partial file. The
+// permissions of an existing file are preserved unless perm forces them.
+func writeFileAtomic(path string, data []byte, perm os.FileMode, forcePerm bool) error {
+	if info, err := os.Stat(path); err == nil && !forcePerm {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself; directories can't be synced on Windows
+	if runtime.GOOS != "windows" {
+		if d, err := os.Open(dir); err == nil {
+			d.Sync()
+			d.Close()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestAcquireFileLockExclusive tests that a held lock blocks a second holder
+func TestAcquireFileLockExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json.lock")
+
+	unlock, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock failed: %v", err)
+	}
+
+	if _, err := acquireFileLock(path, 100*time.Millisecond); err == nil {
+		t.Error("Expected second lock to time out")
+	}
+
+	unlock()
+
+	unlock, err = acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock after release failed: %v", err)
+	}
+	unlock()
+}
+
+// TestAcquireFileLockStale tests that only locks of holders that are gone
+// are broken, however old they are
+func TestAcquireFileLockStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json.lock")
+	old := time.Now().Add(-time.Hour)
+
+	// A live holder keeps its lock
+	os.WriteFile(path, []byte(lockHolder{PID: os.Getpid(), Host: lockHost(), Token: "x"}.String()), 0644)
+	os.Chtimes(path, old, old)
+	if _, err := acquireFileLock(path, 100*time.Millisecond); err == nil {
+		t.Error("Expected the lock of a live holder to be kept")
+	}
+
+	// A holder that is gone loses it
+	os.WriteFile(path, []byte(lockHolder{PID: 99999999, Host: lockHost(), Token: "x"}.String()), 0644)
+	unlock, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("Expected the lock of a dead holder to be broken: %v", err)
+	}
+
+	// Releasing a lock someone else took over leaves theirs alone
+	os.WriteFile(path, []byte("1 elsewhere y\n"), 0644)
+	unlock()
+	if _, err := os.Stat(path); err != nil {
+		t.Error("Expected the other holder's lock to stay")
+	}
+}
+
+// TestWriteFileAtomicPreservesPermissions tests atomic replacement of a file
+func TestWriteFileAtomicPreservesPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions not supported")
+	}
+
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new"), 0644, false); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "new" {
+		t.Errorf("Expected 'new', got: %s", data)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got: %v", info.Mode().Perm())
+	}
+}
+
+// TestBreakStaleLock tests that breaking a lock checks its holder again
+// under the break guard, so a waiter that found the lock stale can't remove
+// the one another waiter took after breaking it first
+func TestBreakStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json.lock")
+	stale := []byte(lockHolder{PID: 99999999, Host: lockHost(), Token: "x"}.String())
+	os.WriteFile(path, stale, 0644)
+
+	// Both waiters find the lock stale; the first breaks it and takes it
+	if !isStaleLock(path) {
+		t.Fatal("Expected the lock of a dead holder to be stale")
+	}
+	if !breakStaleLock(path) {
+		t.Fatal("Expected the stale lock to be broken")
+	}
+	unlock, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock failed: %v", err)
+	}
+	defer unlock()
+
+	// The second, acting on what it saw before, leaves it alone
+	if breakStaleLock(path) {
+		t.Error("Expected the new holder's lock to be kept")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the new holder's lock to stay: %v", err)
+	}
+
+	// Nothing is broken while another waiter holds the guard
+	os.WriteFile(path, stale, 0644)
+	os.WriteFile(path+".break", nil, 0644)
+	if breakStaleLock(path) {
+		t.Error("Expected no break while the guard is held")
+	}
+	os.Remove(path + ".break")
+	if !breakStaleLock(path) {
+		t.Error("Expected the stale lock to be broken once the guard is free")
+	}
+	if _, err := os.Stat(path + ".break"); !os.IsNotExist(err) {
+		t.Errorf("Expected the guard to be removed, got %v", err)
+	}
+}
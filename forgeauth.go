@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// glabStatusPattern matches the account in `glab auth status` output
+var glabStatusPattern = regexp.MustCompile(`Logged in to \S+ as (\S+)`)
+
+// forgeAccountTarget splits a forgeAccount key into the forge and host it
+// refers to. Hosts containing "gitlab" use glab, others gh; a "github:" or
+// "gitlab:" prefix picks the forge of a self-hosted instance explicitly.
+func forgeAccountTarget(key string) (string, string) {
+	if name, host, ok := strings.Cut(key, ":"); ok {
+		if _, known := forges[name]; known {
+			return name, host
+		}
+	}
+	switch key {
+	case "github":
+		return "github", "github.com"
+	case "gitlab":
+		return "gitlab", "gitlab.com"
+	}
+	if strings.Contains(key, "gitlab") {
+		return "gitlab", key
+	}
+	return "github", key
+}
+
+// parseForgeAccount parses HOST=USER for the forgeAccount field
+func parseForgeAccount(value string) (string, string, error) {
+	host, user, ok := strings.Cut(value, "=")
+	host, user = strings.ToLower(strings.TrimSpace(host)), strings.TrimSpace(user)
+	if !ok || host == "" || user == "" || strings.ContainsAny(user, " \t") {
+		return "", "", fmt.Errorf("forgeAccount must be HOST=USER (e.g. github.com=jdoe-work)")
+	}
+	return host, user, nil
+}
+
+// githubSwitchAccount makes user the active gh account for host
+func githubSwitchAccount(host, user string) error {
+	out, err := exec.Command("gh", "auth", "switch", "--hostname", host, "--user", user).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("gh auth switch: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// gitlabSwitchAccount checks that glab is logged in to host as user. glab
+// keeps a single account per host, so it can't be switched automatically
+func gitlabSwitchAccount(host, user string) error {
+	// glab prints the status on stderr and exits non-zero if any host fails
+	out, _ := exec.Command("glab", "auth", "status", "--hostname", host).CombinedOutput()
+	match := glabStatusPattern.FindStringSubmatch(string(out))
+	if match == nil {
+		return fmt.Errorf("glab is not logged in to %s (run 'glab auth login --hostname %s')", host, host)
+	}
+	if !strings.EqualFold(match[1], user) {
+		return fmt.Errorf("glab is logged in to %s as %s, not %s (run 'glab auth login --hostname %s')", host, match[1], user, host)
+	}
+	return nil
+}
+
+// applyForgeAccounts switches the gh/glab CLI accounts of a profile,
+// printing each switched account if verbose. It returns a warning for
+// every account that could not be switched
+func applyForgeAccounts(profile Profile, verbose bool) []string {
+	var warnings []string
+	for _, key := range sortedKeys(profile.ForgeAccounts) {
+		user := profile.ForgeAccounts[key]
+		name, host := forgeAccountTarget(key)
+		f := forges[name]
+
+		if _, err := exec.LookPath(f.cli); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s not found in PATH, %s account not switched", f.cli, host))
+			continue
+		}
+		if err := f.switchAccount(host, user); err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		if verbose {
+			fmt.Printf("   %-8s %s on %s\n", f.cli+":", user, host)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+// TestForgeAccountTarget tests picking the CLI for a forgeAccount host
+func TestForgeAccountTarget(t *testing.T) {
+	tests := []struct {
+		key, forge, host string
+	}{
+		{"github.com", "github", "github.com"},
+		{"github", "github", "github.com"},
+		{"gitlab.com", "gitlab", "gitlab.com"},
+		{"gitlab.corp.com", "gitlab", "gitlab.corp.com"},
+		{"ghe.corp.com", "github", "ghe.corp.com"},
+		{"gitlab:git.corp.com", "gitlab", "git.corp.com"},
+	}
+	for _, tt := range tests {
+		forge, host := forgeAccountTarget(tt.key)
+		if forge != tt.forge || host != tt.host {
+			t.Errorf("forgeAccountTarget(%q) = %q, %q, expected %q, %q", tt.key, forge, host, tt.forge, tt.host)
+		}
+	}
+}
+
+// TestParseForgeAccount tests parsing HOST=USER values
+func TestParseForgeAccount(t *testing.T) {
+	host, user, err := parseForgeAccount(" GitHub.com = jdoe-work ")
+	if err != nil || host != "github.com" || user != "jdoe-work" {
+		t.Errorf("parseForgeAccount = %q, %q, %v", host, user, err)
+	}
+
+	for _, value := range []string{"github.com", "=jdoe", "github.com=", "github.com=j doe"} {
+		if _, _, err := parseForgeAccount(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+// TestGlabStatusPattern tests reading the account from glab auth status
+func TestGlabStatusPattern(t *testing.T) {
+	output := "gitlab.com\n  ✓ Logged in to gitlab.com as jdoe (/home/jdoe/.config/glab-cli/config.yml)\n"
+	match := glabStatusPattern.FindStringSubmatch(output)
+	if match == nil || match[1] != "jdoe" {
+		t.Errorf("Unexpected match %v", match)
+	}
+}
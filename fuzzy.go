@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// profileMatch is how a name that is no profile's matches the profiles
+type profileMatch struct {
+	// Prefix is the only profile whose name starts with it
+	Prefix string
+	// Ambiguous are the profiles starting with it when several do
+	Ambiguous []string
+	// Suggested is the one profile whose name is close to it, for typos
+	Suggested string
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and swaps of neighbouring letters that turn a into b
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// rows[i][j] is the distance between s[:i] and t[:j]
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
+
+// maxTypoDistance is how far a name may be from a profile's to be taken
+// for a typo of it: one edit for short names, two for longer ones
+func maxTypoDistance(name string) int {
+	if len([]rune(name)) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// matchProfileName matches a name that is no profile's against the
+// profile names, ignoring case: by prefix, then by edit distance
+func matchProfileName(names []string, input string) profileMatch {
+	lower := strings.ToLower(input)
+
+	var prefixed []string
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), lower) {
+			prefixed = append(prefixed, name)
+		}
+	}
+	sort.Strings(prefixed)
+	switch len(prefixed) {
+	case 0:
+	case 1:
+		return profileMatch{Prefix: prefixed[0]}
+	default:
+		return profileMatch{Ambiguous: prefixed}
+	}
+
+	best, bestDistance, tied := "", maxTypoDistance(input)+1, false
+	for _, name := range names {
+		distance := editDistance(lower, strings.ToLower(name))
+		switch {
+		case distance < bestDistance:
+			best, bestDistance, tied = name, distance, false
+		case distance == bestDistance:
+			tied = true
+		}
+	}
+	if tied {
+		return profileMatch{}
+	}
+	return profileMatch{Suggested: best}
+}
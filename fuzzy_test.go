@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEditDistance tests counting edits, with a swap of neighbouring
+// letters as one
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"work", "work", 0},
+		{"wrk", "work", 1},
+		{"wrok", "work", 1},
+		{"personal", "persnoal", 1},
+		{"oss", "work", 3},
+		{"", "abc", 3},
+		{"josé", "jose", 1},
+	}
+	for _, c := range cases {
+		if got := editDistance(c.a, c.b); got != c.expected {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.a, c.b, got, c.expected)
+		}
+	}
+}
+
+// TestMatchProfileName tests matching prefixes and typos of profile names
+func TestMatchProfileName(t *testing.T) {
+	names := []string{"client-a", "client-b", "oss", "personal", "work"}
+	cases := map[string]profileMatch{
+		"wo":       {Prefix: "work"},
+		"Pers":     {Prefix: "personal"},
+		"client":   {Ambiguous: []string{"client-a", "client-b"}},
+		"wrk":      {Suggested: "work"},
+		"persnoal": {Suggested: "personal"},
+		"os":       {Prefix: "oss"},
+		"osx":      {Suggested: "oss"},
+		"client-c": {},
+		"xyz":      {},
+	}
+	for input, expected := range cases {
+		got := matchProfileName(names, input)
+		if got.Prefix != expected.Prefix || got.Suggested != expected.Suggested ||
+			strings.Join(got.Ambiguous, ",") != strings.Join(expected.Ambiguous, ",") {
+			t.Errorf("matchProfileName(%q) = %+v, expected %+v", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// GitConfigBackend reads and writes git config. scope is "local",
+// "global", or "" for the merged config git sees; dir is the directory
+// git would run in ("" for the current one)
+type GitConfigBackend interface {
+	// GetAll returns every value of key, none when it is unset
+	GetAll(dir, scope, key string) ([]string, error)
+	// List returns every value of scope by canonical key
+	List(dir, scope string) (scopeConfig, error)
+	// Set replaces all values of key with value
+	Set(dir, scope, key, value string) error
+	// Add adds a value to key
+	Add(dir, scope, key, value string) error
+	// Unset removes the values of key equal to value, or all of them when
+	// value is empty. Nothing to remove isn't an error
+	Unset(dir, scope, key, value string) error
+	// WorkTree returns the top of the work tree dir is in
+	WorkTree(dir string) (string, error)
+}
+
+// gitConfig is the backend all git config goes through
+var gitConfig = selectGitConfigBackend()
+
+// selectGitConfigBackend runs git when it is installed and otherwise
+// edits the config files itself. GIT_USR_GIT_BACKEND=exec|go picks one
+func selectGitConfigBackend() GitConfigBackend {
+	switch os.Getenv("GIT_USR_GIT_BACKEND") {
+	case "exec":
+		return execConfigBackend{}
+	case "go":
+		return fileConfigBackend{}
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return fileConfigBackend{}
+	}
+	return execConfigBackend{}
+}
+
+// gitConfigValue returns the value of a single-valued key, the last one
+// set, or "" when it is unset or can't be read
+func gitConfigValue(dir, scope, key string) string {
+	values, err := gitConfig.GetAll(dir, scope, key)
+	if err != nil {
+		return ""
+	}
+	return lastValue(values)
+}
+
+// execConfigBackend runs git config
+type execConfigBackend struct{}
+
+// configArgs returns the arguments of git config for scope
+func configArgs(scope string, args ...string) []string {
+	if scope == "" {
+		return append([]string{"config"}, args...)
+	}
+	return append([]string{"config", "--" + scope}, args...)
+}
+
+// isConfigExit reports whether git config exited with code, which it uses
+// for "key not found" (1) and "nothing to unset" (5)
+func isConfigExit(err error, code int) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == code
+}
+
+func (execConfigBackend) GetAll(dir, scope, key string) ([]string, error) {
+	out, err := runGit(dir, configArgs(scope, "--null", "--get-all", key)...)
+	if isConfigExit(err, 1) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(out, "\x00"), "\x00"), nil
+}
+
+func (execConfigBackend) List(dir, scope string) (scopeConfig, error) {
+	out, err := runGit(dir, configArgs(scope, "--list", "--null")...)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigList(out), nil
+}
+
+func (execConfigBackend) Set(dir, scope, key, value string) error {
+	if _, err := runGit(dir, configArgs(scope, "--replace-all", key, value)...); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+func (execConfigBackend) Add(dir, scope, key, value string) error {
+	if _, err := runGit(dir, configArgs(scope, "--add", key, value)...); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+func (execConfigBackend) Unset(dir, scope, key, value string) error {
+	args := configArgs(scope, "--unset-all", key)
+	if value != "" {
+		args = append(args, "^"+regexp.QuoteMeta(value)+"$")
+	}
+	if _, err := runGit(dir, args...); err != nil && !isConfigExit(err, 5) {
+		return fmt.Errorf("failed to unset %s: %w", key, err)
+	}
+	return nil
+}
+
+func (execConfigBackend) WorkTree(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if isUnsafeRepository(err) {
+		return "", err
+	}
+	if err != nil || strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("not a git repository")
+	}
+	return filepath.Abs(strings.TrimSpace(out))
+}
+
+// configTransaction changes git config keys of one scope, remembering
+// their values before the first change so a failure partway can put them
+// all back
+type configTransaction struct {
+	dir, scope string
+	// saved holds the keys changed so far. A write that failed changed
+	// nothing to put back
+	saved []savedConfigValues
+}
+
+// savedConfigValues are the values a key had before a transaction changed
+// it, none when it was unset
+type savedConfigValues struct {
+	key    string
+	values []string
+}
+
+// beginConfigTransaction starts a transaction on scope of the repository
+// in dir
+func beginConfigTransaction(dir, scope string) *configTransaction {
+	return &configTransaction{dir: dir, scope: scope}
+}
+
+// changed reports whether key was already changed
+func (t *configTransaction) changed(key string) bool {
+	for _, saved := range t.saved {
+		if saved.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Set replaces all values of key with value
+func (t *configTransaction) Set(key, value string) error {
+	if t.changed(key) {
+		return gitConfig.Set(t.dir, t.scope, key, value)
+	}
+	values, err := gitConfig.GetAll(t.dir, t.scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if err := gitConfig.Set(t.dir, t.scope, key, value); err != nil {
+		return err
+	}
+	t.saved = append(t.saved, savedConfigValues{key: key, values: values})
+	return nil
+}
+
+// Rollback restores every key changed, the last first. It tries them all
+// and returns what couldn't be restored
+func (t *configTransaction) Rollback() error {
+	var errs []error
+	for i := len(t.saved) - 1; i >= 0; i-- {
+		saved := t.saved[i]
+		if err := gitConfig.Unset(t.dir, t.scope, saved.key, ""); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, value := range saved.values {
+			if err := gitConfig.Add(t.dir, t.scope, saved.key, value); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	t.saved = nil
+	return errors.Join(errs...)
+}
+
+// Fail rolls the transaction back after err and returns a
+// *configTransactionError telling whether that worked, or err itself when
+// nothing had been changed yet
+func (t *configTransaction) Fail(err error) error {
+	if len(t.saved) == 0 {
+		return err
+	}
+	keys := make([]string, len(t.saved))
+	for i, saved := range t.saved {
+		keys[i] = saved.key
+	}
+	return &configTransactionError{Err: err, Keys: keys, RollbackErr: t.Rollback()}
+}
+
+// configTransactionError is a transaction that failed after changing
+// Keys: Err is why, and RollbackErr is set when they couldn't all be
+// restored, leaving the scope half changed
+type configTransactionError struct {
+	Err         error
+	Keys        []string
+	RollbackErr error
+}
+
+func (e *configTransactionError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%v, and restoring %s failed: %v", e.Err, strings.Join(e.Keys, ", "), e.RollbackErr)
+	}
+	return e.Err.Error()
+}
+
+func (e *configTransactionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// failingSetBackend fails to set one key
+type failingSetBackend struct {
+	GitConfigBackend
+	key string
+}
+
+func (b failingSetBackend) Set(dir, scope, key, value string) error {
+	if key == b.key {
+		return errors.New("disk full")
+	}
+	return b.GitConfigBackend.Set(dir, scope, key, value)
+}
+
+// TestSetGitConfigRollback tests that a failed email leaves the name as it
+// was, including when it was unset
+func TestSetGitConfigRollback(t *testing.T) {
+	setupConfigHome(t)
+	previous := gitConfig
+	t.Cleanup(func() { gitConfig = previous })
+	gitConfig = fileConfigBackend{}
+
+	if err := setGitConfig("Jane Doe", "jane@home.example", "global"); err != nil {
+		t.Fatal(err)
+	}
+
+	gitConfig = failingSetBackend{GitConfigBackend: fileConfigBackend{}, key: "user.email"}
+	err := setGitConfig("Jane Work", "jane@acme.example", "global")
+	var txErr *configTransactionError
+	if !errors.As(err, &txErr) || txErr.RollbackErr != nil || len(txErr.Keys) != 1 || txErr.Keys[0] != "user.name" {
+		t.Fatalf("setGitConfig() error = %#v, expected user.name rolled back", err)
+	}
+	if name := gitConfigValue("", "global", "user.name"); name != "Jane Doe" {
+		t.Errorf("user.name = %q after the rollback, expected Jane Doe", name)
+	}
+
+	// A key that was unset is unset again
+	gitConfig = failingSetBackend{GitConfigBackend: fileConfigBackend{}, key: "user.email"}
+	tx := beginConfigTransaction("", "global")
+	if err := tx.Set("user.signingkey", "ABCD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := gitConfig.GetAll("", "global", "user.signingkey"); len(values) != 0 {
+		t.Errorf("user.signingkey = %v after the rollback, expected it unset", values)
+	}
+
+	// A failure before anything changed has nothing to roll back
+	gitConfig = failingSetBackend{GitConfigBackend: fileConfigBackend{}, key: "user.name"}
+	if err := setGitConfig("Jane Work", "jane@acme.example", "global"); errors.As(err, &txErr) || err == nil {
+		t.Errorf("setGitConfig() error = %v, expected the plain error", err)
+	}
+}
@@ -0,0 +1,931 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fileConfigBackend reads and writes git config files itself, for systems
+// without git. It reads the system, global and repository files like git
+// does, following include.path and includeIf (gitdir, gitdir/i and
+// onbranch; hasconfig conditions never match). Edits keep the rest of a
+// file, comments included, as it is. Like git, it refuses repositories
+// owned by other users unless they are listed in safe.directory
+type fileConfigBackend struct{}
+
+// errNotRepository is returned for the local scope outside a repository
+var errNotRepository = errors.New("not a git repository")
+
+// maxConfigIncludeDepth is how deep includes may nest, as in git
+const maxConfigIncludeDepth = 10
+
+// configVariable is a variable of a config file
+type configVariable struct {
+	// Key is the canonical key
+	Key   string
+	Value string
+	// Start and End are the bytes the variable takes up: its whole
+	// line(s) when it is on a line of its own
+	Start, End int
+}
+
+// configSection is a section of a config file
+type configSection struct {
+	// Section is lowercased; Subsection is as written
+	Section       string
+	Subsection    string
+	HasSubsection bool
+	Start         int
+	HeaderEnd     int
+	// VarsEnd is where a variable added to the section goes: after its
+	// last variable, or its header
+	VarsEnd int
+	End     int
+	Vars    int
+}
+
+// configFile is a parsed config file
+type configFile struct {
+	Path     string
+	Data     []byte
+	Vars     []configVariable
+	Sections []configSection
+}
+
+// isConfigNameChar reports whether c may appear in a section or variable name
+func isConfigNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// lineBegin returns the start of the line holding data[i]
+func lineBegin(data []byte, i int) int {
+	return bytes.LastIndexByte(data[:i], '\n') + 1
+}
+
+// skipLine returns the index after the end of the line holding data[i]
+func skipLine(data []byte, i int) int {
+	if end := bytes.IndexByte(data[i:], '\n'); end >= 0 {
+		return i + end + 1
+	}
+	return len(data)
+}
+
+// parseConfigFile parses the contents of a config file
+func parseConfigFile(path string, data []byte) (*configFile, error) {
+	f := &configFile{Path: path, Data: data}
+	current := -1
+	i := 0
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		i = 3
+	}
+	for i < len(data) {
+		bad := func() error {
+			return fmt.Errorf("bad config line %d in file %s", bytes.Count(data[:i], []byte("\n"))+1, path)
+		}
+		switch c := data[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '#' || c == ';':
+			i = skipLine(data, i)
+		case c == '[':
+			section, end, ok := parseSectionHeader(data, i)
+			if !ok {
+				return nil, bad()
+			}
+			if current >= 0 {
+				f.Sections[current].End = lineBegin(data, i)
+			}
+			section.Start, section.HeaderEnd, section.VarsEnd = lineBegin(data, i), end, end
+			// The header's own line, unless a variable follows on it
+			j := end
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\r') {
+				j++
+			}
+			if j == len(data) || data[j] == '\n' || data[j] == '#' || data[j] == ';' {
+				section.VarsEnd = skipLine(data, j)
+				j = section.VarsEnd
+			}
+			f.Sections = append(f.Sections, section)
+			current = len(f.Sections) - 1
+			i = j
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			if current < 0 {
+				return nil, bad()
+			}
+			j := i
+			for j < len(data) && isConfigNameChar(data[j]) {
+				j++
+			}
+			name := strings.ToLower(string(data[i:j]))
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t') {
+				j++
+			}
+			value := ""
+			switch {
+			case j < len(data) && data[j] == '=':
+				var err error
+				if value, j, err = parseConfigValue(data, j+1); err != nil {
+					return nil, fmt.Errorf("%w: %v", bad(), err)
+				}
+			case j == len(data) || data[j] == '\n' || data[j] == '\r' || data[j] == '#' || data[j] == ';':
+				// A name without a value is boolean true
+				j = skipLine(data, j)
+			default:
+				return nil, bad()
+			}
+
+			start := i
+			if strings.TrimSpace(string(data[lineBegin(data, i):i])) == "" {
+				start = lineBegin(data, i)
+			}
+			section := &f.Sections[current]
+			key := section.Section + "." + name
+			if section.HasSubsection {
+				key = section.Section + "." + section.Subsection + "." + name
+			}
+			f.Vars = append(f.Vars, configVariable{Key: key, Value: value, Start: start, End: j})
+			section.VarsEnd = j
+			section.Vars++
+			i = j
+		default:
+			return nil, bad()
+		}
+	}
+	if current >= 0 {
+		f.Sections[current].End = len(data)
+	}
+	return f, nil
+}
+
+// parseSectionHeader parses [section], [section "subsection"] or the
+// legacy [section.subsection] at data[i], returning the index after it
+func parseSectionHeader(data []byte, i int) (configSection, int, bool) {
+	j := i + 1
+	for j < len(data) && (isConfigNameChar(data[j]) || data[j] == '.') {
+		j++
+	}
+	name := string(data[i+1 : j])
+	if name == "" || j == len(data) {
+		return configSection{}, 0, false
+	}
+
+	if data[j] == ']' {
+		section := configSection{Section: strings.ToLower(name)}
+		if dot := strings.Index(name, "."); dot >= 0 {
+			section = configSection{Section: strings.ToLower(name[:dot]), Subsection: strings.ToLower(name[dot+1:]), HasSubsection: true}
+		}
+		return section, j + 1, true
+	}
+	if strings.Contains(name, ".") {
+		return configSection{}, 0, false
+	}
+
+	for j < len(data) && (data[j] == ' ' || data[j] == '\t') {
+		j++
+	}
+	if j == len(data) || data[j] != '"' {
+		return configSection{}, 0, false
+	}
+	var subsection strings.Builder
+	for j++; j < len(data) && data[j] != '"'; j++ {
+		switch data[j] {
+		case '\n':
+			return configSection{}, 0, false
+		case '\\':
+			if j++; j == len(data) || data[j] == '\n' {
+				return configSection{}, 0, false
+			}
+		}
+		subsection.WriteByte(data[j])
+	}
+	if j+1 >= len(data) || data[j+1] != ']' {
+		return configSection{}, 0, false
+	}
+	return configSection{Section: strings.ToLower(name), Subsection: subsection.String(), HasSubsection: true}, j + 2, true
+}
+
+// parseConfigValue parses the value starting at data[i], returning it and
+// the index after its line. Whitespace around the value is dropped unless
+// quoted, and a backslash at the end of a line continues it
+func parseConfigValue(data []byte, i int) (string, int, error) {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	var value strings.Builder
+	pending := ""
+	quoted := false
+	write := func(s string) {
+		value.WriteString(pending)
+		pending = ""
+		value.WriteString(s)
+	}
+	for ; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '\n':
+			if quoted {
+				return "", 0, fmt.Errorf("unterminated quote")
+			}
+			return value.String(), i + 1, nil
+		case c == '\r' && i+1 < len(data) && data[i+1] == '\n':
+		case !quoted && (c == '#' || c == ';'):
+			return value.String(), skipLine(data, i), nil
+		case !quoted && (c == ' ' || c == '\t'):
+			pending += string(c)
+		case c == '"':
+			value.WriteString(pending)
+			pending = ""
+			quoted = !quoted
+		case c == '\\':
+			if i++; i == len(data) {
+				return "", 0, fmt.Errorf("bad escape")
+			}
+			switch data[i] {
+			case '\n':
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case 'n':
+				write("\n")
+			case 't':
+				write("\t")
+			case 'b':
+				write("\b")
+			case '\\', '"':
+				write(string(data[i]))
+			default:
+				return "", 0, fmt.Errorf("bad escape \\%c", data[i])
+			}
+		default:
+			write(string(c))
+		}
+	}
+	if quoted {
+		return "", 0, fmt.Errorf("unterminated quote")
+	}
+	return value.String(), i, nil
+}
+
+// splitConfigKey splits a key into its section, subsection and name,
+// checking that git would accept it
+func splitConfigKey(key string) (string, string, bool, string, error) {
+	first, last := strings.Index(key, "."), strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", false, "", fmt.Errorf("key does not contain a section: %s", key)
+	}
+	section, name := key[:first], key[last+1:]
+	for i := 0; i < len(section); i++ {
+		if !isConfigNameChar(section[i]) {
+			return "", "", false, "", fmt.Errorf("invalid key: %s", key)
+		}
+	}
+	for i := 0; i < len(name); i++ {
+		if !isConfigNameChar(name[i]) || i == 0 && !(name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z') {
+			return "", "", false, "", fmt.Errorf("invalid key: %s", key)
+		}
+	}
+	if first == last {
+		return section, "", false, name, nil
+	}
+	subsection := key[first+1 : last]
+	if strings.Contains(subsection, "\n") {
+		return "", "", false, "", fmt.Errorf("invalid key: %s", key)
+	}
+	return section, subsection, true, name, nil
+}
+
+// formatConfigValue quotes and escapes a value as git writes it
+func formatConfigValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\b", `\b`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
+
+// formatConfigSection returns the header of a section
+func formatConfigSection(section, subsection string, hasSubsection bool) string {
+	if !hasSubsection {
+		return "[" + section + "]"
+	}
+	return "[" + section + ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection) + `"]`
+}
+
+// configEdit replaces data[Start:End] with Text
+type configEdit struct {
+	Start, End int
+	Text       string
+}
+
+// applyConfigEdits returns data with non-overlapping edits applied
+func applyConfigEdits(data []byte, edits []configEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start > edits[j].Start })
+	out := append([]byte{}, data...)
+	for _, edit := range edits {
+		out = append(out[:edit.Start], append([]byte(edit.Text), out[edit.End:]...)...)
+	}
+	return out
+}
+
+// addVariable returns the edit adding key=value to the last section it
+// belongs in, or a new section at the end
+func (f *configFile) addVariable(key, value string) (configEdit, error) {
+	section, subsection, hasSubsection, name, err := splitConfigKey(key)
+	if err != nil {
+		return configEdit{}, err
+	}
+	line := "\t" + name + " = " + formatConfigValue(value) + "\n"
+	for i := len(f.Sections) - 1; i >= 0; i-- {
+		s := f.Sections[i]
+		if s.Section == strings.ToLower(section) && s.HasSubsection == hasSubsection && s.Subsection == subsection {
+			if s.VarsEnd > 0 && f.Data[s.VarsEnd-1] != '\n' {
+				line = "\n" + line
+			}
+			return configEdit{s.VarsEnd, s.VarsEnd, line}, nil
+		}
+	}
+	text := formatConfigSection(section, subsection, hasSubsection) + "\n" + line
+	if len(f.Data) > 0 && f.Data[len(f.Data)-1] != '\n' {
+		text = "\n" + text
+	}
+	return configEdit{len(f.Data), len(f.Data), text}, nil
+}
+
+// matching returns the variables of key, all of them or those equal to value
+func (f *configFile) matching(key, value string) []configVariable {
+	var vars []configVariable
+	for _, v := range f.Vars {
+		if v.Key == canonicalConfigKey(key) && (value == "" || v.Value == value) {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+// editConfigFile changes the config file at path under a lock, as git
+// does: the new contents are written to path.lock, which replaces it.
+// edit returns nil to leave the file alone
+func editConfigFile(path string, edit func(f *configFile) ([]byte, error)) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	lockPath := path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("could not lock config file %s: %w", path, err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			lock.Close()
+			os.Remove(lockPath)
+		}
+	}()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f, err := parseConfigFile(path, data)
+	if err != nil {
+		return err
+	}
+	out, err := edit(f)
+	if err != nil || out == nil {
+		return err
+	}
+
+	if _, err := lock.Write(out); err != nil {
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(lockPath, path); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// findRepository returns the git directory of the repository dir is in,
+// and its work tree, which is "" inside the git directory or a bare
+// repository
+func findRepository(dir string) (string, string, error) {
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		gitDir, err := filepath.Abs(gitDir)
+		if err != nil {
+			return "", "", err
+		}
+		workTree := os.Getenv("GIT_WORK_TREE")
+		if workTree == "" && filepath.Base(gitDir) == ".git" {
+			workTree = filepath.Dir(gitDir)
+		}
+		if workTree != "" {
+			workTree, err = filepath.Abs(workTree)
+		}
+		return gitDir, workTree, err
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() && isGitDir(dotGit) {
+				return dotGit, dir, checkOwnership(dir, dotGit)
+			}
+			// Worktrees and submodules point at their git directory
+			if data, err := os.ReadFile(dotGit); err == nil && strings.HasPrefix(string(data), "gitdir:") {
+				gitDir := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+				gitDir = filepath.Clean(gitDir)
+				return gitDir, dir, checkOwnership(dir, dotGit, gitDir)
+			}
+		}
+		if isGitDir(dir) {
+			return dir, "", checkOwnership(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errNotRepository
+		}
+		dir = parent
+	}
+}
+
+// fileOwner returns the uid owning path, false on systems without one
+func fileOwner(path string) (int, bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, false
+	}
+	// os.FileInfo only exposes the owner through the platform's stat
+	// struct, which has a Uid field everywhere but on Windows
+	sys := reflect.Indirect(reflect.ValueOf(info.Sys()))
+	if sys.Kind() != reflect.Struct {
+		return 0, false
+	}
+	uid := sys.FieldByName("Uid")
+	if !uid.IsValid() || !uid.CanUint() {
+		return 0, false
+	}
+	return int(uid.Uint()), true
+}
+
+// checkOwnership returns an UnsafeRepositoryError when the repository at
+// top isn't owned by the current user and isn't listed in safe.directory,
+// as git does (CVE-2022-24765). paths are its .git file and git directory
+func checkOwnership(top string, paths ...string) error {
+	uid := os.Geteuid()
+	if uid < 0 {
+		return nil
+	}
+	// git trusts the user running sudo with their own repositories
+	if sudoUID, err := strconv.Atoi(os.Getenv("SUDO_UID")); uid == 0 && err == nil {
+		uid = sudoUID
+	}
+	owned := true
+	for _, path := range append([]string{top}, paths...) {
+		if owner, ok := fileOwner(path); ok && owner != uid {
+			owned = false
+		}
+	}
+	if owned || safeDirectory(top) {
+		return nil
+	}
+	return &UnsafeRepositoryError{Path: top}
+}
+
+// safeDirectory reports whether safe.directory in the system, global or
+// command line config trusts the repository at top. An empty value clears
+// the earlier ones
+func safeDirectory(top string) bool {
+	r := &configReader{config: scopeConfig{}, includes: true}
+	if system := systemConfigPath(); system != "" {
+		r.read(system, 0)
+	}
+	if global, _, err := globalConfigPaths(); err == nil {
+		for _, path := range global {
+			r.read(path, 0)
+		}
+	}
+	configEnvValues(r.config)
+
+	safe := false
+	for _, value := range r.config["safe.directory"] {
+		switch {
+		case value == "":
+			safe = false
+		case value == "*":
+			safe = true
+		case strings.HasSuffix(value, "/*"):
+			prefix, err := expandHome(strings.TrimSuffix(value, "*"))
+			if err == nil && strings.HasPrefix(filepath.ToSlash(top)+"/", filepath.ToSlash(prefix)) {
+				safe = true
+			}
+		default:
+			path, err := expandHome(value)
+			if err == nil && filepath.Clean(path) == top {
+				safe = true
+			}
+		}
+	}
+	return safe
+}
+
+// isGitDir reports whether dir looks like a git directory
+func isGitDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil || info.IsDir() {
+		return false
+	}
+	for _, name := range []string{"objects", "commondir"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// localConfigPath returns the config file of a repository, which linked
+// worktrees share with the main one
+func localConfigPath(gitDir string) string {
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		return filepath.Join(filepath.Clean(common), "config")
+	}
+	return filepath.Join(gitDir, "config")
+}
+
+// globalConfigPaths returns the global config files in the order git
+// reads them, and the one it writes to
+func globalConfigPaths() ([]string, string, error) {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}, path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, "", err
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	xdgPath, homePath := filepath.Join(xdg, "git", "config"), filepath.Join(home, ".gitconfig")
+
+	write := homePath
+	if _, err := os.Stat(homePath); err != nil {
+		if _, err := os.Stat(xdgPath); err == nil {
+			write = xdgPath
+		}
+	}
+	return []string{xdgPath, homePath}, write, nil
+}
+
+// systemConfigPath returns the system config file, "" when it is skipped
+func systemConfigPath() string {
+	if skip, _ := strconv.ParseBool(os.Getenv("GIT_CONFIG_NOSYSTEM")); skip {
+		return ""
+	}
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/gitconfig"
+}
+
+// configReader collects the values of config files
+type configReader struct {
+	config scopeConfig
+	// includes is set when reading the merged config; git ignores
+	// includes when asked for a single scope
+	includes bool
+	gitDir   string
+}
+
+// read adds the values of the file at path. A missing file has none
+func (r *configReader) read(path string, depth int) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f, err := parseConfigFile(path, data)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range f.Vars {
+		r.config[v.Key] = append(r.config[v.Key], v.Value)
+		if !r.includes || !strings.HasSuffix(v.Key, ".path") {
+			continue
+		}
+		include := v.Key == "include.path"
+		if condition, ok := strings.CutPrefix(strings.TrimSuffix(v.Key, ".path"), "includeif."); ok {
+			include = r.conditionMatches(condition, path)
+		}
+		if !include || v.Value == "" {
+			continue
+		}
+		if depth >= maxConfigIncludeDepth {
+			return fmt.Errorf("exceeded maximum include depth (%d) in %s", maxConfigIncludeDepth, path)
+		}
+		included, err := expandHome(v.Value)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(included) {
+			included = filepath.Join(filepath.Dir(path), included)
+		}
+		if err := r.read(included, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conditionMatches evaluates the condition of an includeIf section in
+// the file at path
+func (r *configReader) conditionMatches(condition, path string) bool {
+	kind, pattern, _ := strings.Cut(condition, ":")
+	switch kind {
+	case "gitdir", "gitdir/i":
+		if r.gitDir == "" {
+			return false
+		}
+		// Joining paths drops the trailing / that matches everything below
+		dirOnly := strings.HasSuffix(pattern, "/")
+		switch {
+		case strings.HasPrefix(pattern, "~/"):
+			pattern, _ = expandHome(pattern)
+		case strings.HasPrefix(pattern, "./"):
+			pattern = filepath.Join(filepath.Dir(path), pattern[2:])
+		case !strings.HasPrefix(pattern, "/") && !filepath.IsAbs(pattern):
+			pattern = "**/" + pattern
+		}
+		if dirOnly && !strings.HasSuffix(pattern, "/") {
+			pattern += "/"
+		}
+		candidates := []string{r.gitDir}
+		if real, err := filepath.EvalSymlinks(r.gitDir); err == nil {
+			candidates = append(candidates, real)
+		}
+		for _, candidate := range candidates {
+			if gitGlobMatch(filepath.ToSlash(pattern), filepath.ToSlash(candidate), kind == "gitdir/i") {
+				return true
+			}
+		}
+	case "onbranch":
+		if r.gitDir == "" {
+			return false
+		}
+		head, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+		if branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/"); err == nil && ok {
+			return gitGlobMatch(pattern, branch, false)
+		}
+	}
+	return false
+}
+
+// gitGlobMatch matches a wildmatch pattern as includeIf does: * and ?
+// stay within a path component, ** crosses them, and a trailing / matches
+// everything below
+func gitGlobMatch(pattern, name string, fold bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	var expr strings.Builder
+	if fold {
+		expr.WriteString("(?i)")
+	}
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			switch {
+			case strings.HasPrefix(pattern[i:], "**/"):
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(pattern[i:], "**"):
+				expr.WriteString(".*")
+				i++
+			default:
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	return err == nil && re.MatchString(name)
+}
+
+// configEnvValues adds the values of GIT_CONFIG_COUNT/KEY_n/VALUE_n, which
+// git applies on top of the config files
+func configEnvValues(config scopeConfig) {
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for i := 0; i < count; i++ {
+		key := os.Getenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+		if key != "" {
+			config[canonicalConfigKey(key)] = append(config[canonicalConfigKey(key)], os.Getenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i)))
+		}
+	}
+}
+
+func (fileConfigBackend) List(dir, scope string) (scopeConfig, error) {
+	gitDir, _, repoErr := findRepository(dir)
+	r := &configReader{config: scopeConfig{}, includes: scope == "", gitDir: gitDir}
+
+	var paths []string
+	switch scope {
+	case "", "global":
+		if scope == "" {
+			if system := systemConfigPath(); system != "" {
+				paths = append(paths, system)
+			}
+		}
+		global, _, err := globalConfigPaths()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, global...)
+		if scope == "" && repoErr == nil {
+			paths = append(paths, localConfigPath(gitDir))
+		}
+	case "local":
+		if repoErr != nil {
+			return nil, repoErr
+		}
+		paths = append(paths, localConfigPath(gitDir))
+	default:
+		return nil, fmt.Errorf("unknown config scope: %s", scope)
+	}
+
+	for _, path := range paths {
+		if err := r.read(path, 0); err != nil {
+			return nil, err
+		}
+	}
+	if scope == "" {
+		configEnvValues(r.config)
+	}
+	return r.config, nil
+}
+
+func (b fileConfigBackend) GetAll(dir, scope, key string) ([]string, error) {
+	config, err := b.List(dir, scope)
+	if err != nil {
+		return nil, err
+	}
+	return config[canonicalConfigKey(key)], nil
+}
+
+// writePath returns the file git writes scope to
+func (fileConfigBackend) writePath(dir, scope string) (string, error) {
+	switch scope {
+	case "global":
+		_, path, err := globalConfigPaths()
+		return path, err
+	case "local", "":
+		gitDir, _, err := findRepository(dir)
+		if err != nil {
+			return "", err
+		}
+		return localConfigPath(gitDir), nil
+	}
+	return "", fmt.Errorf("unknown config scope: %s", scope)
+}
+
+func (b fileConfigBackend) Set(dir, scope, key, value string) error {
+	path, err := b.writePath(dir, scope)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return editConfigFile(path, func(f *configFile) ([]byte, error) {
+		existing := f.matching(key, "")
+		if len(existing) == 0 {
+			edit, err := f.addVariable(key, value)
+			if err != nil {
+				return nil, err
+			}
+			return applyConfigEdits(f.Data, []configEdit{edit}), nil
+		}
+
+		_, _, _, name, err := splitConfigKey(key)
+		if err != nil {
+			return nil, err
+		}
+		first := existing[0]
+		edits := []configEdit{{first.Start, first.End, "\t" + name + " = " + formatConfigValue(value) + "\n"}}
+		for _, v := range existing[1:] {
+			edits = append(edits, configEdit{v.Start, v.End, ""})
+		}
+		return applyConfigEdits(f.Data, edits), nil
+	})
+}
+
+func (b fileConfigBackend) Add(dir, scope, key, value string) error {
+	path, err := b.writePath(dir, scope)
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return editConfigFile(path, func(f *configFile) ([]byte, error) {
+		edit, err := f.addVariable(key, value)
+		if err != nil {
+			return nil, err
+		}
+		return applyConfigEdits(f.Data, []configEdit{edit}), nil
+	})
+}
+
+func (b fileConfigBackend) Unset(dir, scope, key, value string) error {
+	path, err := b.writePath(dir, scope)
+	if err != nil {
+		return fmt.Errorf("failed to unset %s: %w", key, err)
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return editConfigFile(path, func(f *configFile) ([]byte, error) {
+		var edits []configEdit
+		for _, v := range f.matching(key, value) {
+			edits = append(edits, configEdit{v.Start, v.End, ""})
+		}
+		if len(edits) == 0 {
+			return nil, nil
+		}
+		data := applyConfigEdits(f.Data, edits)
+
+		// Drop the sections that are left without variables or comments
+		section, subsection, hasSubsection, _, err := splitConfigKey(key)
+		if err != nil {
+			return nil, err
+		}
+		left, err := parseConfigFile(path, data)
+		if err != nil {
+			return nil, err
+		}
+		edits = nil
+		for _, s := range left.Sections {
+			if s.Vars == 0 && s.Section == strings.ToLower(section) && s.HasSubsection == hasSubsection && s.Subsection == subsection &&
+				strings.TrimSpace(string(data[s.HeaderEnd:s.End])) == "" {
+				edits = append(edits, configEdit{s.Start, s.End, ""})
+			}
+		}
+		return applyConfigEdits(data, edits), nil
+	})
+}
+
+func (fileConfigBackend) WorkTree(dir string) (string, error) {
+	_, workTree, err := findRepository(dir)
+	if isUnsafeRepository(err) {
+		return "", err
+	}
+	if err != nil || workTree == "" {
+		return "", errNotRepository
+	}
+	return workTree, nil
+}
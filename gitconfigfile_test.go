@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setupConfigHome points the global config at a fresh home and skips the
+// system config
+func setupConfigHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	// git refuses some of these when set but empty
+	for _, name := range []string{"GIT_CONFIG_GLOBAL", "GIT_CONFIG_COUNT", "GIT_DIR", "GIT_WORK_TREE"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	return home
+}
+
+// makeGitDir creates the minimal git directory of a repository at top
+func makeGitDir(t *testing.T, top, branch string) string {
+	gitDir := filepath.Join(top, ".git")
+	if err := os.MkdirAll(filepath.Join(gitDir, "objects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/"+branch+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return gitDir
+}
+
+// TestParseConfigFile tests reading values the way git does: quotes,
+// escapes, comments, continuations and case-insensitive names
+func TestParseConfigFile(t *testing.T) {
+	data := "# comment\n[User]\n\tName = Jane Doe ; who\n\temail=\"jane@acme.io\"\n[url \"git@Work:\"]\n\tinsteadOf = git@github.com:\n" +
+		"[core.Pager]\n\tflag\n[alias]\n\tlg = log \\\n  --oneline\n\tq = \"a \\\"b\\\"\\t#c\" # d\n[x]\tk = 1\n"
+	f, err := parseConfigFile("config", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, v := range f.Vars {
+		got[v.Key] = v.Value
+	}
+	expected := map[string]string{
+		"user.name":               "Jane Doe",
+		"user.email":              "jane@acme.io",
+		"url.git@Work:.insteadof": "git@github.com:",
+		"core.pager.flag":         "",
+		"alias.lg":                "log   --oneline",
+		"alias.q":                 "a \"b\"\t#c",
+		"x.k":                     "1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseConfigFile = %q, expected %q", got, expected)
+	}
+
+	for _, bad := range []string{"name = x\n", "[user\n", "[user]\nname = \"x\n", "[user]\n=x\n", "[user]\nname = \\q\n"} {
+		if _, err := parseConfigFile("config", []byte(bad)); err == nil {
+			t.Errorf("parseConfigFile(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestFileConfigBackendEdits tests that Set, Add and Unset change only the
+// lines of the key and keep everything else as written
+func TestFileConfigBackendEdits(t *testing.T) {
+	home := setupConfigHome(t)
+	path := filepath.Join(home, ".gitconfig")
+	original := "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Old\n\tname = Older\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b := fileConfigBackend{}
+
+	steps := []struct {
+		edit     func() error
+		expected string
+	}{
+		{func() error { return b.Set("", "global", "user.name", "Jane Doe") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n"},
+		{func() error { return b.Set("", "global", "user.email", " jane@acme.io") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n\temail = \" jane@acme.io\"\n"},
+		{func() error { return b.Add("", "global", `url.git@Work:.insteadOf`, "git@github.com:") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n\temail = \" jane@acme.io\"\n[url \"git@Work:\"]\n\tinsteadOf = git@github.com:\n"},
+		{func() error { return b.Unset("", "global", "url.git@work:.insteadof", "git@github.com:") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n\temail = \" jane@acme.io\"\n[url \"git@Work:\"]\n\tinsteadOf = git@github.com:\n"},
+		{func() error { return b.Unset("", "global", "url.git@Work:.insteadof", "git@github.com:") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n\temail = \" jane@acme.io\"\n"},
+		{func() error { return b.Unset("", "global", "user.email", "") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n"},
+		{func() error { return b.Unset("", "global", "user.signingkey", "") }, "# mine\n[core]\n\teditor = vim ; keep\n[user]\n\tname = Jane Doe\n"},
+	}
+	for i, step := range steps {
+		if err := step.edit(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != step.expected {
+			t.Fatalf("step %d: got\n%s\nexpected\n%s", i, data, step.expected)
+		}
+	}
+	if values, err := b.GetAll("", "global", "User.Name"); err != nil || !reflect.DeepEqual(values, []string{"Jane Doe"}) {
+		t.Errorf("GetAll = %q (%v)", values, err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 && os.PathSeparator == '/' {
+		t.Errorf("permissions changed to %v", info.Mode().Perm())
+	}
+
+	if err := b.Set("", "global", "nosection", "x"); err == nil {
+		t.Error("Set accepted a key without a section")
+	}
+}
+
+// TestFileConfigBackendRepository tests finding the repository of a
+// directory, linked worktrees sharing its config, and includes
+func TestFileConfigBackendRepository(t *testing.T) {
+	home := setupConfigHome(t)
+	top := filepath.Join(home, "src", "acme", "app")
+	gitDir := makeGitDir(t, top, "main")
+	nested := filepath.Join(top, "pkg", "sub")
+	os.MkdirAll(nested, 0755)
+
+	os.WriteFile(filepath.Join(home, "work.inc"), []byte("[user]\n\temail = jane@acme.io\n"), 0644)
+	os.WriteFile(filepath.Join(home, "main.inc"), []byte("[core]\n\tsshCommand = ssh -i main\n"), 0644)
+	os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Jane Doe\n\temail = jane@home.dev\n"+
+		"[includeIf \"gitdir:~/src/acme/\"]\n\tpath = work.inc\n[includeIf \"onbranch:ma*\"]\n\tpath = ~/main.inc\n"), 0644)
+
+	b := fileConfigBackend{}
+	if workTree, err := b.WorkTree(nested); err != nil || workTree != top {
+		t.Errorf("WorkTree = %s (%v), expected %s", workTree, err, top)
+	}
+	if _, err := b.WorkTree(home); err == nil {
+		t.Error("WorkTree outside a repository succeeded")
+	}
+	if email := lastValue(mustList(t, b, nested, "")["user.email"]); email != "jane@acme.io" {
+		t.Errorf("includeIf gitdir: got %s", email)
+	}
+	if ssh := lastValue(mustList(t, b, nested, "")["core.sshcommand"]); ssh != "ssh -i main" {
+		t.Errorf("includeIf onbranch: got %s", ssh)
+	}
+	if email := lastValue(mustList(t, b, home, "")["user.email"]); email != "jane@home.dev" {
+		t.Errorf("includeIf outside the repository: got %s", email)
+	}
+	// Includes only apply to the merged config
+	if email := lastValue(mustList(t, b, nested, "global")["user.email"]); email != "jane@home.dev" {
+		t.Errorf("global scope followed includes: got %s", email)
+	}
+
+	// A linked worktree writes to the config of the main repository
+	linked := filepath.Join(home, "linked")
+	linkedGitDir := filepath.Join(gitDir, "worktrees", "linked")
+	os.MkdirAll(linked, 0755)
+	os.MkdirAll(linkedGitDir, 0755)
+	os.WriteFile(filepath.Join(linkedGitDir, "HEAD"), []byte("ref: refs/heads/feature\n"), 0644)
+	os.WriteFile(filepath.Join(linkedGitDir, "commondir"), []byte("../..\n"), 0644)
+	os.WriteFile(filepath.Join(linked, ".git"), []byte("gitdir: "+linkedGitDir+"\n"), 0644)
+	if err := b.Set(linked, "local", "user.email", "jane@oss.dev"); err != nil {
+		t.Fatal(err)
+	}
+	if email := lastValue(mustList(t, b, nested, "local")["user.email"]); email != "jane@oss.dev" {
+		t.Errorf("linked worktree config: got %s", email)
+	}
+	if _, ok := mustList(t, b, linked, "")["core.sshcommand"]; ok {
+		t.Error("includeIf onbranch matched another branch")
+	}
+
+	if _, err := b.List(home, "local"); err == nil {
+		t.Error("local scope outside a repository succeeded")
+	}
+}
+
+func mustList(t *testing.T, b GitConfigBackend, dir, scope string) scopeConfig {
+	t.Helper()
+	config, err := b.List(dir, scope)
+	if err != nil {
+		t.Fatalf("List(%s, %q): %v", dir, scope, err)
+	}
+	return config
+}
+
+// TestGitGlobMatch tests the wildmatch subset includeIf patterns use
+func TestGitGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		fold, match   bool
+	}{
+		{"/src/**", "/src/a/b/.git", false, true},
+		{"**/acme/", "/home/j/acme/app/.git", false, true},
+		{"/src/*/.git", "/src/a/b/.git", false, false},
+		{"/src/?/.git", "/src/a/.git", false, true},
+		{"feature/*", "feature/x", false, true},
+		{"feature/*", "feature/x/y", false, false},
+		{"release-[0-9]", "release-7", false, true},
+		{"release-[!0-9]", "release-7", false, false},
+		{"/SRC/", "/src/app/.git", true, true},
+		{"/SRC/", "/src/app/.git", false, false},
+	}
+	for _, test := range tests {
+		if got := gitGlobMatch(test.pattern, test.name, test.fold); got != test.match {
+			t.Errorf("gitGlobMatch(%q, %q, %v) = %v", test.pattern, test.name, test.fold, got)
+		}
+	}
+}
+
+// TestFileConfigBackendMatchesGit tests that git reads what the file
+// backend writes, and the other way around
+func TestFileConfigBackendMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	home := setupConfigHome(t)
+	repo := filepath.Join(home, "repo")
+	if out, err := exec.Command("git", "init", "--quiet", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %s", out)
+	}
+
+	values := []string{"plain", " padded ", "semi;colon # hash", `back\slash "quoted"`, "tab\there"}
+	file, git := fileConfigBackend{}, execConfigBackend{}
+	for i, value := range values {
+		if err := file.Add(repo, "local", "test.value", value); err != nil {
+			t.Fatal(err)
+		}
+		if err := git.Add(repo, "local", "test.other", value); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Set(repo, "local", `sub.Weird "name".key`, value); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := git.GetAll(repo, "local", `sub.Weird "name".key`); !reflect.DeepEqual(got, []string{value}) {
+			t.Errorf("value %d: git read %q", i, got)
+		}
+	}
+	for _, key := range []string{"test.value", "test.other"} {
+		fromFile, _ := file.GetAll(repo, "local", key)
+		fromGit, _ := git.GetAll(repo, "local", key)
+		if !reflect.DeepEqual(fromFile, values) || !reflect.DeepEqual(fromGit, values) {
+			t.Errorf("%s: file backend read %q, git read %q", key, fromFile, fromGit)
+		}
+	}
+
+	if err := file.Unset(repo, "local", "test.value", values[2]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := git.GetAll(repo, "local", "test.value"); len(got) != len(values)-1 {
+		t.Errorf("after Unset git read %q", got)
+	}
+	fromFile, _ := file.List(repo, "local")
+	fromGit, _ := git.List(repo, "local")
+	if !reflect.DeepEqual(fromFile, fromGit) {
+		t.Errorf("List differs:\nfile: %q\ngit:  %q", fromFile, fromGit)
+	}
+	data, _ := os.ReadFile(filepath.Join(repo, ".git", "config"))
+	if strings.Count(string(data), "[test]") != 1 {
+		t.Errorf("expected the test section once, got:\n%s", data)
+	}
+}
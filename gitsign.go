@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gitsignOptionKeys are the gitsign.* settings a profile can carry, by
+// lower-case name
+var gitsignOptionKeys = map[string]string{
+	"fulcio":           "fulcio",
+	"rekor":            "rekor",
+	"issuer":           "issuer",
+	"clientid":         "clientID",
+	"redirecturl":      "redirectURL",
+	"connectorid":      "connectorID",
+	"tokenprovider":    "tokenProvider",
+	"matchcommitter":   "matchCommitter",
+	"autoclosetimeout": "autocloseTimeout",
+}
+
+// gitsignConnectors are shortcuts for the connector IDs of the public
+// Sigstore instance, which skip the provider selection page
+var gitsignConnectors = map[string]string{
+	"github":    "https://github.com/login/oauth",
+	"google":    "https://accounts.google.com",
+	"microsoft": "https://login.microsoftonline.com",
+}
+
+// usesGitsign reports whether a profile signs keyless with gitsign
+func usesGitsign(profile Profile) bool {
+	return profile.SigningFormat == "x509" && filepath.Base(profile.X509Program) == "gitsign"
+}
+
+// parseGitsignOption parses a gitsignOption value of the form KEY=VALUE
+func parseGitsignOption(value string) (string, string, error) {
+	key, option, ok := strings.Cut(value, "=")
+	name, known := gitsignOptionKeys[strings.ToLower(strings.TrimSpace(key))]
+	if !ok || !known {
+		names := make([]string, 0, len(gitsignOptionKeys))
+		for _, name := range gitsignOptionKeys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", "", fmt.Errorf("gitsignOption must be KEY=VALUE with KEY one of %s", strings.Join(names, ", "))
+	}
+	return name, strings.TrimSpace(option), nil
+}
+
+// gitsignKeys returns the gitsign.* config values of a profile
+func gitsignKeys(profile Profile) []ManagedKey {
+	if !usesGitsign(profile) {
+		return nil
+	}
+	var keys []ManagedKey
+	for _, name := range sortedKeys(profile.GitsignOptions) {
+		keys = append(keys, ManagedKey{Key: "gitsign." + name, Value: profile.GitsignOptions[name]})
+	}
+	return keys
+}
+
+// setupGitsign makes a profile sign keyless with gitsign, optionally
+// logging in through a fixed OIDC connector
+func setupGitsign(profileName, connector string) error {
+	if _, err := exec.LookPath("gitsign"); err != nil {
+		fmt.Println("⚠️  gitsign not found in PATH; install it before committing with this profile")
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		return errProfileNotFound
+	}
+
+	// gitsign gets its certificate from the OIDC login; there is no key
+	profile.SigningFormat = "x509"
+	profile.X509Program = "gitsign"
+	profile.SigningKey = ""
+	if connector != "" {
+		if url, ok := gitsignConnectors[connector]; ok {
+			connector = url
+		}
+		if profile.GitsignOptions == nil {
+			profile.GitsignOptions = map[string]string{}
+		}
+		profile.GitsignOptions["connectorID"] = connector
+	}
+	config.Profiles[profileName] = profile
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ '%s' now signs commits and tags keyless with gitsign\n", profileName)
+	fmt.Printf("   Run 'git usr %s' to apply it, and 'git usr doctor %s' to check the OIDC environment\n", profileName, profileName)
+	return nil
+}
+
+// gitsignTokenSource returns where gitsign will get its OIDC token from,
+// or what the environment is missing and how to fix it
+func gitsignTokenSource(getenv func(string) string, interactive bool) (source, problem, fix string) {
+	if getenv("SIGSTORE_ID_TOKEN") != "" {
+		return "SIGSTORE_ID_TOKEN", "", ""
+	}
+
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		if getenv("ACTIONS_ID_TOKEN_REQUEST_URL") == "" || getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN") == "" {
+			return "", "GitHub Actions provides no OIDC token to this job", "add 'permissions: id-token: write' to the workflow"
+		}
+		return "GitHub Actions", "", ""
+	case getenv("GITLAB_CI") == "true":
+		return "", "GitLab CI provides no OIDC token to this job", "add 'id_tokens: SIGSTORE_ID_TOKEN: aud: sigstore' to the job"
+	case getenv("BUILDKITE_AGENT_ACCESS_TOKEN") != "":
+		return "Buildkite", "", ""
+	case getenv("CI") != "":
+		return "", "no OIDC token in this CI environment", "set SIGSTORE_ID_TOKEN to a token for the sigstore audience"
+	case !interactive:
+		return "", "no OIDC token and no terminal for the browser login", "set SIGSTORE_ID_TOKEN, or sign from an interactive session"
+	}
+	return "a browser login", "", ""
+}
+
+// doctorGitsign checks that gitsign is installed and can get an OIDC token
+func doctorGitsign(profileName string, profile Profile) []doctorResult {
+	if !usesGitsign(profile) {
+		return nil
+	}
+
+	if _, err := exec.LookPath(x509Program(profile)); err != nil {
+		return []doctorResult{{
+			Profile: profileName,
+			Message: fmt.Sprintf("%s not found in PATH", x509Program(profile)),
+			Fix:     "install gitsign: https://docs.sigstore.dev/cosign/signing/gitsign/",
+		}}
+	}
+
+	source, problem, fix := gitsignTokenSource(os.Getenv, isInteractive())
+	if problem != "" {
+		return []doctorResult{{Profile: profileName, Message: "gitsign can't sign: " + problem, Fix: fix}}
+	}
+	return []doctorResult{{Profile: profileName, OK: true, Message: "gitsign gets its OIDC token from " + source}}
+}
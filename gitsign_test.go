@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+// TestGitsignSigningKeys tests the config of a keyless gitsign profile
+func TestGitsignSigningKeys(t *testing.T) {
+	profile := Profile{
+		SigningFormat:  "x509",
+		X509Program:    "gitsign",
+		GitsignOptions: map[string]string{"connectorID": "https://github.com/login/oauth"},
+	}
+
+	values := map[string]string{}
+	for _, key := range signingKeys(profile) {
+		values[key.Key] = key.Value
+	}
+	expected := map[string]string{
+		"gpg.format":          "x509",
+		"gpg.x509.program":    "gitsign",
+		"commit.gpgsign":      "true",
+		"tag.gpgsign":         "true",
+		"gitsign.connectorID": "https://github.com/login/oauth",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s = %q, expected %q", key, values[key], value)
+		}
+	}
+	if _, ok := values["user.signingkey"]; ok {
+		t.Error("Expected no user.signingkey for gitsign")
+	}
+}
+
+// TestGitsignTokenSource tests detecting the OIDC environment
+func TestGitsignTokenSource(t *testing.T) {
+	tests := []struct {
+		env         map[string]string
+		interactive bool
+		source      string
+	}{
+		{map[string]string{"SIGSTORE_ID_TOKEN": "t", "CI": "true"}, false, "SIGSTORE_ID_TOKEN"},
+		{map[string]string{"GITHUB_ACTIONS": "true", "ACTIONS_ID_TOKEN_REQUEST_URL": "u", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "t"}, false, "GitHub Actions"},
+		{map[string]string{"GITHUB_ACTIONS": "true"}, false, ""},
+		{map[string]string{"GITLAB_CI": "true"}, false, ""},
+		{map[string]string{"CI": "true"}, true, ""},
+		{map[string]string{}, false, ""},
+		{map[string]string{}, true, "a browser login"},
+	}
+
+	for _, test := range tests {
+		getenv := func(key string) string { return test.env[key] }
+		source, problem, fix := gitsignTokenSource(getenv, test.interactive)
+		if source != test.source {
+			t.Errorf("%v: source = %q, expected %q", test.env, source, test.source)
+		}
+		if (source == "") != (problem != "" && fix != "") {
+			t.Errorf("%v: expected a problem and fix exactly when there is no source, got %q / %q", test.env, problem, fix)
+		}
+	}
+}
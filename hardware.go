@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// tokenStatus describes the hardware token a signing key lives on
+type tokenStatus struct {
+	Kind string
+	// Present is whether the token is plugged in; only meaningful when
+	// Known is set, since not every token can be detected
+	Present bool
+	Known   bool
+	// Touch is "required", "not required" or "" when it can't be told
+	Touch   string
+	Problem string
+	Fix     string
+}
+
+// describe returns a summary such as "OpenPGP card, plugged in, touch
+// required"
+func (s tokenStatus) describe() string {
+	parts := []string{s.Kind}
+	switch {
+	case !s.Known:
+		parts = append(parts, "presence unknown")
+	case s.Present:
+		parts = append(parts, "plugged in")
+	default:
+		parts = append(parts, "not plugged in")
+	}
+	if s.Touch != "" {
+		parts = append(parts, "touch "+s.Touch)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// gpgCardKey is the signing key gpg will use for a key ID, with the serial
+// number of the card it is on if any
+type gpgCardKey struct {
+	Fingerprint string
+	Serial      string
+}
+
+// parseGPGCardKey parses `gpg --list-secret-keys --with-colons` output for
+// a key ID, returning the key that signs: the matching (sub)key when the
+// ID names one, otherwise the newest signing-capable one, as gpg picks it
+func parseGPGCardKey(listing, keyID string) (gpgCardKey, bool) {
+	keyID = strings.ToUpper(strings.TrimPrefix(strings.TrimSuffix(keyID, "!"), "0x"))
+
+	var signing []gpgCardKey
+	current := -1
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 10 {
+			continue
+		}
+		switch fields[0] {
+		case "sec", "ssb":
+			current = -1
+			if len(fields) < 15 || strings.ContainsAny(fields[1], "reid") || !strings.Contains(fields[11], "s") {
+				continue
+			}
+			// Field 15 holds a card serial number, "#" for stubs without a
+			// secret key and "+" for keys on disk
+			serial := fields[14]
+			if serial == "#" || serial == "+" {
+				serial = ""
+			}
+			signing = append(signing, gpgCardKey{Serial: serial})
+			current = len(signing) - 1
+		case "fpr":
+			if current >= 0 && signing[current].Fingerprint == "" {
+				signing[current].Fingerprint = fields[9]
+				if keyID != "" && strings.HasSuffix(fields[9], keyID) {
+					return signing[current], true
+				}
+			}
+		}
+	}
+	if len(signing) == 0 {
+		return gpgCardKey{}, false
+	}
+	return signing[len(signing)-1], true
+}
+
+// parseCardStatus parses `gpg --card-status --with-colons` output into the
+// fingerprint of the key in the signature slot and its touch policy
+func parseCardStatus(status string) (fingerprint, touch string) {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "fpr":
+			fingerprint = strings.ToUpper(fields[1])
+		case "uif":
+			// User interaction flags for sign, decrypt and authenticate;
+			// only YubiKeys and a few other cards report them
+			if fields[1] == "0" {
+				touch = "not required"
+			} else if fields[1] != "" {
+				touch = "required"
+			}
+		}
+	}
+	return fingerprint, touch
+}
+
+// gpgTokenStatus returns the status of the OpenPGP card a key is on, or
+// false if the key is on disk
+func gpgTokenStatus(keyID string) (tokenStatus, bool) {
+	out, err := exec.Command("gpg", "--list-secret-keys", "--with-colons", "--fixed-list-mode", keyID).Output()
+	if err != nil {
+		return tokenStatus{}, false
+	}
+	key, ok := parseGPGCardKey(string(out), keyID)
+	if !ok || key.Serial == "" {
+		return tokenStatus{}, false
+	}
+
+	status := tokenStatus{Kind: "OpenPGP card", Known: true}
+	out, err = exec.Command("gpg", "--card-status", "--with-colons").Output()
+	if err != nil {
+		status.Problem = "the OpenPGP card with the signing key isn't inserted"
+		status.Fix = fmt.Sprintf("insert the token with card serial %s", key.Serial)
+		return status, true
+	}
+
+	fingerprint, touch := parseCardStatus(string(out))
+	status.Touch = touch
+	if fingerprint != "" && !strings.EqualFold(fingerprint, key.Fingerprint) {
+		status.Problem = "the inserted OpenPGP card holds a different signing key"
+		status.Fix = fmt.Sprintf("insert the token with card serial %s", key.Serial)
+		return status, true
+	}
+	status.Present = true
+	return status, true
+}
+
+// isFIDOKeyType reports whether an SSH key type is a FIDO security key
+// (ssh-keygen -t ed25519-sk or ecdsa-sk)
+func isFIDOKeyType(keyType string) bool {
+	return strings.HasPrefix(keyType, "sk-")
+}
+
+// sshSigningKeyType returns the type of an SSH signing key given as a
+// literal "key::" value or as the path to the public or private key
+func sshSigningKeyType(signingKey string) string {
+	if literal, ok := strings.CutPrefix(signingKey, "key::"); ok {
+		return strings.Fields(literal + " ")[0]
+	}
+	path, err := expandHome(signingKey)
+	if err != nil {
+		return ""
+	}
+	if !strings.HasSuffix(path, ".pub") {
+		path += ".pub"
+	}
+	if key, err := readSSHPublicKey(path); err == nil {
+		return key.Type
+	}
+	if key, err := readSSHPublicKey(strings.TrimSuffix(path, ".pub")); err == nil {
+		return key.Type
+	}
+	return ""
+}
+
+// fidoTokenStatus returns the status of the security key an SSH key is
+// on, or false if it is an ordinary key
+func fidoTokenStatus(signingKey string) (tokenStatus, bool) {
+	if !isFIDOKeyType(sshSigningKeyType(signingKey)) {
+		return tokenStatus{}, false
+	}
+
+	// A FIDO key asks for a touch unless it was made with no-touch-required
+	status := tokenStatus{Kind: "FIDO security key", Touch: "required"}
+	var out []byte
+	var err error
+	if _, lookErr := exec.LookPath("fido2-token"); lookErr == nil {
+		out, err = exec.Command("fido2-token", "-L").Output()
+	} else if _, lookErr := exec.LookPath("ykman"); lookErr == nil {
+		out, err = exec.Command("ykman", "list").Output()
+	} else {
+		return status, true
+	}
+
+	status.Known = err == nil
+	status.Present = status.Known && strings.TrimSpace(string(out)) != ""
+	if status.Known && !status.Present {
+		status.Problem = "no FIDO security key is connected"
+		status.Fix = "plug in the security key the SSH signing key was made on"
+	}
+	return status, true
+}
+
+// hardwareTokenStatus returns the status of the hardware token a profile
+// signs with, or false if its key isn't on one
+func hardwareTokenStatus(profile Profile) (tokenStatus, bool) {
+	if profile.SigningKey == "" {
+		return tokenStatus{}, false
+	}
+	switch profile.SigningFormat {
+	case "", "openpgp":
+		return gpgTokenStatus(profile.SigningKey)
+	case "ssh":
+		return fidoTokenStatus(profile.SigningKey)
+	}
+	return tokenStatus{}, false
+}
+
+// gitSigningProfile returns the signing settings git uses here as a profile
+func gitSigningProfile() Profile {
+	return Profile{SigningKey: getGitConfigValue("user.signingkey"), SigningFormat: getGitConfigValue("gpg.format")}
+}
+
+// doctorToken checks that the hardware token of a profile's signing key is
+// plugged in
+func doctorToken(profileName string, profile Profile) []doctorResult {
+	status, ok := hardwareTokenStatus(profile)
+	if !ok {
+		return nil
+	}
+	if status.Problem != "" {
+		return []doctorResult{{Profile: profileName, Message: status.Problem, Fix: status.Fix}}
+	}
+	return []doctorResult{{Profile: profileName, OK: true, Message: fmt.Sprintf("signing key %s is on a hardware token (%s)", profile.SigningKey, status.describe())}}
+}
+
+// hintHardwareToken explains before a signed commit that the token must
+// be plugged in or touched, since gpg and ssh-keygen fail or wait silently
+func hintHardwareToken() {
+	if getGitConfigValue("commit.gpgsign") != "true" {
+		return
+	}
+	status, ok := hardwareTokenStatus(gitSigningProfile())
+	switch {
+	case !ok:
+	case status.Problem != "":
+		fmt.Fprintf(os.Stderr, "⚠️  git-usr: %s, so this commit can't be signed (%s)\n", status.Problem, status.Fix)
+	case status.Touch == "required":
+		fmt.Fprintf(os.Stderr, "👆 git-usr: touch your %s to sign this commit\n", status.Kind)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cardKeyListing is `gpg --list-secret-keys --with-colons` for a key whose
+// signing subkey was moved to an OpenPGP card
+const cardKeyListing = `sec:u:255:22:1111111111111111:1700000000:::u:::cESC:::+:::ed25519:::0:
+fpr:::::::::AAAAAAAAAAAAAAAAAAAAAAAA1111111111111111:
+uid:u::::1700000000::HASH::Jane Doe <jane@example.com>::::::::::0:
+ssb:u:255:22:2222222222222222:1700000000::::::s:::D2760001240103040006123456780000::ed25519::
+fpr:::::::::BBBBBBBBBBBBBBBBBBBBBBBB2222222222222222:
+ssb:u:255:18:3333333333333333:1700000000::::::e:::D2760001240103040006123456780000::cv25519::
+fpr:::::::::CCCCCCCCCCCCCCCCCCCCCCCC3333333333333333:
+`
+
+// TestParseGPGCardKey tests finding the card a signing key is on
+func TestParseGPGCardKey(t *testing.T) {
+	key, ok := parseGPGCardKey(cardKeyListing, "jane@example.com")
+	if !ok || key.Serial != "D2760001240103040006123456780000" || key.Fingerprint != "BBBBBBBBBBBBBBBBBBBBBBBB2222222222222222" {
+		t.Errorf("parseGPGCardKey(email) = %+v, %v", key, ok)
+	}
+
+	// Naming a key on disk picks it even when a card key signs too
+	listing := `sec:u:255:22:1111111111111111:1700000000:::u:::scESC:::+:::ed25519:::0:
+fpr:::::::::AAAAAAAAAAAAAAAAAAAAAAAA1111111111111111:
+ssb:u:255:22:2222222222222222:1700000000::::::s:::D2760001240103040006123456780000::ed25519::
+fpr:::::::::BBBBBBBBBBBBBBBBBBBBBBBB2222222222222222:
+`
+	key, ok = parseGPGCardKey(listing, "0x1111111111111111!")
+	if !ok || key.Serial != "" {
+		t.Errorf("parseGPGCardKey(primary) = %+v, %v, expected the key on disk", key, ok)
+	}
+
+	if _, ok := parseGPGCardKey("", "jane@example.com"); ok {
+		t.Error("parseGPGCardKey found a key in an empty listing")
+	}
+}
+
+// TestParseCardStatus tests reading the signature key and touch policy
+func TestParseCardStatus(t *testing.T) {
+	status := `Reader:Yubico YubiKey OTP FIDO CCID:AID:D2760001240103040006123456780000:openpgp-card
+version:0304:
+vendor:0006:Yubico:
+serial:12345678:
+fpr:bbbbbbbbbbbbbbbbbbbbbbbb2222222222222222:cccccccccccccccccccccccc3333333333333333::
+uif:1:0:0:
+`
+	fingerprint, touch := parseCardStatus(status)
+	if fingerprint != "BBBBBBBBBBBBBBBBBBBBBBBB2222222222222222" || touch != "required" {
+		t.Errorf("parseCardStatus = %q, %q", fingerprint, touch)
+	}
+
+	if _, touch := parseCardStatus("fpr:AAAA:::\n"); touch != "" {
+		t.Errorf("parseCardStatus without uif = touch %q, expected unknown", touch)
+	}
+}
+
+// TestSSHSigningKeyType tests telling FIDO keys from ordinary SSH keys
+func TestSSHSigningKeyType(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "id_ed25519_sk")
+	os.WriteFile(path+".pub", []byte("sk-ssh-ed25519@openssh.com AAAAGnNr jane@example.com\n"), 0644)
+
+	tests := map[string]bool{
+		path:          true,
+		path + ".pub": true,
+		"key::sk-ecdsa-sha2-nistp256@openssh.com AAAA": true,
+		"key::ssh-ed25519 AAAA":                        false,
+		filepath.Join(dir, "missing"):                  false,
+	}
+	for key, expected := range tests {
+		if got := isFIDOKeyType(sshSigningKeyType(key)); got != expected {
+			t.Errorf("isFIDOKeyType(sshSigningKeyType(%q)) = %v, expected %v", key, got, expected)
+		}
+	}
+}
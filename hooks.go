@@ -0,0 +1,465 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// branchHookNames are the hooks installed by `git usr init --install-hooks`.
+// They run on every branch change, which happens far more often than commits
+var branchHookNames = []string{"post-checkout", "post-merge"}
+
+// commitHookNames are installed along with the branch hooks to explain
+// signing problems before git runs into them and to guard the identity
+// policy of the repository's project file
+var commitHookNames = []string{"pre-commit"}
+
+// managedHookNames are all hooks git-usr installs, in the order git runs
+// them
+var managedHookNames = []string{"pre-commit", "prepare-commit-msg", "post-checkout", "post-merge"}
+
+// hookPurposes says what each hook is installed for
+var hookPurposes = map[string]string{
+	"post-checkout":      "check the identity when branches change",
+	"post-merge":         "check the identity when branches change",
+	"pre-commit":         "check the identity policy and explain hardware token signing before committing",
+	"prepare-commit-msg": "add profile trailers and Co-authored-by trailers for 'git usr pair'",
+}
+
+// guardHooks fail with `git usr hook`, so they can stop a commit
+var guardHooks = map[string]bool{"pre-commit": true}
+
+// chainedHookSuffix is added to the name of a hook git-usr took the place
+// of. The git-usr hook runs it first
+const chainedHookSuffix = ".pre-git-usr"
+
+// Hooks chain to the hook they took the place of, or when installed with
+// core.hooksPath to the repository's own hook, which git skips then
+const (
+	chainLocalHook = `"$0` + chainedHookSuffix + `"`
+	chainRepoHook  = `"$(git rev-parse --git-common-dir)/hooks/%s"`
+)
+
+// hookScript delegates a hook to `git usr hook` after running the hook it
+// chains to, if there is one, whose failure fails the hook as it did
+// before. Guard hooks fail with git usr; the others always succeed, since
+// a failing post-checkout or prepare-commit-msg hook makes git itself
+// fail. Where git-usr isn't installed guard hooks stay out of the way,
+// since the hooks directory may be shared
+func hookScript(hook, chained string) string {
+	body := "chained=" + chained + "\n" +
+		`if [ -x "$chained" ]; then "$chained" "$@" || exit $?; fi` + "\n"
+	if guardHooks[hook] {
+		body += `command -v git-usr >/dev/null 2>&1 || exit 0
+exec git usr hook ` + hook + ` "$@"
+`
+	} else {
+		body += `git usr hook ` + hook + ` "$@" || true
+exit 0
+`
+	}
+	return shellScript("Installed by git-usr: "+hookPurposes[hook], body)
+}
+
+// isGitUsrHook reports whether a hook file was written by git-usr, going
+// by the marker comment shellScript puts below the shebang. A hook of the
+// user's own that merely runs git usr isn't ours
+func isGitUsrHook(data []byte) bool {
+	for _, line := range strings.Split(normalizeLF(string(data)), "\n") {
+		if line == "# Installed by git-usr" || strings.HasPrefix(line, "# Installed by git-usr:") {
+			return true
+		}
+	}
+	return false
+}
+
+// getHooksDir returns the hooks directory of the current repository,
+// honoring core.hooksPath
+func getHooksDir() (string, error) {
+	out, err := runGit("", "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(strings.TrimSpace(out))
+}
+
+// getGlobalHooksDir returns the directory `git usr hooks install --global`
+// points core.hooksPath to
+func getGlobalHooksDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "hooks"), nil
+}
+
+// globalHooksPath returns the global core.hooksPath, expanded
+func globalHooksPath() string {
+	hooksPath := getScopedGitConfigValue("global", "core.hooksPath")
+	if expanded, err := expandHome(hooksPath); err == nil && hooksPath != "" {
+		return expanded
+	}
+	return hooksPath
+}
+
+// isInside reports whether path is dir or below it
+func isInside(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// hooksInWorkTree reports whether core.hooksPath points to files of the
+// work tree at top, as with husky. Those belong to the project
+func hooksInWorkTree(hooksDir, top string) bool {
+	return isInside(hooksDir, top) && !isInside(hooksDir, filepath.Join(top, ".git"))
+}
+
+// installHooks writes the named hooks into the current repository. A hook
+// not written by git-usr is kept next to it and run first. It returns the
+// hooks directory, "" when the global hooks already run here
+func installHooks(hooks []string) (string, error) {
+	top, err := gitConfig.WorkTree("")
+	if isUnsafeRepository(err) {
+		return "", err
+	}
+	if err != nil {
+		fmt.Println("❌ Not inside a git repository")
+		return "", err
+	}
+
+	hooksDir, err := getHooksDir()
+	if err != nil {
+		return "", err
+	}
+	if global, err := getGlobalHooksDir(); err == nil && hooksDir == global {
+		fmt.Printf("✅ The git-usr hooks in %s already run here (core.hooksPath)\n", hooksDir)
+		return "", nil
+	}
+	if hooksInWorkTree(hooksDir, top) {
+		fmt.Printf("❌ core.hooksPath points into the repository: %s\n", hooksDir)
+		fmt.Println("   Add these lines to its hooks instead:")
+		for _, hook := range hooks {
+			fmt.Printf("     %s: git usr hook %s \"$@\"\n", hook, hook)
+		}
+		return "", fmt.Errorf("hooks are part of the repository")
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", err
+	}
+
+	// Check everything first so nothing is half installed
+	var chain []string
+	for _, hook := range hooks {
+		hookPath := filepath.Join(hooksDir, hook)
+		data, err := os.ReadFile(hookPath)
+		if err != nil || isGitUsrHook(data) {
+			continue
+		}
+		if _, err := os.Stat(hookPath + chainedHookSuffix); err == nil {
+			fmt.Printf("❌ Both %s and %s exist and neither is a git-usr hook\n", hookPath, hook+chainedHookSuffix)
+			return "", fmt.Errorf("hook exists")
+		}
+		chain = append(chain, hook)
+	}
+
+	for _, hook := range chain {
+		hookPath := filepath.Join(hooksDir, hook)
+		if err := os.Rename(hookPath, hookPath+chainedHookSuffix); err != nil {
+			return "", err
+		}
+		fmt.Printf("   Kept your %s hook as %s; it runs first\n", hook, hook+chainedHookSuffix)
+	}
+	for _, hook := range hooks {
+		if err := writeScript(filepath.Join(hooksDir, hook), hookScript(hook, chainLocalHook)); err != nil {
+			return "", err
+		}
+	}
+	return hooksDir, nil
+}
+
+// uninstallHooks removes the named hooks if git-usr wrote them, putting
+// back the hooks they chained to. It returns the hooks directory and how
+// many were removed
+func uninstallHooks(hooks []string) (string, int, error) {
+	hooksDir, err := getHooksDir()
+	if err != nil {
+		fmt.Println("❌ Not inside a git repository")
+		return "", 0, err
+	}
+	if global, err := getGlobalHooksDir(); err == nil && hooksDir == global {
+		fmt.Println("   The git-usr hooks run from core.hooksPath here (see 'git usr hooks uninstall --global')")
+		return hooksDir, 0, nil
+	}
+
+	removed := 0
+	for _, hook := range hooks {
+		hookPath := filepath.Join(hooksDir, hook)
+		data, err := os.ReadFile(hookPath)
+		if err != nil || !isGitUsrHook(data) {
+			continue
+		}
+		if err := os.Remove(hookPath); err != nil {
+			return "", removed, err
+		}
+		removed++
+		if err := os.Rename(hookPath+chainedHookSuffix, hookPath); err == nil {
+			fmt.Printf("   Restored your %s hook\n", hook)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", removed, err
+		}
+	}
+	return hooksDir, removed, nil
+}
+
+// installGlobalHooks writes every hook into a directory of its own and
+// points the global core.hooksPath to it, so they run in all repositories.
+// Each runs the repository's own hook first, which core.hooksPath would
+// skip otherwise
+func installGlobalHooks() error {
+	hooksDir, err := getGlobalHooksDir()
+	if err != nil {
+		return err
+	}
+	if current := globalHooksPath(); current != "" && current != hooksDir {
+		fmt.Printf("❌ core.hooksPath is already set to %s\n", current)
+		fmt.Println("   Install the hooks per repository with 'git usr hooks install' instead")
+		return fmt.Errorf("core.hooksPath already set")
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return err
+	}
+	for _, hook := range managedHookNames {
+		if err := writeScript(filepath.Join(hooksDir, hook), hookScript(hook, fmt.Sprintf(chainRepoHook, hook))); err != nil {
+			return err
+		}
+	}
+	if err := gitConfig.Set("", "global", "core.hooksPath", hooksDir); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Installed %s hooks in %s\n", strings.Join(managedHookNames, ", "), hooksDir)
+	fmt.Println("   core.hooksPath now points there for all your repositories")
+	fmt.Println("   Each repository's own hooks in .git/hooks still run first")
+	fmt.Println("   Repositories that set core.hooksPath themselves, e.g. with husky, skip them")
+	return nil
+}
+
+// uninstallGlobalHooks removes the hooks of installGlobalHooks and unsets
+// core.hooksPath
+func uninstallGlobalHooks() error {
+	hooksDir, err := getGlobalHooksDir()
+	if err != nil {
+		return err
+	}
+	if globalHooksPath() == hooksDir {
+		if err := gitConfig.Unset("", "global", "core.hooksPath", ""); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(hooksDir); err != nil {
+		return err
+	}
+	fmt.Println("✅ Removed the global git-usr hooks and unset core.hooksPath")
+	return nil
+}
+
+// hookStatus describes one hook file for `git usr hooks status`
+func hookStatus(hookPath string) string {
+	data, err := os.ReadFile(hookPath)
+	switch {
+	case err != nil:
+		return "not installed"
+	case !isGitUsrHook(data):
+		return "not installed (another tool's hook is in place)"
+	}
+	if _, err := os.Stat(hookPath + chainedHookSuffix); err == nil {
+		return "installed, runs your " + filepath.Base(hookPath+chainedHookSuffix) + " first"
+	}
+	return "installed"
+}
+
+// showHooksStatus prints where the git-usr hooks are installed
+func showHooksStatus() error {
+	globalDir, err := getGlobalHooksDir()
+	if err != nil {
+		return err
+	}
+	fmt.Println("\n🪝 git-usr hooks:")
+	fmt.Println("--------------------------------------------------")
+	switch current := globalHooksPath(); current {
+	case "":
+		fmt.Println("   Global:     not installed")
+	case globalDir:
+		fmt.Printf("   Global:     installed in %s (core.hooksPath)\n", globalDir)
+	default:
+		fmt.Printf("   Global:     not installed (core.hooksPath is %s)\n", current)
+	}
+	if config, err := loadConfig(); err == nil {
+		for _, script := range config.OnSwitch {
+			fmt.Printf("   On switch:  %s\n", script)
+		}
+	}
+
+	top, err := gitConfig.WorkTree("")
+	if isUnsafeRepository(err) {
+		return err
+	}
+	if err != nil {
+		fmt.Println("   Not inside a git repository")
+		fmt.Println()
+		return nil
+	}
+	hooksDir, err := getHooksDir()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("   Repository: %s\n", hooksDir)
+	switch {
+	case hooksDir == globalDir:
+		fmt.Println("   The global hooks run here")
+	case hooksInWorkTree(hooksDir, top):
+		fmt.Println("   core.hooksPath points into the repository; git-usr can't install hooks there")
+	default:
+		for _, hook := range managedHookNames {
+			fmt.Printf("     %-19s %s\n", hook, hookStatus(filepath.Join(hooksDir, hook)))
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+// runHooks handles the hooks command
+func runHooks(args []string) error {
+	usage := "Usage: git usr hooks install|uninstall [--global] | status | on-switch [<script> | --remove <script>]"
+	if len(args) > 0 && args[0] == "on-switch" {
+		return runOnSwitch(args[1:])
+	}
+	global := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--global" {
+			global = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	if len(rest) != 1 {
+		fmt.Println(usage)
+		return fmt.Errorf("expected install, uninstall or status")
+	}
+
+	switch rest[0] {
+	case "install":
+		if global {
+			return installGlobalHooks()
+		}
+		hooksDir, err := installHooks(managedHookNames)
+		if err != nil || hooksDir == "" {
+			return err
+		}
+		fmt.Printf("✅ Installed %s hooks in %s\n", strings.Join(managedHookNames, ", "), hooksDir)
+		return nil
+	case "uninstall":
+		if global {
+			return uninstallGlobalHooks()
+		}
+		hooksDir, removed, err := uninstallHooks(managedHookNames)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed %d git-usr hook(s) from %s\n", removed, hooksDir)
+		return nil
+	case "status":
+		if !global {
+			return showHooksStatus()
+		}
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("unknown hooks command: %s", rest[0])
+}
+
+// installBranchHooks writes the post-checkout, post-merge and pre-commit
+// hooks into the current repository
+func installBranchHooks() error {
+	hooksDir, err := installHooks(append(append([]string{}, branchHookNames...), commitHookNames...))
+	if err != nil || hooksDir == "" {
+		return err
+	}
+
+	fmt.Printf("✅ Installed %s hooks in %s\n", strings.Join(branchHookNames, " and "), hooksDir)
+	fmt.Println("   The identity is checked every time you switch branches or merge")
+	fmt.Printf("   A %s hook blocks commits from emails the repository's %s or your rules don't allow\n", strings.Join(commitHookNames, " and "), strings.Join(projectFileNames, " or "))
+	fmt.Println("   and reminds you to plug in or touch a hardware signing token")
+	return nil
+}
+
+// uninstallBranchHooks removes the hooks written by installBranchHooks
+func uninstallBranchHooks() error {
+	hooksDir, removed, err := uninstallHooks(append(append([]string{}, branchHookNames...), commitHookNames...))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Removed %d git-usr hook(s) from %s\n", removed, hooksDir)
+	return nil
+}
+
+// checkIdentityAfterBranchChange applies the pinned or default profile to
+// a repository without an identity and warns on stderr when the identity
+// doesn't belong to any profile, drifted from the pin or doesn't belong
+// to the remote
+func checkIdentityAfterBranchChange() {
+	if getScopedGitConfigValue("local", "user.email") == "" {
+		initRepo(false, true)
+		if email := getScopedGitConfigValue("local", "user.email"); email != "" {
+			fmt.Fprintf(os.Stderr, "✅ git-usr: applied the identity %s\n", email)
+		}
+	}
+
+	info, err := computePromptInfo()
+	if err != nil {
+		return
+	}
+	if warning := identityWarning(info); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+}
+
+// runHook handles the hook command, which the generated hooks call with
+// the hook name and git's hook arguments
+func runHook(args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Usage: git usr hook <post-checkout|post-merge|pre-commit|prepare-commit-msg> [hook args]")
+		return fmt.Errorf("no hook given")
+	}
+	// Hooks run inside git commands, which must never wait for a passphrase
+	promptsDisabled = true
+
+	switch args[0] {
+	case "post-checkout":
+		// The third argument is 1 for branch checkouts and 0 for file checkouts
+		if len(args) < 4 || args[3] != "1" {
+			return nil
+		}
+	case "post-merge":
+	case "pre-commit":
+		if err := guardCommit(); err != nil {
+			return err
+		}
+		hintHardwareToken()
+		return nil
+	case "prepare-commit-msg":
+		if len(args) < 2 {
+			return fmt.Errorf("prepare-commit-msg requires the message file")
+		}
+		return appendCommitTrailers(args[1])
+	default:
+		return fmt.Errorf("unknown hook: %s", args[0])
+	}
+
+	checkIdentityAfterBranchChange()
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHookScript tests that hooks delegate to git usr hook and
+// never fail the checkout
+func TestHookScript(t *testing.T) {
+	for _, hook := range branchHookNames {
+		script := hookScript(hook, chainLocalHook)
+		if !strings.Contains(script, "git usr hook "+hook+` "$@" || true`) {
+			t.Errorf("%s hook does not delegate to git usr:\n%s", hook, script)
+		}
+		if !strings.HasSuffix(script, "exit 0\n") {
+			t.Errorf("%s hook may fail:\n%s", hook, script)
+		}
+		if !isGitUsrHook([]byte(script)) {
+			t.Errorf("%s hook not recognized as a git-usr hook", hook)
+		}
+	}
+
+	if isGitUsrHook([]byte("#!/bin/sh\ngit usr check || exit 1\n")) {
+		t.Error("Expected a user's hook calling git usr not to be taken for ours")
+	}
+	if isGitUsrHook([]byte("#!/bin/sh\nnpx lint-staged\n")) {
+		t.Error("Foreign hook recognized as a git-usr hook")
+	}
+}
+
+// TestGuardHookScript tests that the pre-commit hook can stop a commit,
+// both by itself and through the hook it chains to
+func TestGuardHookScript(t *testing.T) {
+	script := hookScript("pre-commit", chainLocalHook)
+	if !strings.Contains(script, `exec git usr hook pre-commit "$@"`) || strings.Contains(script, "|| true") {
+		t.Errorf("pre-commit hook doesn't fail with git usr:\n%s", script)
+	}
+	if !strings.Contains(script, `"$chained" "$@" || exit $?`) {
+		t.Errorf("pre-commit hook doesn't fail with the hook it chains to:\n%s", script)
+	}
+	if !strings.Contains(hookScript("post-merge", fmt.Sprintf(chainRepoHook, "post-merge")), `chained="$(git rev-parse --git-common-dir)/hooks/post-merge"`) {
+		t.Error("Global hook doesn't chain to the repository's hook")
+	}
+}
+
+// TestHooksInWorkTree tests telling whether a hooks directory is in a
+// work tree
+func TestHooksInWorkTree(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"/repo/.husky", "/repo", true},
+		{"/repo", "/repo", true},
+		{"/repo/.git/hooks", "/repo", false},
+		{"/repo/.githooks", "/repo", true},
+		{"/repository/hooks", "/repo", false},
+		{"/home/jane/.config/git-usr/hooks", "/repo", false},
+	}
+	for _, tt := range tests {
+		if got := hooksInWorkTree(filepath.FromSlash(tt.path), filepath.FromSlash(tt.dir)); got != tt.want {
+			t.Errorf("hooksInWorkTree(%q, %q) = %v, expected %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
+
+// TestRunHookFileCheckout tests that file checkouts are ignored
+func TestRunHookFileCheckout(t *testing.T) {
+	if err := runHook([]string{"post-checkout", "abc", "abc", "0"}); err != nil {
+		t.Errorf("runHook failed: %v", err)
+	}
+	if err := runHook([]string{"pre-push"}); err == nil {
+		t.Error("Expected an error for an unknown hook")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// messageCatalog maps English messages, which are the message IDs, to
+// their translation. Messages missing from a catalog are printed in English
+type messageCatalog map[string]string
+
+// messageCatalogs are the built-in translations by language code
+var messageCatalogs = map[string]messageCatalog{
+	"de": germanMessages,
+}
+
+// messageLanguages returns the language codes to look translations up in,
+// most specific first, from GIT_USR_LANG or else the usual locale
+// variables: "de_AT.UTF-8" gives de_AT then de. English gives none
+func messageLanguages(getenv func(string) string) []string {
+	for _, key := range []string{"GIT_USR_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := getenv(key)
+		if value == "" {
+			continue
+		}
+		// Drop the encoding and modifier: de_AT.UTF-8@euro
+		if i := strings.IndexAny(value, ".@"); i >= 0 {
+			value = value[:i]
+		}
+		language, region, _ := strings.Cut(strings.ReplaceAll(value, "-", "_"), "_")
+		language = strings.ToLower(language)
+		if language == "" || language == "c" || language == "posix" || language == "en" {
+			return nil
+		}
+		if region != "" {
+			return []string{language + "_" + strings.ToUpper(region), language}
+		}
+		return []string{language}
+	}
+	return nil
+}
+
+// loadMessageCatalog merges the catalogs for languages, most specific
+// first: files named <language>.json in dir, e.g. pt_BR.json, and the
+// built-in catalogs. A file can add a language or override messages
+func loadMessageCatalog(languages []string, dir string) messageCatalog {
+	catalog := messageCatalog{}
+	add := func(messages messageCatalog) {
+		for id, translation := range messages {
+			if _, exists := catalog[id]; !exists {
+				catalog[id] = translation
+			}
+		}
+	}
+	for _, language := range languages {
+		if dir != "" {
+			if data, err := os.ReadFile(filepath.Join(dir, language+".json")); err == nil {
+				var messages messageCatalog
+				if err := json.Unmarshal(data, &messages); err != nil {
+					fmt.Fprintf(os.Stderr, "⚠️  Ignoring invalid message catalog %s: %v\n", filepath.Join(dir, language+".json"), err)
+				}
+				add(messages)
+			}
+		}
+		add(messageCatalogs[language])
+	}
+	return catalog
+}
+
+var (
+	activeCatalog     messageCatalog
+	activeCatalogOnce sync.Once
+)
+
+// tr returns the translation of an English message
+func tr(message string) string {
+	activeCatalogOnce.Do(func() {
+		languages := messageLanguages(os.Getenv)
+		if len(languages) == 0 {
+			return
+		}
+		dir := ""
+		if configDir, err := getConfigDir(); err == nil {
+			dir = filepath.Join(configDir, "locales")
+		}
+		activeCatalog = loadMessageCatalog(languages, dir)
+	})
+	if translation, ok := activeCatalog[message]; ok && translation != "" {
+		return translation
+	}
+	return message
+}
+
+// trf formats a translated message
+func trf(format string, args ...interface{}) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// localizeHelp translates help text line by line: whole lines such as
+// headings, or the description after the usage of a command
+func localizeHelp(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(trimmed)]
+		if translated := tr(trimmed); translated != trimmed {
+			lines[i] = indent + translated
+			continue
+		}
+		// Usage and description are separated by at least two spaces
+		gap := strings.Index(trimmed, "  ")
+		if gap <= 0 {
+			continue
+		}
+		description := strings.TrimLeft(trimmed[gap:], " ")
+		if translated := tr(description); translated != description {
+			lines[i] = strings.TrimSuffix(line, description) + translated
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMessageLanguages(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{}, nil},
+		{map[string]string{"LANG": "de_DE.UTF-8"}, []string{"de_DE", "de"}},
+		{map[string]string{"LANG": "de_AT.UTF-8@euro"}, []string{"de_AT", "de"}},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LC_ALL": "C"}, nil},
+		{map[string]string{"LANG": "en_US.UTF-8", "GIT_USR_LANG": "de"}, []string{"de"}},
+		{map[string]string{"LANG": "de_DE.UTF-8", "GIT_USR_LANG": "en"}, nil},
+		{map[string]string{"GIT_USR_LANG": "pt-br"}, []string{"pt_BR", "pt"}},
+		{map[string]string{"LC_MESSAGES": "POSIX", "LANG": "de_DE"}, nil},
+	}
+	for _, tt := range tests {
+		got := messageLanguages(func(key string) string { return tt.env[key] })
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("messageLanguages(%v) = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestLoadMessageCatalog(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "de_AT.json"), []byte(`{"Show this help": "Diese Hilfe anzeigen, bitte"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"Show this help": "Afficher cette aide"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog := loadMessageCatalog([]string{"de_AT", "de"}, dir)
+	if got := catalog["Show this help"]; got != "Diese Hilfe anzeigen, bitte" {
+		t.Errorf("user catalog should override the built-in one, got %q", got)
+	}
+	if got := catalog["Decrypt the config file"]; got != germanMessages["Decrypt the config file"] {
+		t.Errorf("built-in messages should fill in, got %q", got)
+	}
+
+	if got := loadMessageCatalog([]string{"fr"}, dir)["Show this help"]; got != "Afficher cette aide" {
+		t.Errorf("user catalog should add a language, got %q", got)
+	}
+	if catalog := loadMessageCatalog([]string{"xx"}, dir); len(catalog) != 0 {
+		t.Errorf("unknown language should have no messages, got %v", catalog)
+	}
+}
+
+func TestLocalizeHelp(t *testing.T) {
+	saved := activeCatalog
+	activeCatalogOnce.Do(func() {})
+	activeCatalog = germanMessages
+	defer func() { activeCatalog = saved }()
+
+	got := localizeHelp("Usage:\n  git usr help                   Show this help\n  git usr add work \"John Doe\"\n")
+	want := "Verwendung:\n  git usr help                   Diese Hilfe anzeigen\n  git usr add work \"John Doe\"\n"
+	if got != want {
+		t.Errorf("localizeHelp() = %q, want %q", got, want)
+	}
+}
+
+func TestMessageCatalogsMatchSource(t *testing.T) {
+	var source strings.Builder
+	files, _ := filepath.Glob("*.go")
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") || strings.HasPrefix(file, "messages_") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source.Write(data)
+	}
+
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for language, catalog := range messageCatalogs {
+		for id, translation := range catalog {
+			if strings.Join(verbs.FindAllString(id, -1), "") != strings.Join(verbs.FindAllString(translation, -1), "") {
+				t.Errorf("%s: %q has different format verbs than %q", language, translation, id)
+			}
+			// Messages no longer printed would silently go untranslated
+			if !strings.Contains(helpText, id) && !strings.Contains(source.String(), strconv.Quote(id)) {
+				t.Errorf("%s: %q is not a message git-usr prints", language, id)
+			}
+		}
+	}
+}
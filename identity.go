@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// decompositions maps a precomposed letter to its base and mark
+	decompositions = map[rune][2]rune{}
+	// composePairs maps a base and mark to the precomposed letter
+	composePairs = map[[2]rune]rune{}
+)
+
+func init() {
+	for mark, pairs := range compositions {
+		runes := []rune(pairs)
+		for i := 0; i+1 < len(runes); i += 2 {
+			decompositions[runes[i]] = [2]rune{runes[i+1], mark}
+			composePairs[[2]rune{runes[i+1], mark}] = runes[i]
+		}
+	}
+}
+
+// combiningClass returns the canonical combining class of r, 0 for
+// letters and for marks outside combiningClasses
+func combiningClass(r rune) rune {
+	for _, span := range combiningClasses {
+		if r >= span[0] && r <= span[1] {
+			return span[2]
+		}
+	}
+	return 0
+}
+
+// normalizeNFC returns s with letters and combining marks composed, so
+// "é" and "é" compare equal whatever order the marks were typed in. It
+// follows the NFC algorithm (decompose, put marks in canonical order,
+// compose) but only knows the letters in compositions and the marks in
+// combiningClasses, which is what accented names need, rather than all
+// of Unicode.
+func normalizeNFC(s string) string {
+	var decomposed []rune
+	var decompose func(r rune)
+	decompose = func(r rune) {
+		if d, ok := decompositions[r]; ok {
+			decompose(d[0])
+			decomposed = append(decomposed, d[1])
+			return
+		}
+		decomposed = append(decomposed, r)
+	}
+	for _, r := range s {
+		decompose(r)
+	}
+
+	for i := 0; i < len(decomposed); {
+		j := i
+		for j < len(decomposed) && combiningClass(decomposed[j]) != 0 {
+			j++
+		}
+		if j-i > 1 {
+			marks := decomposed[i:j]
+			sort.SliceStable(marks, func(a, b int) bool {
+				return combiningClass(marks[a]) < combiningClass(marks[b])
+			})
+		}
+		i = j + 1
+	}
+
+	// A mark composes with the last letter unless a mark of the same or a
+	// higher class, or another letter, stands between them
+	composed := make([]rune, 0, len(decomposed))
+	starter, lastClass := -1, rune(0)
+	for _, r := range decomposed {
+		class := combiningClass(r)
+		if starter >= 0 {
+			adjacent := starter == len(composed)-1
+			if adjacent || (lastClass != 0 && lastClass < class) {
+				if c, ok := composePairs[[2]rune{composed[starter], r}]; ok {
+					composed[starter] = c
+					continue
+				}
+			}
+		}
+		composed = append(composed, r)
+		if class == 0 {
+			starter = len(composed) - 1
+		}
+		lastClass = class
+	}
+	return string(composed)
+}
+
+// cleanIdentityText normalizes a name or email for storing: composed
+// Unicode, any Unicode whitespace (e.g. no-break spaces) turned into single
+// spaces, and no leading or trailing space
+func cleanIdentityText(s string) string {
+	return strings.Join(strings.FieldsFunc(normalizeNFC(s), unicode.IsSpace), " ")
+}
+
+// controlCharacters returns the control and invisible formatting characters
+// (zero-width spaces, bidi marks, byte order marks) contained in s
+func controlCharacters(s string) []rune {
+	var found []rune
+	for _, r := range s {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// warnIdentityText prints a warning when a name or email contains control
+// or invisible characters, which make identities look equal when they aren't
+func warnIdentityText(field, value string) {
+	found := controlCharacters(value)
+	if len(found) == 0 {
+		return
+	}
+
+	codes := make([]string, len(found))
+	for i, r := range found {
+		codes[i] = fmt.Sprintf("U+%04X", r)
+	}
+	fmt.Printf("⚠️  %s contains control or invisible characters: %s\n", field, strings.Join(codes, " "))
+}
+
+// validateName returns why a cleaned name can't be used as user.name.
+// Control characters are rejected since they garble terminals and logs;
+// invisible formatting characters only get a warning, as some scripts
+// need zero-width joiners
+func validateName(field, name string) error {
+	if name == "" {
+		return fmt.Errorf("%s cannot be empty", field)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%s contains the control character U+%04X", field, r)
+		}
+	}
+	return nil
+}
+
+// emailSpecials are the characters besides letters and digits RFC 5322
+// allows in the local part of an address without quoting
+const emailSpecials = "!#$%&'*+-/=?^_`{|}~"
+
+// validateEmail returns why a cleaned email isn't an address: it must be
+// local@domain with an unquoted local part and a domain of at least two
+// labels. Letters outside ASCII are allowed in both, as RFC 6531 does
+func validateEmail(field, email string) error {
+	invalid := func(why string) error {
+		return fmt.Errorf("%s '%s' is not an email address: %s", field, email, why)
+	}
+	if email == "" {
+		return fmt.Errorf("%s cannot be empty", field)
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return invalid("missing @")
+	}
+	local, domain := email[:at], email[at+1:]
+
+	switch {
+	case local == "":
+		return invalid("nothing before the @")
+	case len(local) > 64:
+		return invalid("the part before the @ is longer than 64 characters")
+	case strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") || strings.Contains(local, ".."):
+		return invalid("misplaced dot before the @")
+	}
+	for _, r := range local {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && !strings.ContainsRune(emailSpecials, r) {
+			return invalid(fmt.Sprintf("%q is not allowed before the @", r))
+		}
+	}
+
+	if domain == "" {
+		return invalid("nothing after the @")
+	}
+	if len(domain) > 253 {
+		return invalid("the domain is longer than 253 characters")
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return invalid("the domain has no dot")
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return invalid("misplaced dot in the domain")
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return invalid(fmt.Sprintf("domain label '%s' starts or ends with a hyphen", label))
+		}
+		for _, r := range label {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' {
+				return invalid(fmt.Sprintf("%q is not allowed in the domain", r))
+			}
+		}
+	}
+	return nil
+}
+
+// domainLookupTimeout bounds the DNS lookups of --verify-domain
+const domainLookupTimeout = 5 * time.Second
+
+// lookupMX and lookupHost resolve domains; tests replace them
+var (
+	lookupMX   = net.DefaultResolver.LookupMX
+	lookupHost = net.DefaultResolver.LookupHost
+)
+
+// verifyEmailDomain checks that the domain of an email can receive mail:
+// it has MX records, or an address mail falls back to (RFC 5321)
+func verifyEmailDomain(email string) error {
+	domain := email[strings.LastIndex(email, "@")+1:]
+	ctx, cancel := context.WithTimeout(context.Background(), domainLookupTimeout)
+	defer cancel()
+
+	records, mxErr := lookupMX(ctx, domain)
+	if mxErr == nil && len(records) > 0 {
+		// A lone "." is a null MX: the domain accepts no mail (RFC 7505)
+		if len(records) == 1 && strings.TrimSuffix(records[0].Host, ".") == "" {
+			return fmt.Errorf("%s accepts no mail", domain)
+		}
+		return nil
+	}
+	if addrs, err := lookupHost(ctx, domain); err == nil && len(addrs) > 0 {
+		return nil
+	}
+
+	var dnsErr *net.DNSError
+	if mxErr == nil || errors.As(mxErr, &dnsErr) && dnsErr.IsNotFound {
+		return fmt.Errorf("%s has no mail server", domain)
+	}
+	return fmt.Errorf("could not look up %s: %w", domain, mxErr)
+}
+
+// identityMatches reports whether name and email belong to profile,
+// comparing normalized forms and ignoring the case of the email
+func identityMatches(profile Profile, name, email string) bool {
+	return cleanIdentityText(profile.Name) == cleanIdentityText(name) &&
+		strings.EqualFold(cleanIdentityText(profile.Email), cleanIdentityText(email))
+}
+
+// hasCommitter reports whether a profile commits under a different
+// identity than it authors with
+func hasCommitter(profile Profile) bool {
+	return profile.CommitterName != "" || profile.CommitterEmail != ""
+}
+
+// committerIdentity returns the committer name and email of a profile,
+// falling back to the author identity for the parts not overridden
+func committerIdentity(profile Profile) (string, string) {
+	name, email := profile.Name, profile.Email
+	if profile.CommitterName != "" {
+		name = profile.CommitterName
+	}
+	if profile.CommitterEmail != "" {
+		email = profile.CommitterEmail
+	}
+	return name, email
+}
+
+// isAtext reports whether r may appear in an unquoted RFC 5322 phrase.
+// Non-ASCII letters are allowed as in RFC 6532
+func isAtext(r rune) bool {
+	if r > unicode.MaxASCII {
+		return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r)
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!#$%&'*+-/=?^_`{|}~ ", r)
+}
+
+// formatAddress formats name and email as an RFC 5322 address, quoting the
+// display name when it contains specials such as commas or periods
+func formatAddress(name, email string) string {
+	if strings.IndexFunc(name, func(r rune) bool { return !isAtext(r) }) < 0 {
+		return name + " <" + email + ">"
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name)
+	return `"` + escaped + `" <` + email + ">"
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestNormalizeNFC tests composing decomposed accented letters, whatever
+// order their marks come in
+func TestNormalizeNFC(t *testing.T) {
+	cases := map[string]string{
+		"Jose\u0301":         "Jos\u00e9",
+		"Jos\u00e9":          "Jos\u00e9",
+		"Nguye\u0302\u0303n": "Nguy\u1ec5n",
+		"Nguy\u00ea\u0303n":  "Nguy\u1ec5n",
+		"\u0418\u0306":       "\u0419",
+		"a\u0302\u0323":      "\u1ead",
+		"a\u0323\u0302":      "\u1ead",
+		"\u00e2\u0323":       "\u1ead",
+		"o\u0301\u031b":      "\u1edb",
+		"q\u0307\u0323":      "q\u0323\u0307",
+		"a\u0301\u0301":      "\u00e1\u0301",
+		"plain":              "plain",
+	}
+	for input, expected := range cases {
+		if got := normalizeNFC(input); got != expected {
+			t.Errorf("normalizeNFC(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+// TestCleanIdentityText tests whitespace normalization of names
+func TestCleanIdentityText(t *testing.T) {
+	cases := map[string]string{
+		"  John Doe  ":      "John Doe",
+		"John\u00a0Doe":     "John Doe",
+		"John \u3000 Doe\t": "John Doe",
+		"Rene\u0301 Dupont": "Ren\u00e9 Dupont",
+	}
+	for input, expected := range cases {
+		if got := cleanIdentityText(input); got != expected {
+			t.Errorf("cleanIdentityText(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+// TestControlCharacters tests detection of invisible characters
+func TestControlCharacters(t *testing.T) {
+	if found := controlCharacters("José García"); len(found) != 0 {
+		t.Errorf("Expected no control characters, got %q", found)
+	}
+	found := controlCharacters("John\u200bDoe\x07")
+	if len(found) != 2 || found[0] != '\u200b' || found[1] != '\x07' {
+		t.Errorf("Expected ZWSP and BEL, got %q", found)
+	}
+}
+
+// TestIdentityMatches tests matching normalized identities
+func TestIdentityMatches(t *testing.T) {
+	profile := Profile{Name: "Jos\u00e9 Garc\u00eda", Email: "jose@work.com"}
+	if !identityMatches(profile, "José García", "Jose@Work.com") {
+		t.Error("Expected decomposed name and email case to match")
+	}
+	if identityMatches(profile, "Jose Garcia", "jose@work.com") {
+		t.Error("Expected unaccented name not to match")
+	}
+}
+
+// TestFormatAddress tests RFC 5322 display name quoting
+func TestFormatAddress(t *testing.T) {
+	cases := []struct{ name, expected string }{
+		{"John Doe", "John Doe <j@x.com>"},
+		{"José García", "José García <j@x.com>"},
+		{"Doe, John", `"Doe, John" <j@x.com>`},
+		{"John Q. Doe", `"John Q. Doe" <j@x.com>`},
+		{`John "JD" Doe`, `"John \"JD\" Doe" <j@x.com>`},
+	}
+	for _, c := range cases {
+		if got := formatAddress(c.name, "j@x.com"); got != c.expected {
+			t.Errorf("formatAddress(%q) = %s, expected %s", c.name, got, c.expected)
+		}
+	}
+}
+
+// TestValidateEmail tests the email syntax check
+func TestValidateEmail(t *testing.T) {
+	valid := []string{
+		"john@company.com",
+		"john.doe+git@mail.company.co.uk",
+		"12345+jdoe@users.noreply.github.com",
+		"jörg@müller.de",
+		"o'brien@example.ie",
+	}
+	for _, email := range valid {
+		if err := validateEmail("email", email); err != nil {
+			t.Errorf("validateEmail(%q) = %v", email, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"john",
+		"@company.com",
+		"john@",
+		"john@localhost",
+		"john@@company.com",
+		"john doe@company.com",
+		".john@company.com",
+		"john..doe@company.com",
+		"john@company..com",
+		"john@-company.com",
+		"john@company_inc.com",
+		"<john@company.com>",
+		strings.Repeat("j", 65) + "@company.com",
+	}
+	for _, email := range invalid {
+		if err := validateEmail("email", email); err == nil {
+			t.Errorf("validateEmail(%q) accepted an invalid address", email)
+		}
+	}
+}
+
+// TestValidateName tests rejecting empty names and control characters
+// while allowing joiners some scripts need
+func TestValidateName(t *testing.T) {
+	if err := validateName("name", "\u0639\u0644\u06cc\u200c\u0631\u0636\u0627"); err != nil {
+		t.Errorf("Expected a name with a zero-width non-joiner to be valid, got %v", err)
+	}
+	for _, name := range []string{"", "John\x1b[31m", "John\x00"} {
+		if err := validateName("name", name); err == nil {
+			t.Errorf("validateName(%q) accepted an invalid name", name)
+		}
+	}
+}
+
+// TestVerifyEmailDomain tests the MX check with its fallback to addresses
+func TestVerifyEmailDomain(t *testing.T) {
+	mx := map[string][]*net.MX{
+		"company.com": {{Host: "mx1.company.com.", Pref: 10}},
+		"nomail.org":  {{Host: ".", Pref: 0}},
+	}
+	hosts := map[string][]string{"small.dev": {"192.0.2.1"}}
+	previousMX, previousHost := lookupMX, lookupHost
+	defer func() { lookupMX, lookupHost = previousMX, previousHost }()
+	lookupMX = func(ctx context.Context, domain string) ([]*net.MX, error) {
+		if records, ok := mx[domain]; ok {
+			return records, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	}
+	lookupHost = func(ctx context.Context, domain string) ([]string, error) {
+		if addrs, ok := hosts[domain]; ok {
+			return addrs, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	}
+
+	for email, ok := range map[string]bool{
+		"john@company.com": true,
+		"john@small.dev":   true,
+		"john@nomail.org":  false,
+		"john@typo.comm":   false,
+	} {
+		if err := verifyEmailDomain(email); (err == nil) != ok {
+			t.Errorf("verifyEmailDomain(%q) = %v", email, err)
+		}
+	}
+}
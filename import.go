@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// csvRow is a profile read from one row of an import CSV. Values holds
+// the non-empty cells by profile field key.
+type csvRow struct {
+	Line        int
+	ProfileName string
+	Values      map[string]string
+	Profile     Profile
+	Err         error
+}
+
+// parseProfileCSV reads profiles from CSV with a header row. The profile,
+// name and email columns are required; other columns are profile fields
+// (e.g. signingKey, pushRemote, or tags for the tag field) and unknown
+// columns are reported as ignored.
+func parseProfileCSV(r io.Reader) ([]csvRow, []string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("empty CSV file")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Spreadsheet exports often start with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	fieldsByLower := map[string]string{}
+	for key := range profileFields {
+		fieldsByLower[strings.ToLower(key)] = key
+	}
+
+	profileColumn := -1
+	columns := make([]string, len(header))
+	var ignored []string
+	for i, title := range header {
+		title = strings.ToLower(strings.TrimSpace(title))
+		if title == "profile" {
+			profileColumn = i
+			continue
+		}
+		if title == "tags" {
+			title = "tag"
+		}
+		if key, ok := fieldsByLower[title]; ok {
+			columns[i] = key
+			continue
+		}
+		ignored = append(ignored, strings.TrimSpace(header[i]))
+	}
+
+	var missing []string
+	if profileColumn < 0 {
+		missing = append(missing, "profile")
+	}
+	for _, required := range []string{"name", "email"} {
+		found := false
+		for _, column := range columns {
+			found = found || column == required
+		}
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("missing column(s): %s", strings.Join(missing, ", "))
+	}
+
+	var warnings []string
+	for _, title := range ignored {
+		warnings = append(warnings, fmt.Sprintf("Ignoring column '%s' (not a profile field)", title))
+	}
+
+	var rows []csvRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		blank := true
+		for _, cell := range record {
+			blank = blank && strings.TrimSpace(cell) == ""
+		}
+		if blank {
+			continue
+		}
+
+		row := csvRow{Line: line, Values: map[string]string{}}
+		if profileColumn < len(record) {
+			row.ProfileName = strings.TrimSpace(record[profileColumn])
+		}
+		for i, key := range columns {
+			if key != "" && i < len(record) && strings.TrimSpace(record[i]) != "" {
+				row.Values[key] = strings.TrimSpace(record[i])
+			}
+		}
+		row.Err = validateCSVRow(&row)
+		rows = append(rows, row)
+	}
+	return rows, warnings, nil
+}
+
+// applyCSVValues sets the profile fields of a row on profile. A tag cell
+// holds any number of tags separated by semicolons or whitespace
+func applyCSVValues(profile *Profile, values map[string]string) error {
+	for _, key := range sortedKeys(values) {
+		cells := []string{values[key]}
+		if key == "tag" {
+			cells = strings.FieldsFunc(values[key], func(r rune) bool {
+				return r == ';' || unicode.IsSpace(r)
+			})
+		}
+		for _, value := range cells {
+			if err := profileFields[key].set(profile, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCSVRow builds row.Profile from its values, checking the profile
+// name and that the identity looks usable
+func validateCSVRow(row *csvRow) error {
+	if err := validateImportName(row.ProfileName); err != nil {
+		return err
+	}
+
+	if err := applyCSVValues(&row.Profile, row.Values); err != nil {
+		return err
+	}
+
+	if err := validateName("name", row.Profile.Name); err != nil {
+		return err
+	}
+	return validateEmail("email", row.Profile.Email)
+}
+
+// validateImportName checks a profile name read from a file
+func validateImportName(profileName string) error {
+	if profileName == "" {
+		return fmt.Errorf("profile is empty")
+	}
+	if strings.ContainsAny(profileName, " \t") {
+		return fmt.Errorf("profile '%s' contains whitespace", profileName)
+	}
+	if reason := reservedProfileName(profileName); reason != "" {
+		return fmt.Errorf("profile '%s' can't be used: %s", profileName, reason)
+	}
+	return nil
+}
+
+// importCSV creates profiles from a CSV file ("-" for stdin). Existing
+// profiles are skipped unless update is set.
+func importCSV(path string, update, dryRun bool) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("❌ Failed to read %s: %v\n", path, err)
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	rows, warnings, err := parseProfileCSV(r)
+	if err != nil {
+		fmt.Printf("❌ Invalid CSV: %v\n", err)
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles := config.Profiles
+
+	created, updated, skipped, invalid := 0, 0, 0, 0
+	seen := map[string]int{}
+	for _, row := range rows {
+		switch existing, exists := profiles[row.ProfileName]; {
+		case row.Err != nil:
+			fmt.Printf("   ✗ line %d: %v\n", row.Line, row.Err)
+			invalid++
+		case seen[row.ProfileName] > 0:
+			fmt.Printf("   ✗ line %d: profile '%s' already defined on line %d\n", row.Line, row.ProfileName, seen[row.ProfileName])
+			invalid++
+		case exists && !update:
+			fmt.Printf("   - %s (exists, use --update to replace it)\n", row.ProfileName)
+			skipped++
+		case exists:
+			// Keep settings the CSV doesn't mention, like environment variables
+			profile := existing
+			applyCSVValues(&profile, row.Values)
+			profiles[row.ProfileName] = profile
+			fmt.Printf("   ~ %s (%s)\n", row.ProfileName, formatAddress(profile.Name, profile.Email))
+			updated++
+		default:
+			profiles[row.ProfileName] = row.Profile
+			fmt.Printf("   + %s (%s)\n", row.ProfileName, formatAddress(row.Profile.Name, row.Profile.Email))
+			created++
+		}
+		if _, ok := seen[row.ProfileName]; !ok && row.Err == nil {
+			seen[row.ProfileName] = row.Line
+		}
+	}
+
+	summary := fmt.Sprintf("%d created, %d updated, %d skipped, %d invalid", created, updated, skipped, invalid)
+	if dryRun {
+		fmt.Printf("\nDry run: %s\n", summary)
+	} else {
+		if created+updated > 0 {
+			if err := saveConfig(config); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("✅ Import finished: %s\n", summary)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("some rows were invalid")
+	}
+	return nil
+}
+
+// runImport handles the import command
+func runImport(args []string) error {
+	usage := "Usage: git usr import --csv <file|-> [--update] [--dry-run]"
+
+	path := ""
+	update, dryRun := false, false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--csv":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--csv requires a file")
+			}
+			path = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--csv="):
+			path = strings.TrimPrefix(args[i], "--csv=")
+		case args[i] == "--update":
+			update = true
+		case args[i] == "--dry-run":
+			dryRun = true
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+
+	if path == "" {
+		fmt.Println(usage)
+		return fmt.Errorf("no CSV file given")
+	}
+	return importCSV(path, update, dryRun)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseProfileCSV tests reading profiles and validating rows
+func TestParseProfileCSV(t *testing.T) {
+	input := "\ufeffProfile, Name, Email, Tags, pushRemote, Team\r\n" +
+		"work,John Doe,john@work.com,backend; oncall,fork,payments\r\n" +
+		"\r\n" +
+		"oss,\"Doe, John\",john@oss.dev,,\r\n" +
+		"bad name,Jane,jane@x.com,,\r\n" +
+		"noemail,Jane,jane,,\r\n" +
+		",Jane,jane@x.com,,\r\n" +
+		"short,Jane\r\n"
+
+	rows, warnings, err := parseProfileCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseProfileCSV failed: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Team") {
+		t.Errorf("Expected a warning for the Team column, got %v", warnings)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("Expected 6 rows, got %d", len(rows))
+	}
+
+	work := rows[0]
+	if work.Err != nil || work.ProfileName != "work" || work.Line != 2 {
+		t.Errorf("Unexpected row %+v", work)
+	}
+	if work.Profile.Name != "John Doe" || work.Profile.Email != "john@work.com" || work.Profile.PushRemote != "fork" {
+		t.Errorf("Unexpected profile %+v", work.Profile)
+	}
+	if !reflect.DeepEqual(work.Profile.Tags, []string{"backend", "oncall"}) {
+		t.Errorf("Expected the tags to be imported, got %v", work.Profile.Tags)
+	}
+
+	if rows[1].Err != nil || rows[1].Profile.Name != "Doe, John" || rows[1].Line != 4 {
+		t.Errorf("Unexpected quoted row %+v", rows[1])
+	}
+
+	for _, row := range rows[2:] {
+		if row.Err == nil {
+			t.Errorf("Expected line %d to be invalid", row.Line)
+		}
+	}
+}
+
+// TestParseProfileCSVMissingColumns tests that required columns are checked
+func TestParseProfileCSVMissingColumns(t *testing.T) {
+	_, _, err := parseProfileCSV(strings.NewReader("profile,name\nwork,John\n"))
+	if err == nil || !strings.Contains(err.Error(), "email") {
+		t.Errorf("Expected a missing email column error, got %v", err)
+	}
+
+	if _, _, err := parseProfileCSV(strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an empty file")
+	}
+}
@@ -17,7 +17,7 @@ if %ERRORLEVEL% NEQ 0 (
 REM Build the binary
 echo Building git-usr...
 cd /d "%SCRIPT_DIR%"
-go build -o git-usr.exe main.go
+go build -o git-usr.exe .
 
 if %ERRORLEVEL% NEQ 0 (
     echo Build failed
@@ -15,7 +15,7 @@ fi
 # Build the binary
 echo "Building git-usr..."
 cd "$SCRIPT_DIR"
-go build -o git-usr main.go
+go build -o git-usr .
 
 if [ $? -ne 0 ]; then
     echo "❌ Build failed"
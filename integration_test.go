@@ -0,0 +1,779 @@
+//go:build integration
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupIntegration generates fixtures and points the config at a fresh home
+func setupIntegration(t *testing.T) *TestdataManifest {
+	t.Helper()
+
+	manifest, err := generateTestdata(t.TempDir())
+	if err != nil {
+		t.Fatalf("generateTestdata failed: %v", err)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+
+	return manifest
+}
+
+// chdir changes into dir for the duration of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// installConfig copies a fixture config into the active config location
+func installConfig(t *testing.T, fixture string) {
+	t.Helper()
+
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath, err := getConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestIntegrationTestdataLayout tests that all fixtures are written
+func TestIntegrationTestdataLayout(t *testing.T) {
+	manifest := setupIntegration(t)
+
+	for name, path := range manifest.Configs {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Config fixture %s missing: %v", name, err)
+		}
+	}
+	for _, name := range []string{"matching", "mismatch", "unconfigured"} {
+		if _, err := os.Stat(filepath.Join(manifest.Repos[name], ".git")); err != nil {
+			t.Errorf("Repo fixture %s is not a git repository: %v", name, err)
+		}
+	}
+}
+
+// TestIntegrationSwitchProfile tests switching a sandbox repo to a profile
+func TestIntegrationSwitchProfile(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+
+	if err := switchProfile("work", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+
+	if email := getGitConfigValue("user.email"); email != manifest.Profiles["work"].Email {
+		t.Errorf("Expected %s, got: %s", manifest.Profiles["work"].Email, email)
+	}
+}
+
+// TestIntegrationApplySubmodules tests applying a profile to nested
+// submodules, which keep the identity they were cloned with otherwise
+func TestIntegrationApplySubmodules(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	root := t.TempDir()
+	commit := []string{"-c", "user.name=Stale", "-c", "user.email=stale@example.com", "commit", "--quiet", "--allow-empty", "-m", "init"}
+	for _, name := range []string{"leaf", "mid"} {
+		dir := filepath.Join(root, name)
+		if err := runGitIn(root, "init", "--quiet", name); err != nil {
+			t.Fatal(err)
+		}
+		if err := runGitIn(dir, commit...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runGitIn(filepath.Join(root, "mid"), "submodule", "--quiet", "add", filepath.Join(root, "leaf"), "leaf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGitIn(filepath.Join(root, "mid"), commit...); err != nil {
+		t.Fatal(err)
+	}
+	top := manifest.Repos["unconfigured"]
+	if err := runGitIn(top, "submodule", "--quiet", "add", filepath.Join(root, "mid"), "deps/mid"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGitIn(top, "submodule", "--quiet", "update", "--init", "--recursive"); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, top)
+	switchLog := addSwitchSideEffects(t, "work")
+
+	if err := runApply([]string{"work", "--submodules"}); err != nil {
+		t.Fatalf("runApply failed: %v", err)
+	}
+	for _, dir := range []string{top, filepath.Join(top, "deps", "mid"), filepath.Join(top, "deps", "mid", "leaf")} {
+		if email := gitConfigValue(dir, "local", "user.email"); email != manifest.Profiles["work"].Email {
+			t.Errorf("%s: expected %s, got %q", dir, manifest.Profiles["work"].Email, email)
+		}
+		checkSwitchSideEffects(t, dir, switchLog)
+	}
+}
+
+// addSwitchSideEffects gives a profile a trailer and adds an on-switch
+// script logging the repositories it runs for, returning the log's path
+func addSwitchSideEffects(t *testing.T, profileName string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("on-switch scripts are shell scripts here")
+	}
+
+	dir := t.TempDir()
+	switchLog := filepath.Join(dir, "switches.log")
+	script := filepath.Join(dir, "on-switch.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$GIT_USR_REPO\" >> '"+switchLog+"'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := config.Profiles[profileName]
+	profile.Trailers = []string{"Signed-off-by"}
+	config.Profiles[profileName] = profile
+	config.OnSwitch = []string{script}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	return switchLog
+}
+
+// checkSwitchSideEffects tests that applying a profile to the repository
+// at dir did what a switch does: install the trailer hook and run the
+// on-switch scripts
+func checkSwitchSideEffects(t *testing.T, dir, switchLog string) {
+	t.Helper()
+	hooksDir, err := runGit(dir, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(strings.TrimSpace(hooksDir), "prepare-commit-msg")); err != nil {
+		t.Errorf("%s: expected the trailer hook: %v", dir, err)
+	}
+	data, _ := os.ReadFile(switchLog)
+	if !containsString(strings.Split(string(data), "\n"), dir) {
+		t.Errorf("%s: expected the on-switch script to run, it ran for %q", dir, data)
+	}
+}
+
+// TestIntegrationInitSwitchesFully tests that init applies the default
+// profile the way a switch does
+func TestIntegrationInitSwitchesFully(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	repo := manifest.Repos["unconfigured"]
+	chdir(t, repo)
+	switchLog := addSwitchSideEffects(t, "work")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Settings.DefaultProfile = "work"
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := initRepo(false, true); err != nil {
+		t.Fatalf("initRepo failed: %v", err)
+	}
+	checkSwitchSideEffects(t, repo, switchLog)
+}
+
+// TestIntegrationPinDrift tests that a global change drifting from the
+// pinned profile is reported, and that init applies the pin
+func TestIntegrationPinDrift(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+
+	if err := runPin([]string{"work", "--tracked"}); err != nil {
+		t.Fatalf("runPin failed: %v", err)
+	}
+	if err := initRepo(false, true); err != nil {
+		t.Fatalf("initRepo failed: %v", err)
+	}
+	if state, _ := promptCheckState(); state != promptCheckOK {
+		t.Errorf("Expected state %d after init, got %d", promptCheckOK, state)
+	}
+
+	// Without a local identity, a global switch changes who commits here
+	if err := gitConfig.Unset("", "local", "user.email", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitConfig.Unset("", "local", "user.name", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := switchProfile("personal", "global"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if state, _ := promptCheckState(); state != promptCheckMismatch {
+		t.Errorf("Expected state %d after drifting, got %d", promptCheckMismatch, state)
+	}
+	info, err := computePromptInfo()
+	if err != nil || !strings.Contains(identityWarning(info), "pinned to 'work'") {
+		t.Errorf("Expected a drift warning, got %q (%v)", identityWarning(info), err)
+	}
+}
+
+// TestIntegrationProjectPolicy tests that a project file's email domain
+// is enforced by check and the guard hook, and picks the profile on init
+func TestIntegrationProjectPolicy(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+	if err := os.WriteFile(".git-usr.yaml", []byte("emailDomain: example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a default profile, the profile the policy allows is applied
+	if err := initRepo(false, true); err != nil {
+		t.Fatalf("initRepo failed: %v", err)
+	}
+	if email := getScopedGitConfigValue("local", "user.email"); email != manifest.Profiles["work"].Email {
+		t.Errorf("Expected the local email %s, got: %s", manifest.Profiles["work"].Email, email)
+	}
+	if err := guardCommit(); err != nil {
+		t.Errorf("Expected the guard to allow %s: %v", manifest.Profiles["work"].Email, err)
+	}
+
+	if err := switchProfile("personal", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if state, _ := promptCheckState(); state != promptCheckMismatch {
+		t.Errorf("Expected state %d for a disallowed email, got %d", promptCheckMismatch, state)
+	}
+	info, err := computePromptInfo()
+	if err != nil || !strings.Contains(identityWarning(info), "as .git-usr.yaml requires (run 'git usr apply work')") {
+		t.Errorf("Expected a policy warning, got %q (%v)", identityWarning(info), err)
+	}
+	if err := guardCommit(); err == nil {
+		t.Errorf("Expected the guard to block %s", manifest.Profiles["personal"].Email)
+	}
+	if err := runCheck([]string{"--pre-commit", "main.go"}); err == nil {
+		t.Errorf("Expected check --pre-commit to fail for %s", manifest.Profiles["personal"].Email)
+	}
+}
+
+// TestIntegrationRules tests that the rule matching a repository's remote
+// is reported, picked by switch --auto and enforced by the guard hook
+func TestIntegrationRules(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+	if err := runGitIn(".", "remote", "add", "origin", "git@github.com:acme/app.git"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runRules([]string{"add", "--remote", "github.com/acme/**", "--profile", "work"}); err != nil {
+		t.Fatalf("rules add failed: %v", err)
+	}
+
+	if err := switchProfile("personal", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if state, _ := promptCheckState(); state != promptCheckMismatch {
+		t.Errorf("Expected state %d when breaking a rule, got %d", promptCheckMismatch, state)
+	}
+	info, err := computePromptInfo()
+	if err != nil || !strings.Contains(identityWarning(info), "breaks rule 1") {
+		t.Errorf("Expected a rule warning, got %q (%v)", identityWarning(info), err)
+	}
+	if err := guardCommit(); err == nil {
+		t.Error("Expected the guard to block a commit breaking the rule")
+	}
+
+	if err := runSwitchSubcommand([]string{"--auto"}); err != nil {
+		t.Fatalf("switch --auto failed: %v", err)
+	}
+	if email := getScopedGitConfigValue("local", "user.email"); email != manifest.Profiles["work"].Email {
+		t.Errorf("Expected the local email %s, got: %s", manifest.Profiles["work"].Email, email)
+	}
+	if err := guardCommit(); err != nil {
+		t.Errorf("Expected the guard to allow the rule's profile: %v", err)
+	}
+}
+
+// TestIntegrationProfileScope tests that a profile's own scope is used
+// when switching without one
+func TestIntegrationProfileScope(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+	if err := updateProfileField("personal", "scope", "global", false); err != nil {
+		t.Fatalf("updateProfileField failed: %v", err)
+	}
+
+	if err := switchProfile("personal", ""); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if email := getScopedGitConfigValue("global", "user.email"); email != manifest.Profiles["personal"].Email {
+		t.Errorf("Expected the global email %s, got: %s", manifest.Profiles["personal"].Email, email)
+	}
+	if email := getScopedGitConfigValue("local", "user.email"); email != "" {
+		t.Errorf("Expected no local email, got: %s", email)
+	}
+
+	// An explicit scope wins
+	if err := switchProfile("personal", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if email := getScopedGitConfigValue("local", "user.email"); email != manifest.Profiles["personal"].Email {
+		t.Errorf("Expected the local email %s, got: %s", manifest.Profiles["personal"].Email, email)
+	}
+}
+
+// TestIntegrationAddFromCurrent tests saving a repository's identity and
+// signing key as a profile
+func TestIntegrationAddFromCurrent(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["mismatch"])
+	if err := runGitIn(".", "config", "user.signingkey", "ABCD1234"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := addProfile("snapshot", "", "", addOptions{FromCurrent: true}); err != nil {
+		t.Fatalf("addProfile failed: %v", err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := config.Profiles["snapshot"]
+	if profile.Email != getGitConfigValue("user.email") || profile.Name != getGitConfigValue("user.name") || profile.SigningKey != "ABCD1234" {
+		t.Errorf("Expected the repository's identity, got %+v", profile)
+	}
+}
+
+// TestIntegrationLockedConfig tests loading the encrypted fixture
+func TestIntegrationLockedConfig(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["locked"])
+	t.Setenv("GIT_USR_PASSPHRASE", manifest.Passphrase)
+	t.Cleanup(func() { configPassphrase, configLocked = "", false })
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		t.Fatalf("loadProfiles failed: %v", err)
+	}
+	if len(profiles) != len(manifest.Profiles) {
+		t.Errorf("Expected %d profiles, got: %d", len(manifest.Profiles), len(profiles))
+	}
+}
+
+// TestIntegrationPromptCheck tests the prompt --check exit code contract
+func TestIntegrationPromptCheck(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+
+	cases := map[string]int{
+		"matching":     promptCheckOK,
+		"mismatch":     promptCheckMismatch,
+		"unconfigured": promptCheckNoIdentity,
+		"not-a-repo":   promptCheckNotRepo,
+	}
+	for repo, expected := range cases {
+		t.Run(repo, func(t *testing.T) {
+			chdir(t, manifest.Repos[repo])
+
+			state, err := promptCheckState()
+			if err != nil {
+				t.Fatalf("promptCheckState failed: %v", err)
+			}
+			if state != expected {
+				t.Errorf("Expected %d, got: %d", expected, state)
+			}
+		})
+	}
+}
+
+// TestIntegrationInitRepo tests applying the default profile to a repository
+func TestIntegrationInitRepo(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	if err := setSetting("defaultProfile", "personal"); err != nil {
+		t.Fatalf("setSetting failed: %v", err)
+	}
+
+	chdir(t, manifest.Repos["unconfigured"])
+	if err := initRepo(false, true); err != nil {
+		t.Fatalf("initRepo failed: %v", err)
+	}
+	if email := getScopedGitConfigValue("local", "user.email"); email != manifest.Profiles["personal"].Email {
+		t.Errorf("Expected %s, got: %s", manifest.Profiles["personal"].Email, email)
+	}
+
+	// Existing local identities are left alone without --force
+	chdir(t, manifest.Repos["mismatch"])
+	if err := initRepo(false, true); err != nil {
+		t.Fatalf("initRepo failed: %v", err)
+	}
+	if email := getScopedGitConfigValue("local", "user.email"); email == manifest.Profiles["personal"].Email {
+		t.Error("initRepo overwrote an existing identity")
+	}
+}
+
+// TestIntegrationBranchHooks tests installing and removing the branch hooks
+func TestIntegrationBranchHooks(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+
+	chdir(t, manifest.Repos["unconfigured"])
+	if err := installBranchHooks(); err != nil {
+		t.Fatalf("installBranchHooks failed: %v", err)
+	}
+	for _, hook := range branchHookNames {
+		info, err := os.Stat(filepath.Join(".git", "hooks", hook))
+		if err != nil || info.Mode().Perm()&0100 == 0 {
+			t.Errorf("%s hook not installed as executable: %v", hook, err)
+		}
+	}
+
+	if err := uninstallBranchHooks(); err != nil {
+		t.Fatalf("uninstallBranchHooks failed: %v", err)
+	}
+	for _, hook := range branchHookNames {
+		if _, err := os.Stat(filepath.Join(".git", "hooks", hook)); err == nil {
+			t.Errorf("%s hook not removed", hook)
+		}
+	}
+
+	// Hooks written by other tools are kept, chained and put back
+	foreign := filepath.Join(".git", "hooks", "post-merge")
+	foreignScript := []byte("#!/bin/sh\nnpm install\n")
+	if err := os.WriteFile(foreign, foreignScript, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := installBranchHooks(); err != nil {
+		t.Fatalf("installBranchHooks failed with a foreign hook: %v", err)
+	}
+	if data, err := os.ReadFile(foreign + chainedHookSuffix); err != nil || string(data) != string(foreignScript) {
+		t.Errorf("Foreign hook not kept for chaining: %q (%v)", data, err)
+	}
+	if err := uninstallBranchHooks(); err != nil {
+		t.Fatalf("uninstallBranchHooks failed: %v", err)
+	}
+	if data, err := os.ReadFile(foreign); err != nil || string(data) != string(foreignScript) {
+		t.Errorf("Foreign hook not restored: %q (%v)", data, err)
+	}
+}
+
+// TestIntegrationChainedHook tests that an existing pre-commit hook still
+// runs, and still stops commits, once git-usr installed its hooks
+func TestIntegrationChainedHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks need sh")
+	}
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["matching"])
+
+	marker, err := filepath.Abs("ran")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lint := "#!/bin/sh\ntouch '" + marker + "'\n[ ! -e .block ]\n"
+	if err := os.WriteFile(filepath.Join(".git", "hooks", "pre-commit"), []byte(lint), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := runHooks([]string{"install"}); err != nil {
+		t.Fatalf("hooks install failed: %v", err)
+	}
+
+	if err := runGitIn(".", "commit", "--quiet", "--allow-empty", "-m", "chained"); err != nil {
+		t.Errorf("Commit failed: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("The chained pre-commit hook didn't run")
+	}
+	if err := os.WriteFile(".block", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGitIn(".", "commit", "--quiet", "--allow-empty", "-m", "blocked"); err == nil {
+		t.Error("Expected the chained pre-commit hook to stop the commit")
+	}
+}
+
+// TestIntegrationURLRewrites tests that switching swaps insteadOf rewrites
+func TestIntegrationURLRewrites(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+
+	profiles := map[string]Profile{
+		"work":     {HostAliases: map[string]string{"github.com": "github.com-work"}},
+		"personal": {URLRewrites: map[string]string{"https://github.com/me/": "git@github.com:me/"}},
+	}
+
+	if _, err := applyURLRewrites(profiles, "work", "local"); err != nil {
+		t.Fatalf("applyURLRewrites failed: %v", err)
+	}
+	if value := getScopedGitConfigValue("local", "url.git@github.com-work:.insteadOf"); value != "git@github.com:" {
+		t.Errorf("Expected work rewrite, got %q", value)
+	}
+
+	if _, err := applyURLRewrites(profiles, "personal", "local"); err != nil {
+		t.Fatalf("applyURLRewrites failed: %v", err)
+	}
+	if value := getScopedGitConfigValue("local", "url.git@github.com-work:.insteadOf"); value != "" {
+		t.Errorf("Expected work rewrite to be removed, got %q", value)
+	}
+	if value := getScopedGitConfigValue("local", "url.git@github.com:me/.insteadOf"); value != "https://github.com/me/" {
+		t.Errorf("Expected personal rewrite, got %q", value)
+	}
+}
+
+// TestIntegrationManagedKeys tests that removing a profile retracts what it set
+func TestIntegrationManagedKeys(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+
+	if err := switchProfile("work", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+
+	state, err := loadState()
+	if err != nil {
+		t.Fatalf("loadState failed: %v", err)
+	}
+	if len(state.Keys) != 2 || state.Keys[0].Profile != "work" || state.Keys[0].Repo == "" {
+		t.Fatalf("Unexpected state: %v", state.Keys)
+	}
+
+	if err := removeProfiles([]string{"work"}, removeOptions{}); err != nil {
+		t.Fatalf("removeProfiles failed: %v", err)
+	}
+	if value := getScopedGitConfigValue("local", "user.email"); value != "" {
+		t.Errorf("Expected user.email to be retracted, got %q", value)
+	}
+
+	state, _ = loadState()
+	if len(state.Keys) != 0 {
+		t.Errorf("Expected empty state, got %v", state.Keys)
+	}
+}
+
+// TestIntegrationShadowedAlias tests that a user's own alias replaced by a
+// profile's is put back when switching away
+func TestIntegrationShadowedAlias(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["valid"])
+	chdir(t, manifest.Repos["unconfigured"])
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	work := config.Profiles["work"]
+	work.Aliases = map[string]string{"co": "checkout -q"}
+	config.Profiles["work"] = work
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+	if err := gitConfig.Set("", "local", "alias.co", "checkout --my-own"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := switchProfile("work", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if value := getScopedGitConfigValue("local", "alias.co"); value != "checkout -q" {
+		t.Errorf("Expected the profile's alias, got %q", value)
+	}
+
+	if err := switchProfile("personal", "local"); err != nil {
+		t.Fatalf("switchProfile failed: %v", err)
+	}
+	if value := getScopedGitConfigValue("local", "alias.co"); value != "checkout --my-own" {
+		t.Errorf("Expected the user's own alias back, got %q", value)
+	}
+	if state, _ := loadState(); len(state.Shadowed) != 0 {
+		t.Errorf("Expected the shadowed alias to be forgotten, got %v", state.Shadowed)
+	}
+}
+
+// TestIntegrationCorruptConfig tests that a corrupt config is reported and
+// left untouched when there is no terminal to prompt on
+func TestIntegrationCorruptConfig(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["corrupt"])
+
+	stdin := os.Stdin
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	if _, err := loadConfig(); err == nil {
+		t.Fatal("Expected loadConfig to fail on a corrupt config")
+	}
+
+	configPath, _ := getConfigPath()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Corrupt config was moved: %v", err)
+	}
+	expected, _ := os.ReadFile(manifest.Configs["corrupt"])
+	if string(data) != string(expected) {
+		t.Error("Corrupt config was modified")
+	}
+}
+
+// TestIntegrationUnsafeRepository tests detection of git's dubious ownership
+// check; it needs root to hand a repository to another user
+func TestIntegrationUnsafeRepository(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root to change repository ownership")
+	}
+	manifest := setupIntegration(t)
+	t.Setenv("SUDO_UID", "")
+
+	repo := manifest.Repos["unconfigured"]
+	if err := os.Chown(repo, 65534, 65534); err != nil {
+		t.Skipf("chown failed: %v", err)
+	}
+	chdir(t, repo)
+
+	if _, err := isInsideWorkTree(); !isUnsafeRepository(err) {
+		t.Errorf("Expected an UnsafeRepositoryError, got %v", err)
+	}
+	if state, _ := promptCheckState(); state != promptCheckUnsafe {
+		t.Errorf("Expected prompt state %d, got %d", promptCheckUnsafe, state)
+	}
+	if err := setGitConfig("John Doe", "john@work.com", "local"); !isUnsafeRepository(err) {
+		t.Errorf("Expected setGitConfig to report an UnsafeRepositoryError, got %v", err)
+	}
+}
+
+// TestIntegrationScriptCRLF tests that generated scripts still run after
+// being converted to CRLF and are made executable when rewritten
+func TestIntegrationScriptCRLF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix sh")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hook")
+
+	script := shellScript("test", "if [ \"$1\" = \"x\" ]; then\n    echo \"ran $1 $2\"\nfi\n")
+	crlf := strings.ReplaceAll(script, "\n", "\r\n")
+	if err := os.WriteFile(path, []byte(crlf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("sh", path, "x", "y").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "ran x y" {
+		t.Errorf("CRLF script failed: %v: %q", err, out)
+	}
+
+	if err := writeScript(path, crlf); err != nil {
+		t.Fatalf("writeScript failed: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("Script is not executable: %v", info.Mode())
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "\r") {
+		t.Error("writeScript kept carriage returns")
+	}
+}
+
+// TestIntegrationLockedConfigNoPrompt tests that the prompt, check and hook
+// entry points report a locked config as unknown instead of waiting for
+// its passphrase
+func TestIntegrationLockedConfigNoPrompt(t *testing.T) {
+	manifest := setupIntegration(t)
+	installConfig(t, manifest.Configs["locked"])
+	t.Setenv("GIT_USR_PASSPHRASE", "")
+	t.Cleanup(func() { configPassphrase, configLocked, promptsDisabled = "", false, false })
+	chdir(t, manifest.Repos["matching"])
+
+	// Nothing is ever written to stdin, so reading a passphrase would block
+	stdin := os.Stdin
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	entries := map[string]func() error{
+		"prompt --check": func() error {
+			var exitErr *ExitError
+			if err := runPrompt([]string{"--check"}); !errors.As(err, &exitErr) || exitErr.Code != promptCheckUnknown {
+				return fmt.Errorf("expected exit code %d, got %v", promptCheckUnknown, err)
+			}
+			return nil
+		},
+		"prompt":        func() error { return runPrompt([]string{"--format", "json"}) },
+		"check":         func() error { return runCheck(nil) },
+		"post-checkout": func() error { return runHook([]string{"post-checkout", "a", "b", "1"}) },
+		"pre-commit":    func() error { return runHook([]string{"pre-commit"}) },
+	}
+	for name, entry := range entries {
+		promptsDisabled = false
+		done := make(chan error, 1)
+		go func() { done <- entry() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s waited for a passphrase", name)
+		}
+	}
+}
+
+// TestIntegrationSchemaFixtures tests that the fixtures for rules,
+// directory rules, settings and co-authors load back as generated
+func TestIntegrationSchemaFixtures(t *testing.T) {
+	manifest := setupIntegration(t)
+
+	for name, expected := range testdataConfigs(manifest.Root, manifest.Profiles) {
+		installConfig(t, manifest.Configs[name])
+		config, err := loadConfig()
+		if err != nil {
+			t.Errorf("%s: loadConfig failed: %v", name, err)
+			continue
+		}
+		config.store, config.localProfiles, config.storeProfiles = nil, nil, nil
+		if !reflect.DeepEqual(config, expected) {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, config)
+		}
+	}
+}
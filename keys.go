@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signingFormats are the values of gpg.format a profile can sign with
+var signingFormats = []string{"openpgp", "ssh", "x509"}
+
+// signingKeys returns the git config values that enable commit and tag
+// signing for a profile, or nothing if it has no signing key
+func signingKeys(profile Profile) []ManagedKey {
+	if !signsCommits(profile) {
+		return nil
+	}
+	format := profile.SigningFormat
+	if format == "" {
+		format = "openpgp"
+	}
+	keys := []ManagedKey{{Key: "gpg.format", Value: format}}
+	if profile.SigningKey != "" {
+		keys = append(keys, ManagedKey{Key: "user.signingkey", Value: profile.SigningKey})
+	}
+	keys = append(keys,
+		ManagedKey{Key: "commit.gpgsign", Value: "true"},
+		ManagedKey{Key: "tag.gpgsign", Value: "true"},
+	)
+	if format == "x509" && profile.X509Program != "" {
+		keys = append(keys, ManagedKey{Key: "gpg.x509.program", Value: profile.X509Program})
+	}
+	return append(keys, gitsignKeys(profile)...)
+}
+
+// signsCommits reports whether a profile signs, with a key or keyless
+// with gitsign
+func signsCommits(profile Profile) bool {
+	return profile.SigningKey != "" || usesGitsign(profile)
+}
+
+// gpgKey is a secret OpenPGP key that can sign
+type gpgKey struct {
+	Fingerprint string
+	UID         string
+	Expires     time.Time
+}
+
+// gpgEscapePattern matches the \xNN escapes of gpg's colon listings
+var gpgEscapePattern = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
+
+// parseGPGSecretKeys parses `gpg --list-secret-keys --with-colons` output,
+// keeping only valid keys with signing capability
+func parseGPGSecretKeys(listing string) []gpgKey {
+	var keys []gpgKey
+	var current *gpgKey
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 10 {
+			continue
+		}
+		switch fields[0] {
+		case "sec":
+			current = nil
+			// Skip revoked, expired and disabled keys
+			if strings.ContainsAny(fields[1], "reid") || len(fields) < 12 || !strings.Contains(fields[11], "S") {
+				continue
+			}
+			key := gpgKey{}
+			if seconds, err := strconv.ParseInt(fields[6], 10, 64); err == nil {
+				key.Expires = time.Unix(seconds, 0)
+			}
+			keys = append(keys, key)
+			current = &keys[len(keys)-1]
+		case "fpr":
+			if current != nil && current.Fingerprint == "" {
+				current.Fingerprint = fields[9]
+			}
+		case "uid":
+			if current != nil && current.UID == "" {
+				current.UID = gpgEscapePattern.ReplaceAllStringFunc(fields[9], func(escape string) string {
+					b, _ := strconv.ParseUint(escape[2:], 16, 8)
+					return string([]byte{byte(b)})
+				})
+			}
+		}
+	}
+	return keys
+}
+
+// listGPGKeys returns the secret signing keys with a user ID for email
+func listGPGKeys(email string) ([]gpgKey, error) {
+	// <email> makes gpg match the address exactly instead of as a substring
+	out, err := exec.Command("gpg", "--list-secret-keys", "--with-colons", "--fixed-list-mode", "<"+email+">").Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gpg --list-secret-keys: %w", err)
+	}
+	return parseGPGSecretKeys(string(out)), nil
+}
+
+// generateGPGKey creates an ed25519 signing key for the profile, letting
+// gpg ask for the passphrase unless noPassphrase is set
+func generateGPGKey(profile Profile, noPassphrase bool) error {
+	args := []string{"--quick-generate-key", formatAddress(profile.Name, profile.Email), "ed25519", "sign", "2y"}
+	if noPassphrase {
+		args = append([]string{"--batch", "--passphrase", ""}, args...)
+	}
+
+	cmd := exec.Command("gpg", args...)
+	attachTerminal(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gpg --quick-generate-key: %w", err)
+	}
+	return nil
+}
+
+// chooseGPGKey asks which of several keys to use
+func chooseGPGKey(keys []gpgKey) (gpgKey, error) {
+	fmt.Println("Several signing keys match:")
+	for i, key := range keys {
+		expires := "never expires"
+		if !key.Expires.IsZero() {
+			expires = "expires " + key.Expires.Format("2006-01-02")
+		}
+		fmt.Printf("  [%d] %s  %s (%s)\n", i+1, key.Fingerprint, key.UID, expires)
+	}
+	fmt.Print("Choice: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return gpgKey{}, err
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(keys) {
+		return gpgKey{}, fmt.Errorf("invalid choice")
+	}
+	return keys[choice-1], nil
+}
+
+// setupGPGKey selects or generates a GPG key for the profile, stores it as
+// the profile's signing key and prints the public key for uploading
+func setupGPGKey(profileName, keyID string, generate, noPassphrase bool) error {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		fmt.Println("❌ gpg not found in PATH")
+		return fmt.Errorf("gpg not found")
+	}
+
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	if keyID == "" {
+		keys, err := listGPGKeys(profile.Email)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case generate || len(keys) == 0:
+			if !generate && !isInteractive() {
+				fmt.Printf("❌ No signing key for %s (use --generate to create one)\n", profile.Email)
+				return fmt.Errorf("no gpg key")
+			}
+			fmt.Printf("🔑 Generating an ed25519 signing key for %s <%s>\n", profile.Name, profile.Email)
+			if err := generateGPGKey(profile, noPassphrase); err != nil {
+				return err
+			}
+			if keys, err = listGPGKeys(profile.Email); err != nil {
+				return err
+			}
+			if len(keys) == 0 {
+				return fmt.Errorf("generated key not found")
+			}
+			// The newest key is listed last
+			keyID = keys[len(keys)-1].Fingerprint
+		case len(keys) == 1:
+			keyID = keys[0].Fingerprint
+		case !isInteractive():
+			fmt.Printf("❌ Several signing keys match %s (use --key to pick one)\n", profile.Email)
+			return fmt.Errorf("ambiguous gpg key")
+		default:
+			key, err := chooseGPGKey(keys)
+			if err != nil {
+				return err
+			}
+			keyID = key.Fingerprint
+		}
+	}
+
+	publicKey, err := exec.Command("gpg", "--armor", "--export", keyID).Output()
+	if err != nil || len(publicKey) == 0 {
+		fmt.Printf("❌ Key '%s' not found in your keyring\n", keyID)
+		return fmt.Errorf("gpg key not found")
+	}
+
+	if err := updateProfileSigning(profileName, "openpgp", keyID, ""); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ '%s' now signs commits and tags with GPG key %s\n", profileName, keyID)
+	fmt.Printf("   Run 'git usr %s' to apply it\n", profileName)
+	fmt.Println("\nUpload this public key so your signatures show as verified:")
+	fmt.Println("   GitHub: https://github.com/settings/gpg/new")
+	fmt.Println("   GitLab: https://gitlab.com/-/user_settings/gpg_keys")
+	fmt.Println()
+	fmt.Print(string(publicKey))
+	return nil
+}
+
+// updateProfileSigning stores the signing format, key and X.509 signing
+// program of a profile
+func updateProfileSigning(profileName, format, key, x509Program string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		return errProfileNotFound
+	}
+
+	profile.SigningFormat = format
+	profile.SigningKey = key
+	profile.X509Program = x509Program
+	config.Profiles[profileName] = profile
+	return saveConfig(config)
+}
+
+// runKeys handles the keys command
+func runKeys(args []string) error {
+	usage := "Usage: git usr keys setup <profile> --gpg|--ssh-signing|--x509|--gitsign [--key <id|path>] [--generate] [--no-passphrase] [--program <x509 program>] [--connector github|google|microsoft|<url>]"
+
+	if len(args) < 2 || args[0] != "setup" {
+		fmt.Println(usage)
+		return fmt.Errorf("invalid keys command")
+	}
+
+	profileName := args[1]
+	keyType := ""
+	generate, noPassphrase := false, false
+	key, program, connector := "", "", ""
+	for i := 2; i < len(args); i++ {
+		switch {
+		case args[i] == "--gpg" || args[i] == "--ssh-signing" || args[i] == "--x509" || args[i] == "--gitsign":
+			if keyType != "" && keyType != args[i] {
+				fmt.Println(usage)
+				return fmt.Errorf("--gpg, --ssh-signing, --x509 and --gitsign are mutually exclusive")
+			}
+			keyType = args[i]
+		case args[i] == "--connector":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--connector requires a value")
+			}
+			connector = args[i+1]
+			i++
+		case args[i] == "--program":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--program requires a value")
+			}
+			program = args[i+1]
+			i++
+		case args[i] == "--generate":
+			generate = true
+		case args[i] == "--no-passphrase":
+			noPassphrase = true
+		case args[i] == "--key":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--key requires a value")
+			}
+			key = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--key="):
+			key = strings.TrimPrefix(args[i], "--key=")
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+
+	switch keyType {
+	case "--gpg":
+		return setupGPGKey(profileName, key, generate, noPassphrase)
+	case "--ssh-signing":
+		return setupSSHSigning(profileName, key, generate, noPassphrase)
+	case "--x509":
+		return setupX509Signing(profileName, key, program)
+	case "--gitsign":
+		return setupGitsign(profileName, connector)
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("no key type given")
+}
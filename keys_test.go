@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+// TestParseGPGSecretKeys tests parsing gpg colon listings
+func TestParseGPGSecretKeys(t *testing.T) {
+	listing := `sec:u:255:22:39FF5B40007EAD1D:1792167340:1855239340::u:::scSC:::+::ed25519:::0:
+fpr:::::::::45DA74C8DCDBA21D84DA1DE839FF5B40007EAD1D:
+grp:::::::::6E73F9DA5E7B170EF252FD1DBEB90BF74DDD48A8:
+uid:u::::1792167340::D7DE12CAA5B109D6FCB5F499F3AB037424359D3E::J\xc3\xb6hn Doe \x3cwork\x3a1\x3e <john@work.com>::::::::::0:
+ssb:u:255:18:1111111111111111:1792167340::::::e:::+::cv25519::
+fpr:::::::::2222222222222222222222222222222222222222:
+sec:r:255:22:AAAAAAAAAAAAAAAA:1792167340:::u:::scSC:::+::ed25519:::0:
+fpr:::::::::AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA:
+uid:r::::1792167340::D7DE12CAA5B109D6FCB5F499F3AB037424359D3E::Revoked <john@work.com>::::::::::0:
+sec:u:255:22:BBBBBBBBBBBBBBBB:1792167340:::u:::eE:::+::ed25519:::0:
+fpr:::::::::BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB:
+`
+	keys := parseGPGSecretKeys(listing)
+	if len(keys) != 1 {
+		t.Fatalf("Expected 1 signing key, got %v", keys)
+	}
+	if keys[0].Fingerprint != "45DA74C8DCDBA21D84DA1DE839FF5B40007EAD1D" {
+		t.Errorf("Unexpected fingerprint %s", keys[0].Fingerprint)
+	}
+	if keys[0].UID != "Jöhn Doe <work:1> <john@work.com>" {
+		t.Errorf("Unexpected UID %q", keys[0].UID)
+	}
+	if keys[0].Expires.Unix() != 1855239340 {
+		t.Errorf("Unexpected expiry %v", keys[0].Expires)
+	}
+}
+
+// TestSigningKeys tests the git config written for signing profiles
+func TestSigningKeys(t *testing.T) {
+	if keys := signingKeys(Profile{}); len(keys) != 0 {
+		t.Errorf("Expected no signing config without a key, got %v", keys)
+	}
+
+	keys := signingKeys(Profile{SigningKey: "ABCD"})
+	expected := map[string]string{
+		"gpg.format":      "openpgp",
+		"user.signingkey": "ABCD",
+		"commit.gpgsign":  "true",
+		"tag.gpgsign":     "true",
+	}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %v", len(expected), keys)
+	}
+	for _, key := range keys {
+		if expected[key.Key] != key.Value {
+			t.Errorf("%s: expected %s, got %s", key.Key, expected[key.Key], key.Value)
+		}
+	}
+}
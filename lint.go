@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lintFinding is a configuration smell and how to fix it
+type lintFinding struct {
+	Profile string
+	Problem string
+	Fix     string
+}
+
+// lintContext is what the lint checks look at
+type lintContext struct {
+	Config       *Config
+	State        *ManagedState
+	Now          time.Time
+	UnusedMonths int
+}
+
+// lintCheck is a single lint rule
+type lintCheck struct {
+	name  string
+	check func(ctx lintContext) []lintFinding
+}
+
+// lintChecks are run by `git usr lint` in order
+var lintChecks = []lintCheck{
+	{"placeholder", lintPlaceholders},
+	{"duplicate", lintDuplicates},
+	{"reserved", lintReservedNames},
+	{"signing", lintSigningRequired},
+	{"rule", lintUnmatchableRules},
+	{"unused", lintUnused},
+	{"overlap", lintOverlappingDirs},
+}
+
+// placeholderLocalParts and placeholderDomains are email parts that suggest
+// an identity was never filled in
+var (
+	placeholderLocalParts = []string{"you", "your", "yourname", "your.name", "user", "username", "email", "name", "test", "changeme", "foo", "john.doe", "jane.doe"}
+	placeholderDomains    = []string{"example.com", "example.org", "example.net", "company.com", "domain.com", "email.com", "localhost"}
+)
+
+// isPlaceholderEmail reports whether email looks like a placeholder
+func isPlaceholderEmail(email string) bool {
+	local, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return false
+	}
+	for _, placeholder := range placeholderLocalParts {
+		if local == placeholder {
+			return true
+		}
+	}
+	for _, placeholder := range placeholderDomains {
+		if domain == placeholder {
+			return true
+		}
+	}
+	// Reserved for documentation and testing by RFC 2606
+	return strings.HasSuffix(domain, ".example") || strings.HasSuffix(domain, ".invalid") ||
+		strings.HasSuffix(domain, ".test") || strings.HasSuffix(domain, ".localhost")
+}
+
+// isPlaceholderName reports whether name looks like a placeholder, such as
+// the "Your Work Name" of the seeded profiles
+func isPlaceholderName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "your ") || lower == "john doe" || lower == "jane doe" || lower == "name"
+}
+
+// lintPlaceholders flags names and emails that were never filled in
+func lintPlaceholders(ctx lintContext) []lintFinding {
+	var findings []lintFinding
+	for _, name := range sortedProfileNames(ctx.Config.Profiles) {
+		profile := ctx.Config.Profiles[name]
+		if isPlaceholderName(profile.Name) {
+			findings = append(findings, lintFinding{
+				Profile: name,
+				Problem: fmt.Sprintf("name '%s' looks like a placeholder", profile.Name),
+				Fix:     fmt.Sprintf("git usr profile set %s name \"<your name>\"", name),
+			})
+		}
+		if isPlaceholderEmail(profile.Email) {
+			findings = append(findings, lintFinding{
+				Profile: name,
+				Problem: fmt.Sprintf("email '%s' looks like a placeholder", profile.Email),
+				Fix:     fmt.Sprintf("git usr profile set %s email <address>", name),
+			})
+		}
+	}
+	return findings
+}
+
+// lintDuplicates flags profiles with the same identity as an earlier one
+func lintDuplicates(ctx lintContext) []lintFinding {
+	var findings []lintFinding
+	names := sortedProfileNames(ctx.Config.Profiles)
+	for i, name := range names {
+		profile := ctx.Config.Profiles[name]
+		for _, earlier := range names[:i] {
+			if identityMatches(ctx.Config.Profiles[earlier], profile.Name, profile.Email) {
+				findings = append(findings, lintFinding{
+					Profile: name,
+					Problem: fmt.Sprintf("same identity as '%s'", earlier),
+					Fix:     fmt.Sprintf("git usr remove %s", name),
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// lintReservedNames flags profiles named like a command or flag, which
+// `git usr <profile>` can't switch to
+func lintReservedNames(ctx lintContext) []lintFinding {
+	var findings []lintFinding
+	for _, name := range sortedProfileNames(ctx.Config.Profiles) {
+		if reason := reservedProfileName(name); reason != "" {
+			findings = append(findings, lintFinding{
+				Profile: name,
+				Problem: "can't be switched to: " + reason,
+				Fix:     "rename it in profiles.json",
+			})
+		}
+	}
+	return findings
+}
+
+// hostOf returns the lower-case host of a URL, scp-like SSH address
+// (git@host:path) or bare host
+func hostOf(address string) string {
+	if strings.Contains(address, "://") {
+		if u, err := url.Parse(address); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+		return ""
+	}
+	if _, rest, ok := strings.Cut(address, "@"); ok {
+		address = rest
+	}
+	host, _, _ := strings.Cut(address, ":")
+	host, _, _ = strings.Cut(host, "/")
+	return strings.ToLower(host)
+}
+
+// profileHosts returns the hosts a profile is set up for through its host
+// aliases, URL rewrites and credentials
+func profileHosts(profile Profile) []string {
+	seen := map[string]bool{}
+	var hosts []string
+	add := func(address string) {
+		if host := hostOf(address); host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	for host := range profile.HostAliases {
+		add(host)
+	}
+	for prefix := range profile.URLRewrites {
+		add(prefix)
+	}
+	for address := range profile.CredentialUsernames {
+		add(address)
+	}
+	for address := range profile.CredentialHelpers {
+		add(address)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// lintSigningRequired flags profiles without a signing key that are used
+// with a host listed in the signingRequiredHosts setting
+func lintSigningRequired(ctx lintContext) []lintFinding {
+	required := map[string]bool{}
+	for _, host := range strings.Split(ctx.Config.Settings.SigningRequiredHosts, ",") {
+		if host != "" {
+			required[host] = true
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	var findings []lintFinding
+	for _, name := range sortedProfileNames(ctx.Config.Profiles) {
+		profile := ctx.Config.Profiles[name]
+		if signsCommits(profile) {
+			continue
+		}
+		for _, host := range profileHosts(profile) {
+			if required[host] {
+				findings = append(findings, lintFinding{
+					Profile: name,
+					Problem: fmt.Sprintf("used with %s, which requires signed commits, but has no signing key", host),
+					Fix:     fmt.Sprintf("git usr keys setup %s --ssh-signing", name),
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// globPatternCovers reports whether pattern, in the form made by form,
+// matches everything other does, as far as can be told without expanding
+// other's wildcards. An empty pattern matches everything
+func globPatternCovers(pattern, other string, form func(string) string, fold bool) bool {
+	if pattern == "" {
+		return true
+	}
+	if other == "" {
+		return false
+	}
+	pattern, other = form(pattern), form(other)
+	if fold {
+		pattern, other = strings.ToLower(pattern), strings.ToLower(other)
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	switch {
+	case pattern == other:
+		return true
+	case !strings.ContainsAny(other, "*?["):
+		return gitGlobMatch(pattern, other, false)
+	case strings.HasSuffix(pattern, "/**") && !strings.ContainsAny(strings.TrimSuffix(pattern, "/**"), "*?["):
+		return strings.HasPrefix(other, strings.TrimSuffix(pattern, "**"))
+	}
+	return false
+}
+
+// coversRule reports whether earlier matches every repository later does
+func coversRule(earlier, later Rule) bool {
+	same := func(pattern string) string { return pattern }
+	return globPatternCovers(earlier.Remote, later.Remote, remoteRuleForm, true) &&
+		globPatternCovers(earlier.Dir, later.Dir, dirRulePattern, false) &&
+		globPatternCovers(earlier.Branch, later.Branch, same, false)
+}
+
+// lintUnmatchableRules flags rules that can never apply: those without a
+// condition, and those an earlier rule matches wherever they would, since
+// the first rule that matches wins
+func lintUnmatchableRules(ctx lintContext) []lintFinding {
+	var findings []lintFinding
+	for i, r := range ctx.Config.Rules {
+		finding := lintFinding{
+			Profile: fmt.Sprintf("rule %d", i+1),
+			Fix:     fmt.Sprintf("git usr rules remove %d", i+1),
+		}
+		if r.Remote == "" && r.Dir == "" && r.Branch == "" {
+			finding.Problem = "has no remote, dir or branch condition, so it never matches"
+			findings = append(findings, finding)
+			continue
+		}
+		for j, earlier := range ctx.Config.Rules[:i] {
+			if (earlier.Remote != "" || earlier.Dir != "" || earlier.Branch != "") && coversRule(earlier, r) {
+				finding.Problem = fmt.Sprintf("never matches: rule %d (%s) matches first wherever it would", j+1, earlier)
+				findings = append(findings, finding)
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// lintOverlappingDirs flags watched directories inside another watched
+// directory. The outer one is scanned first and claims the new clones, so
+// the inner one's profile doesn't apply
+func lintOverlappingDirs(ctx lintContext) []lintFinding {
+	var findings []lintFinding
+	dirs := sortedKeys(ctx.Config.Watch)
+	for _, dir := range dirs {
+		for _, outer := range dirs {
+			if outer == dir || !isInside(dir, outer) {
+				continue
+			}
+			problem := fmt.Sprintf("watched directory is inside the watched %s", outer)
+			if ctx.Config.Watch[dir] != ctx.Config.Watch[outer] {
+				problem += ", which claims its clones first"
+			} else {
+				problem += " with the same profile"
+			}
+			findings = append(findings, lintFinding{
+				Profile: dir,
+				Problem: problem,
+				Fix:     fmt.Sprintf("git usr watch remove %s", shellQuote(dir)),
+			})
+			break
+		}
+	}
+	return findings
+}
+
+// lintUnused flags profiles nobody switched to in the last UnusedMonths
+// months. Profiles are only judged once usage has been tracked that long
+func lintUnused(ctx lintContext) []lintFinding {
+	if ctx.State == nil || ctx.State.UsageSince == nil || ctx.UnusedMonths <= 0 {
+		return nil
+	}
+	cutoff := ctx.Now.AddDate(0, -ctx.UnusedMonths, 0)
+	if ctx.State.UsageSince.After(cutoff) {
+		return nil
+	}
+
+	var findings []lintFinding
+	for _, name := range sortedProfileNames(ctx.Config.Profiles) {
+		lastUsed, used := ctx.State.LastUsed[name]
+		if used && lastUsed.After(cutoff) {
+			continue
+		}
+		problem := fmt.Sprintf("not used in %d months", ctx.UnusedMonths)
+		if used {
+			problem += fmt.Sprintf(" (last used %s)", lastUsed.Format("2006-01-02"))
+		}
+		findings = append(findings, lintFinding{
+			Profile: name,
+			Problem: problem,
+			Fix:     fmt.Sprintf("git usr remove %s", name),
+		})
+	}
+	return findings
+}
+
+// sortedProfileNames returns the profile names in alphabetical order
+func sortedProfileNames(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runLint handles the lint command
+func runLint(args []string) error {
+	usage := "Usage: git usr lint [--unused-months <n>]"
+
+	ctx := lintContext{Now: time.Now(), UnusedMonths: 6}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--unused-months":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--unused-months requires a value")
+			}
+			months, err := strconv.Atoi(args[i+1])
+			if err != nil || months < 0 {
+				fmt.Println(usage)
+				return fmt.Errorf("invalid --unused-months: %s", args[i+1])
+			}
+			ctx.UnusedMonths = months
+			i++
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	ctx.Config, ctx.State = config, state
+
+	count := 0
+	for _, check := range lintChecks {
+		for _, finding := range check.check(ctx) {
+			fmt.Printf("⚠️  %s: %s [%s]\n", finding.Profile, finding.Problem, check.name)
+			fmt.Printf("   Fix: %s\n", finding.Fix)
+			count++
+		}
+	}
+
+	if count == 0 {
+		fmt.Println("✅ No problems found")
+		return nil
+	}
+	fmt.Printf("\n%d problem(s) found\n", count)
+	return fmt.Errorf("lint found %d problem(s)", count)
+}
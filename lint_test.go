@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIsPlaceholderEmail tests placeholder email detection
+func TestIsPlaceholderEmail(t *testing.T) {
+	tests := map[string]bool{
+		"you@work.com":          true,
+		"john@example.com":      true,
+		"jdoe@corp.test":        true,
+		"test@acme.io":          true,
+		"john.smith@acme.io":    false,
+		"jdoe@users.github.com": false,
+		"not-an-email":          false,
+	}
+	for email, expected := range tests {
+		if got := isPlaceholderEmail(email); got != expected {
+			t.Errorf("isPlaceholderEmail(%q) = %v, expected %v", email, got, expected)
+		}
+	}
+}
+
+// TestHostOf tests extracting hosts from URLs and SSH addresses
+func TestHostOf(t *testing.T) {
+	tests := map[string]string{
+		"https://GitHub.com/acme/": "github.com",
+		"ssh://git@gitlab.com/":    "gitlab.com",
+		"git@github.com:":          "github.com",
+		"github.com":               "github.com",
+	}
+	for address, expected := range tests {
+		if got := hostOf(address); got != expected {
+			t.Errorf("hostOf(%q) = %q, expected %q", address, got, expected)
+		}
+	}
+}
+
+// TestLintChecks tests the findings of each lint check
+func TestLintChecks(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	since := now.AddDate(-1, 0, 0)
+	ctx := lintContext{
+		Config: &Config{
+			Profiles: map[string]Profile{
+				"seed":  {Name: "Your Work Name", Email: "you@work.com"},
+				"work":  {Name: "John Smith", Email: "john@acme.io", HostAliases: map[string]string{"github.com": "github.com-work"}},
+				"work2": {Name: "John Smith", Email: "JOHN@acme.io"},
+				"oss":   {Name: "John Smith", Email: "john@oss.dev", SigningKey: "ABC", CredentialUsernames: map[string]string{"https://github.com": "jsmith"}},
+				"list":  {Name: "Lee Ist", Email: "lee@ist.dev"},
+			},
+			Settings: Settings{SigningRequiredHosts: "github.com"},
+			Rules: []Rule{
+				{Remote: "github.com/acme/**", Profile: "work"},
+				{Remote: "git@github.com:acme/api.git", Profile: "oss"},
+				{Remote: "github.com/acme/api", Branch: "release/*", Profile: "oss"},
+				{Remote: "github.com/**", Dir: "~/oss/", Profile: "oss"},
+				{Profile: "oss"},
+			},
+			Watch: map[string]string{"/src": "", "/src/acme": "work", "/src/acme/tools": "work", "/srcx": "oss"},
+		},
+		State: &ManagedState{
+			UsageSince: &since,
+			LastUsed: map[string]time.Time{
+				"work": now.AddDate(0, -1, 0),
+				"oss":  now.AddDate(0, -8, 0),
+			},
+		},
+		Now:          now,
+		UnusedMonths: 6,
+	}
+
+	expected := map[string][]string{
+		"placeholder": {"seed", "seed"},
+		"duplicate":   {"work2"},
+		"reserved":    {"list"},
+		"signing":     {"work"},
+		"rule":        {"rule 2", "rule 3", "rule 5"},
+		"unused":      {"list", "oss", "seed", "work2"},
+		"overlap":     {"/src/acme", "/src/acme/tools"},
+	}
+	for _, check := range lintChecks {
+		findings := check.check(ctx)
+		var profiles []string
+		for _, finding := range findings {
+			profiles = append(profiles, finding.Profile)
+		}
+		if len(profiles) != len(expected[check.name]) {
+			t.Errorf("%s: got findings for %v, expected %v", check.name, profiles, expected[check.name])
+			continue
+		}
+		for i := range profiles {
+			if profiles[i] != expected[check.name][i] {
+				t.Errorf("%s: got findings for %v, expected %v", check.name, profiles, expected[check.name])
+				break
+			}
+		}
+	}
+
+	// Usage tracked for less than the window doesn't flag anything
+	recent := now.AddDate(0, -2, 0)
+	ctx.State.UsageSince = &recent
+	if findings := lintUnused(ctx); len(findings) != 0 {
+		t.Errorf("Expected no unused findings, got %v", findings)
+	}
+}
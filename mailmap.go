@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Markers around the part of a .mailmap owned by git-usr; entries outside
+// them are left alone
+const (
+	mailmapBlockBegin = "# BEGIN git-usr (generated by 'git usr mailmap generate')"
+	mailmapBlockEnd   = "# END git-usr"
+)
+
+// mailmapPerson is one person's profiles and the one whose identity the
+// others map to
+type mailmapPerson struct {
+	Canonical string
+	Profiles  []string
+}
+
+// mailmapPeople groups profiles into people. Profiles sharing a user name
+// or email, ignoring case, are the same person, whose canonical identity is
+// the default profile if it is one of theirs or else the first by name.
+// With canonical set every profile is that one person's
+func mailmapPeople(profiles map[string]Profile, canonical, defaultProfile string) []mailmapPerson {
+	names := sortedProfileNames(profiles)
+	if canonical != "" {
+		return []mailmapPerson{{Canonical: canonical, Profiles: names}}
+	}
+
+	parent := map[string]string{}
+	find := func(name string) string {
+		for parent[name] != name {
+			name = parent[name]
+		}
+		return name
+	}
+	first := map[string]string{}
+	for _, name := range names {
+		parent[name] = name
+		profile := profiles[name]
+		for _, key := range []string{
+			"name:" + strings.ToLower(cleanIdentityText(profile.Name)),
+			"email:" + strings.ToLower(cleanIdentityText(profile.Email)),
+		} {
+			if strings.HasSuffix(key, ":") {
+				continue
+			}
+			if other, ok := first[key]; ok {
+				parent[find(name)] = find(other)
+			} else {
+				first[key] = name
+			}
+		}
+	}
+
+	var people []mailmapPerson
+	index := map[string]int{}
+	for _, name := range names {
+		root := find(name)
+		i, ok := index[root]
+		if !ok {
+			i = len(people)
+			index[root] = i
+			people = append(people, mailmapPerson{Canonical: name})
+		}
+		people[i].Profiles = append(people[i].Profiles, name)
+		if name == defaultProfile {
+			people[i].Canonical = name
+		}
+	}
+	return people
+}
+
+// mailmapEntries returns the .mailmap lines mapping every email of a
+// person with more than one profile to their canonical identity
+func mailmapEntries(profiles map[string]Profile, people []mailmapPerson) []string {
+	var lines []string
+	for _, person := range people {
+		if len(person.Profiles) < 2 {
+			continue
+		}
+		canonical := profiles[person.Canonical]
+		proper := formatAddress(canonical.Name, canonical.Email)
+		// The canonical email alone maps the other names used with it
+		lines = append(lines, proper)
+		seen := map[string]bool{strings.ToLower(canonical.Email): true}
+		for _, name := range person.Profiles {
+			email := profiles[name].Email
+			if email == "" || seen[strings.ToLower(email)] {
+				continue
+			}
+			seen[strings.ToLower(email)] = true
+			lines = append(lines, fmt.Sprintf("%s <%s>", proper, email))
+		}
+	}
+	return lines
+}
+
+// renderMailmap replaces the git-usr block of a .mailmap with entries,
+// placing it last so its entries win over older ones for the same emails
+func renderMailmap(existing string, entries []string) string {
+	return replaceMarkedBlock(existing, mailmapBlockBegin, mailmapBlockEnd, entries)
+}
+
+// runMailmap handles the mailmap command
+func runMailmap(args []string) error {
+	usage := "Usage: git usr mailmap generate [--canonical <profile>] [--output <file>] [--append] [--dry-run]"
+
+	if len(args) == 0 || args[0] != "generate" {
+		fmt.Println(usage)
+		return fmt.Errorf("invalid mailmap command")
+	}
+	canonical, output := "", ""
+	appendTo, dryRun := false, false
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--append":
+			appendTo = true
+		case "--dry-run":
+			dryRun = true
+		case "--canonical", "--output", "-o":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			if arg == "--canonical" {
+				canonical = args[i+1]
+			} else {
+				output = args[i+1]
+			}
+			i++
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Profiles[canonical]; canonical != "" && !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", canonical)
+		return errProfileNotFound
+	}
+	entries := mailmapEntries(config.Profiles, mailmapPeople(config.Profiles, canonical, config.Settings.DefaultProfile))
+	if len(entries) == 0 {
+		fmt.Println("✅ Nothing to map: no one has profiles with more than one email")
+		fmt.Println("   Use --canonical <profile> if your profiles use different names")
+		return nil
+	}
+
+	if output == "-" {
+		fmt.Print(renderMailmap("", entries))
+		return nil
+	}
+	if output == "" {
+		top, err := gitConfig.WorkTree("")
+		if err != nil {
+			fmt.Println("❌ Not in a git repository; use --output <file> to write the mailmap elsewhere")
+			return err
+		}
+		output = filepath.Join(top, ".mailmap")
+	}
+
+	existing, err := os.ReadFile(output)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if !appendTo && len(linesOutsideBlock(string(existing), mailmapBlockBegin, mailmapBlockEnd)) > 0 {
+		fmt.Printf("❌ %s already has entries of its own\n", output)
+		fmt.Println("   Use --append to keep them and add the git-usr entries after them")
+		return fmt.Errorf("mailmap exists")
+	}
+	content := renderMailmap(string(existing), entries)
+	if dryRun {
+		fmt.Print(content)
+		return nil
+	}
+	if err := writeFileAtomic(output, []byte(content), 0644, false); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote %d mailmap entries to %s\n", len(entries), output)
+	fmt.Println("   git shortlog and git blame now show each person under one identity")
+	return nil
+}
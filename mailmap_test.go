@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMailmapPeople tests grouping profiles by shared names and emails
+func TestMailmapPeople(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {Name: "Jane Doe", Email: "jane@acme.io"},
+		"personal": {Name: "jane doe", Email: "jane@home.dev"},
+		"acme":     {Name: "J. Doe", Email: "Jane@Acme.io"},
+		"bot":      {Name: "Release Bot", Email: "bot@acme.io"},
+	}
+
+	expected := []mailmapPerson{
+		{Canonical: "acme", Profiles: []string{"acme", "personal", "work"}},
+		{Canonical: "bot", Profiles: []string{"bot"}},
+	}
+	if got := mailmapPeople(profiles, "", ""); !reflect.DeepEqual(got, expected) {
+		t.Errorf("mailmapPeople() = %v, expected %v", got, expected)
+	}
+
+	expected[0].Canonical = "personal"
+	if got := mailmapPeople(profiles, "", "personal"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("mailmapPeople() with a default profile = %v, expected %v", got, expected)
+	}
+
+	all := []mailmapPerson{{Canonical: "work", Profiles: []string{"acme", "bot", "personal", "work"}}}
+	if got := mailmapPeople(profiles, "work", "personal"); !reflect.DeepEqual(got, all) {
+		t.Errorf("mailmapPeople() with --canonical = %v, expected %v", got, all)
+	}
+}
+
+// TestMailmapEntries tests mapping each email once and skipping people
+// with a single profile
+func TestMailmapEntries(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {Name: "Jane Doe", Email: "jane@acme.io"},
+		"personal": {Name: "Jane Doe", Email: "jane@home.dev"},
+		"acme":     {Name: "J. Doe", Email: "Jane@Acme.io"},
+		"bot":      {Name: "Release Bot", Email: "bot@acme.io"},
+	}
+	expected := []string{
+		"Jane Doe <jane@home.dev>",
+		"Jane Doe <jane@home.dev> <Jane@Acme.io>",
+	}
+	if got := mailmapEntries(profiles, mailmapPeople(profiles, "", "personal")); !reflect.DeepEqual(got, expected) {
+		t.Errorf("mailmapEntries() = %q, expected %q", got, expected)
+	}
+}
+
+// TestRenderMailmap tests that the git-usr block is replaced and moved
+// after the entries of its own
+func TestRenderMailmap(t *testing.T) {
+	existing := mailmapBlockBegin + "\n" +
+		"Old <old@example.com>\n" +
+		mailmapBlockEnd + "\r\n" +
+		"# Maintainers\r\n" +
+		"Max <max@example.com> <max@old.example>\r\n\r\n"
+	entries := []string{"Jane Doe <jane@home.dev>", "Jane Doe <jane@home.dev> <jane@acme.io>"}
+
+	expected := "# Maintainers\n" +
+		"Max <max@example.com> <max@old.example>\n" +
+		"\n" +
+		mailmapBlockBegin + "\n" +
+		"Jane Doe <jane@home.dev>\n" +
+		"Jane Doe <jane@home.dev> <jane@acme.io>\n" +
+		mailmapBlockEnd + "\n"
+	if got := renderMailmap(existing, entries); got != expected {
+		t.Errorf("renderMailmap() =\n%s\nexpected\n%s", got, expected)
+	}
+
+	if got := renderMailmap(mailmapBlockBegin+"\nOld <old@example.com>\n"+mailmapBlockEnd+"\n", nil); got != "" {
+		t.Errorf("renderMailmap() without entries = %q, expected an empty file", got)
+	}
+}
@@ -1,31 +1,123 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-const version = "1.0.0"
-
 // Profile represents a git user profile
 type Profile struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name                string            `json:"name"`
+	Email               string            `json:"email"`
+	Description         string            `json:"description,omitempty"`
+	Tags                []string          `json:"tags,omitempty"`
+	Scope               string            `json:"scope,omitempty"`
+	Env                 map[string]string `json:"env,omitempty"`
+	HostAliases         map[string]string `json:"hostAliases,omitempty"`
+	URLRewrites         map[string]string `json:"urlRewrites,omitempty"`
+	SigningKey          string            `json:"signingKey,omitempty"`
+	SigningFormat       string            `json:"signingFormat,omitempty"`
+	X509Program         string            `json:"x509Program,omitempty"`
+	GitsignOptions      map[string]string `json:"gitsignOptions,omitempty"`
+	PushRemote          string            `json:"pushRemote,omitempty"`
+	CredentialUsernames map[string]string `json:"credentialUsernames,omitempty"`
+	CredentialHelpers   map[string]string `json:"credentialHelpers,omitempty"`
+	ForgeAccounts       map[string]string `json:"forgeAccounts,omitempty"`
+	CommitterName       string            `json:"committerName,omitempty"`
+	CommitterEmail      string            `json:"committerEmail,omitempty"`
+	CommitTemplate      string            `json:"commitTemplate,omitempty"`
+	Trailers            []string          `json:"trailers,omitempty"`
+	Aliases             map[string]string `json:"aliases,omitempty"`
+}
+
+// ExitError is returned by commands that need a specific exit code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
 }
 
-// Config holds all user profiles
+// Config holds all user profiles and settings
 type Config struct {
-	Profiles map[string]Profile `json:"profiles"`
+	Profiles  map[string]Profile  `json:"profiles"`
+	Coauthors map[string]Coauthor `json:"coauthors,omitempty"`
+	// Watch maps the directories `git usr watch` watches for new clones to
+	// the profile for them, "" meaning the remote's or the default profile
+	Watch map[string]string `json:"watch,omitempty"`
+	// Rules say which profile or email domain repositories need; the first
+	// that matches a repository applies
+	Rules []Rule `json:"rules,omitempty"`
+	// Archived holds profiles put away by `git usr prune`, so they can be
+	// restored later
+	Archived map[string]Profile `json:"archived,omitempty"`
+	// OnSwitch holds the scripts `git usr hooks on-switch` runs after
+	// every switch
+	OnSwitch []string `json:"onSwitch,omitempty"`
+	Settings Settings `json:"settings"`
+
+	// Set when the profiles come from a ProfileStore: the store, the
+	// profiles of the local file and the profiles as loaded from the store
+	store         ProfileStore
+	localProfiles map[string]Profile
+	storeProfiles map[string]Profile
 }
 
+// configPathOverride is set by the global --config flag
+var configPathOverride string
+
+// workDirOverride is set by the global -C flag: the directory to run in,
+// as if git-usr had been started there
+var workDirOverride string
+
 // getConfigPath returns the path to the configuration file
 func getConfigPath() (string, error) {
-	var configDir string
+	// Explicit overrides: --config flag, then GIT_USR_CONFIG
+	configPath := configPathOverride
+	if configPath == "" {
+		configPath = os.Getenv("GIT_USR_CONFIG")
+	}
+	if configPath != "" {
+		configPath, err := filepath.Abs(configPath)
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			return "", err
+		}
+		return configPath, nil
+	}
+
+	// Portable installs keep everything next to the executable
+	configDir, err := portableConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if configDir != "" {
+		if err := os.MkdirAll(configDir, 0755); err != nil {
+			return "", err
+		}
+		return filepath.Join(configDir, "profiles.json"), nil
+	}
 
 	if runtime.GOOS == "windows" {
 		appData := os.Getenv("APPDATA")
@@ -37,6 +129,8 @@ func getConfigPath() (string, error) {
 			appData = filepath.Join(home, "AppData", "Roaming")
 		}
 		configDir = filepath.Join(appData, "git-usr")
+	} else if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		configDir = filepath.Join(xdg, "git-usr")
 	} else {
 		home, err := os.UserHomeDir()
 		if err != nil {
@@ -52,16 +146,125 @@ func getConfigPath() (string, error) {
 	return filepath.Join(configDir, "profiles.json"), nil
 }
 
-// loadProfiles loads profiles from the config file
-func loadProfiles() (map[string]Profile, error) {
+// getConfigDir returns the directory holding the configuration file
+func getConfigDir() (string, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(configPath), nil
+}
+
+// parseConfig parses config data, accepting both the current format and
+// the legacy format where the file was a bare map of profiles
+func parseConfig(data []byte) (*Config, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	_, hasProfiles := raw["profiles"]
+	// A legacy profile literally named "settings" carries a name or email,
+	// which the settings object never has
+	hasSettings := raw["settings"] != nil && !isProfileShaped(raw["settings"])
+	isCurrent := hasSettings
+	if hasProfiles && !hasSettings {
+		// A legacy profile literally named "profiles" holds strings, not profiles
+		var profiles map[string]Profile
+		isCurrent = json.Unmarshal(raw["profiles"], &profiles) == nil
+	}
+
+	if isCurrent {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, &config.Profiles); err != nil {
+		return nil, err
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	return config, nil
+}
+
+// isProfileShaped reports whether a JSON value looks like a profile
+func isProfileShaped(data json.RawMessage) bool {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return false
+	}
+	_, hasName := fields["name"]
+	_, hasEmail := fields["email"]
+	return hasName || hasEmail
+}
+
+// loadConfig loads settings from the config file and the profiles from
+// the configured store
+func loadConfig() (*Config, error) {
+	config, err := loadLocalConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := loadStoreProfiles(config); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadLocalConfig loads profiles and settings from the config file only,
+// so settings can be changed while the store is unreachable
+func loadLocalConfig() (*Config, error) {
 	configPath, err := getConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
-	// If file doesn't exist, create default profiles
+	// If file doesn't exist, set up the first profiles
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		defaultProfiles := map[string]Profile{
+		if !configLockHeld {
+			unlock, err := acquireConfigLock()
+			if err != nil {
+				return nil, err
+			}
+			defer unlock()
+		}
+		// Another invocation may have set them up while we waited
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			config := firstRunConfig()
+			if err := saveConfig(config); err != nil {
+				return nil, err
+			}
+			return config, nil
+		}
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = decodeConfigData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := parseConfig(data)
+	if err != nil {
+		if config, err = recoverConfig(configPath, data, err); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// defaultConfig returns the config written on a first run nobody is
+// around to set up
+func defaultConfig() *Config {
+	return &Config{
+		Profiles: map[string]Profile{
 			"work": {
 				Name:  "Your Work Name",
 				Email: "you@work.com",
@@ -70,203 +273,998 @@ func loadProfiles() (map[string]Profile, error) {
 				Name:  "Your Personal Name",
 				Email: "you@personal.com",
 			},
-		}
-		if err := saveProfiles(defaultProfiles); err != nil {
-			return nil, err
-		}
-		return defaultProfiles, nil
+		},
 	}
+}
 
-	data, err := os.ReadFile(configPath)
+// loadProfiles loads just the profiles from the config file
+func loadProfiles() (map[string]Profile, error) {
+	config, err := loadConfig()
 	if err != nil {
 		return nil, err
 	}
+	return config.Profiles, nil
+}
 
-	var profiles map[string]Profile
-	if err := json.Unmarshal(data, &profiles); err != nil {
-		return nil, err
+// saveConfig saves profiles and settings to the config file
+func saveConfig(config *Config) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
 	}
 
-	return profiles, nil
-}
+	config, err = saveStoreProfiles(config)
+	if err != nil {
+		return err
+	}
 
-// saveProfiles saves profiles to the config file
-func saveProfiles(profiles map[string]Profile) error {
-	configPath, err := getConfigPath()
+	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(profiles, "", "  ")
+	data, err = encodeConfigData(data)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	if err := backupConfig(configPath, backupsToKeep(config)); err != nil {
+		return err
+	}
+
+	// Encrypted configs are always owner-only; otherwise keep existing permissions
+	if configLocked {
+		return writeFileAtomic(configPath, data, 0600, true)
+	}
+	return writeFileAtomic(configPath, data, 0644, false)
 }
 
-// setGitConfig sets git user name and email
+// setGitConfig sets git user name and email, both or neither: when one
+// can't be set the other is put back, so no scope is left with a mixed
+// identity
 func setGitConfig(name, email, scope string) error {
-	cmd := exec.Command("git", "config", "--"+scope, "user.name", name)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to set user.name: %w", err)
+	tx := beginConfigTransaction("", scope)
+	if err := tx.Set("user.name", name); err != nil {
+		return tx.Fail(err)
 	}
-
-	cmd = exec.Command("git", "config", "--"+scope, "user.email", email)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to set user.email: %w", err)
+	if err := tx.Set("user.email", email); err != nil {
+		return tx.Fail(err)
 	}
-
 	return nil
 }
 
 // getCurrentGitConfig gets the current git user name and email
 func getCurrentGitConfig() (string, string, error) {
-	nameCmd := exec.Command("git", "config", "user.name")
-	nameOut, err := nameCmd.Output()
-	if err != nil {
+	name := gitConfigValue("", "", "user.name")
+	if name == "" {
 		return "", "", nil // Not an error, just no config
 	}
-
-	emailCmd := exec.Command("git", "config", "user.email")
-	emailOut, err := emailCmd.Output()
-	if err != nil {
+	email := gitConfigValue("", "", "user.email")
+	if email == "" {
 		return "", "", nil
 	}
+	return name, email, nil
+}
+
+// getGitConfigValue gets a single git config value, empty if unset
+func getGitConfigValue(key string) string {
+	return gitConfigValue("", "", key)
+}
+
+// findProfileByIdentity returns the name of the profile matching name and email
+func findProfileByIdentity(profiles map[string]Profile, name, email string) (string, bool) {
+	for profileName, profile := range profiles {
+		if identityMatches(profile, name, email) {
+			return profileName, true
+		}
+	}
+	return "", false
+}
+
+// listOptions are the flags of the list command
+type listOptions struct {
+	Sort   string
+	Filter string
+	// Tags are the tags a profile must all have to be listed
+	Tags  []string
+	Quiet bool
+}
 
-	return strings.TrimSpace(string(nameOut)), strings.TrimSpace(string(emailOut)), nil
+// listSortKeys are the orders accepted by `list --sort`
+var listSortKeys = []string{"name", "email", "used"}
+
+// hasTag reports whether a profile has a tag, ignoring case
+func hasTag(profile Profile, tag string) bool {
+	for _, t := range profile.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
-// listProfiles lists all available profiles
-func listProfiles() error {
-	profiles, err := loadProfiles()
+// listedProfiles returns the names of the profiles with all the tags and
+// matching the filter, a case-insensitive substring of the profile name,
+// user name, email, description or a tag, sorted by profile name, email
+// (then name) or last use (most recent first)
+func listedProfiles(profiles map[string]Profile, lastUsed map[string]time.Time, opts listOptions) []string {
+	filter := strings.ToLower(opts.Filter)
+	var names []string
+	for _, name := range sortedProfileNames(profiles) {
+		profile := profiles[name]
+		text := strings.Join(append([]string{name, profile.Name, profile.Email, profile.Description}, profile.Tags...), "\x00")
+		if filter != "" && !strings.Contains(strings.ToLower(text), filter) {
+			continue
+		}
+		tagged := true
+		for _, tag := range opts.Tags {
+			tagged = tagged && hasTag(profile, tag)
+		}
+		if !tagged {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	switch opts.Sort {
+	case "email":
+		sort.SliceStable(names, func(i, j int) bool {
+			return strings.ToLower(profiles[names[i]].Email) < strings.ToLower(profiles[names[j]].Email)
+		})
+	case "used":
+		sort.SliceStable(names, func(i, j int) bool {
+			return lastUsed[names[i]].After(lastUsed[names[j]])
+		})
+	}
+	return names
+}
+
+// scopeMarker returns which scopes' identity profile matches: "(local)",
+// "(global)", "(both)" or "". The merged config alone can't tell whether
+// the repository or the global config makes a profile current
+func scopeMarker(profile Profile, local, global Profile) string {
+	inLocal := local.Email != "" && identityMatches(profile, local.Name, local.Email)
+	inGlobal := global.Email != "" && identityMatches(profile, global.Name, global.Email)
+	switch {
+	case inLocal && inGlobal:
+		return tr("(both)")
+	case inLocal:
+		return tr("(local)")
+	case inGlobal:
+		return tr("(global)")
+	}
+	return ""
+}
+
+// listProfiles lists the profiles as a table, marking the current one and
+// the scopes each is set in
+func listProfiles(opts listOptions) error {
+	config, err := loadConfig()
 	if err != nil {
 		return err
 	}
+	profiles := config.Profiles
 
-	currentName, currentEmail, _ := getCurrentGitConfig()
+	var lastUsed map[string]time.Time
+	if opts.Sort == "used" {
+		state, err := loadState()
+		if err != nil {
+			return err
+		}
+		lastUsed = state.LastUsed
+	}
+	names := listedProfiles(profiles, lastUsed, opts)
 
-	fmt.Println("\n📋 Available profiles:")
-	fmt.Println(strings.Repeat("-", 50))
+	if opts.Quiet {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
 
-	for name, profile := range profiles {
-		isCurrent := profile.Name == currentName && profile.Email == currentEmail
-		marker := "   "
-		if isCurrent {
-			marker = "👉 "
+	if len(names) == 0 {
+		if len(opts.Tags) > 0 {
+			fmt.Println(trf("No profiles tagged %s", strings.Join(opts.Tags, ", ")))
+		} else if opts.Filter != "" {
+			fmt.Println(trf("No profiles match '%s'", opts.Filter))
+		} else {
+			fmt.Println(tr("No profiles yet. Use 'git usr add' to create one"))
 		}
-		fmt.Printf("%s%s\n", marker, name)
-		fmt.Printf("   Name:  %s\n", profile.Name)
-		fmt.Printf("   Email: %s\n", profile.Email)
-		fmt.Println()
+		return nil
 	}
 
+	currentName, currentEmail, _ := getCurrentGitConfig()
+	local := Profile{Name: getScopedGitConfigValue("local", "user.name"), Email: getScopedGitConfigValue("local", "user.email")}
+	global := Profile{Name: getScopedGitConfigValue("global", "user.name"), Email: getScopedGitConfigValue("global", "user.email")}
+	color := useColor(config.Settings)
+
+	// Descriptions and tags get a column when any listed profile has them
+	withDescription, withTags := false, false
+	for _, name := range names {
+		withDescription = withDescription || profiles[name].Description != ""
+		withTags = withTags || len(profiles[name].Tags) > 0
+	}
+	header := []string{tr("PROFILE"), tr("NAME"), tr("EMAIL")}
+	if withTags {
+		header = append(header, tr("TAGS"))
+	}
+	if withDescription {
+		header = append(header, tr("DESCRIPTION"))
+	}
+	rows := [][]string{header}
+	for _, name := range names {
+		row := []string{name, profiles[name].Name, profiles[name].Email}
+		if withTags {
+			row = append(row, strings.Join(profiles[name].Tags, ","))
+		}
+		if withDescription {
+			row = append(row, profiles[name].Description)
+		}
+		row = append(row, scopeMarker(profiles[name], local, global))
+		rows = append(rows, row)
+	}
+	lines := formatTable(rows)
+
+	fmt.Println("\n📋 " + tr("Available profiles:"))
+	fmt.Println("   " + colorize(color, ansiBold, lines[0]))
+	for i, name := range names {
+		line := lines[i+1]
+		if currentEmail != "" && identityMatches(profiles[name], currentName, currentEmail) {
+			fmt.Println("👉 " + colorize(color, ansiGreen, line))
+		} else {
+			fmt.Println("   " + line)
+		}
+	}
 	return nil
 }
 
-// switchProfile switches to a specific profile
+// runList handles the list command
+func runList(args []string) error {
+	usage := "Usage: git usr list [--sort name|email|used] [--filter <text>] [--tag <tag>]... [--quiet]"
+
+	var opts listOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--quiet" || arg == "-q":
+			opts.Quiet = true
+		case arg == "--sort" || arg == "--filter" || arg == "--tag":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			switch arg {
+			case "--sort":
+				opts.Sort = args[i+1]
+			case "--filter":
+				opts.Filter = args[i+1]
+			default:
+				opts.Tags = append(opts.Tags, args[i+1])
+			}
+			i++
+		case strings.HasPrefix(arg, "--sort="):
+			opts.Sort = strings.TrimPrefix(arg, "--sort=")
+		case strings.HasPrefix(arg, "--filter="):
+			opts.Filter = strings.TrimPrefix(arg, "--filter=")
+		case strings.HasPrefix(arg, "--tag="):
+			opts.Tags = append(opts.Tags, strings.TrimPrefix(arg, "--tag="))
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+	if opts.Sort != "" && !containsString(listSortKeys, opts.Sort) {
+		fmt.Println(usage)
+		return fmt.Errorf("invalid --sort: %s", opts.Sort)
+	}
+
+	return listProfiles(opts)
+}
+
+// runFind handles the find command: list with the words given as --filter
+func runFind(args []string) error {
+	var words, listArgs []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case (arg == "--sort" || arg == "--tag") && i+1 < len(args):
+			listArgs = append(listArgs, arg, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "-"):
+			listArgs = append(listArgs, arg)
+		default:
+			words = append(words, arg)
+		}
+	}
+	if len(words) == 0 {
+		fmt.Println("❌ " + tr("Search text required!"))
+		fmt.Println(tr("Usage:") + " git usr find <text> [--sort name|email|used] [--tag <tag>]... [--quiet]")
+		return fmt.Errorf("search text required")
+	}
+	return runList(append(listArgs, "--filter", strings.Join(words, " ")))
+}
+
+// runSwitch switches to the profile named input or, unless exact is set,
+// the only profile starting with it or, after asking, the profile it is a
+// typo of
+func runSwitch(input, scope string, exact bool) error {
+	if exact {
+		return switchProfile(input, scope)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Profiles[input]; exists {
+		return switchProfile(input, scope)
+	}
+
+	match := matchProfileName(sortedProfileNames(config.Profiles), input)
+	interactive := isInteractive()
+	switch {
+	case match.Prefix != "":
+		input = match.Prefix
+	case len(match.Ambiguous) > 0:
+		fmt.Println("❌ " + trf("'%s' matches several profiles: %s", input, strings.Join(match.Ambiguous, ", ")))
+		return errProfileNotFound
+	case match.Suggested != "" && interactive:
+		answer, err := readAnswer(bufio.NewReader(os.Stdin), trf("Did you mean '%s'? [Y/n] ", match.Suggested), "")
+		if err == nil && strings.ToLower(strings.TrimSpace(answer)) != "n" {
+			input = match.Suggested
+		}
+	}
+
+	err = switchProfile(input, scope)
+	if errors.Is(err, errProfileNotFound) && match.Suggested != "" && !interactive {
+		fmt.Println("\n" + trf("Did you mean '%s'?", match.Suggested))
+	}
+	return err
+}
+
+// runSwitchCommand handles `git usr <profile>` and its flags
+func runSwitchCommand(profileName string, args []string) error {
+	usage := "Usage: git usr <profile> [--global|--local] [--exact]"
+
+	scope, exact := "", false
+	for _, arg := range args {
+		switch arg {
+		case "--global", "--local":
+			flagScope := strings.TrimPrefix(arg, "--")
+			if scope != "" && scope != flagScope {
+				fmt.Println("❌ " + tr("--global and --local can't be used together"))
+				return fmt.Errorf("conflicting scopes")
+			}
+			scope = flagScope
+		case "--exact":
+			exact = true
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+	return runSwitch(profileName, scope, exact)
+}
+
+// switchProfile switches to a specific profile. An empty scope falls back
+// to the profile's own scope, then the defaultScope setting.
 func switchProfile(profileName, scope string) error {
-	profiles, err := loadProfiles()
+	config, err := loadConfig()
 	if err != nil {
 		return err
 	}
+	profiles := config.Profiles
 
 	profile, exists := profiles[profileName]
 	if !exists {
-		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
-		fmt.Println("\nAvailable profiles:", getProfileNames(profiles))
-		fmt.Println("\nUse 'git usr add' to create a new profile")
-		return fmt.Errorf("profile not found")
+		fmt.Println("❌ " + trf("Profile '%s' not found!", profileName))
+		fmt.Println("\n"+tr("Available profiles:"), getProfileNames(profiles))
+		fmt.Println("\n" + tr("Use 'git usr add' to create a new profile"))
+		return errProfileNotFound
+	}
+
+	if scope == "" {
+		scope = profile.Scope
+	}
+	if scope == "" {
+		scope = settingKeys["defaultScope"].get(&config.Settings)
 	}
 
+	warnIdentityText("Name", profile.Name)
+	warnIdentityText("Email", profile.Email)
 	if err := setGitConfig(profile.Name, profile.Email, scope); err != nil {
+		fmt.Println("❌ " + trf("Could not switch to '%s': %v", profileName, err))
+		var txErr *configTransactionError
+		if errors.As(err, &txErr) && txErr.RollbackErr != nil {
+			fmt.Println("   " + tr("The identity may now be mixed; check it with 'git usr current'"))
+			return fmt.Errorf("%w: %w", errMixedIdentity, err)
+		}
+		fmt.Println("   " + tr("Nothing changed"))
 		return err
 	}
 
-	scopeText := "for this repository"
+	switched := "Switched to '%s' profile for this repository"
 	if scope == "global" {
-		scopeText = "globally"
+		switched = "Switched to '%s' profile globally"
 	}
 
-	fmt.Printf("✅ Switched to '%s' profile %s\n", profileName, scopeText)
-	fmt.Printf("   Name:  %s\n", profile.Name)
-	fmt.Printf("   Email: %s\n", profile.Email)
+	fmt.Println("✅ " + trf(switched, profileName))
+	fmt.Println("   " + trf("Name:  %s", profile.Name))
+	fmt.Println("   " + trf("Email: %s", profile.Email))
 
+	applySwitch(config, profileName, scope, true)
 	return nil
 }
 
-// addProfile adds or updates a profile
-func addProfile(profileName, name, email string) error {
-	profiles, err := loadProfiles()
+// applySwitch does what switching to profileName in scope does once the
+// identity is written: the profile's git settings and trailer hook, forge
+// accounts, URL rewrites, managed state and history, the Windows and npm
+// mirrors, on-switch scripts and plugins. Every command that applies a
+// profile goes through it, so the profile behaves the same whichever did.
+// If verbose, what was applied is printed along with the warnings;
+// otherwise the warnings are returned and only scripts and plugins print
+func applySwitch(config *Config, profileName, scope string, verbose bool) []string {
+	profile := config.Profiles[profileName]
+	var warnings []string
+	warn := func(warning string) {
+		if verbose {
+			fmt.Printf("⚠️  %s\n", warning)
+		} else {
+			warnings = append(warnings, warning)
+		}
+	}
+	show := func(format string, args ...interface{}) {
+		if verbose {
+			fmt.Printf(format, args...)
+		}
+	}
+
+	if err := applyProfileConfig(profile, scope); err != nil {
+		warn(fmt.Sprintf("Profile settings not applied: %v", err))
+	} else {
+		if usesGitsign(profile) {
+			show("   Signing: gitsign (keyless)\n")
+		} else if profile.SigningKey != "" {
+			show("   Signing: %s\n", profile.SigningKey)
+		}
+		if hasCommitter(profile) {
+			show("   Committer: %s\n", formatAddress(committerIdentity(profile)))
+		}
+		if profile.PushRemote != "" && scope != "global" {
+			show("   Push:    %s\n", profile.PushRemote)
+		}
+		if profile.CommitTemplate != "" {
+			show("   Template: %s\n", profile.CommitTemplate)
+		}
+		for _, trailer := range profile.Trailers {
+			show("   Trailer: %s\n", trailer)
+		}
+		if len(profile.Trailers) > 0 {
+			if err := ensureTrailerHook(scope); err != nil {
+				warn(fmt.Sprintf("Trailers not added to commits: %v", err))
+			}
+		}
+		if len(profile.Aliases) > 0 {
+			show("   Aliases: %s\n", strings.Join(sortedKeys(profile.Aliases), ", "))
+		}
+		for _, url := range sortedKeys(profile.CredentialUsernames) {
+			show("   Login:   %s at %s\n", profile.CredentialUsernames[url], url)
+		}
+	}
+	for _, warning := range applyForgeAccounts(profile, verbose) {
+		warn(warning)
+	}
+
+	if count, err := applyURLRewrites(config.Profiles, profileName, scope); err != nil {
+		warn(fmt.Sprintf("URL rewrites not applied: %v", err))
+	} else if count > 0 {
+		show("🔀 %d URL rewrite(s) applied\n", count)
+	}
+
+	if err := recordManagedKeys("", scope, profileName, profileManagedKeys(profile, scope)); err != nil {
+		warn(fmt.Sprintf("Managed state not updated: %v", err))
+	} else if err := recordProfileUse(profileName, scope); err != nil {
+		warn(fmt.Sprintf("Managed state not updated: %v", err))
+	}
+
+	if shouldMirrorToWindows(&config.Settings, scope) {
+		if err := setWindowsIdentity(profile.Name, profile.Email); err != nil {
+			warn(fmt.Sprintf("Windows git config not switched: %v", err))
+		} else {
+			show("🪟 git.exe uses it too\n")
+		}
+	}
+
+	if shouldSyncNpm(&config.Settings, scope) {
+		if err := syncNpmAuthor(profile); err != nil {
+			warn(fmt.Sprintf("npm author not synced: %v", err))
+		} else {
+			show("📦 npm init-author-name/email updated\n")
+		}
+	}
+
+	repo, _ := managedRepo("", scope)
+	runOnSwitchScripts(config.OnSwitch, profileName, profile, scope, repo)
+	runPlugins(pluginEvent{Event: pluginEventSwitch, Profile: profileName, Details: profile, Scope: scope, Repo: repo})
+	return warnings
+}
+
+// hiddenCommands are the commands left out of completion
+var hiddenCommands = []string{"hook", "__complete", "__update-check", "testdata"}
+
+// reservedProfileName returns why name can't be a profile's, or "". Commands
+// and flags are dispatched before profiles, so such a profile could never
+// be switched to
+func reservedProfileName(name string) string {
+	if strings.HasPrefix(name, "-") {
+		return "it would be taken for a flag"
+	}
+	for _, command := range completionCommands {
+		if command.Value == name {
+			return "it is a git usr command"
+		}
+	}
+	if containsString(hiddenCommands, name) {
+		return "it is a git usr command"
+	}
+	return ""
+}
+
+// addOptions are the flags of the add command
+type addOptions struct {
+	// VerifyDomain checks that the email's domain receives mail
+	VerifyDomain bool
+	// FromCurrent takes the identity and signing key git uses now, from
+	// the global config if Global is set
+	FromCurrent bool
+	Global      bool
+	// Force allows a profile name that is a command or flag
+	Force bool
+}
+
+// currentGitProfile returns the identity and signing key git uses in
+// scope: "global" for the global config, "" for the current repository
+func currentGitProfile(scope string) Profile {
+	get := getGitConfigValue
+	if scope != "" {
+		get = func(key string) string { return getScopedGitConfigValue(scope, key) }
+	}
+	profile := Profile{Name: get("user.name"), Email: get("user.email"), SigningKey: get("user.signingkey")}
+	if profile.SigningKey != "" {
+		if format := get("gpg.format"); containsString(signingFormats, format) {
+			profile.SigningFormat = format
+		}
+		if profile.SigningFormat == "x509" {
+			profile.X509Program = get("gpg.x509.program")
+		}
+	}
+	return profile
+}
+
+// readAnswer prints prompt and reads a line from reader, returning
+// fallback for an empty line. The fallback is shown after the prompt
+func readAnswer(reader *bufio.Reader, prompt, fallback string) (string, error) {
+	fmt.Print(prompt)
+	if fallback != "" {
+		fmt.Printf("[%s] ", fallback)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	if line = strings.TrimRight(line, "\r\n"); strings.TrimSpace(line) == "" {
+		return fallback, nil
+	}
+	return line, nil
+}
+
+// addProfile adds or updates a profile, asking for the name and email
+// when they aren't given
+func addProfile(profileName, name, email string, opts addOptions) error {
+	// Plugins run once the lock is released, so they can run git usr too
+	var events []pluginEvent
+	defer func() { runPlugins(events...) }()
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
 	if err != nil {
 		return err
 	}
+	profiles := config.Profiles
+
+	if _, exists := profiles[profileName]; !exists && !opts.Force {
+		if reason := reservedProfileName(profileName); reason != "" {
+			fmt.Println("❌ " + trf("Can't name a profile '%s': %s", profileName, tr(reason)))
+			fmt.Println(tr("Pick another name, or add --force if you only use it with other commands"))
+			return fmt.Errorf("reserved profile name: %s", profileName)
+		}
+	}
 
 	// If profile exists and no new data provided
-	if _, exists := profiles[profileName]; exists && (name == "" || email == "") {
-		fmt.Printf("Profile '%s' already exists:\n", profileName)
-		fmt.Printf("  Name:  %s\n", profiles[profileName].Name)
-		fmt.Printf("  Email: %s\n", profiles[profileName].Email)
-		fmt.Println("\nTo update, provide both name and email.")
+	if _, exists := profiles[profileName]; exists && (name == "" || email == "") && !opts.FromCurrent {
+		fmt.Println(trf("Profile '%s' already exists:", profileName))
+		fmt.Println("  " + trf("Name:  %s", profiles[profileName].Name))
+		fmt.Println("  " + trf("Email: %s", profiles[profileName].Email))
+		fmt.Println("\n" + tr("To update, provide both name and email."))
 		return nil
 	}
 
+	var current Profile
+	if opts.FromCurrent {
+		scope := ""
+		if opts.Global {
+			scope = "global"
+		}
+		current = currentGitProfile(scope)
+		if name == "" {
+			name = current.Name
+		}
+		if email == "" {
+			email = current.Email
+		}
+	}
+
 	// Interactive mode if name/email not provided
-	if name == "" {
-		fmt.Print("Enter name: ")
-		if _, err := fmt.Scanln(&name); err != nil {
-			return fmt.Errorf("failed to read name: %w", err)
+	prompted := name == "" || email == ""
+	if prompted {
+		reader := bufio.NewReader(os.Stdin)
+		if name == "" {
+			if name, err = readAnswer(reader, tr("Enter name: "), ""); err != nil {
+				return fmt.Errorf("failed to read name: %w", err)
+			}
+		}
+		if email == "" {
+			if email, err = readAnswer(reader, tr("Enter email: "), ""); err != nil {
+				return fmt.Errorf("failed to read email: %w", err)
+			}
 		}
 	}
-	if email == "" {
-		fmt.Print("Enter email: ")
-		if _, err := fmt.Scanln(&email); err != nil {
-			return fmt.Errorf("failed to read email: %w", err)
+
+	rawName := name
+	name, email = cleanIdentityText(name), cleanIdentityText(email)
+	if name == "" && rawName != "" {
+		fmt.Println("❌ " + tr("Name is only whitespace"))
+		return fmt.Errorf("name is only whitespace")
+	}
+	for _, err := range []error{validateName("name", name), validateEmail("email", email)} {
+		if err != nil {
+			fmt.Println("❌ " + err.Error())
+			return err
+		}
+	}
+	if name != rawName {
+		fmt.Println("⚠️  " + trf("Name had extra whitespace, saved as '%s'", name))
+	}
+	warnIdentityText("Name", name)
+	warnIdentityText("Email", email)
+	if opts.VerifyDomain {
+		if err := verifyEmailDomain(email); err != nil {
+			fmt.Println("❌ " + err.Error())
+			fmt.Println(tr("Leave out --verify-domain to save it anyway"))
+			return err
 		}
 	}
 
-	if name == "" || email == "" {
-		return fmt.Errorf("❌ Name and email are required!")
+	if (prompted || opts.FromCurrent) && isInteractive() {
+		fmt.Println()
+		fmt.Println("  " + trf("Name:  %s", name))
+		fmt.Println("  " + trf("Email: %s", email))
+		if current.SigningKey != "" {
+			fmt.Println("  " + trf("Signing key: %s", current.SigningKey))
+		}
+		answer, err := readAnswer(bufio.NewReader(os.Stdin), trf("Save profile '%s'? [Y/n] ", profileName), "")
+		if err != nil || strings.ToLower(strings.TrimSpace(answer)) == "n" {
+			fmt.Println(tr("Nothing changed"))
+			return nil
+		}
 	}
 
-	profiles[profileName] = Profile{
-		Name:  name,
-		Email: email,
+	// Keep any other settings of an existing profile
+	profile := profiles[profileName]
+	profile.Name = name
+	profile.Email = email
+	if current.SigningKey != "" {
+		profile.SigningKey, profile.SigningFormat, profile.X509Program = current.SigningKey, current.SigningFormat, current.X509Program
 	}
+	profiles[profileName] = profile
 
-	if err := saveProfiles(profiles); err != nil {
+	if err := saveConfig(config); err != nil {
 		return err
 	}
+	events = append(events, pluginEvent{Event: pluginEventAdd, Profile: profileName, Details: profile})
 
-	fmt.Printf("✅ Profile '%s' saved!\n", profileName)
-	fmt.Printf("   Name:  %s\n", name)
-	fmt.Printf("   Email: %s\n", email)
-	fmt.Printf("\nUse: git usr %s\n", profileName)
+	fmt.Println("✅ " + trf("Profile '%s' saved!", profileName))
+	fmt.Println("   " + trf("Name:  %s", name))
+	fmt.Println("   " + trf("Email: %s", email))
+	if current.SigningKey != "" {
+		fmt.Println("   " + trf("Signing key: %s", current.SigningKey))
+	}
+	fmt.Println("\n" + trf("Use: git usr %s", profileName))
 
 	return nil
 }
 
-// removeProfile removes a profile
-func removeProfile(profileName string) error {
-	profiles, err := loadProfiles()
+// runAdd handles the add command
+func runAdd(args []string) error {
+	var opts addOptions
+	var values, pairs []string
+	batch, dryRun := "", false
+	userName, strict := "", false
+	pairsUsage := tr("Usage:") + " git usr add --profile <profile>=<email>... [--name <name>] [--strict] [--dry-run]"
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--profile" || arg == "--name":
+			if i+1 >= len(args) {
+				fmt.Println(pairsUsage)
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			if arg == "--profile" {
+				pairs = append(pairs, args[i+1])
+			} else {
+				userName = args[i+1]
+			}
+			i++
+		case strings.HasPrefix(arg, "--profile="):
+			pairs = append(pairs, strings.TrimPrefix(arg, "--profile="))
+		case strings.HasPrefix(arg, "--name="):
+			userName = strings.TrimPrefix(arg, "--name=")
+		case arg == "--strict":
+			strict = true
+		case arg == "--batch":
+			if i+1 >= len(args) {
+				fmt.Println(tr("Usage:") + " git usr add --batch <file|-> [--dry-run]")
+				return fmt.Errorf("--batch requires a file")
+			}
+			batch = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--batch="):
+			batch = strings.TrimPrefix(arg, "--batch=")
+		case arg == "--dry-run":
+			dryRun = true
+		case arg == "--verify-domain":
+			opts.VerifyDomain = true
+		case arg == "--from-current":
+			opts.FromCurrent = true
+		case arg == "--global":
+			opts.Global = true
+		case arg == "--force":
+			opts.Force = true
+		default:
+			values = append(values, arg)
+		}
+	}
+	if batch != "" {
+		if len(values) > 0 || opts != (addOptions{}) {
+			fmt.Println(tr("Usage:") + " git usr add --batch <file|-> [--dry-run]")
+			return fmt.Errorf("--batch takes no other arguments")
+		}
+		return addBatch(batch, dryRun)
+	}
+	if len(pairs) > 0 {
+		if len(values) > 0 || opts != (addOptions{}) {
+			fmt.Println(pairsUsage)
+			return fmt.Errorf("--profile takes no other arguments")
+		}
+		return addProfilePairs(pairs, userName, batchOptions{DryRun: dryRun, Strict: strict})
+	}
+	if userName != "" || strict {
+		fmt.Println(pairsUsage)
+		return fmt.Errorf("--name and --strict require --profile")
+	}
+	usage := tr("Usage:") + " git usr add <profile> [name] [email] [--from-current [--global]] [--verify-domain] [--force]"
+	if dryRun {
+		fmt.Println(usage)
+		return fmt.Errorf("--dry-run requires --batch")
+	}
+	if len(values) == 0 {
+		fmt.Println("❌ " + tr("Profile name required!"))
+		fmt.Println(usage)
+		return fmt.Errorf("profile name required")
+	}
+	if len(values) > 3 {
+		fmt.Println(usage)
+		return fmt.Errorf("unexpected argument: %s", values[3])
+	}
+	if opts.Global && !opts.FromCurrent {
+		fmt.Println(usage)
+		return fmt.Errorf("--global requires --from-current")
+	}
+
+	name, email := "", ""
+	if len(values) > 1 {
+		name = values[1]
+	}
+	if len(values) > 2 {
+		email = values[2]
+	}
+	return addProfile(values[0], name, email, opts)
+}
+
+// addProfilePairs adds or updates the profiles of profile=email pairs, each
+// with userName or, for new profiles without it, the global user.name
+func addProfilePairs(pairs []string, userName string, opts batchOptions) error {
+	var entries []batchEntry
+	for i, pair := range pairs {
+		profileName, email, ok := strings.Cut(pair, "=")
+		if !ok {
+			fmt.Printf("❌ '%s' isn't <profile>=<email>\n", pair)
+			return fmt.Errorf("invalid --profile: %s", pair)
+		}
+		fields := map[string]any{"email": email}
+		if userName != "" {
+			fields["name"] = userName
+		}
+		entries = append(entries, batchEntry{Position: i + 1, ProfileName: strings.TrimSpace(profileName), Fields: fields})
+	}
+	opts.DefaultName = getScopedGitConfigValue("global", "user.name")
+	return applyBatch(entries, opts)
+}
+
+// removeOptions are the flags of the remove command
+type removeOptions struct {
+	All        bool
+	Force      bool
+	SkipUnsafe bool
+	// Strict fails when any of several profiles doesn't exist, rather than
+	// only when none does
+	Strict bool
+}
+
+// answeredYes reports whether the answer to a [y/N] question is yes
+func answeredYes(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes" || answer == tr("y") || answer == tr("yes")
+}
+
+// removeProfiles removes profiles, or every profile with opts.All, and
+// retracts the git config they set. Profiles that don't exist are reported
+// and the others removed. On a terminal the profiles are shown and
+// confirmed first unless opts.Force is set. Repositories git refuses to use
+// are reported unless opts.SkipUnsafe is set
+func removeProfiles(profileNames []string, opts removeOptions) error {
+	// Plugins run once the lock is released, so they can run git usr too
+	var events []pluginEvent
+	defer func() { runPlugins(events...) }()
+
+	unlock, err := acquireConfigLock()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	if _, exists := profiles[profileName]; !exists {
-		return fmt.Errorf("❌ Profile '%s' not found!", profileName)
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles := config.Profiles
+
+	var names []string
+	missing := 0
+	if opts.All {
+		names = sortedProfileNames(profiles)
+		if len(names) == 0 {
+			fmt.Println(tr("No profiles yet. Use 'git usr add' to create one"))
+			return nil
+		}
+	}
+	for _, name := range profileNames {
+		if _, exists := profiles[name]; !exists {
+			fmt.Println("❌ " + trf("Profile '%s' not found!", name))
+			missing++
+		} else if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return errProfileNotFound
+	case missing > 0 && opts.Strict:
+		fmt.Println(tr("Nothing changed"))
+		return errProfileNotFound
 	}
 
-	delete(profiles, profileName)
+	if !opts.Force {
+		if opts.All && !isInteractive() {
+			fmt.Println("❌ " + tr("Refusing to remove all profiles without a terminal to confirm on; add --force"))
+			return fmt.Errorf("remove --all requires --force")
+		}
+		if isInteractive() {
+			for _, name := range names {
+				fmt.Printf("\n%s:\n", name)
+				profile := profiles[name]
+				for _, line := range profileFieldLines(&profile) {
+					fmt.Println("  " + line)
+				}
+				for _, key := range sortedKeys(profile.Env) {
+					fmt.Printf("  env %s=%s\n", key, profile.Env[key])
+				}
+			}
+			prompt := trf("Remove profile '%s'? [y/N] ", names[0])
+			if opts.All {
+				prompt = trf("Remove all %d profiles? [y/N] ", len(names))
+			} else if len(names) > 1 {
+				prompt = trf("Remove %d profiles? [y/N] ", len(names))
+			}
+			fmt.Println()
+			answer, err := readAnswer(bufio.NewReader(os.Stdin), prompt, "")
+			if err != nil || !answeredYes(answer) {
+				fmt.Println(tr("Nothing changed"))
+				return nil
+			}
+		}
+	}
 
-	if err := saveProfiles(profiles); err != nil {
+	removed := map[string]Profile{}
+	for _, name := range names {
+		removed[name] = profiles[name]
+		delete(profiles, name)
+	}
+	if err := saveConfig(config); err != nil {
 		return err
 	}
+	for _, name := range names {
+		events = append(events, pluginEvent{Event: pluginEventRemove, Profile: name, Details: removed[name]})
+	}
 
-	fmt.Printf("✅ Profile '%s' removed!\n", profileName)
+	var unsafe []error
+	for _, name := range names {
+		fmt.Println("✅ " + trf("Profile '%s' removed!", name))
+
+		count, unsafeRepos, err := retractProfileKeys(name)
+		if err != nil {
+			fmt.Printf("⚠️  Could not retract git config set by '%s': %v\n", name, err)
+		} else if count > 0 {
+			fmt.Printf("🧹 Retracted %d git config value(s) set by '%s'\n", count, name)
+		}
+		unsafe = append(unsafe, unsafeRepos...)
+	}
+	if len(unsafe) > 0 && opts.SkipUnsafe {
+		fmt.Printf("⏭️  Skipped %d repositories owned by other users\n", len(unsafe))
+	} else {
+		for _, unsafeErr := range unsafe {
+			printUnsafeGuidance(unsafeErr)
+		}
+	}
 	return nil
 }
 
+// runRemove handles the remove command
+func runRemove(args []string) error {
+	var opts removeOptions
+	var names []string
+	for _, arg := range args {
+		switch arg {
+		case "--all":
+			opts.All = true
+		case "--force", "-y", "--yes":
+			opts.Force = true
+		case "--skip-unsafe":
+			opts.SkipUnsafe = true
+		case "--strict":
+			opts.Strict = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				fmt.Println(tr("Usage:") + " git usr remove <profile>...|--all [--force] [--strict] [--skip-unsafe]")
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			names = append(names, arg)
+		}
+	}
+	switch {
+	case opts.All && len(names) > 0:
+		fmt.Println(tr("Usage:") + " git usr remove <profile>...|--all [--force] [--strict] [--skip-unsafe]")
+		return fmt.Errorf("--all takes no profile name")
+	case !opts.All && len(names) == 0:
+		fmt.Println("❌ " + tr("Profile name required!"))
+		fmt.Println(tr("Usage:") + " git usr remove <profile>...|--all [--force] [--strict] [--skip-unsafe]")
+		return fmt.Errorf("profile name required")
+	}
+	return removeProfiles(names, opts)
+}
+
 // showCurrent shows the current git configuration
 func showCurrent() error {
 	name, email, err := getCurrentGitConfig()
@@ -275,56 +1273,163 @@ func showCurrent() error {
 	}
 
 	if name != "" && email != "" {
-		fmt.Println("\n📝 Current git configuration:")
-		fmt.Printf("   Name:  %s\n", name)
-		fmt.Printf("   Email: %s\n", email)
+		fmt.Println("\n📝 " + tr("Current git configuration:"))
+		fmt.Println("   " + trf("Name:  %s", name))
+		fmt.Println("   " + trf("Email: %s", email))
+		if committerName, committerEmail := getGitConfigValue("committer.name"), getGitConfigValue("committer.email"); committerName != "" || committerEmail != "" {
+			if committerName == "" {
+				committerName = name
+			}
+			if committerEmail == "" {
+				committerEmail = email
+			}
+			fmt.Printf("   Committer: %s\n", formatAddress(committerName, committerEmail))
+		}
+		if status, ok := hardwareTokenStatus(gitSigningProfile()); ok {
+			fmt.Printf("   Token: %s\n", status.describe())
+		}
 	} else {
-		fmt.Println("❌ No git configuration found in this repository")
+		fmt.Println("❌ " + tr("No git configuration found in this repository"))
 	}
 
 	return nil
 }
 
-// showHelp displays help information
-func showHelp() {
-	configPath, _ := getConfigPath()
+// showCurrentValue prints a single raw value of the current configuration
+// (name, email or profile) for use in scripts
+func showCurrentValue(field string) error {
+	var value string
+
+	switch field {
+	case "name":
+		value = getGitConfigValue("user.name")
+	case "email":
+		value = getGitConfigValue("user.email")
+	case "profile":
+		profiles, err := loadProfiles()
+		if err != nil {
+			return err
+		}
+		name, email, _ := getCurrentGitConfig()
+		if name != "" && email != "" {
+			value, _ = findProfileByIdentity(profiles, name, email)
+		}
+	default:
+		return fmt.Errorf("unknown field: %s", field)
+	}
+
+	if value == "" {
+		return fmt.Errorf("no %s configured", field)
+	}
+
+	fmt.Println(value)
+	return nil
+}
 
-	fmt.Println(`
+// helpText is the help, translated line by line by localizeHelp
+const helpText = `
 🔧 Git User Profile Switcher
 
 Usage:
   git usr <profile>              Switch to profile (local scope)
   git usr <profile> --global     Switch to profile (global scope)
-  git usr list                   List all profiles
+  git usr <profile> --local      Switch to profile for this repository, whatever its scope
+  git usr <profile> --exact      Don't accept a prefix or typo of the name
+  git usr switch --auto [--global]  Switch to the profile rules, pin or remote pick for this repository
+  git usr list [--sort name|email|used] [--filter <text>] [--tag <tag>] [-q]  List profiles as a table
+  git usr find <text>            List the profiles whose name, email, tags or description contain text
+  git usr setup                  Set up your profiles step by step
+  git usr bootstrap --from-env   Set the global identity from the environment, e.g. in a devcontainer
   git usr add <profile>          Add/update a profile (interactive)
   git usr add <profile> "Name" "email@example.com"
-  git usr remove <profile>       Remove a profile
+  git usr add ... --verify-domain  Check that the email's domain receives mail
+  git usr add <profile> --from-current [--global]  Save the identity git uses now as a profile
+  git usr add --batch <file|-> [--dry-run]  Add or update profiles from JSON or YAML
+  git usr add --profile <profile>=<email>... [--name <name>] [--strict]  Add or update several profiles
+  git usr remove <profile>... [--strict] [--skip-unsafe]  Remove profiles and retract their git config
+  git usr dedupe [--keep <profile>]... [--dry-run]  Merge profiles that share an email
+  git usr remove --all           Remove every profile
+  git usr remove ... --force     Don't ask for confirmation (also -y)
   git usr current                Show current git config
+  git usr current --name-only|--email-only|--profile-only  Print a single raw value
+  git usr diff                   Compare the global and local identity and their profiles
+  git usr prompt [--format <fmt>]  Print the current profile for shell prompts
+  git usr session hook bash|zsh|fish  Summarize identity switches when the shell exits
+  git usr check                  Warn when the identity isn't the one expected here (for cd hooks)
+  git usr check --pre-commit     Fail when the next commit breaks the repository's policy or your rules
+  git usr prompt --check         Exit 0 ok, 3 mismatch, 4 no identity, 5 not a repo, 6 unsafe, 10 locked
+  git usr profile show|get|set|unset <profile> ...  Show or change profile fields
+  git usr clone <url> [dir] [--profile <profile>]  Clone with a profile applied
+  git usr config list|get|set    Show or change settings
+  git usr interop [status] | sync [--from-windows]  Compare or align the WSL and Windows global identities
+  git usr default [<profile>|--unset]  Show or set the default profile
+  git usr apply <profile> [--submodules]  Switch this repository and its submodules to a profile
+  git usr pin [<profile>|--unset] [--tracked]  Pin this repository to a profile and warn when its identity drifts
+  git usr rules [list]|add|remove <n>  Require a profile or email domain by remote, directory or branch
+  git usr rules test [--remote <url>] [--dir <path>] [--branch <name>]  Show which rule applies and if the identity satisfies it
+  git usr init [--force]         Apply the default profile to this repository
+  git usr init --install-template  Apply the default profile to new clones
+  git usr init --install-hooks   Check the identity on every branch change
+  git usr hooks install|uninstall [--global]  Install all hooks, keeping and chaining existing ones
+  git usr hooks status           Show which git-usr hooks run here
+  git usr hooks on-switch <script>  Run a script after every switch (--remove to stop)
+  git usr plugins list           List the plugins run on add, remove and switch
+  git usr watch add <dir> [<profile>]  Apply profiles to new clones in a directory
+  git usr watch start|stop|status  Run the watcher in the background, show its log
+  git usr env <profile> [--format dch] [--shell fish|powershell]  Print export statements for a profile
+  git usr env <profile> --set KEY=VALUE [--unset KEY]  Manage profile variables
+  git usr exec <profile> -- <cmd>  Run a command with a profile's environment
+  git usr direnv <profile> [--ssh-key <path>] | --remove  Export a profile's identity from this directory's .envrc
+  git usr pair <profile|coauthor>... | --stop  Add Co-authored-by trailers for teammates
+  git usr coauthor add|list|remove  Manage teammates to pair with who aren't profiles
+  git usr push-to [<git push args>]  Push to the current profile's push remote
+  git usr keys setup <profile> --gpg|--ssh-signing|--x509|--gitsign  Set up a signing key for a profile
+  git usr signers sync [--dry-run]  Trust all profiles' SSH keys in allowed_signers
+  git usr mailmap generate [--canonical <profile>] [--append]  Map all your emails to one identity in .mailmap
+  git usr verify <profile> [--forge github|gitlab]  Check the email is verified on your forge account
+  git usr serve --stdio          Answer JSON API requests for editors and prompts
+  git usr serve --unix-socket [<path>]  Answer JSON API requests on a socket, for GUI apps
+  git usr menubar [--dir <path>] Print an xbar/SwiftBar menu to see and switch profiles
+  git usr lint [--unused-months <n>]  Flag placeholder, duplicate and unused profiles
+  git usr prune [--unused-months <n>]  Archive or delete unused profiles (--archived, --restore <profile>, --scan <dir>)
+  git usr stats [<profile>]      Show how often and where profiles are switched to
+  git usr doctor [<profile>...]  Check signing certificates and the tools profiles rely on
+  git usr assert [--profile <profile>] [--domain <domain>]  Fail unless the next commit is made as expected
+  git usr tag <profile> <tagname> [-m <message>]  Create a signed tag as a profile
+  git usr report --html <file>   Write an HTML report of profile usage and identity problems
+  git usr import --csv <file> [--update]  Create profiles from a CSV file
+  git usr team pull <path|url>   Import signed team profiles (see README)
+  git usr managed list           Show git config values written by git-usr
+  git usr repair [--from-backup] [--dry-run]  Salvage a config file that fails to parse
+  git usr backup list | restore <id>  List or restore the backups kept on every change
+  git usr lock                   Encrypt the config file with a passphrase
+  git usr unlock                 Decrypt the config file
   git usr completion [bash|zsh|fish|powershell]  Generate completion script
-  git usr version                Show version information
+  git usr completion install [<shell>]  Install completion and load it from your shell rc file
+  git usr version [--json]       Show version information
   git usr help                   Show this help
 
+Global flags:
+  -C <path>                      Run as if started in <path>, like git -C
+  --config <path>                Use an alternate profiles file (or set GIT_USR_CONFIG)
+  --plain                        Print without emoji or color (or set GIT_USR_NO_EMOJI)
+  --portable                     Keep the config next to the executable (or set GIT_USR_PORTABLE)
+  --no-seed                      Start a new config without example profiles (or set GIT_USR_NO_SEED)
+  -q, --quiet                    Print nothing but errors, to stderr
+
 Examples:
   git usr work                   Switch to work profile (local)
   git usr personal --global      Switch to personal profile (global)
   git usr add work "John Doe" "john@company.com"
   git usr list                   List all available profiles
 
-Config location: ` + configPath)
-}
+`
 
-// showVersion displays version information
-func showVersion() {
-	fmt.Print(`
-            __
-           / _)
-    .-^^^-/ /
- __/       /
-<__.|_|-|_|
+// showHelp displays help information
+func showHelp() {
+	configPath, _ := getConfigPath()
 
-git-usr version ` + version + `
-Made by Aman (thammina@msu.edu)
-`)
+	fmt.Println(localizeHelp(helpText) + trf("Config location: %s", configPath))
 }
 
 // getProfileNames returns a comma-separated list of profile names
@@ -336,61 +1441,29 @@ func getProfileNames(profiles map[string]Profile) string {
 	return strings.Join(names, ", ")
 }
 
-// generateCompletion generates shell completion scripts
-func generateCompletion(shell string) error {
-	profiles, err := loadProfiles()
-	if err != nil {
-		return err
-	}
-
-	profileNames := make([]string, 0, len(profiles))
-	for name := range profiles {
-		profileNames = append(profileNames, name)
-	}
-
-	switch shell {
-	case "bash":
-		fmt.Println(getBashCompletion(profileNames))
-	case "zsh":
-		fmt.Println(getZshCompletion(profileNames))
-	case "fish":
-		fmt.Println(getFishCompletion(profileNames))
-	case "powershell":
-		fmt.Println(getPowershellCompletion(profileNames))
-	default:
-		return fmt.Errorf("❌ Unsupported shell: %s. Supported: bash, zsh, fish, powershell", shell)
-	}
-
-	return nil
-}
-
-func getBashCompletion(profiles []string) string {
+func getBashCompletion() string {
 	return `# bash completion for git-usr
+#
+# Also completes "git usr ...": git's completion calls _git_usr for the usr
+# subcommand and git aliases of it. For a shell alias like gu='git usr',
+# add: __git_complete gu _git_usr
 _git_usr() {
-    local cur prev opts
-    COMPREPLY=()
-    cur="${COMP_WORDS[COMP_CWORD]}"
-    prev="${COMP_WORDS[COMP_CWORD-1]}"
-
-    # Main commands
-    local commands="list current add remove help version completion ` + strings.Join(profiles, " ") + `"
-    
-    # Completion for subcommands
-    case "${prev}" in
-        completion)
-            COMPREPLY=( $(compgen -W "bash zsh fish powershell" -- ${cur}) )
-            return 0
-            ;;
-        remove)
-            COMPREPLY=( $(compgen -W "` + strings.Join(profiles, " ") + `" -- ${cur}) )
-            return 0
-            ;;
-        *)
-            ;;
-    esac
-
-    # Complete with available commands and profiles
-    COMPREPLY=( $(compgen -W "${commands} --global" -- ${cur}) )
+    # git's completion passes its own words, with "git [options] usr" up to
+    # __git_cmd_idx; otherwise the words are "git-usr ..."
+    local -a line=("${COMP_WORDS[@]}")
+    local current=$COMP_CWORD start=1
+    if [ -n "${cword-}" ]; then
+        line=("${words[@]}")
+        current=$cword
+    fi
+    if [ -n "${__git_cmd_idx-}" ]; then
+        start=$((__git_cmd_idx + 1))
+    elif [ "${line[0]##*/}" = git ]; then
+        start=2
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=( $(git-usr __complete "${line[@]:start:current-start+1}" 2>/dev/null | cut -f1) )
     return 0
 }
 
@@ -400,46 +1473,25 @@ complete -F _git_usr git-usr
 # Or save to /etc/bash_completion.d/git-usr`
 }
 
-func getZshCompletion(profiles []string) string {
-	profileList := strings.Join(profiles, " ")
+func getZshCompletion() string {
 	return `#compdef git-usr
+#
+# Also completes "git usr ...": zsh's _git calls _git-usr, the function
+# this file defines, for the usr subcommand. To list usr among git's
+# commands, add: zstyle ':completion:*:*:git:*' user-commands usr:'switch git identities'
 
 _git_usr() {
-    local -a commands profiles
-    commands=(
-        'list:List all profiles'
-        'current:Show current git config'
-        'add:Add or update a profile'
-        'remove:Remove a profile'
-        'version:Show version information'
-        'help:Show help'
-        'completion:Generate completion script'
-    )
-    
-    profiles=(` + profileList + `)
-
-    _arguments -C \
-        '1: :->command' \
-        '2: :->args' \
-        '*::arg:->args' \
-        '--global[Apply globally]'
-
-    case $state in
-        command)
-            _describe -t commands 'git-usr commands' commands
-            _describe -t profiles 'profiles' profiles
-            ;;
-        args)
-            case $words[1] in
-                completion)
-                    _values 'shell' bash zsh fish powershell
-                    ;;
-                remove)
-                    _describe -t profiles 'profiles' profiles
-                    ;;
-            esac
-            ;;
-    esac
+    local -a candidates
+    local line
+    for line in "${(@f)$(git-usr __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -n $line ]] && candidates+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+    done
+
+    if (( ${#candidates} )); then
+        _describe 'git-usr' candidates
+    else
+        _files
+    fi
 }
 
 _git_usr "$@"
@@ -448,154 +1500,451 @@ _git_usr "$@"
 # Then add to ~/.zshrc: fpath=(~/.zsh/completions $fpath) && autoload -U compinit && compinit`
 }
 
-func getFishCompletion(profiles []string) string {
-	completions := `# fish completion for git-usr
-
-# Main commands
-complete -c git-usr -f -n "__fish_use_subcommand" -a "list" -d "List all profiles"
-complete -c git-usr -f -n "__fish_use_subcommand" -a "current" -d "Show current git config"
-complete -c git-usr -f -n "__fish_use_subcommand" -a "add" -d "Add or update a profile"
-complete -c git-usr -f -n "__fish_use_subcommand" -a "remove" -d "Remove a profile"
-complete -c git-usr -f -n "__fish_use_subcommand" -a "version" -d "Show version information"
-complete -c git-usr -f -n "__fish_use_subcommand" -a "help" -d "Show help"
-complete -c git-usr -f -n "__fish_use_subcommand" -a "completion" -d "Generate completion script"
+func getFishCompletion() string {
+	return `# fish completion for git-usr
+#
+# Also completes "git usr ...": fish's git completions hand git-* commands
+# in PATH to their own completions
 
-# Profiles
-`
-	for _, profile := range profiles {
-		completions += fmt.Sprintf("complete -c git-usr -f -n \"__fish_use_subcommand\" -a \"%s\" -d \"Switch to %s profile\"\n", profile, profile)
-	}
+function __git_usr_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    # "git [options] usr ...": drop everything up to usr
+    if test "$tokens[1]" = git; and set -l usr (contains -i -- usr $tokens)
+        set tokens $tokens[$usr..-1]
+    end
+    git-usr __complete $tokens[2..-1] 2>/dev/null
+end
 
-	completions += `
-# Completion for completion subcommand
-complete -c git-usr -f -n "__fish_seen_subcommand_from completion" -a "bash zsh fish powershell"
-
-# Completion for remove subcommand
-`
-	for _, profile := range profiles {
-		completions += fmt.Sprintf("complete -c git-usr -f -n \"__fish_seen_subcommand_from remove\" -a \"%s\"\n", profile)
-	}
-
-	completions += `
-# Global flag
-complete -c git-usr -l global -d "Apply globally"
+complete -c git-usr -f -a '(__git_usr_complete)'
 
 # Installation: Save to ~/.config/fish/completions/git-usr.fish`
-
-	return completions
 }
 
-func getPowershellCompletion(profiles []string) string {
-	profileList := "'" + strings.Join(profiles, "', '") + "'"
+func getPowershellCompletion() string {
 	return `# PowerShell completion for git-usr
 
-Register-ArgumentCompleter -Native -CommandName git-usr -ScriptBlock {
+$gitUsrCompleter = {
     param($wordToComplete, $commandAst, $cursorPosition)
 
-    $commands = @('list', 'current', 'add', 'remove', 'version', 'help', 'completion')
-    $profiles = @(` + profileList + `)
-    $shells = @('bash', 'zsh', 'fish', 'powershell')
-
-    $tokens = $commandAst.ToString() -split '\s+'
-    
-    if ($tokens.Count -eq 2) {
-        # Complete main commands and profiles
-        $allOptions = $commands + $profiles + @('--global')
-        $allOptions | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
-            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
-        }
+    $words = @($commandAst.CommandElements | Where-Object { $_.Extent.StartOffset -lt $cursorPosition } | ForEach-Object { $_.ToString() })
+    # "git [options] usr ...": drop everything up to usr
+    if ($words[0] -match '^git(\.exe)?$') {
+        $usr = [array]::IndexOf($words, 'usr')
+        if ($usr -lt 0 -or ($usr -eq $words.Count - 1 -and $wordToComplete)) { return }
+        $words = $words[$usr..($words.Count - 1)]
+    }
+    $words = @($words | Select-Object -Skip 1)
+    if ($wordToComplete -eq '') {
+        # PowerShell before 7.3 drops empty arguments; git-usr trims this one
+        $words += ' '
     }
-    elseif ($tokens.Count -eq 3) {
-        switch ($tokens[1]) {
-            'completion' {
-                $shells | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
-                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
-                }
-            }
-            'remove' {
-                $profiles | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
-                    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
-                }
-            }
-        }
+
+    git-usr __complete @words 2>$null | ForEach-Object {
+        $value, $description = $_ -split "` + "`" + `t", 2
+        if (-not $description) { $description = $value }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
     }
 }
 
+Register-ArgumentCompleter -Native -CommandName git-usr -ScriptBlock $gitUsrCompleter
+# "git usr ...", unless posh-git completes git: there can only be one
+# native completer per command
+if (-not (Get-Module posh-git)) {
+    Register-ArgumentCompleter -Native -CommandName git -ScriptBlock $gitUsrCompleter
+}
+
 # Installation: Add this to your PowerShell profile ($PROFILE)
 # Or dot-source this file: . path\to\git-usr-completion.ps1`
 }
 
+// passthroughCommands hand their arguments to another program
+var passthroughCommands = map[string]bool{
+	"exec":    true,
+	"push-to": true,
+}
+
+// parseGlobalFlags extracts flags accepted by every command (such as
+// --config <path>) and returns the remaining arguments. Like git, several
+// -C options combine, each relative to the one before, and the arguments
+// of commands passing them on are left alone, as git leaves its
+// subcommands' alone
+func parseGlobalFlags(args []string) ([]string, error) {
+	remaining := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			// The rest belongs to a command run by git-usr
+			return append(remaining, args[i:]...), nil
+		case len(remaining) > 0 && passthroughCommands[remaining[0]]:
+			return append(remaining, args[i:]...), nil
+		case arg == "--plain":
+			plainOutput = true
+		case arg == "--portable":
+			portableFlag = true
+		case arg == "--no-seed":
+			noSeedFlag = true
+		case (arg == "-q" || arg == "--quiet") && (len(remaining) == 0 || !ownQuietCommands[remaining[0]] && !rawOutputCommands[remaining[0]]):
+			quietOutput = true
+		case arg == "--config":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--config requires a path")
+			}
+			configPathOverride = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--config="):
+			configPathOverride = strings.TrimPrefix(arg, "--config=")
+		case arg == "-C":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("-C requires a path")
+			}
+			// An empty path leaves the directory as it is
+			if dir := args[i+1]; dir != "" {
+				if filepath.IsAbs(dir) || workDirOverride == "" {
+					workDirOverride = dir
+				} else {
+					workDirOverride = filepath.Join(workDirOverride, dir)
+				}
+			}
+			i++
+		default:
+			remaining = append(remaining, arg)
+		}
+	}
+
+	return remaining, nil
+}
+
+// configFlagArgs returns the global flags a git-usr started by this one
+// needs to find the same config, to go before its command
+func configFlagArgs() []string {
+	var args []string
+	if configPathOverride != "" {
+		args = append(args, "--config", configPathOverride)
+	}
+	if portableFlag {
+		args = append(args, "--portable")
+	}
+	return args
+}
+
+// rawOutputCommands print output read by programs, which plain output
+// must not rewrite
+var rawOutputCommands = map[string]bool{
+	"version":    true,
+	"--version":  true,
+	"-v":         true,
+	"serve":      true,
+	"menubar":    true,
+	"env":        true,
+	"prompt":     true,
+	"completion": true,
+	"__complete": true,
+	"testdata":   true,
+}
+
+// gitlessCommands work without git installed: they either don't touch
+// git config or only need what the file backend reads and writes.
+// Switching profiles does too
+var gitlessCommands = map[string]bool{
+	"help":           true,
+	"--help":         true,
+	"-h":             true,
+	"version":        true,
+	"--version":      true,
+	"-v":             true,
+	"completion":     true,
+	"__complete":     true,
+	"__update-check": true,
+	"list":           true,
+	"find":           true,
+	"dedupe":         true,
+	"mailmap":        true,
+	"direnv":         true,
+	"bootstrap":      true,
+	"plugins":        true,
+	"current":        true,
+	"diff":           true,
+	"add":            true,
+	"remove":         true,
+	"lock":           true,
+	"unlock":         true,
+	"check":          true,
+	"prompt":         true,
+	"config":         true,
+	"profile":        true,
+	"default":        true,
+	"env":            true,
+	"lint":           true,
+	"stats":          true,
+	"repair":         true,
+	"backup":         true,
+	"import":         true,
+	"managed":        true,
+	"coauthor":       true,
+	"rules":          true,
+	"switch":         true,
+	"setup":          true,
+}
+
+// finishOutput flushes plain and quiet output before exiting
+var finishOutput = func() {}
+
 func main() {
-	if len(os.Args) < 2 {
-		showHelp()
-		return
+	args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
 	}
 
-	command := os.Args[1]
-	scope := "local"
+	// Everything after this, git included, runs in the -C directory. A
+	// path still being typed doesn't break completion
+	if workDirOverride != "" {
+		if err := os.Chdir(workDirOverride); err != nil && (len(args) == 0 || args[0] != "__complete") {
+			fmt.Printf("❌ Cannot change to '%s': %v\n", workDirOverride, errors.Unwrap(err))
+			os.Exit(1)
+		}
+	}
 
-	// Check for --global flag
-	for _, arg := range os.Args {
-		if arg == "--global" {
-			scope = "global"
-			break
+	if len(args) > 0 && !rawOutputCommands[args[0]] && (plainOutput || emojiDisabled()) {
+		plainOutput = true
+		finish, err := startPlainOutput()
+		if err == nil {
+			var once sync.Once
+			finishOutput = func() { once.Do(finish) }
 		}
 	}
 
-	var err error
+	// Quiet output goes through plain output, to which it sends errors
+	if quietOutput && len(args) > 0 && !rawOutputCommands[args[0]] {
+		finish, err := startQuietOutput()
+		if err == nil {
+			var once sync.Once
+			finishPlain := finishOutput
+			finishOutput = func() {
+				once.Do(finish)
+				finishPlain()
+			}
+		}
+	}
+	defer func() { finishOutput() }()
+
+	if len(args) < 1 {
+		showHelp()
+		return
+	}
 
+	command := args[0]
+	offerSetup = !rawOutputCommands[command] && !containsString(hiddenCommands, command) && command != "add" && command != "import"
+	if _, err := exec.LookPath("git"); err != nil && !gitlessCommands[command] && reservedProfileName(command) != "" {
+		fmt.Println("❌ git is not installed or not in your PATH")
+		finishOutput()
+		os.Exit(exitGitMissing)
+	}
 	switch command {
 	case "help", "--help", "-h":
 		showHelp()
 
 	case "version", "--version", "-v":
-		showVersion()
+		err = runVersion(args[1:])
 
 	case "list":
-		err = listProfiles()
+		err = runList(args[1:])
 
-	case "current":
-		err = showCurrent()
+	case "find":
+		err = runFind(args[1:])
 
-	case "add":
-		if len(os.Args) < 3 {
-			fmt.Println("❌ Profile name required!")
-			fmt.Println("Usage: git usr add <profile> [name] [email]")
-			return
-		}
-		profileName := os.Args[2]
-		name := ""
-		email := ""
-		if len(os.Args) > 3 {
-			name = os.Args[3]
+	case "dedupe":
+		err = runDedupe(args[1:])
+
+	case "current":
+		field := ""
+		for _, arg := range args[1:] {
+			switch arg {
+			case "--name", "--email", "--profile", "--name-only", "--email-only", "--profile-only":
+				field = strings.TrimSuffix(strings.TrimPrefix(arg, "--"), "-only")
+			}
 		}
-		if len(os.Args) > 4 {
-			email = os.Args[4]
+		if field != "" {
+			err = showCurrentValue(field)
+		} else {
+			err = showCurrent()
 		}
-		err = addProfile(profileName, name, email)
+
+	case "diff":
+		err = runDiff(args[1:])
+
+	case "add":
+		err = runAdd(args[1:])
+
+	case "setup":
+		err = runSetup(args[1:])
+
+	case "bootstrap":
+		err = runBootstrap(args[1:])
 
 	case "remove":
-		if len(os.Args) < 3 {
-			fmt.Println("❌ Profile name required!")
-			fmt.Println("Usage: git usr remove <profile>")
-			return
-		}
-		err = removeProfile(os.Args[2])
+		err = runRemove(args[1:])
+
+	case "lock":
+		err = lockConfig()
+
+	case "unlock":
+		err = unlockConfig()
 
 	case "completion":
-		if len(os.Args) < 3 {
-			fmt.Println("❌ Shell type required!")
-			fmt.Println("Usage: git usr completion [bash|zsh|fish|powershell]")
-			return
-		}
-		err = generateCompletion(os.Args[2])
+		err = runCompletion(args[1:])
+
+	case "check":
+		err = runCheck(args[1:])
+
+	case "prompt":
+		err = runPrompt(args[1:])
+
+	case "config":
+		err = runConfig(args[1:])
+
+	case "profile":
+		err = runProfile(args[1:])
+
+	case "clone":
+		err = runClone(args[1:])
+
+	case "apply":
+		err = runApply(args[1:])
+
+	case "rules":
+		err = runRules(args[1:])
+
+	case "switch":
+		err = runSwitchSubcommand(args[1:])
+
+	case "pin":
+		err = runPin(args[1:])
+
+	case "default":
+		err = runDefault(args[1:])
+
+	case "init":
+		err = runInit(args[1:])
+
+	case "env":
+		err = runEnv(args[1:])
+
+	case "direnv":
+		err = runDirenv(args[1:])
+
+	case "interop":
+		err = runInterop(args[1:])
+
+	case "exec":
+		err = runExec(args[1:])
+
+	case "doctor":
+		err = runDoctor(args[1:])
+
+	case "report":
+		err = runReport(args[1:])
+
+	case "lint":
+		err = runLint(args[1:])
+
+	case "assert":
+		err = runAssert(args[1:])
+
+	case "tag":
+		err = runTag(args[1:])
+
+	case "session":
+		err = runSession(args[1:])
+
+	case "prune":
+		err = runPrune(args[1:])
+
+	case "stats":
+		err = runStats(args[1:])
+
+	case "repair":
+		err = runRepair(args[1:])
+
+	case "backup":
+		err = runBackup(args[1:])
+
+	case "import":
+		err = runImport(args[1:])
+
+	case "team":
+		err = runTeam(args[1:])
+
+	case "managed":
+		err = runManaged(args[1:])
+
+	case "verify":
+		err = runVerify(args[1:])
+
+	case "keys":
+		err = runKeys(args[1:])
+
+	case "signers":
+		err = runSigners(args[1:])
+
+	case "mailmap":
+		err = runMailmap(args[1:])
+
+	case "push-to":
+		err = runPushTo(args[1:])
+
+	case "pair":
+		err = runPair(args[1:])
+
+	case "coauthor":
+		err = runCoauthor(args[1:])
+
+	case "watch":
+		err = runWatch(args[1:])
+
+	case "serve":
+		err = runServe(args[1:])
+
+	case "hooks":
+		err = runHooks(args[1:])
+
+	case "plugins":
+		err = runPluginsCommand(args[1:])
+
+	case "menubar":
+		err = runMenubar(args[1:])
+
+	case "hook":
+		// Hidden: called by the hooks installed with init --install-hooks
+		err = runHook(args[1:])
+
+	case "__complete":
+		// Hidden: called by the completion scripts
+		err = runComplete(args[1:])
+
+	case "testdata":
+		// Hidden: fixtures for integration tests and downstream tooling
+		err = runTestdata(args[1:])
+
+	case "__update-check":
+		// Hidden: started in the background by notifyUpdate
+		err = checkForUpdate()
 
 	default:
 		// Assume it's a profile name
-		err = switchProfile(command, scope)
+		err = runSwitchCommand(command, args[1:])
+	}
+
+	if !rawOutputCommands[command] && !containsString(hiddenCommands, command) {
+		notifyUpdate()
 	}
 
 	if err != nil {
-		os.Exit(1)
+		printUnsafeGuidance(err)
+		finishOutput()
+
+		os.Exit(exitCode(err))
 	}
 }
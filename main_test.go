@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestGetConfigPath tests the config path generation
@@ -17,6 +22,102 @@ func TestGetConfigPath(t *testing.T) {
 	}
 }
 
+// TestGetConfigPathOverrides tests GIT_USR_CONFIG, --config and XDG_CONFIG_HOME
+func TestGetConfigPathOverrides(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(dir, "env", "profiles.json"))
+	path, err := getConfigPath()
+	if err != nil || path != filepath.Join(dir, "env", "profiles.json") {
+		t.Errorf("GIT_USR_CONFIG not honored, got: %s (%v)", path, err)
+	}
+
+	configPathOverride = filepath.Join(dir, "flag.json")
+	defer func() { configPathOverride = "" }()
+	path, err = getConfigPath()
+	if err != nil || path != filepath.Join(dir, "flag.json") {
+		t.Errorf("--config not preferred over GIT_USR_CONFIG, got: %s (%v)", path, err)
+	}
+	configPathOverride = ""
+
+	if runtime.GOOS != "windows" {
+		t.Setenv("GIT_USR_CONFIG", "")
+		t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+		path, err = getConfigPath()
+		if err != nil || path != filepath.Join(dir, "xdg", "git-usr", "profiles.json") {
+			t.Errorf("XDG_CONFIG_HOME not honored, got: %s (%v)", path, err)
+		}
+	}
+}
+
+// TestParseGlobalFlags tests extraction of --config from arguments
+func TestParseGlobalFlags(t *testing.T) {
+	defer func() { configPathOverride = "" }()
+
+	args, err := parseGlobalFlags([]string{"--config", "/tmp/p.json", "list"})
+	if err != nil || len(args) != 1 || args[0] != "list" || configPathOverride != "/tmp/p.json" {
+		t.Errorf("Unexpected result: %v %v %s", args, err, configPathOverride)
+	}
+
+	if _, err := parseGlobalFlags([]string{"list", "--config"}); err == nil {
+		t.Error("Expected error for --config without a path")
+	}
+}
+
+// TestParseGlobalFlagsQuiet tests that -q is global except for commands
+// with a --quiet of their own
+func TestParseGlobalFlagsQuiet(t *testing.T) {
+	defer func() { quietOutput = false }()
+
+	tests := []struct {
+		args  []string
+		quiet bool
+		rest  int
+	}{
+		{[]string{"work", "-q"}, true, 1},
+		{[]string{"--quiet", "current"}, true, 1},
+		{[]string{"list", "-q"}, false, 2},
+		{[]string{"exec", "work", "--", "git", "fetch", "-q"}, false, 6},
+		{[]string{"push-to", "-q"}, false, 2},
+		{[]string{"-q", "push-to", "--force"}, true, 2},
+	}
+	for _, test := range tests {
+		quietOutput = false
+		args, err := parseGlobalFlags(test.args)
+		if err != nil || quietOutput != test.quiet || len(args) != test.rest {
+			t.Errorf("parseGlobalFlags(%v) = %v, %v with quiet %v, expected %d args with quiet %v", test.args, args, err, quietOutput, test.rest, test.quiet)
+		}
+	}
+}
+
+// TestParseGlobalFlagsWorkDir tests that -C options combine like git's
+func TestParseGlobalFlagsWorkDir(t *testing.T) {
+	defer func() { workDirOverride = "" }()
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-C", "src", "current"}, "src"},
+		{[]string{"-C", "src", "-C", "app", "current"}, filepath.Join("src", "app")},
+		{[]string{"-C", "src", "-C", "/repos/app", "current"}, "/repos/app"},
+		{[]string{"-C", "", "current"}, ""},
+		{[]string{"exec", "work", "--", "make", "-C", "build"}, ""},
+		{[]string{"exec", "work", "git", "-C", "sub", "status"}, ""},
+		{[]string{"-C", "src", "exec", "work", "git", "-C", "sub", "status"}, "src"},
+	}
+	for _, test := range tests {
+		workDirOverride = ""
+		if _, err := parseGlobalFlags(test.args); err != nil || workDirOverride != test.expected {
+			t.Errorf("parseGlobalFlags(%q) set %q (%v), expected %q", test.args, workDirOverride, err, test.expected)
+		}
+	}
+
+	if _, err := parseGlobalFlags([]string{"current", "-C"}); err == nil {
+		t.Error("Expected error for -C without a path")
+	}
+}
+
 // TestGetProfileNames tests profile name extraction
 func TestGetProfileNames(t *testing.T) {
 	profiles := map[string]Profile{
@@ -54,24 +155,162 @@ func TestProfileJSONSerialization(t *testing.T) {
 	}
 }
 
+// TestListedProfiles tests filtering and sorting the list
+func TestListedProfiles(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {Name: "Jane Doe", Email: "jane@acme.io"},
+		"personal": {Name: "Jane Doe", Email: "jane@home.dev"},
+		"client":   {Name: "J. Doe", Email: "contractor@bigcorp.com", Description: "BigCorp migration", Tags: []string{"client", "billable"}},
+		"oss":      {Name: "Jane Doe", Email: "jane@oss.dev", Tags: []string{"Client"}},
+	}
+	now := time.Now()
+	lastUsed := map[string]time.Time{"personal": now, "client": now.Add(-time.Hour)}
+
+	tests := []struct {
+		opts     listOptions
+		expected string
+	}{
+		{listOptions{}, "client oss personal work"},
+		{listOptions{Sort: "email"}, "client work personal oss"},
+		{listOptions{Sort: "used"}, "personal client oss work"},
+		{listOptions{Filter: "JANE"}, "oss personal work"},
+		{listOptions{Filter: "bigcorp"}, "client"},
+		{listOptions{Filter: "migration"}, "client"},
+		{listOptions{Filter: "billable"}, "client"},
+		{listOptions{Filter: "nothing"}, ""},
+		{listOptions{Tags: []string{"client"}}, "client oss"},
+		{listOptions{Tags: []string{"client", "billable"}}, "client"},
+		{listOptions{Tags: []string{"client"}, Filter: "oss"}, "oss"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(listedProfiles(profiles, lastUsed, test.opts), " "); got != test.expected {
+			t.Errorf("listedProfiles(%+v) = %q, expected %q", test.opts, got, test.expected)
+		}
+	}
+}
+
+// TestRunFindArgs tests passing find's words and flags on to list
+func TestRunFindArgs(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	for _, args := range [][]string{{}, {"--sort", "email"}, {"jane", "--sort", "bogus"}} {
+		if err := runFind(args); err == nil {
+			t.Errorf("runFind(%q) succeeded, expected an error", args)
+		}
+	}
+	for _, args := range [][]string{{"work"}, {"your", "work", "--sort", "email", "-q"}} {
+		if err := runFind(args); err != nil {
+			t.Errorf("runFind(%q) error = %v", args, err)
+		}
+	}
+}
+
+// TestScopeMarker tests marking the scopes a profile is set in
+func TestScopeMarker(t *testing.T) {
+	work := Profile{Name: "Jane Doe", Email: "jane@acme.io"}
+	home := Profile{Name: "Jane Doe", Email: "jane@home.dev"}
+	tests := []struct {
+		local, global Profile
+		expected      string
+	}{
+		{work, home, "(local)"},
+		{home, work, "(global)"},
+		{work, work, "(both)"},
+		{Profile{}, home, ""},
+		{Profile{Name: "Jane Doe", Email: "JANE@acme.io"}, Profile{}, "(local)"},
+	}
+	for _, test := range tests {
+		if got := scopeMarker(work, test.local, test.global); got != test.expected {
+			t.Errorf("scopeMarker(work, %v, %v) = %q, expected %q", test.local, test.global, got, test.expected)
+		}
+	}
+}
+
+// TestReservedProfileName tests refusing names the dispatcher would take
+// for a command or flag
+func TestReservedProfileName(t *testing.T) {
+	for _, name := range []string{"list", "add", "help", "version", "completion", "hook", "--global", "-x"} {
+		if reservedProfileName(name) == "" {
+			t.Errorf("Expected %q to be reserved", name)
+		}
+	}
+	for _, name := range []string{"work", "personal", "lists", "work-2"} {
+		if reason := reservedProfileName(name); reason != "" {
+			t.Errorf("Expected %q to be allowed, got %s", name, reason)
+		}
+	}
+}
+
+// TestRunSwitchCommandFlags tests rejecting conflicting scopes and
+// arguments switching doesn't take
+func TestRunSwitchCommandFlags(t *testing.T) {
+	for _, args := range [][]string{{"--global", "--local"}, {"--local", "--exact", "--global"}, {"--globall"}, {"extra"}} {
+		if err := runSwitchCommand("work", args); err == nil {
+			t.Errorf("runSwitchCommand(%q) succeeded", args)
+		}
+	}
+}
+
+// TestReadAnswer tests reading whole lines, with spaces, and falling back
+// to the offered answer on an empty line
+func TestReadAnswer(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("John Doe\r\n\n  \nlast"))
+	for _, expected := range []string{"John Doe", "jane@acme.io", "jane@acme.io", "last"} {
+		got, err := readAnswer(reader, "", "jane@acme.io")
+		if err != nil || got != expected {
+			t.Errorf("readAnswer = %q (%v), expected %q", got, err, expected)
+		}
+	}
+	if _, err := readAnswer(reader, "", "jane@acme.io"); err == nil {
+		t.Error("Expected an error at the end of input")
+	}
+}
+
+// TestRunRemoveArgs tests that remove needs exactly one of a profile or
+// --all, and that --all refuses to run unconfirmed without a terminal
+func TestRunRemoveArgs(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	for _, args := range [][]string{{}, {"work", "--all"}, {"work", "oss", "--strict"}, {"work", "--bogus"}, {"--all"}, {"oss", "legacy"}} {
+		if err := runRemove(args); err == nil {
+			t.Errorf("runRemove(%q) succeeded, expected an error", args)
+		}
+	}
+
+	// Without --strict one missing profile doesn't fail the others
+	if err := runRemove([]string{"work", "oss", "personal"}); err != nil {
+		t.Errorf("runRemove() error = %v, expected the existing profiles removed", err)
+	}
+	if profiles, _ := loadProfiles(); len(profiles) != 0 {
+		t.Errorf("profiles left: %v", sortedProfileNames(profiles))
+	}
+
+	for answer, yes := range map[string]bool{"y": true, " YES\n": true, "": false, "n": false, "yep": false} {
+		if answeredYes(answer) != yes {
+			t.Errorf("answeredYes(%q) = %v", answer, !yes)
+		}
+	}
+}
+
 // TestGenerateCompletionBash tests bash completion generation
 func TestGenerateCompletionBash(t *testing.T) {
-	profiles := []string{"work", "personal"}
-	completion := getBashCompletion(profiles)
+	completion := getBashCompletion()
 
 	if completion == "" {
 		t.Error("Bash completion is empty")
 	}
 
-	if !contains(completion, "work") || !contains(completion, "personal") {
-		t.Error("Bash completion missing profiles")
+	if !contains(completion, "git-usr __complete") {
+		t.Error("Bash completion doesn't ask git-usr for candidates")
+	}
+
+	// git's completion calls _git_<subcommand> for "git usr"
+	if !contains(completion, "_git_usr()") || !contains(completion, "__git_cmd_idx") {
+		t.Error("Bash completion missing glue for git's completion")
 	}
 }
 
 // TestGenerateCompletionZsh tests zsh completion generation
 func TestGenerateCompletionZsh(t *testing.T) {
-	profiles := []string{"work"}
-	completion := getZshCompletion(profiles)
+	completion := getZshCompletion()
 
 	if completion == "" {
 		t.Error("Zsh completion is empty")
@@ -84,8 +323,7 @@ func TestGenerateCompletionZsh(t *testing.T) {
 
 // TestGenerateCompletionFish tests fish completion generation
 func TestGenerateCompletionFish(t *testing.T) {
-	profiles := []string{"work"}
-	completion := getFishCompletion(profiles)
+	completion := getFishCompletion()
 
 	if completion == "" {
 		t.Error("Fish completion is empty")
@@ -98,8 +336,7 @@ func TestGenerateCompletionFish(t *testing.T) {
 
 // TestGenerateCompletionPowershell tests powershell completion generation
 func TestGenerateCompletionPowershell(t *testing.T) {
-	profiles := []string{"work"}
-	completion := getPowershellCompletion(profiles)
+	completion := getPowershellCompletion()
 
 	if completion == "" {
 		t.Error("PowerShell completion is empty")
@@ -108,6 +345,10 @@ func TestGenerateCompletionPowershell(t *testing.T) {
 	if !contains(completion, "Register-ArgumentCompleter") {
 		t.Error("PowerShell completion missing Register-ArgumentCompleter")
 	}
+
+	if !contains(completion, "-CommandName git -ScriptBlock") {
+		t.Error("PowerShell completion doesn't complete git usr")
+	}
 }
 
 // TestEmptyProfileHandling tests handling of empty profile sets
@@ -120,6 +361,23 @@ func TestEmptyProfileHandling(t *testing.T) {
 	}
 }
 
+// TestFindProfileByIdentity tests matching a profile by name and email
+func TestFindProfileByIdentity(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {Name: "John", Email: "john@work.com"},
+		"personal": {Name: "John", Email: "john@personal.com"},
+	}
+
+	name, ok := findProfileByIdentity(profiles, "John", "john@work.com")
+	if !ok || name != "work" {
+		t.Errorf("Expected 'work', got: %s", name)
+	}
+
+	if _, ok := findProfileByIdentity(profiles, "Jane", "john@work.com"); ok {
+		t.Error("Expected no match for unknown identity")
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFileName is the file next to profiles.json that records every git
+// config value git-usr has written
+const stateFileName = "state.json"
+
+// ManagedKey is a git config value written by git-usr. Repo is the work
+// tree of a local value and empty for global ones
+type ManagedKey struct {
+	Scope   string `json:"scope"`
+	Repo    string `json:"repo,omitempty"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Profile string `json:"profile"`
+}
+
+// switchHistoryLimit is how many switches the state file remembers
+const switchHistoryLimit = 1000
+
+// SwitchRecord is a switch to a profile. Repo is the work tree of a local
+// switch and empty for global ones
+type SwitchRecord struct {
+	Time    time.Time `json:"time"`
+	Profile string    `json:"profile"`
+	Scope   string    `json:"scope"`
+	Repo    string    `json:"repo,omitempty"`
+}
+
+// ProfileUsage counts the switches to a profile: in total, globally, and
+// per repository
+type ProfileUsage struct {
+	Switches int            `json:"switches"`
+	Global   int            `json:"global,omitempty"`
+	Repos    map[string]int `json:"repos,omitempty"`
+}
+
+// ManagedState is the content of the state file. LastUsed records when
+// each profile was last switched to and Usage how often, since UsageSince,
+// and History the latest switches, oldest first. Shadowed holds the user's
+// own values that managed ones replaced, put back when those are retracted
+type ManagedState struct {
+	Version    int                     `json:"version"`
+	Keys       []ManagedKey            `json:"keys"`
+	Shadowed   []ManagedKey            `json:"shadowed,omitempty"`
+	LastUsed   map[string]time.Time    `json:"lastUsed,omitempty"`
+	Usage      map[string]ProfileUsage `json:"usage,omitempty"`
+	UsageSince *time.Time              `json:"usageSince,omitempty"`
+	History    []SwitchRecord          `json:"history,omitempty"`
+}
+
+// getStatePath returns the path of the state file
+func getStatePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, stateFileName), nil
+}
+
+// loadState reads the state file, returning an empty state if it is missing
+func loadState() (*ManagedState, error) {
+	statePath, err := getStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ManagedState{Version: 1}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", statePath, err)
+	}
+	return state, nil
+}
+
+// updateState loads the state under its lock, applies fn and saves it
+func updateState(fn func(state *ManagedState) error) error {
+	statePath, err := getStatePath()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := acquireFileLock(statePath+".lock", configLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(statePath, data, 0644, false)
+}
+
+// managedRepo returns the work tree a scope refers to when run in dir,
+// or "" for the global scope
+func managedRepo(dir, scope string) (string, error) {
+	if scope == "global" {
+		return "", nil
+	}
+
+	return gitConfig.WorkTree(dir)
+}
+
+// identityKeys returns the user.name/user.email values of a profile
+func identityKeys(profile Profile) []ManagedKey {
+	return []ManagedKey{
+		{Key: "user.name", Value: profile.Name},
+		{Key: "user.email", Value: profile.Email},
+	}
+}
+
+// profileConfigKeys returns the git config values besides the identity
+// that a switch to profile in scope applies
+func profileConfigKeys(profile Profile, scope string) []ManagedKey {
+	keys := signingKeys(profile)
+	// committer.* (git 2.22+) take precedence over user.* for the committer only
+	if profile.CommitterName != "" {
+		keys = append(keys, ManagedKey{Key: "committer.name", Value: profile.CommitterName})
+	}
+	if profile.CommitterEmail != "" {
+		keys = append(keys, ManagedKey{Key: "committer.email", Value: profile.CommitterEmail})
+	}
+	// Other repositories may have no remote of that name, so only a local
+	// switch makes it the push default
+	if profile.PushRemote != "" && scope != "global" {
+		keys = append(keys, ManagedKey{Key: "remote.pushDefault", Value: profile.PushRemote})
+	}
+	if profile.CommitTemplate != "" {
+		keys = append(keys, ManagedKey{Key: "commit.template", Value: profile.CommitTemplate})
+	}
+	for _, trailer := range profile.Trailers {
+		keys = append(keys, ManagedKey{Key: trailerConfigKey, Value: trailer})
+	}
+	keys = append(keys, aliasKeys(profile)...)
+	return append(keys, credentialKeys(profile)...)
+}
+
+// applyProfileConfig writes the profile's config values to scope. The first
+// value of a key replaces all existing ones; repeated keys are added after it.
+// The user's own aliases of the same name are remembered to be put back
+func applyProfileConfig(profile Profile, scope string) error {
+	if err := shadowKeys(scope, aliasKeys(profile)); err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	for _, key := range profileConfigKeys(profile, scope) {
+		set := gitConfig.Set
+		if written[key.Key] {
+			set = gitConfig.Add
+		}
+		written[key.Key] = true
+		if err := set("", scope, key.Key, key.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profileManagedKeys returns every git config value a switch to profile in
+// scope writes
+func profileManagedKeys(profile Profile, scope string) []ManagedKey {
+	keys := append(identityKeys(profile), profileConfigKeys(profile, scope)...)
+	rewrites := profileURLRewrites(profile)
+	for _, prefix := range sortedKeys(rewrites) {
+		keys = append(keys, ManagedKey{Key: "url." + rewrites[prefix] + ".insteadOf", Value: prefix})
+	}
+	return keys
+}
+
+// recordProfileUse notes that profileName was switched to now in scope
+// (as seen from the current directory)
+func recordProfileUse(profileName, scope string) error {
+	repo, _ := managedRepo("", scope)
+	return updateState(func(state *ManagedState) error {
+		now := time.Now().UTC()
+		if state.UsageSince == nil {
+			state.UsageSince = &now
+		}
+		if state.LastUsed == nil {
+			state.LastUsed = map[string]time.Time{}
+		}
+		state.LastUsed[profileName] = now
+
+		state.Usage = usageCounts(state)
+		state.Usage[profileName] = countSwitch(state.Usage[profileName], scope, repo)
+
+		state.History = append(state.History, SwitchRecord{Time: now, Profile: profileName, Scope: scope, Repo: repo})
+		if len(state.History) > switchHistoryLimit {
+			state.History = state.History[len(state.History)-switchHistoryLimit:]
+		}
+		return nil
+	})
+}
+
+// sameLocation reports whether two keys are the same key at the same place
+func sameLocation(a, b ManagedKey) bool {
+	return a.Scope == b.Scope && a.Repo == b.Repo && a.Key == b.Key
+}
+
+// shadowKeys remembers the values in scope that keys are about to replace,
+// unless git-usr wrote them itself or already remembers one there
+func shadowKeys(scope string, keys []ManagedKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	repo, err := managedRepo("", scope)
+	if err != nil {
+		return err
+	}
+
+	return updateState(func(state *ManagedState) error {
+		for _, key := range keys {
+			key.Scope, key.Repo, key.Value = scope, repo, gitConfigValue("", scope, key.Key)
+			if key.Value == "" {
+				continue
+			}
+			if !isManagedValue(state, key) {
+				state.Shadowed = append(state.Shadowed, key)
+			}
+		}
+		return nil
+	})
+}
+
+// isManagedValue reports whether git-usr wrote key's value itself, or
+// already remembers a value shadowed there
+func isManagedValue(state *ManagedState, key ManagedKey) bool {
+	for _, managed := range state.Keys {
+		if sameLocation(managed, key) && managed.Value == key.Value {
+			return true
+		}
+	}
+	for _, shadowed := range state.Shadowed {
+		if sameLocation(shadowed, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// retractManagedKey removes a recorded value from git config, leaving the
+// key alone if someone else has changed it since. A user's value the key
+// replaced is put back once no other value took its place
+func retractManagedKey(state *ManagedState, key ManagedKey) error {
+	if key.Repo != "" {
+		if _, err := os.Stat(key.Repo); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+	if err := unsetGitConfigValue(key.Repo, key.Scope, key.Key, key.Value); err != nil {
+		return err
+	}
+
+	for i, shadowed := range state.Shadowed {
+		if !sameLocation(shadowed, key) {
+			continue
+		}
+		if gitConfigValue(key.Repo, key.Scope, key.Key) != "" {
+			return nil
+		}
+		if err := gitConfig.Set(key.Repo, key.Scope, key.Key, shadowed.Value); err != nil {
+			return err
+		}
+		state.Shadowed = append(state.Shadowed[:i], state.Shadowed[i+1:]...)
+		return nil
+	}
+	return nil
+}
+
+// recordManagedKeys records the values written to scope (as seen from dir)
+// for profileName, retracting values recorded there earlier that are no
+// longer part of the set
+func recordManagedKeys(dir, scope, profileName string, keys []ManagedKey) error {
+	repo, err := managedRepo(dir, scope)
+	if err != nil {
+		return err
+	}
+
+	return updateState(func(state *ManagedState) error {
+		current := map[string]bool{}
+		for _, key := range keys {
+			current[key.Key+"\x00"+key.Value] = true
+		}
+
+		kept := state.Keys[:0]
+		for _, old := range state.Keys {
+			if old.Scope != scope || old.Repo != repo {
+				kept = append(kept, old)
+				continue
+			}
+			if !current[old.Key+"\x00"+old.Value] {
+				if err := retractManagedKey(state, old); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, key := range keys {
+			key.Scope, key.Repo, key.Profile = scope, repo, profileName
+			kept = append(kept, key)
+		}
+		state.Keys = kept
+		return nil
+	})
+}
+
+// retractProfileKeys removes every value recorded for profileName from git
+// config and forgets them, returning how many were retracted. Values in
+// repositories git refuses to use are kept and returned as unsafe
+func retractProfileKeys(profileName string) (int, []error, error) {
+	count := 0
+	var unsafe []error
+	err := updateState(func(state *ManagedState) error {
+		kept := state.Keys[:0]
+		unsafeRepos := map[string]bool{}
+		var firstErr error
+		for _, key := range state.Keys {
+			if key.Profile != profileName {
+				kept = append(kept, key)
+				continue
+			}
+			if err := retractManagedKey(state, key); err != nil {
+				kept = append(kept, key)
+				if isUnsafeRepository(err) {
+					if !unsafeRepos[key.Repo] {
+						unsafeRepos[key.Repo] = true
+						unsafe = append(unsafe, err)
+					}
+				} else if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			count++
+		}
+		state.Keys = kept
+		delete(state.LastUsed, profileName)
+		return firstErr
+	})
+	return count, unsafe, err
+}
+
+// listManagedKeys prints the recorded values grouped by location
+func listManagedKeys() error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	if len(state.Keys) == 0 {
+		fmt.Println("No git config values are managed by git-usr")
+		return nil
+	}
+
+	groups := map[string][]ManagedKey{}
+	order := []string{}
+	for _, key := range state.Keys {
+		location := "global"
+		if key.Scope != "global" {
+			location = key.Repo
+		}
+		if _, seen := groups[location]; !seen {
+			order = append(order, location)
+		}
+		groups[location] = append(groups[location], key)
+	}
+
+	for _, location := range order {
+		fmt.Println(location)
+		for _, key := range groups[location] {
+			fmt.Printf("   %s=%s (%s)\n", key.Key, key.Value, key.Profile)
+		}
+	}
+	return nil
+}
+
+// runManaged handles the managed command
+func runManaged(args []string) error {
+	if len(args) == 1 && args[0] == "list" {
+		return listManagedKeys()
+	}
+
+	fmt.Println("Usage: git usr managed list")
+	return fmt.Errorf("invalid managed command")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestProfileManagedKeys tests the git config values a switch writes
+func TestProfileManagedKeys(t *testing.T) {
+	profile := Profile{
+		Name:        "John Doe",
+		Email:       "john@work.com",
+		HostAliases: map[string]string{"github.com": "github.com-work"},
+	}
+
+	keys := profileManagedKeys(profile, "local")
+	expected := []ManagedKey{
+		{Key: "user.name", Value: "John Doe"},
+		{Key: "user.email", Value: "john@work.com"},
+		{Key: "url.git@github.com-work:.insteadOf", Value: "git@github.com:"},
+		{Key: "url.ssh://git@github.com-work/.insteadOf", Value: "ssh://git@github.com/"},
+	}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %v", len(expected), keys)
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("Key %d: expected %v, got %v", i, expected[i], keys[i])
+		}
+	}
+}
+
+// TestProfileConfigKeys tests the config values applied besides the identity
+func TestProfileConfigKeys(t *testing.T) {
+	if keys := profileConfigKeys(Profile{Name: "John", Email: "j@x.com"}, "local"); len(keys) != 0 {
+		t.Errorf("Expected no config for a plain profile, got %v", keys)
+	}
+
+	keys := profileConfigKeys(Profile{PushRemote: "origin-work"}, "local")
+	if len(keys) != 1 || keys[0].Key != "remote.pushDefault" || keys[0].Value != "origin-work" {
+		t.Errorf("Expected remote.pushDefault=origin-work, got %v", keys)
+	}
+	if keys := profileConfigKeys(Profile{PushRemote: "origin-work"}, "global"); len(keys) != 0 {
+		t.Errorf("Expected no remote.pushDefault for a global switch, got %v", keys)
+	}
+
+	keys = profileConfigKeys(Profile{CommitterName: "John", CommitterEmail: "john@x.com"}, "local")
+	if len(keys) != 2 || keys[0].Key != "committer.name" || keys[1] != (ManagedKey{Key: "committer.email", Value: "john@x.com"}) {
+		t.Errorf("Expected committer.name and committer.email, got %v", keys)
+	}
+
+	keys = profileConfigKeys(Profile{CommitTemplate: "~/.gitmessage-work", Trailers: []string{"Signed-off-by", "Team: payments"}}, "local")
+	expected := []ManagedKey{
+		{Key: "commit.template", Value: "~/.gitmessage-work"},
+		{Key: trailerConfigKey, Value: "Signed-off-by"},
+		{Key: trailerConfigKey, Value: "Team: payments"},
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected commit.template and the trailers, got %v", keys)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// terminalTTYScripts ask the terminal apps git-usr knows for the tty of
+// their front tab, by the name System Events gives the app
+var terminalTTYScripts = map[string]string{
+	"Terminal": `tell application "Terminal" to get tty of selected tab of front window`,
+	"iTerm2":   `tell application "iTerm2" to get tty of current session of current window`,
+}
+
+// runOsascript runs an AppleScript and returns what it printed
+var runOsascript = func(script string) (string, error) {
+	out, err := exec.Command("osascript", "-e", script).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// foregroundProcess picks the foreground process out of `ps -o pid=,stat=`
+// output for a tty: the last one in the foreground process group, which
+// is the shell while it waits at the prompt
+func foregroundProcess(psOutput string) (int, bool) {
+	pid, found := 0, false
+	for _, line := range strings.Split(psOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.Contains(fields[1], "+") {
+			continue
+		}
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			pid, found = n, true
+		}
+	}
+	return pid, found
+}
+
+// processDir returns the working directory of a process from
+// `lsof -Fn` output
+func processDir(lsofOutput string) string {
+	for _, line := range strings.Split(lsofOutput, "\n") {
+		if strings.HasPrefix(line, "n/") {
+			return strings.TrimPrefix(line, "n")
+		}
+	}
+	return ""
+}
+
+// frontmostTerminalDir returns the working directory of the front tab of
+// the frontmost app when it is a terminal, or "" when that can't be told
+var frontmostTerminalDir = func() string {
+	app, err := runOsascript(`tell application "System Events" to get name of first process whose frontmost is true`)
+	if err != nil {
+		return ""
+	}
+	script, ok := terminalTTYScripts[app]
+	if !ok {
+		return ""
+	}
+	tty, err := runOsascript(script)
+	if err != nil || tty == "" {
+		return ""
+	}
+	out, err := exec.Command("ps", "-o", "pid=,stat=", "-t", strings.TrimPrefix(tty, "/dev/")).Output()
+	if err != nil {
+		return ""
+	}
+	pid, ok := foregroundProcess(string(out))
+	if !ok {
+		return ""
+	}
+	out, err = exec.Command("lsof", "-a", "-p", strconv.Itoa(pid), "-d", "cwd", "-Fn").Output()
+	if err != nil {
+		return ""
+	}
+	return processDir(string(out))
+}
+
+// menubarText makes text safe for a menu line, where | starts the
+// parameters
+func menubarText(text string) string {
+	return strings.ReplaceAll(text, "|", "¦")
+}
+
+// menubarAction returns the parameters of a menu line that runs git-usr
+// with args and refreshes the menu afterwards
+func menubarAction(executable string, args []string) string {
+	params := []string{"bash=" + strconv.Quote(executable)}
+	for i, arg := range args {
+		params = append(params, fmt.Sprintf("param%d=%s", i+1, strconv.Quote(arg)))
+	}
+	return strings.Join(append(params, "terminal=false", "refresh=true"), " ")
+}
+
+// menubarView is what the menu shows: the repository of the frontmost
+// terminal, "" outside of one, its prompt --check state, the identity used
+// there and the global one
+type menubarView struct {
+	Repo        string
+	State       int
+	Name, Email string
+	Global      Profile
+}
+
+// menubarLines returns the xbar/SwiftBar plugin output for view. The
+// switch actions run executable with baseArgs before the command
+func menubarLines(config *Config, view menubarView, executable string, baseArgs []string) []string {
+	profileName, known := findProfileByIdentity(config.Profiles, view.Name, view.Email)
+
+	title := profileName
+	switch {
+	case view.Email == "":
+		title = "no identity"
+	case !known:
+		title = view.Email
+	}
+	if view.Email == "" || (view.Repo != "" && view.State == promptCheckMismatch) {
+		title = "⚠️ " + title
+	}
+	lines := []string{"👤 " + menubarText(title), "---"}
+
+	if view.Email != "" {
+		lines = append(lines, menubarText(formatAddress(view.Name, view.Email)))
+	}
+	if view.Repo != "" {
+		lines = append(lines, menubarText(filepath.Base(view.Repo)))
+		if view.State == promptCheckMismatch {
+			lines = append(lines, "Not the identity expected here")
+		}
+	} else {
+		lines = append(lines, "Global identity")
+	}
+	lines = append(lines, "---")
+
+	switchMenu := func(label, scope, current string) {
+		lines = append(lines, label)
+		for _, profile := range sortedProfileNames(config.Profiles) {
+			item := menubarText(profile)
+			if profile == current {
+				item += " ✓"
+			}
+			args := append(append([]string{}, baseArgs...), "switch", profile, "--exact", "--"+scope)
+			lines = append(lines, "--"+item+" | "+menubarAction(executable, args))
+		}
+	}
+	globalProfile, _ := findProfileByIdentity(config.Profiles, view.Global.Name, view.Global.Email)
+	if view.Repo != "" {
+		switchMenu("Switch this repository", "local", profileName)
+	}
+	switchMenu("Switch globally", "global", globalProfile)
+
+	lines = append(lines, "---", "Refresh | refresh=true")
+	return lines
+}
+
+// runMenubar handles the menubar command
+func runMenubar(args []string) error {
+	usage := "Usage: git usr menubar [--dir <path>]"
+	dir := ""
+	switch {
+	case len(args) == 0:
+		dir = frontmostTerminalDir()
+	case len(args) == 2 && args[0] == "--dir":
+		dir = args[1]
+	default:
+		fmt.Println(usage)
+		return fmt.Errorf("invalid menubar command")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// The menu's actions start git-usr elsewhere, so they need to find
+	// the same config and repository
+	baseArgs := configFlagArgs()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	view := menubarView{State: promptCheckNotRepo, Global: currentGitProfile("global")}
+	view.Name, view.Email = view.Global.Name, view.Global.Email
+	if dir != "" {
+		if top, err := gitConfig.WorkTree(dir); err == nil {
+			view.Repo = top
+			baseArgs = append(baseArgs, "-C", top)
+			if view.State, err = promptCheckStateIn(top); err != nil {
+				return err
+			}
+			view.Name, view.Email = getIdentityIn(top)
+		}
+	}
+
+	for _, line := range menubarLines(config, view, executable, baseArgs) {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestForegroundProcess tests finding the shell of a terminal tab
+func TestForegroundProcess(t *testing.T) {
+	ps := "  501 Ss\n  502 S+\n  503 R+\n  504 S\n"
+	if pid, ok := foregroundProcess(ps); !ok || pid != 503 {
+		t.Errorf("foregroundProcess() = %d, %v, expected 503", pid, ok)
+	}
+	if _, ok := foregroundProcess("  501 Ss\n"); ok {
+		t.Error("Expected no foreground process")
+	}
+}
+
+// TestProcessDir tests reading the working directory from lsof output
+func TestProcessDir(t *testing.T) {
+	if got := processDir("p503\nfcwd\nn/Users/jane/src/app\n"); got != "/Users/jane/src/app" {
+		t.Errorf("processDir() = %q", got)
+	}
+	if got := processDir("p503\n"); got != "" {
+		t.Errorf("processDir() = %q, expected nothing", got)
+	}
+}
+
+// TestMenubarLines tests the menu for a repository and outside of one
+func TestMenubarLines(t *testing.T) {
+	config := &Config{Profiles: map[string]Profile{
+		"work": {Name: "Jane Doe", Email: "jane@acme.io"},
+		"oss":  {Name: "Jane Doe", Email: "jane@example.org"},
+	}}
+
+	lines := menubarLines(config, menubarView{
+		Repo:   "/src/app",
+		State:  promptCheckOK,
+		Name:   "Jane Doe",
+		Email:  "jane@acme.io",
+		Global: Profile{Name: "Jane Doe", Email: "jane@example.org"},
+	}, "/usr/local/bin/git-usr", []string{"-C", "/src/app"})
+	menu := strings.Join(lines, "\n")
+	if lines[0] != "👤 work" {
+		t.Errorf("Expected the profile as the title, got %q", lines[0])
+	}
+	for _, want := range []string{
+		"Switch this repository\n--oss | bash=\"/usr/local/bin/git-usr\" param1=\"-C\" param2=\"/src/app\" param3=\"switch\" param4=\"oss\" param5=\"--exact\" param6=\"--local\" terminal=false refresh=true\n--work ✓ |",
+		"Switch globally\n--oss ✓ |",
+		"param6=\"--global\"",
+	} {
+		if !strings.Contains(menu, want) {
+			t.Errorf("Expected %q in the menu:\n%s", want, menu)
+		}
+	}
+
+	lines = menubarLines(config, menubarView{State: promptCheckNotRepo}, "git-usr", nil)
+	menu = strings.Join(lines, "\n")
+	if lines[0] != "👤 ⚠️ no identity" || strings.Contains(menu, "Switch this repository") || !strings.Contains(menu, "Global identity") {
+		t.Errorf("Unexpected menu outside a repository:\n%s", menu)
+	}
+
+	lines = menubarLines(config, menubarView{Repo: "/src/app", State: promptCheckMismatch, Name: "J", Email: "j|x@corp.com"}, "git-usr", nil)
+	if lines[0] != "👤 ⚠️ j¦x@corp.com" {
+		t.Errorf("Expected a warning with the unknown email, got %q", lines[0])
+	}
+}
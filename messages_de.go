@@ -0,0 +1,150 @@
+package main
+
+// germanMessages is the German message catalog
+var germanMessages = messageCatalog{
+	// Help
+	"🔧 Git User Profile Switcher":      "🔧 Git-Benutzerprofile wechseln",
+	"Usage:":                           "Verwendung:",
+	"Global flags:":                    "Globale Optionen:",
+	"Examples:":                        "Beispiele:",
+	"Config location: %s":              "Konfigurationsdatei: %s",
+	"Switch to profile (local scope)":  "Zum Profil wechseln (lokal)",
+	"Switch to profile (global scope)": "Zum Profil wechseln (global)",
+	"List profiles as a table":         "Profile als Tabelle auflisten",
+	"List the profiles whose name, email, tags or description contain text":             "Profile auflisten, deren Name, E-Mail, Tags oder Beschreibung den Text enthalten",
+	"Set up your profiles step by step":                                                 "Profile Schritt für Schritt einrichten",
+	"Set the global identity from the environment, e.g. in a devcontainer":              "Globale Identität aus der Umgebung setzen, z. B. in einem Devcontainer",
+	"Add/update a profile (interactive)":                                                "Profil anlegen/ändern (interaktiv)",
+	"Remove every profile":                                                              "Alle Profile entfernen",
+	"Don't ask for confirmation (also -y)":                                              "Nicht nach Bestätigung fragen (auch -y)",
+	"Remove profiles and retract their git config":                                      "Profile entfernen und ihre git-Konfiguration zurücknehmen",
+	"Add or update several profiles":                                                    "Mehrere Profile hinzufügen oder aktualisieren",
+	"Merge profiles that share an email":                                                "Profile mit derselben E-Mail zusammenführen",
+	"Show current git config":                                                           "Aktuelle git-Konfiguration anzeigen",
+	"Print a single raw value":                                                          "Einen einzelnen Wert ausgeben",
+	"Print the current profile for shell prompts":                                       "Aktuelles Profil für den Shell-Prompt ausgeben",
+	"Summarize identity switches when the shell exits":                                  "Identitätswechsel beim Beenden der Shell zusammenfassen",
+	"Warn when the identity isn't the one expected here (for cd hooks)":                 "Warnen, wenn hier eine andere Identität erwartet wird (für cd-Hooks)",
+	"Fail when the next commit breaks the repository's policy or your rules":            "Fehlschlagen, wenn der nächste Commit gegen die Richtlinie des Repositorys oder deine Regeln verstößt",
+	"Exit 0 ok, 3 mismatch, 4 no identity, 5 not a repo, 6 unsafe, 10 locked":           "Exit 0 ok, 3 falsch, 4 keine Identität, 5 kein Repository, 6 unsicher, 10 gesperrt",
+	"Show or change profile fields":                                                     "Profilfelder anzeigen oder ändern",
+	"Clone with a profile applied":                                                      "Mit einem Profil klonen",
+	"Show or change settings":                                                           "Einstellungen anzeigen oder ändern",
+	"Compare or align the WSL and Windows global identities":                            "Globale Identitäten von WSL und Windows vergleichen oder angleichen",
+	"Show or set the default profile":                                                   "Standardprofil anzeigen oder festlegen",
+	"Switch this repository and its submodules to a profile":                            "Dieses Repository und seine Submodule auf ein Profil umstellen",
+	"Pin this repository to a profile and warn when its identity drifts":                "Dieses Repository an ein Profil binden und bei abweichender Identität warnen",
+	"Switch to the profile rules, pin or remote pick for this repository":               "Zum Profil wechseln, das Regeln, Bindung oder Remote für dieses Repository vorgeben",
+	"Require a profile or email domain by remote, directory or branch":                  "Profil oder E-Mail-Domain nach Remote, Verzeichnis oder Branch vorschreiben",
+	"Show which rule applies and if the identity satisfies it":                          "Anzeigen, welche Regel gilt und ob die Identität sie erfüllt",
+	"Apply the default profile to this repository":                                      "Standardprofil auf dieses Repository anwenden",
+	"Apply the default profile to new clones":                                           "Standardprofil auf neue Klone anwenden",
+	"Check the identity on every branch change":                                         "Identität bei jedem Branch-Wechsel prüfen",
+	"Install all hooks, keeping and chaining existing ones":                             "Alle Hooks installieren, vorhandene behalten und verketten",
+	"Show which git-usr hooks run here":                                                 "Anzeigen, welche git-usr-Hooks hier laufen",
+	"Run a script after every switch (--remove to stop)":                                "Nach jedem Wechsel ein Skript ausführen (--remove beendet das)",
+	"List the plugins run on add, remove and switch":                                    "Die Plugins auflisten, die bei add, remove und switch laufen",
+	"Apply profiles to new clones in a directory":                                       "Profile auf neue Klone in einem Verzeichnis anwenden",
+	"Run the watcher in the background, show its log":                                   "Überwachung im Hintergrund ausführen, Protokoll anzeigen",
+	"Print export statements for a profile":                                             "export-Anweisungen für ein Profil ausgeben",
+	"Manage profile variables":                                                          "Profilvariablen verwalten",
+	"Run a command with a profile's environment":                                        "Befehl mit der Umgebung eines Profils ausführen",
+	"Export a profile's identity from this directory's .envrc":                          "Identität eines Profils aus der .envrc dieses Verzeichnisses exportieren",
+	"Add Co-authored-by trailers for teammates":                                         "Co-authored-by-Trailer für Teammitglieder hinzufügen",
+	"Manage teammates to pair with who aren't profiles":                                 "Teammitglieder ohne eigenes Profil verwalten",
+	"Push to the current profile's push remote":                                         "Zum Push-Remote des aktuellen Profils pushen",
+	"Set up a signing key for a profile":                                                "Signaturschlüssel für ein Profil einrichten",
+	"Trust all profiles' SSH keys in allowed_signers":                                   "SSH-Schlüssel aller Profile in allowed_signers eintragen",
+	"Map all your emails to one identity in .mailmap":                                   "Alle deine E-Mails in .mailmap einer Identität zuordnen",
+	"Check the email is verified on your forge account":                                 "Prüfen, ob die E-Mail im Forge-Konto bestätigt ist",
+	"Answer JSON API requests for editors and prompts":                                  "JSON-API-Anfragen für Editoren und Prompts beantworten",
+	"Answer JSON API requests on a socket, for GUI apps":                                "JSON-API-Anfragen über einen Socket beantworten, für GUI-Apps",
+	"Print an xbar/SwiftBar menu to see and switch profiles":                            "Ein xbar/SwiftBar-Menü ausgeben, um Profile zu sehen und zu wechseln",
+	"Flag placeholder, duplicate and unused profiles":                                   "Platzhalter, doppelte und ungenutzte Profile melden",
+	"Archive or delete unused profiles (--archived, --restore <profile>, --scan <dir>)": "Ungenutzte Profile archivieren oder löschen (--archived, --restore <profil>, --scan <verz>)",
+	"Check that the email's domain receives mail":                                       "Prüfen, ob die Domain der E-Mail-Adresse Mail empfängt",
+	"Save the identity git uses now as a profile":                                       "Die aktuell von Git verwendete Identität als Profil speichern",
+	"Add or update profiles from JSON or YAML":                                          "Profile aus JSON oder YAML hinzufügen oder aktualisieren",
+	"Compare the global and local identity and their profiles":                          "Globale und lokale Identität und ihre Profile vergleichen",
+	"Switch to profile for this repository, whatever its scope":                         "Für dieses Repository zum Profil wechseln, unabhängig von seinem Bereich",
+	"Don't accept a prefix or typo of the name":                                         "Keinen Anfang oder Tippfehler des Namens akzeptieren",
+	"Show how often and where profiles are switched to":                                 "Anzeigen, wie oft und wo Profile verwendet werden",
+	"Check signing certificates and the tools profiles rely on":                         "Signaturzertifikate und benötigte Werkzeuge prüfen",
+	"Fail unless the next commit is made as expected":                                   "Fehlschlagen, wenn der nächste Commit nicht wie erwartet erstellt wird",
+	"Create a signed tag as a profile":                                                  "Signierten Tag als Profil erstellen",
+	"Write an HTML report of profile usage and identity problems":                       "HTML-Bericht über Profilnutzung und Identitätsprobleme schreiben",
+	"Create profiles from a CSV file":                                                   "Profile aus einer CSV-Datei anlegen",
+	"Import signed team profiles (see README)":                                          "Signierte Teamprofile importieren (siehe README)",
+	"Show git config values written by git-usr":                                         "Von git-usr geschriebene git-Konfiguration anzeigen",
+	"Salvage a config file that fails to parse":                                         "Nicht lesbare Konfigurationsdatei retten",
+	"List or restore the backups kept on every change":                                  "Bei jeder Änderung angelegte Sicherungen auflisten oder wiederherstellen",
+	"Encrypt the config file with a passphrase":                                         "Konfigurationsdatei mit einer Passphrase verschlüsseln",
+	"Decrypt the config file":                                                           "Konfigurationsdatei entschlüsseln",
+	"Generate completion script":                                                        "Vervollständigungsskript erzeugen",
+	"Install completion and load it from your shell rc file":                            "Vervollständigung installieren und in der Shell-rc-Datei laden",
+	"Show version information":                                                          "Versionsinformationen anzeigen",
+	"Show this help":                                                                    "Diese Hilfe anzeigen",
+	"Run as if started in <path>, like git -C":                                          "So ausführen, als wäre es in <path> gestartet, wie git -C",
+	"Use an alternate profiles file (or set GIT_USR_CONFIG)":                            "Andere Profildatei verwenden (oder GIT_USR_CONFIG setzen)",
+	"Print without emoji or color (or set GIT_USR_NO_EMOJI)":                            "Ohne Emoji und Farbe ausgeben (oder GIT_USR_NO_EMOJI setzen)",
+	"Keep the config next to the executable (or set GIT_USR_PORTABLE)":                  "Konfiguration neben der ausführbaren Datei ablegen (oder GIT_USR_PORTABLE setzen)",
+	"Start a new config without example profiles (or set GIT_USR_NO_SEED)":              "Neue Konfiguration ohne Beispielprofile anlegen (oder GIT_USR_NO_SEED setzen)",
+	"Print nothing but errors, to stderr":                                               "Nur Fehler ausgeben, auf stderr",
+	"Switch to work profile (local)":                                                    "Zum Profil work wechseln (lokal)",
+	"Switch to personal profile (global)":                                               "Zum Profil personal wechseln (global)",
+	"List all available profiles":                                                       "Alle verfügbaren Profile auflisten",
+
+	// Status messages
+	"Available profiles:":    "Verfügbare Profile:",
+	"PROFILE":                "PROFIL",
+	"NAME":                   "NAME",
+	"EMAIL":                  "E-MAIL",
+	"TAGS":                   "TAGS",
+	"DESCRIPTION":            "BESCHREIBUNG",
+	"No profiles tagged %s":  "Keine Profile mit Tag %s",
+	"No profiles match '%s'": "Keine Profile passen zu '%s'",
+	"No profiles yet. Use 'git usr add' to create one": "Noch keine Profile. Lege mit 'git usr add' eines an",
+	"Profile '%s' not found!":                          "Profil '%s' nicht gefunden!",
+	"Use 'git usr add' to create a new profile":        "Lege mit 'git usr add' ein neues Profil an",
+	"Switched to '%s' profile for this repository":     "Für dieses Repository zum Profil '%s' gewechselt",
+	"Switched to '%s' profile globally":                "Global zum Profil '%s' gewechselt",
+	"Name:  %s":                                        "Name:   %s",
+	"Email: %s":                                        "E-Mail: %s",
+	"Profile '%s' already exists:":                     "Profil '%s' existiert bereits:",
+	"To update, provide both name and email.":          "Gib zum Ändern Name und E-Mail an.",
+	"Enter name: ":                                     "Name eingeben: ",
+	"Enter email: ":                                    "E-Mail eingeben: ",
+	"Profile '%s' saved!":                              "Profil '%s' gespeichert!",
+	"Use: git usr %s":                                  "Verwenden: git usr %s",
+	"Profile '%s' removed!":                            "Profil '%s' entfernt!",
+	"Current git configuration:":                       "Aktuelle git-Konfiguration:",
+	"No git configuration found in this repository":    "Keine git-Konfiguration in diesem Repository gefunden",
+	"Profile name required!":                           "Profilname erforderlich!",
+	"Search text required!":                            "Suchtext erforderlich!",
+	"--global and --local can't be used together":      "--global und --local können nicht zusammen verwendet werden",
+	"Can't name a profile '%s': %s":                    "Ein Profil kann nicht '%s' heißen: %s",
+	"it would be taken for a flag":                     "es würde als Option verstanden",
+	"it is a git usr command":                          "es ist ein git-usr-Befehl",
+	"Pick another name, or add --force if you only use it with other commands": "Wähle einen anderen Namen oder gib --force an, wenn du es nur mit anderen Befehlen verwendest",
+	"'%s' matches several profiles: %s":                                        "'%s' passt zu mehreren Profilen: %s",
+	"Did you mean '%s'? [Y/n] ":                                                "Meintest du '%s'? [J/n] ",
+	"Did you mean '%s'?":                                                       "Meintest du '%s'?",
+	"Signing key: %s":                                                          "Signaturschlüssel: %s",
+	"Save profile '%s'? [Y/n] ":                                                "Profil '%s' speichern? [J/n] ",
+	"Remove profile '%s'? [y/N] ":                                              "Profil '%s' entfernen? [j/N] ",
+	"Remove all %d profiles? [y/N] ":                                           "Alle %d Profile entfernen? [j/N] ",
+	"Remove %d profiles? [y/N] ":                                               "%d Profile entfernen? [j/N] ",
+	"Refusing to remove all profiles without a terminal to confirm on; add --force": "Ohne Terminal zur Bestätigung werden nicht alle Profile entfernt; --force angeben",
+	"y":   "j",
+	"yes": "ja",
+	"A new release of git-usr is available: %s → %s (%s)":            "Eine neue Version von git-usr ist verfügbar: %s → %s (%s)",
+	"Could not switch to '%s': %v":                                   "Wechsel zu '%s' fehlgeschlagen: %v",
+	"The identity may now be mixed; check it with 'git usr current'": "Die Identität ist jetzt womöglich gemischt; prüfe sie mit 'git usr current'",
+	"(local)":                 "(lokal)",
+	"(global)":                "(global)",
+	"(both)":                  "(beide)",
+	"Nothing changed":         "Nichts geändert",
+	"Name is only whitespace": "Der Name besteht nur aus Leerzeichen",
+	"Name had extra whitespace, saved as '%s'":    "Überzählige Leerzeichen im Namen entfernt, gespeichert als '%s'",
+	"Leave out --verify-domain to save it anyway": "Ohne --verify-domain wird es trotzdem gespeichert",
+}
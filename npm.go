@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// shouldSyncNpm reports whether a switch in scope should update npm config
+func shouldSyncNpm(settings *Settings, scope string) bool {
+	switch settings.NpmSync {
+	case "always":
+		return true
+	case "global":
+		return scope == "global"
+	default:
+		return false
+	}
+}
+
+// syncNpmAuthor writes the profile's name and email into the user-level
+// npm (and yarn classic) init-author config used to scaffold package.json
+func syncNpmAuthor(profile Profile) error {
+	if _, err := exec.LookPath("npm"); err != nil {
+		return fmt.Errorf("npm not found in PATH")
+	}
+
+	values := [][2]string{
+		{"init-author-name", profile.Name},
+		{"init-author-email", profile.Email},
+	}
+	for _, v := range values {
+		if out, err := exec.Command("npm", "config", "set", v[0], v[1]).CombinedOutput(); err != nil {
+			return fmt.Errorf("npm config set %s: %s", v[0], strings.TrimSpace(string(out)))
+		}
+	}
+
+	// Yarn berry reads npm's config; only yarn classic keeps its own
+	if out, err := exec.Command("yarn", "--version").Output(); err == nil && strings.HasPrefix(string(out), "1.") {
+		for _, v := range values {
+			if out, err := exec.Command("yarn", "config", "set", v[0], v[1]).CombinedOutput(); err != nil {
+				return fmt.Errorf("yarn config set %s: %s", v[0], strings.TrimSpace(string(out)))
+			}
+		}
+	}
+
+	return nil
+}
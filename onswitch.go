@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// onSwitchGuardVar is set for on-switch scripts, so a script that switches
+// profiles itself doesn't run the scripts again
+const onSwitchGuardVar = "GIT_USR_IN_ON_SWITCH"
+
+// onSwitchEnv returns the variables an on-switch script gets about the
+// switch. Repo is "" for global switches
+func onSwitchEnv(profileName string, profile Profile, scope, repo string) []string {
+	return []string{
+		"GIT_USR_PROFILE=" + profileName,
+		"GIT_USR_NAME=" + profile.Name,
+		"GIT_USR_EMAIL=" + profile.Email,
+		"GIT_USR_SCOPE=" + scope,
+		"GIT_USR_REPO=" + repo,
+		"GIT_USR_SIGNING_KEY=" + profile.SigningKey,
+		"GIT_USR_TAGS=" + strings.Join(profile.Tags, ","),
+		onSwitchGuardVar + "=1",
+	}
+}
+
+// runOnSwitchScripts runs the on-switch scripts after a switch, in the
+// order they were added. A failing script is reported and doesn't undo
+// the switch or stop the others
+func runOnSwitchScripts(scripts []string, profileName string, profile Profile, scope, repo string) {
+	if len(scripts) == 0 || os.Getenv(onSwitchGuardVar) != "" {
+		return
+	}
+	env := append(os.Environ(), onSwitchEnv(profileName, profile, scope, repo)...)
+	for _, script := range scripts {
+		path, err := expandHome(script)
+		if err != nil {
+			fmt.Printf("⚠️  on-switch script %s: %v\n", script, err)
+			continue
+		}
+		cmd := exec.Command(path)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("⚠️  on-switch script %s failed: %v\n", script, err)
+		}
+	}
+}
+
+// onSwitchScriptPath returns the path an on-switch script is stored as:
+// absolute, unless it is below the home directory as ~/...
+func onSwitchScriptPath(script string) (string, error) {
+	if strings.HasPrefix(script, "~/") {
+		return script, nil
+	}
+	return filepath.Abs(script)
+}
+
+// updateOnSwitchScripts adds or removes an on-switch script
+func updateOnSwitchScripts(script string, remove bool) error {
+	script, err := onSwitchScriptPath(script)
+	if err != nil {
+		return err
+	}
+	if !remove {
+		path, err := expandHome(script)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			fmt.Printf("❌ %s is not a file\n", script)
+			return fmt.Errorf("not a file: %s", script)
+		}
+		if info.Mode()&0111 == 0 && runtime.GOOS != "windows" {
+			fmt.Printf("⚠️  %s isn't executable; run chmod +x on it\n", script)
+		}
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if remove {
+		if !containsString(config.OnSwitch, script) {
+			fmt.Printf("❌ %s isn't an on-switch script\n", script)
+			return fmt.Errorf("no on-switch script %s", script)
+		}
+		var kept []string
+		for _, existing := range config.OnSwitch {
+			if existing != script {
+				kept = append(kept, existing)
+			}
+		}
+		config.OnSwitch = kept
+	} else if !containsString(config.OnSwitch, script) {
+		config.OnSwitch = append(config.OnSwitch, script)
+	}
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	if remove {
+		fmt.Printf("✅ %s no longer runs on switch\n", script)
+	} else {
+		fmt.Printf("✅ %s runs after every switch\n", script)
+		fmt.Println("   It gets GIT_USR_PROFILE, GIT_USR_NAME, GIT_USR_EMAIL, GIT_USR_SCOPE, GIT_USR_REPO,")
+		fmt.Println("   GIT_USR_SIGNING_KEY and GIT_USR_TAGS")
+	}
+	return nil
+}
+
+// runOnSwitch handles `git usr hooks on-switch`
+func runOnSwitch(args []string) error {
+	usage := "Usage: git usr hooks on-switch [<script> | --remove <script>]"
+	switch {
+	case len(args) == 0:
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if len(config.OnSwitch) == 0 {
+			fmt.Println("No on-switch scripts")
+			fmt.Println("\nUse: git usr hooks on-switch <script>")
+			return nil
+		}
+		fmt.Println("🪝 Run after every switch:")
+		for _, script := range config.OnSwitch {
+			fmt.Printf("   %s\n", script)
+		}
+		return nil
+	case len(args) == 1 && !strings.HasPrefix(args[0], "-"):
+		return updateOnSwitchScripts(args[0], false)
+	case len(args) == 2 && args[0] == "--remove":
+		return updateOnSwitchScripts(args[1], true)
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("invalid on-switch command")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestRunOnSwitchScripts tests passing the profile to on-switch scripts
+// and carrying on after a failing one
+func TestRunOnSwitchScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test scripts are shell scripts")
+	}
+	t.Setenv(onSwitchGuardVar, "")
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	failing := filepath.Join(dir, "failing.sh")
+	recording := filepath.Join(dir, "recording.sh")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$GIT_USR_PROFILE|$GIT_USR_EMAIL|$GIT_USR_SCOPE|$GIT_USR_REPO|$GIT_USR_TAGS\" >> " + shellQuote(out) + "\n"
+	if err := os.WriteFile(recording, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := Profile{Name: "Jane Doe", Email: "jane@acme.io", Tags: []string{"work", "oss"}}
+	runOnSwitchScripts([]string{failing, recording}, "work", profile, "global", "")
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("the script after the failing one didn't run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "work|jane@acme.io|global||work,oss" {
+		t.Errorf("the script got %q", got)
+	}
+
+	// Switches from within a script don't run the scripts again
+	t.Setenv(onSwitchGuardVar, "1")
+	runOnSwitchScripts([]string{recording}, "work", profile, "global", "")
+	if data, _ := os.ReadFile(out); strings.Count(string(data), "\n") != 1 {
+		t.Errorf("the scripts ran from within an on-switch script:\n%s", data)
+	}
+}
+
+// TestOnSwitchScriptPath tests storing scripts independent of the current
+// directory
+func TestOnSwitchScriptPath(t *testing.T) {
+	if got, err := onSwitchScriptPath("~/bin/switched.sh"); err != nil || got != "~/bin/switched.sh" {
+		t.Errorf("onSwitchScriptPath(~/bin/switched.sh) = %q, %v", got, err)
+	}
+	got, err := onSwitchScriptPath("switched.sh")
+	if err != nil || !filepath.IsAbs(got) || filepath.Base(got) != "switched.sh" {
+		t.Errorf("onSwitchScriptPath(switched.sh) = %q, %v, expected an absolute path", got, err)
+	}
+}
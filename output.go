@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI escapes used when color is enabled
+const (
+	ansiBold  = "\x1b[1m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// plainOutput is set by --plain, GIT_USR_NO_EMOJI or the emoji setting:
+// output is written without emoji or color, for CI logs and terminals that
+// can't render them
+var plainOutput bool
+
+// terminalStdout and terminalStderr are the process's real stdout and
+// stderr, which plain output filters into
+var (
+	terminalStdout = os.Stdout
+	terminalStderr = os.Stderr
+)
+
+// attachTerminal hands the terminal to a command such as git or an editor,
+// past plain output, so it still sees a TTY for pagers, colors and prompts
+func attachTerminal(cmd *exec.Cmd) {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, terminalStdout, terminalStderr
+}
+
+// useColor reports whether to colorize output according to the color
+// setting. auto colors a terminal, unless NO_COLOR is set or TERM is dumb
+func useColor(settings Settings) bool {
+	if plainOutput {
+		return false
+	}
+	switch settings.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := terminalStdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in an ANSI color when enabled
+func colorize(enabled bool, color, s string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// isWideRune reports whether r takes two terminal columns: CJK, Hangul,
+// fullwidth forms and emoji
+func isWideRune(r rune) bool {
+	switch {
+	case r >= 0x1100 && r <= 0x115F, // Hangul Jamo
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F, // CJK ... Yi
+		r >= 0xAC00 && r <= 0xD7A3,                // Hangul syllables
+		r >= 0xF900 && r <= 0xFAFF,                // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F,                // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60,                // Fullwidth forms
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1FAFF, // Emoji
+		r >= 0x20000 && r <= 0x3FFFD: // CJK extensions
+		return true
+	}
+	return false
+}
+
+// displayWidth returns how many terminal columns s takes
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) || r == 0xFE0F:
+		case isWideRune(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// formatTable aligns rows into columns two spaces apart. The last column
+// isn't padded, so lines carry no trailing spaces
+func formatTable(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := displayWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-displayWidth(cell)+2))
+			}
+		}
+		// Empty trailing cells leave no padding behind
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+	}
+	return lines
+}
+
+// plainReplacements are the glyphs that carry meaning, spelled out in plain
+// output. The pointing hand keeps its two columns so tables stay aligned
+var plainReplacements = map[rune]string{
+	'❌': "Error:",
+	'⚠': "Warning:",
+	'✗': "x",
+	'→': "->",
+	'👉': "* ",
+}
+
+// isEmoji reports whether r is an emoji or pictograph dropped from plain
+// output, including the variation selector that asks for emoji style
+func isEmoji(r rune) bool {
+	return r >= 0x1F000 && r <= 0x1FAFF ||
+		r >= 0x2300 && r <= 0x23FF ||
+		r >= 0x2600 && r <= 0x27BF ||
+		r >= 0x2B00 && r <= 0x2BFF ||
+		r == 0x2139 || r == 0xFE0F
+}
+
+// plainWriter rewrites the text written through it for plain output:
+// replaced glyphs become words followed by at most one space, and other
+// emoji are dropped together with the spaces after them
+type plainWriter struct {
+	w io.Writer
+	// partial is the start of a UTF-8 sequence split across writes
+	partial []byte
+	// spaces is how many more spaces after a glyph are kept: one after a
+	// replacement, none after a dropped emoji, or -1 for all of them
+	spaces int
+}
+
+func (p *plainWriter) Write(data []byte) (int, error) {
+	buf := append(p.partial, data...)
+	p.partial = nil
+
+	var out strings.Builder
+	for len(buf) > 0 {
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size == 1 && !utf8.FullRune(buf) {
+			p.partial = append([]byte{}, buf...)
+			break
+		}
+		buf = buf[size:]
+
+		switch replacement, replaced := plainReplacements[r]; {
+		case replaced:
+			out.WriteString(replacement)
+			p.spaces = 1
+		case r == 0xFE0F:
+			// The emoji style selector of the glyph before it
+		case isEmoji(r):
+			p.spaces = 0
+		case r == ' ' && p.spaces >= 0:
+			if p.spaces > 0 {
+				out.WriteRune(r)
+				p.spaces--
+			}
+		default:
+			p.spaces = -1
+			out.WriteRune(r)
+		}
+	}
+
+	if _, err := io.WriteString(p.w, out.String()); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// emojiDisabled reports whether plain output was asked for through the
+// environment or the emoji setting. The config file is read directly, so
+// this neither seeds profiles nor asks for the passphrase of a locked one
+func emojiDisabled() bool {
+	if os.Getenv("GIT_USR_NO_EMOJI") != "" {
+		return true
+	}
+	settings, ok := fileSettings()
+	return ok && settings.Emoji != nil && !*settings.Emoji
+}
+
+// fileSettings reads the settings straight from the config file, for
+// checks made before every command that must not ask for the passphrase
+// of an encrypted config or reach a remote store. ok is false when they
+// can't be read
+func fileSettings() (Settings, bool) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return Settings{}, false
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return Settings{}, false
+	}
+	var config struct {
+		Settings Settings `json:"settings"`
+	}
+	if json.Unmarshal(data, &config) != nil {
+		return Settings{}, false
+	}
+	return config.Settings, true
+}
+
+// startPlainOutput routes stdout and stderr, including the output of
+// commands git-usr runs, through a plainWriter. The returned function
+// waits for everything written to reach the terminal
+func startPlainOutput() (func(), error) {
+	var writers []*os.File
+	var done []chan struct{}
+	for _, target := range []**os.File{&os.Stdout, &os.Stderr} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		terminal := *target
+		finished := make(chan struct{})
+		go func() {
+			io.Copy(&plainWriter{w: terminal, spaces: -1}, r)
+			close(finished)
+		}()
+		*target = w
+		writers = append(writers, w)
+		done = append(done, finished)
+	}
+
+	return func() {
+		for i, w := range writers {
+			w.Close()
+			<-done[i]
+		}
+	}, nil
+}
+
+// quietOutput is set by -q/--quiet: only errors are printed, to stderr, so
+// a script sees nothing but the exit code when a command succeeds
+var quietOutput bool
+
+// ownQuietCommands give -q/--quiet a meaning of their own
+var ownQuietCommands = map[string]bool{
+	"list": true,
+	"find": true,
+	"init": true,
+}
+
+// quietWriter passes on only the lines of error messages: those starting
+// with ❌ or a usage, and the indented lines after them
+type quietWriter struct {
+	w       io.Writer
+	line    []byte
+	inError bool
+}
+
+func (q *quietWriter) Write(data []byte) (int, error) {
+	q.line = append(q.line, data...)
+	for {
+		end := strings.IndexByte(string(q.line), '\n')
+		if end < 0 {
+			return len(data), nil
+		}
+		if err := q.writeLine(q.line[:end+1]); err != nil {
+			return 0, err
+		}
+		q.line = q.line[end+1:]
+	}
+}
+
+// writeLine writes line when it belongs to an error message
+func (q *quietWriter) writeLine(line []byte) error {
+	text := strings.TrimLeft(string(line), " \t")
+	switch {
+	case strings.HasPrefix(text, "❌") || strings.HasPrefix(text, "Usage:") || strings.HasPrefix(text, tr("Usage:")):
+		q.inError = true
+	case q.inError && text != string(line) && strings.TrimSpace(text) != "":
+		// Indented lines explain the error before them
+	default:
+		q.inError = false
+		return nil
+	}
+	_, err := q.w.Write(line)
+	return err
+}
+
+// Close writes a last line left without a newline
+func (q *quietWriter) Close() error {
+	if len(q.line) == 0 {
+		return nil
+	}
+	line := q.line
+	q.line = nil
+	return q.writeLine(line)
+}
+
+// startQuietOutput sends stdout through a quietWriter into stderr. The
+// returned function flushes it
+func startQuietOutput() (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	quiet := &quietWriter{w: os.Stderr}
+	finished := make(chan struct{})
+	go func() {
+		io.Copy(quiet, r)
+		quiet.Close()
+		close(finished)
+	}()
+	os.Stdout = w
+
+	return func() {
+		w.Close()
+		<-finished
+	}, nil
+}
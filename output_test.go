@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDisplayWidth tests counting terminal columns
+func TestDisplayWidth(t *testing.T) {
+	tests := map[string]int{
+		"work":      4,
+		"Zo\u00eb":  3,
+		"Zoe\u0308": 3,
+		"山田 太郎":     9,
+		"👉":         2,
+		"ｆｕｌｌ":      8,
+		"a\u200bb":  2,
+		"Ångström":  8,
+	}
+	for s, expected := range tests {
+		if got := displayWidth(s); got != expected {
+			t.Errorf("displayWidth(%q) = %d, expected %d", s, got, expected)
+		}
+	}
+}
+
+// TestFormatTable tests aligning columns by display width
+func TestFormatTable(t *testing.T) {
+	lines := formatTable([][]string{
+		{"PROFILE", "EMAIL"},
+		{"日本", "taro@example.jp"},
+		{"work", "you@work.com"},
+	})
+	expected := []string{
+		"PROFILE  EMAIL",
+		"日本     taro@example.jp",
+		"work     you@work.com",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d = %q, expected %q", i, lines[i], expected[i])
+		}
+	}
+}
+
+// TestPlainWriter tests rewriting output for plain terminals
+func TestPlainWriter(t *testing.T) {
+	tests := map[string]string{
+		"✅ Switched to 'work'\n":              "Switched to 'work'\n",
+		"❌ Profile 'x' not found!\n":          "Error: Profile 'x' not found!\n",
+		"⚠️  Could not retract\n":             "Warning: Could not retract\n",
+		"👉 work      Jane\n   personal  Jane": "*  work      Jane\n   personal  Jane",
+		"work → personal":                     "work -> personal",
+		"   Name:  Zoë 山田":                    "   Name:  Zoë 山田",
+	}
+	for input, expected := range tests {
+		var out bytes.Buffer
+		w := &plainWriter{w: &out, spaces: -1}
+		// Byte by byte, so runes are split across writes
+		for i := 0; i < len(input); i++ {
+			w.Write([]byte{input[i]})
+		}
+		if out.String() != expected {
+			t.Errorf("plain %q = %q, expected %q", input, out.String(), expected)
+		}
+	}
+}
+
+// TestQuietWriter tests keeping only the lines of error messages
+func TestQuietWriter(t *testing.T) {
+	input := "✅ Switched to 'work'\n   Name: Jane\n❌ Profile 'x' not found!\n   Run 'git usr list'\n\nDone\nUsage: git usr setup"
+	expected := "❌ Profile 'x' not found!\n   Run 'git usr list'\nUsage: git usr setup"
+
+	var out bytes.Buffer
+	w := &quietWriter{w: &out}
+	for i := 0; i < len(input); i += 7 {
+		w.Write([]byte(input[i:min(i+7, len(input))]))
+	}
+	w.Close()
+	if out.String() != expected {
+		t.Errorf("quiet %q = %q, expected %q", input, out.String(), expected)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pairConfigKey is the local git config key listing the paired profiles
+const pairConfigKey = "usr.pair"
+
+// getPair returns the profiles paired in the current repository
+func getPair() []string {
+	values, err := gitConfig.GetAll("", "local", pairConfigKey)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(strings.Join(values, " "))
+}
+
+// coAuthorTrailers returns the Co-authored-by trailers for the paired
+// profiles and co-authors, leaving out whoever is committing. Names
+// removed since pairing are skipped
+func coAuthorTrailers(profiles map[string]Profile, pair []string, name, email string) []string {
+	var trailers []string
+	seen := map[string]bool{}
+	for _, profileName := range pair {
+		profile, exists := profiles[profileName]
+		if !exists || identityMatches(profile, name, email) || seen[strings.ToLower(profile.Email)] {
+			continue
+		}
+		seen[strings.ToLower(profile.Email)] = true
+		trailers = append(trailers, "Co-authored-by: "+formatAddress(profile.Name, profile.Email))
+	}
+	return trailers
+}
+
+// pairTrailers returns the Co-authored-by trailers of the pair in the
+// current repository for a commit made as name and email
+func pairTrailers(name, email string) ([]string, error) {
+	pair := getPair()
+	if len(pair) == 0 {
+		return nil, nil
+	}
+
+	profiles, err := loadPairRoster()
+	if err != nil {
+		return nil, err
+	}
+	return coAuthorTrailers(profiles, pair, name, email), nil
+}
+
+// startPair records the paired profiles and co-authors and installs the
+// hook
+func startPair(profileNames []string) error {
+	profiles, err := loadPairRoster()
+	if err != nil {
+		return err
+	}
+	for _, profileName := range profileNames {
+		if _, exists := profiles[profileName]; !exists {
+			fmt.Printf("❌ No profile or co-author named '%s'!\n", profileName)
+			fmt.Println("\nAvailable:", getProfileNames(profiles))
+			return errProfileNotFound
+		}
+	}
+
+	hooksDir, err := installHooks([]string{"prepare-commit-msg"})
+	if err != nil {
+		return err
+	}
+	if hooksDir == "" {
+		hooksDir = globalHooksPath()
+	}
+
+	if err := unsetGitConfigValues(pairConfigKey); err != nil {
+		return err
+	}
+	for _, profileName := range profileNames {
+		if err := gitConfig.Add("", "local", pairConfigKey, profileName); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("👥 Pairing with %s\n", strings.Join(profileNames, ", "))
+	name, email, _ := getCurrentGitConfig()
+	for _, trailer := range coAuthorTrailers(profiles, profileNames, name, email) {
+		fmt.Printf("   %s\n", trailer)
+	}
+	fmt.Printf("   Trailers are added by the prepare-commit-msg hook in %s\n", hooksDir)
+	fmt.Println("   The profile you commit as is left out; stop with 'git usr pair --stop'")
+	return nil
+}
+
+// unsetGitConfigValues removes every value of a local key, if any
+func unsetGitConfigValues(key string) error {
+	return gitConfig.Unset("", "local", key, "")
+}
+
+// stopPair forgets the pair and removes the hook
+func stopPair() error {
+	if err := unsetGitConfigValues(pairConfigKey); err != nil {
+		return err
+	}
+	// The hook stays for the trailers of the profile committing here
+	if len(configuredTrailers()) == 0 {
+		if _, _, err := uninstallHooks([]string{"prepare-commit-msg"}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("✅ Stopped pairing; commits no longer get Co-authored-by trailers")
+	return nil
+}
+
+// runPair handles the pair command
+func runPair(args []string) error {
+	inside, err := isInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		fmt.Println("❌ Not inside a git repository")
+		return fmt.Errorf("not a git repository")
+	}
+
+	switch {
+	case len(args) == 0:
+		pair := getPair()
+		if len(pair) == 0 {
+			fmt.Println("Not pairing in this repository")
+			fmt.Println("\nUse: git usr pair <profile|coauthor>...")
+			return nil
+		}
+		fmt.Printf("👥 Pairing with %s\n", strings.Join(pair, ", "))
+		return nil
+
+	case len(args) == 1 && args[0] == "--stop":
+		return stopPair()
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			fmt.Println("Usage: git usr pair <profile|coauthor>... | --stop")
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+	return startPair(args)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCoAuthorTrailers tests that the committer and unknown profiles are
+// left out of the co-authors
+func TestCoAuthorTrailers(t *testing.T) {
+	profiles := map[string]Profile{
+		"me":    {Name: "John Doe", Email: "john@acme.io"},
+		"alice": {Name: "Alice Smith", Email: "alice@acme.io"},
+		"bob":   {Name: "Bob Jones, Jr.", Email: "bob@acme.io"},
+		"bob2":  {Name: "Bob Jones", Email: "BOB@acme.io"},
+	}
+
+	trailers := coAuthorTrailers(profiles, []string{"me", "alice", "gone", "bob", "bob2"}, "John Doe", "john@acme.io")
+	expected := []string{
+		"Co-authored-by: Alice Smith <alice@acme.io>",
+		`Co-authored-by: "Bob Jones, Jr." <bob@acme.io>`,
+	}
+	if len(trailers) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, trailers)
+	}
+	for i := range expected {
+		if trailers[i] != expected[i] {
+			t.Errorf("Trailer %d = %q, expected %q", i, trailers[i], expected[i])
+		}
+	}
+}
+
+// TestPairRoster tests that co-authors can be paired with and profiles
+// win over co-authors of the same name
+func TestPairRoster(t *testing.T) {
+	config := &Config{
+		Profiles: map[string]Profile{"alice": {Name: "Alice Smith", Email: "alice@acme.io"}},
+		Coauthors: map[string]Coauthor{
+			"alice": {Name: "Old Alice", Email: "old@acme.io"},
+			"carol": {Name: "Carol White", Email: "carol@acme.io"},
+		},
+	}
+
+	roster := pairRoster(config)
+	if roster["alice"].Email != "alice@acme.io" {
+		t.Errorf("Expected the profile to win, got %+v", roster["alice"])
+	}
+	trailers := coAuthorTrailers(roster, []string{"carol"}, "Alice Smith", "alice@acme.io")
+	if len(trailers) != 1 || trailers[0] != "Co-authored-by: Carol White <carol@acme.io>" {
+		t.Errorf("Expected a trailer for the co-author, got %v", trailers)
+	}
+}
+
+// TestRemoveUnknownCoauthor tests that removing an unknown co-author says
+// so instead of failing silently
+func TestRemoveUnknownCoauthor(t *testing.T) {
+	setupConfigHome(t)
+	if err := saveConfig(defaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = removeCoauthor("nobody") })
+	if err == nil || strings.HasPrefix(err.Error(), "❌") {
+		t.Errorf("Expected a plain error, got %v", err)
+	}
+	if !strings.Contains(out, "❌ Co-author 'nobody' not found!") {
+		t.Errorf("Expected the failure to be printed, got %q", out)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pinConfigKey is the local git config key of the profile a repository is
+// pinned to
+const pinConfigKey = "usr.pin"
+
+// pinFileName is the file at the top of the work tree that shares a pin
+// with everyone who clones the repository, see projectFileNames
+const pinFileName = ".gitusr"
+
+// plainYAMLPattern matches profile names that need no quotes in YAML
+var plainYAMLPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// pinSourceLocal is shown by `git usr pin` for pins kept in the local
+// config; tracked pins show the name of their project file
+const pinSourceLocal = "local config"
+
+// setPinFileProfile returns the contents of a project file with its
+// profile line replaced, added, or removed for an empty profile. The rest
+// of the file is kept as written
+func setPinFileProfile(data, profileName string) string {
+	line := ""
+	if profileName != "" {
+		value := profileName
+		if !plainYAMLPattern.MatchString(value) {
+			value = strconv.Quote(value)
+		}
+		line = "profile: " + value + "\n"
+	}
+
+	var b strings.Builder
+	replaced := false
+	for _, current := range strings.SplitAfter(data, "\n") {
+		key, _, ok := strings.Cut(current, ":")
+		if ok && key == "profile" {
+			if !replaced {
+				b.WriteString(line)
+			}
+			replaced = true
+			continue
+		}
+		b.WriteString(current)
+	}
+	if !replaced && line != "" {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// getPin returns the profile the repository in dir is pinned to and where
+// the pin is kept, "" when there is none. A pin in the local config
+// overrides the tracked one
+func getPin(dir string) (string, string) {
+	if profileName := gitConfigValue(dir, "local", pinConfigKey); profileName != "" {
+		return profileName, pinSourceLocal
+	}
+	policy, file, err := getProjectPolicy(dir)
+	if err != nil || policy.Profile == "" {
+		return "", ""
+	}
+	return policy.Profile, file
+}
+
+// pinDrift describes the identity in dir when it isn't the one of the
+// profile the repository is pinned to, "" when it is or there is no pin
+func pinDrift(profiles map[string]Profile, dir string) string {
+	pinned, _ := getPin(dir)
+	profile, exists := profiles[pinned]
+	if !exists {
+		return ""
+	}
+	name, email := getIdentityIn(dir)
+	if identityMatches(profile, name, email) {
+		return ""
+	}
+	if email == "" {
+		return "no identity configured"
+	}
+	return formatAddress(name, email)
+}
+
+// writePin pins the current repository to profileName, or removes the pin
+// when it is empty, in the local config or the tracked project file
+func writePin(profileName string, tracked bool) error {
+	if !tracked {
+		if profileName == "" {
+			return gitConfig.Unset("", "local", pinConfigKey, "")
+		}
+		return gitConfig.Set("", "local", pinConfigKey, profileName)
+	}
+
+	top, err := gitConfig.WorkTree("")
+	if err != nil {
+		return err
+	}
+	path := projectFilePath(top)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	updated := setPinFileProfile(string(data), profileName)
+	if strings.TrimSpace(updated) == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return writeFileAtomic(path, []byte(updated), 0644, false)
+}
+
+// showPin prints the profile the current repository is pinned to
+func showPin() error {
+	pinned, source := getPin("")
+	if pinned == "" {
+		fmt.Println("No profile pinned to this repository")
+		fmt.Println("\nUse: git usr pin <profile> [--tracked]")
+		return nil
+	}
+	fmt.Printf("📌 Pinned to '%s' (%s)\n", pinned, source)
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	if _, exists := profiles[pinned]; !exists {
+		fmt.Printf("⚠️  You have no profile named '%s' (run 'git usr add %s')\n", pinned, pinned)
+	} else if drift := pinDrift(profiles, ""); drift != "" {
+		fmt.Printf("⚠️  Drifted from '%s': %s (run 'git usr apply %s')\n", pinned, drift, pinned)
+	}
+	return nil
+}
+
+// runPin handles the pin command
+func runPin(args []string) error {
+	usage := "Usage: git usr pin [<profile>|--unset] [--tracked]"
+	profileName, unset, tracked := "", false, false
+	for _, arg := range args {
+		switch {
+		case arg == "--unset":
+			unset = true
+		case arg == "--tracked":
+			tracked = true
+		case strings.HasPrefix(arg, "-") || profileName != "":
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		default:
+			profileName = arg
+		}
+	}
+	if unset && profileName != "" {
+		fmt.Println(usage)
+		return fmt.Errorf("--unset takes no profile")
+	}
+
+	top, err := gitConfig.WorkTree("")
+	if isUnsafeRepository(err) {
+		return err
+	}
+	if err != nil {
+		fmt.Println("❌ Not inside a git repository")
+		return err
+	}
+	trackedFile := filepath.Base(projectFilePath(top))
+
+	if profileName == "" && !unset {
+		if tracked {
+			fmt.Println(usage)
+			return fmt.Errorf("--tracked requires a profile or --unset")
+		}
+		return showPin()
+	}
+
+	if unset {
+		if err := writePin("", tracked); err != nil {
+			fmt.Printf("❌ Failed to remove the pin: %v\n", err)
+			return err
+		}
+		where := pinSourceLocal
+		if tracked {
+			where = trackedFile
+		}
+		fmt.Printf("✅ Removed the pin (%s)\n", where)
+		if pinned, source := getPin(""); pinned != "" {
+			fmt.Printf("   The repository stays pinned to '%s' (%s)\n", pinned, source)
+		}
+		return nil
+	}
+
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	profile, exists := profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		fmt.Println("\nAvailable profiles:", getProfileNames(profiles))
+		return errProfileNotFound
+	}
+	if err := writePin(profileName, tracked); err != nil {
+		fmt.Printf("❌ Failed to pin: %v\n", err)
+		return err
+	}
+
+	fmt.Printf("📌 Pinned this repository to '%s' (%s)\n", profileName, formatAddress(profile.Name, profile.Email))
+	pinned, source := getPin("")
+	if tracked {
+		fmt.Printf("   Commit %s to pin it for everyone who clones the repository\n", trackedFile)
+		if source == pinSourceLocal {
+			fmt.Printf("⚠️  The pin to '%s' in your local config overrides it here (remove it with 'git usr pin --unset')\n", pinned)
+		}
+	}
+	if drift := pinDrift(profiles, ""); drift != "" {
+		fmt.Printf("⚠️  Drifted from '%s': %s (run 'git usr apply %s')\n", pinned, drift, pinned)
+	}
+	return nil
+}
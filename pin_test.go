@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSetPinFileProfile tests that only the profile line of a .gitusr
+// file changes
+func TestSetPinFileProfile(t *testing.T) {
+	tests := []struct {
+		data, profile, expected string
+	}{
+		{"", "work", "profile: work\n"},
+		{"# team policy\nprofile: home\nother: x\n", "work", "# team policy\nprofile: work\nother: x\n"},
+		{"other: x", "work", "other: x\nprofile: work\n"},
+		{"profile: home\nother: x\n", "", "other: x\n"},
+		{"nested:\n  profile: keep\n", "work", "nested:\n  profile: keep\nprofile: work\n"},
+		{"", "acme: client", "profile: \"acme: client\"\n"},
+	}
+	for _, test := range tests {
+		if got := setPinFileProfile(test.data, test.profile); got != test.expected {
+			t.Errorf("setPinFileProfile(%q, %q) = %q, expected %q", test.data, test.profile, got, test.expected)
+		}
+	}
+}
+
+// TestReadPinFile tests reading back what setPinFileProfile writes
+func TestReadPinFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), pinFileName)
+	for _, profile := range []string{"work", "acme: client", ""} {
+		if err := os.WriteFile(path, []byte(setPinFileProfile("# pinned\nother: x\n", profile)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := readProjectFile(path); err != nil || got.Profile != profile {
+			t.Errorf("readProjectFile profile = %q (%v), expected %q", got.Profile, err, profile)
+		}
+	}
+
+	os.WriteFile(path, []byte("- work\n"), 0644)
+	if _, err := readProjectFile(path); err == nil {
+		t.Error("Expected an error for a list")
+	}
+}
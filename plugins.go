@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plugins are executables in the plugins directory next to the config. On
+// every event git-usr runs each of them in name order with a pluginEvent as
+// a single JSON document on stdin. Plugins ignore events they don't know;
+// new fields may be added to the event, while changing or removing one
+// requires a new protocol version. A plugin's exit status is reported but
+// never fails the command that caused the event.
+
+// pluginProtocol is the version of the event plugins get
+const pluginProtocol = 1
+
+// The events plugins are run for
+const (
+	pluginEventAdd    = "add"
+	pluginEventRemove = "remove"
+	pluginEventSwitch = "switch"
+)
+
+// pluginExtensions are the files in the plugins directory that are plugins
+var pluginExtensions = []string{".sh", ".exe"}
+
+// pluginTimeout is how long a plugin may take for one event
+const pluginTimeout = 30 * time.Second
+
+// pluginGuardVar is set for plugins, so git-usr run by a plugin doesn't run
+// the plugins again
+const pluginGuardVar = "GIT_USR_IN_PLUGIN"
+
+// pluginEvent is what a plugin gets on stdin. Details is the profile as
+// saved for add and switch, and as it was for remove. Scope and Repo are
+// only set for switches, Repo only for local ones
+type pluginEvent struct {
+	Protocol int     `json:"protocol"`
+	Event    string  `json:"event"`
+	Profile  string  `json:"profile"`
+	Details  Profile `json:"details"`
+	Scope    string  `json:"scope,omitempty"`
+	Repo     string  `json:"repo,omitempty"`
+}
+
+// getPluginsDir returns the directory plugins are read from
+func getPluginsDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "plugins"), nil
+}
+
+// listPlugins returns the paths of the plugins in dir, sorted by name. A
+// missing directory has no plugins
+func listPlugins(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var plugins []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if containsString(pluginExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			plugins = append(plugins, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(plugins)
+	return plugins, nil
+}
+
+// pluginCommand returns the command running a plugin. Shell plugins
+// without the executable bit, and all of them on Windows, run through sh
+func pluginCommand(ctx context.Context, path string) *exec.Cmd {
+	if strings.EqualFold(filepath.Ext(path), ".sh") {
+		info, err := os.Stat(path)
+		if runtime.GOOS == "windows" || err != nil || info.Mode()&0111 == 0 {
+			return exec.CommandContext(ctx, "sh", path)
+		}
+	}
+	return exec.CommandContext(ctx, path)
+}
+
+// runPlugin runs a plugin for one event
+func runPlugin(path string, event pluginEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	cmd := pluginCommand(ctx, path)
+	cmd.Stdin = strings.NewReader(string(data) + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), pluginGuardVar+"=1")
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", pluginTimeout)
+	}
+	return err
+}
+
+// runPlugins runs every plugin for each of events. Failures are reported
+// and don't stop the other plugins
+func runPlugins(events ...pluginEvent) {
+	if len(events) == 0 || os.Getenv(pluginGuardVar) != "" {
+		return
+	}
+	dir, err := getPluginsDir()
+	if err != nil {
+		return
+	}
+	plugins, err := listPlugins(dir)
+	if err != nil {
+		fmt.Printf("⚠️  Could not read the plugins: %v\n", err)
+		return
+	}
+	for _, event := range events {
+		event.Protocol = pluginProtocol
+		for _, plugin := range plugins {
+			if err := runPlugin(plugin, event); err != nil {
+				fmt.Printf("⚠️  Plugin %s failed on %s: %v\n", filepath.Base(plugin), event.Event, err)
+			}
+		}
+	}
+}
+
+// runPluginsCommand handles the plugins command
+func runPluginsCommand(args []string) error {
+	if len(args) > 1 || len(args) == 1 && args[0] != "list" {
+		fmt.Println("Usage: git usr plugins [list]")
+		return fmt.Errorf("invalid plugins command")
+	}
+
+	dir, err := getPluginsDir()
+	if err != nil {
+		return err
+	}
+	plugins, err := listPlugins(dir)
+	if err != nil {
+		return err
+	}
+	if len(plugins) == 0 {
+		fmt.Printf("No plugins in %s\n", dir)
+		fmt.Printf("\nAdd executables ending in %s there; they get add, remove and switch events as JSON on stdin\n",
+			strings.Join(pluginExtensions, " or "))
+		return nil
+	}
+
+	fmt.Printf("🔌 Plugins in %s:\n", dir)
+	for _, plugin := range plugins {
+		note := ""
+		if info, err := os.Stat(plugin); err == nil && info.Mode()&0111 == 0 && runtime.GOOS != "windows" {
+			note = " (runs through sh)"
+			if !strings.EqualFold(filepath.Ext(plugin), ".sh") {
+				note = " (not executable; chmod +x it)"
+			}
+		}
+		fmt.Printf("   %s%s\n", filepath.Base(plugin), note)
+	}
+	return nil
+}
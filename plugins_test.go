@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// TestListPlugins tests picking the plugins out of the plugins directory
+func TestListPlugins(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.sh", "a.EXE", "notes.txt", ".hidden.sh", "c.sh.bak"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := listPlugins(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a.EXE"), filepath.Join(dir, "b.sh")}
+	if !reflect.DeepEqual(plugins, want) {
+		t.Errorf("listPlugins() = %v, expected %v", plugins, want)
+	}
+
+	if plugins, err := listPlugins(filepath.Join(dir, "missing")); err != nil || plugins != nil {
+		t.Errorf("listPlugins() of a missing directory = %v, %v", plugins, err)
+	}
+}
+
+// TestRunPlugins tests passing events to plugins as JSON on stdin
+func TestRunPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+	setupConfigHome(t)
+	t.Setenv(pluginGuardVar, "")
+	dir, err := getPluginsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "events")
+	// Without the executable bit the plugin runs through sh
+	if err := os.WriteFile(filepath.Join(dir, "record.sh"), []byte("cat >> "+shellQuote(out)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := Profile{Name: "Jane Doe", Email: "jane@acme.io"}
+	runPlugins(
+		pluginEvent{Event: pluginEventAdd, Profile: "work", Details: profile},
+		pluginEvent{Event: pluginEventSwitch, Profile: "work", Details: profile, Scope: "local", Repo: "/src/app"},
+	)
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("the plugin didn't run: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var events []pluginEvent
+	for decoder.More() {
+		var event pluginEvent
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("the plugin got invalid JSON: %v\n%s", err, data)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 {
+		t.Fatalf("the plugin got %d events, expected 2:\n%s", len(events), data)
+	}
+	if got := events[0]; got.Protocol != pluginProtocol || got.Event != "add" || got.Profile != "work" || got.Details.Email != "jane@acme.io" || got.Scope != "" {
+		t.Errorf("add event = %+v", got)
+	}
+	if got := events[1]; got.Event != "switch" || got.Scope != "local" || got.Repo != "/src/app" {
+		t.Errorf("switch event = %+v", got)
+	}
+
+	// git usr run by a plugin doesn't run the plugins again
+	t.Setenv(pluginGuardVar, "1")
+	runPlugins(pluginEvent{Event: pluginEventRemove, Profile: "work"})
+	if again, _ := os.ReadFile(out); len(again) != len(data) {
+		t.Errorf("the plugins ran from within a plugin")
+	}
+}
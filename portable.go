@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// portableBuild is set to "true" for builds that always run portable:
+//
+//	go build -ldflags "-X main.portableBuild=true"
+var portableBuild = ""
+
+// portableFlag is set by the global --portable flag
+var portableFlag bool
+
+// portableMarker is the file next to the executable that turns on
+// portable mode, e.g. on a USB stick
+const portableMarker = "portable"
+
+// portableDataDir is the directory next to the executable a portable
+// git-usr keeps its config, backups and caches in
+const portableDataDir = "git-usr-data"
+
+// executableDir returns the directory of the running executable, with
+// symlinks resolved so a linked binary finds its own files
+var executableDir = func() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe), nil
+}
+
+// portableConfigDir returns the config directory next to the executable
+// when running portable, by --portable, GIT_USR_PORTABLE, a portable
+// build or a portable marker file, and "" otherwise
+func portableConfigDir() (string, error) {
+	requested := portableFlag || os.Getenv("GIT_USR_PORTABLE") != "" || portableBuild == "true"
+	dir, err := executableDir()
+	if err != nil {
+		if requested {
+			return "", fmt.Errorf("portable mode: %w", err)
+		}
+		return "", nil
+	}
+	if !requested {
+		if _, err := os.Stat(filepath.Join(dir, portableMarker)); err != nil {
+			return "", nil
+		}
+	}
+	return filepath.Join(dir, portableDataDir), nil
+}
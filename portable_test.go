@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPortableConfigDir tests what turns portable mode on
+func TestPortableConfigDir(t *testing.T) {
+	exeDir := t.TempDir()
+	previous := executableDir
+	executableDir = func() (string, error) { return exeDir, nil }
+	t.Cleanup(func() { executableDir = previous })
+	t.Setenv("GIT_USR_PORTABLE", "")
+	want := filepath.Join(exeDir, portableDataDir)
+
+	if dir, err := portableConfigDir(); err != nil || dir != "" {
+		t.Errorf("portableConfigDir() = %q, %v; expected not portable", dir, err)
+	}
+
+	t.Setenv("GIT_USR_PORTABLE", "1")
+	if dir, _ := portableConfigDir(); dir != want {
+		t.Errorf("portableConfigDir() = %q with GIT_USR_PORTABLE, expected %q", dir, want)
+	}
+	t.Setenv("GIT_USR_PORTABLE", "")
+
+	portableFlag = true
+	dir, _ := portableConfigDir()
+	portableFlag = false
+	if dir != want {
+		t.Errorf("portableConfigDir() = %q with --portable, expected %q", dir, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(exeDir, portableMarker), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if dir, _ := portableConfigDir(); dir != want {
+		t.Errorf("portableConfigDir() = %q with a marker file, expected %q", dir, want)
+	}
+
+	// An explicit config file still wins
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	if path, err := getConfigPath(); err != nil || path != os.Getenv("GIT_USR_CONFIG") {
+		t.Errorf("getConfigPath() = %q, %v; expected GIT_USR_CONFIG", path, err)
+	}
+	t.Setenv("GIT_USR_CONFIG", "")
+	if path, err := getConfigPath(); err != nil || path != filepath.Join(want, "profiles.json") {
+		t.Errorf("getConfigPath() = %q, %v; expected the portable config", path, err)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// profileField describes a single key accepted by `git usr profile`
+type profileField struct {
+	description string
+	get         func(p *Profile) string
+	set         func(p *Profile, value string) error
+	unset       func(p *Profile, value string) error
+}
+
+// profileFields are the profile keys that can be read and changed
+var profileFields = map[string]profileField{
+	"name": {
+		description: "user.name applied on switch",
+		get:         func(p *Profile) string { return p.Name },
+		set: func(p *Profile, value string) error {
+			value = cleanIdentityText(value)
+			if err := validateName("name", value); err != nil {
+				return err
+			}
+			warnIdentityText("Name", value)
+			p.Name = value
+			return nil
+		},
+	},
+	"email": {
+		description: "user.email applied on switch",
+		get:         func(p *Profile) string { return p.Email },
+		set: func(p *Profile, value string) error {
+			value = cleanIdentityText(value)
+			if err := validateEmail("email", value); err != nil {
+				return err
+			}
+			warnIdentityText("Email", value)
+			p.Email = value
+			return nil
+		},
+	},
+	"description": {
+		description: "what the profile is for, shown by list",
+		get:         func(p *Profile) string { return p.Description },
+		set: func(p *Profile, value string) error {
+			p.Description = cleanIdentityText(value)
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.Description = ""
+			return nil
+		},
+	},
+	"tag": {
+		description: "a tag to group profiles by, e.g. client (list --tag filters by it); unset removes one or all",
+		get:         func(p *Profile) string { return strings.Join(p.Tags, "\n") },
+		set: func(p *Profile, value string) error {
+			value = strings.TrimSpace(value)
+			if value == "" || strings.ContainsAny(value, ", \t") {
+				return fmt.Errorf("tag must be a single word without commas")
+			}
+			if !hasTag(*p, value) {
+				p.Tags = append(p.Tags, value)
+			}
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			if value == "" {
+				p.Tags = nil
+				return nil
+			}
+			if !hasTag(*p, value) {
+				return fmt.Errorf("no tag %s", value)
+			}
+			var kept []string
+			for _, tag := range p.Tags {
+				if !strings.EqualFold(tag, value) {
+					kept = append(kept, tag)
+				}
+			}
+			p.Tags = kept
+			return nil
+		},
+	},
+	"scope": {
+		description: "scope the profile is switched to without --global (local|global), overriding the defaultScope setting",
+		get:         func(p *Profile) string { return p.Scope },
+		set: func(p *Profile, value string) error {
+			if value != "local" && value != "global" {
+				return fmt.Errorf("scope must be 'local' or 'global'")
+			}
+			p.Scope = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.Scope = ""
+			return nil
+		},
+	},
+	"committerName": {
+		description: "committer.name applied on switch when committing on behalf of someone else",
+		get:         func(p *Profile) string { return p.CommitterName },
+		set: func(p *Profile, value string) error {
+			value = cleanIdentityText(value)
+			if err := validateName("committerName", value); err != nil {
+				return err
+			}
+			warnIdentityText("Committer name", value)
+			p.CommitterName = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.CommitterName = ""
+			return nil
+		},
+	},
+	"committerEmail": {
+		description: "committer.email applied on switch when committing on behalf of someone else",
+		get:         func(p *Profile) string { return p.CommitterEmail },
+		set: func(p *Profile, value string) error {
+			value = cleanIdentityText(value)
+			if err := validateEmail("committerEmail", value); err != nil {
+				return err
+			}
+			warnIdentityText("Committer email", value)
+			p.CommitterEmail = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.CommitterEmail = ""
+			return nil
+		},
+	},
+	"hostAlias": mapField(
+		"SSH host alias used for clones, as HOST=ALIAS (e.g. github.com=github.com-work)",
+		"host alias",
+		func(p *Profile) *map[string]string { return &p.HostAliases },
+		func(value string) (string, string, error) {
+			host, alias, ok := strings.Cut(value, "=")
+			if !ok || host == "" || alias == "" {
+				return "", "", fmt.Errorf("hostAlias must be HOST=ALIAS")
+			}
+			return host, alias, nil
+		},
+	),
+	"urlRewrite": mapField(
+		"Remote URL rewrite applied on switch, as PREFIX=BASE (url.BASE.insteadOf PREFIX)",
+		"URL rewrite",
+		func(p *Profile) *map[string]string { return &p.URLRewrites },
+		parseURLRewrite,
+	),
+	"credentialUsername": credentialField(
+		"credentialUsername",
+		"credential.URL.username applied on switch, as URL=USER (e.g. github.com=jdoe-work)",
+		"credential username",
+		func(p *Profile) *map[string]string { return &p.CredentialUsernames },
+	),
+	"credentialHelper": credentialField(
+		"credentialHelper",
+		"credential.URL.helper applied on switch instead of inherited helpers, as URL=HELPER",
+		"credential helper",
+		func(p *Profile) *map[string]string { return &p.CredentialHelpers },
+	),
+	"alias": mapField(
+		"git alias applied on switch and removed when switching away, as NAME=COMMAND (e.g. ci=commit -s)",
+		"alias",
+		func(p *Profile) *map[string]string { return &p.Aliases },
+		parseGitAlias,
+	),
+	"forgeAccount": mapField(
+		"gh/glab CLI account switched to on switch, as HOST=USER (e.g. github.com=jdoe-work)",
+		"forge account",
+		func(p *Profile) *map[string]string { return &p.ForgeAccounts },
+		parseForgeAccount,
+	),
+	"signingKey": {
+		description: "user.signingkey applied on switch, enabling commit and tag signing",
+		get:         func(p *Profile) string { return p.SigningKey },
+		set: func(p *Profile, value string) error {
+			p.SigningKey = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.SigningKey = ""
+			return nil
+		},
+	},
+	"signingFormat": {
+		description: "gpg.format of the signing key (openpgp|ssh|x509, default openpgp)",
+		get:         func(p *Profile) string { return p.SigningFormat },
+		set: func(p *Profile, value string) error {
+			for _, format := range signingFormats {
+				if value == format {
+					p.SigningFormat = value
+					return nil
+				}
+			}
+			return fmt.Errorf("signingFormat must be one of %s", strings.Join(signingFormats, ", "))
+		},
+		unset: func(p *Profile, value string) error {
+			p.SigningFormat = ""
+			return nil
+		},
+	},
+	"x509Program": {
+		description: "gpg.x509.program applied on switch for x509 signing, e.g. smimesign (default gpgsm)",
+		get:         func(p *Profile) string { return p.X509Program },
+		set: func(p *Profile, value string) error {
+			p.X509Program = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.X509Program = ""
+			return nil
+		},
+	},
+	"gitsignOption": mapField(
+		"gitsign.KEY applied on switch for gitsign profiles, as KEY=VALUE (e.g. connectorID=https://github.com/login/oauth)",
+		"gitsign option",
+		func(p *Profile) *map[string]string { return &p.GitsignOptions },
+		parseGitsignOption,
+	),
+	"pushRemote": {
+		description: "Remote used by push-to and set as remote.pushDefault on local switches",
+		get:         func(p *Profile) string { return p.PushRemote },
+		set: func(p *Profile, value string) error {
+			if strings.TrimSpace(value) == "" || strings.ContainsAny(value, " \t") {
+				return fmt.Errorf("pushRemote must be a remote name")
+			}
+			p.PushRemote = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.PushRemote = ""
+			return nil
+		},
+	},
+	"commitTemplate": {
+		description: "commit.template applied on switch, the message new commits start from",
+		get:         func(p *Profile) string { return p.CommitTemplate },
+		set: func(p *Profile, value string) error {
+			path, err := expandHome(value)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("commitTemplate must be an existing file: %w", err)
+			}
+			p.CommitTemplate = value
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			p.CommitTemplate = ""
+			return nil
+		},
+	},
+	"trailer": {
+		description: "trailer added to every commit after switching, as TOKEN: VALUE or a bare TOKEN like Signed-off-by; unset removes one or all",
+		get:         func(p *Profile) string { return strings.Join(p.Trailers, "\n") },
+		set: func(p *Profile, value string) error {
+			trailer, err := parseTrailer(value)
+			if err != nil {
+				return err
+			}
+			if !containsString(p.Trailers, trailer) {
+				p.Trailers = append(p.Trailers, trailer)
+			}
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			if value == "" {
+				p.Trailers = nil
+				return nil
+			}
+			trailer, err := parseTrailer(value)
+			if err != nil {
+				return err
+			}
+			if !containsString(p.Trailers, trailer) {
+				return fmt.Errorf("no trailer %s", trailer)
+			}
+			var kept []string
+			for _, existing := range p.Trailers {
+				if existing != trailer {
+					kept = append(kept, existing)
+				}
+			}
+			p.Trailers = kept
+			return nil
+		},
+	},
+}
+
+// mapField builds a profile field stored as a map, set as KEY=VALUE and
+// unset by KEY (or entirely without a value)
+func mapField(description, noun string, field func(p *Profile) *map[string]string, parse func(value string) (string, string, error)) profileField {
+	return profileField{
+		description: description,
+		get: func(p *Profile) string {
+			return formatKeyValues(*field(p))
+		},
+		set: func(p *Profile, value string) error {
+			key, mapped, err := parse(value)
+			if err != nil {
+				return err
+			}
+			m := field(p)
+			if *m == nil {
+				*m = map[string]string{}
+			}
+			(*m)[key] = mapped
+			return nil
+		},
+		unset: func(p *Profile, value string) error {
+			m := field(p)
+			if value == "" {
+				*m = nil
+				return nil
+			}
+			if _, ok := (*m)[value]; !ok {
+				return fmt.Errorf("no %s for %s", noun, value)
+			}
+			delete(*m, value)
+			if len(*m) == 0 {
+				*m = nil
+			}
+			return nil
+		},
+	}
+}
+
+// formatKeyValues formats a map as sorted KEY=VALUE lines
+func formatKeyValues(m map[string]string) string {
+	lines := make([]string, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		lines = append(lines, key+"="+m[key])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// profileFieldNames returns the profile keys in alphabetical order
+func profileFieldNames() []string {
+	names := make([]string, 0, len(profileFields))
+	for name := range profileFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupProfileField returns a profile field by key, reporting unknown keys
+func lookupProfileField(key string) (profileField, error) {
+	field, ok := profileFields[key]
+	if !ok {
+		fmt.Printf("❌ Unknown profile key '%s'\n", key)
+		fmt.Println("\nAvailable keys:")
+		for _, name := range profileFieldNames() {
+			fmt.Printf("   %-16s %s\n", name, profileFields[name].description)
+		}
+		return profileField{}, fmt.Errorf("unknown profile key: %s", key)
+	}
+	return field, nil
+}
+
+// showProfileFields prints all fields of a profile
+func showProfileFields(profileName string) error {
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range profileFieldLines(&profile) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// profileFieldLines returns the set fields of a profile as key=value lines
+func profileFieldLines(profile *Profile) []string {
+	var lines []string
+	for _, name := range profileFieldNames() {
+		value := profileFields[name].get(profile)
+		if value == "" {
+			continue
+		}
+		for _, line := range strings.Split(value, "\n") {
+			lines = append(lines, name+"="+line)
+		}
+	}
+	return lines
+}
+
+// getProfileField prints a single field of a profile
+func getProfileField(profileName, key string) error {
+	field, err := lookupProfileField(key)
+	if err != nil {
+		return err
+	}
+
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	value := field.get(&profile)
+	if value == "" {
+		return fmt.Errorf("%s is not set", key)
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// updateProfileField sets or unsets a single field of a profile
+func updateProfileField(profileName, key, value string, unset bool) error {
+	field, err := lookupProfileField(key)
+	if err != nil {
+		return err
+	}
+	if unset && field.unset == nil {
+		fmt.Printf("❌ %s cannot be unset\n", key)
+		return fmt.Errorf("%s cannot be unset", key)
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		return errProfileNotFound
+	}
+
+	if unset {
+		err = field.unset(&profile, value)
+	} else {
+		err = field.set(&profile, value)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return err
+	}
+
+	config.Profiles[profileName] = profile
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	if unset {
+		fmt.Printf("✅ Unset %s for '%s'\n", key, profileName)
+	} else {
+		fmt.Printf("✅ Set %s for '%s'\n", key, profileName)
+	}
+	return nil
+}
+
+// runProfile handles the profile command
+func runProfile(args []string) error {
+	usage := "Usage: git usr profile show <profile> | get <profile> <key> | set <profile> <key> <value> | unset <profile> <key> [value]"
+
+	switch {
+	case len(args) == 2 && args[0] == "show":
+		return showProfileFields(args[1])
+	case len(args) == 3 && args[0] == "get":
+		return getProfileField(args[1], args[2])
+	case len(args) == 4 && args[0] == "set":
+		return updateProfileField(args[1], args[2], args[3], false)
+	case (len(args) == 3 || len(args) == 4) && args[0] == "unset":
+		value := ""
+		if len(args) == 4 {
+			value = args[3]
+		}
+		return updateProfileField(args[1], args[2], value, true)
+	}
+
+	fmt.Println(usage)
+	return fmt.Errorf("invalid profile command")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// projectFileNames are the files at the top of the work tree a team
+// commits to share its identity policy, in the order they are looked for
+var projectFileNames = []string{pinFileName, ".git-usr.yaml"}
+
+// projectPolicy is what a repository's .gitusr or .git-usr.yaml declares
+type projectPolicy struct {
+	// Profile is the profile the repository is pinned to
+	Profile string
+	// EmailDomains are the domains commit emails must be at, any of which
+	// will do. Subdomains count too
+	EmailDomains []string
+	// SuggestedProfile is the profile to switch to when an identity
+	// breaks the policy
+	SuggestedProfile string
+}
+
+// projectFilePath returns the project file of the work tree at top: the
+// first one that exists, or .gitusr when there is none yet
+func projectFilePath(top string) string {
+	for _, name := range projectFileNames {
+		path := filepath.Join(top, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(top, projectFileNames[0])
+}
+
+// readProjectFile parses a project file. Keys it doesn't know are ignored
+// so newer files keep working with older versions
+func readProjectFile(path string) (projectPolicy, error) {
+	var policy projectPolicy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return policy, fmt.Errorf("%s: %w", path, err)
+	}
+	fields, ok := doc.(map[string]any)
+	if !ok {
+		if doc == nil {
+			return policy, nil
+		}
+		return policy, fmt.Errorf("%s: expected a mapping", path)
+	}
+
+	profile, _ := fields["profile"].(string)
+	policy.Profile = strings.TrimSpace(profile)
+	suggested, _ := fields["suggestedProfile"].(string)
+	policy.SuggestedProfile = strings.TrimSpace(suggested)
+
+	// emailDomain takes one domain or a list of them
+	switch value := fields["emailDomain"].(type) {
+	case nil:
+	case string:
+		policy.EmailDomains = append(policy.EmailDomains, value)
+	case []any:
+		for _, item := range value {
+			domain, ok := item.(string)
+			if !ok {
+				return policy, fmt.Errorf("%s: emailDomain must be a domain or a list of domains", path)
+			}
+			policy.EmailDomains = append(policy.EmailDomains, domain)
+		}
+	default:
+		return policy, fmt.Errorf("%s: emailDomain must be a domain or a list of domains", path)
+	}
+	domains := policy.EmailDomains[:0]
+	for _, domain := range policy.EmailDomains {
+		if domain = strings.TrimPrefix(strings.TrimSpace(domain), "@"); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	policy.EmailDomains = domains
+	return policy, nil
+}
+
+// getProjectPolicy reads the project file of the repository in dir. A
+// repository without one has an empty policy
+func getProjectPolicy(dir string) (projectPolicy, string, error) {
+	top, err := gitConfig.WorkTree(dir)
+	if err != nil {
+		return projectPolicy{}, "", err
+	}
+	path := projectFilePath(top)
+	policy, err := readProjectFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return projectPolicy{}, "", nil
+	}
+	return policy, filepath.Base(path), err
+}
+
+// allowsEmail reports whether the policy lets email commit
+func (p projectPolicy) allowsEmail(email string) bool {
+	if len(p.EmailDomains) == 0 {
+		return true
+	}
+	for _, domain := range p.EmailDomains {
+		if emailInDomain(email, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestProfile returns the profile to switch to for the policy: the one
+// it suggests if there is such a profile, otherwise the first whose email
+// it allows. It returns "" without a policy or a fitting profile
+func suggestProfile(profiles map[string]Profile, policy projectPolicy) string {
+	if _, exists := profiles[policy.SuggestedProfile]; exists {
+		return policy.SuggestedProfile
+	}
+	if len(policy.EmailDomains) == 0 {
+		return ""
+	}
+	for _, name := range sortedKeys(profiles) {
+		if policy.allowsEmail(profiles[name].Email) {
+			return name
+		}
+	}
+	return ""
+}
+
+// formatDomains lists domains for messages, as "@a.com or @b.com"
+func formatDomains(domains []string) string {
+	sorted := append([]string{}, domains...)
+	sort.Strings(sorted)
+	for i, domain := range sorted {
+		sorted[i] = "@" + domain
+	}
+	return strings.Join(sorted, " or ")
+}
+
+// guardCommit is the guard run by the pre-commit hook: it fails when the
+// author or committer of the next commit breaks the repository's policy
+// or the rule that applies to it
+func guardCommit() error {
+	policy, file, err := getProjectPolicy("")
+	if isUnsafeRepository(err) {
+		return nil
+	}
+	if err != nil {
+		// A broken policy file shouldn't stop anyone from committing
+		fmt.Fprintf(os.Stderr, "⚠️  git-usr: %v\n", err)
+	}
+	config, err := loadConfig()
+	if errors.Is(err, errConfigLocked) {
+		// Without the passphrase only the repository's policy can be checked
+		config, err = &Config{}, nil
+	}
+	if err != nil {
+		return nil
+	}
+	facts, _ := repoRuleFacts("")
+	rule, number := matchingRule(config.Rules, facts)
+	if len(policy.EmailDomains) == 0 && number == 0 {
+		return nil
+	}
+
+	// Without an identity git refuses the commit and explains why itself
+	idents, err := effectiveIdents()
+	if err != nil {
+		return nil
+	}
+	suggested, blocked := "", false
+	for _, ident := range idents {
+		// Only report the committer when it differs from the author
+		if ident.Role == "committer" && ident.Email == idents[0].Email {
+			continue
+		}
+		switch {
+		case !policy.allowsEmail(ident.Email):
+			fmt.Fprintf(os.Stderr, "❌ git-usr: the %s %s is not at %s as %s requires\n", ident.Role, formatAddress(ident.Name, ident.Email), formatDomains(policy.EmailDomains), file)
+			if suggested == "" {
+				suggested = suggestProfile(config.Profiles, policy)
+			}
+		case number != 0 && !rule.allows(config.Profiles, ident):
+			fmt.Fprintf(os.Stderr, "❌ git-usr: the %s %s breaks rule %d (%s)\n", ident.Role, formatAddress(ident.Name, ident.Email), number, rule)
+			if suggested == "" {
+				suggested = ruleProfile(config.Profiles, rule)
+			}
+		default:
+			continue
+		}
+		blocked = true
+	}
+	if !blocked {
+		return nil
+	}
+	if suggested != "" {
+		fmt.Fprintf(os.Stderr, "   Run 'git usr apply %s' and commit again\n", suggested)
+	}
+	return fmt.Errorf("identity not allowed here")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestReadProjectFile tests the policy keys of a project file
+func TestReadProjectFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    projectPolicy
+		wantErr bool
+	}{
+		{"empty", "", projectPolicy{}, false},
+		{"one domain", "emailDomain: corp.com\n", projectPolicy{EmailDomains: []string{"corp.com"}}, false},
+		{"at sign", "emailDomain: \"@corp.com\"\n", projectPolicy{EmailDomains: []string{"corp.com"}}, false},
+		{"list", "emailDomain:\n  - corp.com\n  - corp.io\nsuggestedProfile: work\n", projectPolicy{EmailDomains: []string{"corp.com", "corp.io"}, SuggestedProfile: "work"}, false},
+		{"pin", "profile: work\nunknown: x\n", projectPolicy{Profile: "work"}, false},
+		{"bad domain", "emailDomain:\n  key: corp.com\n", projectPolicy{}, true},
+	}
+
+	path := filepath.Join(t.TempDir(), ".git-usr.yaml")
+	for _, tt := range tests {
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readProjectFile(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, expected %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestProjectFilePath tests that .gitusr is preferred and the default
+func TestProjectFilePath(t *testing.T) {
+	top := t.TempDir()
+	if got := projectFilePath(top); got != filepath.Join(top, ".gitusr") {
+		t.Errorf("projectFilePath = %q, expected .gitusr", got)
+	}
+	os.WriteFile(filepath.Join(top, ".git-usr.yaml"), nil, 0644)
+	if got := projectFilePath(top); got != filepath.Join(top, ".git-usr.yaml") {
+		t.Errorf("projectFilePath = %q, expected .git-usr.yaml", got)
+	}
+	os.WriteFile(filepath.Join(top, ".gitusr"), nil, 0644)
+	if got := projectFilePath(top); got != filepath.Join(top, ".gitusr") {
+		t.Errorf("projectFilePath = %q, expected .gitusr", got)
+	}
+}
+
+// TestSuggestProfile tests which profile a policy suggests
+func TestSuggestProfile(t *testing.T) {
+	profiles := map[string]Profile{
+		"home": {Name: "Jane", Email: "jane@home.dev"},
+		"work": {Name: "Jane", Email: "jane@eu.corp.com"},
+	}
+	tests := []struct {
+		policy projectPolicy
+		want   string
+	}{
+		{projectPolicy{}, ""},
+		{projectPolicy{EmailDomains: []string{"corp.com"}}, "work"},
+		{projectPolicy{EmailDomains: []string{"other.org"}}, ""},
+		{projectPolicy{EmailDomains: []string{"corp.com"}, SuggestedProfile: "home"}, "home"},
+		{projectPolicy{EmailDomains: []string{"corp.com"}, SuggestedProfile: "gone"}, "work"},
+	}
+	for _, tt := range tests {
+		if got := suggestProfile(profiles, tt.policy); got != tt.want {
+			t.Errorf("suggestProfile(%+v) = %q, expected %q", tt.policy, got, tt.want)
+		}
+	}
+}
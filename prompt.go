@@ -0,0 +1,437 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Exit codes of `git usr prompt --check`. These are a stable contract for
+// shell prompts that color themselves without parsing any output. The
+// codes of exitcodes.go leave 3 to 5 to them; an unsafe repository and a
+// locked config, whose profiles can't be read without prompting, reuse the
+// exit codes that mean the same
+const (
+	promptCheckOK         = 0
+	promptCheckMismatch   = 3
+	promptCheckNoIdentity = 4
+	promptCheckNotRepo    = 5
+	promptCheckUnsafe     = exitUnsafeRepository
+	promptCheckUnknown    = exitConfigLocked
+)
+
+// isInsideWorkTree reports whether the working directory is inside a git
+// work tree, returning an UnsafeRepositoryError if git refuses to use it
+func isInsideWorkTree() (bool, error) {
+	return isInsideWorkTreeIn("")
+}
+
+// isInsideWorkTreeIn is isInsideWorkTree for dir
+func isInsideWorkTreeIn(dir string) (bool, error) {
+	_, err := gitConfig.WorkTree(dir)
+	if isUnsafeRepository(err) {
+		return false, err
+	}
+	return err == nil, nil
+}
+
+// getIdentityIn returns the effective user.name and user.email in dir
+func getIdentityIn(dir string) (string, string) {
+	return gitConfigValue(dir, "", "user.name"), gitConfigValue(dir, "", "user.email")
+}
+
+// promptCheckState classifies the current repository's identity
+func promptCheckState() (int, error) {
+	return promptCheckStateIn("")
+}
+
+// promptCheckStateIn classifies the identity of the repository at dir
+func promptCheckStateIn(dir string) (int, error) {
+	inside, err := isInsideWorkTreeIn(dir)
+	if err != nil {
+		return promptCheckUnsafe, nil
+	}
+	if !inside {
+		return promptCheckNotRepo, nil
+	}
+
+	name, email := getIdentityIn(dir)
+	if name == "" || email == "" {
+		return promptCheckNoIdentity, nil
+	}
+
+	config, err := loadConfig()
+	if errors.Is(err, errConfigLocked) {
+		return promptCheckUnknown, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	profiles := config.Profiles
+	if _, ok := findProfileByIdentity(profiles, name, email); !ok {
+		return promptCheckMismatch, nil
+	}
+	if _, number := brokenRule(config, dir, name, email); number != 0 {
+		return promptCheckMismatch, nil
+	}
+	if pinDrift(profiles, dir) != "" {
+		return promptCheckMismatch, nil
+	}
+	if policy, _, err := getProjectPolicy(dir); err == nil && !policy.allowsEmail(email) {
+		return promptCheckMismatch, nil
+	}
+
+	return promptCheckOK, nil
+}
+
+// defaultPromptFormat shows just the profile name
+const defaultPromptFormat = "%p"
+
+// promptCacheTTL bounds how long a cached prompt is trusted, for changes
+// the cache key can't see such as included git config files or a remote
+// profile store
+const promptCacheTTL = time.Minute
+
+// promptInfo is what `git usr prompt` shows for a repository
+type promptInfo struct {
+	Key     string    `json:"key"`
+	Time    time.Time `json:"time"`
+	State   int       `json:"state"`
+	Profile string    `json:"profile,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Email   string    `json:"email,omitempty"`
+	Format  string    `json:"format,omitempty"`
+	// Expected are the profiles the repository's remotes belong to
+	Expected []string `json:"expected,omitempty"`
+	// Pinned is the profile the repository is pinned to, and PinMissing
+	// whether there is no profile of that name
+	Pinned     string `json:"pinned,omitempty"`
+	PinMissing bool   `json:"pinMissing,omitempty"`
+	// Domains are the email domains the repository's project file
+	// requires, PolicyFile is its name and Suggested the profile to
+	// switch to when the email isn't at one of them
+	Domains    []string `json:"domains,omitempty"`
+	PolicyFile string   `json:"policyFile,omitempty"`
+	Suggested  string   `json:"suggested,omitempty"`
+	// BrokenRule describes the rule the identity breaks and RuleProfile
+	// the profile that satisfies it
+	BrokenRule  string `json:"brokenRule,omitempty"`
+	RuleProfile string `json:"ruleProfile,omitempty"`
+}
+
+// findWorkTreeRoot walks up from dir to the directory containing .git
+// without running git, returning "" outside repositories
+func findWorkTreeRoot(dir string) string {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// promptCacheKey fingerprints the files the prompt depends on: the
+// repository and global git config, HEAD, the project file and the git-usr
+// config. In worktrees, where .git is a file, local config changes wait
+// for promptCacheTTL
+func promptCacheKey(root string) string {
+	paths := []string{
+		filepath.Join(root, ".git"),
+		filepath.Join(root, ".git", "config"),
+		filepath.Join(root, ".git", "HEAD"),
+	}
+	for _, name := range projectFileNames {
+		paths = append(paths, filepath.Join(root, name))
+	}
+	if global := os.Getenv("GIT_CONFIG_GLOBAL"); global != "" {
+		paths = append(paths, global)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		xdg := os.Getenv("XDG_CONFIG_HOME")
+		if xdg == "" {
+			xdg = filepath.Join(home, ".config")
+		}
+		paths = append(paths, filepath.Join(home, ".gitconfig"), filepath.Join(xdg, "git", "config"))
+	}
+	if configPath, err := getConfigPath(); err == nil {
+		paths = append(paths, configPath)
+	}
+
+	var parts []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			parts = append(parts, fmt.Sprintf("%s@%d/%d", path, info.ModTime().UnixNano(), info.Size()))
+		} else {
+			parts = append(parts, path+"@-")
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// getPromptCachePath returns the path of the prompt cache
+func getPromptCachePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "prompt-cache.json"), nil
+}
+
+// loadPromptCache returns the cached prompts by repository root
+func loadPromptCache() map[string]promptInfo {
+	cache := map[string]promptInfo{}
+	path, err := getPromptCachePath()
+	if err != nil {
+		return cache
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// savePromptCache writes the cache, dropping expired entries. Failures
+// are ignored, the prompt just gets slower
+func savePromptCache(cache map[string]promptInfo) {
+	for root, info := range cache {
+		if time.Since(info.Time) > promptCacheTTL {
+			delete(cache, root)
+		}
+	}
+	path, err := getPromptCachePath()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	writeFileAtomic(path, data, 0644, false)
+}
+
+// computePromptInfo looks up the identity of the current repository
+func computePromptInfo() (promptInfo, error) {
+	state, err := promptCheckState()
+	if err != nil {
+		return promptInfo{}, err
+	}
+	info := promptInfo{State: state}
+	if state != promptCheckOK && state != promptCheckMismatch && state != promptCheckNoIdentity {
+		return info, nil
+	}
+
+	config, err := loadConfig()
+	if errors.Is(err, errConfigLocked) {
+		return promptInfo{State: promptCheckUnknown}, nil
+	}
+	if err != nil {
+		return promptInfo{}, err
+	}
+	info.Format = config.Settings.PromptFormat
+	info.Name, info.Email = getIdentityIn("")
+	info.Profile, _ = findProfileByIdentity(config.Profiles, info.Name, info.Email)
+	info.Expected = expectedProfiles(config.Profiles, getRemoteURLs(""))
+	if info.Pinned, _ = getPin(""); info.Pinned != "" {
+		_, exists := config.Profiles[info.Pinned]
+		info.PinMissing = !exists
+	}
+	if policy, file, err := getProjectPolicy(""); err == nil && len(policy.EmailDomains) > 0 {
+		info.Domains, info.PolicyFile = policy.EmailDomains, file
+		info.Suggested = suggestProfile(config.Profiles, policy)
+	}
+	if r, number := brokenRule(config, "", info.Name, info.Email); number != 0 && info.Email != "" {
+		info.BrokenRule = fmt.Sprintf("rule %d (%s)", number, r)
+		info.RuleProfile = ruleProfile(config.Profiles, r)
+	}
+	return info, nil
+}
+
+// getPromptInfo returns the prompt for the current repository, from the
+// cache when nothing it depends on changed
+func getPromptInfo(useCache bool) (promptInfo, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return promptInfo{}, err
+	}
+	root := findWorkTreeRoot(cwd)
+	if root == "" {
+		return promptInfo{State: promptCheckNotRepo}, nil
+	}
+	if !useCache {
+		return computePromptInfo()
+	}
+
+	key := promptCacheKey(root)
+	cache := loadPromptCache()
+	if info, ok := cache[root]; ok && info.Key == key && time.Since(info.Time) < promptCacheTTL {
+		return info, nil
+	}
+
+	info, err := computePromptInfo()
+	if err != nil {
+		return promptInfo{}, err
+	}
+	// Once GIT_USR_PASSPHRASE is set the state is known, so don't keep it
+	if info.State == promptCheckUnknown {
+		return info, nil
+	}
+	info.Key, info.Time = key, time.Now()
+	cache[root] = info
+	savePromptCache(cache)
+	return info, nil
+}
+
+// formatPrompt expands %p (profile, ? when the identity matches none),
+// %n (name), %e (email) and %% in format. Repositories without an
+// identity show nothing
+func formatPrompt(format string, info promptInfo) string {
+	if info.State != promptCheckOK && info.State != promptCheckMismatch {
+		return ""
+	}
+	profile := info.Profile
+	if profile == "" {
+		profile = "?"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'p':
+			b.WriteString(profile)
+		case 'n':
+			b.WriteString(info.Name)
+		case 'e':
+			b.WriteString(info.Email)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
+
+// promptStatuses name the identity states in machine-readable prompts
+var promptStatuses = map[int]string{
+	promptCheckOK:         "ok",
+	promptCheckMismatch:   "mismatch",
+	promptCheckNoIdentity: "no-identity",
+	promptCheckNotRepo:    "not-repo",
+	promptCheckUnsafe:     "unsafe",
+	promptCheckUnknown:    "unknown",
+}
+
+// promptStyles are the named formats of `git usr prompt --format`, for
+// prompt frameworks that want more than plain text
+var promptStyles = map[string]func(info promptInfo) string{
+	"starship": starshipPrompt,
+	"json":     jsonPrompt,
+}
+
+// starshipPrompt colors the profile for a starship custom module: green
+// for a known profile, red with the email when the identity matches no
+// profile and yellow when there is none or the config is locked
+func starshipPrompt(info promptInfo) string {
+	const green, red, yellow, reset = "\x1b[32m", "\x1b[31m", "\x1b[33m", "\x1b[0m"
+	switch info.State {
+	case promptCheckOK:
+		return green + info.Profile + reset
+	case promptCheckMismatch:
+		return red + "? " + info.Email + reset
+	case promptCheckNoIdentity:
+		return yellow + "no identity" + reset
+	case promptCheckUnknown:
+		return yellow + "locked" + reset
+	}
+	return ""
+}
+
+// jsonPrompt describes the identity as a JSON object, e.g. for
+// oh-my-posh or other tools that template it themselves
+func jsonPrompt(info promptInfo) string {
+	data, _ := json.Marshal(struct {
+		Status  string `json:"status"`
+		Profile string `json:"profile"`
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+	}{promptStatuses[info.State], info.Profile, info.Name, info.Email})
+	return string(data)
+}
+
+// runPrompt handles the prompt command
+func runPrompt(args []string) error {
+	usage := "Usage: git usr prompt [--format <format>|starship|json] [--no-cache] | --check"
+
+	check, useCache, format := false, true, ""
+	// Prompts run on every command line and must never wait for input
+	promptsDisabled = true
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--check":
+			check = true
+		case "--no-cache":
+			useCache = false
+		case "--format":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--format requires a value")
+			}
+			format = args[i+1]
+			i++
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+
+	if check {
+		state, err := promptCheckState()
+		if err != nil {
+			return err
+		}
+		if state != promptCheckOK {
+			return &ExitError{Code: state}
+		}
+		return nil
+	}
+
+	info, err := getPromptInfo(useCache)
+	if err != nil {
+		var unsafeErr *UnsafeRepositoryError
+		if errors.As(err, &unsafeErr) {
+			return nil
+		}
+		return err
+	}
+	if format == "" {
+		format = info.Format
+	}
+	if format == "" {
+		format = defaultPromptFormat
+	}
+
+	out := ""
+	if style, ok := promptStyles[format]; ok {
+		out = style(info)
+	} else {
+		out = formatPrompt(format, info)
+	}
+	if out != "" {
+		fmt.Println(out)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFormatPrompt tests the prompt placeholders
+func TestFormatPrompt(t *testing.T) {
+	info := promptInfo{State: promptCheckOK, Profile: "work", Name: "John Doe", Email: "john@work.com"}
+	tests := []struct {
+		format   string
+		info     promptInfo
+		expected string
+	}{
+		{"%p", info, "work"},
+		{"%n <%e> 100%% %x%", info, "John Doe <john@work.com> 100% %x%"},
+		{"%p", promptInfo{State: promptCheckMismatch, Email: "other@work.com"}, "?"},
+		{"%p", promptInfo{State: promptCheckNoIdentity}, ""},
+		{"%p", promptInfo{State: promptCheckNotRepo}, ""},
+	}
+
+	for _, test := range tests {
+		if got := formatPrompt(test.format, test.info); got != test.expected {
+			t.Errorf("formatPrompt(%q) = %q, expected %q", test.format, got, test.expected)
+		}
+	}
+}
+
+// TestFindWorkTreeRoot tests finding the repository without git
+func TestFindWorkTreeRoot(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := findWorkTreeRoot(nested); got == root {
+		t.Fatalf("Expected no repository yet, got %s", got)
+	}
+
+	// A .git file, as in worktrees and submodules, counts too
+	if err := os.WriteFile(filepath.Join(root, ".git"), []byte("gitdir: elsewhere\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := findWorkTreeRoot(nested); got != root {
+		t.Errorf("Expected %s, got %s", root, got)
+	}
+}
+
+// TestPromptStyles tests the starship and json formats
+func TestPromptStyles(t *testing.T) {
+	ok := promptInfo{State: promptCheckOK, Profile: "work", Name: "John Doe", Email: "john@work.com"}
+	if got := starshipPrompt(ok); got != "\x1b[32mwork\x1b[0m" {
+		t.Errorf("Unexpected starship output %q", got)
+	}
+	if got := starshipPrompt(promptInfo{State: promptCheckMismatch, Email: "x@y.z"}); got != "\x1b[31m? x@y.z\x1b[0m" {
+		t.Errorf("Unexpected starship output for a mismatch %q", got)
+	}
+	if got := starshipPrompt(promptInfo{State: promptCheckNotRepo}); got != "" {
+		t.Errorf("Expected no starship output outside repositories, got %q", got)
+	}
+
+	expected := `{"status":"ok","profile":"work","name":"John Doe","email":"john@work.com"}`
+	if got := jsonPrompt(ok); got != expected {
+		t.Errorf("jsonPrompt = %s, expected %s", got, expected)
+	}
+}
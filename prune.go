@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pruneReference is something that names a profile and stops working, or
+// silently changes meaning, once the profile is gone
+type pruneReference struct {
+	Kind   string
+	Target string
+}
+
+func (r pruneReference) String() string {
+	if r.Target == "" {
+		return r.Kind
+	}
+	return r.Kind + " " + r.Target
+}
+
+// profileReferences returns what refers to a profile: the default profile
+// setting, watched directories and the git config it was applied to
+func profileReferences(config *Config, state *ManagedState, name string) []pruneReference {
+	var refs []pruneReference
+	if config.Settings.DefaultProfile == name {
+		refs = append(refs, pruneReference{Kind: "default profile"})
+	}
+	for _, dir := range sortedKeys(config.Watch) {
+		if config.Watch[dir] == name {
+			refs = append(refs, pruneReference{Kind: "watched directory", Target: dir})
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, key := range state.Keys {
+		if key.Profile != name || seen[key.Repo] {
+			continue
+		}
+		seen[key.Repo] = true
+		if key.Scope == "global" {
+			refs = append(refs, pruneReference{Kind: "global git config"})
+		} else {
+			refs = append(refs, pruneReference{Kind: "repository", Target: key.Repo})
+		}
+	}
+	return refs
+}
+
+// dropProfileReferences clears the config's references to a profile.
+// Watched directories keep being watched, picking the profile by remote
+func dropProfileReferences(config *Config, name string) []string {
+	var dropped []string
+	if config.Settings.DefaultProfile == name {
+		config.Settings.DefaultProfile = ""
+		dropped = append(dropped, "cleared the default profile")
+	}
+	for _, dir := range sortedKeys(config.Watch) {
+		if config.Watch[dir] == name {
+			config.Watch[dir] = ""
+			dropped = append(dropped, fmt.Sprintf("%s now uses the remote's profile", dir))
+		}
+	}
+	return dropped
+}
+
+// pruneDecision is what to do with an unused profile
+type pruneDecision struct {
+	Profile string
+	Archive bool
+}
+
+// askPruneDecision asks what to do with a profile; ok is false to stop
+func askPruneDecision(reader *bufio.Reader) (action string, ok bool) {
+	for {
+		fmt.Print("   [a]rchive, [d]elete, [s]kip or [q]uit? ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", false
+		}
+		switch answer := strings.ToLower(strings.TrimSpace(line)); answer {
+		case "a", "d", "s":
+			return answer, true
+		case "q":
+			return "", false
+		}
+	}
+}
+
+// applyPruneDecisions archives or deletes profiles and drops the
+// references to them. Deleting also retracts the git config they set;
+// archiving leaves it, so a restored profile picks up where it left off
+func applyPruneDecisions(decisions []pruneDecision) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Profiles removed meanwhile by another git-usr are left out
+	var applied []pruneDecision
+	var notes [][]string
+	for _, decision := range decisions {
+		profile, exists := config.Profiles[decision.Profile]
+		if !exists {
+			continue
+		}
+		if decision.Archive {
+			if config.Archived == nil {
+				config.Archived = map[string]Profile{}
+			}
+			config.Archived[decision.Profile] = profile
+		}
+		delete(config.Profiles, decision.Profile)
+		applied = append(applied, decision)
+		notes = append(notes, dropProfileReferences(config, decision.Profile))
+	}
+
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	for i, decision := range applied {
+		if decision.Archive {
+			fmt.Printf("📦 Archived '%s' (restore it with 'git usr prune --restore %s')\n", decision.Profile, decision.Profile)
+		} else {
+			fmt.Printf("✅ Profile '%s' removed!\n", decision.Profile)
+		}
+		for _, note := range notes[i] {
+			fmt.Printf("   %s\n", note)
+		}
+		if decision.Archive {
+			continue
+		}
+
+		count, unsafe, err := retractProfileKeys(decision.Profile)
+		if err != nil {
+			fmt.Printf("⚠️  Could not retract git config set by '%s': %v\n", decision.Profile, err)
+		} else if count > 0 {
+			fmt.Printf("🧹 Retracted %d git config value(s) set by '%s'\n", count, decision.Profile)
+		}
+		for _, unsafeErr := range unsafe {
+			printUnsafeGuidance(unsafeErr)
+		}
+	}
+	return nil
+}
+
+// restoreArchivedProfile moves an archived profile back to the profiles
+func restoreArchivedProfile(name string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profile, archived := config.Archived[name]
+	if !archived {
+		if names := sortedProfileNames(config.Archived); len(names) > 0 {
+			fmt.Printf("Archived profiles: %s\n", strings.Join(names, ", "))
+		}
+		fmt.Printf("❌ Profile '%s' is not archived!\n", name)
+		return fmt.Errorf("not archived: %s", name)
+	}
+	if _, exists := config.Profiles[name]; exists {
+		fmt.Printf("❌ Profile '%s' already exists; remove or rename it first\n", name)
+		return fmt.Errorf("profile already exists: %s", name)
+	}
+
+	config.Profiles[name] = profile
+	delete(config.Archived, name)
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Profile '%s' restored!\n", name)
+	return nil
+}
+
+// pruneProfiles lists the profiles unused for months and what refers to
+// them, and on a terminal asks whether to archive or delete each
+func pruneProfiles(months int, now time.Time) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.AddDate(0, -months, 0)
+	if state.UsageSince == nil || state.UsageSince.After(cutoff) {
+		since := "no switches recorded yet"
+		if state.UsageSince != nil {
+			since = "usage recorded since " + state.UsageSince.Format("2006-01-02")
+		}
+		fmt.Printf("ℹ️  Can't tell which profiles are unused for %d months: %s\n", months, since)
+		return nil
+	}
+
+	findings := lintUnused(lintContext{Config: config, State: state, Now: now, UnusedMonths: months})
+	if len(findings) == 0 {
+		fmt.Printf("✅ Every profile was used in the last %d months\n", months)
+		return nil
+	}
+
+	interactive := isInteractive()
+	reader := bufio.NewReader(os.Stdin)
+	var decisions []pruneDecision
+	for _, finding := range findings {
+		fmt.Printf("\n🗑️  %s (%s): %s\n", finding.Profile, config.Profiles[finding.Profile].Email, finding.Problem)
+		refs := profileReferences(config, state, finding.Profile)
+		if len(refs) == 0 {
+			fmt.Println("   Nothing refers to it")
+		}
+		for _, ref := range refs {
+			fmt.Printf("   Used by %s\n", ref)
+		}
+		if !interactive {
+			continue
+		}
+
+		action, ok := askPruneDecision(reader)
+		if !ok {
+			break
+		}
+		if action != "s" {
+			decisions = append(decisions, pruneDecision{Profile: finding.Profile, Archive: action == "a"})
+		}
+	}
+
+	if !interactive {
+		fmt.Println("\nRun 'git usr prune' from a terminal to archive or delete them.")
+		return nil
+	}
+	if len(decisions) == 0 {
+		fmt.Println("\nNothing changed")
+		return nil
+	}
+	fmt.Println()
+	return applyPruneDecisions(decisions)
+}
+
+// staleIdentity is a repository whose local identity is no profile's
+// email. Former is the archived or removed profile it belonged to, if known
+type staleIdentity struct {
+	Repo   string
+	Email  string
+	Former string
+	// Suggested is the profile the remote belongs to, or the default profile
+	Suggested string
+}
+
+// formerProfileEmails maps the emails of archived profiles, and of removed
+// profiles git-usr wrote to repositories, to the profile's name
+func formerProfileEmails(config *Config, state *ManagedState) map[string]string {
+	former := map[string]string{}
+	for _, key := range state.Keys {
+		if _, exists := config.Profiles[key.Profile]; !exists && key.Key == "user.email" {
+			former[strings.ToLower(key.Value)] = key.Profile
+		}
+	}
+	for _, name := range sortedProfileNames(config.Archived) {
+		former[strings.ToLower(config.Archived[name].Email)] = name
+	}
+	return former
+}
+
+// findStaleIdentities returns the repositories whose local user.email
+// matches none of the profiles. Repositories without a local identity use
+// the global one and are left out
+func findStaleIdentities(config *Config, state *ManagedState, repos []string, inspect repoInspector) []staleIdentity {
+	former := formerProfileEmails(config, state)
+	var stale []staleIdentity
+	for _, repo := range repos {
+		email, remotes, exists := inspect(repo)
+		if !exists || email == "" {
+			continue
+		}
+		if _, known := profileForEmail(config.Profiles, email); known {
+			continue
+		}
+		suggested := config.Settings.DefaultProfile
+		if expected := expectedProfiles(config.Profiles, remotes); len(expected) > 0 {
+			suggested = expected[0]
+		}
+		if _, exists := config.Profiles[suggested]; !exists {
+			suggested = ""
+		}
+		stale = append(stale, staleIdentity{Repo: repo, Email: email, Former: former[strings.ToLower(email)], Suggested: suggested})
+	}
+	return stale
+}
+
+// askReplacement asks which profile to use instead of a stale identity;
+// ok is false to stop
+func askReplacement(reader *bufio.Reader, profiles map[string]Profile, suggested string) (profileName string, ok bool) {
+	for {
+		if suggested != "" {
+			fmt.Printf("   Replace with which profile? [%s] (s to skip, q to quit) ", suggested)
+		} else {
+			fmt.Print("   Replace with which profile? (s to skip, q to quit) ")
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", false
+		}
+		switch answer := strings.TrimSpace(line); answer {
+		case "":
+			if suggested != "" {
+				return suggested, true
+			}
+		case "s":
+			return "", true
+		case "q":
+			return "", false
+		default:
+			if _, exists := profiles[answer]; exists {
+				return answer, true
+			}
+			fmt.Printf("   No profile '%s'. Profiles: %s\n", answer, strings.Join(sortedProfileNames(profiles), ", "))
+		}
+	}
+}
+
+// replaceStaleIdentity applies a profile to the repository of a stale
+// identity
+func replaceStaleIdentity(config *Config, stale staleIdentity, profileName string) error {
+	// The git helpers work on the current directory
+	previous, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(stale.Repo); err != nil {
+		return err
+	}
+	defer os.Chdir(previous)
+
+	warnings, err := applyLocalProfile(config, profileName)
+	if err != nil {
+		fmt.Printf("❌ %s: applying '%s' failed: %v\n", stale.Repo, profileName, err)
+		return err
+	}
+	fmt.Printf("✅ %s: %s → %s <%s>\n", stale.Repo, stale.Email, profileName, config.Profiles[profileName].Email)
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	return nil
+}
+
+// scanStaleIdentities finds the repositories below dir that still commit
+// as an identity no profile has, e.g. one of a job left behind, and
+// replaces it with replacement, or on a terminal the profile picked for each
+func scanStaleIdentities(dir, replacement string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	if replacement != "" {
+		if _, exists := config.Profiles[replacement]; !exists {
+			fmt.Printf("❌ Profile '%s' not found!\n", replacement)
+			return errProfileNotFound
+		}
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		fmt.Printf("❌ %s is not a directory\n", dir)
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	stale := findStaleIdentities(config, state, findRepos(root, watchDepth), inspectRepo)
+	if len(stale) == 0 {
+		fmt.Printf("✅ Every repository below %s commits as one of your profiles\n", root)
+		return nil
+	}
+
+	interactive := replacement == "" && isInteractive()
+	reader := bufio.NewReader(os.Stdin)
+	replaced, failed := 0, 0
+	for _, identity := range stale {
+		fmt.Printf("\n🕸️  %s commits as %s", identity.Repo, identity.Email)
+		if identity.Former != "" {
+			fmt.Printf(" (former profile '%s')", identity.Former)
+		}
+		fmt.Println()
+
+		profileName := replacement
+		if interactive {
+			var ok bool
+			profileName, ok = askReplacement(reader, config.Profiles, identity.Suggested)
+			if !ok {
+				break
+			}
+		}
+		if profileName == "" {
+			continue
+		}
+		if err := replaceStaleIdentity(config, identity, profileName); err != nil {
+			failed++
+			continue
+		}
+		replaced++
+	}
+
+	if replacement == "" && !interactive {
+		fmt.Printf("\n%d repository(ies) with a stale identity. Run 'git usr prune --scan %s' from a terminal, or add --to <profile>, to update them.\n", len(stale), dir)
+		return nil
+	}
+	fmt.Printf("\nUpdated %d of %d repository(ies)\n", replaced, len(stale))
+	if failed > 0 {
+		return fmt.Errorf("%d repository(ies) could not be updated", failed)
+	}
+	return nil
+}
+
+// listArchivedProfiles prints the archived profiles
+func listArchivedProfiles() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(config.Archived) == 0 {
+		fmt.Println("No archived profiles")
+		return nil
+	}
+
+	fmt.Println("📦 Archived profiles:")
+	for _, name := range sortedProfileNames(config.Archived) {
+		profile := config.Archived[name]
+		fmt.Printf("   %s: %s <%s>\n", name, profile.Name, profile.Email)
+	}
+	return nil
+}
+
+// runPrune handles the prune command
+func runPrune(args []string) error {
+	usage := "Usage: git usr prune [--unused-months <n>] | --archived | --restore <profile> | --scan <dir> [--to <profile>]"
+
+	months := 6
+	scanDir, replacement := "", ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--unused-months":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--unused-months requires a value")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				fmt.Println(usage)
+				return fmt.Errorf("invalid --unused-months: %s", args[i+1])
+			}
+			months = n
+			i++
+		case "--archived":
+			return listArchivedProfiles()
+		case "--restore":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("--restore requires a profile")
+			}
+			return restoreArchivedProfile(args[i+1])
+		case "--scan", "--to":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			if args[i] == "--scan" {
+				scanDir = args[i+1]
+			} else {
+				replacement = args[i+1]
+			}
+			i++
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+	if replacement != "" && scanDir == "" {
+		fmt.Println(usage)
+		return fmt.Errorf("--to requires --scan")
+	}
+	if scanDir != "" {
+		return scanStaleIdentities(scanDir, replacement)
+	}
+	return pruneProfiles(months, time.Now())
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestProfileReferences tests finding what refers to a profile
+func TestProfileReferences(t *testing.T) {
+	config := &Config{
+		Profiles: map[string]Profile{"old": {}, "work": {}},
+		Watch:    map[string]string{"/src/old": "old", "/src/work": "work"},
+		Settings: Settings{DefaultProfile: "old"},
+	}
+	state := &ManagedState{Keys: []ManagedKey{
+		{Scope: "local", Repo: "/src/old/app", Key: "user.name", Profile: "old"},
+		{Scope: "local", Repo: "/src/old/app", Key: "user.email", Profile: "old"},
+		{Scope: "global", Key: "user.email", Profile: "old"},
+		{Scope: "local", Repo: "/src/work/app", Key: "user.email", Profile: "work"},
+	}}
+
+	var refs []string
+	for _, ref := range profileReferences(config, state, "old") {
+		refs = append(refs, ref.String())
+	}
+	expected := "default profile|watched directory /src/old|repository /src/old/app|global git config"
+	if got := strings.Join(refs, "|"); got != expected {
+		t.Errorf("profileReferences = %q, expected %q", got, expected)
+	}
+
+	dropped := dropProfileReferences(config, "old")
+	if len(dropped) != 2 || config.Settings.DefaultProfile != "" {
+		t.Errorf("dropProfileReferences = %q", dropped)
+	}
+	if profile, watched := config.Watch["/src/old"]; !watched || profile != "" {
+		t.Error("watched directory should stay watched without a profile")
+	}
+	if config.Watch["/src/work"] != "work" {
+		t.Error("another profile's watched directory was changed")
+	}
+}
+
+// TestFindStaleIdentities tests finding repositories that commit as no
+// profile's email and naming the profile they used to belong to
+func TestFindStaleIdentities(t *testing.T) {
+	config := &Config{
+		Profiles: map[string]Profile{
+			"work":     {Email: "jane@acme.io", URLRewrites: map[string]string{"git@github.com:acme/": "git@github.com-work:acme/"}},
+			"personal": {Email: "jane@home.dev"},
+		},
+		Archived: map[string]Profile{"oldjob": {Email: "jane@oldjob.com"}},
+		Settings: Settings{DefaultProfile: "personal"},
+	}
+	state := &ManagedState{Keys: []ManagedKey{
+		{Scope: "local", Repo: "/src/legacy", Key: "user.email", Value: "jane@gone.org", Profile: "gone"},
+		{Scope: "local", Repo: "/src/app", Key: "user.email", Value: "jane@acme.io", Profile: "work"},
+	}}
+	repos := map[string][]string{
+		"/src/app":    {"Jane@Acme.io", "git@github.com:acme/app.git"},
+		"/src/global": {""},
+		"/src/old":    {"jane@oldjob.com", "git@github.com:acme/old.git"},
+		"/src/legacy": {"jane@gone.org"},
+		"/src/fork":   {"someone@else.com", "git@github.com:jane/fork.git"},
+	}
+	inspect := func(path string) (string, []string, bool) {
+		repo, exists := repos[path]
+		if !exists {
+			return "", nil, false
+		}
+		return repo[0], repo[1:], true
+	}
+
+	var got []string
+	for _, stale := range findStaleIdentities(config, state, []string{"/src/app", "/src/global", "/src/old", "/src/legacy", "/src/fork", "/src/missing"}, inspect) {
+		got = append(got, stale.Repo+":"+stale.Former+":"+stale.Suggested)
+	}
+	expected := "/src/old:oldjob:work|/src/legacy:gone:personal|/src/fork::personal"
+	if strings.Join(got, "|") != expected {
+		t.Errorf("findStaleIdentities = %q, expected %q", strings.Join(got, "|"), expected)
+	}
+}
+
+// TestRestoreArchivedProfileErrors tests that restoring a profile that
+// isn't archived, or over an existing one, says why
+func TestRestoreArchivedProfileErrors(t *testing.T) {
+	setupConfigHome(t)
+	config := defaultConfig()
+	config.Archived = map[string]Profile{"work": {Name: "Old Work", Email: "old@work.com"}}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"nobody": "❌ Profile 'nobody' is not archived!",
+		"work":   "❌ Profile 'work' already exists",
+	}
+	for name, expected := range cases {
+		var err error
+		out := captureStdout(t, func() { err = restoreArchivedProfile(name) })
+		if err == nil || strings.HasPrefix(err.Error(), "❌") {
+			t.Errorf("%s: expected a plain error, got %v", name, err)
+		}
+		if !strings.Contains(out, expected) {
+			t.Errorf("%s: expected %q to be printed, got %q", name, expected, out)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// runPushTo pushes to the push remote of the profile matching the current
+// identity, refusing to push when the identity matches no profile
+func runPushTo(args []string) error {
+	inside, err := isInsideWorkTree()
+	if err != nil {
+		return err
+	}
+	if !inside {
+		fmt.Println("❌ Not inside a git repository")
+		return fmt.Errorf("not a git repository")
+	}
+
+	name, email, _ := getCurrentGitConfig()
+	profiles, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	profileName, ok := findProfileByIdentity(profiles, name, email)
+	if name == "" || email == "" {
+		fmt.Println("❌ No identity configured in this repository")
+		fmt.Println("\nSwitch first with: git usr <profile>")
+		return fmt.Errorf("no identity")
+	}
+	if !ok {
+		fmt.Printf("❌ Current identity %s matches no profile\n", formatAddress(name, email))
+		fmt.Println("\nSwitch first with: git usr <profile>")
+		return fmt.Errorf("unknown identity")
+	}
+
+	remote := profiles[profileName].PushRemote
+	if remote == "" {
+		remote = "origin"
+	}
+	if _, err := runGit("", "remote", "get-url", remote); err != nil {
+		fmt.Printf("❌ Remote '%s' of profile '%s' not found\n", remote, profileName)
+		fmt.Printf("\nAdd it with: git remote add %s <url>\n", remote)
+		fmt.Printf("Or change it with: git usr profile set %s pushRemote <remote>\n", profileName)
+		return fmt.Errorf("remote not found")
+	}
+
+	fmt.Printf("🚀 Pushing as '%s' to %s\n", profileName, remote)
+	cmd := exec.Command("git", append([]string{"push", remote}, args...)...)
+	attachTerminal(cmd)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &ExitError{Code: exitErr.ExitCode(), Err: err}
+		}
+		return err
+	}
+	return nil
+}
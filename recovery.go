@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// jsonErrorLocation returns the 1-based line and column a JSON decoding
+// error points at
+func jsonErrorLocation(data []byte, err error) (int, int, bool) {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return 0, 0, false
+	}
+	line, column := offsetLocation(data, offset)
+	if column > 1 {
+		// Offsets point just past the offending byte
+		column--
+	}
+	return line, column, true
+}
+
+// offsetLocation returns the 1-based line and column of a byte offset
+func offsetLocation(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := strings.Count(string(before), "\n") + 1
+	column := int(offset) - strings.LastIndex(string(before), "\n")
+	return line, column
+}
+
+// printParseError shows where the config failed to parse
+func printParseError(configPath string, data []byte, err error) {
+	line, column, ok := jsonErrorLocation(data, err)
+	if !ok {
+		fmt.Printf("❌ Could not parse %s: %v\n", configPath, err)
+		return
+	}
+
+	fmt.Printf("❌ Could not parse %s (line %d, column %d): %v\n", configPath, line, column, err)
+	lines := strings.Split(string(data), "\n")
+	if line <= len(lines) {
+		text := strings.TrimRight(lines[line-1], "\r")
+		fmt.Printf("\n   %s\n   %s^\n", text, strings.Repeat(" ", column-1))
+	}
+}
+
+// promptsDisabled is set by the API server, whose stdin carries requests
+// or belongs to nobody, so nothing may be read from it
+var promptsDisabled bool
+
+// isInteractive reports whether both stdin and stdout are terminals, so a
+// prompt never blocks scripts or shell prompt integrations. Quiet output
+// couldn't show the question
+func isInteractive() bool {
+	if quietOutput || promptsDisabled {
+		return false
+	}
+	for _, f := range []*os.File{os.Stdin, terminalStdout} {
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// moveAside renames a corrupt config out of the way instead of overwriting it
+func moveAside(configPath string) (string, error) {
+	asidePath := configPath + ".corrupt-" + time.Now().Format("20060102-150405")
+	if err := os.Rename(configPath, asidePath); err != nil {
+		return "", err
+	}
+	return asidePath, nil
+}
+
+// editFile opens path in $VISUAL or $EDITOR and waits for it to exit
+func editFile(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// Editors are often configured with arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	attachTerminal(cmd)
+	return cmd.Run()
+}
+
+// readConfigFile reads, decrypts and parses a config file
+func readConfigFile(path string) (*Config, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = decodeConfigData(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := parseConfig(data)
+	return config, data, err
+}
+
+// recoverConfig is called when the config file fails to parse. It shows
+// where, and on a terminal offers to edit the file, restore the backup or
+// start over. The corrupt file is never overwritten; it is moved aside first
+func recoverConfig(configPath string, data []byte, parseErr error) (*Config, error) {
+	printParseError(configPath, data, parseErr)
+
+	backup, hasBackup := latestBackup(configPath)
+
+	if !isInteractive() {
+		fmt.Println("\nFix the file by hand, run 'git usr repair' to salvage what can be read, or run git-usr from a terminal to recover it.")
+		if hasBackup {
+			fmt.Printf("The previous version is in %s\n", backup.Path)
+		}
+		return nil, fmt.Errorf("%w: %w", errConfigCorrupt, parseErr)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Println("\nHow do you want to continue?")
+		fmt.Println("  [e] Edit the file in $EDITOR")
+		if hasBackup {
+			fmt.Println("  [b] Restore the latest backup (" + backup.ID + ")")
+		}
+		fmt.Println("  [s] Salvage what can be read (git usr repair)")
+		fmt.Println("  [r] Start over with the default profiles")
+		fmt.Println("  [q] Quit")
+		fmt.Print("Choice: ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("%w: %w", errConfigCorrupt, parseErr)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "e":
+			if err := editFile(configPath); err != nil {
+				fmt.Printf("❌ Editor failed: %v\n", err)
+				continue
+			}
+			config, edited, err := readConfigFile(configPath)
+			if err == nil {
+				fmt.Println("✅ Config is valid again")
+				return config, nil
+			}
+			data, parseErr = edited, err
+			printParseError(configPath, data, parseErr)
+
+		case "b":
+			if !hasBackup {
+				continue
+			}
+			config, asidePath, err := restoreBackup(configPath, backup)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				continue
+			}
+			fmt.Printf("✅ Restored backup %s (corrupt file kept as %s)\n", backup.ID, asidePath)
+			return config, nil
+
+		case "s":
+			config, lost := salvageConfig(data)
+			for _, what := range lost {
+				fmt.Printf("   Lost: %s\n", what)
+			}
+			asidePath, err := moveAside(configPath)
+			if err != nil {
+				return nil, err
+			}
+			if err := saveConfig(config); err != nil {
+				return nil, err
+			}
+			fmt.Printf("✅ Recovered %d profile(s) (corrupt file kept as %s)\n", len(config.Profiles), asidePath)
+			return config, nil
+
+		case "r":
+			asidePath, err := moveAside(configPath)
+			if err != nil {
+				return nil, err
+			}
+			config := defaultConfig()
+			if err := saveConfig(config); err != nil {
+				return nil, err
+			}
+			fmt.Printf("✅ Created a new config (corrupt file kept as %s)\n", asidePath)
+			return config, nil
+
+		case "q":
+			return nil, fmt.Errorf("%w: %w", errConfigCorrupt, parseErr)
+		}
+	}
+}
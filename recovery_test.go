@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestJSONErrorLocation tests mapping decode errors to line and column
+func TestJSONErrorLocation(t *testing.T) {
+	data := []byte("{\n  \"profiles\": {\n    \"work\": {,\n  }\n}")
+	_, err := parseConfig(data)
+	if err == nil {
+		t.Fatal("Expected parse error")
+	}
+
+	line, column, ok := jsonErrorLocation(data, err)
+	if !ok {
+		t.Fatalf("Expected a location for %v", err)
+	}
+	if line != 3 || column != 14 {
+		t.Errorf("Expected line 3, column 14, got line %d, column %d", line, column)
+	}
+
+	if _, _, ok := jsonErrorLocation(data, errors.New("other")); ok {
+		t.Error("Expected no location for non-JSON errors")
+	}
+}
+
+// TestBackupConfig tests that saving keeps the previous version
+func TestBackupConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "profiles.json")
+	t.Setenv("GIT_USR_CONFIG", configPath)
+
+	first := defaultConfig()
+	if err := saveConfig(first); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+	if backups, _ := listBackups(configPath); len(backups) != 0 {
+		t.Errorf("Expected no backup after the first save, got %v", backups)
+	}
+	original, _ := os.ReadFile(configPath)
+
+	first.Profiles["extra"] = Profile{Name: "Extra", Email: "extra@example.com"}
+	if err := saveConfig(first); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+
+	latest, ok := latestBackup(configPath)
+	if !ok {
+		t.Fatal("Backup missing")
+	}
+	backup, err := os.ReadFile(latest.Path)
+	if err != nil {
+		t.Fatalf("Backup missing: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("Backup does not hold the previous version:\n%s", backup)
+	}
+}
+
+// TestBackupRotation tests that only the newest backups are kept
+func TestBackupRotation(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "profiles.json")
+	t.Setenv("GIT_USR_CONFIG", configPath)
+
+	config := defaultConfig()
+	keep := 3
+	config.Settings.Backups = &keep
+	for i := 0; i < 6; i++ {
+		config.Profiles["p"] = Profile{Name: "P", Email: fmt.Sprintf("p%d@example.com", i)}
+		if err := saveConfig(config); err != nil {
+			t.Fatalf("saveConfig failed: %v", err)
+		}
+	}
+
+	backups, err := listBackups(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != keep {
+		t.Fatalf("Expected %d backups, got %d", keep, len(backups))
+	}
+	// The newest backup holds the save before the last one
+	data, _ := os.ReadFile(backups[len(backups)-1].Path)
+	if !strings.Contains(string(data), "p4@example.com") {
+		t.Errorf("Expected the newest backup to hold the previous save:\n%s", data)
+	}
+
+	found, err := findBackup(configPath, backups[0].ID)
+	if err != nil || found.Path != backups[0].Path {
+		t.Errorf("findBackup(%s) = %v, %v", backups[0].ID, found, err)
+	}
+	if _, err := findBackup(configPath, backups[0].ID[:8]); err == nil {
+		t.Error("Expected a prefix matching several backups to be rejected")
+	}
+	if _, err := findBackup(configPath, "19990101"); err == nil {
+		t.Error("Expected an unknown ID to be rejected")
+	}
+
+	none := 0
+	config.Settings.Backups = &none
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+	if after, _ := listBackups(configPath); len(after) != keep {
+		t.Errorf("Expected no new backup with backups set to 0, got %d", len(after))
+	}
+}
+
+// TestLockEncryptsBackups tests that locking the config leaves no backup
+// holding it in plaintext
+func TestLockEncryptsBackups(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "profiles.json")
+	t.Setenv("GIT_USR_CONFIG", configPath)
+	t.Setenv("GIT_USR_PASSPHRASE", "hunter2")
+	t.Cleanup(func() { configPassphrase, configLocked = "", false })
+
+	config := defaultConfig()
+	config.Profiles["secret"] = Profile{Name: "Jane Doe", Email: "jane@secret.dev"}
+	for i := 0; i < 3; i++ {
+		config.Settings.DefaultProfile = fmt.Sprintf("p%d", i)
+		if err := saveConfig(config); err != nil {
+			t.Fatalf("saveConfig failed: %v", err)
+		}
+	}
+	if err := lockConfig(); err != nil {
+		t.Fatalf("lockConfig failed: %v", err)
+	}
+
+	backups, err := listBackups(configPath)
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("Expected the earlier backups to be kept, got %v (%v)", backups, err)
+	}
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "jane@secret.dev") {
+			t.Errorf("Backup %s holds the config in plaintext", backup.ID)
+		}
+		if describeBackup(backup) != "(encrypted)" {
+			t.Errorf("Expected backup %s to be encrypted", backup.ID)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cleanJSON removes what people commonly add to hand-edited JSON: a byte
+// order mark, // and /* */ comments and trailing commas. Strings are left
+// untouched
+func cleanJSON(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var out []byte
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			// Drop a comma before the closing bracket
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// salvageMembers decodes the members of the JSON object dec is at, one by
+// one, until the end of the object or the first one that can't be read.
+// Objects under nested keys are salvaged member by member too
+func salvageMembers(dec *json.Decoder, nested map[string]bool) (map[string]json.RawMessage, error) {
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	members := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return members, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return members, fmt.Errorf("expected a key")
+		}
+
+		if nested[key] {
+			inner, err := salvageMembers(dec, nil)
+			if len(inner) > 0 {
+				members[key], _ = json.Marshal(inner)
+			}
+			if err != nil {
+				return members, err
+			}
+			continue
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return members, err
+		}
+		members[key] = value
+	}
+	_, err := dec.Token()
+	return members, err
+}
+
+// salvageConfig reads as much of a config that fails to parse as it can:
+// everything up to where it breaks off, skipping profiles and top-level
+// values of the wrong type. lost describes what couldn't be read
+func salvageConfig(data []byte) (*Config, []string) {
+	cleaned := cleanJSON(data)
+	if config, err := parseConfig(cleaned); err == nil {
+		return config, nil
+	}
+
+	var lost []string
+	dec := json.NewDecoder(bytes.NewReader(cleaned))
+	members, err := salvageMembers(dec, map[string]bool{"profiles": true, "archived": true})
+	if err != nil {
+		line, column := offsetLocation(cleaned, dec.InputOffset())
+		lost = append(lost, fmt.Sprintf("everything from line %d, column %d on (%v)", line, column, err))
+	}
+
+	// dropInvalidProfiles drops profiles that have values of the wrong type
+	dropInvalidProfiles := func(profiles map[string]json.RawMessage, kind string) {
+		for _, name := range sortedKeys(profiles) {
+			var profile Profile
+			if err := json.Unmarshal(profiles[name], &profile); err != nil {
+				lost = append(lost, fmt.Sprintf("%s '%s' (%v)", kind, name, err))
+				delete(profiles, name)
+			}
+		}
+	}
+
+	_, hasProfiles := members["profiles"]
+	_, hasSettings := members["settings"]
+	if !hasProfiles && !hasSettings {
+		// The legacy format: a bare map of profiles
+		dropInvalidProfiles(members, "profile")
+	} else {
+		for _, key := range sortedKeys(members) {
+			var profiles map[string]json.RawMessage
+			if (key == "profiles" || key == "archived") && json.Unmarshal(members[key], &profiles) == nil {
+				kind := "profile"
+				if key == "archived" {
+					kind = "archived profile"
+				}
+				dropInvalidProfiles(profiles, kind)
+				members[key], _ = json.Marshal(profiles)
+			}
+			single, _ := json.Marshal(map[string]json.RawMessage{key: members[key]})
+			if err := json.Unmarshal(single, &Config{}); err != nil {
+				lost = append(lost, fmt.Sprintf("'%s' (%v)", key, err))
+				delete(members, key)
+			}
+		}
+	}
+
+	salvaged, _ := json.Marshal(members)
+	config, err := parseConfig(salvaged)
+	if err != nil {
+		return &Config{Profiles: map[string]Profile{}}, lost
+	}
+	return config, lost
+}
+
+// restoreBackup replaces a corrupt config with a backup, moving the
+// corrupt file aside first
+func restoreBackup(configPath string, backup configBackup) (*Config, string, error) {
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	config, _, err := readConfigFile(backup.Path)
+	if err != nil {
+		return nil, "", fmt.Errorf("the backup is not valid either: %w", err)
+	}
+	asidePath, err := moveAside(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := writeFileAtomic(configPath, data, 0600, true); err != nil {
+		return nil, "", err
+	}
+	return config, asidePath, nil
+}
+
+// repairConfig salvages what it can of a config that fails to parse and
+// writes it back, keeping the corrupt file. With fromBackup it restores
+// the backup instead
+func repairConfig(fromBackup, dryRun bool) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	backup, hasBackup := latestBackup(configPath)
+	if fromBackup {
+		if !hasBackup {
+			fmt.Printf("❌ There are no backups of %s\n", configPath)
+			return fmt.Errorf("no backups")
+		}
+		if dryRun {
+			config, _, err := readConfigFile(backup.Path)
+			if err != nil {
+				fmt.Printf("❌ The backup is not valid: %v\n", err)
+				return err
+			}
+			fmt.Printf("Would restore backup %s with %d profile(s): %s\n", backup.ID, len(config.Profiles), strings.Join(sortedProfileNames(config.Profiles), ", "))
+			return nil
+		}
+		_, asidePath, err := restoreBackup(configPath, backup)
+		if err != nil {
+			fmt.Printf("❌ Could not restore backup %s: %v\n", backup.ID, err)
+			return err
+		}
+		fmt.Printf("✅ Restored backup %s (previous file kept as %s)\n", backup.ID, asidePath)
+		return nil
+	}
+
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		fmt.Printf("❌ Could not read %s: %v\n", configPath, err)
+		return err
+	}
+	data, err := decodeConfigData(raw)
+	if err != nil {
+		return err
+	}
+	if _, err := parseConfig(data); err == nil {
+		fmt.Printf("✅ %s is valid, nothing to repair\n", configPath)
+		return nil
+	}
+
+	config, lost := salvageConfig(data)
+	names := sortedProfileNames(config.Profiles)
+	fmt.Printf("🔧 Recovered %d profile(s) from %s: %s\n", len(names), configPath, strings.Join(names, ", "))
+	for _, what := range lost {
+		fmt.Printf("   Lost: %s\n", what)
+	}
+	if previous, _, err := readConfigFile(backup.Path); hasBackup && err == nil {
+		var missing []string
+		for _, name := range sortedProfileNames(previous.Profiles) {
+			if _, ok := config.Profiles[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			fmt.Printf("   Backup %s also has %s; use 'git usr repair --from-backup' to restore it instead\n", backup.ID, strings.Join(missing, ", "))
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+	if isInteractive() {
+		fmt.Print("Write the recovered config? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			fmt.Println("Nothing changed")
+			return nil
+		}
+	}
+
+	// Moved aside first, so the backup of the last good version stays
+	asidePath, err := moveAside(configPath)
+	if err != nil {
+		return err
+	}
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Repaired %s (corrupt file kept as %s)\n", configPath, asidePath)
+	return nil
+}
+
+// runRepair handles the repair command
+func runRepair(args []string) error {
+	usage := "Usage: git usr repair [--from-backup] [--dry-run]"
+
+	fromBackup, dryRun := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--from-backup":
+			fromBackup = true
+		case "--dry-run":
+			dryRun = true
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+	return repairConfig(fromBackup, dryRun)
+}
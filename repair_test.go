@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"a": 1,}`, `{"a": 1}`},
+		{"{\"a\": [1, 2,\n]}", "{\"a\": [1, 2\n]}"},
+		{"{\n  // comment\n  \"a\": 1\n}", "{\n  \n  \"a\": 1\n}"},
+		{`{/* x */"a": 1}`, `{"a": 1}`},
+		{`{"url": "https://example.com/a,}"}`, `{"url": "https://example.com/a,}"}`},
+		{`{"a": "say \"//hi\""}`, `{"a": "say \"//hi\""}`},
+		{"\xef\xbb\xbf{}", "{}"},
+	}
+	for _, tt := range tests {
+		if got := string(cleanJSON([]byte(tt.in))); got != tt.want {
+			t.Errorf("cleanJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSalvageConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		profiles []string
+		lost     int
+		settings bool
+	}{
+		{
+			name:     "comments and trailing commas",
+			data:     "{\"profiles\": {\"work\": {\"name\": \"W\", \"email\": \"w@x.io\",}}, // ok\n\"settings\": {\"defaultScope\": \"global\"}}",
+			profiles: []string{"work"},
+			settings: true,
+		},
+		{
+			name:     "truncated",
+			data:     `{"settings": {"defaultScope": "global"}, "profiles": {"a": {"name": "A", "email": "a@x.io"}, "b": {"name": "B", "em`,
+			profiles: []string{"a"},
+			lost:     1,
+			settings: true,
+		},
+		{
+			name:     "wrong types",
+			data:     `{"profiles": {"a": {"name": "A", "email": "a@x.io"}, "b": {"name": 5}}, "settings": {"defaultScope": 1}}`,
+			profiles: []string{"a"},
+			lost:     2,
+		},
+		{
+			name:     "legacy format",
+			data:     `{"a": {"name": "A", "email": "a@x.io"}, "b": {"name": "B", "email": "b@x.io"}, "c": {"na`,
+			profiles: []string{"a", "b"},
+			lost:     1,
+		},
+		{
+			name: "garbage",
+			data: `not json`,
+			lost: 1,
+		},
+	}
+	for _, tt := range tests {
+		config, lost := salvageConfig([]byte(tt.data))
+		if got := strings.Join(sortedProfileNames(config.Profiles), ","); got != strings.Join(tt.profiles, ",") {
+			t.Errorf("%s: profiles = %s, want %v", tt.name, got, tt.profiles)
+		}
+		if len(lost) != tt.lost {
+			t.Errorf("%s: lost = %q, want %d entries", tt.name, lost, tt.lost)
+		}
+		if got := config.Settings.DefaultScope == "global"; got != tt.settings {
+			t.Errorf("%s: settings kept = %v, want %v", tt.name, got, tt.settings)
+		}
+	}
+}
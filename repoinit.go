@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// templateHookScript is installed as post-checkout in init.templateDir so
+// fresh clones get the default profile. A null previous HEAD means clone.
+var templateHookScript = shellScript("Installed by git-usr: apply the default profile to new clones", `if [ "$1" = "0000000000000000000000000000000000000000" ]; then
+    git usr init --quiet
+fi
+`)
+
+// getScopedGitConfigValue gets a git config value from a single scope
+func getScopedGitConfigValue(scope, key string) string {
+	return gitConfigValue("", scope, key)
+}
+
+// showDefaultProfile prints the default profile
+func showDefaultProfile() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if config.Settings.DefaultProfile == "" {
+		fmt.Println("No default profile set")
+		fmt.Println("\nUse: git usr default <profile>")
+		return nil
+	}
+
+	fmt.Println(config.Settings.DefaultProfile)
+	return nil
+}
+
+// initRepo applies the profile the current repository is pinned to, the
+// one its project file suggests, or the default profile, unless it already
+// has a local identity
+func initRepo(force, quiet bool) error {
+	inside, err := isInsideWorkTree()
+	if err != nil {
+		if quiet {
+			return fmt.Errorf("unsafe repository")
+		}
+		return err
+	}
+	if !inside {
+		if !quiet {
+			fmt.Println("❌ Not inside a git repository")
+		}
+		return fmt.Errorf("not a git repository")
+	}
+
+	if !force && getScopedGitConfigValue("local", "user.email") != "" {
+		if !quiet {
+			fmt.Println("Repository already has a local identity (use --force to replace it)")
+		}
+		return nil
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// A pin names the profile meant for this repository, and a policy the
+	// profiles that are allowed in it
+	profileName, kind := config.Settings.DefaultProfile, "default"
+	if pinned, _ := getPin(""); pinned != "" {
+		if _, exists := config.Profiles[pinned]; exists {
+			profileName, kind = pinned, "pinned"
+		}
+	}
+	if kind == "default" {
+		if policy, _, err := getProjectPolicy(""); err == nil {
+			_, suggested := config.Profiles[policy.SuggestedProfile]
+			if suggested || !policy.allowsEmail(config.Profiles[profileName].Email) {
+				if name := suggestProfile(config.Profiles, policy); name != "" {
+					profileName, kind = name, "suggested"
+				}
+			}
+		}
+	}
+	if profileName == "" {
+		if !quiet {
+			fmt.Println("No default profile set")
+			fmt.Println("\nUse: git usr default <profile>")
+		}
+		return nil
+	}
+
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Default profile '%s' not found!\n", profileName)
+		return errProfileNotFound
+	}
+
+	warnings, err := applyLocalProfile(config, profileName)
+	if err != nil {
+		return err
+	}
+	if !quiet {
+		for _, warning := range warnings {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+	}
+
+	if !quiet {
+		fmt.Printf("✅ Applied %s profile '%s' to this repository\n", kind, profileName)
+		fmt.Printf("   Name:  %s\n", profile.Name)
+		fmt.Printf("   Email: %s\n", profile.Email)
+	}
+	return nil
+}
+
+// applyLocalProfile switches the current repository to a profile like
+// `git usr <profile>` does, without printing anything but what on-switch
+// scripts and plugins print. What fails after the identity is written is
+// returned as warnings
+func applyLocalProfile(config *Config, profileName string) ([]string, error) {
+	profile := config.Profiles[profileName]
+	if err := setGitConfig(profile.Name, profile.Email, "local"); err != nil {
+		return nil, err
+	}
+	return applySwitch(config, profileName, "local", false), nil
+}
+
+// installTemplateHook installs the post-checkout hook into init.templateDir,
+// creating and registering a template directory if none is configured
+func installTemplateHook() error {
+	templateDir := getScopedGitConfigValue("global", "init.templateDir")
+	if templateDir == "" {
+		configDir, err := getConfigDir()
+		if err != nil {
+			return err
+		}
+		templateDir = filepath.Join(configDir, "template")
+		if err := gitConfig.Set("", "global", "init.templateDir", templateDir); err != nil {
+			return err
+		}
+	} else {
+		expanded, err := expandHome(templateDir)
+		if err != nil {
+			return err
+		}
+		templateDir = expanded
+	}
+
+	hooksDir := filepath.Join(templateDir, "hooks")
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return err
+	}
+
+	hookPath := filepath.Join(hooksDir, "post-checkout")
+	if data, err := os.ReadFile(hookPath); err == nil && !isGitUsrHook(data) {
+		fmt.Printf("❌ %s already exists and was not written by git-usr\n", hookPath)
+		fmt.Println("   Add 'git usr init --quiet' to it manually")
+		return fmt.Errorf("hook exists")
+	}
+
+	if err := writeScript(hookPath, templateHookScript); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Installed post-checkout hook in %s\n", hooksDir)
+	fmt.Println("   New clones will get the default profile automatically")
+	fmt.Println("\nFor 'git init', add an alias:")
+	fmt.Println("   git config --global alias.new '!git init && git usr init'")
+	return nil
+}
+
+// runDefault handles the default command
+func runDefault(args []string) error {
+	if len(args) == 0 {
+		return showDefaultProfile()
+	}
+	if args[0] == "--unset" {
+		return setSetting("defaultProfile", "")
+	}
+	return setSetting("defaultProfile", args[0])
+}
+
+// runInit handles the init command
+func runInit(args []string) error {
+	force, quiet := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--force":
+			force = true
+		case "--quiet", "-q":
+			quiet = true
+		case "--install-template":
+			return installTemplateHook()
+		case "--install-hooks":
+			return installBranchHooks()
+		case "--uninstall-hooks":
+			return uninstallBranchHooks()
+		default:
+			fmt.Println("Usage: git usr init [--force] [--quiet] | --install-template | --install-hooks | --uninstall-hooks")
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+
+	return initRepo(force, quiet)
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// reportDays is how many days the heatmap covers
+	reportDays = 56
+	// reportTimelineLimit is how many switches the timeline lists
+	reportTimelineLimit = 200
+)
+
+// reportProfile is a row of the heatmap
+type reportProfile struct {
+	Name     string
+	Email    string
+	LastUsed time.Time
+	Switches int
+	// Levels are 0-4 for each day of the heatmap, by switches that day
+	Levels []int
+	Counts []int
+}
+
+// reportRepo is a repository git-usr has applied a profile to
+type reportRepo struct {
+	Path     string   `json:"path"`
+	Profile  string   `json:"profile"`
+	Email    string   `json:"email"`
+	Expected []string `json:"expected,omitempty"`
+	Problem  string   `json:"problem,omitempty"`
+}
+
+// reportViolation is a policy violation: a lint finding or a repository
+// committing as the wrong identity
+type reportViolation struct {
+	Subject string `json:"subject"`
+	Problem string `json:"problem"`
+	Fix     string `json:"fix"`
+	Check   string `json:"check"`
+}
+
+// reportData is everything the HTML report shows
+type reportData struct {
+	Generated  time.Time
+	Since      time.Time
+	Days       []time.Time
+	Profiles   []reportProfile
+	Timeline   []SwitchRecord
+	Repos      []reportRepo
+	Violations []reportViolation
+}
+
+// repoInspector returns the local user.email and the remote URLs of a
+// repository, or false when it no longer exists
+type repoInspector func(path string) (email string, remotes []string, exists bool)
+
+// inspectRepo is the repoInspector that asks git
+func inspectRepo(path string) (string, []string, bool) {
+	if _, err := os.Stat(path); err != nil {
+		return "", nil, false
+	}
+	return gitConfigValue(path, "local", "user.email"), getRemoteURLs(path), true
+}
+
+// heatLevel buckets a day's switches into the heatmap's five shades
+func heatLevel(count, max int) int {
+	if count == 0 || max == 0 {
+		return 0
+	}
+	// Rounded up, so the busiest day is darkest and any switch shows
+	return (count*4 + max - 1) / max
+}
+
+// profileForEmail returns the profile with an email, if any
+func profileForEmail(profiles map[string]Profile, email string) (string, bool) {
+	for _, name := range sortedProfileNames(profiles) {
+		if strings.EqualFold(profiles[name].Email, email) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// repoIdentityProblem returns what is wrong with the email a repository
+// commits as, given the profiles its remotes belong to, and the check that
+// found it: "identity" when it matches no profile and "remote" when it is
+// another profile's. A missing identity is a problem but no violation
+func repoIdentityProblem(profiles map[string]Profile, email string, expected []string) (problem, check string) {
+	current, known := profileForEmail(profiles, email)
+	switch {
+	case email == "":
+		return "no local identity", ""
+	case !known:
+		return fmt.Sprintf("%s matches no profile", email), "identity"
+	case len(expected) > 0 && !containsString(expected, current):
+		return fmt.Sprintf("commits as '%s' but the remote belongs to '%s'", current, strings.Join(expected, "' or '")), "remote"
+	}
+	return "", ""
+}
+
+// buildReport gathers the report from the config, the managed state and
+// the repositories git-usr has written to
+func buildReport(config *Config, state *ManagedState, now time.Time, inspect repoInspector) reportData {
+	data := reportData{Generated: now}
+	if state.UsageSince != nil {
+		data.Since = *state.UsageSince
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	first := today.AddDate(0, 0, -(reportDays - 1))
+	for i := 0; i < reportDays; i++ {
+		data.Days = append(data.Days, first.AddDate(0, 0, i))
+	}
+
+	// Heatmap: switches per profile and day
+	counts := map[string][]int{}
+	totals := map[string]int{}
+	max := 0
+	for _, record := range state.History {
+		totals[record.Profile]++
+		local := record.Time.In(now.Location())
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, now.Location())
+		// Round, since days around DST changes aren't 24 hours long
+		day := int(math.Round(midnight.Sub(first).Hours() / 24))
+		if day < 0 || day >= reportDays {
+			continue
+		}
+		if counts[record.Profile] == nil {
+			counts[record.Profile] = make([]int, reportDays)
+		}
+		counts[record.Profile][day]++
+		if counts[record.Profile][day] > max {
+			max = counts[record.Profile][day]
+		}
+	}
+
+	names := sortedProfileNames(config.Profiles)
+	for name := range totals {
+		if _, exists := config.Profiles[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		row := reportProfile{Name: name, Email: config.Profiles[name].Email, LastUsed: state.LastUsed[name], Switches: totals[name]}
+		row.Counts = counts[name]
+		if row.Counts == nil {
+			row.Counts = make([]int, reportDays)
+		}
+		for _, count := range row.Counts {
+			row.Levels = append(row.Levels, heatLevel(count, max))
+		}
+		data.Profiles = append(data.Profiles, row)
+	}
+
+	for i := len(state.History) - 1; i >= 0 && len(data.Timeline) < reportTimelineLimit; i-- {
+		data.Timeline = append(data.Timeline, state.History[i])
+	}
+
+	// Repositories: the recorded identity against what they use now
+	recorded := map[string]string{}
+	var repos []string
+	for _, key := range state.Keys {
+		if key.Repo == "" || key.Key != "user.email" {
+			continue
+		}
+		if _, seen := recorded[key.Repo]; !seen {
+			repos = append(repos, key.Repo)
+		}
+		recorded[key.Repo] = key.Profile
+	}
+	for _, path := range repos {
+		repo := reportRepo{Path: path, Profile: recorded[path]}
+		email, remotes, exists := inspect(path)
+		if !exists {
+			repo.Problem = "no longer exists"
+			data.Repos = append(data.Repos, repo)
+			continue
+		}
+		repo.Email = email
+		repo.Expected = expectedProfiles(config.Profiles, remotes)
+
+		var check string
+		repo.Problem, check = repoIdentityProblem(config.Profiles, email, repo.Expected)
+		if check != "" {
+			fix := "run 'git usr <profile>' in the repository"
+			if check == "remote" {
+				fix = fmt.Sprintf("run 'git usr %s' in the repository", repo.Expected[0])
+			}
+			data.Violations = append(data.Violations, reportViolation{
+				Subject: path,
+				Problem: repo.Problem,
+				Fix:     fix,
+				Check:   check,
+			})
+		}
+		data.Repos = append(data.Repos, repo)
+	}
+
+	ctx := lintContext{Config: config, State: state, Now: now, UnusedMonths: 6}
+	for _, check := range lintChecks {
+		for _, finding := range check.check(ctx) {
+			data.Violations = append(data.Violations, reportViolation{
+				Subject: finding.Profile,
+				Problem: finding.Problem,
+				Fix:     finding.Fix,
+				Check:   check.name,
+			})
+		}
+	}
+	return data
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// reportTemplate is the standalone HTML report; it loads nothing from the
+// network so it can be mailed or attached to a ticket
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	},
+	"day":  func(t time.Time) string { return t.Format("Mon 2006-01-02") },
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>git-usr identity report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.5em; } h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #d0d7de; }
+table { border-collapse: collapse; } th, td { text-align: left; padding: 4px 10px; border-bottom: 1px solid #eaeef2; vertical-align: top; }
+.meta { color: #656d76; } .bad { color: #cf222e; } .ok { color: #1a7f37; }
+table.heatmap td, table.heatmap th { border: none; padding: 1px; }
+table.heatmap th.name { padding-right: 10px; white-space: nowrap; }
+.cell { width: 11px; height: 11px; border-radius: 2px; }
+.l0 { background: #ebedf0; } .l1 { background: #9be9a8; } .l2 { background: #40c463; } .l3 { background: #30a14e; } .l4 { background: #216e39; }
+</style>
+</head>
+<body>
+<h1>git-usr identity report</h1>
+<p class="meta">Generated {{date .Generated}}{{if not .Since.IsZero}}, usage recorded since {{date .Since}}{{end}}</p>
+
+<h2>Profile usage</h2>
+<table class="heatmap">
+{{- range $row := .Profiles}}
+<tr><th class="name">{{$row.Name}}</th>{{range $i, $level := $row.Levels}}<td><div class="cell l{{$level}}" title="{{index $.Days $i | day}}: {{index $row.Counts $i}} switch(es)"></div></td>{{end}}</tr>
+{{- end}}
+</table>
+<table>
+<tr><th>Profile</th><th>Email</th><th>Switches</th><th>Last used</th></tr>
+{{- range .Profiles}}
+<tr><td>{{.Name}}</td><td>{{.Email}}</td><td>{{.Switches}}</td><td>{{date .LastUsed}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Policy violations</h2>
+{{- if .Violations}}
+<table>
+<tr><th>Subject</th><th>Problem</th><th>Fix</th><th>Check</th></tr>
+{{- range .Violations}}
+<tr><td>{{.Subject}}</td><td class="bad">{{.Problem}}</td><td>{{.Fix}}</td><td>{{.Check}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="ok">None found</p>
+{{- end}}
+
+<h2>Repositories</h2>
+{{- if .Repos}}
+<table>
+<tr><th>Repository</th><th>Applied profile</th><th>Current email</th><th>Remote belongs to</th><th>Status</th></tr>
+{{- range .Repos}}
+<tr><td>{{.Path}}</td><td>{{.Profile}}</td><td>{{.Email}}</td><td>{{join .Expected ", "}}</td>{{if .Problem}}<td class="bad">{{.Problem}}</td>{{else}}<td class="ok">ok</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="meta">git-usr hasn't applied a profile to a repository yet</p>
+{{- end}}
+
+<h2>Switch timeline</h2>
+{{- if .Timeline}}
+<table>
+<tr><th>Time</th><th>Profile</th><th>Scope</th><th>Repository</th></tr>
+{{- range .Timeline}}
+<tr><td>{{date .Time}}</td><td>{{.Profile}}</td><td>{{.Scope}}</td><td>{{.Repo}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="meta">No switches recorded yet</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeReport renders the HTML report to path
+func writeReport(path string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	data := buildReport(config, state, time.Now(), inspectRepo)
+	var out bytes.Buffer
+	if err := reportTemplate.Execute(&out, data); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Report written to %s\n", path)
+	fmt.Printf("   %d profile(s), %d repositories, %d violation(s)\n", len(data.Profiles), len(data.Repos), len(data.Violations))
+	return nil
+}
+
+// runReport handles the report command
+func runReport(args []string) error {
+	if len(args) == 2 && args[0] == "--html" {
+		return writeReport(args[1])
+	}
+	if len(args) == 1 && strings.HasPrefix(args[0], "--html=") {
+		return writeReport(strings.TrimPrefix(args[0], "--html="))
+	}
+
+	fmt.Println("Usage: git usr report --html <file>")
+	return fmt.Errorf("invalid report command")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHeatLevel tests bucketing switch counts into shades
+func TestHeatLevel(t *testing.T) {
+	tests := []struct{ count, max, level int }{
+		{0, 0, 0},
+		{0, 5, 0},
+		{1, 1, 4},
+		{1, 8, 1},
+		{8, 8, 4},
+		{5, 8, 3},
+	}
+	for _, test := range tests {
+		if got := heatLevel(test.count, test.max); got != test.level {
+			t.Errorf("heatLevel(%d, %d) = %d, expected %d", test.count, test.max, got, test.level)
+		}
+	}
+}
+
+// TestBuildReport tests gathering usage, repositories and violations
+func TestBuildReport(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	config := &Config{Profiles: map[string]Profile{
+		"work":     {Name: "Jane Doe", Email: "jane@acme.io", HostAliases: map[string]string{"github.com": "github.com-work"}},
+		"personal": {Name: "Jane", Email: "jane@home.dev"},
+	}}
+	state := &ManagedState{
+		History: []SwitchRecord{
+			{Time: now.AddDate(0, 0, -100), Profile: "work", Scope: "global"},
+			{Time: now.AddDate(0, 0, -1), Profile: "work", Scope: "local", Repo: "/src/app"},
+			{Time: now, Profile: "personal", Scope: "local", Repo: "/src/blog"},
+			{Time: now, Profile: "old", Scope: "global"},
+		},
+		Keys: []ManagedKey{
+			{Scope: "local", Repo: "/src/app", Key: "user.email", Value: "jane@acme.io", Profile: "work"},
+			{Scope: "local", Repo: "/src/blog", Key: "user.email", Value: "jane@home.dev", Profile: "personal"},
+			{Scope: "local", Repo: "/src/gone", Key: "user.email", Value: "jane@home.dev", Profile: "personal"},
+			{Scope: "global", Key: "user.email", Value: "jane@home.dev", Profile: "personal"},
+		},
+	}
+	repos := map[string][]string{
+		"/src/app":  {"jane@acme.io", "git@github.com-work:acme/app.git"},
+		"/src/blog": {"jane@home.dev", "git@github.com-work:acme/blog.git"},
+	}
+	inspect := func(path string) (string, []string, bool) {
+		repo, exists := repos[path]
+		if !exists {
+			return "", nil, false
+		}
+		return repo[0], repo[1:], true
+	}
+
+	data := buildReport(config, state, now, inspect)
+
+	if len(data.Days) != reportDays || !data.Days[reportDays-1].Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("heatmap days end on %v", data.Days[len(data.Days)-1])
+	}
+	rows := map[string]reportProfile{}
+	for _, row := range data.Profiles {
+		rows[row.Name] = row
+	}
+	if work := rows["work"]; work.Switches != 2 || work.Counts[reportDays-2] != 1 || work.Levels[reportDays-2] != 4 {
+		t.Errorf("work row = %+v", work)
+	}
+	if _, ok := rows["old"]; !ok {
+		t.Error("removed profile with recorded switches missing from the report")
+	}
+	if len(data.Timeline) != 4 || data.Timeline[0].Profile != "old" {
+		t.Errorf("timeline not newest first: %+v", data.Timeline)
+	}
+
+	if len(data.Repos) != 3 {
+		t.Fatalf("repos = %+v", data.Repos)
+	}
+	if data.Repos[0].Problem != "" || data.Repos[2].Problem != "no longer exists" {
+		t.Errorf("repo problems = %q, %q", data.Repos[0].Problem, data.Repos[2].Problem)
+	}
+	if !strings.Contains(data.Repos[1].Problem, "remote belongs to 'work'") {
+		t.Errorf("blog problem = %q", data.Repos[1].Problem)
+	}
+
+	var remote, placeholder bool
+	for _, violation := range data.Violations {
+		remote = remote || violation.Check == "remote" && violation.Subject == "/src/blog"
+		placeholder = placeholder || violation.Check == "placeholder"
+	}
+	if !remote || !placeholder {
+		t.Errorf("violations = %+v", data.Violations)
+	}
+}
+
+// TestReportTemplateEscapes tests that config values can't inject markup
+// into a report meant to be shared
+func TestReportTemplateEscapes(t *testing.T) {
+	config := &Config{Profiles: map[string]Profile{
+		"<script>alert(1)</script>": {Name: "X", Email: "x@y.z"},
+	}}
+	data := buildReport(config, &ManagedState{}, time.Now(), func(string) (string, []string, bool) { return "", nil, false })
+
+	var out bytes.Buffer
+	if err := reportTemplate.Execute(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "<script>alert") {
+		t.Error("profile name not escaped in the report")
+	}
+	if !strings.Contains(out.String(), "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Error("escaped profile name missing from the report")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profileURLRewrites returns the url.<base>.insteadOf rewrites of a profile
+// as prefix -> base, including the SSH forms derived from its host aliases
+func profileURLRewrites(profile Profile) map[string]string {
+	rewrites := map[string]string{}
+
+	for host, alias := range profile.HostAliases {
+		rewrites["git@"+host+":"] = "git@" + alias + ":"
+		rewrites["ssh://git@"+host+"/"] = "ssh://git@" + alias + "/"
+	}
+	for prefix, base := range profile.URLRewrites {
+		rewrites[prefix] = base
+	}
+
+	return rewrites
+}
+
+// sortedKeys returns the keys of m in alphabetical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unsetGitConfigValue removes the exact key/value pair from scope, running
+// in dir (the current directory when empty)
+func unsetGitConfigValue(dir, scope, key, value string) error {
+	return gitConfig.Unset(dir, scope, key, value)
+}
+
+// addGitConfigValue adds key=value to scope unless it is already present
+func addGitConfigValue(scope, key, value string) error {
+	if err := unsetGitConfigValue("", scope, key, value); err != nil {
+		return err
+	}
+	return gitConfig.Add("", scope, key, value)
+}
+
+// applyURLRewrites installs the rewrites of the target profile in scope and
+// removes those belonging to every other profile
+func applyURLRewrites(profiles map[string]Profile, profileName, scope string) (int, error) {
+	target := profileURLRewrites(profiles[profileName])
+
+	for name, profile := range profiles {
+		if name == profileName {
+			continue
+		}
+		for prefix, base := range profileURLRewrites(profile) {
+			// Another profile may share the rewrite; keep it then
+			if target[prefix] == base {
+				continue
+			}
+			if err := unsetGitConfigValue("", scope, "url."+base+".insteadOf", prefix); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	for _, prefix := range sortedKeys(target) {
+		if err := addGitConfigValue(scope, "url."+target[prefix]+".insteadOf", prefix); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(target), nil
+}
+
+// parseURLRewrite parses a PREFIX=BASE profile value
+func parseURLRewrite(value string) (string, string, error) {
+	prefix, base, ok := strings.Cut(value, "=")
+	if !ok || prefix == "" || base == "" {
+		return "", "", fmt.Errorf("urlRewrite must be PREFIX=BASE (e.g. https://github.com/acme/=git@github.com-work:acme/)")
+	}
+	return prefix, base, nil
+}
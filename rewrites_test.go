@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// TestProfileURLRewrites tests rewrites derived from host aliases and urlRewrites
+func TestProfileURLRewrites(t *testing.T) {
+	profile := Profile{
+		HostAliases: map[string]string{"github.com": "github.com-work"},
+		URLRewrites: map[string]string{"https://github.com/acme/": "git@github.com-work:acme/"},
+	}
+
+	expected := map[string]string{
+		"git@github.com:":          "git@github.com-work:",
+		"ssh://git@github.com/":    "ssh://git@github.com-work/",
+		"https://github.com/acme/": "git@github.com-work:acme/",
+	}
+	rewrites := profileURLRewrites(profile)
+	if len(rewrites) != len(expected) {
+		t.Fatalf("Expected %d rewrites, got %v", len(expected), rewrites)
+	}
+	for prefix, base := range expected {
+		if rewrites[prefix] != base {
+			t.Errorf("Expected %s -> %s, got %s", prefix, base, rewrites[prefix])
+		}
+	}
+}
+
+// TestParseURLRewrite tests parsing of PREFIX=BASE values
+func TestParseURLRewrite(t *testing.T) {
+	prefix, base, err := parseURLRewrite("https://github.com/acme/=git@github.com-work:acme/")
+	if err != nil {
+		t.Fatalf("parseURLRewrite failed: %v", err)
+	}
+	if prefix != "https://github.com/acme/" || base != "git@github.com-work:acme/" {
+		t.Errorf("Unexpected result %s -> %s", prefix, base)
+	}
+
+	for _, value := range []string{"", "https://github.com/", "=git@github.com:", "https://github.com/="} {
+		if _, _, err := parseURLRewrite(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
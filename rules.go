@@ -0,0 +1,492 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Rule requires a profile or an email domain for the repositories it
+// matches. Remote, Dir and Branch are globs; a rule matches when all of
+// those set match
+type Rule struct {
+	// Remote matches any remote URL in its host/path form, so
+	// github.com/acme/** covers SSH and HTTPS remotes alike
+	Remote string `json:"remote,omitempty"`
+	// Dir matches the top of the work tree, like includeIf's gitdir
+	Dir string `json:"dir,omitempty"`
+	// Branch matches the branch checked out, like includeIf's onbranch
+	Branch string `json:"branch,omitempty"`
+
+	Profile     string `json:"profile,omitempty"`
+	EmailDomain string `json:"emailDomain,omitempty"`
+}
+
+// ruleFacts are what rules are matched against
+type ruleFacts struct {
+	Dir     string
+	Remotes []string
+	Branch  string
+}
+
+// remoteRuleForm returns a remote URL or pattern as host/path, the form
+// Remote patterns are matched in
+func remoteRuleForm(remote string) string {
+	if strings.Contains(remote, "://") || strings.Contains(remote, ":") {
+		remote = httpsForm(remote)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(remote, "https://"), ".git")
+}
+
+// dirRulePattern expands a Dir pattern like includeIf does with gitdir:
+// ~/ is the home directory and a pattern that isn't absolute can match
+// anywhere
+func dirRulePattern(pattern string) string {
+	if expanded, err := expandHome(pattern); err == nil && strings.HasPrefix(pattern, "~/") {
+		// expandHome cleans the path, which drops a trailing slash
+		if strings.HasSuffix(pattern, "/") {
+			expanded += "/"
+		}
+		pattern = expanded
+	}
+	pattern = filepath.ToSlash(pattern)
+	if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "**") && filepath.VolumeName(pattern) == "" {
+		pattern = "**/" + pattern
+	}
+	return pattern
+}
+
+// matches reports whether the rule applies to a repository
+func (r Rule) matches(facts ruleFacts) bool {
+	if r.Remote == "" && r.Dir == "" && r.Branch == "" {
+		return false
+	}
+	if r.Dir != "" && (facts.Dir == "" || !gitGlobMatch(dirRulePattern(r.Dir), filepath.ToSlash(facts.Dir), false)) {
+		return false
+	}
+	if r.Branch != "" && (facts.Branch == "" || !gitGlobMatch(r.Branch, facts.Branch, false)) {
+		return false
+	}
+	if r.Remote != "" {
+		pattern := remoteRuleForm(r.Remote)
+		for _, remote := range facts.Remotes {
+			if gitGlobMatch(pattern, remoteRuleForm(remote), true) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// requirement describes what the rule requires, as "profile 'work'" or
+// "@corp.com"
+func (r Rule) requirement() string {
+	if r.Profile != "" {
+		return fmt.Sprintf("profile '%s'", r.Profile)
+	}
+	return formatDomains([]string{r.EmailDomain})
+}
+
+// String describes the rule, as "remote github.com/acme/** → @corp.com"
+func (r Rule) String() string {
+	var conditions []string
+	if r.Remote != "" {
+		conditions = append(conditions, "remote "+r.Remote)
+	}
+	if r.Dir != "" {
+		conditions = append(conditions, "dir "+r.Dir)
+	}
+	if r.Branch != "" {
+		conditions = append(conditions, "branch "+r.Branch)
+	}
+	return strings.Join(conditions, " and ") + " → " + r.requirement()
+}
+
+// allows reports whether an identity satisfies the rule
+func (r Rule) allows(profiles map[string]Profile, ident gitIdent) bool {
+	if r.Profile != "" {
+		_, ok := identProfile(profiles, ident, []string{r.Profile})
+		return ok
+	}
+	return emailInDomain(ident.Email, r.EmailDomain)
+}
+
+// ruleProfile returns the profile that satisfies a rule, "" when there is
+// none
+func ruleProfile(profiles map[string]Profile, r Rule) string {
+	if r.Profile != "" {
+		if _, exists := profiles[r.Profile]; exists {
+			return r.Profile
+		}
+		return ""
+	}
+	return suggestProfile(profiles, projectPolicy{EmailDomains: []string{r.EmailDomain}})
+}
+
+// matchingRule returns the first rule that matches, with its number
+// counting from 1, or 0 when none does
+func matchingRule(rules []Rule, facts ruleFacts) (Rule, int) {
+	for i, r := range rules {
+		if r.matches(facts) {
+			return r, i + 1
+		}
+	}
+	return Rule{}, 0
+}
+
+// currentBranch returns the branch checked out in the repository in dir,
+// "" on a detached HEAD
+func currentBranch(dir string) string {
+	gitDir, _, err := findRepository(dir)
+	if err != nil {
+		return ""
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// repoRuleFacts collects what rules match on for the repository in dir
+func repoRuleFacts(dir string) (ruleFacts, error) {
+	top, err := gitConfig.WorkTree(dir)
+	if err != nil {
+		return ruleFacts{}, err
+	}
+	return ruleFacts{Dir: top, Remotes: getRemoteURLs(dir), Branch: currentBranch(dir)}, nil
+}
+
+// brokenRule returns the rule that applies to the repository in dir when
+// the identity doesn't satisfy it, with its number, or 0
+func brokenRule(config *Config, dir, name, email string) (Rule, int) {
+	if len(config.Rules) == 0 {
+		return Rule{}, 0
+	}
+	facts, err := repoRuleFacts(dir)
+	if err != nil {
+		return Rule{}, 0
+	}
+	r, number := matchingRule(config.Rules, facts)
+	if number == 0 || r.allows(config.Profiles, gitIdent{Role: "author", Name: name, Email: email}) {
+		return Rule{}, 0
+	}
+	return r, number
+}
+
+// listRules prints the rules in the order they are tried
+func listRules(config *Config) error {
+	if len(config.Rules) == 0 {
+		fmt.Println("No rules")
+		fmt.Println("\nUse: git usr rules add --remote|--dir|--branch <glob> --profile <profile>|--domain <domain>")
+		return nil
+	}
+
+	fmt.Println("\n📏 Rules (the first that matches applies):")
+	fmt.Println("--------------------------------------------------")
+	for i, r := range config.Rules {
+		fmt.Printf("   %d. %s\n", i+1, r)
+		if _, exists := config.Profiles[r.Profile]; r.Profile != "" && !exists {
+			fmt.Printf("      ⚠️  You have no profile named '%s'\n", r.Profile)
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+// parseRuleFlags reads --remote, --dir, --branch, --profile, --domain and
+// --email flags with their values
+func parseRuleFlags(args []string, allowed ...string) (map[string]string, error) {
+	values := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		flag := strings.TrimPrefix(args[i], "--")
+		if !strings.HasPrefix(args[i], "--") || !containsString(allowed, flag) {
+			return nil, fmt.Errorf("unexpected argument: %s", args[i])
+		}
+		if i+1 >= len(args) || args[i+1] == "" {
+			return nil, fmt.Errorf("--%s requires a value", flag)
+		}
+		values[flag] = args[i+1]
+		i++
+	}
+	return values, nil
+}
+
+// addRule appends a rule to the config
+func addRule(args []string) error {
+	usage := "Usage: git usr rules add [--remote <glob>] [--dir <glob>] [--branch <glob>] --profile <profile>|--domain <domain>"
+	values, err := parseRuleFlags(args, "remote", "dir", "branch", "profile", "domain")
+	if err != nil {
+		fmt.Println(usage)
+		return err
+	}
+	r := Rule{
+		Remote:      values["remote"],
+		Dir:         values["dir"],
+		Branch:      values["branch"],
+		Profile:     values["profile"],
+		EmailDomain: strings.TrimPrefix(values["domain"], "@"),
+	}
+	if r.Remote == "" && r.Dir == "" && r.Branch == "" {
+		fmt.Println("❌ A rule needs --remote, --dir or --branch")
+		fmt.Println(usage)
+		return fmt.Errorf("no condition")
+	}
+	if (r.Profile == "") == (r.EmailDomain == "") {
+		fmt.Println("❌ A rule needs either --profile or --domain")
+		fmt.Println(usage)
+		return fmt.Errorf("no requirement")
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Profiles[r.Profile]; r.Profile != "" && !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", r.Profile)
+		fmt.Println("\nAvailable profiles:", getProfileNames(config.Profiles))
+		return errProfileNotFound
+	}
+
+	config.Rules = append(config.Rules, r)
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Added rule %d: %s\n", len(config.Rules), r)
+	return nil
+}
+
+// removeRule removes a rule by its number
+func removeRule(arg string) error {
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(arg)
+	if err != nil || number < 1 || number > len(config.Rules) {
+		fmt.Printf("❌ No rule %s (see 'git usr rules list')\n", arg)
+		return fmt.Errorf("no rule %s", arg)
+	}
+
+	removed := config.Rules[number-1]
+	config.Rules = append(config.Rules[:number-1], config.Rules[number:]...)
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Removed rule %d: %s\n", number, removed)
+	return nil
+}
+
+// testRules shows which rule applies to the current repository, or to the
+// remote, directory and branch given, and whether the identity satisfies it
+func testRules(args []string) error {
+	values, err := parseRuleFlags(args, "remote", "dir", "branch", "email")
+	if err != nil {
+		fmt.Println("Usage: git usr rules test [--remote <url>] [--dir <path>] [--branch <name>] [--email <email>]")
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Start from the current repository, if there is one
+	facts, err := repoRuleFacts("")
+	if isUnsafeRepository(err) {
+		return err
+	}
+	inRepo := err == nil
+	name, email := "", ""
+	if inRepo {
+		name, email = getIdentityIn("")
+	}
+	if dir, ok := values["dir"]; ok {
+		if dir, err = expandHome(dir); err != nil {
+			return err
+		}
+		if facts.Dir, err = filepath.Abs(dir); err != nil {
+			return err
+		}
+	}
+	if remote, ok := values["remote"]; ok {
+		facts.Remotes = []string{remote}
+	}
+	if branch, ok := values["branch"]; ok {
+		facts.Branch = branch
+	}
+	if value, ok := values["email"]; ok {
+		name, email = "", value
+	}
+
+	fmt.Println("\n🧪 Testing rules against:")
+	fmt.Printf("   Directory: %s\n", orNone(facts.Dir))
+	fmt.Printf("   Remote:    %s\n", orNone(strings.Join(facts.Remotes, ", ")))
+	fmt.Printf("   Branch:    %s\n", orNone(facts.Branch))
+	fmt.Printf("   Email:     %s\n", orNone(email))
+	fmt.Println("--------------------------------------------------")
+
+	r, number := matchingRule(config.Rules, facts)
+	for i, rule := range config.Rules {
+		mark := "  "
+		if i+1 == number {
+			mark = "→ "
+		}
+		fmt.Printf(" %s%d. %s\n", mark, i+1, rule)
+	}
+	if number == 0 {
+		fmt.Println("\nNo rule applies")
+		return nil
+	}
+
+	fmt.Printf("\nRule %d applies: commits must be made as %s\n", number, r.requirement())
+	switch {
+	case email == "":
+		if suggested := ruleProfile(config.Profiles, r); suggested != "" {
+			fmt.Printf("   'git usr switch --auto' would switch to '%s'\n", suggested)
+		}
+	case r.allows(config.Profiles, gitIdent{Role: "author", Name: name, Email: email}):
+		fmt.Printf("✅ %s satisfies it\n", email)
+	default:
+		fmt.Printf("❌ %s breaks it", email)
+		if suggested := ruleProfile(config.Profiles, r); suggested != "" {
+			fmt.Printf(" ('git usr switch --auto' would switch to '%s')", suggested)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// orNone returns s, or "(none)" when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// runRules handles the rules command
+func runRules(args []string) error {
+	usage := "Usage: git usr rules [list] | add <conditions> <requirement> | remove <n> | test [<facts>]"
+	if len(args) == 0 || len(args) == 1 && args[0] == "list" {
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		return listRules(config)
+	}
+	switch args[0] {
+	case "add":
+		return addRule(args[1:])
+	case "remove":
+		if len(args) == 2 {
+			return removeRule(args[1])
+		}
+	case "test":
+		return testRules(args[1:])
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("unknown rules command: %s", strings.Join(args, " "))
+}
+
+// autoProfile picks the profile for the current repository: the one the
+// first matching rule asks for, the pinned one, the one the project file
+// suggests, or the only profile its remotes belong to. It returns what
+// picked it too
+func autoProfile(config *Config) (string, string, error) {
+	facts, err := repoRuleFacts("")
+	if err != nil {
+		return "", "", err
+	}
+	if r, number := matchingRule(config.Rules, facts); number != 0 {
+		return ruleProfile(config.Profiles, r), fmt.Sprintf("rule %d: %s", number, r), nil
+	}
+	if pinned, source := getPin(""); pinned != "" {
+		if _, exists := config.Profiles[pinned]; exists {
+			return pinned, "pinned in " + source, nil
+		}
+	}
+	if policy, file, err := getProjectPolicy(""); err == nil {
+		if suggested := suggestProfile(config.Profiles, policy); suggested != "" {
+			return suggested, "suggested by " + file, nil
+		}
+	}
+	if expected := expectedProfiles(config.Profiles, facts.Remotes); len(expected) == 1 {
+		return expected[0], "the remote belongs to it", nil
+	}
+	return "", "", nil
+}
+
+// runSwitchSubcommand handles the switch command, the explicit spelling of
+// `git usr <profile>` that can also pick the profile with --auto
+func runSwitchSubcommand(args []string) error {
+	usage := "Usage: git usr switch <profile>|--auto [--global|--local] [--exact]"
+	auto := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--auto" {
+			auto = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	if !auto {
+		if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+			fmt.Println("❌ Profile name required!")
+			fmt.Println(usage)
+			return fmt.Errorf("profile name required")
+		}
+		return runSwitchCommand(rest[0], rest[1:])
+	}
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		fmt.Println(usage)
+		return fmt.Errorf("--auto takes no profile")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profileName, reason, err := autoProfile(config)
+	if isUnsafeRepository(err) {
+		return err
+	}
+	if err != nil {
+		fmt.Println("❌ Not inside a git repository")
+		return err
+	}
+	if profileName == "" {
+		if reason != "" {
+			fmt.Printf("❌ None of your profiles satisfies %s\n", reason)
+			return fmt.Errorf("no profile satisfies %s", reason)
+		}
+		fmt.Println("❌ Nothing says which profile this repository needs")
+		fmt.Println("\nUse: git usr rules add, or git usr pin <profile>")
+		return fmt.Errorf("no profile for this repository")
+	}
+	fmt.Printf("🔎 Picked '%s' (%s)\n", profileName, reason)
+	// What applies to this repository goes into its own config unless
+	// asked otherwise
+	if !containsString(rest, "--global") && !containsString(rest, "--local") {
+		rest = append(rest, "--local")
+	}
+	return runSwitchCommand(profileName, append(rest, "--exact"))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestRuleMatches tests matching remotes, directories and branches
+func TestRuleMatches(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	facts := ruleFacts{
+		Dir:     filepath.Join(home, "src", "acme", "app"),
+		Remotes: []string{"git@github.com:Acme/app.git"},
+		Branch:  "release/1.2",
+	}
+
+	tests := []struct {
+		rule Rule
+		want bool
+	}{
+		{Rule{Remote: "github.com/acme/**"}, true},
+		{Rule{Remote: "github.com/acme/*"}, true},
+		{Rule{Remote: "https://github.com/acme/app.git"}, true},
+		{Rule{Remote: "git@github.com:acme/*"}, true},
+		{Rule{Remote: "gitlab.com/**"}, false},
+		{Rule{Remote: "github.com/*"}, false},
+		{Rule{Dir: "~/src/"}, true},
+		{Rule{Dir: "~/src/*"}, false},
+		{Rule{Dir: "acme/"}, true},
+		{Rule{Dir: "~/oss/"}, false},
+		{Rule{Branch: "release/*"}, true},
+		{Rule{Branch: "release"}, false},
+		{Rule{Remote: "github.com/acme/**", Branch: "main"}, false},
+		{Rule{Remote: "github.com/acme/**", Dir: "~/src/", Branch: "release/**"}, true},
+		{Rule{Profile: "work"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.matches(facts); got != tt.want {
+			t.Errorf("%+v matches = %v, expected %v", tt.rule, got, tt.want)
+		}
+	}
+
+	// Outside a repository, only rules without a directory can match
+	if (Rule{Dir: "/"}).matches(ruleFacts{}) {
+		t.Error("Expected a dir rule not to match without a directory")
+	}
+}
+
+// TestMatchingRule tests that the first matching rule applies
+func TestMatchingRule(t *testing.T) {
+	rules := []Rule{
+		{Branch: "oss/*", EmailDomain: "home.dev"},
+		{Remote: "github.com/acme/**", Profile: "work"},
+		{Remote: "github.com/**", EmailDomain: "home.dev"},
+	}
+	tests := []struct {
+		facts ruleFacts
+		want  int
+	}{
+		{ruleFacts{Remotes: []string{"https://github.com/acme/app"}, Branch: "main"}, 2},
+		{ruleFacts{Remotes: []string{"https://github.com/acme/app"}, Branch: "oss/x"}, 1},
+		{ruleFacts{Remotes: []string{"https://github.com/jane/app"}}, 3},
+		{ruleFacts{Remotes: []string{"https://gitlab.com/jane/app"}}, 0},
+	}
+	for _, tt := range tests {
+		if _, got := matchingRule(rules, tt.facts); got != tt.want {
+			t.Errorf("matchingRule(%+v) = %d, expected %d", tt.facts, got, tt.want)
+		}
+	}
+}
+
+// TestRuleAllows tests profile and domain requirements
+func TestRuleAllows(t *testing.T) {
+	profiles := map[string]Profile{
+		"home": {Name: "Jane", Email: "jane@home.dev"},
+		"work": {Name: "Jane Doe", Email: "jane@corp.com"},
+	}
+	tests := []struct {
+		rule  Rule
+		ident gitIdent
+		want  bool
+	}{
+		{Rule{Profile: "work"}, gitIdent{Role: "author", Name: "Jane Doe", Email: "jane@corp.com"}, true},
+		{Rule{Profile: "work"}, gitIdent{Role: "author", Name: "Jane", Email: "jane@home.dev"}, false},
+		{Rule{EmailDomain: "corp.com"}, gitIdent{Role: "author", Email: "j@eu.corp.com"}, true},
+		{Rule{EmailDomain: "corp.com"}, gitIdent{Role: "author", Email: "j@home.dev"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.allows(profiles, tt.ident); got != tt.want {
+			t.Errorf("%+v allows %+v = %v, expected %v", tt.rule, tt.ident, got, tt.want)
+		}
+	}
+
+	if got := ruleProfile(profiles, Rule{EmailDomain: "home.dev"}); got != "home" {
+		t.Errorf("ruleProfile = %q, expected home", got)
+	}
+	if got := ruleProfile(profiles, Rule{Profile: "gone"}); got != "" {
+		t.Errorf("ruleProfile = %q for a missing profile, expected none", got)
+	}
+}
+
+// TestRuleString tests how rules are shown
+func TestRuleString(t *testing.T) {
+	r := Rule{Remote: "github.com/acme/**", Branch: "main", Profile: "work"}
+	if got, want := r.String(), "remote github.com/acme/** and branch main → profile 'work'"; got != want {
+		t.Errorf("String() = %q, expected %q", got, want)
+	}
+	r = Rule{Dir: "~/oss/", EmailDomain: "home.dev"}
+	if got, want := r.String(), "dir ~/oss/ → @home.dev"; got != want {
+		t.Errorf("String() = %q, expected %q", got, want)
+	}
+}
+
+// TestParseRuleFlags tests reading flags with values
+func TestParseRuleFlags(t *testing.T) {
+	values, err := parseRuleFlags([]string{"--remote", "github.com/*", "--profile", "work"}, "remote", "profile")
+	if err != nil || values["remote"] != "github.com/*" || values["profile"] != "work" {
+		t.Errorf("parseRuleFlags = %v, %v", values, err)
+	}
+	for _, args := range [][]string{{"--remote"}, {"--dir", "x"}, {"work"}, {"--remote", ""}} {
+		if _, err := parseRuleFlags(args, "remote", "profile"); err == nil {
+			t.Errorf("parseRuleFlags(%q) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	profileStores["s3"] = newS3Store
+}
+
+// s3Credentials are the AWS credentials requests are signed with
+type s3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// newS3Store keeps the profiles in an object of S3 or S3-compatible
+// storage such as MinIO or Ceph, e.g. storeURL "s3://bucket/git-usr.json".
+// AWS_ENDPOINT_URL points it at a server other than AWS
+func newS3Store(location string) (ProfileStore, error) {
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Scheme != "s3" || parsed.Host == "" || strings.Trim(parsed.Path, "/") == "" {
+		return nil, fmt.Errorf("storeURL must be s3://<bucket>/<key> for the s3 store")
+	}
+	bucket, key := parsed.Host, strings.TrimPrefix(parsed.Path, "/")
+
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	objectURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, key)
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		// Other servers are addressed by path rather than by host name
+		objectURL = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + key
+	}
+
+	store, err := newRemoteFileStore("s3", objectURL, func(req *http.Request, body []byte) error {
+		creds, err := loadS3Credentials()
+		if err != nil {
+			return err
+		}
+		signS3Request(req, body, creds, region, time.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// loadS3Credentials reads the credentials from AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY, or else the AWS_PROFILE (or default) section of
+// the shared credentials file
+func loadS3Credentials() (s3Credentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return s3Credentials{id, os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return s3Credentials{}, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	section := os.Getenv("AWS_PROFILE")
+	if section == "" {
+		section = "default"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return s3Credentials{}, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or add them to %s", path)
+	}
+	defer f.Close()
+
+	var creds s3Credentials
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current != section {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if creds.AccessKeyID == "" {
+		return s3Credentials{}, fmt.Errorf("no AWS credentials for profile '%s' in %s", section, path)
+	}
+	return creds, scanner.Err()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes each segment of a path the way S3 signatures
+// expect
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+	return strings.Join(segments, "/")
+}
+
+// signS3Request signs a request with AWS Signature Version 4, covering the
+// host and every header already set on it
+func signS3Request(req *http.Request, body []byte, creds s3Credentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), amzDate[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
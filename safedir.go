@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+)
+
+// dubiousOwnershipPattern matches git's refusal to use a repository owned
+// by another user (CVE-2022-24765)
+var dubiousOwnershipPattern = regexp.MustCompile(`dubious ownership in repository at '([^']+)'`)
+
+// UnsafeRepositoryError reports a repository git refuses to use because it
+// is owned by someone else and not listed in safe.directory
+type UnsafeRepositoryError struct {
+	Path string
+}
+
+func (e *UnsafeRepositoryError) Error() string {
+	return fmt.Sprintf("repository at %s is owned by another user", e.Path)
+}
+
+// runGit runs git in dir (the current directory when empty) and returns its
+// output, turning dubious-ownership failures into an UnsafeRepositoryError
+func runGit(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if m := dubiousOwnershipPattern.FindStringSubmatch(stderr.String()); m != nil {
+			return "", &UnsafeRepositoryError{Path: m[1]}
+		}
+		// Some commands (e.g. git config --local) only report "not a git
+		// repository"; ask rev-parse whether ownership is the real cause
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 128 && !(len(args) > 0 && args[0] == "rev-parse") {
+			if _, probeErr := runGit(dir, "rev-parse", "--git-dir"); isUnsafeRepository(probeErr) {
+				return "", probeErr
+			}
+		}
+	}
+	return string(out), err
+}
+
+// isUnsafeRepository reports whether err is caused by dubious ownership
+func isUnsafeRepository(err error) bool {
+	var unsafeErr *UnsafeRepositoryError
+	return errors.As(err, &unsafeErr)
+}
+
+// printUnsafeGuidance explains how to trust a repository git refused to use
+func printUnsafeGuidance(err error) {
+	var unsafeErr *UnsafeRepositoryError
+	if !errors.As(err, &unsafeErr) {
+		return
+	}
+	fmt.Printf("❌ Git refuses to use %s: it is owned by another user\n", unsafeErr.Path)
+	fmt.Println("   If you trust it, mark it as safe with:")
+	fmt.Printf("   git config --global --add safe.directory %s\n", unsafeErr.Path)
+}
@@ -0,0 +1,69 @@
+package main
+
+import "strings"
+
+// crlfShim lets a generated sh script survive being converted to CRLF, e.g.
+// by core.autocrlf on Windows: if the file contains carriage returns it
+// re-runs itself with them stripped. The trailing '#' keeps the CR of this
+// line inside a comment.
+const crlfShim = `if [ -z "$GIT_USR_NO_CR" ] && grep -q "$(printf '\r')" "$0"; then export GIT_USR_NO_CR=1; exec sh -c "$(tr -d '\r' < "$0")" "$0" "$@"; fi #
+`
+
+// shellScript assembles a POSIX sh script from a comment and a body,
+// prefixed with the shebang and the CRLF shim
+func shellScript(comment, body string) string {
+	return "#!/bin/sh\n# " + comment + "\n" + crlfShim + body
+}
+
+// normalizeLF converts CRLF and lone CR line endings to LF
+func normalizeLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+// writeScript writes an executable script with LF line endings. The mode is
+// always set, since os.WriteFile keeps the mode of an existing file and a
+// hook without the executable bit is silently ignored by git
+func writeScript(path, content string) error {
+	return writeFileAtomic(path, []byte(normalizeLF(content)), 0755, true)
+}
+
+// linesOutsideBlock returns the lines of a file outside the block between
+// the begin and end markers, without trailing blank lines
+func linesOutsideBlock(existing, begin, end string) []string {
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(normalizeLF(existing), "\n") {
+		switch {
+		case line == begin:
+			inBlock = true
+		case line == end:
+			inBlock = false
+		case !inBlock:
+			kept = append(kept, line)
+		}
+	}
+	for len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+		kept = kept[:len(kept)-1]
+	}
+	return kept
+}
+
+// replaceMarkedBlock replaces the block between the begin and end markers
+// with lines, placing it at the end of the file after a blank line. No
+// lines removes the block
+func replaceMarkedBlock(existing, begin, end string, lines []string) string {
+	kept := linesOutsideBlock(existing, begin, end)
+	if len(lines) > 0 {
+		if len(kept) > 0 {
+			kept = append(kept, "")
+		}
+		kept = append(kept, begin)
+		kept = append(kept, lines...)
+		kept = append(kept, end)
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return strings.Join(kept, "\n") + "\n"
+}
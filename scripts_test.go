@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNormalizeLF tests line ending normalization
+func TestNormalizeLF(t *testing.T) {
+	cases := map[string]string{
+		"a\nb\n":     "a\nb\n",
+		"a\r\nb\r\n": "a\nb\n",
+		"a\rb\r":     "a\nb\n",
+	}
+	for input, expected := range cases {
+		if got := normalizeLF(input); got != expected {
+			t.Errorf("normalizeLF(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+// TestShellScript tests the layout of generated scripts
+func TestShellScript(t *testing.T) {
+	script := shellScript("Installed by git-usr", "echo hi\n")
+	if !strings.HasPrefix(script, "#!/bin/sh\n# Installed by git-usr\n") {
+		t.Errorf("Unexpected header:\n%s", script)
+	}
+	if !strings.Contains(script, crlfShim) || !strings.HasSuffix(script, "echo hi\n") {
+		t.Errorf("Shim or body missing:\n%s", script)
+	}
+	if strings.Contains(script, "\r") {
+		t.Error("Generated script contains carriage returns")
+	}
+}
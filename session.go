@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionHooks are the shell snippets printed by `git usr session hook`.
+// They note when the shell started and report the switches since then
+// when it exits. bash has a single EXIT trap, so its snippet runs the one
+// set before it after the report instead of replacing it
+var sessionHooks = map[string]string{
+	"bash": `__git_usr_session_start=$(date +%s)
+__git_usr_session_report() { git-usr session report --since "$__git_usr_session_start"; }
+__git_usr_session_exit() {
+    local status=$?
+    __git_usr_session_report
+    (exit "$status")
+    eval "$__git_usr_session_prev_exit"
+}
+if [[ $(trap -p EXIT) != *__git_usr_session_exit* ]]; then
+    __git_usr_session_prev_exit=$(trap -p EXIT)
+    __git_usr_session_prev_exit=${__git_usr_session_prev_exit#"trap -- "}
+    eval "__git_usr_session_prev_exit=${__git_usr_session_prev_exit%" EXIT"}"
+    trap __git_usr_session_exit EXIT
+fi
+`,
+	"zsh": `__git_usr_session_start=$(date +%s)
+__git_usr_session_report() { git-usr session report --since "$__git_usr_session_start" }
+autoload -U add-zsh-hook && add-zsh-hook zshexit __git_usr_session_report
+`,
+	"fish": `set -g __git_usr_session_start (date +%s)
+function __git_usr_session_report --on-event fish_exit
+    git-usr session report --since $__git_usr_session_start
+end
+`,
+}
+
+// sessionSummary returns a one-line summary of the switches recorded
+// since a shell started and of what they left behind: the profile the
+// global identity now belongs to, when it was switched, and repositories
+// switched to a profile their remotes don't belong to. It is "" when
+// nothing was switched. globalEmail is the current global user.email
+func sessionSummary(config *Config, state *ManagedState, since time.Time, inspect repoInspector, globalEmail string) string {
+	var switches []SwitchRecord
+	for _, record := range state.History {
+		if !record.Time.Before(since) {
+			switches = append(switches, record)
+		}
+	}
+	if len(switches) == 0 {
+		return ""
+	}
+
+	var trail []string
+	var repos []string
+	global := false
+	for _, record := range switches {
+		if len(trail) == 0 || trail[len(trail)-1] != record.Profile {
+			trail = append(trail, record.Profile)
+		}
+		if record.Scope == "global" {
+			global = true
+		} else if record.Repo != "" && !containsString(repos, record.Repo) {
+			repos = append(repos, record.Repo)
+		}
+	}
+
+	parts := []string{fmt.Sprintf("git-usr: %d switch(es) this session (%s)", len(switches), strings.Join(trail, " → "))}
+	if global {
+		if name, ok := profileForEmail(config.Profiles, globalEmail); ok {
+			parts = append(parts, fmt.Sprintf("global identity is '%s'", name))
+		} else if globalEmail != "" {
+			parts = append(parts, fmt.Sprintf("global identity is %s, which matches no profile", globalEmail))
+		} else {
+			parts = append(parts, "no global identity")
+		}
+	}
+
+	for _, path := range repos {
+		email, remotes, exists := inspect(path)
+		if !exists {
+			continue
+		}
+		if problem, check := repoIdentityProblem(config.Profiles, email, expectedProfiles(config.Profiles, remotes)); check != "" {
+			parts = append(parts, fmt.Sprintf("⚠️  %s %s", path, problem))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// reportSession prints the session summary for switches since a time
+func reportSession(since time.Time) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	globalEmail := getScopedGitConfigValue("global", "user.email")
+	if summary := sessionSummary(config, state, since, inspectRepo, globalEmail); summary != "" {
+		fmt.Println(summary)
+	}
+	return nil
+}
+
+// runSession handles the session command
+func runSession(args []string) error {
+	usage := "Usage: git usr session hook bash|zsh|fish | report --since <unix time>"
+
+	switch {
+	case len(args) == 2 && args[0] == "hook":
+		hook, ok := sessionHooks[args[1]]
+		if !ok {
+			fmt.Println(usage)
+			return fmt.Errorf("unsupported shell: %s", args[1])
+		}
+		fmt.Print(hook)
+		return nil
+	case len(args) == 3 && args[0] == "report" && args[1] == "--since":
+		seconds, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			fmt.Println(usage)
+			return fmt.Errorf("invalid --since: %s", args[2])
+		}
+		return reportSession(time.Unix(seconds, 0))
+	}
+
+	fmt.Println(usage)
+	return fmt.Errorf("invalid session command")
+}
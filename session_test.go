@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessionSummary tests summarizing the switches of a shell session
+func TestSessionSummary(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	config := &Config{Profiles: map[string]Profile{
+		"work":     {Name: "Jane Doe", Email: "jane@acme.io", HostAliases: map[string]string{"github.com": "github.com-work"}},
+		"personal": {Name: "Jane Doe", Email: "jane@home.dev"},
+	}}
+	state := &ManagedState{History: []SwitchRecord{
+		{Time: start.Add(-time.Hour), Profile: "work", Scope: "global"},
+		{Time: start.Add(time.Minute), Profile: "personal", Scope: "local", Repo: "/src/app"},
+		{Time: start.Add(2 * time.Minute), Profile: "personal", Scope: "global"},
+		{Time: start.Add(3 * time.Minute), Profile: "work", Scope: "local", Repo: "/src/lib"},
+	}}
+	repos := map[string][]string{
+		"/src/app": {"jane@home.dev", "git@github.com-work:acme/app.git"},
+		"/src/lib": {"jane@acme.io", "git@github.com-work:acme/lib.git"},
+	}
+	inspect := func(path string) (string, []string, bool) {
+		repo, exists := repos[path]
+		if !exists {
+			return "", nil, false
+		}
+		return repo[0], repo[1:], true
+	}
+
+	summary := sessionSummary(config, state, start, inspect, "jane@home.dev")
+	for _, part := range []string{
+		"3 switch(es) this session (personal → work)",
+		"global identity is 'personal'",
+		"/src/app commits as 'personal' but the remote belongs to 'work'",
+	} {
+		if !strings.Contains(summary, part) {
+			t.Errorf("summary %q missing %q", summary, part)
+		}
+	}
+	if strings.Contains(summary, "/src/lib") || strings.Contains(summary, "\n") {
+		t.Errorf("summary = %q", summary)
+	}
+
+	if summary := sessionSummary(config, state, start.Add(time.Hour), inspect, ""); summary != "" {
+		t.Errorf("summary without switches = %q", summary)
+	}
+}
+
+// TestBashSessionHookChainsTrap tests that the bash hook keeps an EXIT
+// trap set before it, and the exit status it sees, and reports only once
+// when loaded twice
+func TestBashSessionHookChainsTrap(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	script := `git-usr() { echo report; }
+trap 'echo "user $?"' EXIT
+` + sessionHooks["bash"] + sessionHooks["bash"] + "exit 3\n"
+	out, err := exec.Command("bash", "-c", script).Output()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("Expected exit status 3, got %v", err)
+	}
+	if string(out) != "report\nuser 3\n" {
+		t.Errorf("Expected the report followed by the user's trap, got %q", out)
+	}
+}
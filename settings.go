@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Settings holds user preferences persisted alongside the profiles
+type Settings struct {
+	DefaultScope         string `json:"defaultScope,omitempty"`
+	Emoji                *bool  `json:"emoji,omitempty"`
+	Color                string `json:"color,omitempty"`
+	DefaultProfile       string `json:"defaultProfile,omitempty"`
+	NpmSync              string `json:"npmSync,omitempty"`
+	SigningRequiredHosts string `json:"signingRequiredHosts,omitempty"`
+	Store                string `json:"store,omitempty"`
+	StoreURL             string `json:"storeURL,omitempty"`
+	PromptFormat         string `json:"promptFormat,omitempty"`
+	Backups              *int   `json:"backups,omitempty"`
+	UpdateCheck          bool   `json:"updateCheck,omitempty"`
+	Interop              string `json:"interop,omitempty"`
+}
+
+// setting describes a single key of the settings section
+type setting struct {
+	description string
+	get         func(s *Settings) string
+	set         func(c *Config, value string) error
+}
+
+// settingKeys are the keys accepted by `git usr config`
+var settingKeys = map[string]setting{
+	"defaultScope": {
+		description: "Scope used when switching without --global to a profile without its own scope (local|global)",
+		get: func(s *Settings) string {
+			if s.DefaultScope == "" {
+				return "local"
+			}
+			return s.DefaultScope
+		},
+		set: func(c *Config, value string) error {
+			if value != "local" && value != "global" {
+				return fmt.Errorf("defaultScope must be 'local' or 'global'")
+			}
+			c.Settings.DefaultScope = value
+			return nil
+		},
+	},
+	"emoji": {
+		description: "Decorate output with emoji (true|false, false is the same as --plain)",
+		get: func(s *Settings) string {
+			return strconv.FormatBool(s.Emoji == nil || *s.Emoji)
+		},
+		set: func(c *Config, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("emoji must be 'true' or 'false'")
+			}
+			c.Settings.Emoji = &enabled
+			return nil
+		},
+	},
+	"color": {
+		description: "Colorize output (auto|always|never)",
+		get: func(s *Settings) string {
+			if s.Color == "" {
+				return "auto"
+			}
+			return s.Color
+		},
+		set: func(c *Config, value string) error {
+			if value != "auto" && value != "always" && value != "never" {
+				return fmt.Errorf("color must be 'auto', 'always' or 'never'")
+			}
+			c.Settings.Color = value
+			return nil
+		},
+	},
+	"backups": {
+		description: "How many timestamped backups of the config to keep (0 turns them off)",
+		get: func(s *Settings) string {
+			if s.Backups == nil {
+				return strconv.Itoa(defaultBackups)
+			}
+			return strconv.Itoa(*s.Backups)
+		},
+		set: func(c *Config, value string) error {
+			keep, err := strconv.Atoi(value)
+			if err != nil || keep < 0 {
+				return fmt.Errorf("backups must be a number, 0 or more")
+			}
+			c.Settings.Backups = &keep
+			return nil
+		},
+	},
+	"updateCheck": {
+		description: "Check for a newer release once a day in the background (true|false)",
+		get: func(s *Settings) string {
+			return strconv.FormatBool(s.UpdateCheck)
+		},
+		set: func(c *Config, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("updateCheck must be 'true' or 'false'")
+			}
+			c.Settings.UpdateCheck = enabled
+			return nil
+		},
+	},
+	"npmSync": {
+		description: "Sync npm/yarn init-author-* on switch (off|global|always)",
+		get: func(s *Settings) string {
+			if s.NpmSync == "" {
+				return "off"
+			}
+			return s.NpmSync
+		},
+		set: func(c *Config, value string) error {
+			if value != "off" && value != "global" && value != "always" {
+				return fmt.Errorf("npmSync must be 'off', 'global' or 'always'")
+			}
+			c.Settings.NpmSync = value
+			return nil
+		},
+	},
+	"interop": {
+		description: "Under WSL, also switch the Windows git config on global switches (off|on)",
+		get: func(s *Settings) string {
+			if s.Interop == "" {
+				return "off"
+			}
+			return s.Interop
+		},
+		set: func(c *Config, value string) error {
+			if value != "off" && value != "on" {
+				return fmt.Errorf("interop must be 'off' or 'on'")
+			}
+			c.Settings.Interop = value
+			return nil
+		},
+	},
+	"defaultProfile": {
+		description: "Profile applied when none is given",
+		get: func(s *Settings) string {
+			return s.DefaultProfile
+		},
+		set: func(c *Config, value string) error {
+			if _, exists := c.Profiles[value]; !exists && value != "" {
+				return fmt.Errorf("%w: %s", errProfileNotFound, value)
+			}
+			c.Settings.DefaultProfile = value
+			return nil
+		},
+	},
+	"promptFormat": {
+		description: "Format of 'git usr prompt': %p profile, %n name, %e email, or starship|json",
+		get: func(s *Settings) string {
+			if s.PromptFormat == "" {
+				return defaultPromptFormat
+			}
+			return s.PromptFormat
+		},
+		set: func(c *Config, value string) error {
+			c.Settings.PromptFormat = value
+			return nil
+		},
+	},
+	"signingRequiredHosts": {
+		description: "Comma-separated hosts whose profiles lint expects to sign commits",
+		get: func(s *Settings) string {
+			return s.SigningRequiredHosts
+		},
+		set: func(c *Config, value string) error {
+			var hosts []string
+			for _, host := range strings.Split(value, ",") {
+				if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+					hosts = append(hosts, host)
+				}
+			}
+			c.Settings.SigningRequiredHosts = strings.Join(hosts, ",")
+			return nil
+		},
+	},
+	"store": {
+		description: "Where profiles are stored (file|http|git|vault|s3|webdav, default: this config file)",
+		get: func(s *Settings) string {
+			if s.Store == "" {
+				return "file"
+			}
+			return s.Store
+		},
+		set: func(c *Config, value string) error {
+			if _, ok := profileStores[value]; !ok && value != "" {
+				return fmt.Errorf("store must be one of %s", strings.Join(profileStoreNames(), ", "))
+			}
+			c.Settings.Store = value
+			return nil
+		},
+	},
+	"storeURL": {
+		description: "Location of the profile store: a file path, http(s) URL, git remote or s3:// URL",
+		get: func(s *Settings) string {
+			return s.StoreURL
+		},
+		set: func(c *Config, value string) error {
+			c.Settings.StoreURL = value
+			return nil
+		},
+	},
+}
+
+// settingNames returns the setting keys in alphabetical order
+func settingNames() []string {
+	names := make([]string, 0, len(settingKeys))
+	for name := range settingKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupSetting returns a setting by key, reporting unknown keys
+func lookupSetting(key string) (setting, error) {
+	s, ok := settingKeys[key]
+	if !ok {
+		fmt.Printf("❌ Unknown setting '%s'\n", key)
+		fmt.Println("\nAvailable settings:")
+		for _, name := range settingNames() {
+			fmt.Printf("   %-16s %s\n", name, settingKeys[name].description)
+		}
+		return setting{}, fmt.Errorf("unknown setting: %s", key)
+	}
+	return s, nil
+}
+
+// getSetting prints the value of a setting
+func getSetting(key string) error {
+	s, err := lookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	config, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(s.get(&config.Settings))
+	return nil
+}
+
+// setSetting validates and stores the value of a setting
+func setSetting(key, value string) error {
+	s, err := lookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The store settings must be fixable while the store is unreachable;
+	// the others may check the profiles
+	load := loadConfig
+	if key == "store" || key == "storeURL" {
+		load = loadLocalConfig
+	}
+	config, err := load()
+	if err != nil {
+		return err
+	}
+
+	if err := s.set(config, value); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return err
+	}
+
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ %s = %s\n", key, s.get(&config.Settings))
+	return nil
+}
+
+// listSettings prints all settings with their current values
+func listSettings() error {
+	config, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range settingNames() {
+		fmt.Printf("%s=%s\n", name, settingKeys[name].get(&config.Settings))
+	}
+	return nil
+}
+
+// runConfig handles the config command
+func runConfig(args []string) error {
+	usage := "Usage: git usr config list | get <key> | set <key> <value>"
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		return listSettings()
+	case len(args) == 2 && args[0] == "get":
+		return getSetting(args[1])
+	case len(args) == 3 && args[0] == "set":
+		return setSetting(args[1], args[2])
+	}
+
+	fmt.Println(usage)
+	return fmt.Errorf("invalid config command")
+}
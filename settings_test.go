@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+// TestParseConfigFormats tests parsing of current and legacy config files
+func TestParseConfigFormats(t *testing.T) {
+	current := `{"profiles":{"work":{"name":"John","email":"john@work.com"}},"settings":{"defaultScope":"global"}}`
+	config, err := parseConfig([]byte(current))
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	if config.Profiles["work"].Email != "john@work.com" || config.Settings.DefaultScope != "global" {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	legacy := `{"work":{"name":"John","email":"john@work.com"}}`
+	config, err = parseConfig([]byte(legacy))
+	if err != nil {
+		t.Fatalf("parseConfig legacy failed: %v", err)
+	}
+	if config.Profiles["work"].Name != "John" {
+		t.Errorf("Legacy profiles not loaded: %+v", config)
+	}
+
+	// A legacy profile literally named "profiles"
+	ambiguous := `{"profiles":{"name":"John","email":"john@profiles.com"}}`
+	config, err = parseConfig([]byte(ambiguous))
+	if err != nil {
+		t.Fatalf("parseConfig ambiguous failed: %v", err)
+	}
+	if config.Profiles["profiles"].Email != "john@profiles.com" {
+		t.Errorf("Legacy 'profiles' profile not loaded: %+v", config)
+	}
+
+	// A legacy profile literally named "settings"
+	legacySettings := `{"work":{"name":"John","email":"john@work.com"},"settings":{"name":"S","email":"s@x.io"}}`
+	config, err = parseConfig([]byte(legacySettings))
+	if err != nil {
+		t.Fatalf("parseConfig legacy settings failed: %v", err)
+	}
+	if config.Profiles["work"].Name != "John" || config.Profiles["settings"].Email != "s@x.io" {
+		t.Errorf("Legacy 'settings' profile not loaded: %+v", config)
+	}
+}
+
+// TestSettingValidation tests that settings reject invalid values
+func TestSettingValidation(t *testing.T) {
+	config := &Config{Profiles: map[string]Profile{"work": {Name: "John", Email: "john@work.com"}}}
+
+	if err := settingKeys["defaultScope"].set(config, "system"); err == nil {
+		t.Error("Expected error for invalid scope")
+	}
+	if err := settingKeys["defaultProfile"].set(config, "missing"); err == nil {
+		t.Error("Expected error for unknown default profile")
+	}
+	if err := settingKeys["emoji"].set(config, "false"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := settingKeys["emoji"].get(&config.Settings); got != "false" {
+		t.Errorf("Expected 'false', got: %s", got)
+	}
+	if got := settingKeys["defaultScope"].get(&config.Settings); got != "local" {
+		t.Errorf("Expected default 'local', got: %s", got)
+	}
+}
+
+// TestShouldSyncNpm tests the npmSync setting against switch scopes
+func TestShouldSyncNpm(t *testing.T) {
+	settings := &Settings{}
+	if shouldSyncNpm(settings, "global") {
+		t.Error("npm sync should be off by default")
+	}
+
+	settings.NpmSync = "global"
+	if !shouldSyncNpm(settings, "global") || shouldSyncNpm(settings, "local") {
+		t.Error("npmSync=global should only sync global switches")
+	}
+
+	settings.NpmSync = "always"
+	if !shouldSyncNpm(settings, "local") {
+		t.Error("npmSync=always should sync local switches")
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// offerSetup is set by main for commands that may ask to run the setup
+// wizard on first run; programs reading the output and hooks never do
+var offerSetup bool
+
+// noSeedFlag is set by the global --no-seed flag
+var noSeedFlag bool
+
+// seedingDisabled reports whether a first run starts without profiles, by
+// --no-seed or GIT_USR_NO_SEED, e.g. in automation where placeholder
+// profiles would only show up in completion
+func seedingDisabled() bool {
+	return noSeedFlag || os.Getenv("GIT_USR_NO_SEED") != ""
+}
+
+// setupAccount is an account added by the setup wizard
+type setupAccount struct {
+	Profile string
+	// Dir is the directory whose repositories use it through includeIf,
+	// "" for none
+	Dir string
+}
+
+// firstRunConfig returns the config of a first run. Someone at a terminal
+// is offered the setup wizard instead of placeholder profiles that could
+// be switched into a real repository by accident
+func firstRunConfig() *Config {
+	if seedingDisabled() {
+		return &Config{Profiles: map[string]Profile{}}
+	}
+	if !offerSetup || !isInteractive() {
+		return defaultConfig()
+	}
+	config := &Config{Profiles: map[string]Profile{}}
+	reader := bufio.NewReader(os.Stdin)
+	run, err := askYesNo(reader, "👋 No git-usr profiles yet. Set them up now? [Y/n] ", true)
+	if err == nil && run {
+		fmt.Println()
+		err = setupWizard(reader, config)
+	}
+	if err != nil || len(config.Profiles) == 0 {
+		fmt.Println("   Run 'git usr setup' any time to add your accounts")
+	}
+	fmt.Println()
+	return config
+}
+
+// askYesNo asks a yes/no question, fallback being the answer to an empty line
+func askYesNo(reader *bufio.Reader, prompt string, fallback bool) (bool, error) {
+	answer, err := readAnswer(reader, prompt, "")
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(answer) == "" {
+		return fallback, nil
+	}
+	return answeredYes(answer), nil
+}
+
+// askSetupProfileName asks for the name of a new profile until it is one
+// that can be used
+func askSetupProfileName(reader *bufio.Reader, config *Config, fallback string) (string, error) {
+	if _, exists := config.Profiles[fallback]; exists {
+		fallback = ""
+	}
+	for {
+		name, err := readAnswer(reader, "   Profile name: ", fallback)
+		if err != nil {
+			return "", err
+		}
+		name = strings.TrimSpace(name)
+		if _, exists := config.Profiles[name]; exists {
+			fmt.Printf("   ❌ Profile '%s' already exists, pick another name\n", name)
+			continue
+		}
+		if name == "" || strings.ContainsAny(name, " \t") {
+			fmt.Println("   ❌ Use a single word, like work or personal")
+			continue
+		}
+		if reason := reservedProfileName(name); reason != "" {
+			fmt.Printf("   ❌ Can't name a profile '%s': %s\n", name, reason)
+			continue
+		}
+		return name, nil
+	}
+}
+
+// askSetupField asks for a name or email until it is valid
+func askSetupField(reader *bufio.Reader, field, prompt, fallback string, validate func(string, string) error) (string, error) {
+	for {
+		value, err := readAnswer(reader, prompt, fallback)
+		if err != nil {
+			return "", err
+		}
+		value = cleanIdentityText(value)
+		if err := validate(field, value); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			continue
+		}
+		return value, nil
+	}
+}
+
+// setupWizard walks through setting up profiles: it imports the global
+// identity, adds further accounts, offers includeIf so git picks each by
+// directory on its own, and offers to install completion
+func setupWizard(reader *bufio.Reader, config *Config) error {
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	fmt.Println("👋 Let's set up your git identities. Press Enter to take the value in [brackets].")
+
+	global := currentGitProfile("global")
+	defaultName := global.Name
+	if global.Name != "" && global.Email != "" {
+		if existing, ok := profileForEmail(config.Profiles, global.Email); ok {
+			fmt.Printf("\nYour global identity %s is profile '%s'\n", formatAddress(global.Name, global.Email), existing)
+		} else {
+			fmt.Printf("\nYour global identity is %s\n", formatAddress(global.Name, global.Email))
+			keep, err := askYesNo(reader, "   Save it as a profile? [Y/n] ", true)
+			if err != nil {
+				return err
+			}
+			if keep {
+				name, err := askSetupProfileName(reader, config, "personal")
+				if err != nil {
+					return err
+				}
+				config.Profiles[name] = global
+				fmt.Printf("   ✅ Added '%s'\n", name)
+			}
+		}
+	}
+
+	var accounts []setupAccount
+	for {
+		question := "\nAdd another account, e.g. for work? [y/N] "
+		fallback := false
+		if len(config.Profiles) == 0 {
+			question, fallback = "\nAdd an account? [Y/n] ", true
+		}
+		add, err := askYesNo(reader, question, fallback)
+		if err != nil {
+			return err
+		}
+		if !add {
+			break
+		}
+
+		suggested := "work"
+		if len(config.Profiles) == 0 {
+			suggested = "personal"
+		}
+		name, err := askSetupProfileName(reader, config, suggested)
+		if err != nil {
+			return err
+		}
+		var profile Profile
+		if profile.Name, err = askSetupField(reader, "name", "   Name: ", defaultName, validateName); err != nil {
+			return err
+		}
+		if profile.Email, err = askSetupField(reader, "email", "   Email: ", "", validateEmail); err != nil {
+			return err
+		}
+		defaultName = profile.Name
+		config.Profiles[name] = profile
+		fmt.Printf("   ✅ Added '%s'\n", name)
+
+		dir, err := readAnswer(reader, "   Use it for every repository in a directory? Enter the directory (e.g. ~/work), or nothing: ", "")
+		if err != nil {
+			return err
+		}
+		accounts = append(accounts, setupAccount{Profile: name, Dir: strings.TrimSpace(dir)})
+	}
+
+	if len(config.Profiles) == 0 {
+		fmt.Println("\nNo profiles set up")
+		return nil
+	}
+
+	// Before includeIf, since git takes the last value and the included
+	// identities have to come after [user]
+	if global.Name == "" || global.Email == "" {
+		names := sortedProfileNames(config.Profiles)
+		first := names[0]
+		if len(accounts) > 0 {
+			first = accounts[0].Profile
+		}
+		use, err := askYesNo(reader, fmt.Sprintf("\nYou have no global identity yet. Use '%s' everywhere else? [Y/n] ", first), true)
+		if err != nil {
+			return err
+		}
+		if use {
+			if err := setGitConfig(config.Profiles[first].Name, config.Profiles[first].Email, "global"); err != nil {
+				fmt.Printf("   ⚠️  Global identity not set: %v\n", err)
+			} else {
+				fmt.Printf("   ✅ Switched to '%s' globally\n", first)
+			}
+		}
+	}
+
+	var autoSwitch []setupAccount
+	for _, account := range accounts {
+		if account.Dir != "" {
+			autoSwitch = append(autoSwitch, account)
+		}
+	}
+	if len(autoSwitch) > 0 {
+		fmt.Println("\ngit can pick these identities on its own, without git usr, with includeIf in your global config:")
+		for _, account := range autoSwitch {
+			fmt.Printf("   %s → %s\n", includeIfDir(account.Dir), account.Profile)
+		}
+		add, err := askYesNo(reader, "   Add them? [Y/n] ", true)
+		if err != nil {
+			return err
+		}
+		if add {
+			for _, account := range autoSwitch {
+				if err := writeIncludeIf(account.Profile, config.Profiles[account.Profile], account.Dir); err != nil {
+					fmt.Printf("   ⚠️  includeIf for '%s' not added: %v\n", account.Profile, err)
+					continue
+				}
+				fmt.Printf("   ✅ Repositories in %s use '%s'\n", includeIfDir(account.Dir), account.Profile)
+			}
+		}
+	}
+
+	if shell := detectShell(os.Getenv); shell != "" {
+		install, err := askYesNo(reader, fmt.Sprintf("\nInstall tab completion for %s? [Y/n] ", shell), true)
+		if err != nil {
+			return err
+		}
+		if install {
+			if err := installCompletion(shell); err != nil {
+				fmt.Printf("   ⚠️  Completion not installed: %v\n", err)
+			}
+		}
+	}
+
+	fmt.Printf("\n🎉 All set: %s\n", strings.Join(sortedProfileNames(config.Profiles), ", "))
+	fmt.Println("   Use: git usr <profile> to switch a repository, git usr list to see them all")
+	return nil
+}
+
+// includeIfDir returns dir as an includeIf gitdir pattern: slash-separated
+// and ending in a slash, so it matches every repository below it
+func includeIfDir(dir string) string {
+	dir = filepath.ToSlash(dir)
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir
+}
+
+// writeIncludeIf writes profile's identity to a config file next to the
+// git-usr config and includes it in the global git config for the
+// repositories in dir
+func writeIncludeIf(name string, profile Profile, dir string) error {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, "includes", name+".gitconfig")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Identity of the git-usr profile '%s', included for %s\n", name, includeIfDir(dir))
+	b.WriteString("[user]\n")
+	fmt.Fprintf(&b, "\tname = %s\n", formatConfigValue(profile.Name))
+	fmt.Fprintf(&b, "\temail = %s\n", formatConfigValue(profile.Email))
+	if profile.SigningKey != "" {
+		fmt.Fprintf(&b, "\tsigningkey = %s\n", formatConfigValue(profile.SigningKey))
+		if profile.SigningFormat != "" {
+			fmt.Fprintf(&b, "[gpg]\n\tformat = %s\n", formatConfigValue(profile.SigningFormat))
+		}
+	}
+	if err := writeFileAtomic(path, []byte(b.String()), 0644, false); err != nil {
+		return err
+	}
+	return gitConfig.Set("", "global", "includeIf.gitdir:"+includeIfDir(dir)+".path", filepath.ToSlash(path))
+}
+
+// runSetup handles the setup command
+func runSetup(args []string) error {
+	if len(args) > 0 {
+		fmt.Println("Usage: git usr setup")
+		return fmt.Errorf("usage")
+	}
+	if !isInteractive() {
+		fmt.Println("❌ git usr setup asks questions and needs a terminal")
+		fmt.Println("   In scripts, use: git usr add <profile> <name> <email>")
+		return fmt.Errorf("not a terminal")
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	config := &Config{Profiles: map[string]Profile{}}
+	if _, err := os.Stat(configPath); err == nil {
+		if config, err = loadConfig(); err != nil {
+			return err
+		}
+	}
+
+	if err := setupWizard(bufio.NewReader(os.Stdin), config); err != nil {
+		fmt.Println("\n❌ Setup cancelled, no profiles saved")
+		return err
+	}
+	return saveConfig(config)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIncludeIfDir tests turning directories into gitdir patterns
+func TestIncludeIfDir(t *testing.T) {
+	for dir, want := range map[string]string{
+		"~/work":      "~/work/",
+		"~/work/":     "~/work/",
+		"/src/client": "/src/client/",
+	} {
+		if got := includeIfDir(dir); got != want {
+			t.Errorf("includeIfDir(%q) = %q, expected %q", dir, got, want)
+		}
+	}
+}
+
+// TestSetupWizard tests importing the global identity and adding an
+// account that includeIf picks by directory
+func TestSetupWizard(t *testing.T) {
+	home := setupConfigHome(t)
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(home, "git-usr", "profiles.json"))
+	t.Setenv("SHELL", "")
+	t.Setenv("PSModulePath", "")
+	if err := setGitConfig("Jane Doe", "jane@personal.example", "global"); err != nil {
+		t.Fatal(err)
+	}
+
+	answers := []string{
+		"",              // save the global identity
+		"",              // as personal
+		"y",             // add another account
+		"",              // as work
+		"",              // named Jane Doe
+		"jane",          // not an email, asked again
+		"jane@acme.com", // email
+		"~/work",        // used in ~/work
+		"n",             // no more accounts
+		"",              // add includeIf
+	}
+	config := &Config{}
+	reader := bufio.NewReader(strings.NewReader(strings.Join(answers, "\n") + "\n"))
+	if err := setupWizard(reader, config); err != nil {
+		t.Fatalf("setupWizard() error = %v", err)
+	}
+
+	want := map[string]Profile{
+		"personal": {Name: "Jane Doe", Email: "jane@personal.example"},
+		"work":     {Name: "Jane Doe", Email: "jane@acme.com"},
+	}
+	for name, profile := range want {
+		if got := config.Profiles[name]; got.Name != profile.Name || got.Email != profile.Email {
+			t.Errorf("profile %s = %+v, expected %+v", name, got, profile)
+		}
+	}
+	if len(config.Profiles) != len(want) {
+		t.Errorf("got profiles %v, expected %v", sortedProfileNames(config.Profiles), sortedProfileNames(want))
+	}
+
+	include := filepath.Join(home, "git-usr", "includes", "work.gitconfig")
+	if got := gitConfigValue("", "global", "includeIf.gitdir:~/work/.path"); got != filepath.ToSlash(include) {
+		t.Errorf("includeIf path = %q, expected %q", got, include)
+	}
+	data, err := os.ReadFile(include)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "email = jane@acme.com") {
+		t.Errorf("include file doesn't set the email:\n%s", data)
+	}
+}
+
+// TestFirstRunConfig tests what a first run without a terminal starts with
+func TestFirstRunConfig(t *testing.T) {
+	t.Setenv("GIT_USR_NO_SEED", "")
+	if config := firstRunConfig(); len(config.Profiles) != len(defaultConfig().Profiles) {
+		t.Errorf("firstRunConfig() profiles = %v, expected the placeholders", sortedProfileNames(config.Profiles))
+	}
+
+	t.Setenv("GIT_USR_NO_SEED", "1")
+	config := firstRunConfig()
+	if config.Profiles == nil || len(config.Profiles) != 0 {
+		t.Errorf("firstRunConfig() profiles = %v with GIT_USR_NO_SEED, expected none", config.Profiles)
+	}
+}
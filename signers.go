@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Markers around the part of the allowed signers file owned by git-usr;
+// entries outside them are left alone
+const (
+	signersBlockBegin = "# BEGIN git-usr (generated by 'git usr signers sync')"
+	signersBlockEnd   = "# END git-usr"
+)
+
+// readSigningPublicKey resolves a profile's SSH signingKey, which git
+// accepts as a public key path or as a literal "key::" / "ssh-..." key
+func readSigningPublicKey(signingKey string) (sshPublicKey, error) {
+	literal := strings.TrimPrefix(signingKey, "key::")
+	if literal != signingKey || strings.HasPrefix(literal, "ssh-") || strings.HasPrefix(literal, "ecdsa-") {
+		fields := strings.Fields(literal)
+		if len(fields) < 2 {
+			return sshPublicKey{}, fmt.Errorf("invalid SSH key %q", signingKey)
+		}
+		return sshPublicKey{Type: fields[0], Key: fields[1], Comment: strings.Join(fields[2:], " ")}, nil
+	}
+
+	path, err := expandHome(signingKey)
+	if err != nil {
+		return sshPublicKey{}, err
+	}
+	if !strings.HasSuffix(path, ".pub") {
+		path += ".pub"
+	}
+	return readSSHPublicKey(path)
+}
+
+// allowedSignersEntries returns the allowed_signers lines for every profile
+// that signs with SSH, in profile name order, plus warnings for keys that
+// could not be read
+func allowedSignersEntries(profiles map[string]Profile) ([]string, []string) {
+	var lines, warnings []string
+	seen := map[string]bool{}
+	for _, name := range sortedProfileNames(profiles) {
+		profile := profiles[name]
+		if profile.SigningFormat != "ssh" || profile.SigningKey == "" {
+			continue
+		}
+		key, err := readSigningPublicKey(profile.SigningKey)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		line := allowedSignersLine(profile.Email, key)
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	return lines, warnings
+}
+
+// splitAllowedSigners splits an allowed signers file into the lines outside
+// the git-usr block and the entries inside it
+func splitAllowedSigners(existing string) ([]string, []string) {
+	var outside, block []string
+	inBlock := false
+	for _, line := range strings.Split(normalizeLF(existing), "\n") {
+		switch {
+		case line == signersBlockBegin:
+			inBlock = true
+		case line == signersBlockEnd:
+			inBlock = false
+		case line == "":
+		case inBlock:
+			block = append(block, line)
+		default:
+			outside = append(outside, line)
+		}
+	}
+	return outside, block
+}
+
+// renderAllowedSigners replaces the git-usr block of an allowed signers
+// file with entries
+func renderAllowedSigners(existing string, entries []string) string {
+	kept, _ := splitAllowedSigners(existing)
+	if len(entries) > 0 {
+		kept = append(kept, signersBlockBegin)
+		kept = append(kept, entries...)
+		kept = append(kept, signersBlockEnd)
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return strings.Join(kept, "\n") + "\n"
+}
+
+// syncAllowedSigners regenerates the git-usr entries of the allowed signers
+// file from all profiles, returning its path and new content
+func syncAllowedSigners(dryRun bool) (string, string, error) {
+	profiles, err := loadProfiles()
+	if err != nil {
+		return "", "", err
+	}
+
+	entries, warnings := allowedSignersEntries(profiles)
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+
+	var path string
+	if dryRun {
+		// Don't register a file in the global config on a dry run
+		if path, err = expandHome(getScopedGitConfigValue("global", "gpg.ssh.allowedSignersFile")); err != nil {
+			return "", "", err
+		}
+	} else if path, err = getAllowedSignersPath(); err != nil {
+		return "", "", err
+	}
+
+	var existing []byte
+	if path != "" {
+		existing, err = os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+	}
+	content := renderAllowedSigners(string(existing), entries)
+
+	if dryRun {
+		return path, content, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", "", err
+	}
+	if err := writeFileAtomic(path, []byte(content), 0644, false); err != nil {
+		return "", "", err
+	}
+	return path, content, nil
+}
+
+// runSigners handles the signers command
+func runSigners(args []string) error {
+	usage := "Usage: git usr signers sync [--dry-run]"
+
+	if len(args) == 0 || args[0] != "sync" {
+		fmt.Println(usage)
+		return fmt.Errorf("invalid signers command")
+	}
+	dryRun := false
+	for _, arg := range args[1:] {
+		if arg != "--dry-run" {
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+		dryRun = true
+	}
+
+	path, content, err := syncAllowedSigners(dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Print(content)
+		return nil
+	}
+
+	count := strings.Count(content, `namespaces="git"`)
+	fmt.Printf("✅ Synced allowed signers in %s (%d signer(s))\n", path, count)
+	return nil
+}
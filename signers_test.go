@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadSigningPublicKey tests resolving key paths and literal keys
+func TestReadSigningPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519.pub")
+	if err := os.WriteFile(keyPath, []byte("ssh-ed25519 AAAAfile john@work.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		signingKey string
+		key        string
+	}{
+		{keyPath, "AAAAfile"},
+		{filepath.Join(dir, "id_ed25519"), "AAAAfile"},
+		{"key::ssh-ed25519 AAAAliteral", "AAAAliteral"},
+		{"ssh-ed25519 AAAAbare comment", "AAAAbare"},
+	}
+	for _, tt := range tests {
+		key, err := readSigningPublicKey(tt.signingKey)
+		if err != nil {
+			t.Errorf("readSigningPublicKey(%q) failed: %v", tt.signingKey, err)
+			continue
+		}
+		if key.Type != "ssh-ed25519" || key.Key != tt.key {
+			t.Errorf("readSigningPublicKey(%q) = %+v", tt.signingKey, key)
+		}
+	}
+
+	if _, err := readSigningPublicKey("key::garbage"); err == nil {
+		t.Error("Expected an error for an invalid literal key")
+	}
+}
+
+// TestAllowedSignersEntries tests that only SSH signing profiles are listed
+func TestAllowedSignersEntries(t *testing.T) {
+	profiles := map[string]Profile{
+		"work":     {Email: "john@work.com", SigningFormat: "ssh", SigningKey: "key::ssh-ed25519 AAAAwork"},
+		"personal": {Email: "john@home.com", SigningFormat: "ssh", SigningKey: "key::ssh-ed25519 AAAAhome"},
+		"gpg":      {Email: "john@gpg.com", SigningFormat: "openpgp", SigningKey: "ABCDEF"},
+		"missing":  {Email: "john@missing.com", SigningFormat: "ssh", SigningKey: "/nonexistent/key.pub"},
+	}
+
+	entries, warnings := allowedSignersEntries(profiles)
+	expected := []string{
+		`john@home.com namespaces="git" ssh-ed25519 AAAAhome`,
+		`john@work.com namespaces="git" ssh-ed25519 AAAAwork`,
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("Entry %d = %q, expected %q", i, entries[i], expected[i])
+		}
+	}
+	if len(warnings) != 1 {
+		t.Errorf("Expected one warning for the missing key, got %v", warnings)
+	}
+}
+
+// TestRenderAllowedSigners tests that the git-usr block is replaced while
+// the entries outside it are left alone
+func TestRenderAllowedSigners(t *testing.T) {
+	existing := "other@example.com ssh-ed25519 AAAAother\r\n" +
+		"john@work.com namespaces=\"git\" ssh-ed25519 AAAAold\r\n" +
+		signersBlockBegin + "\n" +
+		"gone@example.com namespaces=\"git\" ssh-ed25519 AAAAgone\n" +
+		signersBlockEnd + "\n"
+	entries := []string{`john@work.com namespaces="git" ssh-ed25519 AAAAnew`}
+
+	expected := "other@example.com ssh-ed25519 AAAAother\n" +
+		"john@work.com namespaces=\"git\" ssh-ed25519 AAAAold\n" +
+		signersBlockBegin + "\n" +
+		"john@work.com namespaces=\"git\" ssh-ed25519 AAAAnew\n" +
+		signersBlockEnd + "\n"
+	if got := renderAllowedSigners(existing, entries); got != expected {
+		t.Errorf("Unexpected allowed_signers:\n%s", got)
+	}
+
+	// Rendering again is stable
+	if got := renderAllowedSigners(expected, entries); got != expected {
+		t.Errorf("Rendering is not idempotent:\n%s", got)
+	}
+
+	if got := renderAllowedSigners("", nil); got != "" {
+		t.Errorf("Expected an empty file, got %q", got)
+	}
+}
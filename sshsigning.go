@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// expandHome expands a leading ~/ to the user's home directory
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+// sshPublicKey is a public key file in ~/.ssh
+type sshPublicKey struct {
+	Path    string
+	Type    string
+	Key     string
+	Comment string
+}
+
+// readSSHPublicKey reads a public key file ("type base64 [comment]")
+func readSSHPublicKey(path string) (sshPublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sshPublicKey{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return sshPublicKey{}, fmt.Errorf("%s is not an SSH public key", path)
+	}
+	return sshPublicKey{
+		Path:    path,
+		Type:    fields[0],
+		Key:     fields[1],
+		Comment: strings.Join(fields[2:], " "),
+	}, nil
+}
+
+// listSSHPublicKeys returns the public keys in ~/.ssh, keys whose comment
+// mentions email first
+func listSSHPublicKeys(email string) ([]sshPublicKey, error) {
+	sshDir, err := expandHome("~/.ssh")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(sshDir, "*.pub"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []sshPublicKey
+	for _, path := range paths {
+		if key, err := readSSHPublicKey(path); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return strings.Contains(keys[i].Comment, email) && !strings.Contains(keys[j].Comment, email)
+	})
+	return keys, nil
+}
+
+// generateSSHKey creates an ed25519 key for the profile at
+// ~/.ssh/id_ed25519_<profile>, letting ssh-keygen ask for the passphrase
+// unless noPassphrase is set
+func generateSSHKey(profileName string, profile Profile, noPassphrase bool) (string, error) {
+	path, err := expandHome("~/.ssh/id_ed25519_" + profileName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("❌ %s already exists (use --key to select it)\n", path)
+		return "", fmt.Errorf("key exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+
+	args := []string{"-t", "ed25519", "-C", profile.Email, "-f", path}
+	if noPassphrase {
+		args = append(args, "-N", "")
+	}
+	cmd := exec.Command("ssh-keygen", args...)
+	attachTerminal(cmd)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ssh-keygen: %w", err)
+	}
+	return path + ".pub", nil
+}
+
+// chooseSSHKey asks which of several public keys to use
+func chooseSSHKey(keys []sshPublicKey) (sshPublicKey, error) {
+	fmt.Println("Which SSH key should sign commits?")
+	for i, key := range keys {
+		fmt.Printf("  [%d] %s  %s %s\n", i+1, key.Path, key.Type, key.Comment)
+	}
+	fmt.Print("Choice: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return sshPublicKey{}, err
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > len(keys) {
+		return sshPublicKey{}, fmt.Errorf("invalid choice")
+	}
+	return keys[choice-1], nil
+}
+
+// getAllowedSignersPath returns gpg.ssh.allowedSignersFile, registering a
+// file in the config directory if none is configured
+func getAllowedSignersPath() (string, error) {
+	if path := getScopedGitConfigValue("global", "gpg.ssh.allowedSignersFile"); path != "" {
+		return expandHome(path)
+	}
+
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(configDir, "allowed_signers")
+	if err := gitConfig.Set("", "global", "gpg.ssh.allowedSignersFile", path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// allowedSignersLine formats an allowed_signers entry for git signatures
+func allowedSignersLine(email string, key sshPublicKey) string {
+	return email + ` namespaces="git" ` + key.Type + " " + key.Key
+}
+
+// updateAllowedSigners adds the entry for email to the git-usr block of the
+// allowed signers file at path, replacing the block's previous entries for
+// exactly that email
+func updateAllowedSigners(path, email string, key sshPublicKey) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var entries []string
+	_, block := splitAllowedSigners(string(data))
+	for _, line := range block {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] != email {
+			entries = append(entries, line)
+		}
+	}
+	entries = append(entries, allowedSignersLine(email, key))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(renderAllowedSigners(string(data), entries)), 0644, false)
+}
+
+// setupSSHSigning selects or generates an SSH key for the profile, stores
+// it as the profile's signing key and trusts it in allowed_signers
+func setupSSHSigning(profileName, keyPath string, generate, noPassphrase bool) error {
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case keyPath != "":
+		if keyPath, err = expandHome(keyPath); err != nil {
+			return err
+		}
+		if !strings.HasSuffix(keyPath, ".pub") {
+			keyPath += ".pub"
+		}
+	case generate:
+		if keyPath, err = generateSSHKey(profileName, profile, noPassphrase); err != nil {
+			return err
+		}
+	default:
+		keys, err := listSSHPublicKeys(profile.Email)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(keys) == 0:
+			fmt.Println("❌ No SSH keys found in ~/.ssh (use --generate to create one)")
+			return fmt.Errorf("no ssh key")
+		case len(keys) == 1 || strings.Contains(keys[0].Comment, profile.Email) && !strings.Contains(keys[1].Comment, profile.Email):
+			keyPath = keys[0].Path
+		case !isInteractive():
+			fmt.Println("❌ Several SSH keys found (use --key to pick one)")
+			return fmt.Errorf("ambiguous ssh key")
+		default:
+			key, err := chooseSSHKey(keys)
+			if err != nil {
+				return err
+			}
+			keyPath = key.Path
+		}
+	}
+
+	key, err := readSSHPublicKey(keyPath)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return err
+	}
+
+	if err := updateProfileSigning(profileName, "ssh", keyPath, ""); err != nil {
+		return err
+	}
+
+	signersPath, err := getAllowedSignersPath()
+	if err != nil {
+		return err
+	}
+	if err := updateAllowedSigners(signersPath, profile.Email, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ '%s' now signs commits and tags with SSH key %s\n", profileName, keyPath)
+	fmt.Printf("   Trusted for %s in %s\n", profile.Email, signersPath)
+	fmt.Printf("   Run 'git usr %s' to apply it\n", profileName)
+	fmt.Println("\nAdd this public key as a signing key so your signatures show as verified:")
+	fmt.Println("   GitHub: https://github.com/settings/ssh/new (key type: Signing Key)")
+	fmt.Println("   GitLab: https://gitlab.com/-/user_settings/ssh_keys (usage type: Signing)")
+	fmt.Println()
+	fmt.Println(key.Type + " " + key.Key + " " + key.Comment)
+	return nil
+}
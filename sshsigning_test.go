@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestUpdateAllowedSigners tests adding and replacing allowed_signers entries
+func TestUpdateAllowedSigners(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "allowed_signers")
+	initial := "other@example.com ssh-ed25519 AAAAother\r\n" +
+		signersBlockBegin + "\n" +
+		"john@work.com namespaces=\"git\" ssh-ed25519 AAAAold\n" +
+		"john@home.com namespaces=\"git\" ssh-ed25519 AAAAhome\n" +
+		signersBlockEnd + "\n"
+	if err := os.WriteFile(path, []byte(initial), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(dir, "id_ed25519.pub")
+	if err := os.WriteFile(keyPath, []byte("ssh-ed25519 AAAAnew john@work.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	key, err := readSSHPublicKey(keyPath)
+	if err != nil {
+		t.Fatalf("readSSHPublicKey failed: %v", err)
+	}
+	if key.Type != "ssh-ed25519" || key.Key != "AAAAnew" || key.Comment != "john@work.com" {
+		t.Errorf("Unexpected key %+v", key)
+	}
+
+	if err := updateAllowedSigners(path, "john@work.com", key); err != nil {
+		t.Fatalf("updateAllowedSigners failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	expected := "other@example.com ssh-ed25519 AAAAother\n" +
+		signersBlockBegin + "\n" +
+		"john@home.com namespaces=\"git\" ssh-ed25519 AAAAhome\n" +
+		"john@work.com namespaces=\"git\" ssh-ed25519 AAAAnew\n" +
+		signersBlockEnd + "\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected allowed_signers:\n%s", data)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// countSwitch adds a switch in scope, to repo unless global, to usage
+func countSwitch(usage ProfileUsage, scope, repo string) ProfileUsage {
+	usage.Switches++
+	if scope == "global" || repo == "" {
+		usage.Global++
+		return usage
+	}
+	if usage.Repos == nil {
+		usage.Repos = map[string]int{}
+	}
+	usage.Repos[repo]++
+	return usage
+}
+
+// usageCounts returns the switch counts of the state. State written before
+// they were kept is counted from the switch history
+func usageCounts(state *ManagedState) map[string]ProfileUsage {
+	if state.Usage != nil {
+		return state.Usage
+	}
+	usage := map[string]ProfileUsage{}
+	for _, record := range state.History {
+		usage[record.Profile] = countSwitch(usage[record.Profile], record.Scope, record.Repo)
+	}
+	return usage
+}
+
+// profileStat is a row of `git usr stats`
+type profileStat struct {
+	Name     string
+	Usage    ProfileUsage
+	LastUsed time.Time
+	// Removed is set for profiles that were used but no longer exist
+	Removed bool
+}
+
+// profileStats returns the usage of every profile, and of removed profiles
+// that were used, most switched to first
+func profileStats(profiles map[string]Profile, state *ManagedState) []profileStat {
+	usage := usageCounts(state)
+	var stats []profileStat
+	for name := range profiles {
+		stats = append(stats, profileStat{Name: name, Usage: usage[name], LastUsed: state.LastUsed[name]})
+	}
+	for name, counts := range usage {
+		if _, exists := profiles[name]; !exists {
+			stats = append(stats, profileStat{Name: name, Usage: counts, LastUsed: state.LastUsed[name], Removed: true})
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Usage.Switches != stats[j].Usage.Switches {
+			return stats[i].Usage.Switches > stats[j].Usage.Switches
+		}
+		if !stats[i].LastUsed.Equal(stats[j].LastUsed) {
+			return stats[i].LastUsed.After(stats[j].LastUsed)
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// formatLastUsed returns when a profile was last switched to
+func formatLastUsed(lastUsed time.Time) string {
+	if lastUsed.IsZero() {
+		return "never"
+	}
+	return lastUsed.Local().Format("2006-01-02 15:04")
+}
+
+// showStats prints how often and where each profile was switched to
+func showStats() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	if state.UsageSince == nil {
+		fmt.Println("No switches recorded yet. Usage is counted from the next 'git usr <profile>'")
+		return nil
+	}
+
+	rows := [][]string{{"PROFILE", "SWITCHES", "LAST USED", "GLOBAL", "REPOS"}}
+	for _, stat := range profileStats(config.Profiles, state) {
+		name := stat.Name
+		if stat.Removed {
+			name += " (removed)"
+		}
+		rows = append(rows, []string{name, strconv.Itoa(stat.Usage.Switches), formatLastUsed(stat.LastUsed), strconv.Itoa(stat.Usage.Global), strconv.Itoa(len(stat.Usage.Repos))})
+	}
+
+	fmt.Printf("\n📊 Profile usage since %s:\n", state.UsageSince.Local().Format("2006-01-02"))
+	for _, line := range formatTable(rows) {
+		fmt.Println("   " + line)
+	}
+	fmt.Println("\nSee where a profile was used with 'git usr stats <profile>'")
+	return nil
+}
+
+// showProfileStats prints the repositories a profile was switched to in
+func showProfileStats(profileName string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	usage, used := usageCounts(state)[profileName]
+	if _, exists := config.Profiles[profileName]; !exists && !used {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		return errProfileNotFound
+	}
+
+	fmt.Printf("\n📊 '%s': %d switch(es), last used %s\n", profileName, usage.Switches, formatLastUsed(state.LastUsed[profileName]))
+	if usage.Global > 0 {
+		fmt.Printf("   %d globally\n", usage.Global)
+	}
+	repos := sortedKeys(usage.Repos)
+	sort.SliceStable(repos, func(i, j int) bool { return usage.Repos[repos[i]] > usage.Repos[repos[j]] })
+	if len(repos) > 0 {
+		rows := [][]string{{"SWITCHES", "REPOSITORY"}}
+		for _, repo := range repos {
+			rows = append(rows, []string{strconv.Itoa(usage.Repos[repo]), repo})
+		}
+		fmt.Println()
+		for _, line := range formatTable(rows) {
+			fmt.Println("   " + line)
+		}
+	}
+	if usage.Switches == 0 {
+		fmt.Printf("\nNever switched to since %s; remove it with 'git usr prune' if it's no longer needed\n", state.UsageSince.Local().Format("2006-01-02"))
+	}
+	return nil
+}
+
+// runStats handles the stats command
+func runStats(args []string) error {
+	switch {
+	case len(args) == 0:
+		return showStats()
+	case len(args) == 1 && !strings.HasPrefix(args[0], "-"):
+		return showProfileStats(args[0])
+	}
+	fmt.Println("Usage: git usr stats [<profile>]")
+	return fmt.Errorf("invalid stats command")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUsageCounts tests counting switches, from the switch history for
+// state written before counts were kept
+func TestUsageCounts(t *testing.T) {
+	state := &ManagedState{History: []SwitchRecord{
+		{Profile: "work", Scope: "local", Repo: "/src/app"},
+		{Profile: "work", Scope: "local", Repo: "/src/app"},
+		{Profile: "work", Scope: "global"},
+		{Profile: "personal", Scope: "local", Repo: "/src/blog"},
+	}}
+	usage := usageCounts(state)
+	if work := usage["work"]; work.Switches != 3 || work.Global != 1 || work.Repos["/src/app"] != 2 {
+		t.Errorf("work: got %+v", work)
+	}
+	if personal := usage["personal"]; personal.Switches != 1 || personal.Global != 0 || personal.Repos["/src/blog"] != 1 {
+		t.Errorf("personal: got %+v", personal)
+	}
+
+	// Kept counts outlive the history
+	state.Usage = map[string]ProfileUsage{"work": {Switches: 5000}}
+	if got := usageCounts(state)["work"].Switches; got != 5000 {
+		t.Errorf("Expected the kept count, got %d", got)
+	}
+	state.Usage["work"] = countSwitch(state.Usage["work"], "local", "/src/lib")
+	if work := state.Usage["work"]; work.Switches != 5001 || work.Repos["/src/lib"] != 1 {
+		t.Errorf("countSwitch: got %+v", work)
+	}
+}
+
+// TestProfileStats tests that the most used profiles come first and that
+// removed profiles are still listed
+func TestProfileStats(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	profiles := map[string]Profile{"work": {}, "personal": {}, "old": {}, "spare": {}}
+	state := &ManagedState{
+		LastUsed: map[string]time.Time{"work": now, "personal": now.Add(time.Hour), "gone": now.Add(-time.Hour)},
+		Usage: map[string]ProfileUsage{
+			"work":     {Switches: 4},
+			"personal": {Switches: 4},
+			"gone":     {Switches: 7},
+		},
+	}
+
+	stats := profileStats(profiles, state)
+	var names []string
+	for _, stat := range stats {
+		names = append(names, stat.Name)
+	}
+	want := []string{"gone", "personal", "work", "old", "spare"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, names)
+		}
+	}
+	if !stats[0].Removed || stats[1].Removed {
+		t.Errorf("Expected only 'gone' to be marked removed, got %+v", stats)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProfileStore is where the profiles live when they are not kept in the
+// local config file. Settings always stay local; the store only provides
+// the profiles section.
+type ProfileStore interface {
+	// Load returns the stored profiles
+	Load() (map[string]Profile, error)
+	// Save replaces the stored profiles, or returns errReadOnlyStore
+	Save(profiles map[string]Profile) error
+}
+
+// errReadOnlyStore is returned when saving to a store that can't be written
+var errReadOnlyStore = errors.New("the profile store is read-only")
+
+// profileStores creates a store by the name used in the store setting from
+// the storeURL setting. Other backends register themselves here.
+var profileStores = map[string]func(location string) (ProfileStore, error){
+	"file": newFileStore,
+	"http": newHTTPStore,
+	"git":  newGitStore,
+}
+
+// storeRefreshInterval is how long remote stores serve their cached copy
+// before fetching again, so prompts and hooks don't hit the network each time
+const storeRefreshInterval = 5 * time.Minute
+
+// profileStoreNames returns the registered store names in alphabetical order
+func profileStoreNames() []string {
+	names := make([]string, 0, len(profileStores))
+	for name := range profileStores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredStore returns the store selected by settings, or nil when the
+// profiles are kept in the local config file
+func configuredStore(settings Settings) (ProfileStore, error) {
+	if settings.Store == "" || settings.Store == "file" && settings.StoreURL == "" {
+		return nil, nil
+	}
+	newStore, ok := profileStores[settings.Store]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile store '%s' (available: %s)", settings.Store, strings.Join(profileStoreNames(), ", "))
+	}
+	if settings.StoreURL == "" {
+		return nil, fmt.Errorf("the %s profile store needs the storeURL setting", settings.Store)
+	}
+	return newStore(settings.StoreURL)
+}
+
+// copyProfiles returns a deep copy of profiles, so edits to the maps
+// inside a profile can be detected by comparing with the original
+func copyProfiles(profiles map[string]Profile) map[string]Profile {
+	data, _ := json.Marshal(profiles)
+	copied := map[string]Profile{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// loadStoreProfiles replaces the profiles of config with those of the
+// configured store, remembering both to tell on save what changed
+func loadStoreProfiles(config *Config) error {
+	store, err := configuredStore(config.Settings)
+	if err != nil {
+		// Keep the config usable so the settings can be fixed
+		fmt.Fprintf(os.Stderr, "⚠️  %v; using the local profiles\n", err)
+		return nil
+	}
+	if store == nil {
+		return nil
+	}
+
+	profiles, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load profiles from the %s store: %w", config.Settings.Store, err)
+	}
+	if profiles == nil {
+		profiles = map[string]Profile{}
+	}
+
+	config.store = store
+	config.localProfiles = config.Profiles
+	config.storeProfiles = copyProfiles(profiles)
+	config.Profiles = profiles
+	return nil
+}
+
+// saveStoreProfiles writes changed profiles back to the store, returning
+// the config to write locally: the same settings with the local profiles
+func saveStoreProfiles(config *Config) (*Config, error) {
+	if config.store == nil {
+		return config, nil
+	}
+
+	if !reflect.DeepEqual(config.Profiles, config.storeProfiles) {
+		if err := config.store.Save(config.Profiles); err != nil {
+			if errors.Is(err, errReadOnlyStore) {
+				fmt.Printf("❌ Profiles come from the read-only %s store (%s)\n", config.Settings.Store, config.Settings.StoreURL)
+			}
+			return nil, err
+		}
+		config.storeProfiles = copyProfiles(config.Profiles)
+	}
+
+	local := *config
+	local.Profiles = config.localProfiles
+	if local.Profiles == nil {
+		local.Profiles = map[string]Profile{}
+	}
+	return &local, nil
+}
+
+// marshalProfiles encodes profiles in the config file format
+func marshalProfiles(profiles map[string]Profile) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Profiles map[string]Profile `json:"profiles"`
+	}{profiles}, "", "  ")
+}
+
+// encodeStoreData encodes profiles for a store, encrypted with the config
+// passphrase while the config is locked so the store's copy of the Env
+// secrets is covered by `git usr lock` as well
+func encodeStoreData(profiles map[string]Profile) ([]byte, error) {
+	data, err := marshalProfiles(profiles)
+	if err != nil {
+		return nil, err
+	}
+	return encodeConfigData(data)
+}
+
+// parseStoreData parses the profiles of a store, decrypting them when they
+// were saved from a locked config
+func parseStoreData(data []byte) (*Config, error) {
+	if envelope, ok := parseEncryptedConfig(data); ok {
+		if configPassphrase == "" {
+			passphrase, err := readPassphrase("Profile store passphrase: ")
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errConfigLocked, err)
+			}
+			configPassphrase = passphrase
+		}
+		plain, err := decryptConfig(envelope, configPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errConfigLocked, err)
+		}
+		data = plain
+	}
+	return parseConfig(data)
+}
+
+// fileStore keeps the profiles in a JSON file of their own, e.g. on a
+// shared or synced drive
+type fileStore struct {
+	path string
+}
+
+func newFileStore(location string) (ProfileStore, error) {
+	path, err := expandHome(location)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{path: path}, nil
+}
+
+func (s *fileStore) Load() (map[string]Profile, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Profile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseStoreData(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile file %s: %w", s.path, err)
+	}
+	return config.Profiles, nil
+}
+
+func (s *fileStore) Save(profiles map[string]Profile) error {
+	unlock, err := acquireFileLock(s.path+".lock", configLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := encodeStoreData(profiles)
+	if err != nil {
+		return err
+	}
+	if configLocked {
+		return writeFileAtomic(s.path, data, 0600, true)
+	}
+	return writeFileAtomic(s.path, data, 0644, false)
+}
+
+// storeCachePath returns a cache location in the config directory unique
+// to a store and location
+func storeCachePath(kind, location string) (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(configDir, "stores", kind+"-"+hex.EncodeToString(sum[:6])), nil
+}
+
+// isFresh reports whether path was modified within storeRefreshInterval
+func isFresh(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < storeRefreshInterval
+}
+
+// httpStore reads the profiles from an HTTP(S) URL, e.g. a file published
+// by the team. It can't be written.
+type httpStore struct {
+	url       string
+	cachePath string
+}
+
+func newHTTPStore(location string) (ProfileStore, error) {
+	if !isRemoteSource(location) {
+		return nil, fmt.Errorf("storeURL must be an http(s) URL for the http store")
+	}
+	cachePath, err := storeCachePath("http", location)
+	if err != nil {
+		return nil, err
+	}
+	return &httpStore{url: location, cachePath: cachePath + ".json"}, nil
+}
+
+func (s *httpStore) Load() (map[string]Profile, error) {
+	if !isFresh(s.cachePath) {
+		data, err := fetchSource(s.url)
+		if err == nil {
+			_, err = parseStoreData(data)
+		}
+		if err == nil {
+			if err := os.MkdirAll(filepath.Dir(s.cachePath), 0755); err != nil {
+				return nil, err
+			}
+			if err := writeFileAtomic(s.cachePath, data, 0644, false); err != nil {
+				return nil, err
+			}
+		} else if _, statErr := os.Stat(s.cachePath); statErr != nil {
+			return nil, err
+		} else {
+			// Wait another interval before retrying an unreachable source
+			now := time.Now()
+			os.Chtimes(s.cachePath, now, now)
+			fmt.Fprintf(os.Stderr, "⚠️  Using cached profiles, %s failed: %v\n", s.url, err)
+		}
+	}
+
+	data, err := os.ReadFile(s.cachePath)
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseStoreData(data)
+	if err != nil {
+		return nil, err
+	}
+	return config.Profiles, nil
+}
+
+func (s *httpStore) Save(profiles map[string]Profile) error {
+	return errReadOnlyStore
+}
+
+// remoteFileStore keeps the profiles in a JSON file behind HTTP GET and
+// PUT, as S3-compatible object storage and WebDAV servers offer. Reads are
+// cached like the http store's. Writes send the ETag of the copy they
+// were based on, so a change made elsewhere in the meantime isn't lost
+type remoteFileStore struct {
+	kind      string
+	url       string
+	cachePath string
+	client    *http.Client
+	// authorize adds credentials to a request with the given body
+	authorize func(req *http.Request, body []byte) error
+}
+
+func newRemoteFileStore(kind, url string, authorize func(req *http.Request, body []byte) error) (*remoteFileStore, error) {
+	cachePath, err := storeCachePath(kind, url)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteFileStore{
+		kind:      kind,
+		url:       url,
+		cachePath: cachePath + ".json",
+		client:    &http.Client{Timeout: 30 * time.Second},
+		authorize: authorize,
+	}, nil
+}
+
+// request sends an authorized request for the file
+func (s *remoteFileStore) request(method string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if err := s.authorize(req, body); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, data, err
+}
+
+// writeCache keeps a copy of the file and the ETag it had
+func (s *remoteFileStore) writeCache(data []byte, etag string) error {
+	if err := os.MkdirAll(filepath.Dir(s.cachePath), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.cachePath+".etag", []byte(etag), 0600, true); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.cachePath, data, 0600, true)
+}
+
+// fetch downloads the file into the cache. A missing file holds no profiles
+func (s *remoteFileStore) fetch() error {
+	resp, data, err := s.request("GET", nil, nil)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		data = []byte(`{"profiles": {}}`)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("GET %s: %s", s.url, resp.Status)
+	}
+	if _, err := parseStoreData(data); err != nil {
+		return fmt.Errorf("invalid profile file %s: %w", s.url, err)
+	}
+	return s.writeCache(data, resp.Header.Get("ETag"))
+}
+
+func (s *remoteFileStore) Load() (map[string]Profile, error) {
+	if !isFresh(s.cachePath) {
+		if err := s.fetch(); err != nil {
+			if _, statErr := os.Stat(s.cachePath); statErr != nil {
+				return nil, err
+			}
+			// Wait another interval before retrying an unreachable store
+			now := time.Now()
+			os.Chtimes(s.cachePath, now, now)
+			fmt.Fprintf(os.Stderr, "⚠️  Using cached profiles, %s failed: %v\n", s.url, err)
+		}
+	}
+	return (&fileStore{path: s.cachePath}).Load()
+}
+
+func (s *remoteFileStore) Save(profiles map[string]Profile) error {
+	data, err := encodeStoreData(profiles)
+	if err != nil {
+		return err
+	}
+
+	// Without an ETag the file didn't exist when read, so it must not
+	// have been created elsewhere since either
+	header := http.Header{"Content-Type": {"application/json"}}
+	if etag, err := os.ReadFile(s.cachePath + ".etag"); err == nil && len(etag) > 0 {
+		header.Set("If-Match", string(etag))
+	} else {
+		header.Set("If-None-Match", "*")
+	}
+	resp, _, err := s.request("PUT", data, header)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		// Fetch again on the next run
+		os.Chtimes(s.cachePath, time.Unix(0, 0), time.Unix(0, 0))
+		return fmt.Errorf("the profiles at %s were changed elsewhere since they were read; run the command again", s.url)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: %s", s.url, resp.Status)
+	}
+	return s.writeCache(data, resp.Header.Get("ETag"))
+}
+
+// gitStore keeps the profiles as profiles.json in a git repository, cloned
+// into the config directory. Saving commits and pushes the change.
+type gitStore struct {
+	url string
+	dir string
+}
+
+func newGitStore(location string) (ProfileStore, error) {
+	dir, err := storeCachePath("git", location)
+	if err != nil {
+		return nil, err
+	}
+	return &gitStore{url: location, dir: dir}, nil
+}
+
+// sync clones the repository or pulls it when the last fetch is stale
+func (s *gitStore) sync() error {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		if err := os.MkdirAll(filepath.Dir(s.dir), 0755); err != nil {
+			return err
+		}
+		if _, err := runGit("", "clone", "--quiet", s.url, s.dir); err != nil {
+			return fmt.Errorf("git clone %s: %w", s.url, err)
+		}
+		return os.WriteFile(filepath.Join(s.dir, ".git", "git-usr-synced"), nil, 0644)
+	}
+
+	// The stamp is written on failures too, so offline use doesn't retry on
+	// every call
+	stamp := filepath.Join(s.dir, ".git", "git-usr-synced")
+	if isFresh(stamp) {
+		return nil
+	}
+	os.WriteFile(stamp, nil, 0644)
+	if _, err := runGit(s.dir, "pull", "--quiet", "--ff-only"); err != nil {
+		// Work offline with the last copy
+		fmt.Fprintf(os.Stderr, "⚠️  Using cached profiles, git pull from %s failed: %v\n", s.url, err)
+	}
+	return nil
+}
+
+func (s *gitStore) Load() (map[string]Profile, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return (&fileStore{path: filepath.Join(s.dir, "profiles.json")}).Load()
+}
+
+func (s *gitStore) Save(profiles map[string]Profile) error {
+	if err := s.sync(); err != nil {
+		return err
+	}
+	if err := (&fileStore{path: filepath.Join(s.dir, "profiles.json")}).Save(profiles); err != nil {
+		return err
+	}
+
+	if _, err := runGit(s.dir, "add", "profiles.json"); err != nil {
+		return err
+	}
+	if status, err := runGit(s.dir, "status", "--porcelain", "profiles.json"); err == nil && strings.TrimSpace(status) == "" {
+		return nil
+	}
+	// A fresh clone of an empty repository has no HEAD yet
+	head, _ := runGit(s.dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	if _, err := runGit(s.dir, "commit", "--quiet", "-m", "Update profiles with git-usr"); err != nil {
+		return fmt.Errorf("git commit: %w", err)
+	}
+	if _, err := runGit(s.dir, "push", "--quiet"); err != nil {
+		// Drop the unpushed commit, so the next pull isn't blocked by it
+		if head = strings.TrimSpace(head); head != "" {
+			runGit(s.dir, "reset", "--quiet", "--hard", head)
+		} else {
+			runGit(s.dir, "update-ref", "-d", "HEAD")
+		}
+		return fmt.Errorf("git push to %s: %w", s.url, err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfiguredStore tests selecting a store from settings
+func TestConfiguredStore(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+
+	for _, settings := range []Settings{{}, {Store: "file"}} {
+		if store, err := configuredStore(settings); store != nil || err != nil {
+			t.Errorf("Expected the local config for %+v, got %v (%v)", settings, store, err)
+		}
+	}
+	if _, err := configuredStore(Settings{Store: "nope", StoreURL: "x"}); err == nil {
+		t.Error("Expected an error for an unknown store")
+	}
+	if _, err := configuredStore(Settings{Store: "git"}); err == nil {
+		t.Error("Expected an error for a store without storeURL")
+	}
+	if _, err := configuredStore(Settings{Store: "http", StoreURL: "/tmp/profiles.json"}); err == nil {
+		t.Error("Expected an error for an http store with a local path")
+	}
+}
+
+// TestFileStore tests saving and loading profiles from a separate file
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.json")
+	store, err := newFileStore(path)
+	if err != nil {
+		t.Fatalf("newFileStore failed: %v", err)
+	}
+
+	profiles, err := store.Load()
+	if err != nil || len(profiles) != 0 {
+		t.Fatalf("Expected no profiles before the first save, got %v (%v)", profiles, err)
+	}
+
+	want := map[string]Profile{"work": {Name: "Work", Email: "work@company.com"}}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	profiles, err = store.Load()
+	if err != nil || profiles["work"].Email != "work@company.com" {
+		t.Errorf("Expected the saved profiles, got %v (%v)", profiles, err)
+	}
+}
+
+// TestFileStoreLocked tests that a locked config keeps the store encrypted
+func TestFileStoreLocked(t *testing.T) {
+	configPassphrase, configLocked = "hunter2", true
+	t.Cleanup(func() { configPassphrase, configLocked = "", false })
+
+	path := filepath.Join(t.TempDir(), "shared.json")
+	store, _ := newFileStore(path)
+	want := map[string]Profile{"work": {Name: "Work", Email: "work@company.com", Env: map[string]string{"TOKEN": "secret-token"}}}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if _, ok := parseEncryptedConfig(data); !ok || strings.Contains(string(data), "secret-token") {
+		t.Fatalf("Expected an encrypted store file, got %s", data)
+	}
+	profiles, err := store.Load()
+	if err != nil || profiles["work"].Env["TOKEN"] != "secret-token" {
+		t.Errorf("Expected the saved profiles, got %v (%v)", profiles, err)
+	}
+}
+
+// TestGitStorePushFailure tests that a failed push leaves no local commit
+func TestGitStorePushFailure(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(dir, "profiles.json"))
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+	remote := filepath.Join(dir, "remote.git")
+	if err := runGitIn(dir, "init", "--quiet", "--bare", remote); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+
+	store, _ := newGitStore(remote)
+	gs := store.(*gitStore)
+	if err := store.Save(map[string]Profile{"work": {Name: "Work", Email: "work@company.com"}}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	head, _ := runGit(gs.dir, "rev-parse", "HEAD")
+
+	// The remote rejects every push from now on
+	os.WriteFile(filepath.Join(remote, "hooks", "pre-receive"), []byte("#!/bin/sh\nexit 1\n"), 0755)
+	if err := store.Save(map[string]Profile{}); err == nil {
+		t.Fatal("Expected the rejected push to fail the save")
+	}
+	if after, _ := runGit(gs.dir, "rev-parse", "HEAD"); after != head {
+		t.Errorf("Expected the unpushed commit to be dropped, HEAD moved from %s to %s", head, after)
+	}
+}
+
+// TestHTTPStore tests that the http store caches and can't be written
+func TestHTTPStore(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"profiles": {"team": {"name": "Team", "email": "team@company.com"}}}`))
+	}))
+	defer server.Close()
+
+	store, err := newHTTPStore(server.URL)
+	if err != nil {
+		t.Fatalf("newHTTPStore failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		profiles, err := store.Load()
+		if err != nil || profiles["team"].Email != "team@company.com" {
+			t.Fatalf("Expected the served profiles, got %v (%v)", profiles, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the second load to use the cache, got %d requests", requests)
+	}
+
+	if err := store.Save(nil); !errors.Is(err, errReadOnlyStore) {
+		t.Errorf("Expected errReadOnlyStore, got %v", err)
+	}
+}
+
+// TestSaveStoreProfiles tests that the store gets the profiles and the
+// local config keeps its own
+func TestSaveStoreProfiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(dir, "profiles.json"))
+	storePath := filepath.Join(dir, "shared.json")
+
+	config := defaultConfig()
+	config.Settings.Store = "file"
+	config.Settings.StoreURL = storePath
+	if err := loadStoreProfiles(config); err != nil {
+		t.Fatalf("loadStoreProfiles failed: %v", err)
+	}
+	if len(config.Profiles) != 0 {
+		t.Fatalf("Expected the empty store's profiles, got %v", config.Profiles)
+	}
+
+	config.Profiles["shared"] = Profile{Name: "Shared", Email: "shared@company.com"}
+	local, err := saveStoreProfiles(config)
+	if err != nil {
+		t.Fatalf("saveStoreProfiles failed: %v", err)
+	}
+	if _, exists := local.Profiles["shared"]; exists {
+		t.Error("Expected the store's profile to stay out of the local config")
+	}
+	if _, exists := local.Profiles["work"]; !exists {
+		t.Error("Expected the local config to keep its profiles")
+	}
+
+	data, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatalf("Store file missing: %v", err)
+	}
+	stored, err := parseConfig(data)
+	if err != nil || stored.Profiles["shared"].Email != "shared@company.com" {
+		t.Errorf("Expected the profile in the store, got %s (%v)", data, err)
+	}
+}
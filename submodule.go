@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// listSubmodules returns the paths of the initialized submodules of the
+// repository at top, nested ones included, relative to top. Submodules
+// that were never checked out are left out since they have no config
+func listSubmodules(top string) ([]string, error) {
+	out, err := runGit(top, "submodule", "foreach", "--quiet", "--recursive", `printf '%s\0' "$displaypath"`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list submodules: %w", err)
+	}
+	var paths []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// applyToSubmodules switches every initialized submodule of the
+// repository at top to a profile, the same way the repository was
+func applyToSubmodules(config *Config, profileName, top string) error {
+	paths, err := listSubmodules(top)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Println("   No initialized submodules")
+		return nil
+	}
+
+	// The git helpers work on the current directory
+	previous, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(previous)
+
+	failed := 0
+	for _, path := range paths {
+		if err := os.Chdir(filepath.Join(top, path)); err != nil {
+			fmt.Printf("   ✗ %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		warnings, err := applyLocalProfile(config, profileName)
+		if err != nil {
+			fmt.Printf("   ✗ %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("   ✓ %s\n", path)
+		for _, warning := range warnings {
+			fmt.Printf("     ⚠️  %s\n", warning)
+		}
+	}
+
+	fmt.Printf("📦 Applied '%s' to %d of %d submodule(s)\n", profileName, len(paths)-failed, len(paths))
+	if failed > 0 {
+		return fmt.Errorf("%d submodule(s) not updated", failed)
+	}
+	return nil
+}
+
+// runApply handles the apply command: switching this repository to a
+// profile and, with --submodules, its submodules too
+func runApply(args []string) error {
+	usage := "Usage: git usr apply <profile> [--submodules]"
+	profileName, submodules := "", false
+	for _, arg := range args {
+		switch {
+		case arg == "--submodules":
+			submodules = true
+		case strings.HasPrefix(arg, "-") || profileName != "":
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		default:
+			profileName = arg
+		}
+	}
+	if profileName == "" {
+		fmt.Println("❌ Profile name required!")
+		fmt.Println(usage)
+		return fmt.Errorf("profile name required")
+	}
+
+	top, err := gitConfig.WorkTree("")
+	if isUnsafeRepository(err) {
+		return err
+	}
+	if err != nil {
+		fmt.Println("❌ Not inside a git repository")
+		return err
+	}
+
+	if err := switchProfile(profileName, "local"); err != nil {
+		return err
+	}
+	if !submodules {
+		return nil
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	return applyToSubmodules(config, profileName, top)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// tagPolicyProblems returns why a profile may not tag a release in a
+// repository with remotes: tags must be signed, by a filled-in identity
+// that the remotes belong to
+func tagPolicyProblems(config *Config, profileName string, remotes []string) []string {
+	profile := config.Profiles[profileName]
+	var problems []string
+	if !signsCommits(profile) {
+		problems = append(problems, fmt.Sprintf("'%s' has no signing key (set one up with 'git usr keys setup %s')", profileName, profileName))
+	}
+
+	ctx := lintContext{Config: &Config{Profiles: map[string]Profile{profileName: profile}}, Now: time.Now()}
+	for _, finding := range lintPlaceholders(ctx) {
+		problems = append(problems, fmt.Sprintf("'%s' %s", profileName, finding.Problem))
+	}
+
+	if expected := expectedProfiles(config.Profiles, remotes); len(expected) > 0 && !containsString(expected, profileName) {
+		problems = append(problems, fmt.Sprintf("the remote belongs to '%s', not '%s'", strings.Join(expected, "' or '"), profileName))
+	}
+	return problems
+}
+
+// tagArgs returns the git arguments creating a signed tag with a profile's
+// signing settings, passed with -c so the repository config is untouched
+func tagArgs(profile Profile, gitTagArgs []string) []string {
+	var args []string
+	for _, key := range signingKeys(profile) {
+		args = append(args, "-c", key.Key+"="+key.Value)
+	}
+	return append(append(args, "tag", "-s"), gitTagArgs...)
+}
+
+// createTag tags a release as a profile. The identity comes from the
+// environment and the signing settings from -c options, so nothing is
+// switched and there is nothing to restore afterwards, even on failure
+func createTag(profileName, tagName string, gitTagArgs []string, commit ...string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profile, exists := config.Profiles[profileName]
+	if !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		fmt.Println("\nAvailable profiles:", getProfileNames(config.Profiles))
+		return errProfileNotFound
+	}
+
+	if problems := tagPolicyProblems(config, profileName, getRemoteURLs("")); len(problems) > 0 {
+		fmt.Printf("❌ Not tagging %s:\n", tagName)
+		for _, problem := range problems {
+			fmt.Printf("   %s\n", problem)
+		}
+		return fmt.Errorf("tag policy not met")
+	}
+	if status, ok := hardwareTokenStatus(profile); ok && status.Problem != "" {
+		fmt.Printf("❌ Not tagging %s: %s\n", tagName, status.Problem)
+		if status.Fix != "" {
+			fmt.Printf("   %s\n", status.Fix)
+		}
+		return fmt.Errorf("signing token not available")
+	}
+
+	env := os.Environ()
+	for _, v := range profileEnv(profile) {
+		env = append(env, v.Key+"="+v.Value)
+	}
+	cmd := exec.Command("git", tagArgs(profile, append(append(gitTagArgs, tagName), commit...))...)
+	cmd.Env = env
+	attachTerminal(cmd)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &ExitError{Code: exitErr.ExitCode(), Err: err}
+		}
+		return err
+	}
+
+	committerName, committerEmail := committerIdentity(profile)
+	fmt.Printf("✅ Signed tag %s as %s ('%s' profile)\n", tagName, formatAddress(committerName, committerEmail), profileName)
+	return nil
+}
+
+// runTag handles the tag command
+func runTag(args []string) error {
+	usage := "Usage: git usr tag <profile> <tagname> [<commit>] [-m <message>] [-f]"
+	if len(args) < 2 {
+		fmt.Println(usage)
+		return fmt.Errorf("profile and tag name required")
+	}
+
+	profileName, tagName := args[0], args[1]
+	var gitTagArgs, commit []string
+	for i := 2; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-m" || arg == "-F":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			gitTagArgs = append(gitTagArgs, arg, args[i+1])
+			i++
+		case arg == "-f" || arg == "--force":
+			gitTagArgs = append(gitTagArgs, arg)
+		case !strings.HasPrefix(arg, "-") && len(commit) == 0:
+			commit = append(commit, arg)
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+	return createTag(profileName, tagName, gitTagArgs, commit...)
+}
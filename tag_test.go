@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTagPolicyProblems tests the release tagging policy
+func TestTagPolicyProblems(t *testing.T) {
+	config := &Config{Profiles: map[string]Profile{
+		"release":  {Name: "Release Bot", Email: "release@acme.io", SigningKey: "~/.ssh/release.pub", SigningFormat: "ssh", HostAliases: map[string]string{"github.com": "github.com-acme"}},
+		"personal": {Name: "Priya Patel", Email: "priya@home.dev", HostAliases: map[string]string{"github.com": "github.com-priya"}},
+		"seed":     {Name: "Your Work Name", Email: "you@work.com", SigningKey: "ABCD"},
+	}}
+
+	if problems := tagPolicyProblems(config, "release", []string{"git@github.com-acme:acme/app.git"}); len(problems) != 0 {
+		t.Errorf("release: %q", problems)
+	}
+
+	problems := tagPolicyProblems(config, "personal", []string{"git@github.com-acme:acme/app.git"})
+	if len(problems) != 2 || !strings.Contains(problems[0], "no signing key") || !strings.Contains(problems[1], "belongs to 'release'") {
+		t.Errorf("personal: %q", problems)
+	}
+
+	if problems := tagPolicyProblems(config, "seed", nil); len(problems) == 0 {
+		t.Error("placeholder identity allowed to tag")
+	}
+}
+
+// TestTagArgs tests passing signing settings without touching the config
+func TestTagArgs(t *testing.T) {
+	profile := Profile{SigningKey: "~/.ssh/release.pub", SigningFormat: "ssh"}
+	got := strings.Join(tagArgs(profile, []string{"-m", "Release 1", "v1"}), " ")
+	expected := "-c gpg.format=ssh -c user.signingkey=~/.ssh/release.pub -c commit.gpgsign=true -c tag.gpgsign=true tag -s -m Release 1 v1"
+	if got != expected {
+		t.Errorf("tagArgs = %q, expected %q", got, expected)
+	}
+}
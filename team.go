@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sshSignatureNamespace is the ssh-keygen -Y namespace team files are signed with
+const sshSignatureNamespace = "git-usr"
+
+// TeamPullOptions controls how a team profile source is fetched and verified
+type TeamPullOptions struct {
+	Source      string
+	Signature   string
+	SSHSigners  string
+	SSHIdentity string
+	MinisignKey string
+	SkipVerify  bool
+	DryRun      bool
+}
+
+// isRemoteSource reports whether source should be fetched over HTTP(S)
+func isRemoteSource(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// fetchSource reads a local path or downloads an HTTP(S) URL
+func fetchSource(source string) ([]byte, error) {
+	if !isRemoteSource(source) {
+		return os.ReadFile(source)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// writeTempFile writes data to a temporary file and returns its path
+func writeTempFile(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// verifySSHSignature verifies data against a detached `ssh-keygen -Y sign`
+// signature made by identity, as listed in an allowed signers file
+func verifySSHSignature(data, signature []byte, allowedSigners, identity string) error {
+	sigPath, err := writeTempFile("git-usr-*.sig", signature)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sigPath)
+
+	cmd := exec.Command("ssh-keygen", "-Y", "verify",
+		"-f", allowedSigners,
+		"-I", identity,
+		"-n", sshSignatureNamespace,
+		"-s", sigPath)
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ssh signature verification failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// verifyMinisignSignature verifies data against a detached minisign signature
+func verifyMinisignSignature(data, signature []byte, publicKey string) error {
+	dataPath, err := writeTempFile("git-usr-*.json", data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dataPath)
+
+	sigPath, err := writeTempFile("git-usr-*.minisig", signature)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sigPath)
+
+	// Accept either a public key file or the base64 key itself
+	keyFlag := "-P"
+	if _, err := os.Stat(publicKey); err == nil {
+		keyFlag = "-p"
+	}
+
+	cmd := exec.Command("minisign", "-V", "-q", "-m", dataPath, "-x", sigPath, keyFlag, publicKey)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("minisign verification failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// verifyTeamSource checks the detached signature of a team profile source
+func verifyTeamSource(data []byte, opts TeamPullOptions) error {
+	switch {
+	case opts.SSHSigners != "":
+		if opts.SSHIdentity == "" {
+			return fmt.Errorf("--identity is required with --ssh-signers")
+		}
+		sigSource := opts.Signature
+		if sigSource == "" {
+			sigSource = opts.Source + ".sig"
+		}
+		signature, err := fetchSource(sigSource)
+		if err != nil {
+			return fmt.Errorf("failed to read signature %s: %w", sigSource, err)
+		}
+		return verifySSHSignature(data, signature, opts.SSHSigners, opts.SSHIdentity)
+
+	case opts.MinisignKey != "":
+		sigSource := opts.Signature
+		if sigSource == "" {
+			sigSource = opts.Source + ".minisig"
+		}
+		signature, err := fetchSource(sigSource)
+		if err != nil {
+			return fmt.Errorf("failed to read signature %s: %w", sigSource, err)
+		}
+		return verifyMinisignSignature(data, signature, opts.MinisignKey)
+
+	case opts.SkipVerify:
+		return nil
+
+	default:
+		return fmt.Errorf("no trust anchor given: use --ssh-signers/--identity or --minisign-key (or --insecure-skip-verify)")
+	}
+}
+
+// teamPull fetches a team profile source, verifies it and merges its
+// profiles into the local config
+func teamPull(opts TeamPullOptions) error {
+	data, err := fetchSource(opts.Source)
+	if err != nil {
+		fmt.Printf("❌ Failed to read team source: %v\n", err)
+		return err
+	}
+
+	if err := verifyTeamSource(data, opts); err != nil {
+		fmt.Printf("❌ Refusing untrusted team source: %v\n", err)
+		return err
+	}
+	if opts.SkipVerify {
+		fmt.Println("⚠️  Signature verification skipped")
+	} else {
+		fmt.Println("🔏 Signature verified")
+	}
+
+	teamConfig, err := parseConfig(data)
+	if err != nil {
+		fmt.Printf("❌ Invalid team source: %v\n", err)
+		return err
+	}
+	teamProfiles := teamConfig.Profiles
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles := config.Profiles
+
+	names := make([]string, 0, len(teamProfiles))
+	for name := range teamProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	added, updated := 0, 0
+	for _, name := range names {
+		profile := teamProfiles[name]
+		existing, exists := profiles[name]
+		switch {
+		case !exists:
+			fmt.Printf("   + %s (%s <%s>)\n", name, profile.Name, profile.Email)
+			added++
+		case !reflect.DeepEqual(existing, profile):
+			fmt.Printf("   ~ %s (%s <%s>)\n", name, profile.Name, profile.Email)
+			updated++
+		default:
+			continue
+		}
+		profiles[name] = profile
+	}
+
+	if opts.DryRun {
+		fmt.Printf("\nDry run: %d to add, %d to update\n", added, updated)
+		return nil
+	}
+
+	if added+updated > 0 {
+		if err := saveConfig(config); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✅ Team profiles pulled: %d added, %d updated\n", added, updated)
+	return nil
+}
+
+// runTeam handles the team command
+func runTeam(args []string) error {
+	usage := "Usage: git usr team pull <path|url> [--sig <path|url>] (--ssh-signers <file> --identity <principal> | --minisign-key <key>) [--dry-run]"
+
+	if len(args) < 2 || args[0] != "pull" {
+		fmt.Println(usage)
+		return fmt.Errorf("invalid team command")
+	}
+
+	opts := TeamPullOptions{}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		value := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch arg {
+		case "--sig":
+			opts.Signature = value()
+		case "--ssh-signers":
+			opts.SSHSigners = value()
+		case "--identity":
+			opts.SSHIdentity = value()
+		case "--minisign-key":
+			opts.MinisignKey = value()
+		case "--insecure-skip-verify":
+			opts.SkipVerify = true
+		case "--dry-run":
+			opts.DryRun = true
+		default:
+			if strings.HasPrefix(arg, "-") || opts.Source != "" {
+				fmt.Println(usage)
+				return fmt.Errorf("unexpected argument: %s", arg)
+			}
+			opts.Source = arg
+		}
+	}
+
+	if opts.Source == "" {
+		fmt.Println(usage)
+		return fmt.Errorf("team source required")
+	}
+
+	return teamPull(opts)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVerifySSHSignature tests verification of ssh-keygen -Y signatures
+func TestVerifySSHSignature(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+
+	dir := t.TempDir()
+	key := filepath.Join(dir, "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen failed: %v: %s", err, out)
+	}
+
+	data := []byte(`{"work":{"name":"John","email":"john@work.com"}}`)
+	dataPath := filepath.Join(dir, "team.json")
+	if err := os.WriteFile(dataPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("ssh-keygen", "-Y", "sign", "-f", key, "-n", sshSignatureNamespace, dataPath).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen sign failed: %v: %s", err, out)
+	}
+
+	pub, _ := os.ReadFile(key + ".pub")
+	signers := filepath.Join(dir, "allowed_signers")
+	if err := os.WriteFile(signers, []byte("lead@example.com "+strings.TrimSpace(string(pub))+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	signature, _ := os.ReadFile(dataPath + ".sig")
+
+	if err := verifySSHSignature(data, signature, signers, "lead@example.com"); err != nil {
+		t.Errorf("Valid signature rejected: %v", err)
+	}
+	if err := verifySSHSignature([]byte(`{"evil":{}}`), signature, signers, "lead@example.com"); err == nil {
+		t.Error("Tampered data accepted")
+	}
+	if err := verifySSHSignature(data, signature, signers, "other@example.com"); err == nil {
+		t.Error("Signature accepted for wrong identity")
+	}
+}
+
+// TestVerifyTeamSourceRequiresTrustAnchor tests that unsigned sources are rejected
+func TestVerifyTeamSourceRequiresTrustAnchor(t *testing.T) {
+	if err := verifyTeamSource([]byte("{}"), TeamPullOptions{Source: "team.json"}); err == nil {
+		t.Error("Expected error without a trust anchor")
+	}
+	if err := verifyTeamSource([]byte("{}"), TeamPullOptions{Source: "team.json", SkipVerify: true}); err != nil {
+		t.Errorf("Unexpected error with --insecure-skip-verify: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// testdataPassphrase is the passphrase of the generated locked config fixture
+const testdataPassphrase = "git-usr-testdata"
+
+// TestdataManifest describes the fixtures written by generateTestdata
+type TestdataManifest struct {
+	Version    string             `json:"version"`
+	Root       string             `json:"root"`
+	Passphrase string             `json:"passphrase"`
+	Profiles   map[string]Profile `json:"profiles"`
+	Configs    map[string]string  `json:"configs"`
+	Repos      map[string]string  `json:"repos"`
+}
+
+// testdataProfiles returns the sample profiles used across fixtures
+func testdataProfiles() map[string]Profile {
+	return map[string]Profile{
+		"work": {
+			Name:  "Test Worker",
+			Email: "worker@example.com",
+		},
+		"personal": {
+			Name:  "Test Person",
+			Email: "person@example.org",
+		},
+	}
+}
+
+// testdataConfigs returns the sample configs covering the parts of the
+// schema beyond profiles, by fixture name. Directories are below root
+func testdataConfigs(root string, profiles map[string]Profile) map[string]*Config {
+	emoji, backups := false, 3
+	return map[string]*Config{
+		"rules": {
+			Profiles: profiles,
+			Rules: []Rule{
+				{Remote: "github.com/acme/**", Profile: "work"},
+				{Remote: "gitlab.com/**", Branch: "release/*", EmailDomain: "example.com"},
+				{Remote: "*/oss/**", EmailDomain: "example.org"},
+			},
+		},
+		"dir-rules": {
+			Profiles: profiles,
+			Rules: []Rule{
+				{Dir: filepath.ToSlash(filepath.Join(root, "src", "work")) + "/", Profile: "work"},
+				{Dir: "~/personal/", Branch: "main", Profile: "personal"},
+			},
+			Watch: map[string]string{
+				filepath.Join(root, "src", "work"): "work",
+				filepath.Join(root, "src", "any"):  "",
+			},
+		},
+		"settings": {
+			Profiles: profiles,
+			Settings: Settings{
+				DefaultScope:         "global",
+				Emoji:                &emoji,
+				Color:                "never",
+				DefaultProfile:       "work",
+				NpmSync:              "global",
+				SigningRequiredHosts: "github.com",
+				PromptFormat:         "%p <%e>",
+				Backups:              &backups,
+				UpdateCheck:          true,
+				Interop:              "off",
+			},
+		},
+		"coauthors": {
+			Profiles: profiles,
+			Coauthors: map[string]Coauthor{
+				"pat": {Name: "Pat Pair", Email: "pat@example.com"},
+			},
+			Archived: map[string]Profile{
+				"old": {Name: "Old Job", Email: "old@example.net"},
+			},
+			OnSwitch: []string{"~/.config/git-usr/on-switch.sh"},
+		},
+	}
+}
+
+// runGitIn runs a git command inside dir
+func runGitIn(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %v: %w: %s", args, err, out)
+	}
+	return nil
+}
+
+// generateTestdata writes sample config files and sandbox repos into root,
+// creating a temporary directory when root is empty
+func generateTestdata(root string) (*TestdataManifest, error) {
+	if root == "" {
+		dir, err := os.MkdirTemp("", "git-usr-testdata-")
+		if err != nil {
+			return nil, err
+		}
+		root = dir
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	configDir := filepath.Join(root, "config")
+	reposDir := filepath.Join(root, "repos")
+	for _, dir := range []string{configDir, reposDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	profiles := testdataProfiles()
+	manifest := &TestdataManifest{
+		Version:    version,
+		Root:       root,
+		Passphrase: testdataPassphrase,
+		Profiles:   profiles,
+		Configs:    map[string]string{},
+		Repos:      map[string]string{},
+	}
+
+	// Config files
+	valid, err := json.MarshalIndent(&Config{Profiles: profiles}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	locked, err := encryptConfig(valid, testdataPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string][]byte{
+		"valid":   valid,
+		"legacy":  legacy,
+		"empty":   []byte("{}"),
+		"corrupt": valid[:len(valid)/2],
+		"locked":  locked,
+	}
+	for name, config := range testdataConfigs(root, profiles) {
+		if configs[name], err = json.MarshalIndent(config, "", "  "); err != nil {
+			return nil, err
+		}
+	}
+	for name, data := range configs {
+		path := filepath.Join(configDir, name+".json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+		manifest.Configs[name] = path
+	}
+
+	// Sandbox repos
+	repos := map[string]*Profile{
+		"matching":     {Name: profiles["work"].Name, Email: profiles["work"].Email},
+		"mismatch":     {Name: "Unknown Person", Email: "unknown@example.net"},
+		"unconfigured": nil,
+	}
+	for name, identity := range repos {
+		path := filepath.Join(reposDir, name)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return nil, err
+		}
+		if err := runGitIn(path, "init", "--quiet"); err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			if err := runGitIn(path, "config", "--local", "user.name", identity.Name); err != nil {
+				return nil, err
+			}
+			if err := runGitIn(path, "config", "--local", "user.email", identity.Email); err != nil {
+				return nil, err
+			}
+		}
+		manifest.Repos[name] = path
+	}
+
+	notRepo := filepath.Join(reposDir, "not-a-repo")
+	if err := os.MkdirAll(notRepo, 0755); err != nil {
+		return nil, err
+	}
+	manifest.Repos["not-a-repo"] = notRepo
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(root, "manifest.json"), data, 0644); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// runTestdata handles the hidden testdata command
+func runTestdata(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		fmt.Println("Usage: git usr testdata generate [dir]")
+		return fmt.Errorf("unknown testdata command")
+	}
+
+	dir := ""
+	if len(args) > 1 {
+		dir = args[1]
+	}
+
+	manifest, err := generateTestdata(dir)
+	if err != nil {
+		fmt.Printf("❌ Failed to generate testdata: %v\n", err)
+		return err
+	}
+
+	fmt.Println(manifest.Root)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// trailerConfigKey is the git config key listing the trailers of the
+// profile switched to, which the prepare-commit-msg hook adds
+const trailerConfigKey = "usr.trailer"
+
+// trailerTokenPattern matches trailer tokens like Signed-off-by
+var trailerTokenPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// parseTrailer normalizes a profile trailer, "Token: value" or a bare
+// token that is signed by whoever commits, e.g. Signed-off-by
+func parseTrailer(value string) (string, error) {
+	token, text, _ := strings.Cut(value, ":")
+	token, text = strings.TrimSpace(token), strings.TrimSpace(text)
+	if !trailerTokenPattern.MatchString(token) {
+		return "", fmt.Errorf("trailer must be TOKEN: VALUE, or a bare TOKEN like Signed-off-by to sign as whoever commits")
+	}
+	if text == "" {
+		return token, nil
+	}
+	return token + ": " + text, nil
+}
+
+// resolveTrailer returns a trailer for a commit made as name and email,
+// filling in a bare token with them
+func resolveTrailer(trailer, name, email string) string {
+	if strings.Contains(trailer, ":") {
+		return trailer
+	}
+	return trailer + ": " + formatAddress(name, email)
+}
+
+// configuredTrailers returns the trailers of the profile switched to,
+// as git sees them here
+func configuredTrailers() []string {
+	values, err := gitConfig.GetAll("", "", trailerConfigKey)
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
+// commitTrailers returns the trailers for a commit made as name and email:
+// the profile's followed by the pair's. The profile's are returned even
+// when the pair's can't be
+func commitTrailers(name, email string) ([]string, error) {
+	var trailers []string
+	for _, trailer := range configuredTrailers() {
+		trailers = append(trailers, resolveTrailer(trailer, name, email))
+	}
+	coauthors, err := pairTrailers(name, email)
+	if err != nil {
+		return trailers, err
+	}
+	return append(trailers, coauthors...), nil
+}
+
+// appendCommitTrailers adds the commit's trailers to a commit message
+// file, as called from the prepare-commit-msg hook
+func appendCommitTrailers(messageFile string) error {
+	name, email, _ := getCurrentGitConfig()
+	trailers, err := commitTrailers(name, email)
+	if errors.Is(err, errConfigLocked) {
+		// Don't block the commit over the co-authors
+		fmt.Fprintln(os.Stderr, "⚠️  git-usr: the config is locked, so no Co-authored-by trailers were added; set GIT_USR_PASSPHRASE")
+	} else if err != nil {
+		return err
+	}
+	if len(trailers) == 0 {
+		return nil
+	}
+
+	// addIfDifferent keeps amends and re-edited messages free of duplicates
+	args := []string{"interpret-trailers", "--in-place", "--if-exists", "addIfDifferent"}
+	for _, trailer := range trailers {
+		args = append(args, "--trailer", trailer)
+	}
+	args = append(args, messageFile)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git interpret-trailers: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ensureTrailerHook makes sure the prepare-commit-msg hook adding a
+// profile's trailers runs where scope applies. Local switches install it
+// in the repository; global ones need the global hooks
+func ensureTrailerHook(scope string) error {
+	if global, err := getGlobalHooksDir(); err == nil && globalHooksPath() == global {
+		return nil
+	}
+	if scope == "global" {
+		fmt.Println("   Trailers are added by the prepare-commit-msg hook; run 'git usr hooks install --global' to add them in every repository")
+		return nil
+	}
+	_, err := installHooks([]string{"prepare-commit-msg"})
+	return err
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+// TestParseTrailer tests normalizing profile trailers
+func TestParseTrailer(t *testing.T) {
+	for value, want := range map[string]string{
+		"Signed-off-by":         "Signed-off-by",
+		" Signed-off-by: ":      "Signed-off-by",
+		"Team:payments":         "Team: payments",
+		"Refs:  PROJ-1, PROJ-2": "Refs: PROJ-1, PROJ-2",
+	} {
+		if got, err := parseTrailer(value); err != nil || got != want {
+			t.Errorf("parseTrailer(%q) = %q, %v, expected %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", ": value", "Two words: value", "-Token"} {
+		if _, err := parseTrailer(value); err == nil {
+			t.Errorf("parseTrailer(%q) accepted an invalid trailer", value)
+		}
+	}
+}
+
+// TestResolveTrailer tests signing bare tokens as whoever commits
+func TestResolveTrailer(t *testing.T) {
+	if got := resolveTrailer("Signed-off-by", "Jane Doe", "jane@acme.io"); got != "Signed-off-by: Jane Doe <jane@acme.io>" {
+		t.Errorf("resolveTrailer() = %q", got)
+	}
+	if got := resolveTrailer("Team: payments", "Jane Doe", "jane@acme.io"); got != "Team: payments" {
+		t.Errorf("resolveTrailer() = %q, expected the trailer as is", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+// compositions lists, per combining mark, pairs of a precomposed letter and
+// the letter it is built on, covering the Latin, Greek and Cyrillic blocks.
+// Derived from the canonical decompositions in UnicodeData.txt; letters
+// with several marks (e.g. Vietnamese) decompose in steps through this table.
+var compositions = map[rune]string{
+	// grave accent
+	'\u0300': "ÀAÈEÌIÒOÙUàaèeìiòoùuǛÜǜüǸNǹnḔĒḕēṐŌṑōẀWẁwẦÂầâẰĂằăỀÊềêỒÔồôỜƠờơỪƯừưỲYỳyЀЕЍИѐеѝи",
+	// acute accent
+	'\u0301': "ÁAÉEÍIÓOÚUÝYáaéeíióoúuýyĆCćcĹLĺlŃNńnŔRŕrŚSśsŹZźzǗÜǘüǴGǵgǺÅǻåǼÆǽæǾØǿøḈÇḉçḖĒḗēḮÏḯïḰKḱkḾMḿmṌÕṍõṒŌṓōṔPṕpṸŨṹũẂWẃwẤÂấâẮĂắăẾÊếêỐÔốôỚƠớơỨƯứưΆΑΈΕΉΗΊΙΌΟΎΥΏΩΐϊάαέεήηίιΰϋόούυώωЃГЌКѓгќк",
+	// circumflex accent
+	'\u0302': "ÂAÊEÎIÔOÛUâaêeîiôoûuĈCĉcĜGĝgĤHĥhĴJĵjŜSŝsŴWŵwŶYŷyẐZẑzẬẠậạỆẸệẹỘỌộọ",
+	// tilde
+	'\u0303': "ÃAÑNÕOãañnõoĨIĩiŨUũuṼVṽvẪÂẫâẴĂẵăẼEẽeỄÊễêỖÔỗôỠƠỡơỮƯữưỸYỹy",
+	// macron
+	'\u0304': "ĀAāaĒEēeĪIīiŌOōoŪUūuǕÜǖüǞÄǟäǠȦǡȧǢÆǣæǬǪǭǫȪÖȫöȬÕȭõȰȮȱȯȲYȳyḠGḡgḸḶḹḷṜṚṝṛӢИӣиӮУӯу",
+	// breve
+	'\u0306': "ĂAăaĔEĕeĞGğgĬIĭiŎOŏoŬUŭuḜȨḝȩẶẠặạЎУЙИйиўуӁЖӂжӐАӑаӖЕӗе",
+	// dot above
+	'\u0307': "ĊCċcĖEėeĠGġgİIŻZżzȦAȧaȮOȯoḂBḃbḊDḋdḞFḟfḢHḣhṀMṁmṄNṅnṖPṗpṘRṙrṠSṡsṤŚṥśṦŠṧšṨṢṩṣṪTṫtẆWẇwẊXẋxẎYẏyẛſ",
+	// diaeresis
+	'\u0308': "ÄAËEÏIÖOÜUäaëeïiöoüuÿyŸYḦHḧhṎÕṏõṺŪṻūẄWẅwẌXẍxẗtΪΙΫΥϊιϋυЁЕЇІёеїіӒАӓаӚӘӛәӜЖӝжӞЗӟзӤИӥиӦОӧоӪӨӫөӬЭӭэӰУӱуӴЧӵчӸЫӹы",
+	// hook above
+	'\u0309': "ẢAảaẨÂẩâẲĂẳăẺEẻeỂÊểêỈIỉiỎOỏoỔÔổôỞƠởơỦUủuỬƯửưỶYỷy",
+	// ring above
+	'\u030A': "ÅAåaŮUůuẘwẙy",
+	// double acute accent
+	'\u030B': "ŐOőoŰUűuӲУӳу",
+	// caron
+	'\u030C': "ČCčcĎDďdĚEěeĽLľlŇNňnŘRřrŠSšsŤTťtŽZžzǍAǎaǏIǐiǑOǒoǓUǔuǙÜǚüǦGǧgǨKǩkǮƷǯʒǰjȞHȟh",
+	// double grave accent
+	'\u030F': "ȀAȁaȄEȅeȈIȉiȌOȍoȐRȑrȔUȕuѶѴѷѵ",
+	// inverted breve
+	'\u0311': "ȂAȃaȆEȇeȊIȋiȎOȏoȒRȓrȖUȗu",
+	// horn
+	'\u031B': "ƠOơoƯUưu",
+	// dot below
+	'\u0323': "ḄBḅbḌDḍdḤHḥhḲKḳkḶLḷlṂMṃmṆNṇnṚRṛrṢSṣsṬTṭtṾVṿvẈWẉwẒZẓzẠAạaẸEẹeỊIịiỌOọoỢƠợơỤUụuỰƯựưỴYỵy",
+	// diaeresis below
+	'\u0324': "ṲUṳu",
+	// ring below
+	'\u0325': "ḀAḁa",
+	// comma below
+	'\u0326': "ȘSșsȚTțt",
+	// cedilla
+	'\u0327': "ÇCçcĢGģgĶKķkĻLļlŅNņnŖRŗrŞSşsŢTţtȨEȩeḐDḑdḨHḩh",
+	// ogonek
+	'\u0328': "ĄAąaĘEęeĮIįiŲUųuǪOǫo",
+	// circumflex accent below
+	'\u032D': "ḒDḓdḘEḙeḼLḽlṊNṋnṰTṱtṶUṷu",
+	// breve below
+	'\u032E': "ḪHḫh",
+	// tilde below
+	'\u0330': "ḚEḛeḬIḭiṴUṵu",
+	// macron below
+	'\u0331': "ḆBḇbḎDḏdḴKḵkḺLḻlṈNṉnṞRṟrṮTṯtẔZẕzẖh",
+}
+
+// combiningClasses lists the canonical combining classes of the Combining
+// Diacritical Marks block (U+0300–U+036F) from UnicodeData.txt, as ranges
+// of first mark, last mark and class. Marks outside the block count as
+// class 0, so they are never reordered and block composition across them
+var combiningClasses = [][3]rune{
+	{0x0300, 0x0314, 230}, {0x0315, 0x0315, 232}, {0x0316, 0x0319, 220},
+	{0x031A, 0x031A, 232}, {0x031B, 0x031B, 216}, {0x031C, 0x0320, 220},
+	{0x0321, 0x0322, 202}, {0x0323, 0x0326, 220}, {0x0327, 0x0328, 202},
+	{0x0329, 0x0333, 220}, {0x0334, 0x0338, 1}, {0x0339, 0x033C, 220},
+	{0x033D, 0x0344, 230}, {0x0345, 0x0345, 240}, {0x0346, 0x0346, 230},
+	{0x0347, 0x0349, 220}, {0x034A, 0x034C, 230}, {0x034D, 0x034E, 220},
+	{0x0350, 0x0352, 230}, {0x0353, 0x0356, 220}, {0x0357, 0x0357, 230},
+	{0x0358, 0x0358, 232}, {0x0359, 0x035A, 220}, {0x035B, 0x035B, 230},
+	{0x035C, 0x035C, 233}, {0x035D, 0x035E, 234}, {0x035F, 0x035F, 233},
+	{0x0360, 0x0361, 234}, {0x0362, 0x0362, 233}, {0x0363, 0x036F, 230},
+}
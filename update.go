@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// updateCheckInterval is how often the latest release is looked up
+const updateCheckInterval = 24 * time.Hour
+
+// latestReleaseURL is the GitHub API endpoint of the latest release
+var latestReleaseURL = "https://api.github.com/repos/amantham20/git-usr/releases/latest"
+
+// updateCache is the outcome of the last update check
+type updateCache struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Latest    string    `json:"latest,omitempty"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// getUpdateCachePath returns the path of the update check cache
+func getUpdateCachePath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "update-check.json"), nil
+}
+
+// loadUpdateCache returns the last update check, empty if there was none
+func loadUpdateCache() updateCache {
+	var cache updateCache
+	path, err := getUpdateCachePath()
+	if err != nil {
+		return cache
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// saveUpdateCache writes the update check cache. Failures are ignored,
+// the check just runs again
+func saveUpdateCache(cache updateCache) {
+	path, err := getUpdateCachePath()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	writeFileAtomic(path, data, 0644, false)
+}
+
+// updateCheckEnabled reports whether the updateCheck setting is on and
+// GIT_USR_NO_UPDATE_CHECK isn't set
+func updateCheckEnabled() bool {
+	if os.Getenv("GIT_USR_NO_UPDATE_CHECK") != "" {
+		return false
+	}
+	settings, ok := fileSettings()
+	return ok && settings.UpdateCheck
+}
+
+// parseVersion splits a version like v1.2.3 or 1.2.3-rc.1 into its
+// numbers and pre-release suffix
+func parseVersion(v string) ([]int, string, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, pre, _ := strings.Cut(v, "-")
+	var numbers []int
+	for _, part := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, "", false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, pre, true
+}
+
+// newerVersion reports whether latest is a later release than current.
+// Versions that don't parse are never newer
+func newerVersion(latest, current string) bool {
+	l, lpre, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, cpre, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := 0; i < max(len(l), len(c)); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	// 1.2.0 is newer than 1.2.0-rc.1
+	return lpre == "" && cpre != ""
+}
+
+// notifyUpdate mentions a newer release found by an earlier check, and
+// starts a check in the background once the last one is a day old. It
+// stays quiet unless enabled and on a terminal, so scripts and CI never
+// see the notice or wait on the network
+func notifyUpdate() {
+	if !updateCheckEnabled() || !isInteractive() {
+		return
+	}
+
+	current := currentBuild().Version
+	cache := loadUpdateCache()
+	if newerVersion(cache.Latest, current) {
+		url := cache.URL
+		if url == "" {
+			url = "https://github.com/amantham20/git-usr/releases/latest"
+		}
+		fmt.Fprintf(os.Stderr, "\n%s\n", trf("A new release of git-usr is available: %s → %s (%s)", current, strings.TrimPrefix(cache.Latest, "v"), url))
+	}
+
+	if time.Since(cache.CheckedAt) < updateCheckInterval {
+		return
+	}
+	// Recorded before checking, so a failing check isn't retried on every run
+	cache.CheckedAt = time.Now()
+	saveUpdateCache(cache)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(executable, append(configFlagArgs(), "__update-check")...)
+	if cmd.Start() == nil {
+		cmd.Process.Release()
+	}
+}
+
+// checkForUpdate looks up the latest release and records it in the cache
+func checkForUpdate() error {
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if err := getJSON(client, latestReleaseURL, map[string]string{"Accept": "application/vnd.github+json"}, &release); err != nil {
+		return err
+	}
+	if release.TagName == "" {
+		return fmt.Errorf("no release found")
+	}
+	saveUpdateCache(updateCache{CheckedAt: time.Now(), Latest: release.TagName, URL: release.HTMLURL})
+	return nil
+}
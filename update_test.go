@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// TestNewerVersion tests comparing release versions
+func TestNewerVersion(t *testing.T) {
+	for _, test := range []struct {
+		latest, current string
+		newer           bool
+	}{
+		{"v1.1.0", "1.0.0", true},
+		{"1.0.10", "1.0.9", true},
+		{"v2", "1.9.9", true},
+		{"v1.0.0", "1.0.0", false},
+		{"v1.0", "1.0.0", false},
+		{"v0.9.0", "1.0.0", false},
+		{"v1.0.0", "1.0.0-rc.1", true},
+		{"v1.1.0-rc.1", "1.0.0", true},
+		{"v1.0.0-rc.2", "1.0.0", false},
+		{"nightly", "1.0.0", false},
+		{"v1.1.0", "dev", false},
+		{"", "1.0.0", false},
+	} {
+		if got := newerVersion(test.latest, test.current); got != test.newer {
+			t.Errorf("newerVersion(%q, %q) = %v", test.latest, test.current, got)
+		}
+	}
+}
+
+// TestCheckForUpdate tests recording the latest release, and that the
+// environment variable turns the check off
+func TestCheckForUpdate(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.4.0", "html_url": "https://github.com/amantham20/git-usr/releases/tag/v1.4.0"}`))
+	}))
+	defer server.Close()
+	defer func(url string) { latestReleaseURL = url }(latestReleaseURL)
+	latestReleaseURL = server.URL
+
+	if err := checkForUpdate(); err != nil {
+		t.Fatalf("checkForUpdate failed: %v", err)
+	}
+	cache := loadUpdateCache()
+	if cache.Latest != "v1.4.0" || cache.URL == "" || cache.CheckedAt.IsZero() {
+		t.Errorf("Unexpected cache %+v", cache)
+	}
+
+	if updateCheckEnabled() {
+		t.Error("The update check is on without the setting")
+	}
+	config, _ := loadConfig()
+	config.Settings.UpdateCheck = true
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if !updateCheckEnabled() {
+		t.Error("The update check is off with the setting")
+	}
+	t.Setenv("GIT_USR_NO_UPDATE_CHECK", "1")
+	if updateCheckEnabled() {
+		t.Error("GIT_USR_NO_UPDATE_CHECK didn't turn the update check off")
+	}
+}
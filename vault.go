@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	profileStores["vault"] = newVaultStore
+}
+
+// vaultStore keeps the profiles in a HashiCorp Vault KV version 2 secret,
+// e.g. storeURL "secret/git-usr/team". The address and credentials come
+// from the usual Vault environment variables, and nothing is cached on
+// disk, so tokens kept in profile environments never touch the filesystem.
+type vaultStore struct {
+	address string
+	mount   string
+	path    string
+	client  *http.Client
+	token   string
+}
+
+func newVaultStore(location string) (ProfileStore, error) {
+	address := strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	if address == "" {
+		return nil, fmt.Errorf("the vault store needs VAULT_ADDR")
+	}
+	mount, path, ok := strings.Cut(strings.Trim(location, "/"), "/")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("storeURL must be <mount>/<path> for the vault store, e.g. secret/git-usr")
+	}
+	return &vaultStore{
+		address: address,
+		mount:   mount,
+		path:    path,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// vaultResponse is the part of Vault's responses git-usr reads
+type vaultResponse struct {
+	Data struct {
+		Data struct {
+			Profiles map[string]Profile `json:"profiles"`
+		} `json:"data"`
+	} `json:"data"`
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// request sends a request to the Vault API, decoding the response into
+// result. It returns the status code so callers can tell a missing secret
+func (s *vaultStore) request(method, apiPath, token string, body interface{}, result *vaultResponse) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.address+"/v1/"+apiPath, reader)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp.StatusCode, fmt.Errorf("%s %s: unexpected response: %w", method, apiPath, err)
+		}
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return resp.StatusCode, fmt.Errorf("%s %s: %s %s", method, apiPath, resp.Status, strings.Join(result.Errors, "; "))
+	}
+	return resp.StatusCode, nil
+}
+
+// login returns a Vault token: VAULT_TOKEN, an AppRole login with
+// VAULT_ROLE_ID and VAULT_SECRET_ID, or the token saved by `vault login`
+func (s *vaultStore) login() (string, error) {
+	if s.token != "" {
+		return s.token, nil
+	}
+
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		s.token = token
+		return token, nil
+	}
+
+	if roleID := os.Getenv("VAULT_ROLE_ID"); roleID != "" {
+		mount := os.Getenv("VAULT_APPROLE_MOUNT")
+		if mount == "" {
+			mount = "approle"
+		}
+		var result vaultResponse
+		body := map[string]string{"role_id": roleID, "secret_id": os.Getenv("VAULT_SECRET_ID")}
+		if _, err := s.request("POST", "auth/"+mount+"/login", "", body, &result); err != nil {
+			return "", fmt.Errorf("AppRole login: %w", err)
+		}
+		if result.Auth.ClientToken == "" {
+			return "", fmt.Errorf("AppRole login returned no token")
+		}
+		s.token = result.Auth.ClientToken
+		return s.token, nil
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			s.token = strings.TrimSpace(string(data))
+			return s.token, nil
+		}
+	}
+	return "", fmt.Errorf("no Vault credentials: set VAULT_TOKEN, VAULT_ROLE_ID and VAULT_SECRET_ID, or run 'vault login'")
+}
+
+func (s *vaultStore) Load() (map[string]Profile, error) {
+	token, err := s.login()
+	if err != nil {
+		return nil, err
+	}
+
+	var result vaultResponse
+	status, err := s.request("GET", s.mount+"/data/"+s.path, token, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound || result.Data.Data.Profiles == nil {
+		return map[string]Profile{}, nil
+	}
+	return result.Data.Data.Profiles, nil
+}
+
+func (s *vaultStore) Save(profiles map[string]Profile) error {
+	token, err := s.login()
+	if err != nil {
+		return err
+	}
+
+	// Every save is a new version of the secret, so older profiles can be
+	// restored with `vault kv rollback`
+	body := map[string]interface{}{"data": map[string]interface{}{"profiles": profiles}}
+	var result vaultResponse
+	_, err = s.request("POST", s.mount+"/data/"+s.path, token, body, &result)
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestVaultStore tests an AppRole login and a KV v2 round trip
+func TestVaultStore(t *testing.T) {
+	var secret json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/auth/approle/login":
+			w.Write([]byte(`{"auth": {"client_token": "s.approle"}}`))
+		case r.Header.Get("X-Vault-Token") != "s.approle":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+		case r.URL.Path != "/v1/secret/data/git-usr/team":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "POST":
+			body, _ := io.ReadAll(r.Body)
+			var request struct {
+				Data json.RawMessage `json:"data"`
+			}
+			json.Unmarshal(body, &request)
+			secret = request.Data
+			w.Write([]byte(`{"data": {"version": 1}}`))
+		case secret == nil:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		default:
+			w.Write([]byte(`{"data": {"data": ` + string(secret) + `}}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "secret")
+
+	if _, err := newVaultStore("secret"); err == nil {
+		t.Error("Expected an error for a storeURL without a path")
+	}
+	store, err := newVaultStore("secret/git-usr/team")
+	if err != nil {
+		t.Fatalf("newVaultStore failed: %v", err)
+	}
+
+	profiles, err := store.Load()
+	if err != nil || len(profiles) != 0 {
+		t.Fatalf("Expected no profiles before the first save, got %v (%v)", profiles, err)
+	}
+	want := map[string]Profile{"work": {Name: "Work", Email: "work@company.com", Env: map[string]string{"GH_TOKEN": "t0k3n"}}}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	profiles, err = store.Load()
+	if err != nil || profiles["work"].Env["GH_TOKEN"] != "t0k3n" {
+		t.Errorf("Expected the saved profiles, got %v (%v)", profiles, err)
+	}
+
+	denied, _ := newVaultStore("secret/git-usr/team")
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := denied.Load(); err == nil {
+		t.Error("Expected an error for a denied token")
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// forgeEmail is an email address registered on a forge account
+type forgeEmail struct {
+	Email    string
+	Verified bool
+}
+
+// forge describes a hosting service whose API lists an account's emails,
+// and the CLI whose logged in account follows the profile
+type forge struct {
+	displayName   string
+	tokenEnvs     []string
+	apiURL        func() string
+	emails        func(client *http.Client, apiURL, token string) ([]forgeEmail, error)
+	cli           string
+	switchAccount func(host, user string) error
+}
+
+// forges are the services `git usr verify` can check against
+var forges = map[string]forge{
+	"github": {
+		displayName: "GitHub",
+		tokenEnvs:   []string{"GH_TOKEN", "GITHUB_TOKEN"},
+		apiURL: func() string {
+			if url := os.Getenv("GITHUB_API_URL"); url != "" {
+				return url
+			}
+			return "https://api.github.com"
+		},
+		emails:        githubEmails,
+		cli:           "gh",
+		switchAccount: githubSwitchAccount,
+	},
+	"gitlab": {
+		displayName: "GitLab",
+		tokenEnvs:   []string{"GITLAB_TOKEN"},
+		apiURL: func() string {
+			if host := os.Getenv("GITLAB_HOST"); host != "" {
+				if !strings.Contains(host, "://") {
+					host = "https://" + host
+				}
+				return strings.TrimRight(host, "/") + "/api/v4"
+			}
+			return "https://gitlab.com/api/v4"
+		},
+		emails:        gitlabEmails,
+		cli:           "glab",
+		switchAccount: gitlabSwitchAccount,
+	},
+}
+
+// token returns the first API token set in the forge's environment variables
+func (f forge) token() string {
+	for _, name := range f.tokenEnvs {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// getJSON fetches url with the given headers and decodes the JSON response
+func getJSON(client *http.Client, url string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// githubEmails lists the emails of the token's GitHub account, including
+// its noreply addresses (requires the user:email scope)
+func githubEmails(client *http.Client, apiURL, token string) ([]forgeEmail, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + token,
+		"Accept":        "application/vnd.github+json",
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := getJSON(client, apiURL+"/user", headers, &user); err != nil {
+		return nil, err
+	}
+
+	var listed []struct {
+		Email    string `json:"email"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(client, apiURL+"/user/emails", headers, &listed); err != nil {
+		return nil, err
+	}
+
+	emails := []forgeEmail{
+		{Email: fmt.Sprintf("%d+%s@users.noreply.github.com", user.ID, user.Login), Verified: true},
+		{Email: user.Login + "@users.noreply.github.com", Verified: true},
+	}
+	for _, e := range listed {
+		emails = append(emails, forgeEmail{Email: e.Email, Verified: e.Verified})
+	}
+	return emails, nil
+}
+
+// gitlabEmails lists the primary and secondary emails of the token's
+// GitLab account
+func gitlabEmails(client *http.Client, apiURL, token string) ([]forgeEmail, error) {
+	headers := map[string]string{"PRIVATE-TOKEN": token}
+
+	var user struct {
+		Email       string `json:"email"`
+		CommitEmail string `json:"commit_email"`
+	}
+	if err := getJSON(client, apiURL+"/user", headers, &user); err != nil {
+		return nil, err
+	}
+
+	var listed []struct {
+		Email       string  `json:"email"`
+		ConfirmedAt *string `json:"confirmed_at"`
+	}
+	if err := getJSON(client, apiURL+"/user/emails", headers, &listed); err != nil {
+		return nil, err
+	}
+
+	// The primary and commit emails can only be set to confirmed addresses
+	emails := []forgeEmail{{Email: user.Email, Verified: true}}
+	if user.CommitEmail != "" {
+		emails = append(emails, forgeEmail{Email: user.CommitEmail, Verified: true})
+	}
+	for _, e := range listed {
+		emails = append(emails, forgeEmail{Email: e.Email, Verified: e.ConfirmedAt != nil})
+	}
+	return emails, nil
+}
+
+// findForgeEmail looks up email on an account, ignoring case
+func findForgeEmail(emails []forgeEmail, email string) (forgeEmail, bool) {
+	for _, e := range emails {
+		if strings.EqualFold(e.Email, email) {
+			return e, true
+		}
+	}
+	return forgeEmail{}, false
+}
+
+// verifyProfileEmail checks the profile's email against every forge with a
+// token configured (or just forgeName) and reports whether commits made
+// with it would be attributed to the account. apiURL, if set, is the API
+// of forgeName; the other forges keep their own
+func verifyProfileEmail(profileName, forgeName, apiURL string) error {
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	if forgeName != "" {
+		if _, ok := forges[forgeName]; !ok {
+			fmt.Printf("❌ Unknown forge '%s' (use github or gitlab)\n", forgeName)
+			return fmt.Errorf("unknown forge: %s", forgeName)
+		}
+		names = append(names, forgeName)
+	} else {
+		for name, f := range forges {
+			if f.token() != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fmt.Println("❌ No forge API token found")
+		fmt.Println("Set GH_TOKEN/GITHUB_TOKEN or GITLAB_TOKEN")
+		return fmt.Errorf("no forge token")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	attributed := true
+	for _, name := range names {
+		f := forges[name]
+		token := f.token()
+		if token == "" {
+			fmt.Printf("❌ %s: set %s\n", f.displayName, strings.Join(f.tokenEnvs, " or "))
+			attributed = false
+			continue
+		}
+		url := f.apiURL()
+		if apiURL != "" && name == forgeName {
+			url = apiURL
+		}
+
+		emails, err := f.emails(client, strings.TrimRight(url, "/"), token)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", f.displayName, err)
+			attributed = false
+			continue
+		}
+
+		e, found := findForgeEmail(emails, profile.Email)
+		switch {
+		case found && e.Verified:
+			fmt.Printf("✅ %s: %s is verified on the account\n", f.displayName, profile.Email)
+		case found:
+			fmt.Printf("⚠️  %s: %s is not verified; commits won't be attributed until it is\n", f.displayName, profile.Email)
+			attributed = false
+		default:
+			fmt.Printf("❌ %s: %s is not on the account; commits won't be attributed\n", f.displayName, profile.Email)
+			attributed = false
+		}
+	}
+
+	if !attributed {
+		return fmt.Errorf("email not verified")
+	}
+	return nil
+}
+
+// runVerify handles the verify command
+func runVerify(args []string) error {
+	usage := "Usage: git usr verify <profile> [--forge github|gitlab] [--api-url <url>]"
+
+	profileName, forgeName, apiURL := "", "", ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--forge" || args[i] == "--api-url":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			if args[i] == "--forge" {
+				forgeName = args[i+1]
+			} else {
+				apiURL = args[i+1]
+			}
+			i++
+		case strings.HasPrefix(args[i], "--forge="):
+			forgeName = strings.TrimPrefix(args[i], "--forge=")
+		case strings.HasPrefix(args[i], "--api-url="):
+			apiURL = strings.TrimPrefix(args[i], "--api-url=")
+		case profileName == "" && !strings.HasPrefix(args[i], "-"):
+			profileName = args[i]
+		default:
+			fmt.Println(usage)
+			return fmt.Errorf("unexpected argument: %s", args[i])
+		}
+	}
+
+	if profileName == "" {
+		fmt.Println("❌ Profile name required!")
+		fmt.Println(usage)
+		return fmt.Errorf("profile required")
+	}
+	if apiURL != "" && forgeName == "" {
+		fmt.Println("❌ --api-url needs --forge to say which forge it is for")
+		fmt.Println(usage)
+		return fmt.Errorf("--api-url requires --forge")
+	}
+
+	return verifyProfileEmail(profileName, forgeName, apiURL)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGitHubEmails tests listing emails from the GitHub API
+func TestGitHubEmails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"id": 42, "login": "jdoe"}`))
+		case "/user/emails":
+			w.Write([]byte(`[{"email": "john@work.com", "verified": true}, {"email": "john@old.com", "verified": false}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	emails, err := githubEmails(server.Client(), server.URL, "secret")
+	if err != nil {
+		t.Fatalf("githubEmails failed: %v", err)
+	}
+
+	cases := map[string]struct{ found, verified bool }{
+		"John@Work.com":                    {true, true},
+		"john@old.com":                     {true, false},
+		"42+jdoe@users.noreply.github.com": {true, true},
+		"john@personal.com":                {false, false},
+	}
+	for email, expected := range cases {
+		e, found := findForgeEmail(emails, email)
+		if found != expected.found || e.Verified != expected.verified {
+			t.Errorf("%s: expected found=%v verified=%v, got found=%v verified=%v", email, expected.found, expected.verified, found, e.Verified)
+		}
+	}
+
+	if _, err := githubEmails(server.Client(), server.URL, "wrong"); err == nil {
+		t.Error("Expected error for a rejected token")
+	}
+}
+
+// TestGitLabEmails tests listing emails from the GitLab API
+func TestGitLabEmails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"email": "john@work.com", "commit_email": "john@commits.com"}`))
+		case "/user/emails":
+			w.Write([]byte(`[{"email": "john@old.com", "confirmed_at": null}, {"email": "john@new.com", "confirmed_at": "2024-01-01T00:00:00Z"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	emails, err := gitlabEmails(server.Client(), server.URL, "secret")
+	if err != nil {
+		t.Fatalf("gitlabEmails failed: %v", err)
+	}
+
+	cases := map[string]struct{ found, verified bool }{
+		"john@work.com":    {true, true},
+		"john@commits.com": {true, true},
+		"john@new.com":     {true, true},
+		"john@old.com":     {true, false},
+	}
+	for email, expected := range cases {
+		e, found := findForgeEmail(emails, email)
+		if found != expected.found || e.Verified != expected.verified {
+			t.Errorf("%s: expected found=%v verified=%v, got found=%v verified=%v", email, expected.found, expected.verified, found, e.Verified)
+		}
+	}
+}
+
+// TestVerifyAPIURLNeedsForge tests that --api-url is only taken together
+// with the forge it belongs to
+func TestVerifyAPIURLNeedsForge(t *testing.T) {
+	if err := runVerify([]string{"work", "--api-url", "https://git.corp.com/api/v3"}); err == nil {
+		t.Error("Expected --api-url without --forge to be rejected")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Builds without them fall back to what the Go toolchain recorded
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo describes the running binary, as printed by `version --json`
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// readBuildInfo is debug.ReadBuildInfo, replaced in tests
+var readBuildInfo = debug.ReadBuildInfo
+
+// currentBuild returns the version metadata injected at build time. A
+// `go install ...@v1.2.0` gets its version from the module, and a build
+// from a checkout gets its commit and date from the VCS stamp
+func currentBuild() buildInfo {
+	build := buildInfo{
+		Version:   strings.TrimPrefix(version, "v"),
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	info, ok := readBuildInfo()
+	if !ok {
+		return build
+	}
+	// Checkouts without a release tag get a v0.0.0 pseudo-version, which is
+	// no more a release than "dev"
+	if module := info.Main.Version; build.Version == "dev" && module != "" && module != "(devel)" && !strings.HasPrefix(module, "v0.0.0-") {
+		build.Version = strings.TrimPrefix(module, "v")
+	}
+	if build.Commit == "" {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build.Commit = setting.Value
+			case "vcs.time":
+				build.Date = setting.Value
+			case "vcs.modified":
+				build.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return build
+}
+
+// showVersion displays version information
+func showVersion() {
+	build := currentBuild()
+	fmt.Print(`
+            __
+           / _)
+    .-^^^-/ /
+ __/       /
+<__.|_|-|_|
+
+git-usr version ` + build.Version + `
+`)
+	if build.Commit != "" {
+		details := "commit " + build.Commit[:min(len(build.Commit), 12)]
+		if build.Modified {
+			details += " (modified)"
+		}
+		if build.Date != "" {
+			details += ", built " + build.Date
+		}
+		fmt.Println(details)
+	}
+	fmt.Println("Made by Aman (thammina@msu.edu)")
+}
+
+// runVersion handles the version command
+func runVersion(args []string) error {
+	switch {
+	case len(args) == 0:
+		showVersion()
+		return nil
+	case len(args) == 1 && args[0] == "--json":
+		data, err := json.MarshalIndent(currentBuild(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Println("Usage: git usr version [--json]")
+	return fmt.Errorf("unexpected argument: %s", args[0])
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+// TestCurrentBuild tests that injected metadata wins over what the Go
+// toolchain recorded, which fills in what wasn't injected
+func TestCurrentBuild(t *testing.T) {
+	defer func(v, c, d string, read func() (*debug.BuildInfo, bool)) {
+		version, commit, date, readBuildInfo = v, c, d, read
+	}(version, commit, date, readBuildInfo)
+
+	info := &debug.BuildInfo{
+		Main: debug.Module{Version: "v1.3.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef"},
+			{Key: "vcs.time", Value: "2026-10-01T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return info, true }
+
+	version, commit, date = "v1.2.0", "fedcba", "2026-09-01T00:00:00Z"
+	if build := currentBuild(); build.Version != "1.2.0" || build.Commit != "fedcba" || build.Date != "2026-09-01T00:00:00Z" || build.Modified {
+		t.Errorf("Injected metadata: got %+v", build)
+	}
+
+	version, commit, date = "dev", "", ""
+	if build := currentBuild(); build.Version != "1.3.0" || build.Commit != "0123456789abcdef" || build.Date != "2026-10-01T12:00:00Z" || !build.Modified {
+		t.Errorf("Recorded metadata: got %+v", build)
+	}
+
+	info.Main.Version = "v0.0.0-20261001120000-0123456789ab+dirty"
+	if build := currentBuild(); build.Version != "dev" {
+		t.Errorf("Expected a pseudo-version to stay dev, got %s", build.Version)
+	}
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	if build := currentBuild(); build.Version != "dev" || build.Commit != "" || build.Platform == "" {
+		t.Errorf("Without build info: got %+v", build)
+	}
+}
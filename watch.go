@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// watchInterval is how often the watched directories are scanned.
+	// Polling keeps git-usr free of dependencies; a scan only reads the
+	// directories above the repositories
+	watchInterval = 2 * time.Second
+	// watchDepth is how deep below a watched directory repositories are
+	// looked for, e.g. ~/src/github.com/org/repo
+	watchDepth = 3
+	// watchSettle is how long a clone that never checks out files (empty
+	// repositories, --no-checkout) must be quiet before it counts as done
+	watchSettle = time.Minute
+	// watchPendingTimeout is how long a clone may take before the watcher
+	// gives up on it
+	watchPendingTimeout = 10 * time.Minute
+	// watchMaxLogSize is the size at which the log is rotated on start
+	watchMaxLogSize = 1 << 20
+	// watchStatusLines is how many log lines `watch status` shows
+	watchStatusLines = 20
+)
+
+// watchPaths returns the pid file, which the daemon touches on every scan,
+// and the log file of the watch daemon
+func watchPaths() (pidPath, logPath string, err error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(configDir, "watch.pid"), filepath.Join(configDir, "watch.log"), nil
+}
+
+// runningWatcher returns the pid of the running watch daemon, or 0. A
+// daemon that hasn't scanned for a while is considered dead
+func runningWatcher(pidPath string) int {
+	info, err := os.Stat(pidPath)
+	if err != nil || time.Since(info.ModTime()) > 5*watchInterval {
+		return 0
+	}
+	data, err := os.ReadFile(pidPath)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// findRepos returns the repositories at most depth levels below root,
+// without descending into repositories or hidden directories
+func findRepos(root string, depth int) []string {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+		return []string{root}
+	}
+	if depth == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var repos []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		repos = append(repos, findRepos(filepath.Join(root, entry.Name()), depth-1)...)
+	}
+	return repos
+}
+
+// cloneFinished reports whether git is done creating the repository at
+// path: the checkout wrote the index, or nothing has changed for a while
+func cloneFinished(path string, now time.Time) bool {
+	gitDir := filepath.Join(path, ".git")
+	for _, lock := range []string{"index.lock", "config.lock", "HEAD.lock"} {
+		if _, err := os.Stat(filepath.Join(gitDir, lock)); err == nil {
+			return false
+		}
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "index")); err == nil {
+		return true
+	}
+
+	// The pack being fetched grows in objects/pack
+	paths := []string{gitDir, filepath.Join(gitDir, "config")}
+	packDir := filepath.Join(gitDir, "objects", "pack")
+	if entries, err := os.ReadDir(packDir); err == nil {
+		for _, entry := range entries {
+			paths = append(paths, filepath.Join(packDir, entry.Name()))
+		}
+	}
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && now.Sub(info.ModTime()) < watchSettle {
+			return false
+		}
+	}
+	return true
+}
+
+// watchedRepo is a new repository below a watched directory
+type watchedRepo struct {
+	Path string
+	Root string
+}
+
+// watcher remembers the repositories below the watched directories so it
+// can tell new clones from repositories that were there before
+type watcher struct {
+	roots   map[string]bool
+	known   map[string]bool
+	pending map[string]time.Time
+}
+
+func newWatcher() *watcher {
+	return &watcher{roots: map[string]bool{}, known: map[string]bool{}, pending: map[string]time.Time{}}
+}
+
+// scan returns the repositories that appeared below dirs since the last
+// scan and are done cloning. The first scan of a directory only records
+// what is already there
+func (w *watcher) scan(dirs []string, now time.Time) []watchedRepo {
+	var ready []watchedRepo
+	for _, root := range dirs {
+		repos := findRepos(root, watchDepth)
+		if !w.roots[root] {
+			w.roots[root] = true
+			for _, repo := range repos {
+				w.known[repo] = true
+			}
+			continue
+		}
+
+		for _, repo := range repos {
+			if w.known[repo] {
+				continue
+			}
+			if _, seen := w.pending[repo]; !seen {
+				w.pending[repo] = now
+			}
+			if cloneFinished(repo, now) {
+				ready = append(ready, watchedRepo{Path: repo, Root: root})
+				w.known[repo] = true
+				delete(w.pending, repo)
+			} else if now.Sub(w.pending[repo]) > watchPendingTimeout {
+				w.known[repo] = true
+				delete(w.pending, repo)
+			}
+		}
+	}
+	return ready
+}
+
+// watchProfileFor picks the profile for a new clone: the profile its
+// remote belongs to, the watched directory's profile, then the default
+func watchProfileFor(config *Config, root string, remotes []string) (string, string) {
+	if expected := expectedProfiles(config.Profiles, remotes); len(expected) > 0 {
+		return expected[0], "remote"
+	}
+	if profileName := config.Watch[root]; profileName != "" {
+		return profileName, "directory"
+	}
+	if config.Settings.DefaultProfile != "" {
+		return config.Settings.DefaultProfile, "default"
+	}
+	return "", ""
+}
+
+// applyWatchedRepo applies the matching profile to a new clone unless it
+// already has a local identity, e.g. because it was cloned with
+// `git usr clone`
+func applyWatchedRepo(logger *log.Logger, repo watchedRepo) {
+	if email := gitConfigValue(repo.Path, "local", "user.email"); email != "" {
+		logger.Printf("%s: already has identity %s", repo.Path, email)
+		return
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		logger.Printf("%s: %v", repo.Path, err)
+		return
+	}
+
+	profileName, reason := watchProfileFor(config, repo.Root, getRemoteURLs(repo.Path))
+	if profileName == "" {
+		logger.Printf("%s: no profile matches and no default profile set", repo.Path)
+		return
+	}
+	if _, exists := config.Profiles[profileName]; !exists {
+		logger.Printf("%s: profile '%s' not found", repo.Path, profileName)
+		return
+	}
+
+	// The git helpers work on the current directory
+	previous, err := os.Getwd()
+	if err != nil {
+		logger.Printf("%s: %v", repo.Path, err)
+		return
+	}
+	if err := os.Chdir(repo.Path); err != nil {
+		logger.Printf("%s: %v", repo.Path, err)
+		return
+	}
+	defer os.Chdir(previous)
+
+	warnings, err := applyLocalProfile(config, profileName)
+	if err != nil {
+		logger.Printf("%s: applying '%s' failed: %v", repo.Path, profileName, err)
+		return
+	}
+	profile := config.Profiles[profileName]
+	logger.Printf("%s: applied '%s' (%s) <%s>", repo.Path, profileName, reason, profile.Email)
+	for _, warning := range warnings {
+		logger.Printf("%s: %s", repo.Path, warning)
+	}
+}
+
+// openWatchLog opens the watch log for appending, rotating it first when
+// it has grown too large
+func openWatchLog(logPath string) (*os.File, error) {
+	if info, err := os.Stat(logPath); err == nil && info.Size() > watchMaxLogSize {
+		os.Rename(logPath, logPath+".1")
+	}
+	return os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+// runWatcher scans the watched directories until interrupted. In the
+// background it only writes to the log; in the foreground also to stdout
+func runWatcher(background bool) error {
+	pidPath, logPath, err := watchPaths()
+	if err != nil {
+		return err
+	}
+	if pid := runningWatcher(pidPath); pid != 0 && pid != os.Getpid() {
+		fmt.Printf("❌ git usr watch is already running (pid %d)\n", pid)
+		return fmt.Errorf("already running")
+	}
+
+	logFile, err := openWatchLog(logPath)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	var out io.Writer = logFile
+	if !background {
+		out = io.MultiWriter(os.Stdout, logFile)
+	}
+	logger := log.New(out, "", log.LstdFlags)
+
+	pid := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	if err := os.WriteFile(pidPath, pid, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(pidPath)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	if background {
+		// Outlive the terminal `watch start` was run from
+		signal.Ignore(syscall.SIGHUP)
+		promptsDisabled = true
+	}
+
+	logger.Printf("watching started (pid %d)", os.Getpid())
+	w := newWatcher()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	var watched []string
+	for {
+		// Re-read the directories each time so `watch add` takes effect
+		// without a restart
+		if config, err := loadLocalConfig(); errors.Is(err, errConfigLocked) {
+			// Nothing unlocks it while running, so every poll would fail
+			logger.Printf("reading config: %v", err)
+			logger.Printf("watching stopped: the config is locked; restart the watcher with GIT_USR_PASSPHRASE set")
+			return err
+		} else if err != nil {
+			logger.Printf("reading config: %v", err)
+		} else {
+			dirs := sortedKeys(config.Watch)
+			if strings.Join(dirs, "\n") != strings.Join(watched, "\n") {
+				watched = dirs
+				logger.Printf("watching %s", strings.Join(dirs, ", "))
+			}
+			for _, repo := range w.scan(dirs, time.Now()) {
+				applyWatchedRepo(logger, repo)
+			}
+		}
+		os.Chtimes(pidPath, time.Now(), time.Now())
+
+		select {
+		case <-stop:
+			logger.Printf("watching stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// startWatcher runs the watch daemon in the background
+func startWatcher() error {
+	pidPath, logPath, err := watchPaths()
+	if err != nil {
+		return err
+	}
+	if pid := runningWatcher(pidPath); pid != 0 {
+		fmt.Printf("git usr watch is already running (pid %d)\n", pid)
+		return nil
+	}
+
+	// The watcher can't ask for a passphrase in the background
+	if configPath, err := getConfigPath(); err == nil && os.Getenv("GIT_USR_PASSPHRASE") == "" {
+		if data, err := os.ReadFile(configPath); err == nil {
+			if _, locked := parseEncryptedConfig(data); locked {
+				fmt.Println("❌ The config is locked; set GIT_USR_PASSPHRASE to start the watcher")
+				return errConfigLocked
+			}
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := append(configFlagArgs(), "watch", "run", "--background")
+	cmd := exec.Command(executable, args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the watcher: %w", err)
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+
+	fmt.Printf("✅ git usr watch started (pid %d)\n", pid)
+	fmt.Printf("   Log: %s\n", logPath)
+	return nil
+}
+
+// stopWatcher stops the watch daemon
+func stopWatcher() error {
+	pidPath, _, err := watchPaths()
+	if err != nil {
+		return err
+	}
+	pid := runningWatcher(pidPath)
+	if pid == 0 {
+		fmt.Println("git usr watch is not running")
+		return nil
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	// Windows can't deliver interrupts; the daemon then leaves its pid
+	// file behind, which goes stale on its own
+	if err := process.Signal(os.Interrupt); err != nil {
+		if err := process.Kill(); err != nil {
+			return fmt.Errorf("failed to stop pid %d: %w", pid, err)
+		}
+		os.Remove(pidPath)
+	}
+
+	fmt.Printf("✅ git usr watch stopped (pid %d)\n", pid)
+	return nil
+}
+
+// tailLines returns the last n lines of a file
+func tailLines(path string, n int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// showWatchStatus prints whether the daemon runs, what it watches and the
+// end of its log
+func showWatchStatus() error {
+	pidPath, logPath, err := watchPaths()
+	if err != nil {
+		return err
+	}
+	config, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	if pid := runningWatcher(pidPath); pid != 0 {
+		fmt.Printf("✅ git usr watch is running (pid %d)\n", pid)
+	} else {
+		fmt.Println("git usr watch is not running")
+		fmt.Println("\nUse: git usr watch start")
+	}
+	if err := listWatchDirs(config); err != nil {
+		return err
+	}
+
+	lines, err := tailLines(logPath, watchStatusLines)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📜 %s:\n", logPath)
+	for _, line := range lines {
+		fmt.Printf("   %s\n", line)
+	}
+	return nil
+}
+
+// listWatchDirs prints the watched directories
+func listWatchDirs(config *Config) error {
+	if len(config.Watch) == 0 {
+		fmt.Println("No watched directories")
+		fmt.Println("\nUse: git usr watch add <dir> [<profile>]")
+		return nil
+	}
+
+	fmt.Println("\n👀 Watched directories:")
+	fmt.Println("--------------------------------------------------")
+	for _, dir := range sortedKeys(config.Watch) {
+		profileName := config.Watch[dir]
+		if profileName == "" {
+			profileName = "(remote or default profile)"
+		}
+		fmt.Printf("   %s → %s\n", dir, profileName)
+	}
+	fmt.Println()
+	return nil
+}
+
+// watchDirKey returns the absolute form of dir the config keeps it under
+func watchDirKey(dir string) (string, error) {
+	dir, err := expandHome(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(dir)
+}
+
+// addWatchDir watches dir for new clones, applying profileName to them
+func addWatchDir(dir, profileName string) error {
+	dir, err := watchDirKey(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("❌ %s is not a directory\n", dir)
+		return fmt.Errorf("not a directory")
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Profiles[profileName]; profileName != "" && !exists {
+		fmt.Printf("❌ Profile '%s' not found!\n", profileName)
+		fmt.Println("\nAvailable profiles:", getProfileNames(config.Profiles))
+		return errProfileNotFound
+	}
+
+	if config.Watch == nil {
+		config.Watch = map[string]string{}
+	}
+	config.Watch[dir] = profileName
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	if profileName != "" {
+		fmt.Printf("✅ New clones in %s will get '%s' unless their remote belongs to another profile\n", dir, profileName)
+	} else {
+		fmt.Printf("✅ New clones in %s will get the profile of their remote or the default profile\n", dir)
+	}
+	pidPath, _, err := watchPaths()
+	if err == nil && runningWatcher(pidPath) == 0 {
+		fmt.Println("\nUse: git usr watch start")
+	}
+	return nil
+}
+
+// removeWatchDir stops watching dir
+func removeWatchDir(dir string) error {
+	dir, err := watchDirKey(dir)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := acquireConfigLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if _, exists := config.Watch[dir]; !exists {
+		fmt.Printf("❌ %s is not watched!\n", dir)
+		return fmt.Errorf("not watched: %s", dir)
+	}
+
+	delete(config.Watch, dir)
+	if err := saveConfig(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ No longer watching %s\n", dir)
+	return nil
+}
+
+// runWatch handles the watch command
+func runWatch(args []string) error {
+	usage := "Usage: git usr watch [run] | start | stop | status | add <dir> [<profile>] | remove <dir> | list"
+
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "run":
+		return runWatcher(false)
+	case len(args) == 2 && args[0] == "run" && args[1] == "--background":
+		return runWatcher(true)
+	case len(args) == 1 && args[0] == "start":
+		return startWatcher()
+	case len(args) == 1 && args[0] == "stop":
+		return stopWatcher()
+	case len(args) == 1 && args[0] == "status":
+		return showWatchStatus()
+	case len(args) == 1 && args[0] == "list":
+		config, err := loadLocalConfig()
+		if err != nil {
+			return err
+		}
+		return listWatchDirs(config)
+	case len(args) == 2 && args[0] == "add":
+		return addWatchDir(args[1], "")
+	case len(args) == 3 && args[0] == "add":
+		return addWatchDir(args[1], args[2])
+	case len(args) == 2 && args[0] == "remove":
+		return removeWatchDir(args[1])
+	}
+
+	fmt.Println(usage)
+	return fmt.Errorf("invalid watch command")
+}
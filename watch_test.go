@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeRepo creates a directory with a .git directory below root
+func makeRepo(t *testing.T, root string, parts ...string) string {
+	t.Helper()
+	path := filepath.Join(append([]string{root}, parts...)...)
+	if err := os.MkdirAll(filepath.Join(path, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestFindRepos tests where the watcher looks for repositories
+func TestFindRepos(t *testing.T) {
+	root := t.TempDir()
+	app := makeRepo(t, root, "app")
+	deep := makeRepo(t, root, "github.com", "acme", "lib")
+	makeRepo(t, root, "app", "vendor", "nested")
+	makeRepo(t, root, ".cache", "hidden")
+	makeRepo(t, root, "a", "b", "c", "too-deep")
+
+	got := findRepos(root, watchDepth)
+	expected := []string{app, deep}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("findRepos = %v, expected %v", got, expected)
+	}
+}
+
+// TestCloneFinished tests telling a finished clone from one in progress
+func TestCloneFinished(t *testing.T) {
+	root := t.TempDir()
+	repo := makeRepo(t, root, "app")
+	now := time.Now()
+
+	if cloneFinished(repo, now) {
+		t.Error("fresh clone without an index counted as finished")
+	}
+	if !cloneFinished(repo, now.Add(2*watchSettle)) {
+		t.Error("quiet clone without an index not counted as finished")
+	}
+
+	index := filepath.Join(repo, ".git", "index")
+	os.WriteFile(index, nil, 0644)
+	if !cloneFinished(repo, now) {
+		t.Error("checked out clone not counted as finished")
+	}
+
+	os.WriteFile(index+".lock", nil, 0644)
+	if cloneFinished(repo, now) {
+		t.Error("clone holding index.lock counted as finished")
+	}
+}
+
+// TestWatcherScan tests that only repositories appearing after the first
+// scan are reported, once each
+func TestWatcherScan(t *testing.T) {
+	root := t.TempDir()
+	makeRepo(t, root, "existing")
+	w := newWatcher()
+	now := time.Now()
+
+	if ready := w.scan([]string{root}, now); len(ready) != 0 {
+		t.Fatalf("first scan reported %v", ready)
+	}
+
+	repo := makeRepo(t, root, "new")
+	if ready := w.scan([]string{root}, now); len(ready) != 0 {
+		t.Fatalf("clone in progress reported: %v", ready)
+	}
+
+	os.WriteFile(filepath.Join(repo, ".git", "index"), nil, 0644)
+	ready := w.scan([]string{root}, now)
+	expected := []watchedRepo{{Path: repo, Root: root}}
+	if !reflect.DeepEqual(ready, expected) {
+		t.Fatalf("scan = %v, expected %v", ready, expected)
+	}
+	if ready := w.scan([]string{root}, now); len(ready) != 0 {
+		t.Errorf("repository reported twice: %v", ready)
+	}
+}
+
+// TestWatchProfileFor tests picking the profile for a new clone
+func TestWatchProfileFor(t *testing.T) {
+	config := &Config{
+		Profiles: map[string]Profile{
+			"work":     {HostAliases: map[string]string{"github.com": "github.com-work"}},
+			"personal": {},
+			"client":   {},
+		},
+		Watch:    map[string]string{"/src/client": "client", "/src/other": ""},
+		Settings: Settings{DefaultProfile: "personal"},
+	}
+
+	tests := []struct {
+		root    string
+		remote  string
+		profile string
+		reason  string
+	}{
+		{"/src/client", "git@github.com-work:acme/app.git", "work", "remote"},
+		{"/src/client", "git@github.com:client/app.git", "client", "directory"},
+		{"/src/other", "git@github.com:me/app.git", "personal", "default"},
+	}
+	for _, test := range tests {
+		profile, reason := watchProfileFor(config, test.root, []string{test.remote})
+		if profile != test.profile || reason != test.reason {
+			t.Errorf("watchProfileFor(%s, %s) = %s, %s, expected %s, %s", test.root, test.remote, profile, reason, test.profile, test.reason)
+		}
+	}
+
+	config.Settings.DefaultProfile = ""
+	if profile, _ := watchProfileFor(config, "/src/other", nil); profile != "" {
+		t.Errorf("watchProfileFor without a default = %s, expected none", profile)
+	}
+}
+
+// TestRunWatcherLockedConfig tests that the background watcher stops on a
+// locked config instead of failing on every poll
+func TestRunWatcherLockedConfig(t *testing.T) {
+	setupConfigHome(t)
+	t.Setenv("GIT_USR_PASSPHRASE", "")
+	configPassphrase, configLocked = "hunter2", true
+	if err := saveConfig(defaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	configPassphrase, configLocked = "", false
+	t.Cleanup(func() { configPassphrase, configLocked, promptsDisabled = "", false, false })
+
+	done := make(chan error, 1)
+	go func() { done <- runWatcher(true) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errConfigLocked) {
+			t.Errorf("Expected errConfigLocked, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The watcher kept running on a locked config")
+	}
+
+	if err := startWatcher(); !errors.Is(err, errConfigLocked) {
+		t.Errorf("Expected watch start to refuse a locked config, got %v", err)
+	}
+}
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	fn()
+	w.Close()
+	return string(<-done)
+}
+
+// TestRemoveWatchDirNotWatched tests that removing a directory that isn't
+// watched says so instead of failing silently
+func TestRemoveWatchDirNotWatched(t *testing.T) {
+	setupConfigHome(t)
+	if err := saveConfig(defaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	var err error
+	out := captureStdout(t, func() { err = removeWatchDir(dir) })
+	if err == nil || strings.HasPrefix(err.Error(), "❌") {
+		t.Errorf("Expected a plain error, got %v", err)
+	}
+	if !strings.Contains(out, "❌ ") || !strings.Contains(out, "is not watched") {
+		t.Errorf("Expected the failure to be printed, got %q", out)
+	}
+}
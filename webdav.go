@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+func init() {
+	profileStores["webdav"] = newWebDAVStore
+}
+
+// newWebDAVStore keeps the profiles in a file on a WebDAV server such as
+// Nextcloud or a NAS, e.g. storeURL
+// "https://cloud.example.com/remote.php/dav/files/me/git-usr.json". It
+// logs in with GIT_USR_WEBDAV_USER and GIT_USR_WEBDAV_PASSWORD, or the
+// user and password in the URL
+func newWebDAVStore(location string) (ProfileStore, error) {
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("storeURL must be an http(s) URL for the webdav store")
+	}
+
+	user := os.Getenv("GIT_USR_WEBDAV_USER")
+	password := os.Getenv("GIT_USR_WEBDAV_PASSWORD")
+	if parsed.User != nil {
+		if user == "" {
+			user = parsed.User.Username()
+		}
+		if urlPassword, ok := parsed.User.Password(); ok && password == "" {
+			password = urlPassword
+		}
+		// Keep the password out of requests' URLs and the cache name
+		parsed.User = nil
+	}
+
+	store, err := newRemoteFileStore("webdav", parsed.String(), func(req *http.Request, body []byte) error {
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWebDAVStore tests a round trip with the credentials from the URL
+func TestWebDAVStore(t *testing.T) {
+	t.Setenv("GIT_USR_CONFIG", filepath.Join(t.TempDir(), "profiles.json"))
+	t.Setenv("GIT_USR_WEBDAV_USER", "")
+	t.Setenv("GIT_USR_WEBDAV_PASSWORD", "")
+
+	var file []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "me" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "PUT":
+			file, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+		case file == nil:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write(file)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := newWebDAVStore("/srv/git-usr.json"); err == nil {
+		t.Error("Expected an error for a storeURL that isn't a URL")
+	}
+	location := strings.Replace(server.URL, "://", "://me:s3cret@", 1) + "/dav/git-usr.json"
+	store, err := newWebDAVStore(location)
+	if err != nil {
+		t.Fatalf("newWebDAVStore failed: %v", err)
+	}
+	if strings.Contains(store.(*remoteFileStore).url, "s3cret") {
+		t.Errorf("Expected the password to be kept out of the URL, got %s", store.(*remoteFileStore).url)
+	}
+
+	if err := store.Save(map[string]Profile{"nas": {Name: "NAS", Email: "nas@home.example"}}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	profiles, err := store.Load()
+	if err != nil || profiles["nas"].Email != "nas@home.example" {
+		t.Errorf("Expected the saved profiles, got %v (%v)", profiles, err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// isWSL reports whether git-usr runs under the Windows Subsystem for Linux,
+// where git.exe and Linux git share checkouts but not their global config
+var isWSL = func() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// runWindowsGit runs git.exe of the Windows side through WSL interop. It
+// runs in / so it never looks at the repository of the current directory
+var runWindowsGit = func(args ...string) (string, error) {
+	cmd := exec.Command("git.exe", args...)
+	cmd.Dir = "/"
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("git.exe %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return strings.TrimRight(string(out), "\r\n"), err
+}
+
+// shouldMirrorToWindows reports whether a switch in scope is mirrored into
+// the Windows global config. Repository configs are shared by both gits
+func shouldMirrorToWindows(settings *Settings, scope string) bool {
+	return settings.Interop == "on" && scope == "global" && isWSL()
+}
+
+// windowsIdentity returns the global identity of the Windows-side git
+func windowsIdentity() (Profile, error) {
+	var profile Profile
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"user.name", &profile.Name},
+		{"user.email", &profile.Email},
+	} {
+		value, err := runWindowsGit("config", "--global", "--get", field.key)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			continue
+		}
+		if err != nil {
+			return Profile{}, err
+		}
+		*field.value = value
+	}
+	return profile, nil
+}
+
+// setWindowsIdentity sets the global identity of the Windows-side git
+func setWindowsIdentity(name, email string) error {
+	if _, err := runWindowsGit("config", "--global", "user.name", name); err != nil {
+		return err
+	}
+	_, err := runWindowsGit("config", "--global", "user.email", email)
+	return err
+}
+
+// showInterop prints the global identities of both sides and whether they
+// drifted apart
+func showInterop(config *Config) error {
+	linux := currentGitProfile("global")
+	windows, err := windowsIdentity()
+	if err != nil {
+		fmt.Printf("❌ Could not read the Windows git config: %v\n", err)
+		return err
+	}
+
+	describe := func(identity Profile) string {
+		if identity.Email == "" {
+			return "not set"
+		}
+		if name, ok := findProfileByIdentity(config.Profiles, identity.Name, identity.Email); ok {
+			return fmt.Sprintf("%s (%s)", formatAddress(identity.Name, identity.Email), name)
+		}
+		return formatAddress(identity.Name, identity.Email)
+	}
+	fmt.Printf("   Linux:   %s\n", describe(linux))
+	fmt.Printf("   Windows: %s\n", describe(windows))
+	if identityMatches(windows, linux.Name, linux.Email) {
+		fmt.Println("✅ Both use the same global identity")
+	} else {
+		fmt.Println("⚠️  The global identities differ; commits from git.exe and Linux git won't match")
+		fmt.Println("   Use 'git usr interop sync' to copy the Linux one to Windows (or --from-windows)")
+	}
+	if config.Settings.Interop != "on" {
+		fmt.Println("   'git usr config set interop on' keeps them in step on every global switch")
+	}
+	return nil
+}
+
+// syncInterop copies the global identity from one side to the other
+func syncInterop(fromWindows bool) error {
+	if fromWindows {
+		windows, err := windowsIdentity()
+		if err != nil {
+			fmt.Printf("❌ Could not read the Windows git config: %v\n", err)
+			return err
+		}
+		if windows.Name == "" || windows.Email == "" {
+			fmt.Println("❌ The Windows git config has no global identity")
+			return fmt.Errorf("no Windows identity")
+		}
+		if err := setGitConfig(windows.Name, windows.Email, "global"); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Linux git now uses %s globally, like Windows\n", formatAddress(windows.Name, windows.Email))
+		return nil
+	}
+
+	linux := currentGitProfile("global")
+	if linux.Name == "" || linux.Email == "" {
+		fmt.Println("❌ The Linux git config has no global identity")
+		return fmt.Errorf("no Linux identity")
+	}
+	if err := setWindowsIdentity(linux.Name, linux.Email); err != nil {
+		fmt.Printf("❌ Could not update the Windows git config: %v\n", err)
+		return err
+	}
+	fmt.Printf("✅ Windows git now uses %s globally, like Linux\n", formatAddress(linux.Name, linux.Email))
+	return nil
+}
+
+// runInterop handles the interop command
+func runInterop(args []string) error {
+	usage := "Usage: git usr interop [status] | sync [--from-windows]"
+	if !isWSL() {
+		fmt.Println("❌ Not running under WSL; there is no Windows git config to keep in step")
+		return fmt.Errorf("not running under WSL")
+	}
+
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "status":
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		return showInterop(config)
+	case args[0] == "sync" && len(args) == 1:
+		return syncInterop(false)
+	case args[0] == "sync" && len(args) == 2 && args[1] == "--from-windows":
+		return syncInterop(true)
+	}
+	fmt.Println(usage)
+	return fmt.Errorf("invalid interop command")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeWindowsGit runs git with a home of its own, standing in for git.exe
+// and the Windows global config
+func fakeWindowsGit(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	windowsHome := t.TempDir()
+	previous := runWindowsGit
+	t.Cleanup(func() { runWindowsGit = previous })
+	runWindowsGit = func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), "HOME="+windowsHome)
+		out, err := cmd.Output()
+		return strings.TrimRight(string(out), "\r\n"), err
+	}
+	return windowsHome
+}
+
+// TestShouldMirrorToWindows tests that only global switches under WSL
+// with interop on are mirrored
+func TestShouldMirrorToWindows(t *testing.T) {
+	previous := isWSL
+	t.Cleanup(func() { isWSL = previous })
+	wsl := true
+	isWSL = func() bool { return wsl }
+
+	settings := &Settings{}
+	if shouldMirrorToWindows(settings, "global") {
+		t.Error("mirrored with interop off")
+	}
+	settings.Interop = "on"
+	if !shouldMirrorToWindows(settings, "global") || shouldMirrorToWindows(settings, "local") {
+		t.Error("interop=on should mirror global switches only")
+	}
+	wsl = false
+	if shouldMirrorToWindows(settings, "global") {
+		t.Error("mirrored outside WSL")
+	}
+}
+
+// TestSyncInterop tests copying the global identity in both directions
+func TestSyncInterop(t *testing.T) {
+	setupConfigHome(t)
+	windowsHome := fakeWindowsGit(t)
+
+	if identity, err := windowsIdentity(); err != nil || identity.Email != "" {
+		t.Fatalf("windowsIdentity() = %+v, %v, expected none", identity, err)
+	}
+
+	if err := setGitConfig("Jane Doe", "jane@home.example", "global"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncInterop(false); err != nil {
+		t.Fatalf("syncInterop() error = %v", err)
+	}
+	if identity, _ := windowsIdentity(); !identityMatches(identity, "Jane Doe", "jane@home.example") {
+		t.Errorf("Windows identity = %+v after syncing from Linux", identity)
+	}
+
+	if err := os.WriteFile(filepath.Join(windowsHome, ".gitconfig"), []byte("[user]\n\tname = Jane Work\n\temail = jane@acme.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncInterop(true); err != nil {
+		t.Fatalf("syncInterop(fromWindows) error = %v", err)
+	}
+	if linux := currentGitProfile("global"); !identityMatches(linux, "Jane Work", "jane@acme.example") {
+		t.Errorf("Linux identity = %+v after syncing from Windows", linux)
+	}
+}
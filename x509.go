@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultX509Program is what git runs for gpg.format=x509 unless
+// gpg.x509.program says otherwise
+const defaultX509Program = "gpgsm"
+
+// x509Program returns the program a profile signs X.509 commits with
+func x509Program(profile Profile) string {
+	if profile.X509Program != "" {
+		return profile.X509Program
+	}
+	return defaultX509Program
+}
+
+// parseGPGSMSecretCerts parses `gpgsm --list-secret-keys --with-colons`
+// output, keeping certificates that can sign
+func parseGPGSMSecretCerts(listing string) []gpgKey {
+	var certs []gpgKey
+	var current *gpgKey
+	for _, line := range strings.Split(listing, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 10 {
+			continue
+		}
+		switch fields[0] {
+		case "crs":
+			current = nil
+			// Skip revoked, expired and invalid certificates
+			if strings.ContainsAny(fields[1], "reid") || len(fields) < 12 || !strings.Contains(fields[11], "s") {
+				continue
+			}
+			cert := gpgKey{}
+			if expires, err := time.Parse("20060102T150405", fields[6]); err == nil {
+				cert.Expires = expires
+			}
+			certs = append(certs, cert)
+			current = &certs[len(certs)-1]
+		case "fpr":
+			if current != nil && current.Fingerprint == "" {
+				current.Fingerprint = fields[9]
+			}
+		case "uid":
+			// The first user ID is the subject DN, later ones are emails
+			if current != nil && current.UID == "" {
+				current.UID = fields[9]
+			}
+		}
+	}
+	return certs
+}
+
+// parseSmimesignKeys parses `smimesign --list-keys` output, keeping
+// certificates for email
+func parseSmimesignKeys(listing, email string) []gpgKey {
+	var certs []gpgKey
+	var cert gpgKey
+	var emails []string
+	flush := func() {
+		for _, certEmail := range emails {
+			if cert.Fingerprint != "" && strings.EqualFold(certEmail, email) {
+				certs = append(certs, cert)
+				break
+			}
+		}
+		cert, emails = gpgKey{}, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(listing))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			flush()
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "ID":
+			cert.Fingerprint = value
+		case "Subject":
+			cert.UID = value
+		case "Validity":
+			// "<not before> - <not after>" in Go's time.Time format
+			if _, notAfter, ok := strings.Cut(value, " - "); ok {
+				if expires, err := time.Parse("2006-01-02 15:04:05 -0700 MST", notAfter); err == nil {
+					cert.Expires = expires
+				}
+			}
+		case "Emails":
+			for _, certEmail := range strings.Split(value, ",") {
+				emails = append(emails, strings.TrimSpace(certEmail))
+			}
+		}
+	}
+	flush()
+	return certs
+}
+
+// listX509Certs returns the signing certificates of program for email
+func listX509Certs(program, email string) ([]gpgKey, error) {
+	switch filepath.Base(program) {
+	case "gpgsm":
+		out, err := exec.Command(program, "--list-secret-keys", "--with-colons", "<"+email+">").Output()
+		if err != nil {
+			// gpgsm fails when nothing matches
+			return nil, nil
+		}
+		return parseGPGSMSecretCerts(string(out)), nil
+	case "smimesign":
+		out, err := exec.Command(program, "--list-keys").Output()
+		if err != nil {
+			return nil, fmt.Errorf("smimesign --list-keys: %w", err)
+		}
+		return parseSmimesignKeys(string(out), email), nil
+	}
+	return nil, fmt.Errorf("can't list certificates of %s (use --key)", program)
+}
+
+// setupX509Signing selects a certificate for the profile and stores it
+// with the program that signs with it
+func setupX509Signing(profileName, keyID, program string) error {
+	profile, err := getProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	storedProgram := program
+	if program == "" {
+		program = defaultX509Program
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		fmt.Printf("❌ %s not found in PATH\n", program)
+		return fmt.Errorf("%s not found", program)
+	}
+
+	if keyID == "" {
+		certs, err := listX509Certs(program, profile.Email)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return err
+		}
+
+		switch {
+		case len(certs) == 0:
+			fmt.Printf("❌ No signing certificate for %s found by %s\n", profile.Email, program)
+			fmt.Println("   Import the certificate issued to you, then run this again")
+			return fmt.Errorf("no certificate")
+		case len(certs) == 1:
+			keyID = certs[0].Fingerprint
+		case !isInteractive():
+			fmt.Printf("❌ Several certificates match %s (use --key to pick one)\n", profile.Email)
+			return fmt.Errorf("ambiguous certificate")
+		default:
+			cert, err := chooseGPGKey(certs)
+			if err != nil {
+				return err
+			}
+			keyID = cert.Fingerprint
+		}
+	}
+
+	if err := updateProfileSigning(profileName, "x509", keyID, storedProgram); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ '%s' now signs commits and tags with X.509 certificate %s using %s\n", profileName, keyID, program)
+	fmt.Printf("   Run 'git usr %s' to apply it, and 'git usr doctor %s' to check the certificate chain\n", profileName, profileName)
+	return nil
+}
+
+// x509SignProblems explains the reason codes of gpg's INV_SGNR status line
+var x509SignProblems = map[string]string{
+	"1":  "certificate not found",
+	"3":  "certificate not usable for signing",
+	"4":  "certificate revoked",
+	"5":  "certificate expired",
+	"6":  "no CRL known for the certificate",
+	"7":  "the CRL is too old",
+	"9":  "no private key for the certificate",
+	"10": "certificate chain does not end at a trusted root",
+	"11": "certificate missing",
+	"12": "issuer certificate missing from the chain",
+}
+
+// statusField returns the fields after keyword of the first gpg status
+// line with that keyword
+func statusField(status, keyword string) ([]string, bool) {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "[GNUPG:] "))
+		if len(fields) > 0 && fields[0] == keyword {
+			return fields[1:], true
+		}
+	}
+	return nil, false
+}
+
+// parseStatusTime parses the timestamps of gpg status lines, which are
+// either seconds since the epoch or ISO 8601 basic format
+func parseStatusTime(value string) (time.Time, bool) {
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
+
+// checkX509Signing signs a probe message with the profile's certificate
+// and verifies it the way git does, so the chain is checked by the same
+// program and trust store git uses. It returns when the certificate
+// expires
+func checkX509Signing(profile Profile) (time.Time, error) {
+	program := x509Program(profile)
+	if _, err := exec.LookPath(program); err != nil {
+		return time.Time{}, fmt.Errorf("%s not found in PATH", program)
+	}
+
+	probe := []byte("git-usr doctor\n")
+	var signature, signStatus bytes.Buffer
+	sign := exec.Command(program, "--status-fd=2", "-bsau", profile.SigningKey)
+	sign.Stdin = bytes.NewReader(probe)
+	sign.Stdout = &signature
+	sign.Stderr = &signStatus
+	if err := sign.Run(); err != nil {
+		if fields, ok := statusField(signStatus.String(), "INV_SGNR"); ok && len(fields) > 0 {
+			if problem, known := x509SignProblems[fields[0]]; known {
+				return time.Time{}, fmt.Errorf("%s", problem)
+			}
+		}
+		return time.Time{}, fmt.Errorf("signing failed: %s", lastLine(signStatus.String()))
+	}
+
+	sigFile, err := writeTempFile("git-usr-doctor-*.sig", signature.Bytes())
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(sigFile)
+
+	verify := exec.Command(program, "--status-fd=1", "--verify", sigFile, "-")
+	verify.Stdin = bytes.NewReader(probe)
+	out, _ := verify.Output()
+	status := string(out)
+
+	if _, ok := statusField(status, "GOODSIG"); !ok {
+		return time.Time{}, fmt.Errorf("the signature does not verify")
+	}
+	_, fully := statusField(status, "TRUST_FULLY")
+	_, ultimate := statusField(status, "TRUST_ULTIMATE")
+	if !fully && !ultimate {
+		return time.Time{}, fmt.Errorf("certificate chain does not end at a trusted root")
+	}
+
+	var expires time.Time
+	// VALIDSIG <fingerprint> <date> <created> <expires> ...
+	if fields, ok := statusField(status, "VALIDSIG"); ok && len(fields) > 3 {
+		expires, _ = parseStatusTime(fields[3])
+	}
+	return expires, nil
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseGPGSMSecretCerts tests reading gpgsm's colon listing
+func TestParseGPGSMSecretCerts(t *testing.T) {
+	listing := `crs::2048:1:3DC5EB9F431A76E9:20261016T164328:20261105T164328:3808C35664091EBCDFFC993C6F9A2F1C742B3972::CN=Test CA::sS::::::23:
+fpr:::::::::03FF96BE110818B83AD416553DC5EB9F431A76E9::::
+uid:::::::::CN=John Doe::
+uid:::::::::<john@work.com>::
+crs:e:2048:1:AAAAAAAAAAAAAAAA:20240101T000000:20250101T000000:01::CN=Test CA::sS::::::23:
+fpr:::::::::EXPIRED0000000000000000000000000000000000::::
+`
+	certs := parseGPGSMSecretCerts(listing)
+	if len(certs) != 1 {
+		t.Fatalf("Expected 1 usable certificate, got %+v", certs)
+	}
+	if certs[0].Fingerprint != "03FF96BE110818B83AD416553DC5EB9F431A76E9" || certs[0].UID != "CN=John Doe" {
+		t.Errorf("Unexpected certificate: %+v", certs[0])
+	}
+	if !certs[0].Expires.Equal(time.Date(2026, 11, 5, 16, 43, 28, 0, time.UTC)) {
+		t.Errorf("Unexpected expiry: %v", certs[0].Expires)
+	}
+}
+
+// TestParseSmimesignKeys tests matching smimesign's listing by email
+func TestParseSmimesignKeys(t *testing.T) {
+	listing := `       ID: 0d4fa5e8c9a1b2c3d4e5f60718293a4b5c6d7e8f
+      S/N: 1a2b
+Algorithm: SHA256-RSA
+ Validity: 2026-01-01 00:00:00 +0000 UTC - 2027-01-01 00:00:00 +0000 UTC
+   Issuer: CN=Corp CA
+  Subject: CN=John Doe
+   Emails: john@work.com, jdoe@work.com
+
+       ID: 99999999c9a1b2c3d4e5f60718293a4b5c6d7e8f
+  Subject: CN=Someone Else
+   Emails: else@work.com
+`
+	certs := parseSmimesignKeys(listing, "JDOE@work.com")
+	if len(certs) != 1 || certs[0].Fingerprint != "0d4fa5e8c9a1b2c3d4e5f60718293a4b5c6d7e8f" {
+		t.Fatalf("Expected John's certificate, got %+v", certs)
+	}
+	if certs[0].Expires.Year() != 2027 {
+		t.Errorf("Unexpected expiry: %v", certs[0].Expires)
+	}
+}
+
+// TestStatusField tests reading gpg status lines
+func TestStatusField(t *testing.T) {
+	status := "[GNUPG:] NEWSIG\n[GNUPG:] GOODSIG 03FF /CN=John Doe\n" +
+		"[GNUPG:] VALIDSIG 03FF 2026-10-16 20261016T164335 20261105T164328 0 0 1 8 00\n[GNUPG:] TRUST_FULLY 0 shell\n"
+
+	fields, ok := statusField(status, "VALIDSIG")
+	if !ok || len(fields) < 4 {
+		t.Fatalf("Expected VALIDSIG fields, got %v", fields)
+	}
+	if expires, ok := parseStatusTime(fields[3]); !ok || expires.Format("2006-01-02") != "2026-11-05" {
+		t.Errorf("Unexpected expiry: %v", expires)
+	}
+	if _, ok := statusField(status, "TRUST_UNDEFINED"); ok {
+		t.Error("Expected no TRUST_UNDEFINED line")
+	}
+	if seconds, ok := parseStatusTime("1700000000"); !ok || seconds.Unix() != 1700000000 {
+		t.Errorf("Expected epoch timestamps to parse, got %v", seconds)
+	}
+}
+
+// TestSigningKeysX509Program tests that the program is only set for x509
+func TestSigningKeysX509Program(t *testing.T) {
+	profile := Profile{SigningKey: "03FF", SigningFormat: "x509", X509Program: "smimesign"}
+	found := false
+	for _, key := range signingKeys(profile) {
+		if key.Key == "gpg.x509.program" && key.Value == "smimesign" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected gpg.x509.program, got %v", signingKeys(profile))
+	}
+
+	profile.SigningFormat = "openpgp"
+	for _, key := range signingKeys(profile) {
+		if key.Key == "gpg.x509.program" {
+			t.Error("Expected no gpg.x509.program for openpgp")
+		}
+	}
+}